@@ -0,0 +1,50 @@
+package catalog
+
+import "fmt"
+
+// SetSymbolAliases replaces the aliases of the root directory, which map a
+// symbol to the old one its data was stored under before a ticker change,
+// e.g. "META" to "FB".  An old symbol may be an alias itself, but the
+// aliases must not form a cycle.
+func (d *Directory) SetSymbolAliases(aliases map[string]string) error {
+	if err := ValidateSymbolAliases(aliases); err != nil {
+		return err
+	}
+	m := make(map[string]string, len(aliases))
+	for symbol, old := range aliases {
+		m[symbol] = old
+	}
+	d.Lock()
+	d.aliases = m
+	d.Unlock()
+	return nil
+}
+
+// SymbolAliases returns the old symbols of symbol, from the most recent one,
+// or nil if it has none.
+func (d *Directory) SymbolAliases(symbol string) (olds []string) {
+	d.RLock()
+	defer d.RUnlock()
+	for old, ok := d.aliases[symbol]; ok; old, ok = d.aliases[old] {
+		olds = append(olds, old)
+	}
+	return olds
+}
+
+// ValidateSymbolAliases returns an error if a symbol of aliases is an alias
+// of itself, directly or through its old symbols.
+func ValidateSymbolAliases(aliases map[string]string) error {
+	for symbol := range aliases {
+		seen := map[string]bool{symbol: true}
+		for old, ok := aliases[symbol]; ok; old, ok = aliases[old] {
+			if old == "" {
+				return fmt.Errorf("empty alias of symbol %s", symbol)
+			}
+			if seen[old] {
+				return fmt.Errorf("cyclic alias of symbol %s", symbol)
+			}
+			seen[old] = true
+		}
+	}
+	return nil
+}
@@ -10,7 +10,9 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/io/blockfile"
 )
 
 type DMap map[string]*Directory              // General purpose map for storing directories
@@ -33,14 +35,27 @@ type Directory struct {
 	/*
 		datafile[Key]: Key is the fully specified path to the datafile, including rootPath and filename
 	*/
+	tierPaths []string
+	/*
+		tierPaths: root paths of the colder storage tiers, set on the root directory only
+	*/
+	aliases map[string]string
+	/*
+		aliases[Key]: Key is the symbol, of which the data is also read from the old symbol, set on the root directory only
+	*/
 }
 
-func NewDirectory(rootpath string) *Directory {
+// NewDirectory loads the catalog in rootpath, along with the year files in the
+// colder storage tiers at tierPaths, which have the same directory structure
+// as rootpath.  The directories of the buckets must exist in rootpath for
+// their files in the tiers to be found.
+func NewDirectory(rootpath string, tierPaths ...string) *Directory {
 	d := &Directory{
 		// Directmap will point to each directory node using a composite key
 		directMap: make(DMap),
 	}
 	d.load(rootpath)
+	d.loadTiers(rootpath, tierPaths)
 	return d
 }
 
@@ -128,7 +143,11 @@ func (dRoot *Directory) AddTimeBucket(tbk *io.TimeBucketKey, f *io.TimeBucketInf
 	*/
 	childNodeName := datakeySplit[0]
 	childNodePath := filepath.Join(dRoot.GetPath(), childNodeName)
-	childDirectory := NewDirectory(childNodePath)
+	childTierPaths := make([]string, len(dRoot.tierPaths))
+	for i, tierPath := range dRoot.tierPaths {
+		childTierPaths[i] = filepath.Join(tierPath, childNodeName)
+	}
+	childDirectory := NewDirectory(childNodePath, childTierPaths...)
 	dRoot.addSubdir(childDirectory, childNodeName)
 	return nil
 }
@@ -159,6 +178,10 @@ func (dRoot *Directory) RemoveTimeBucket(tbk *io.TimeBucketKey) (err error) {
 	for i := end; i >= 0; i-- {
 		if i == end {
 			removeDirFiles(tree[i])
+			// along with the other files of the bucket in the storage tiers
+			for _, tierPath := range dRoot.tierPaths {
+				os.RemoveAll(tbk.GetPathToYearFiles(tierPath))
+			}
 			deleteMap[i] = true // This dir was deleted, we'll remove it from the parent's subdir list later
 		} else {
 			if deleteMap[i+1] {
@@ -308,6 +331,23 @@ func (subDir *Directory) AddFile(newYear int16) (finfo_p *io.TimeBucketInfo, err
 	return newFileInfo, nil
 }
 
+// RemoveYearFile removes the year file at fullFilePath from the catalog,
+// leaving the file itself in place for the reads of it in progress.
+func (d *Directory) RemoveYearFile(fullFilePath string) error {
+	// Must be thread-safe for WRITE access
+	subDir, err := d.GetOwningSubDirectory(fullFilePath)
+	if err != nil {
+		return err
+	}
+	subDir.Lock()
+	defer subDir.Unlock()
+	if _, ok := subDir.datafile[fullFilePath]; !ok {
+		return NotFoundError(fullFilePath)
+	}
+	delete(subDir.datafile, fullFilePath)
+	return nil
+}
+
 func (d *Directory) DirHasDataFiles() bool {
 	d.RLock()
 	defer d.RUnlock()
@@ -657,7 +697,82 @@ func (d *Directory) load(rootPath string) error {
 	return loader(d, rootPath, rootPath)
 }
 
+func (d *Directory) loadTiers(rootPath string, tierPaths []string) {
+	// loadTiers is single thread compatible as load is
+	d.tierPaths = tierPaths
+	if len(tierPaths) == 0 {
+		return
+	}
+	rootDmap := d.directMap
+	addTierFiles := func(d *Directory, _ interface{}) {
+		if d.category != "Year" {
+			return
+		}
+		relPath, err := filepath.Rel(rootPath, d.pathToItemName)
+		if err != nil {
+			return
+		}
+		for _, tierPath := range tierPaths {
+			tierDir := filepath.Join(tierPath, relPath)
+			filelist, err := ioutil.ReadDir(tierDir)
+			if err != nil {
+				continue
+			}
+			for _, file := range filelist {
+				if file.IsDir() || filepath.Ext(file.Name()) != ".bin" {
+					continue
+				}
+				yearInt, err := strconv.Atoi(strings.TrimSuffix(file.Name(), ".bin"))
+				if err != nil || d.hasYearFile(int16(yearInt)) {
+					// a year file is read from the hottest tier it is in
+					continue
+				}
+				if d.datafile == nil {
+					d.datafile = make(map[string]*io.TimeBucketInfo)
+				}
+				leafPath := filepath.Join(tierDir, file.Name())
+				d.datafile[leafPath] = &io.TimeBucketInfo{Year: int16(yearInt), Path: leafPath}
+				// the directory is found by the path of any of its files
+				rootDmap[d.pathToItemName] = d
+				rootDmap[tierDir] = d
+			}
+		}
+	}
+	d.recurse(nil, addTierFiles)
+}
+
+// BucketFilePath returns the path of the file named name in the directory of
+// the bucket, e.g. a side file of its records, in the hottest storage tier
+// it is in, or in the root directory if it is in none.
+func (d *Directory) BucketFilePath(tbk *io.TimeBucketKey, name string) string {
+	hotPath := filepath.Join(tbk.GetPathToYearFiles(d.GetPath()), name)
+	if fileExists(hotPath) {
+		return hotPath
+	}
+	for _, tierPath := range d.tierPaths {
+		if tierFile := filepath.Join(tbk.GetPathToYearFiles(tierPath), name); fileExists(tierFile) {
+			return tierFile
+		}
+	}
+	return hotPath
+}
+
+func (d *Directory) hasYearFile(year int16) bool {
+	for _, tbi := range d.datafile {
+		if tbi.Year == year {
+			return true
+		}
+	}
+	return false
+}
+
 func removeDirFiles(td *Directory) {
+	// the year files in the storage tiers are outside of the directory
+	for filePath := range td.datafile {
+		if filepath.Dir(filePath) != td.pathToItemName {
+			os.Remove(filePath)
+		}
+	}
 	os.RemoveAll(td.pathToItemName)
 }
 
@@ -670,6 +785,13 @@ func newTimeBucketInfoFromTemplate(newTimeBucketInfo *io.TimeBucketInfo) (err er
 	if _, err := os.Stat(newTimeBucketInfo.Path); err == nil {
 		return FileAlreadyExists("Can not overwrite file")
 	}
+	// the fixed length files of the timeframes configured are compressed
+	codec := blockfile.None
+	if newTimeBucketInfo.GetRecordType() == io.FIXED {
+		codec, _ = blockfile.ParseCodec(utils.InstanceConfig.Compression[newTimeBucketInfo.GetTimeframe()])
+	}
+	newTimeBucketInfo.SetCodec(codec)
+
 	// Create the file
 	fp, err := os.OpenFile(newTimeBucketInfo.Path, os.O_CREATE|os.O_RDWR, 0600)
 	defer fp.Close()
@@ -685,7 +807,12 @@ func newTimeBucketInfoFromTemplate(newTimeBucketInfo *io.TimeBucketInfo) (err er
 		int(newTimeBucketInfo.Year),
 		int(newTimeBucketInfo.GetRecordLength()),
 	)
-	if err = fp.Truncate(fileSize); err != nil {
+	if codec != blockfile.None {
+		err = blockfile.Create(fp, io.Headersize, fileSize-io.Headersize)
+	} else {
+		err = fp.Truncate(fileSize)
+	}
+	if err != nil {
 		return UnableToCreateFile(err.Error())
 	}
 
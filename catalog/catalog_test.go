@@ -2,8 +2,10 @@ package catalog
 
 import (
 	"fmt"
+	"io/ioutil"
 	"path"
 	"testing"
+	"time"
 
 	. "gopkg.in/check.v1"
 
@@ -298,3 +300,63 @@ func exists(path string) bool {
 	}
 	return true
 }
+
+func (s *TestSuite) TestStorageTiers(c *C) {
+	rootDir, tierDir := c.MkDir(), c.MkDir()
+	MakeDummyCurrencyDir(rootDir, false, false)
+
+	// the older years of a bucket are in the cold tier, with a stale copy of 2001
+	hotDir := filepath.Join(rootDir, "EURUSD/1Min/OHLC")
+	coldDir := filepath.Join(tierDir, "EURUSD/1Min/OHLC")
+	c.Assert(os.MkdirAll(coldDir, 0770), IsNil)
+	c.Assert(os.Rename(filepath.Join(hotDir, "2000.bin"), filepath.Join(coldDir, "2000.bin")), IsNil)
+	buf, err := ioutil.ReadFile(filepath.Join(hotDir, "2001.bin"))
+	c.Assert(err, IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(coldDir, "2001.bin"), buf, 0600), IsNil)
+
+	d := NewDirectory(rootDir, tierDir)
+	c.Assert(len(d.GatherTimeBucketInfo()), Equals, 54)
+	tbi, err := d.PathToTimeBucketInfo(filepath.Join(coldDir, "2000.bin"))
+	c.Assert(err, IsNil)
+	c.Assert(tbi.Year, Equals, int16(2000))
+	c.Assert(tbi.GetTimeframe(), Equals, time.Minute)
+	_, err = d.PathToTimeBucketInfo(filepath.Join(coldDir, "2001.bin"))
+	c.Assert(err, NotNil)
+
+	// the directory of the bucket is found by the paths in either tier
+	subDir, err := d.GetOwningSubDirectory(filepath.Join(coldDir, "2000.bin"))
+	c.Assert(err, IsNil)
+	c.Assert(subDir.GetPath(), Equals, hotDir)
+	c.Assert(subDir.GetTimeBucketInfoSlice(), HasLen, 3)
+
+	// the tier files are kept when a bucket is added alongside
+	tbk := io.NewTimeBucketKey("EURUSD/1Min/TICK")
+	dsv := io.NewDataShapeVector([]string{"Bid"}, []io.EnumElementType{io.FLOAT32})
+	tbinfo := io.NewTimeBucketInfo(*utils.TimeframeFromString("1Min"), tbk.GetPathToYearFiles(rootDir),
+		"Test", 2001, dsv, io.FIXED)
+	c.Assert(d.AddTimeBucket(tbk, tbinfo), IsNil)
+	_, err = d.PathToTimeBucketInfo(filepath.Join(coldDir, "2000.bin"))
+	c.Assert(err, IsNil)
+
+	// the other files of the bucket are found in the tier they are in
+	ohlc := io.NewTimeBucketKey("EURUSD/1Min/OHLC")
+	c.Assert(d.BucketFilePath(ohlc, "strings.dat"), Equals, filepath.Join(hotDir, "strings.dat"))
+	c.Assert(ioutil.WriteFile(filepath.Join(coldDir, "strings.dat"), nil, 0600), IsNil)
+	c.Assert(d.BucketFilePath(ohlc, "strings.dat"), Equals, filepath.Join(coldDir, "strings.dat"))
+
+	// and removed with it
+	c.Assert(d.RemoveTimeBucket(ohlc), IsNil)
+	_, err = os.Stat(coldDir)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *TestSuite) TestSymbolAliases(c *C) {
+	d := &Directory{}
+	c.Assert(d.SetSymbolAliases(map[string]string{"META": "FB", "FB": "THEFACEBOOK"}), IsNil)
+	c.Assert(d.SymbolAliases("META"), DeepEquals, []string{"FB", "THEFACEBOOK"})
+	c.Assert(d.SymbolAliases("AAPL"), IsNil)
+
+	err := d.SetSymbolAliases(map[string]string{"A": "B", "B": "A"})
+	c.Assert(err, ErrorMatches, "cyclic alias of symbol .")
+	c.Assert(d.SymbolAliases("META"), HasLen, 2)
+}
@@ -0,0 +1,66 @@
+package backup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	usage = "backup"
+	short = "Back up the data directory of a running server"
+	long  = `This command makes a consistent copy of the root directory of a running
+server through its utilities endpoint.  The backup has all the writes
+committed before it is taken and none after, and can be used as the root
+directory of a fresh instance as is.  The server must have utilities_url and
+backup_directory set, and the output directory must be in its
+backup_directory, given as an absolute path or relative to it.`
+	example = "marketstore backup --out mktsdb-20200102 --url localhost:5994"
+)
+
+var (
+	// Cmd is the backup command.
+	Cmd = &cobra.Command{
+		Use:     usage,
+		Short:   short,
+		Long:    long,
+		Example: example,
+		RunE:    executeBackup,
+	}
+	// OutDir is the directory the backup is written to.
+	OutDir string
+	// UtilitiesURL is the address of the utilities endpoint of the server.
+	UtilitiesURL string
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&OutDir, "out", "o", "",
+		"Directory to write the backup to, in the backup_directory of the server, which must not exist or be empty")
+	Cmd.Flags().StringVarP(&UtilitiesURL, "url", "u", "localhost:5994",
+		"Address of the utilities endpoint (utilities_url) of the server")
+	Cmd.MarkFlagRequired("out")
+}
+
+func executeBackup(cmd *cobra.Command, args []string) error {
+	// relative to the backup directory of the server
+	out := OutDir
+	base := UtilitiesURL
+	if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+	resp, err := http.Post(base+"/backup?out="+url.QueryEscape(out), "", nil)
+	if err != nil {
+		return fmt.Errorf("failed to request the backup: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("backup failed: %s", strings.TrimSpace(string(body)))
+	}
+	fmt.Printf("backed up to %s\n", out)
+	return nil
+}
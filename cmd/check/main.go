@@ -0,0 +1,94 @@
+package check
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/spf13/cobra"
+)
+
+const (
+	usage = "check"
+	short = "Check the integrity of the data files"
+	long  = `This command checks the header, the size and the record indexes of each
+data file in the directory, and reports the corrupt files.  With --repair,
+the records from the first corrupt one onward are cleared, so that the file
+has only the records before the corruption.  Corrupt headers are not repaired.
+
+It exits with status 1 if any corruption is found, even if repaired,
+and with status 2 if the directory cannot be checked.
+Do not run it with --repair on the directory of a running server.`
+	example = "marketstore check --dir <path> --repair"
+)
+
+var (
+	// Cmd is the check command.
+	Cmd = &cobra.Command{
+		Use:     usage,
+		Short:   short,
+		Long:    long,
+		Example: example,
+		RunE:    executeCheck,
+	}
+	// RootDir is the data directory to check.
+	RootDir string
+	// Repair clears the corrupt records if true.
+	Repair bool
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&RootDir, "dir", "d", "",
+		"Data directory (root_directory) to check")
+	Cmd.Flags().BoolVar(&Repair, "repair", false,
+		"Clear the records from the first corrupt one onward")
+	Cmd.MarkFlagRequired("dir")
+}
+
+func executeCheck(cmd *cobra.Command, args []string) error {
+	corrupt, err := checkDir()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if corrupt > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// checkDir prints the corrupt files in the directory, and returns the number of them.
+func checkDir() (corrupt int, err error) {
+	rootDir := filepath.Clean(RootDir)
+	if fi, err := os.Stat(rootDir); err != nil {
+		return 0, err
+	} else if !fi.IsDir() {
+		return 0, fmt.Errorf("%s is not a directory", rootDir)
+	}
+
+	checks, err := executor.CheckDataDir(rootDir, Repair)
+	if err != nil {
+		return 0, err
+	}
+	var repaired int
+	for _, fc := range checks {
+		if fc.OK() {
+			continue
+		}
+		corrupt++
+		status := "CORRUPT"
+		if fc.Repaired {
+			repaired++
+			status = "REPAIRED"
+		}
+		name := fc.Path
+		if fc.Key != "" {
+			name = fmt.Sprintf("%s %d", fc.Key, fc.Year)
+		}
+		fmt.Printf("%-8s %s: %s\n", status, name, strings.Join(fc.Problems, "; "))
+	}
+	fmt.Printf("checked %d files, %d corrupt, %d repaired\n", len(checks), corrupt, repaired)
+	return corrupt, nil
+}
@@ -1,10 +1,13 @@
 package loader
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/utils/io"
 )
 
 // Hook up gocheck into the "go test" runner.
@@ -46,3 +49,61 @@ func (s *LoaderTests) TestParseTimestamp(c *C) {
 	c.Assert(err == nil, Equals, true)
 	c.Assert(tt1 == tTest, Equals, true)
 }
+
+func (s *LoaderTests) TestCSVtoCSM(c *C) {
+	dsv := []io.DataShape{
+		{Name: "Epoch", Type: io.INT64},
+		{Name: "Open", Type: io.FLOAT32},
+		{Name: "Volume", Type: io.INT64},
+	}
+	tbk := *io.NewTimeBucketKey("AAPL/1Min/OHLCV")
+	conf := &CSVConfig{
+		FirstRowHasColumnNames: true,
+		TimeFormat:             "2006-01-02 15:04",
+		Timezone:               "America/New_York",
+		ColumnRenames:          map[string]string{"vol": "Volume", "Time": "Epoch"},
+	}
+	data := "Time,Open,Vol\n" +
+		"2020-01-02 09:30,1.5,100\n" +
+		"2020-01-02 09:31,1.75,200\n" +
+		"2020-01-02 09:32,x,300\n"
+
+	csvReader, cvm, err := ReadMetadataWithConfig(strings.NewReader(data), conf, dsv)
+	c.Assert(err, IsNil)
+
+	csm, endReached, err := CSVtoCSM(csvReader, tbk, cvm, 2, false)
+	c.Assert(err, IsNil)
+	c.Assert(endReached, Equals, false)
+	cs := csm[tbk]
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{
+		time.Date(2020, 1, 2, 14, 30, 0, 0, time.UTC).Unix(),
+		time.Date(2020, 1, 2, 14, 31, 0, 0, time.UTC).Unix(),
+	})
+	c.Assert(cs.GetColumn("Open"), DeepEquals, []float32{1.5, 1.75})
+	c.Assert(cs.GetColumn("Volume"), DeepEquals, []int64{100, 200})
+	c.Assert(cs.Exists("Nanoseconds"), Equals, false)
+
+	// the row number counts from the beginning of the file
+	_, _, err = CSVtoCSM(csvReader, tbk, cvm, 2, false)
+	pe, ok := err.(*ParseError)
+	c.Assert(ok, Equals, true)
+	c.Assert(pe.Row, Equals, 3)
+	c.Assert(pe.Column, Equals, "Open")
+}
+
+func (s *LoaderTests) TestCSVtoCSMMalformed(c *C) {
+	dsv := []io.DataShape{
+		{Name: "Epoch", Type: io.INT64},
+		{Name: "Open", Type: io.FLOAT32},
+	}
+	tbk := *io.NewTimeBucketKey("AAPL/1Min/OHLCV")
+	conf := &CSVConfig{FirstRowHasColumnNames: true, TimeFormat: "timestamp"}
+	data := "Epoch,Open\n1577972400,1.5\n1577972460\n"
+
+	csvReader, cvm, err := ReadMetadataWithConfig(strings.NewReader(data), conf, dsv)
+	c.Assert(err, IsNil)
+	_, _, err = CSVtoCSM(csvReader, tbk, cvm, 10, false)
+	pe, ok := err.(*ParseError)
+	c.Assert(ok, Equals, true)
+	c.Assert(pe.Row, Equals, 2)
+}
@@ -6,8 +6,7 @@ import (
 )
 
 // readTimeColumns retuns the epoch and nano columns of a csv file.
-func readTimeColumns(csvData [][]string, columnIndex []int, conf *CSVConfig) (epochCol []int64, nanosCol []int32) {
-	var err error
+func readTimeColumns(csvData [][]string, columnIndex []int, conf *CSVConfig) (epochCol []int64, nanosCol []int32, err error) {
 	epochCol = make([]int64, len(csvData))
 	nanosCol = make([]int32, len(csvData))
 	/*
@@ -17,8 +16,7 @@ func readTimeColumns(csvData [][]string, columnIndex []int, conf *CSVConfig) (ep
 	mustComposeEpoch := columnIndex[2] == -1
 	if mustComposeEpoch {
 		if columnIndex[0] == -1 || columnIndex[1] == -1 {
-			fmt.Println("Unable to build Epoch time from mapping - need both a date and time")
-			return nil, nil
+			return nil, nil, fmt.Errorf("Unable to build Epoch time from mapping - need both a date and time")
 		}
 	}
 
@@ -30,8 +28,7 @@ func readTimeColumns(csvData [][]string, columnIndex []int, conf *CSVConfig) (ep
 	if len(conf.Timezone) != 0 {
 		tzLoc, err = time.LoadLocation(conf.Timezone)
 		if err != nil {
-			fmt.Printf("Unable to parse timezone %s: %s\n", conf.Timezone, err.Error())
-			return nil, nil
+			return nil, nil, fmt.Errorf("Unable to parse timezone %s: %s", conf.Timezone, err.Error())
 		}
 	}
 
@@ -57,13 +54,11 @@ func readTimeColumns(csvData [][]string, columnIndex []int, conf *CSVConfig) (ep
 			firstParse = false
 		}
 		if err != nil {
-			fmt.Printf("Error parsing Epoch column(s) from input data file: %s\n", err.Error())
-			fmt.Printf("rowTime %v, mustComposeEpoch %v, dateTime %v, format %v, loc %v, fromAdj %v", rowTime, mustComposeEpoch, dateTime, conf.TimeFormat, tzLoc, formatAdj)
-			return nil, nil
+			return nil, nil, &ParseError{Row: i + 1, Column: "Epoch", Err: err}
 		}
 		epochCol[i] = rowTime.UTC().Unix()
 		nanosCol[i] = int32(rowTime.UTC().Nanosecond())
 	}
 
-	return epochCol, nanosCol
+	return epochCol, nanosCol, nil
 }
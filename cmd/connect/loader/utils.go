@@ -3,6 +3,7 @@ package loader
 import (
 	"encoding/csv"
 	"fmt"
+	stdio "io"
 	"os"
 	"strings"
 
@@ -18,12 +19,29 @@ type CSVConfig struct {
 	TimeFormat             string   `yaml:"timeFormat"`
 	Timezone               string   `yaml:"timeZone"`
 	ColumnNameMap          []string `yaml:"columnNameMap"`
+	// ColumnRenames maps the column names in the first row to the DB column names
+	ColumnRenames map[string]string `yaml:"columnRenames"`
 }
 
 type CSVMetadata struct {
 	Config      *CSVConfig     // Configuration of the CSV file, including the names of the columns
 	DSV         []io.DataShape // Datashapes inside this CSV file
 	ColumnIndex []int          // Maps the index of the columns in the CSV file to each time bucket in the DB
+	Rows        int            // Number of the data rows read so far
+}
+
+// ParseError is an error in reading a data row of the CSV file.
+type ParseError struct {
+	Row    int    // Data row number, starting from 1 after the column names
+	Column string // Empty if the whole row is invalid
+	Err    error
+}
+
+func (e *ParseError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+	}
+	return fmt.Sprintf("row %d, column %s: %v", e.Row, e.Column, e.Err)
 }
 
 func CSVtoNumpyMulti(csvReader *csv.Reader, tbk io.TimeBucketKey, cvm *CSVMetadata, chunkSize int,
@@ -31,38 +49,56 @@ func CSVtoNumpyMulti(csvReader *csv.Reader, tbk io.TimeBucketKey, cvm *CSVMetada
 
 	fmt.Println("Beginning parse...")
 
+	csm, endReached, err := CSVtoCSM(csvReader, tbk, cvm, chunkSize, isVariable)
+	if err != nil || csm == nil {
+		return nil, endReached, err
+	}
+	fmt.Printf("Read next %d lines from CSV file...", csm[tbk].Len())
+
+	np, err := io.NewNumpyDataset(csm[tbk])
+	if err != nil {
+		return nil, false, err
+	}
+	npm, err = io.NewNumpyMultiDataset(np, tbk)
+
+	return npm, endReached, nil
+}
+
+// CSVtoCSM reads up to chunkSize rows from the CSV file, and returns them
+// as a ColumnSeriesMap of tbk, or nil at the end of the file.
+// The errors in the rows are returned as *ParseError.
+func CSVtoCSM(csvReader *csv.Reader, tbk io.TimeBucketKey, cvm *CSVMetadata, chunkSize int,
+	isVariable bool) (csm io.ColumnSeriesMap, endReached bool, err error) {
+
 	csvChunk := make([][]string, 0)
-	var linesRead int
 	for i := 0; i < chunkSize; i++ {
 		row, err := csvReader.Read()
-		if err != nil {
+		if err == stdio.EOF {
 			endReached = true
 			break
 		}
+		if err != nil {
+			return nil, false, &ParseError{Row: cvm.Rows + len(csvChunk) + 1, Err: err}
+		}
 		csvChunk = append(csvChunk, row)
-		linesRead++
 	}
 	if len(csvChunk) == 0 {
 		return nil, true, nil
 	}
-	fmt.Printf("Read next %d lines from CSV file...", linesRead)
 
-	csm, err := convertCSVtoCSM(tbk, cvm, csvChunk)
+	csm, err = convertCSVtoCSM(tbk, cvm, csvChunk)
 	if err != nil {
+		if pe, ok := err.(*ParseError); ok {
+			pe.Row += cvm.Rows
+		}
 		return nil, false, err
 	}
+	cvm.Rows += len(csvChunk)
 
 	if !isVariable {
 		csm[tbk].Remove("Nanoseconds")
 	}
-
-	np, err := io.NewNumpyDataset(csm[tbk])
-	if err != nil {
-		return nil, false, err
-	}
-	npm, err = io.NewNumpyMultiDataset(np, tbk)
-
-	return npm, endReached, nil
+	return csm, endReached, nil
 }
 
 // ReadMetadata returns formatting info about the csv file containing
@@ -70,38 +106,45 @@ func CSVtoNumpyMulti(csvReader *csv.Reader, tbk io.TimeBucketKey, cvm *CSVMetada
 func ReadMetadata(dataFD, controlFD *os.File, dbDataShapes []io.DataShape) (csvReader *csv.Reader, cvm *CSVMetadata, err error) {
 	fmt.Println("DB Data Shapes: ", dbDataShapes)
 
-	cvm = &CSVMetadata{}
-
-	/*
-		We add a couple of fake data items to the beginning - these are optionally looked for as named columns in the CSV
-		The fake columns are cut off after the mapping process, leaving only the single EPOCH column
-	*/
-	cvm.DSV = make([]io.DataShape, 0)
-	cvm.DSV = append(cvm.DSV, io.DataShape{Name: "Epoch-date", Type: io.INT64})
-	cvm.DSV = append(cvm.DSV, io.DataShape{Name: "Epoch-time", Type: io.INT64})
-	for _, shape := range dbDataShapes {
-		cvm.DSV = append(cvm.DSV, shape)
-	}
-
-	var inputColNames []string
 	if dataFD == nil {
 		fmt.Println("Failed to open data file for loading")
 		return nil, nil, err
 	}
 
+	var conf *CSVConfig
 	if controlFD != nil {
 		// We have a loader control file, read the contents
-		cvm.Config, err = readControlFile(controlFD)
+		conf, err = readControlFile(controlFD)
 		if err != nil {
 			return nil, nil, err
 		}
 	} else {
 		// Defaults.
-		cvm.Config = &CSVConfig{
+		conf = &CSVConfig{
 			TimeFormat: "1/2/2006 3:04:05 PM",
 			Timezone:   "UTC",
 		}
 	}
+	return ReadMetadataWithConfig(dataFD, conf, dbDataShapes)
+}
+
+// ReadMetadataWithConfig is ReadMetadata with the formatting given by conf
+// instead of a control file.
+func ReadMetadataWithConfig(dataFD stdio.Reader, conf *CSVConfig, dbDataShapes []io.DataShape) (csvReader *csv.Reader, cvm *CSVMetadata, err error) {
+	cvm = &CSVMetadata{Config: conf}
+
+	/*
+		We add a couple of fake data items to the beginning - these are optionally looked for as named columns in the CSV
+		The fake columns are cut off after the mapping process, leaving only the single EPOCH column
+	*/
+	cvm.DSV = make([]io.DataShape, 0)
+	cvm.DSV = append(cvm.DSV, io.DataShape{Name: "Epoch-date", Type: io.INT64})
+	cvm.DSV = append(cvm.DSV, io.DataShape{Name: "Epoch-time", Type: io.INT64})
+	for _, shape := range dbDataShapes {
+		cvm.DSV = append(cvm.DSV, shape)
+	}
+
+	var inputColNames []string
 
 	/*
 		Valid row name cases:
@@ -170,6 +213,14 @@ func ReadMetadata(dataFD, controlFD *os.File, dbDataShapes []io.DataShape) (csvR
 		}
 	}
 
+	for from, to := range cvm.Config.ColumnRenames {
+		for i, name := range inputColNames {
+			if strings.EqualFold(name, from) {
+				inputColNames[i] = to
+			}
+		}
+	}
+
 	/*
 		Look for the columns needed in the input file by name (case independent)
 	*/
@@ -198,8 +249,11 @@ func ReadMetadata(dataFD, controlFD *os.File, dbDataShapes []io.DataShape) (csvR
 	var fail bool
 	for i := 2; i < len(cvm.ColumnIndex); i++ {
 		if cvm.ColumnIndex[i] == -1 {
+			if cvm.DSV[i-2].Name == "Epoch" && cvm.ColumnIndex[0] != -1 && cvm.ColumnIndex[1] != -1 {
+				continue // composed from the date and time
+			}
 			fail = true
-			fmt.Printf("Unable to find a matching csv column for \"%s\"\n", cvm.DSV[i].Name)
+			fmt.Printf("Unable to find a matching csv column for \"%s\"\n", cvm.DSV[i-2].Name)
 		}
 	}
 	if fail {
@@ -210,18 +264,20 @@ func ReadMetadata(dataFD, controlFD *os.File, dbDataShapes []io.DataShape) (csvR
 }
 
 func convertCSVtoCSM(tbk io.TimeBucketKey, cvm *CSVMetadata, csvDataChunk [][]string) (csm io.ColumnSeriesMap, err error) {
-	epochCol, nanosCol := readTimeColumns(csvDataChunk, cvm.ColumnIndex, cvm.Config)
-	if epochCol == nil {
-		fmt.Println("Error building time columns from csv data")
-		return
+	epochCol, nanosCol, err := readTimeColumns(csvDataChunk, cvm.ColumnIndex, cvm.Config)
+	if err != nil {
+		return nil, err
 	}
 
 	csmInit := io.NewColumnSeriesMap()
 	csmInit.AddColumn(tbk, "Epoch", epochCol)
-	csm = columnSeriesMapFromCSVData(csmInit, tbk, csvDataChunk, cvm.ColumnIndex[2:], cvm.DSV)
+	csm, err = columnSeriesMapFromCSVData(csmInit, tbk, csvDataChunk, cvm.ColumnIndex[2:], cvm.DSV)
+	if err != nil {
+		return nil, err
+	}
 	csm.AddColumn(tbk, "Nanoseconds", nanosCol)
 
-	return csm, err
+	return csm, nil
 }
 
 func readControlFile(controlFD *os.File) (cf *CSVConfig, err error) {
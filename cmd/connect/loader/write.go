@@ -8,7 +8,7 @@ import (
 )
 
 func columnSeriesMapFromCSVData(csmInit io.ColumnSeriesMap, key io.TimeBucketKey, csvRows [][]string, columnIndex []int,
-	dataShapes []io.DataShape) (csm io.ColumnSeriesMap) {
+	dataShapes []io.DataShape) (csm io.ColumnSeriesMap, err error) {
 
 	if csmInit == nil {
 		csm = io.NewColumnSeriesMap()
@@ -17,81 +17,81 @@ func columnSeriesMapFromCSVData(csmInit io.ColumnSeriesMap, key io.TimeBucketKey
 	}
 	for i, shape := range dataShapes {
 		index := columnIndex[i]
-		if index != 0 {
+		if shape.Name != "Epoch" {
 			/*
-				We skip the first column, as it's the Epoch and we parse that independently
+				We skip the Epoch, as we parse that independently
 			*/
 			switch shape.Type {
 			case io.STRING:
 				col, err := getStringColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 			case io.FLOAT32:
 				col, err := getFloat32ColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 			case io.FLOAT64:
 				col, err := getFloat64ColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 			case io.BYTE:
 				col, err := getInt8ColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 			case io.INT16:
 				col, err := getInt16ColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 			case io.INT32:
 				col, err := getInt32ColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 			case io.INT64:
 				col, err := getInt64ColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 			case io.UINT8:
 				col, err := getUInt8ColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 			case io.UINT16:
 				col, err := getUInt16ColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 			case io.UINT32:
 				col, err := getUInt32ColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 			case io.UINT64:
 				col, err := getUInt64ColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 			case io.BOOL:
 				col, err := getBoolColumnFromCSVRows(csvRows, index)
-				if columnError(err, shape.Name) {
-					return nil
+				if err != nil {
+					return nil, columnError(err, shape.Name)
 				}
 				csm.AddColumn(key, shape.Name, col)
 
@@ -99,15 +99,15 @@ func columnSeriesMapFromCSVData(csmInit io.ColumnSeriesMap, key io.TimeBucketKey
 
 		}
 	}
-	return csm
+	return csm, nil
 }
 
-func columnError(err error, name string) bool {
-	if err != nil {
-		fmt.Printf("Error obtaining column \"%s\" from csv data\n", name)
-		return true
+func columnError(err error, name string) error {
+	if pe, ok := err.(*ParseError); ok {
+		pe.Column = name
+		return pe
 	}
-	return false
+	return fmt.Errorf("Error obtaining column \"%s\" from csv data: %v", name, err)
 }
 
 func getBoolColumnFromCSVRows(csvRows [][]string, index int) (col []bool, err error) {
@@ -115,7 +115,7 @@ func getBoolColumnFromCSVRows(csvRows [][]string, index int) (col []bool, err er
 	for i, row := range csvRows {
 		val, err := strconv.ParseBool(row[index])
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Row: i + 1, Err: err}
 		}
 		col[i] = bool(val)
 	}
@@ -135,7 +135,7 @@ func getFloat32ColumnFromCSVRows(csvRows [][]string, index int) (col []float32,
 	for i, row := range csvRows {
 		val, err := strconv.ParseFloat(row[index], 32)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Row: i + 1, Err: err}
 		}
 		col[i] = float32(val)
 	}
@@ -147,7 +147,7 @@ func getFloat64ColumnFromCSVRows(csvRows [][]string, index int) (col []float64,
 	for i, row := range csvRows {
 		col[i], err = strconv.ParseFloat(row[index], 64)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Row: i + 1, Err: err}
 		}
 	}
 	return col, nil
@@ -158,7 +158,7 @@ func getInt8ColumnFromCSVRows(csvRows [][]string, index int) (col []int8, err er
 	for i, row := range csvRows {
 		val, err := strconv.ParseInt(row[index], 10, 8)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Row: i + 1, Err: err}
 		}
 		col[i] = int8(val)
 	}
@@ -170,7 +170,7 @@ func getInt16ColumnFromCSVRows(csvRows [][]string, index int) (col []int16, err
 	for i, row := range csvRows {
 		val, err := strconv.ParseInt(row[index], 10, 16)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Row: i + 1, Err: err}
 		}
 		col[i] = int16(val)
 	}
@@ -182,7 +182,7 @@ func getInt32ColumnFromCSVRows(csvRows [][]string, index int) (col []int32, err
 	for i, row := range csvRows {
 		val, err := strconv.ParseInt(row[index], 10, 32)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Row: i + 1, Err: err}
 		}
 		col[i] = int32(val)
 	}
@@ -194,7 +194,7 @@ func getInt64ColumnFromCSVRows(csvRows [][]string, index int) (col []int64, err
 	for i, row := range csvRows {
 		col[i], err = strconv.ParseInt(row[index], 10, 64)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Row: i + 1, Err: err}
 		}
 	}
 	return col, nil
@@ -205,7 +205,7 @@ func getUInt8ColumnFromCSVRows(csvRows [][]string, index int) (col []uint8, err
 	for i, row := range csvRows {
 		val, err := strconv.ParseUint(row[index], 10, 8)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Row: i + 1, Err: err}
 		}
 		col[i] = uint8(val)
 	}
@@ -217,7 +217,7 @@ func getUInt16ColumnFromCSVRows(csvRows [][]string, index int) (col []uint16, er
 	for i, row := range csvRows {
 		val, err := strconv.ParseUint(row[index], 10, 16)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Row: i + 1, Err: err}
 		}
 		col[i] = uint16(val)
 	}
@@ -229,7 +229,7 @@ func getUInt32ColumnFromCSVRows(csvRows [][]string, index int) (col []uint32, er
 	for i, row := range csvRows {
 		val, err := strconv.ParseUint(row[index], 10, 32)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Row: i + 1, Err: err}
 		}
 		col[i] = uint32(val)
 	}
@@ -241,7 +241,7 @@ func getUInt64ColumnFromCSVRows(csvRows [][]string, index int) (col []uint64, er
 	for i, row := range csvRows {
 		col[i], err = strconv.ParseUint(row[index], 10, 64)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Row: i + 1, Err: err}
 		}
 	}
 	return col, nil
@@ -69,6 +69,7 @@ func (c *Client) functionHelp(line string) {
 			columnNameMap: [Epoch, Open, High, Low, Close, Volume]
 			timeZone: if specified, this will override the timezone of the epoch found in the input file
 			columnNameMap: optional mapping of column position to name
+			columnRenames: optional renames of the column names in the first row, e.g. {Date: Epoch, Vol: Volume}
 
 		Note: "Epoch" is a special name, as is "Epoch-date" and "Epoch-time"
 		If the input file has the time index epoch in separate date and time columns, you will
@@ -101,6 +102,9 @@ func (c *Client) functionHelp(line string) {
 			in a row: name1,name2,name3/type:name4,name5/type:name6/type
 		- Example: We have OHLCV data where prices are 32-bit floats and volume is 32-bit int:
 			<row data shape schema> = Open,High,Low,Close/float32:Volume/int32
+		- The type decimal(<scale>) stores exact prices as int64 values of price * 10^scale,
+		where scale is 1 to 18, converting the float values written:
+			<row data shape schema> = Bid,Ask/decimal(8):Size/float32
 
 		<row-type>: The type of rows to be stored, one of "fixed" or "variable":
 		- Example: We are storing tick data, where each time interval can contain a variable
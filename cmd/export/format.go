@@ -0,0 +1,165 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	stdio "io"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// recordWriter writes the records of a bucket in a file format.
+type recordWriter interface {
+	// Write writes the records in the column series.
+	Write(cs *io.ColumnSeries) error
+	// Close flushes the records written so far.
+	Close() error
+}
+
+// timeFormatter formats the Epoch of the records.
+type timeFormatter struct {
+	layout   string // "timestamp" for the seconds since the Unix epoch
+	location *time.Location
+}
+
+func (f *timeFormatter) format(epoch int64, nanos int32) string {
+	if f.layout == "timestamp" {
+		if nanos == 0 {
+			return strconv.FormatInt(epoch, 10)
+		}
+		return fmt.Sprintf("%d.%09d", epoch, nanos)
+	}
+	return time.Unix(epoch, int64(nanos)).In(f.location).Format(f.layout)
+}
+
+// epochs returns the Epoch and Nanoseconds columns of cs,
+// with nil Nanoseconds for the fixed length records.
+func epochs(cs *io.ColumnSeries) ([]int64, []int32) {
+	nanos, _ := cs.GetColumn("Nanoseconds").([]int32)
+	return cs.GetEpoch(), nanos
+}
+
+// csvWriter writes a header row of the column names followed by a row
+// for each record.
+type csvWriter struct {
+	w      *csv.Writer
+	shapes []io.DataShape
+	tf     *timeFormatter
+	row    []string
+}
+
+func newCSVWriter(w stdio.Writer, shapes []io.DataShape, tf *timeFormatter) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), shapes: shapes, tf: tf, row: make([]string, len(shapes))}
+	for i, shape := range shapes {
+		cw.row[i] = shape.Name
+	}
+	return cw, cw.w.Write(cw.row)
+}
+
+func (cw *csvWriter) Write(cs *io.ColumnSeries) error {
+	epoch, nanos := epochs(cs)
+	columns := make([]reflect.Value, len(cw.shapes))
+	for i, shape := range cw.shapes {
+		columns[i] = reflect.ValueOf(cs.GetColumn(shape.Name))
+	}
+	for j := range epoch {
+		for i, shape := range cw.shapes {
+			if shape.Name == "Epoch" {
+				var nano int32
+				if nanos != nil {
+					nano = nanos[j]
+				}
+				cw.row[i] = cw.tf.format(epoch[j], nano)
+				continue
+			}
+			cw.row[i] = fmt.Sprint(columns[i].Index(j).Interface())
+		}
+		if err := cw.w.Write(cw.row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// jsonWriter writes a JSON object per line for each record, with the keys
+// in the order of the columns.  NaN and infinite values are written as null.
+type jsonWriter struct {
+	w      stdio.Writer
+	shapes []io.DataShape
+	tf     *timeFormatter
+	buf    []byte
+}
+
+func newJSONWriter(w stdio.Writer, shapes []io.DataShape, tf *timeFormatter) (*jsonWriter, error) {
+	return &jsonWriter{w: w, shapes: shapes, tf: tf}, nil
+}
+
+func (jw *jsonWriter) Write(cs *io.ColumnSeries) error {
+	epoch, nanos := epochs(cs)
+	columns := make([]reflect.Value, len(jw.shapes))
+	for i, shape := range jw.shapes {
+		columns[i] = reflect.ValueOf(cs.GetColumn(shape.Name))
+	}
+	for j := range epoch {
+		jw.buf = append(jw.buf[:0], '{')
+		for i, shape := range jw.shapes {
+			if i > 0 {
+				jw.buf = append(jw.buf, ',')
+			}
+			jw.buf = strconv.AppendQuote(jw.buf, shape.Name)
+			jw.buf = append(jw.buf, ':')
+
+			var value interface{}
+			if shape.Name == "Epoch" {
+				var nano int32
+				if nanos != nil {
+					nano = nanos[j]
+				}
+				if jw.tf.layout == "timestamp" {
+					value = json.Number(jw.tf.format(epoch[j], nano))
+				} else {
+					value = jw.tf.format(epoch[j], nano)
+				}
+			} else {
+				value = columns[i].Index(j).Interface()
+			}
+			if isNaNOrInf(value) {
+				value = nil
+			}
+			b, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			jw.buf = append(jw.buf, b...)
+		}
+		jw.buf = append(jw.buf, '}', '\n')
+		if _, err := jw.w.Write(jw.buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (jw *jsonWriter) Close() error {
+	return nil
+}
+
+func isNaNOrInf(value interface{}) bool {
+	switch v := value.(type) {
+	case float32:
+		return math.IsNaN(float64(v)) || math.IsInf(float64(v), 0)
+	case float64:
+		return math.IsNaN(v) || math.IsInf(v, 0)
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	stdio "io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/planner"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+const (
+	usage = "export"
+	short = "Export the records of a bucket to a file"
+	long  = `This command writes the records of a bucket in a time range to a file
+or the standard output, as CSV with a header row of the column names, as
+a JSON object per line, or as Parquet.  The columns are in the order of the
+bucket's schema.  In Parquet, the Epoch is the int64 seconds since the Unix
+epoch, with the Nanoseconds column of the variable length records, and the
+bucket is stored in the key-value metadata.
+
+The records are read a page at a time, so that a long range is not read into
+memory at once.  The data directory is only read, so the server may be running.`
+	example = "marketstore export --dir data --symbol AAPL --timeframe 1Min --start 2020-01-01 --format json --out aapl.json"
+
+	defaultTimeFormat = "2006-01-02T15:04:05.999999999Z07:00"
+)
+
+var (
+	// Cmd is the export command.
+	Cmd = &cobra.Command{
+		Use:     usage,
+		Short:   short,
+		Long:    long,
+		Example: example,
+		RunE:    executeExport,
+	}
+
+	rootDir        string
+	symbol         string
+	timeframe      string
+	attributeGroup string
+	startFlag      string
+	endFlag        string
+	format         string
+	outFile        string
+	timezone       string
+	timeFormat     string
+	pageSize       int
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&rootDir, "dir", "d", "", "data directory (root_directory) to export from")
+	Cmd.Flags().StringVarP(&symbol, "symbol", "s", "", "symbol of the bucket")
+	Cmd.Flags().StringVarP(&timeframe, "timeframe", "t", "1Min", "timeframe of the bucket")
+	Cmd.Flags().StringVar(&attributeGroup, "attribute-group", "OHLCV", "attribute group of the bucket")
+	Cmd.Flags().StringVar(&startFlag, "start", "", "start of the range (inclusive), e.g. 2020-01-01 or 2020-01-01T09:30:00")
+	Cmd.Flags().StringVar(&endFlag, "end", "", "end of the range (inclusive)")
+	Cmd.Flags().StringVarP(&format, "format", "F", "csv", "output format, csv, json or parquet")
+	Cmd.Flags().StringVarP(&outFile, "out", "o", "-", "output file, - for the standard output")
+	Cmd.Flags().StringVar(&timezone, "timezone", "UTC", "timezone of --start, --end and the Epoch in the output")
+	Cmd.Flags().StringVar(&timeFormat, "time-format", defaultTimeFormat,
+		"Go time layout of the Epoch in csv and json, or \"timestamp\" for the seconds since the Unix epoch")
+	Cmd.Flags().IntVar(&pageSize, "page-size", 100000, "number of intervals of the timeframe read at once")
+	Cmd.MarkFlagRequired("dir")
+	Cmd.MarkFlagRequired("symbol")
+}
+
+// executeExport implements the export command.
+func executeExport(cmd *cobra.Command, args []string) error {
+	tbk := io.NewTimeBucketKey(strings.Join([]string{symbol, timeframe, attributeGroup}, "/"))
+	if tbk == nil || utils.TimeframeFromString(timeframe) == nil {
+		return fmt.Errorf("invalid bucket %s/%s/%s", symbol, timeframe, attributeGroup)
+	}
+	if pageSize <= 0 {
+		return fmt.Errorf("page size must be positive")
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return err
+	}
+	start, end := planner.MinTime, planner.MaxTime
+	if startFlag != "" {
+		if start, err = parseTime(startFlag, loc); err != nil {
+			return err
+		}
+	}
+	if endFlag != "" {
+		if end, err = parseTime(endFlag, loc); err != nil {
+			return err
+		}
+	}
+
+	var out stdio.Writer = os.Stdout
+	if outFile != "-" {
+		f, err := os.Create(outFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	} else {
+		// the logs are written to the standard output as well
+		log.SetLevel(log.ERROR)
+	}
+	bw := bufio.NewWriter(out)
+
+	// read only, without taking over the WAL
+	initCatalog, initWALCache, backgroundSync, WALBypass := true, false, false, false
+	executor.NewInstanceSetup(rootDir, initCatalog, initWALCache, backgroundSync, WALBypass)
+
+	tbi, err := executor.ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(tbk)
+	if err != nil {
+		return fmt.Errorf("%s does not exist", tbk.GetItemKey())
+	}
+	tf := &timeFormatter{layout: timeFormat, location: loc}
+	w, err := newRecordWriter(bw, tbk, tbi, tf)
+	if err != nil {
+		return err
+	}
+
+	var rows int
+	err = executor.ReadPages(tbk, start, end, pageSize, func(cs *io.ColumnSeries) error {
+		rows += cs.Len()
+		return w.Write(cs)
+	})
+	if err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	if outFile != "-" {
+		fmt.Printf("exported %d rows of %s to %s\n", rows, tbk.GetItemKey(), outFile)
+	}
+	return nil
+}
+
+func newRecordWriter(w stdio.Writer, tbk *io.TimeBucketKey, tbi *io.TimeBucketInfo,
+	tf *timeFormatter) (recordWriter, error) {
+	shapes := tbi.GetDataShapesWithEpoch()
+	switch format {
+	case "csv":
+		return newCSVWriter(w, shapes, tf)
+	case "json":
+		return newJSONWriter(w, shapes, tf)
+	case "parquet":
+		return newParquetWriter(w, tbk, tbi)
+	default:
+		return nil, fmt.Errorf("unknown format %s, not csv, json or parquet", format)
+	}
+}
+
+func parseTime(value string, loc *time.Location) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02T15:04:05", time.RFC3339Nano} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %s, not 2006-01-02[T15:04[:05]] or RFC3339", value)
+}
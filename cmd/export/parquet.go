@@ -0,0 +1,114 @@
+package export
+
+import (
+	"fmt"
+	stdio "io"
+	"reflect"
+
+	parquetsource "github.com/xitongsys/parquet-go-source/writer"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// parquetTypes maps the column types to the Parquet schema of the columns,
+// the narrower integers as the converted types of INT32 and INT64.
+var parquetTypes = map[io.EnumElementType]string{
+	io.FLOAT32: "type=FLOAT",
+	io.FLOAT64: "type=DOUBLE",
+	io.INT64:   "type=INT64",
+	io.EPOCH:   "type=INT64",
+	io.INT32:   "type=INT32",
+	io.INT16:   "type=INT_16",
+	io.BYTE:    "type=INT_8",
+	io.UINT8:   "type=UINT_8",
+	io.UINT16:  "type=UINT_16",
+	io.UINT32:  "type=UINT_32",
+	io.UINT64:  "type=UINT_64",
+	io.BOOL:    "type=BOOLEAN",
+}
+
+// parquetWriter writes a row group for each page of the records, with the
+// Epoch as the seconds since the Unix epoch and, for the variable length
+// records, the Nanoseconds in a column of their own.  The bucket is stored
+// in the key-value metadata of the file.
+type parquetWriter struct {
+	pw      *writer.CSVWriter
+	columns []string
+}
+
+func newParquetWriter(w stdio.Writer, tbk *io.TimeBucketKey, tbi *io.TimeBucketInfo) (*parquetWriter, error) {
+	shapes := tbi.GetDataShapesWithEpoch()
+	if tbi.GetRecordType() == io.VARIABLE {
+		shapes = append(shapes, io.DataShape{Name: "Nanoseconds", Type: io.INT32})
+	}
+	md := make([]string, len(shapes))
+	columns := make([]string, len(shapes))
+	for i, shape := range shapes {
+		typ, ok := parquetTypes[shape.Type]
+		if !ok {
+			return nil, fmt.Errorf("column %s of type %s is not supported in parquet", shape.Name, shape.Type)
+		}
+		md[i] = fmt.Sprintf("name=%s, %s", shape.Name, typ)
+		columns[i] = shape.Name
+	}
+
+	// one goroutine, as the pages are written in order
+	pw, err := writer.NewCSVWriter(md, parquetsource.NewWriterFile(w), 1)
+	if err != nil {
+		return nil, err
+	}
+	for _, kv := range [][2]string{
+		{"marketstore.key", tbk.GetItemKey()},
+		{"marketstore.symbol", tbk.GetItemInCategory("Symbol")},
+		{"marketstore.timeframe", tbk.GetItemInCategory("Timeframe")},
+		{"marketstore.attribute_group", tbk.GetItemInCategory("AttributeGroup")},
+	} {
+		key, value := kv[0], kv[1]
+		pw.Footer.KeyValueMetadata = append(pw.Footer.KeyValueMetadata, &parquet.KeyValue{Key: key, Value: &value})
+	}
+	return &parquetWriter{pw: pw, columns: columns}, nil
+}
+
+func (pq *parquetWriter) Write(cs *io.ColumnSeries) error {
+	values := make([]reflect.Value, len(pq.columns))
+	for i, name := range pq.columns {
+		values[i] = reflect.ValueOf(cs.GetColumn(name))
+	}
+	for j := 0; j < cs.Len(); j++ {
+		// the rows are kept until the flush, so each needs its own slice
+		row := make([]interface{}, len(pq.columns))
+		for i := range pq.columns {
+			row[i] = parquetValue(values[i].Index(j).Interface())
+		}
+		if err := pq.pw.Write(row); err != nil {
+			return err
+		}
+	}
+	// a row group for each page, rather than buffering the whole file
+	return pq.pw.Flush(true)
+}
+
+func (pq *parquetWriter) Close() error {
+	return pq.pw.WriteStop()
+}
+
+// parquetValue converts the value to the Go type of its Parquet physical type.
+func parquetValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int16:
+		return int32(v)
+	case int8:
+		return int32(v)
+	case uint8:
+		return int32(v)
+	case uint16:
+		return int32(v)
+	case uint32:
+		return int32(v)
+	case uint64:
+		return int64(v)
+	}
+	return value
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type ExportTestSuite struct{}
+
+var _ = Suite(&ExportTestSuite{})
+
+func (s *ExportTestSuite) TestExportParquet(c *C) {
+	rootDir = c.MkDir()
+	executor.NewInstanceSetup(rootDir, true, true, false, true)
+
+	t0 := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{t0.Unix(), t0.Add(time.Minute).Unix(), t0.Add(2 * time.Minute).Unix()})
+	cs.AddColumn("Close", []float32{1.5, 2.5, 3.5})
+	cs.AddColumn("Volume", []int64{100, 200, 300})
+	cs.AddColumn("Trades", []int16{1, 2, 3})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*io.NewTimeBucketKey("AAPL/1Min/OHLCV"), cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+
+	symbol, timeframe, attributeGroup = "AAPL", "1Min", "OHLCV"
+	startFlag, endFlag, timezone, timeFormat = "2020-01-02T03:04", "2020-01-02T03:06", "UTC", defaultTimeFormat
+	format, outFile, pageSize = "parquet", filepath.Join(c.MkDir(), "aapl.parquet"), 2
+	c.Assert(executeExport(nil, nil), IsNil)
+
+	fr, err := local.NewLocalFileReader(outFile)
+	c.Assert(err, IsNil)
+	defer fr.Close()
+	pr, err := reader.NewParquetColumnReader(fr, 1)
+	c.Assert(err, IsNil)
+	defer pr.ReadStop()
+	c.Assert(pr.GetNumRows(), Equals, int64(3))
+	// a row group by page
+	c.Assert(pr.Footer.RowGroups, HasLen, 2)
+
+	types := map[string]parquet.Type{}
+	for _, elem := range pr.Footer.Schema[1:] {
+		types[elem.Name] = elem.GetType()
+	}
+	c.Assert(types, DeepEquals, map[string]parquet.Type{
+		"Epoch":  parquet.Type_INT64,
+		"Close":  parquet.Type_FLOAT,
+		"Volume": parquet.Type_INT64,
+		"Trades": parquet.Type_INT32,
+	})
+	c.Assert(pr.Footer.Schema[4].GetConvertedType(), Equals, parquet.ConvertedType_INT_16)
+
+	metadata := map[string]string{}
+	for _, kv := range pr.Footer.KeyValueMetadata {
+		metadata[kv.Key] = kv.GetValue()
+	}
+	c.Assert(metadata, DeepEquals, map[string]string{
+		"marketstore.key":             "AAPL/1Min/OHLCV",
+		"marketstore.symbol":          "AAPL",
+		"marketstore.timeframe":       "1Min",
+		"marketstore.attribute_group": "OHLCV",
+	})
+
+	epoch, _, _, err := pr.ReadColumnByPath("parquet_go_root.Epoch", 3)
+	c.Assert(err, IsNil)
+	c.Assert(epoch, DeepEquals, []interface{}{t0.Unix(), t0.Add(time.Minute).Unix(), t0.Add(2 * time.Minute).Unix()})
+	closes, _, _, err := pr.ReadColumnByPath("parquet_go_root.Close", 3)
+	c.Assert(err, IsNil)
+	c.Assert(closes, DeepEquals, []interface{}{float32(1.5), float32(2.5), float32(3.5)})
+	trades, _, _, err := pr.ReadColumnByPath("parquet_go_root.Trades", 3)
+	c.Assert(err, IsNil)
+	c.Assert(trades, DeepEquals, []interface{}{int32(1), int32(2), int32(3)})
+}
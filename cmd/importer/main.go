@@ -0,0 +1,212 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alpacahq/marketstore/v4/cmd/connect/loader"
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+const (
+	usage = "import"
+	short = "Import a CSV file into the database"
+	long  = `This command writes the rows of a CSV file to a bucket in the data directory.
+
+The CSV columns are matched to the DB columns by name (case insensitive), taken
+from the first row or from --columns, and optionally renamed with --rename.
+The Epoch is parsed from the "Epoch" column, or from the "Epoch-date" and
+"Epoch-time" columns, with --time-format, which is a Go time layout or
+"timestamp" for the seconds since the Unix epoch.
+
+The bucket is created with --schema if it does not exist.  The timestamps must
+be increasing, and the rows are written in batches of --batch-size, each in a
+WAL transaction.  The server must not be running on the data directory.`
+	example = `marketstore import --dir data --symbol AAPL --timeframe 1Min --file aapl.csv \
+    --rename Date=Epoch,Vol=Volume --time-format "2006-01-02 15:04" --timezone America/New_York`
+)
+
+var (
+	// Cmd is the import command.
+	Cmd = &cobra.Command{
+		Use:     usage,
+		Short:   short,
+		Long:    long,
+		Example: example,
+		RunE:    executeImport,
+	}
+
+	rootDir        string
+	symbol         string
+	timeframe      string
+	attributeGroup string
+	dataFile       string
+	noHeader       bool
+	columns        []string
+	renames        map[string]string
+	timeFormat     string
+	timezone       string
+	schema         []string
+	isVariable     bool
+	batchSize      int
+	dryRun         bool
+)
+
+func init() {
+	Cmd.Flags().StringVarP(&rootDir, "dir", "d", "", "data directory (root_directory) to import into")
+	Cmd.Flags().StringVarP(&symbol, "symbol", "s", "", "symbol of the bucket")
+	Cmd.Flags().StringVarP(&timeframe, "timeframe", "t", "1Min", "timeframe of the bucket")
+	Cmd.Flags().StringVar(&attributeGroup, "attribute-group", "OHLCV", "attribute group of the bucket")
+	Cmd.Flags().StringVarP(&dataFile, "file", "f", "", "CSV file to import")
+	Cmd.Flags().BoolVar(&noHeader, "no-header", false, "the first row is data rather than the column names")
+	Cmd.Flags().StringSliceVar(&columns, "columns", nil,
+		"column names in order, replacing those in the first row (empty to keep one)")
+	Cmd.Flags().StringToStringVar(&renames, "rename", nil, "renames of the CSV columns to the DB columns, e.g. Date=Epoch")
+	Cmd.Flags().StringVar(&timeFormat, "time-format", "2006-01-02 15:04:05", "Go time layout of the Epoch, or \"timestamp\"")
+	Cmd.Flags().StringVar(&timezone, "timezone", "UTC", "timezone of the Epoch")
+	Cmd.Flags().StringSliceVar(&schema, "schema", nil,
+		"DB columns of a new bucket, e.g. Open:float32,High:float32,Low:float32,Close:float32,Volume:int64")
+	Cmd.Flags().BoolVar(&isVariable, "variable", false, "create a new bucket with variable length records")
+	Cmd.Flags().IntVar(&batchSize, "batch-size", 100000, "number of rows written in a transaction")
+	Cmd.Flags().BoolVar(&dryRun, "dry-run", false, "validate the CSV file without writing")
+	Cmd.MarkFlagRequired("dir")
+	Cmd.MarkFlagRequired("symbol")
+	Cmd.MarkFlagRequired("file")
+}
+
+// executeImport implements the import command.
+func executeImport(cmd *cobra.Command, args []string) error {
+	tbk := io.NewTimeBucketKey(strings.Join([]string{symbol, timeframe, attributeGroup}, "/"))
+	if tbk == nil {
+		return fmt.Errorf("invalid bucket %s/%s/%s", symbol, timeframe, attributeGroup)
+	}
+	if utils.TimeframeFromString(timeframe) == nil {
+		return fmt.Errorf("invalid timeframe %s", timeframe)
+	}
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive")
+	}
+	dataFD, err := os.Open(dataFile)
+	if err != nil {
+		return err
+	}
+	defer dataFD.Close()
+
+	if _, err = os.Stat(rootDir); os.IsNotExist(err) && !dryRun {
+		if err = os.MkdirAll(rootDir, 0700); err != nil {
+			return err
+		}
+	}
+	if _, err = os.Stat(rootDir); err == nil {
+		// no WAL for the dry run, so that nothing is written
+		initCatalog, initWALCache, backgroundSync, WALBypass := true, !dryRun, false, false
+		executor.NewInstanceSetup(rootDir, initCatalog, initWALCache, backgroundSync, WALBypass)
+	}
+
+	dsv, err := dataShapes(tbk)
+	if err != nil {
+		return err
+	}
+	conf := &loader.CSVConfig{
+		FirstRowHasColumnNames: !noHeader,
+		TimeFormat:             timeFormat,
+		Timezone:               timezone,
+		ColumnNameMap:          columns,
+		ColumnRenames:          renames,
+	}
+	csvReader, cvm, err := loader.ReadMetadataWithConfig(dataFD, conf, dsv)
+	if err != nil {
+		return err
+	}
+
+	var lastEpoch int64
+	var lastNanos int32
+	for {
+		rows := cvm.Rows
+		csm, endReached, err := loader.CSVtoCSM(csvReader, *tbk, cvm, batchSize, isVariable)
+		if err != nil {
+			return err
+		}
+		if csm == nil {
+			break
+		}
+
+		// validate that the timestamps are increasing, which may be
+		// equal for the variable length records
+		cs := csm[*tbk]
+		epochs := cs.GetEpoch()
+		nanos, _ := cs.GetColumn("Nanoseconds").([]int32)
+		for i, epoch := range epochs {
+			var nano int32
+			if nanos != nil {
+				nano = nanos[i]
+			}
+			if rows+i > 0 && (epoch < lastEpoch ||
+				epoch == lastEpoch && (!isVariable || nano < lastNanos)) {
+				return &loader.ParseError{Row: rows + i + 1, Column: "Epoch",
+					Err: fmt.Errorf("timestamp is not after the previous row")}
+			}
+			lastEpoch, lastNanos = epoch, nano
+		}
+
+		if !dryRun {
+			if err = executor.WriteCSM(csm, isVariable); err != nil {
+				return fmt.Errorf("failed to write the rows up to %d: %v", cvm.Rows, err)
+			}
+			executor.ThisInstance.WALFile.RequestFlush()
+		}
+		if endReached {
+			break
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("validated %d rows for %s\n", cvm.Rows, tbk.GetItemKey())
+		return nil
+	}
+	if err = executor.ThisInstance.WALFile.Sync(context.Background()); err != nil {
+		return err
+	}
+	fmt.Printf("imported %d rows into %s\n", cvm.Rows, tbk.GetItemKey())
+	return nil
+}
+
+// dataShapes returns the DB columns of the bucket if it exists,
+// or the ones given by --schema.
+func dataShapes(tbk *io.TimeBucketKey) ([]io.DataShape, error) {
+	var tbi *io.TimeBucketInfo
+	err := fmt.Errorf("no data directory")
+	if executor.ThisInstance != nil {
+		tbi, err = executor.ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(tbk)
+	}
+	if err == nil {
+		if len(schema) != 0 {
+			return nil, fmt.Errorf("%s already exists, --schema is only for a new bucket", tbk.GetItemKey())
+		}
+		isVariable = tbi.GetRecordType() == io.VARIABLE
+		return tbi.GetDataShapesWithEpoch(), nil
+	}
+	if len(schema) == 0 {
+		return nil, fmt.Errorf("%s does not exist, set --schema to create it", tbk.GetItemKey())
+	}
+
+	dsv := []io.DataShape{{Name: "Epoch", Type: io.INT64}}
+	for _, column := range schema {
+		parts := strings.Split(column, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid column %s in the schema, not {Name}:{Type}", column)
+		}
+		typ := io.EnumElementTypeFromName(parts[1])
+		if typ.Size() == 0 {
+			return nil, fmt.Errorf("invalid type %s of %s in the schema", parts[1], parts[0])
+		}
+		dsv = append(dsv, io.DataShape{Name: parts[0], Type: typ})
+	}
+	return dsv, nil
+}
@@ -1,14 +1,19 @@
 package cmd
 
 import (
-	"fmt"
+	"os"
 
+	"github.com/alpacahq/marketstore/v4/cmd/backup"
+	"github.com/alpacahq/marketstore/v4/cmd/check"
 	"github.com/alpacahq/marketstore/v4/cmd/connect"
 	"github.com/alpacahq/marketstore/v4/cmd/create"
 	"github.com/alpacahq/marketstore/v4/cmd/estimate"
+	"github.com/alpacahq/marketstore/v4/cmd/export"
+	"github.com/alpacahq/marketstore/v4/cmd/importer"
+	"github.com/alpacahq/marketstore/v4/cmd/migrate"
 	"github.com/alpacahq/marketstore/v4/cmd/start"
 	"github.com/alpacahq/marketstore/v4/cmd/tool"
-	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/cmd/version"
 	"github.com/spf13/cobra"
 )
 
@@ -24,9 +29,7 @@ func Execute() error {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// Print version if specified.
 			if flagPrintVersion {
-				fmt.Printf("version: %+v\n", utils.Tag)
-				fmt.Printf("commit hash: %+v\n", utils.GitHash)
-				fmt.Printf("utc build time: %+v\n", utils.BuildStamp)
+				version.Print(os.Stdout)
 				return nil
 			}
 			// Print information regarding usage.
@@ -35,11 +38,17 @@ func Execute() error {
 	}
 
 	// Adds subcommands and version flag.
+	c.AddCommand(backup.Cmd)
+	c.AddCommand(check.Cmd)
 	c.AddCommand(create.Cmd)
 	c.AddCommand(estimate.Cmd)
+	c.AddCommand(export.Cmd)
+	c.AddCommand(importer.Cmd)
+	c.AddCommand(migrate.Cmd)
 	c.AddCommand(start.Cmd)
 	c.AddCommand(tool.Cmd)
 	c.AddCommand(connect.Cmd)
+	c.AddCommand(version.Cmd)
 	c.Flags().BoolVarP(&flagPrintVersion, "version", "v", false, "show the version info and exit")
 
 	return c.Execute()
@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+)
+
+const (
+	usage = "migrate"
+	short = "Rewrite a data directory into another on-disk layout"
+	long  = `This command rewrites the data directory of a stopped server from one
+on-disk layout into another, in a new directory, and verifies the checksums of
+the records of each year file migrated against its source.  The source files
+are only read.  The layouts are v4, the files not compressed, as written by
+the releases before the compression, and v4-snappy and v4-zstd, the fixed
+length files compressed in blocks.
+
+The migration fails before writing anything if a year file is not in the
+layout migrated from, or if a WAL file has not been replayed.  A migration
+stopped before its end is resumed by running it again with the same
+directories, the files migrated already being verified rather than written
+again.  --dry-run lists the files that would be migrated.`
+	example = "marketstore migrate --dir <path> --out <path> --from v4 --to v4-zstd [--dry-run]"
+)
+
+var (
+	// Cmd is the migrate command.
+	Cmd = &cobra.Command{
+		Use:     usage,
+		Short:   short,
+		Long:    long,
+		Example: example,
+		RunE:    executeMigrate,
+	}
+	// RootDir is the data directory to migrate.
+	RootDir string
+	// OutDir is the directory the migrated data directory is written to.
+	OutDir string
+	// From is the layout of RootDir.
+	From string
+	// To is the layout to migrate to.
+	To string
+	// DryRun lists the migrations without carrying them out if true.
+	DryRun bool
+)
+
+func init() {
+	names := strings.Join(executor.LayoutNames(), ", ")
+	Cmd.Flags().StringVarP(&RootDir, "dir", "d", "",
+		"Data directory (root_directory) to migrate, only read")
+	Cmd.Flags().StringVarP(&OutDir, "out", "o", "",
+		"Directory to write the migrated data directory to, out of --dir")
+	Cmd.Flags().StringVar(&From, "from", "", "Layout of --dir: "+names)
+	Cmd.Flags().StringVar(&To, "to", "v4", "Layout to migrate to: "+names)
+	Cmd.Flags().BoolVar(&DryRun, "dry-run", false,
+		"List the files that would be migrated without writing them")
+	Cmd.MarkFlagRequired("dir")
+	Cmd.MarkFlagRequired("out")
+	Cmd.MarkFlagRequired("from")
+}
+
+func executeMigrate(cmd *cobra.Command, args []string) error {
+	from, ok := executor.Layouts[From]
+	if !ok {
+		return fmt.Errorf("unknown layout %q, must be one of %s", From, strings.Join(executor.LayoutNames(), ", "))
+	}
+	to, ok := executor.Layouts[To]
+	if !ok {
+		return fmt.Errorf("unknown layout %q, must be one of %s", To, strings.Join(executor.LayoutNames(), ", "))
+	}
+	if fi, err := os.Stat(RootDir); err != nil {
+		return err
+	} else if !fi.IsDir() {
+		return fmt.Errorf("%s is not a directory", RootDir)
+	}
+
+	migrations, err := executor.MigrateDataDir(RootDir, OutDir, from, to, DryRun)
+	var converted, copied, done int
+	for _, m := range migrations {
+		switch m.Action {
+		case executor.MigrateConvert:
+			converted++
+			if DryRun {
+				fmt.Printf("convert %s (%s to %s)\n", m.Path, m.From, m.To)
+			}
+		case executor.MigrateCopy:
+			copied++
+			if DryRun {
+				fmt.Printf("copy %s\n", m.Path)
+			}
+		case executor.MigrateDone:
+			done++
+			if DryRun {
+				fmt.Printf("skip %s, migrated already\n", m.Path)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if DryRun {
+		fmt.Printf("would convert %d year files and copy %d files, %d migrated already\n", converted, copied, done)
+		return nil
+	}
+	fmt.Printf("migrated %s to %s in %s: converted %d year files and copied %d files, %d migrated before, all verified\n",
+		RootDir, OutDir, to.Name, converted, copied, done)
+	return nil
+}
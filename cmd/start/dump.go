@@ -0,0 +1,81 @@
+package start
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// profileDump writes the profiles requested by SIGUSR1.
+type profileDump struct {
+	// dir is the directory of the profile files, or empty to write the
+	// profiles as text to stdout
+	dir         string
+	profiles    []string
+	cpuDuration time.Duration
+	stdout      io.Writer
+}
+
+// dump writes each of the profiles to a file of the dump directory named
+// after the time and the profile, e.g. 20210102T150405-heap.pprof, or to
+// stdout without one.  The runtime profiles are in the pprof format, except
+// the goroutine stacks which are text, and the cpu profile is collected for
+// the cpu duration.
+func (d *profileDump) dump() {
+	stamp := time.Now().Format("20060102T150405")
+	for _, name := range d.profiles {
+		if d.dir == "" {
+			if name == "cpu" {
+				log.Warn("skipping the cpu profile, which requires a dump_directory")
+				continue
+			}
+			fmt.Fprintf(d.stdout, "%s profile:\n", name)
+			if err := pprof.Lookup(name).WriteTo(d.stdout, 1); err != nil {
+				log.Error("failed to dump the %s profile - error: %v", name, err)
+			}
+			continue
+		}
+
+		ext := ".pprof"
+		if name == "goroutine" {
+			ext = ".txt"
+		}
+		path := filepath.Join(d.dir, stamp+"-"+name+ext)
+		if err := d.writeFile(path, name); err != nil {
+			log.Error("failed to dump the %s profile to %s - error: %v", name, path, err)
+			continue
+		}
+		log.Info("dumped the %s profile to %s", name, path)
+	}
+}
+
+func (d *profileDump) writeFile(path, name string) error {
+	fp, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+
+	switch name {
+	case "cpu":
+		if err = pprof.StartCPUProfile(fp); err != nil {
+			return err
+		}
+		time.Sleep(d.cpuDuration)
+		pprof.StopCPUProfile()
+	case "goroutine":
+		// the full stacks, like those of a panic
+		err = pprof.Lookup(name).WriteTo(fp, 2)
+	default:
+		err = pprof.Lookup(name).WriteTo(fp, 0)
+	}
+	if err != nil {
+		return err
+	}
+	return fp.Close()
+}
@@ -0,0 +1,48 @@
+package start
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *SignalsTestSuite) TestDumpToDirectory(c *C) {
+	dir := c.MkDir()
+	d := &profileDump{
+		dir:         dir,
+		profiles:    []string{"goroutine", "heap", "cpu"},
+		cpuDuration: 10 * time.Millisecond,
+	}
+	d.dump()
+
+	for _, pattern := range []string{"*-goroutine.txt", "*-heap.pprof", "*-cpu.pprof"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		c.Assert(err, IsNil)
+		c.Assert(matches, HasLen, 1, Commentf(pattern))
+		data, err := ioutil.ReadFile(matches[0])
+		c.Assert(err, IsNil)
+		c.Assert(len(data) > 0, Equals, true)
+	}
+	stacks, err := filepath.Glob(filepath.Join(dir, "*-goroutine.txt"))
+	c.Assert(err, IsNil)
+	data, err := ioutil.ReadFile(stacks[0])
+	c.Assert(err, IsNil)
+	c.Assert(strings.Contains(string(data), "TestDumpToDirectory"), Equals, true)
+}
+
+func (s *SignalsTestSuite) TestDumpToStdout(c *C) {
+	var stdout bytes.Buffer
+	d := &profileDump{
+		profiles: []string{"goroutine", "cpu"},
+		stdout:   &stdout,
+	}
+	d.dump()
+
+	// without the cpu profile, which needs a file
+	c.Assert(strings.HasPrefix(stdout.String(), "goroutine profile:\n"), Equals, true)
+	c.Assert(strings.Contains(stdout.String(), "TestDumpToStdout"), Equals, true)
+}
@@ -0,0 +1,49 @@
+package start
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/alpacahq/marketstore/v4/frontend"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// marketstoreService is the name of the Marketstore service in the health checks.
+const marketstoreService = "proto.Marketstore"
+
+// newGRPCServer returns the gRPC server of the Marketstore service and the
+// standard health service, which reports not serving until setServing, and
+// of the reflection service if enabled in the config.
+func newGRPCServer(config *utils.MktsConfig) (*grpc.Server, *health.Server) {
+	grpcServer := grpc.NewServer(
+		grpc.MaxSendMsgSize(config.GRPCMaxSendMsgSize),
+		grpc.MaxRecvMsgSize(config.GRPCMaxRecvMsgSize),
+	)
+	proto.RegisterMarketstoreServer(grpcServer, frontend.GRPCService{})
+
+	healthServer := health.NewServer()
+	setServing(healthServer, false)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	if config.GRPCReflection {
+		log.Info("enabling grpc reflection...")
+		reflection.Register(grpcServer)
+	}
+	return grpcServer, healthServer
+}
+
+// setServing sets the health status of the server as a whole and of the
+// Marketstore service, which follows the readiness of /readyz.
+func setServing(healthServer *health.Server, serving bool) {
+	status := healthpb.HealthCheckResponse_NOT_SERVING
+	if serving {
+		status = healthpb.HealthCheckResponse_SERVING
+	}
+	for _, service := range []string{"", marketstoreService} {
+		healthServer.SetServingStatus(service, status)
+	}
+}
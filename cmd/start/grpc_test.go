@@ -0,0 +1,59 @@
+package start
+
+import (
+	"context"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/utils"
+)
+
+type GRPCTestSuite struct{}
+
+var _ = Suite(&GRPCTestSuite{})
+
+func (s *GRPCTestSuite) TestHealth(c *C) {
+	grpcServer, healthServer := newGRPCServer(&utils.MktsConfig{})
+	defer grpcServer.Stop()
+
+	check := func(service string) healthpb.HealthCheckResponse_ServingStatus {
+		resp, err := healthServer.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+		c.Assert(err, IsNil)
+		return resp.Status
+	}
+
+	// not serving until queryable
+	c.Assert(check(""), Equals, healthpb.HealthCheckResponse_NOT_SERVING)
+	c.Assert(check(marketstoreService), Equals, healthpb.HealthCheckResponse_NOT_SERVING)
+
+	setServing(healthServer, true)
+	c.Assert(check(""), Equals, healthpb.HealthCheckResponse_SERVING)
+	c.Assert(check(marketstoreService), Equals, healthpb.HealthCheckResponse_SERVING)
+
+	// nor from the shutdown on
+	healthServer.Shutdown()
+	setServing(healthServer, true)
+	c.Assert(check(""), Equals, healthpb.HealthCheckResponse_NOT_SERVING)
+	c.Assert(check(marketstoreService), Equals, healthpb.HealthCheckResponse_NOT_SERVING)
+}
+
+func (s *GRPCTestSuite) TestReflection(c *C) {
+	services := func(config *utils.MktsConfig) map[string]bool {
+		grpcServer, _ := newGRPCServer(config)
+		defer grpcServer.Stop()
+		names := map[string]bool{}
+		for name := range grpcServer.GetServiceInfo() {
+			names[name] = true
+		}
+		return names
+	}
+
+	names := services(&utils.MktsConfig{})
+	c.Assert(names[marketstoreService], Equals, true)
+	c.Assert(names["grpc.health.v1.Health"], Equals, true)
+	c.Assert(names["grpc.reflection.v1alpha.ServerReflection"], Equals, false)
+
+	names = services(&utils.MktsConfig{GRPCReflection: true})
+	c.Assert(names["grpc.reflection.v1alpha.ServerReflection"], Equals, true)
+}
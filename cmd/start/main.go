@@ -1,13 +1,13 @@
 package start
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"runtime/pprof"
 	"sync/atomic"
 	"syscall"
 	"time"
@@ -15,21 +15,23 @@ import (
 	"github.com/alpacahq/marketstore/v4/executor"
 	"github.com/alpacahq/marketstore/v4/frontend"
 	"github.com/alpacahq/marketstore/v4/frontend/stream"
-	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/metrics"
 	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/log"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
 )
 
 const (
-	usage                 = "start"
-	short                 = "Start a marketstore database server"
-	long                  = "This command starts a marketstore database server"
-	example               = "marketstore start --config <path>"
-	defaultConfigFilePath = "./mkts.yml"
-	configDesc            = "set the path for the marketstore YAML configuration file"
+	usage                   = "start"
+	short                   = "Start a marketstore database server"
+	long                    = "This command starts a marketstore database server"
+	example                 = "marketstore start --config <path>"
+	defaultConfigFilePath   = "./mkts.yml"
+	configDesc              = "set the path for the marketstore YAML configuration file"
+	allowPluginFailuresDesc = "start even if some trigger or bgworker plugins fail to load, skipping them"
+	createRootDesc          = "create the root directory if it does not exist, like create_root_directory"
 )
 
 var (
@@ -45,11 +47,17 @@ var (
 	}
 	// configFilePath set flag for a path to the config file.
 	configFilePath string
+	// allowPluginFailures set flag to skip the plugins failing to load.
+	allowPluginFailures bool
+	// createRoot set flag to create the missing root directory.
+	createRoot bool
 )
 
 func init() {
 	utils.InstanceConfig.StartTime = time.Now()
 	Cmd.Flags().StringVarP(&configFilePath, "config", "c", defaultConfigFilePath, configDesc)
+	Cmd.Flags().BoolVar(&allowPluginFailures, "allow-plugin-failures", false, allowPluginFailuresDesc)
+	Cmd.Flags().BoolVar(&createRoot, "create-root", false, createRootDesc)
 }
 
 // executeStart implements the start command.
@@ -70,34 +78,81 @@ func executeStart(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to parse configuration file error: %v", err.Error())
 	}
 
+	// Check the root directory before it is used by the catalog and the WAL.
+	rootDir, err := utils.CheckRootDirectory(utils.InstanceConfig.RootDirectory,
+		utils.InstanceConfig.CreateRootDirectory || createRoot)
+	if err != nil {
+		return err
+	}
+	utils.InstanceConfig.RootDirectory = rootDir
+
 	// New grpc server.
-	grpcServer := grpc.NewServer(
-		grpc.MaxSendMsgSize(utils.InstanceConfig.GRPCMaxSendMsgSize),
-		grpc.MaxRecvMsgSize(utils.InstanceConfig.GRPCMaxRecvMsgSize),
-	)
-	proto.RegisterMarketstoreServer(grpcServer, frontend.GRPCService{})
-
-	// Spawn a goroutine and listen for a signal.
-	signalChan := make(chan os.Signal)
-	go func() {
-		for s := range signalChan {
-			switch s {
-			case syscall.SIGUSR1:
-				log.Info("dumping stack traces due to SIGUSR1 request")
-				pprof.Lookup("goroutine").WriteTo(os.Stdout, 1)
-			case syscall.SIGINT:
-				fallthrough
-			case syscall.SIGTERM:
-				log.Info("initiating graceful shutdown due to '%v' request", s)
-				grpcServer.GracefulStop()
-				atomic.StoreUint32(&frontend.Queryable, uint32(0))
-				log.Info("waiting a grace period of %v to shutdown...", utils.InstanceConfig.StopGracePeriod)
-				time.Sleep(utils.InstanceConfig.StopGracePeriod)
-				shutdown()
-			}
+	grpcServer, healthServer := newGRPCServer(&utils.InstanceConfig)
+
+	// New http server for rpc, websocket and metrics.  It has its own mux
+	// rather than the default one, which net/http/pprof registers itself on.
+	mux := http.NewServeMux()
+	httpServer := newHTTPServer(utils.InstanceConfig.ListenURL, mux)
+	httpServers := []*http.Server{httpServer}
+
+	// The health checks, the metrics and the profiling are served on the
+	// admin listener if set, apart from the data traffic, and on the main
+	// one otherwise.
+	adminMux := mux
+	var adminServer *http.Server
+	if utils.InstanceConfig.AdminListenURL != "" {
+		adminMux = http.NewServeMux()
+		adminServer = newHTTPServer(utils.InstanceConfig.AdminListenURL, adminMux)
+		httpServers = append(httpServers, adminServer)
+	}
+
+	var utilitiesServer *http.Server
+	if utils.InstanceConfig.UtilitiesURL != "" {
+		utilitiesServer = frontend.Utilities(utils.InstanceConfig.UtilitiesURL)
+		httpServers = append(httpServers, utilitiesServer)
+	}
+
+	// Spawn a goroutine and listen for a signal.  The channel is buffered
+	// not to miss a signal sent while the previous one is handled.
+	signalChan := make(chan os.Signal, 1)
+	dump := &profileDump{
+		dir:         utils.InstanceConfig.DumpDirectory,
+		profiles:    utils.InstanceConfig.DumpProfiles,
+		cpuDuration: utils.InstanceConfig.DumpCPUDuration,
+		stdout:      os.Stdout,
+	}
+	go handleSignals(signalChan, dump.dump, func(os.Signal) {
+		gracefulShutdown(shutdownPhases(grpcServer, healthServer, httpServers),
+			utils.InstanceConfig.StopGracePeriod, os.Exit)
+	}, os.Exit)
+	signal.Notify(signalChan, syscall.SIGUSR1, syscall.SIGINT, syscall.SIGTERM)
+
+	// Serve the health checks while initializing, so that the readiness
+	// probe reports the instance is not ready until the WAL is replayed.
+	adminMux.HandleFunc("/healthz", frontend.Healthz)
+	adminMux.HandleFunc("/readyz", frontend.Readyz)
+	if adminServer != nil {
+		log.Info("launching tcp listener for admin services...")
+		adminLn, err := net.Listen("tcp", utils.InstanceConfig.AdminListenURL)
+		if err != nil {
+			return fmt.Errorf("failed to start admin server - error: %s", err.Error())
 		}
+		go func() {
+			if err := adminServer.Serve(adminLn); err != nil && err != http.ErrServerClosed {
+				log.Error("failed to serve admin services - error: %v", err)
+			}
+		}()
+	}
+
+	log.Info("launching tcp listener for http services...")
+	ln, err := net.Listen("tcp", utils.InstanceConfig.ListenURL)
+	if err != nil {
+		return fmt.Errorf("failed to start server - error: %s", err.Error())
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.Serve(ln)
 	}()
-	signal.Notify(signalChan, syscall.SIGUSR1, syscall.SIGINT, syscall.SIGTERM)
 
 	// Initialize marketstore services.
 	// --------------------------------
@@ -111,38 +166,82 @@ func executeStart(cmd *cobra.Command, args []string) error {
 		utils.InstanceConfig.BackgroundSync,
 		utils.InstanceConfig.WALBypass)
 
+	if len(utils.InstanceConfig.Schemas) > 0 || utils.InstanceConfig.StrictSchemas {
+		log.Info("declaring the bucket schemas...")
+		if err := executor.DeclareSchemas(utils.InstanceConfig.Schemas, utils.InstanceConfig.StrictSchemas); err != nil {
+			return fmt.Errorf("failed to declare the bucket schemas - error: %v", err)
+		}
+	}
+
+	if utils.InstanceConfig.QueryCacheSize > 0 {
+		log.Info("enabling the query cache of %d results...", utils.InstanceConfig.QueryCacheSize)
+		frontend.InitQueryCache(utils.InstanceConfig.QueryCacheSize, utils.InstanceConfig.QueryCacheTTL)
+	}
+
 	// New server.
 	server, _ := frontend.NewServer()
 
 	// Set rpc handler.
 	log.Info("launching rpc data server...")
-	http.Handle("/rpc", server)
+	mux.Handle("/rpc", server)
 
 	// Set websocket handler.
 	log.Info("initializing websocket...")
 	stream.Initialize()
-	http.HandleFunc("/ws", stream.Handler)
+	mux.HandleFunc("/ws", stream.Handler)
 
 	// Set monitoring handler.
 	log.Info("launching prometheus metrics server...")
-	http.Handle("/metrics", promhttp.Handler())
+	adminMux.Handle("/metrics", metrics.Setup(
+		utils.InstanceConfig.MetricsNamespace,
+		utils.InstanceConfig.MetricsLabels,
+	))
+
+	if utils.InstanceConfig.EnablePprof {
+		// Set profiling handler.
+		log.Info("enabling pprof endpoints at /debug/pprof/...")
+		frontend.RegisterPprof(adminMux)
+	}
 
 	// Initialize any provided plugins.
-	InitializeTriggers()
-	RunBgWorkers()
+	if err := InitializeTriggers(allowPluginFailures); err != nil {
+		return err
+	}
+	if err := RunBgWorkers(allowPluginFailures); err != nil {
+		return err
+	}
 
-	if utils.InstanceConfig.UtilitiesURL != "" {
+	log.Info("launching disk usage collection every %v...", utils.InstanceConfig.DiskUsageInterval)
+	go executor.RunDiskUsage(utils.InstanceConfig.DiskUsageInterval)
+	log.Info("launching ingestion lag collection every %v...", utils.InstanceConfig.IngestionLagInterval)
+	go executor.RunIngestionLag(utils.InstanceConfig.IngestionLagInterval)
+
+	if len(utils.InstanceConfig.Retention.Policies) > 0 {
+		retention, err := executor.NewRetention(utils.InstanceConfig.Retention)
+		if err != nil {
+			return fmt.Errorf("failed to set up retention - error: %v", err)
+		}
+		log.Info("launching retention pruning...")
+		go retention.Run(utils.InstanceConfig.Retention.Interval)
+	}
+
+	if utilitiesServer != nil {
 		// Start utility endpoints.
 		log.Info("launching utility service...")
-		go frontend.Utilities(utils.InstanceConfig.UtilitiesURL)
+		go func() {
+			if err := utilitiesServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error("failed to start utility service - error: %v", err)
+			}
+		}()
 	}
 
 	log.Info("enabling query access...")
 	atomic.StoreUint32(&frontend.Queryable, 1)
+	setServing(healthServer, true)
 
 	// Serve.
-	log.Info("launching tcp listener for all services...")
 	if utils.InstanceConfig.GRPCListenURL != "" {
+		log.Info("launching tcp listener for grpc services...")
 		grpcLn, err := net.Listen("tcp", utils.InstanceConfig.GRPCListenURL)
 		if err != nil {
 			return fmt.Errorf("failed to start GRPC server - error: %s", err.Error())
@@ -155,16 +254,81 @@ func executeStart(cmd *cobra.Command, args []string) error {
 		}()
 	}
 
-	if err := http.ListenAndServe(utils.InstanceConfig.ListenURL, nil); err != nil {
+	if err := <-serveErr; err != http.ErrServerClosed {
 		return fmt.Errorf("failed to start server - error: %s", err.Error())
 	}
+	// the server is shutting down, and the process exits once it's done
+	select {}
+}
+
+// newHTTPServer returns an http server on addr with the timeouts of the
+// config.
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:      handler,
+		Addr:         addr,
+		ReadTimeout:  utils.InstanceConfig.HTTPReadTimeout,
+		WriteTimeout: utils.InstanceConfig.HTTPWriteTimeout,
+		IdleTimeout:  utils.InstanceConfig.HTTPIdleTimeout,
+	}
+}
+
+// shutdownHTTP closes the websocket streams, and waits until ctx is done
+// for the in-flight requests of the servers to complete.
+func shutdownHTTP(ctx context.Context, servers []*http.Server) {
+	stream.Shutdown()
+
+	log.Info("waiting for in-flight http requests...")
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err == context.DeadlineExceeded {
+			log.Warn("in-flight requests to %v were not drained within the grace period", srv.Addr)
+		} else if err != nil {
+			log.Error("failed to shutdown http server on %v - error: %v", srv.Addr, err)
+		}
+	}
+}
 
-	return nil
+// shutdownPhases returns the phases of the graceful shutdown: no more
+// requests are accepted, once the in-flight ones are done, then the
+// pending writes are flushed along with those of the triggers they fired,
+// and the WAL is written to disk, all within the grace period.
+func shutdownPhases(grpcServer *grpc.Server, healthServer *health.Server,
+	httpServers []*http.Server) []shutdownPhase {
+	return []shutdownPhase{
+		{"stop accepting requests", func(ctx context.Context) error {
+			atomic.StoreUint32(&frontend.Queryable, uint32(0))
+			// not serving from then on
+			healthServer.Shutdown()
+			stopGRPC(ctx, grpcServer)
+			shutdownHTTP(ctx, httpServers)
+			return ctx.Err()
+		}},
+		{"drain triggers", func(ctx context.Context) error {
+			if executor.ThisInstance.WALFile == nil {
+				return nil
+			}
+			return executor.ThisInstance.DrainTriggers(ctx)
+		}},
+		{"flush WAL", func(ctx context.Context) error {
+			executor.ThisInstance.ShutdownPending = true
+			executor.ThisInstance.WALWg.Wait()
+			return nil
+		}},
+	}
 }
 
-func shutdown() {
-	executor.ThisInstance.ShutdownPending = true
-	executor.ThisInstance.WALWg.Wait()
-	log.Info("exiting...")
-	os.Exit(0)
+// stopGRPC waits until ctx is done for the in-flight gRPC requests to
+// complete, and closes the connections after that.
+func stopGRPC(ctx context.Context, grpcServer *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		log.Warn("in-flight grpc requests were not drained within the grace period")
+		grpcServer.Stop()
+	}
 }
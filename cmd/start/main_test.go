@@ -0,0 +1,55 @@
+package start
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/utils"
+)
+
+type HTTPTestSuite struct{}
+
+var _ = Suite(&HTTPTestSuite{})
+
+func (s *HTTPTestSuite) TestNewHTTPServer(c *C) {
+	defer func(config utils.MktsConfig) { utils.InstanceConfig = config }(utils.InstanceConfig)
+	utils.InstanceConfig.HTTPReadTimeout = 3 * time.Second
+	utils.InstanceConfig.HTTPWriteTimeout = 100 * time.Millisecond
+	utils.InstanceConfig.HTTPIdleTimeout = 5 * time.Second
+
+	mux := http.NewServeMux()
+	srv := newHTTPServer("localhost:0", mux)
+	c.Assert(srv.Addr, Equals, "localhost:0")
+	c.Assert(srv.Handler, Equals, http.Handler(mux))
+	c.Assert(srv.ReadTimeout, Equals, 3*time.Second)
+	c.Assert(srv.WriteTimeout, Equals, 100*time.Millisecond)
+	c.Assert(srv.IdleTimeout, Equals, 5*time.Second)
+
+	// a response written past the write timeout does not reach the client
+	mux.HandleFunc("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fast"))
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(3 * utils.InstanceConfig.HTTPWriteTimeout)
+		w.Write([]byte("slow"))
+	})
+	ln, err := net.Listen("tcp", srv.Addr)
+	c.Assert(err, IsNil)
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get("http://" + ln.Addr().String() + "/fast")
+	c.Assert(err, IsNil)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, IsNil)
+	c.Assert(string(body), Equals, "fast")
+
+	_, err = client.Get("http://" + ln.Addr().String() + "/slow")
+	c.Assert(err, NotNil)
+}
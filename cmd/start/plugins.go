@@ -1,6 +1,9 @@
 package start
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/alpacahq/marketstore/v4/executor"
 	"github.com/alpacahq/marketstore/v4/plugins"
 	"github.com/alpacahq/marketstore/v4/plugins/bgworker"
@@ -9,62 +12,95 @@ import (
 	"github.com/alpacahq/marketstore/v4/utils/log"
 )
 
-func InitializeTriggers() {
+// InitializeTriggers sets up the triggers of the config, failing if the
+// module of one of them cannot be loaded, unless allowFailures is set, in
+// which case the trigger is skipped.
+func InitializeTriggers(allowFailures bool) error {
 	log.Info("InitializeTriggers")
 	config := utils.InstanceConfig
 	theInstance := executor.ThisInstance
+	var loaded []string
 	for _, triggerSetting := range config.Triggers {
 		log.Info("triggerSetting = %v", triggerSetting)
-		tmatcher := NewTriggerMatcher(triggerSetting)
-		if tmatcher != nil {
-			theInstance.TriggerMatchers = append(
-				theInstance.TriggerMatchers, tmatcher)
+		tmatcher, err := NewTriggerMatcher(triggerSetting)
+		if err != nil {
+			err = fmt.Errorf("failed to set up the trigger %s on %s: %v", triggerSetting.Module, triggerSetting.On, err)
+			if !allowFailures {
+				return err
+			}
+			log.Error("%v, skipping it as plugin failures are allowed", err)
+			continue
 		}
+		theInstance.TriggerMatchers = append(
+			theInstance.TriggerMatchers, tmatcher)
+		loaded = append(loaded, fmt.Sprintf("%s on %s", triggerSetting.Module, triggerSetting.On))
 	}
-	log.Info("InitializeTriggers - Done")
+	log.Info("InitializeTriggers - Done, loaded %d/%d triggers: [%s]",
+		len(loaded), len(config.Triggers), strings.Join(loaded, ", "))
+	return nil
 }
 
-func NewTriggerMatcher(ts *utils.TriggerSetting) *trigger.TriggerMatcher {
+func NewTriggerMatcher(ts *utils.TriggerSetting) (*trigger.TriggerMatcher, error) {
 	loader, err := plugins.NewSymbolLoader(ts.Module)
 	if err != nil {
-		log.Error("Unable to open plugin for trigger in %s: %v", ts.Module, err)
-		return nil
+		return nil, fmt.Errorf("unable to open plugin: %v", err)
 	}
 	trig, err := trigger.Load(loader, ts.Config)
 	if err != nil {
-		log.Error("Error returned while creating a trigger: %v", err)
-		return nil
+		return nil, fmt.Errorf("error returned while creating a trigger: %v", err)
 	}
-	return trigger.NewMatcher(trig, ts.On)
+	tmatcher := trigger.NewMatcher(trig, ts.On)
+	tmatcher.Name = ts.Module
+	return tmatcher, nil
 }
 
-func RunBgWorkers() {
+// RunBgWorkers sets up the bgworkers of the config and runs them, failing
+// before running any of them if the module of one cannot be loaded, unless
+// allowFailures is set, in which case the bgworker is skipped.
+func RunBgWorkers(allowFailures bool) error {
 	log.Info("InitializeBgWorkers")
 	config := utils.InstanceConfig
-	for _, bgWorkerSetting := range config.BgWorkers {
+	bgWorkers := make([]bgworker.BgWorker, len(config.BgWorkers))
+	var loaded []string
+	for i, bgWorkerSetting := range config.BgWorkers {
 		// bgWorkerSetting may contain sensitive data such as a password or token.
 		log.Debug("bgWorkerSetting = %v", bgWorkerSetting)
-		bgWorker := NewBgWorker(bgWorkerSetting)
-		if bgWorker != nil {
-			// we should probably keep track of this process status
-			// and may want to kill it or get info.  utils.Process may help
-			// but will figure it out later.
-			log.Info("Start running BgWorker %s...", bgWorkerSetting.Name)
-			go bgWorker.Run()
+		bgWorker, err := NewBgWorker(bgWorkerSetting)
+		if err != nil {
+			err = fmt.Errorf("failed to set up the bgworker %s: %v", bgWorkerSetting.Name, err)
+			if !allowFailures {
+				return err
+			}
+			log.Error("%v, skipping it as plugin failures are allowed", err)
+			continue
+		}
+		bgWorkers[i] = bgWorker
+		loaded = append(loaded, fmt.Sprintf("%s (%s)", bgWorkerSetting.Name, bgWorkerSetting.Module))
+	}
+	for i, bgWorker := range bgWorkers {
+		if bgWorker == nil {
+			continue
 		}
+		bgWorkerSetting := config.BgWorkers[i]
+		log.Info("Start running BgWorker %s...", bgWorkerSetting.Name)
+		go bgworker.NewSupervisor(bgWorkerSetting.Name, bgWorker, bgWorkerSetting.StallTimeout).Run()
 	}
-	log.Info("InitializeBgWorkers Done")
+	log.Info("InitializeBgWorkers Done, running %d/%d bgworkers: [%s]",
+		len(loaded), len(config.BgWorkers), strings.Join(loaded, ", "))
+	return nil
 }
 
-func NewBgWorker(s *utils.BgWorkerSetting) bgworker.BgWorker {
+func NewBgWorker(s *utils.BgWorkerSetting) (bgworker.BgWorker, error) {
 	loader, err := plugins.NewSymbolLoader(s.Module)
 	if err != nil {
-		log.Error("Unable to open plugin for bgworker in %s: %v", s.Module, err)
-		return nil
+		return nil, fmt.Errorf("unable to open plugin: %v", err)
 	}
 	bgWorker, err := bgworker.Load(loader, s.Config)
 	if err != nil {
-		log.Error("Failed to create bgworker: %v", err)
+		return nil, fmt.Errorf("error returned while creating a bgworker: %v", err)
+	}
+	if bgWorker == nil {
+		return nil, fmt.Errorf("no bgworker returned by %s", s.Module)
 	}
-	return bgWorker
+	return bgWorker, nil
 }
@@ -0,0 +1,35 @@
+package start
+
+import (
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/utils"
+	. "gopkg.in/check.v1"
+)
+
+type PluginsTestSuite struct{}
+
+var _ = Suite(&PluginsTestSuite{})
+
+func (s *PluginsTestSuite) TestInitializeTriggersUnknownModule(c *C) {
+	executor.NewInstanceSetup(c.MkDir(), true, true, false, true)
+	defer func(triggers []*utils.TriggerSetting) { utils.InstanceConfig.Triggers = triggers }(utils.InstanceConfig.Triggers)
+	utils.InstanceConfig.Triggers = []*utils.TriggerSetting{{Module: "missing.so", On: "*/1Min/OHLCV"}}
+
+	err := InitializeTriggers(false)
+	c.Assert(err, ErrorMatches, `(?s)failed to set up the trigger missing.so on \*/1Min/OHLCV: unable to open plugin: .*`)
+	c.Assert(executor.ThisInstance.TriggerMatchers, HasLen, 0)
+
+	// skipped if the failures are allowed
+	c.Assert(InitializeTriggers(true), IsNil)
+	c.Assert(executor.ThisInstance.TriggerMatchers, HasLen, 0)
+}
+
+func (s *PluginsTestSuite) TestRunBgWorkersUnknownModule(c *C) {
+	defer func(bgWorkers []*utils.BgWorkerSetting) { utils.InstanceConfig.BgWorkers = bgWorkers }(utils.InstanceConfig.BgWorkers)
+	utils.InstanceConfig.BgWorkers = []*utils.BgWorkerSetting{{Name: "feeder", Module: "missing.so"}}
+
+	err := RunBgWorkers(false)
+	c.Assert(err, ErrorMatches, `(?s)failed to set up the bgworker feeder: unable to open plugin: .*missing.so.*`)
+
+	c.Assert(RunBgWorkers(true), IsNil)
+}
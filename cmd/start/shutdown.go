@@ -0,0 +1,55 @@
+package start
+
+import (
+	"context"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// shutdownPhase is a step of the graceful shutdown, which returns the
+// context error if the deadline of the shutdown passes before it finishes.
+type shutdownPhase struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// gracefulShutdown runs the phases in order, logging how long each took,
+// and exits with status 0 once they are all done.  The phases share the
+// grace period, from the start of the shutdown: if a phase fails or the
+// grace period passes, the process exits with status 1 without running
+// the rest.
+func gracefulShutdown(phases []shutdownPhase, gracePeriod time.Duration, exit func(code int)) {
+	start := time.Now()
+	ctx, cancel := context.WithDeadline(context.Background(), start.Add(gracePeriod))
+	defer cancel()
+	for _, phase := range phases {
+		if err := runShutdownPhase(ctx, phase); err != nil {
+			log.Error("failed to %s - error: %v, forcing exit", phase.name, err)
+			exit(1)
+			return
+		}
+	}
+	log.Info("exiting after a graceful shutdown of %v...", time.Since(start))
+	exit(0)
+}
+
+func runShutdownPhase(ctx context.Context, phase shutdownPhase) error {
+	start := time.Now()
+	log.Info("shutdown: %s...", phase.name)
+	done := make(chan error, 1)
+	go func() {
+		done <- phase.run(ctx)
+	}()
+	// not relying on the phase to return when the context is done
+	select {
+	case err := <-done:
+		if err != nil {
+			return err
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	log.Info("shutdown: %s took %v", phase.name, time.Since(start))
+	return nil
+}
@@ -0,0 +1,65 @@
+package start
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+type ShutdownTestSuite struct{}
+
+var _ = Suite(&ShutdownTestSuite{})
+
+func (s *ShutdownTestSuite) TestGracefulShutdown(c *C) {
+	var ran []string
+	phase := func(name string) shutdownPhase {
+		return shutdownPhase{name, func(ctx context.Context) error {
+			ran = append(ran, name)
+			return nil
+		}}
+	}
+
+	code := -1
+	gracefulShutdown([]shutdownPhase{phase("first"), phase("second")}, time.Second, func(c int) { code = c })
+	c.Assert(ran, DeepEquals, []string{"first", "second"})
+	c.Assert(code, Equals, 0)
+}
+
+func (s *ShutdownTestSuite) TestGracefulShutdownTimeout(c *C) {
+	hung := make(chan struct{})
+	defer close(hung)
+
+	never := false
+	code := -1
+	gracefulShutdown([]shutdownPhase{
+		{"hang", func(ctx context.Context) error {
+			// not returning on the context
+			<-hung
+			return nil
+		}},
+		{"never", func(ctx context.Context) error {
+			never = true
+			return nil
+		}},
+	}, 10*time.Millisecond, func(c int) { code = c })
+	c.Assert(never, Equals, false)
+	c.Assert(code, Equals, 1)
+}
+
+func (s *ShutdownTestSuite) TestGracefulShutdownDeadline(c *C) {
+	// the phases share the grace period, rather than each having it
+	var deadlines []time.Time
+	phase := func(ctx context.Context) error {
+		deadline, _ := ctx.Deadline()
+		deadlines = append(deadlines, deadline)
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	}
+	code := -1
+	gracefulShutdown([]shutdownPhase{{"first", phase}, {"second", phase}, {"third", phase}},
+		50*time.Millisecond, func(c int) { code = c })
+	c.Assert(code, Equals, 1)
+	c.Assert(deadlines, HasLen, 2)
+	c.Assert(deadlines[1], Equals, deadlines[0])
+}
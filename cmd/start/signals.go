@@ -0,0 +1,33 @@
+package start
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// handleSignals handles the signals until the channel is closed.  The
+// profiles are dumped on SIGUSR1, in the background as the cpu profile
+// takes a while, and the graceful shutdown is run on the first SIGINT or
+// SIGTERM, in the background so that another one force-exits the process
+// with exit, e.g. on a hung shutdown.
+func handleSignals(signals <-chan os.Signal, dump func(), shutdown func(os.Signal), exit func(code int)) {
+	shuttingDown := false
+	for s := range signals {
+		switch s {
+		case syscall.SIGUSR1:
+			log.Info("dumping profiles due to SIGUSR1 request")
+			go dump()
+		case syscall.SIGINT, syscall.SIGTERM:
+			if shuttingDown {
+				log.Warn("forcing exit due to '%v' request during graceful shutdown", s)
+				exit(1)
+				continue
+			}
+			shuttingDown = true
+			log.Info("initiating graceful shutdown due to '%v' request, send it again to force exit", s)
+			go shutdown(s)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package start
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type SignalsTestSuite struct{}
+
+var _ = Suite(&SignalsTestSuite{})
+
+func (s *SignalsTestSuite) TestForceExit(c *C) {
+	signals := make(chan os.Signal, 1)
+	shutdowns := make(chan os.Signal, 2)
+	hung := make(chan struct{})
+	defer close(hung)
+	exits := make(chan int, 2)
+
+	done := make(chan struct{})
+	go func() {
+		handleSignals(signals, func() {}, func(sig os.Signal) {
+			shutdowns <- sig
+			<-hung
+		}, func(code int) { exits <- code })
+		close(done)
+	}()
+
+	// the first signal starts the graceful shutdown, which hangs
+	signals <- syscall.SIGTERM
+	select {
+	case sig := <-shutdowns:
+		c.Assert(sig, Equals, syscall.SIGTERM)
+	case <-time.After(time.Second):
+		c.Fatal("the shutdown was not started")
+	}
+
+	// the second one is still handled, and forces the exit
+	signals <- syscall.SIGINT
+	select {
+	case code := <-exits:
+		c.Assert(code, Equals, 1)
+	case <-time.After(time.Second):
+		c.Fatal("the exit was not forced")
+	}
+	close(signals)
+	<-done
+	c.Assert(shutdowns, HasLen, 0)
+}
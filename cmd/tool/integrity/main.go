@@ -79,12 +79,6 @@ func init() {
 		os.Exit(0)
 	}
 
-	if !parallel {
-		log.Info("Running single threaded")
-	} else {
-		log.Info("Running in parallel")
-	}
-
 	if yearEnd == 0 {
 		yearEnd = 10000
 	}
@@ -147,6 +141,12 @@ func executeIntegrity(cmd *cobra.Command, args []string) error {
 
 	log.Info("Root directory: %v", rootDirPath)
 
+	if !parallel {
+		log.Info("Running single threaded")
+	} else {
+		log.Info("Running in parallel")
+	}
+
 	// Perform integrity check.
 	return filepath.Walk(rootDirPath, cksumDataFiles)
 }
@@ -0,0 +1,38 @@
+package version
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	usage   = "version"
+	short   = "Show the version of the build"
+	long    = "This command shows the version, the git commit and the build time of the binary, and the Go version it was built with"
+	example = "marketstore version"
+)
+
+// Cmd is the version command.
+var Cmd = &cobra.Command{
+	Use:     usage,
+	Short:   short,
+	Long:    long,
+	Example: example,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		Print(cmd.OutOrStdout())
+		return nil
+	},
+}
+
+// Print writes the version info of the binary to w.
+func Print(w io.Writer) {
+	info := utils.GetVersionInfo()
+	fmt.Fprintf(w, "version: %+v\n", info.Version)
+	fmt.Fprintf(w, "commit hash: %+v\n", info.GitHash)
+	fmt.Fprintf(w, "utc build time: %+v\n", info.BuildTime)
+	fmt.Fprintf(w, "go version: %+v\n", info.GoVersion)
+}
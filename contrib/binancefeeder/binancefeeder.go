@@ -14,6 +14,7 @@ import (
 	"github.com/alpacahq/marketstore/v4/executor"
 	"github.com/alpacahq/marketstore/v4/planner"
 	"github.com/alpacahq/marketstore/v4/plugins/bgworker"
+	"github.com/alpacahq/marketstore/v4/plugins/version"
 	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/io"
 	"github.com/alpacahq/marketstore/v4/utils/log"
@@ -457,6 +458,9 @@ func (bn *BinanceFetcher) Run() {
 	}
 }
 
+// MarketstoreVersion stamps the plugin with the version it is built against.
+var MarketstoreVersion = version.Version
+
 func main() {
 	// symbol := "BTC"
 	// interval := "1m"
@@ -10,6 +10,7 @@ import (
 	"github.com/alpacahq/marketstore/v4/executor"
 	"github.com/alpacahq/marketstore/v4/planner"
 	"github.com/alpacahq/marketstore/v4/plugins/bgworker"
+	"github.com/alpacahq/marketstore/v4/plugins/version"
 	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/io"
 	"github.com/alpacahq/marketstore/v4/utils/log"
@@ -202,6 +203,9 @@ func (gd *BitmexFetcher) Run() {
 	}
 }
 
+// MarketstoreVersion stamps the plugin with the version it is built against.
+var MarketstoreVersion = version.Version
+
 func main() {
 	client := bitmex.Init()
 	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -1,13 +1,15 @@
 // Package calendar provides market calendar, with which you can
 // check if the market is open at specific point of time.
-// Though the package is generalized to support different market
-// calendars, only the NASDAQ is implemented at this moment.
-// You can create your own calendar if you provide the calendar
-// json string.  See nasdaq.go for the format.
+// The NASDAQ (also used for the NYSE), LSE and TSE calendars are
+// available by name through Get.  You can create your own calendar
+// if you provide the calendar json string.  See nasdaq.go for the format.
+// Sessions enumerates the trading days of a calendar backward from a day.
 package calendar
 
 import (
 	"encoding/json"
+	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +22,29 @@ const (
 	EarlyClose
 )
 
+// MarketCalendar is the interface implemented by the market calendars.
+type MarketCalendar interface {
+	// IsMarketDay returns true if the market opens on the day of t.
+	IsMarketDay(t time.Time) bool
+	// IsMarketOpen returns true if t is in the market hours.
+	IsMarketOpen(t time.Time) bool
+	// EpochIsMarketOpen returns true if epoch is in the market hours.
+	EpochIsMarketOpen(epoch int64) bool
+	// MarketOpen returns the market open time of the day of t,
+	// or the zero time if the market is closed on that day.
+	MarketOpen(t time.Time) time.Time
+	// MarketClose returns the market close time of the day of t,
+	// taking early closes into account, or the zero time if the
+	// market is closed on that day.
+	MarketClose(t time.Time) time.Time
+	// EpochMarketClose returns the market close time of the day of epoch.
+	EpochMarketClose(epoch int64) time.Time
+	// IsEarlyClose returns true if the market closes early on the day of t.
+	IsEarlyClose(t time.Time) bool
+	// Tz returns the timezone of the market.
+	Tz() *time.Location
+}
+
 type Time struct {
 	hour, minute, second int
 }
@@ -41,8 +66,48 @@ type calendarJson struct {
 	EarlyCloseTime string   `json:"early_close_time"`
 }
 
-// Nasdaq implements market calendar for the NASDAQ.
-var Nasdaq = New(NasdaqJson)
+var (
+	// Nasdaq implements market calendar for the NASDAQ.
+	Nasdaq = New(NasdaqJson)
+	// LSE implements market calendar for the London Stock Exchange.
+	LSE = New(LSEJson)
+	// TSE implements market calendar for the Tokyo Stock Exchange.
+	TSE = New(TSEJson)
+
+	calendars = map[string]MarketCalendar{
+		"nasdaq": Nasdaq,
+		"nyse":   Nasdaq,
+		"lse":    LSE,
+		"tse":    TSE,
+	}
+)
+
+// Register makes a market calendar available by name,
+// replacing any calendar previously registered with it.
+func Register(name string, cal MarketCalendar) {
+	calendars[strings.ToLower(name)] = cal
+}
+
+// Get returns the market calendar registered with the name
+// (case insensitive), e.g. "nasdaq", "nyse", "lse" or "tse".
+func Get(name string) (MarketCalendar, error) {
+	cal, ok := calendars[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown market calendar %q, available calendars: %v",
+			name, strings.Join(Names(), ", "))
+	}
+	return cal, nil
+}
+
+// Names returns the sorted names of the registered market calendars.
+func Names() []string {
+	names := make([]string, 0, len(calendars))
+	for name := range calendars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
 func jd(t time.Time) int {
 	// Note: Date() is faster than calling Hour(), Month(), and Day() separately
@@ -132,44 +197,70 @@ func (calendar *Calendar) IsMarketOpen(t time.Time) bool {
 	}
 }
 
-// EpochMarketClose determines the market close time of the day that
-// the supplied epoch timestamp occurs on. Returns nil if it is not
+// MarketOpen determines the market open time of the day that the
+// supplied timestamp occurs on.  Returns the zero time if it is not
 // a market day.
-func (calendar *Calendar) EpochMarketClose(epoch int64) *time.Time {
+func (calendar *Calendar) MarketOpen(t time.Time) time.Time {
+	if !calendar.IsMarketDay(t) {
+		return time.Time{}
+	}
+
+	ot := calendar.openTime
+	year, month, day := t.Date()
+	return time.Date(year, month, day, ot.hour, ot.minute, ot.second, 0, calendar.tz)
+}
+
+// EpochMarketClose determines the market close time of the day that
+// the supplied epoch timestamp occurs on. Returns the zero time if it
+// is not a market day.
+func (calendar *Calendar) EpochMarketClose(epoch int64) time.Time {
 	t := time.Unix(epoch, 0).In(calendar.tz)
 	return calendar.MarketClose(t)
 }
 
 // MarketClose determines the market close time of the day that the
-// supplied timestamp occurs on. Returns nil if it is not a market day.
-func (calendar *Calendar) MarketClose(t time.Time) (mktClose *time.Time) {
-	if state, ok := calendar.days[jd(t)]; ok {
-		switch state {
-		case EarlyClose:
-			earlyClose := time.Date(
-				t.Year(), t.Month(), t.Day(),
-				calendar.earlyCloseTime.hour,
-				calendar.earlyCloseTime.minute,
-				calendar.earlyCloseTime.second,
-				0, calendar.tz)
-
-			mktClose = &earlyClose
-		case Closed:
-			return
-		default:
-			normalClose := time.Date(
-				t.Year(), t.Month(), t.Day(),
-				calendar.closeTime.hour,
-				calendar.closeTime.minute,
-				calendar.closeTime.second,
-				0, calendar.tz)
-
-			mktClose = &normalClose
-		}
+// supplied timestamp occurs on, taking early closes into account.
+// Returns the zero time if it is not a market day.
+func (calendar *Calendar) MarketClose(t time.Time) time.Time {
+	if !calendar.IsMarketDay(t) {
+		return time.Time{}
+	}
+
+	ct := calendar.closeTime
+	if calendar.IsEarlyClose(t) {
+		ct = calendar.earlyCloseTime
 	}
-	return nil
+
+	year, month, day := t.Date()
+	return time.Date(year, month, day, ct.hour, ct.minute, ct.second, 0, calendar.tz)
+}
+
+// IsEarlyClose returns true if the day of t is a half-day
+// on which the market closes early.
+func (calendar *Calendar) IsEarlyClose(t time.Time) bool {
+	state, ok := calendar.days[jd(t)]
+	return ok && state == EarlyClose
 }
 
 func (calendar *Calendar) Tz() *time.Location {
 	return calendar.tz
 }
+
+// Sessions returns the days of the last n sessions of cal up to the
+// day of t included, the latest first, each at midnight in the location
+// of t.  Fewer are returned if cal has no sessions for a year before
+// the earliest found.
+func Sessions(cal MarketCalendar, t time.Time, n int) []time.Time {
+	sessions := make([]time.Time, 0, n)
+	year, month, day := t.Date()
+	d := time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+	for closed := 0; len(sessions) < n && closed <= 366; d = d.AddDate(0, 0, -1) {
+		if !cal.IsMarketDay(d) {
+			closed++
+			continue
+		}
+		sessions = append(sessions, d)
+		closed = 0
+	}
+	return sessions
+}
@@ -1,6 +1,8 @@
 package calendar
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -51,3 +53,156 @@ func (s *CalendarTestSuite) TestCalendar(c *C) {
 
 	c.Assert(Nasdaq.Tz().String(), Equals, "America/New_York")
 }
+
+func (s *CalendarTestSuite) TestGet(c *C) {
+	for _, name := range []string{"nasdaq", "NYSE", "lse", "tse"} {
+		cal, err := Get(name)
+		c.Assert(err, IsNil)
+		c.Assert(cal, NotNil)
+	}
+
+	_, err := Get("xetra")
+	c.Assert(err, NotNil)
+}
+
+func (s *CalendarTestSuite) TestLSE(c *C) {
+	London, _ := time.LoadLocation("Europe/London")
+
+	// Early May bank holiday moved to VE day in 2020
+	c.Assert(LSE.IsMarketDay(time.Date(2020, 5, 4, 11, 0, 0, 0, London)), Equals, true)
+	c.Assert(LSE.IsMarketDay(time.Date(2020, 5, 8, 11, 0, 0, 0, London)), Equals, false)
+
+	// Boxing day
+	c.Assert(LSE.IsMarketDay(time.Date(2019, 12, 26, 11, 0, 0, 0, London)), Equals, false)
+
+	// Christmas Eve half-day
+	c.Assert(LSE.IsMarketOpen(time.Date(2019, 12, 24, 12, 0, 0, 0, London)), Equals, true)
+	c.Assert(LSE.IsMarketOpen(time.Date(2019, 12, 24, 13, 0, 0, 0, London)), Equals, false)
+
+	// normal day
+	c.Assert(LSE.IsMarketOpen(time.Date(2019, 12, 23, 16, 0, 0, 0, London)), Equals, true)
+	c.Assert(LSE.Tz().String(), Equals, "Europe/London")
+}
+
+func (s *CalendarTestSuite) TestTSE(c *C) {
+	Tokyo, _ := time.LoadLocation("Asia/Tokyo")
+
+	// New year holidays
+	c.Assert(TSE.IsMarketDay(time.Date(2020, 1, 3, 10, 0, 0, 0, Tokyo)), Equals, false)
+	c.Assert(TSE.IsMarketDay(time.Date(2020, 1, 6, 10, 0, 0, 0, Tokyo)), Equals, true)
+
+	// Golden week, including the substitute holiday
+	c.Assert(TSE.IsMarketDay(time.Date(2020, 5, 6, 10, 0, 0, 0, Tokyo)), Equals, false)
+
+	// Marine day moved for the Olympics in 2021
+	c.Assert(TSE.IsMarketDay(time.Date(2021, 7, 22, 10, 0, 0, 0, Tokyo)), Equals, false)
+	c.Assert(TSE.IsMarketDay(time.Date(2021, 7, 19, 10, 0, 0, 0, Tokyo)), Equals, true)
+
+	c.Assert(TSE.IsMarketOpen(time.Date(2021, 7, 19, 14, 0, 0, 0, Tokyo)), Equals, true)
+	c.Assert(TSE.IsMarketOpen(time.Date(2021, 7, 19, 15, 0, 0, 0, Tokyo)), Equals, false)
+}
+
+func (s *CalendarTestSuite) TestMarketClose(c *C) {
+	// day after Thanksgiving 2019 (early close)
+	blackFriday := time.Date(2019, 11, 29, 10, 0, 0, 0, NY)
+	c.Assert(Nasdaq.IsEarlyClose(blackFriday), Equals, true)
+	c.Assert(Nasdaq.MarketClose(blackFriday), DeepEquals, time.Date(2019, 11, 29, 13, 0, 0, 0, NY))
+	c.Assert(Nasdaq.EpochMarketClose(blackFriday.Unix()), DeepEquals, time.Date(2019, 11, 29, 13, 0, 0, 0, NY))
+
+	// normal day
+	normal := time.Date(2019, 11, 26, 10, 0, 0, 0, NY)
+	c.Assert(Nasdaq.IsEarlyClose(normal), Equals, false)
+	c.Assert(Nasdaq.MarketClose(normal), DeepEquals, time.Date(2019, 11, 26, 16, 0, 0, 0, NY))
+
+	// Thanksgiving and weekend
+	c.Assert(Nasdaq.MarketClose(time.Date(2019, 11, 28, 10, 0, 0, 0, NY)).IsZero(), Equals, true)
+	c.Assert(Nasdaq.MarketClose(time.Date(2019, 11, 30, 10, 0, 0, 0, NY)).IsZero(), Equals, true)
+}
+
+func (s *CalendarTestSuite) TestMarketOpen(c *C) {
+	// the open of an early close day is not changed
+	c.Assert(Nasdaq.MarketOpen(time.Date(2019, 11, 29, 15, 0, 0, 0, NY)), DeepEquals, time.Date(2019, 11, 29, 9, 30, 0, 0, NY))
+	c.Assert(Nasdaq.MarketOpen(time.Date(2019, 11, 26, 8, 0, 0, 0, NY)), DeepEquals, time.Date(2019, 11, 26, 9, 30, 0, 0, NY))
+	c.Assert(Nasdaq.MarketOpen(time.Date(2019, 11, 28, 10, 0, 0, 0, NY)).IsZero(), Equals, true)
+}
+
+func (s *CalendarTestSuite) TestSessions(c *C) {
+	// around Independence Day 2019, a Thursday
+	day := func(d int) time.Time { return time.Date(2019, 7, d, 0, 0, 0, 0, NY) }
+	sessions := Sessions(Nasdaq, time.Date(2019, 7, 8, 15, 0, 0, 0, NY), 5)
+	c.Assert(sessions, DeepEquals, []time.Time{day(8), day(5), day(3), day(2), day(1)})
+
+	// from a closed day
+	c.Assert(Sessions(Nasdaq, day(7), 2), DeepEquals, []time.Time{day(5), day(3)})
+	c.Assert(Sessions(Nasdaq, day(7), 0), HasLen, 0)
+
+	// a calendar without sessions
+	closed := New(`{"timezone": "UTC", "open_time": "00:00:00", "close_time": "00:00:00", "early_close_time": "00:00:00"}`)
+	c.Assert(Sessions(neverOpen{closed}, day(7), 3), HasLen, 0)
+}
+
+// neverOpen is a calendar closed on all days.
+type neverOpen struct{ *Calendar }
+
+func (neverOpen) IsMarketDay(time.Time) bool { return false }
+
+func (s *CalendarTestSuite) TestOverrides(c *C) {
+	dir := c.MkDir()
+
+	yamlFile := filepath.Join(dir, "provider.yaml")
+	err := ioutil.WriteFile(yamlFile, []byte(`
+non_trading_days:
+  - 2019-11-26
+early_closes:
+  - 2019-11-27
+trading_days:
+  - 2019-11-28
+`), 0644)
+	c.Assert(err, IsNil)
+
+	csvFile := filepath.Join(dir, "provider.csv")
+	err = ioutil.WriteFile(csvFile, []byte(`date,state
+2019-11-26,closed
+2019-11-27, early_close
+2019-11-28,open
+`), 0644)
+	c.Assert(err, IsNil)
+
+	for _, file := range []string{yamlFile, csvFile} {
+		o, err := LoadOverrides(file)
+		c.Assert(err, IsNil)
+
+		cal := Nasdaq.WithOverrides(o)
+		c.Assert(cal.IsMarketDay(time.Date(2019, 11, 26, 10, 0, 0, 0, NY)), Equals, false)
+		c.Assert(cal.IsEarlyClose(time.Date(2019, 11, 27, 10, 0, 0, 0, NY)), Equals, true)
+		c.Assert(cal.IsMarketDay(time.Date(2019, 11, 28, 10, 0, 0, 0, NY)), Equals, true)
+		// untouched days are inherited
+		c.Assert(cal.IsEarlyClose(time.Date(2019, 11, 29, 10, 0, 0, 0, NY)), Equals, true)
+	}
+
+	// the built-in calendar is not modified
+	c.Assert(Nasdaq.IsMarketDay(time.Date(2019, 11, 26, 10, 0, 0, 0, NY)), Equals, true)
+	c.Assert(Nasdaq.IsMarketDay(time.Date(2019, 11, 28, 10, 0, 0, 0, NY)), Equals, false)
+}
+
+func (s *CalendarTestSuite) TestOverridesErrors(c *C) {
+	dir := c.MkDir()
+
+	files := map[string]string{
+		"bad_date.yaml":  "non_trading_days:\n  - 2019/11/26\n",
+		"bad_yaml.yml":   "non_trading_days: [",
+		"bad_state.csv":  "2019-11-26,holiday\n",
+		"bad_fields.csv": "2019-11-26\n",
+		"unknown.txt":    "2019-11-26,closed\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0644), IsNil)
+
+		_, err := LoadOverrides(path)
+		c.Assert(err, NotNil, Commentf(name))
+	}
+
+	_, err := LoadOverrides(filepath.Join(dir, "missing.yaml"))
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,300 @@
+package calendar
+
+var LSEJson = `{
+  "timezone": "Europe/London",
+  "open_time": "08:00:00",
+  "close_time": "16:30:00",
+  "early_close_time": "12:30:00",
+  "non_trading_days": [
+    "2000-01-03",
+    "2000-04-21",
+    "2000-04-24",
+    "2000-05-01",
+    "2000-05-29",
+    "2000-08-28",
+    "2000-12-25",
+    "2000-12-26",
+    "2001-01-01",
+    "2001-04-13",
+    "2001-04-16",
+    "2001-05-07",
+    "2001-05-28",
+    "2001-08-27",
+    "2001-12-25",
+    "2001-12-26",
+    "2002-01-01",
+    "2002-03-29",
+    "2002-04-01",
+    "2002-05-06",
+    "2002-06-03",
+    "2002-06-04",
+    "2002-08-26",
+    "2002-12-25",
+    "2002-12-26",
+    "2003-01-01",
+    "2003-04-18",
+    "2003-04-21",
+    "2003-05-05",
+    "2003-05-26",
+    "2003-08-25",
+    "2003-12-25",
+    "2003-12-26",
+    "2004-01-01",
+    "2004-04-09",
+    "2004-04-12",
+    "2004-05-03",
+    "2004-05-31",
+    "2004-08-30",
+    "2004-12-27",
+    "2004-12-28",
+    "2005-01-03",
+    "2005-03-25",
+    "2005-03-28",
+    "2005-05-02",
+    "2005-05-30",
+    "2005-08-29",
+    "2005-12-26",
+    "2005-12-27",
+    "2006-01-02",
+    "2006-04-14",
+    "2006-04-17",
+    "2006-05-01",
+    "2006-05-29",
+    "2006-08-28",
+    "2006-12-25",
+    "2006-12-26",
+    "2007-01-01",
+    "2007-04-06",
+    "2007-04-09",
+    "2007-05-07",
+    "2007-05-28",
+    "2007-08-27",
+    "2007-12-25",
+    "2007-12-26",
+    "2008-01-01",
+    "2008-03-21",
+    "2008-03-24",
+    "2008-05-05",
+    "2008-05-26",
+    "2008-08-25",
+    "2008-12-25",
+    "2008-12-26",
+    "2009-01-01",
+    "2009-04-10",
+    "2009-04-13",
+    "2009-05-04",
+    "2009-05-25",
+    "2009-08-31",
+    "2009-12-25",
+    "2009-12-28",
+    "2010-01-01",
+    "2010-04-02",
+    "2010-04-05",
+    "2010-05-03",
+    "2010-05-31",
+    "2010-08-30",
+    "2010-12-27",
+    "2010-12-28",
+    "2011-01-03",
+    "2011-04-22",
+    "2011-04-25",
+    "2011-04-29",
+    "2011-05-02",
+    "2011-05-30",
+    "2011-08-29",
+    "2011-12-26",
+    "2011-12-27",
+    "2012-01-02",
+    "2012-04-06",
+    "2012-04-09",
+    "2012-05-07",
+    "2012-06-04",
+    "2012-06-05",
+    "2012-08-27",
+    "2012-12-25",
+    "2012-12-26",
+    "2013-01-01",
+    "2013-03-29",
+    "2013-04-01",
+    "2013-05-06",
+    "2013-05-27",
+    "2013-08-26",
+    "2013-12-25",
+    "2013-12-26",
+    "2014-01-01",
+    "2014-04-18",
+    "2014-04-21",
+    "2014-05-05",
+    "2014-05-26",
+    "2014-08-25",
+    "2014-12-25",
+    "2014-12-26",
+    "2015-01-01",
+    "2015-04-03",
+    "2015-04-06",
+    "2015-05-04",
+    "2015-05-25",
+    "2015-08-31",
+    "2015-12-25",
+    "2015-12-28",
+    "2016-01-01",
+    "2016-03-25",
+    "2016-03-28",
+    "2016-05-02",
+    "2016-05-30",
+    "2016-08-29",
+    "2016-12-26",
+    "2016-12-27",
+    "2017-01-02",
+    "2017-04-14",
+    "2017-04-17",
+    "2017-05-01",
+    "2017-05-29",
+    "2017-08-28",
+    "2017-12-25",
+    "2017-12-26",
+    "2018-01-01",
+    "2018-03-30",
+    "2018-04-02",
+    "2018-05-07",
+    "2018-05-28",
+    "2018-08-27",
+    "2018-12-25",
+    "2018-12-26",
+    "2019-01-01",
+    "2019-04-19",
+    "2019-04-22",
+    "2019-05-06",
+    "2019-05-27",
+    "2019-08-26",
+    "2019-12-25",
+    "2019-12-26",
+    "2020-01-01",
+    "2020-04-10",
+    "2020-04-13",
+    "2020-05-08",
+    "2020-05-25",
+    "2020-08-31",
+    "2020-12-25",
+    "2020-12-28",
+    "2021-01-01",
+    "2021-04-02",
+    "2021-04-05",
+    "2021-05-03",
+    "2021-05-31",
+    "2021-08-30",
+    "2021-12-27",
+    "2021-12-28",
+    "2022-01-03",
+    "2022-04-15",
+    "2022-04-18",
+    "2022-05-02",
+    "2022-06-02",
+    "2022-06-03",
+    "2022-08-29",
+    "2022-09-19",
+    "2022-12-26",
+    "2022-12-27",
+    "2023-01-02",
+    "2023-04-07",
+    "2023-04-10",
+    "2023-05-01",
+    "2023-05-08",
+    "2023-05-29",
+    "2023-08-28",
+    "2023-12-25",
+    "2023-12-26",
+    "2024-01-01",
+    "2024-03-29",
+    "2024-04-01",
+    "2024-05-06",
+    "2024-05-27",
+    "2024-08-26",
+    "2024-12-25",
+    "2024-12-26",
+    "2025-01-01",
+    "2025-04-18",
+    "2025-04-21",
+    "2025-05-05",
+    "2025-05-26",
+    "2025-08-25",
+    "2025-12-25",
+    "2025-12-26",
+    "2026-01-01",
+    "2026-04-03",
+    "2026-04-06",
+    "2026-05-04",
+    "2026-05-25",
+    "2026-08-31",
+    "2026-12-25",
+    "2026-12-28",
+    "2027-01-01",
+    "2027-03-26",
+    "2027-03-29",
+    "2027-05-03",
+    "2027-05-31",
+    "2027-08-30",
+    "2027-12-27",
+    "2027-12-28",
+    "2028-01-03",
+    "2028-04-14",
+    "2028-04-17",
+    "2028-05-01",
+    "2028-05-29",
+    "2028-08-28",
+    "2028-12-25",
+    "2028-12-26",
+    "2029-01-01",
+    "2029-03-30",
+    "2029-04-02",
+    "2029-05-07",
+    "2029-05-28",
+    "2029-08-27",
+    "2029-12-25",
+    "2029-12-26"
+  ],
+  "early_closes": [
+    "2001-12-24",
+    "2001-12-31",
+    "2002-12-24",
+    "2002-12-31",
+    "2003-12-24",
+    "2003-12-31",
+    "2004-12-24",
+    "2004-12-31",
+    "2007-12-24",
+    "2007-12-31",
+    "2008-12-24",
+    "2008-12-31",
+    "2009-12-24",
+    "2009-12-31",
+    "2010-12-24",
+    "2010-12-31",
+    "2012-12-24",
+    "2012-12-31",
+    "2013-12-24",
+    "2013-12-31",
+    "2014-12-24",
+    "2014-12-31",
+    "2015-12-24",
+    "2015-12-31",
+    "2018-12-24",
+    "2018-12-31",
+    "2019-12-24",
+    "2019-12-31",
+    "2020-12-24",
+    "2020-12-31",
+    "2021-12-24",
+    "2021-12-31",
+    "2024-12-24",
+    "2024-12-31",
+    "2025-12-24",
+    "2025-12-31",
+    "2026-12-24",
+    "2026-12-31",
+    "2027-12-24",
+    "2027-12-31",
+    "2029-12-24",
+    "2029-12-31"
+  ]
+}`
@@ -0,0 +1,130 @@
+package calendar
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Overrides are the changes layered on top of a built-in calendar.
+//
+// In YAML:
+//
+//	non_trading_days:
+//	  - 2020-05-01
+//	early_closes:
+//	  - 2020-12-24
+//	trading_days:
+//	  - 2020-11-26
+//
+// In CSV, one "date,state" row per day where state is
+// one of "closed", "early_close" or "open":
+//
+//	date,state
+//	2020-05-01,closed
+//	2020-12-24,early_close
+//	2020-11-26,open
+type Overrides struct {
+	NonTradingDays []string `yaml:"non_trading_days"`
+	EarlyCloses    []string `yaml:"early_closes"`
+	// TradingDays are built-in holidays or early closes
+	// that are regular trading days in the overrides.
+	TradingDays []string `yaml:"trading_days"`
+}
+
+// LoadOverrides reads the overrides from a YAML (.yml, .yaml)
+// or CSV (.csv) file.
+func LoadOverrides(path string) (*Overrides, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calendar file %s: %v", path, err)
+	}
+
+	var o *Overrides
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		o = &Overrides{}
+		if err = yaml.Unmarshal(data, o); err != nil {
+			return nil, fmt.Errorf("failed to parse calendar file %s: %v", path, err)
+		}
+	case ".csv":
+		if o, err = parseCSVOverrides(string(data)); err != nil {
+			return nil, fmt.Errorf("failed to parse calendar file %s: %v", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported calendar file %s, it must be .yml, .yaml or .csv", path)
+	}
+
+	for _, days := range [][]string{o.NonTradingDays, o.EarlyCloses, o.TradingDays} {
+		for _, day := range days {
+			if _, err := time.Parse("2006-01-02", day); err != nil {
+				return nil, fmt.Errorf("invalid date %q in calendar file %s, expected YYYY-MM-DD", day, path)
+			}
+		}
+	}
+
+	return o, nil
+}
+
+func parseCSVOverrides(data string) (*Overrides, error) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Overrides{}
+	for i, record := range records {
+		day, state := strings.TrimSpace(record[0]), strings.ToLower(strings.TrimSpace(record[1]))
+		if i == 0 && strings.EqualFold(day, "date") {
+			// header
+			continue
+		}
+		switch state {
+		case "closed":
+			o.NonTradingDays = append(o.NonTradingDays, day)
+		case "early_close":
+			o.EarlyCloses = append(o.EarlyCloses, day)
+		case "open":
+			o.TradingDays = append(o.TradingDays, day)
+		default:
+			return nil, fmt.Errorf("line %d: invalid state %q, expected closed, early_close or open", i+1, record[1])
+		}
+	}
+
+	return o, nil
+}
+
+// WithOverrides returns a copy of the calendar with the overrides layered
+// on top. The calendar itself is left untouched.
+func (calendar *Calendar) WithOverrides(o *Overrides) *Calendar {
+	cal := *calendar
+	cal.days = make(map[int]MarketState, len(calendar.days))
+	for k, v := range calendar.days {
+		cal.days[k] = v
+	}
+
+	for _, dateString := range o.TradingDays {
+		t, _ := time.Parse("2006-01-02", dateString)
+		delete(cal.days, jd(t))
+	}
+	for _, dateString := range o.NonTradingDays {
+		t, _ := time.Parse("2006-01-02", dateString)
+		cal.days[jd(t)] = Closed
+	}
+	for _, dateString := range o.EarlyCloses {
+		t, _ := time.Parse("2006-01-02", dateString)
+		cal.days[jd(t)] = EarlyClose
+	}
+
+	return &cal
+}
@@ -0,0 +1,490 @@
+package calendar
+
+var TSEJson = `{
+  "timezone": "Asia/Tokyo",
+  "open_time": "09:00:00",
+  "close_time": "15:00:00",
+  "early_close_time": "15:00:00",
+  "non_trading_days": [
+    "2000-01-03",
+    "2000-01-10",
+    "2000-02-11",
+    "2000-03-20",
+    "2000-05-03",
+    "2000-05-04",
+    "2000-05-05",
+    "2000-07-20",
+    "2000-09-15",
+    "2000-10-09",
+    "2000-11-03",
+    "2000-11-23",
+    "2001-01-01",
+    "2001-01-02",
+    "2001-01-03",
+    "2001-01-08",
+    "2001-02-12",
+    "2001-03-20",
+    "2001-04-30",
+    "2001-05-03",
+    "2001-05-04",
+    "2001-07-20",
+    "2001-09-24",
+    "2001-10-08",
+    "2001-11-23",
+    "2001-12-24",
+    "2001-12-31",
+    "2002-01-01",
+    "2002-01-02",
+    "2002-01-03",
+    "2002-01-14",
+    "2002-02-11",
+    "2002-03-21",
+    "2002-04-29",
+    "2002-05-03",
+    "2002-05-06",
+    "2002-09-16",
+    "2002-09-23",
+    "2002-10-14",
+    "2002-11-04",
+    "2002-12-23",
+    "2002-12-31",
+    "2003-01-01",
+    "2003-01-02",
+    "2003-01-03",
+    "2003-01-13",
+    "2003-02-11",
+    "2003-03-21",
+    "2003-04-29",
+    "2003-05-05",
+    "2003-07-21",
+    "2003-09-15",
+    "2003-09-23",
+    "2003-10-13",
+    "2003-11-03",
+    "2003-11-24",
+    "2003-12-23",
+    "2003-12-31",
+    "2004-01-01",
+    "2004-01-02",
+    "2004-01-12",
+    "2004-02-11",
+    "2004-04-29",
+    "2004-05-03",
+    "2004-05-04",
+    "2004-05-05",
+    "2004-07-19",
+    "2004-09-20",
+    "2004-09-23",
+    "2004-10-11",
+    "2004-11-03",
+    "2004-11-23",
+    "2004-12-23",
+    "2004-12-31",
+    "2005-01-03",
+    "2005-01-10",
+    "2005-02-11",
+    "2005-03-21",
+    "2005-04-29",
+    "2005-05-03",
+    "2005-05-04",
+    "2005-05-05",
+    "2005-07-18",
+    "2005-09-19",
+    "2005-09-23",
+    "2005-10-10",
+    "2005-11-03",
+    "2005-11-23",
+    "2005-12-23",
+    "2006-01-02",
+    "2006-01-03",
+    "2006-01-09",
+    "2006-03-21",
+    "2006-05-03",
+    "2006-05-04",
+    "2006-05-05",
+    "2006-07-17",
+    "2006-09-18",
+    "2006-10-09",
+    "2006-11-03",
+    "2006-11-23",
+    "2007-01-01",
+    "2007-01-02",
+    "2007-01-03",
+    "2007-01-08",
+    "2007-02-12",
+    "2007-03-21",
+    "2007-04-30",
+    "2007-05-03",
+    "2007-05-04",
+    "2007-07-16",
+    "2007-09-17",
+    "2007-09-24",
+    "2007-10-08",
+    "2007-11-23",
+    "2007-12-24",
+    "2007-12-31",
+    "2008-01-01",
+    "2008-01-02",
+    "2008-01-03",
+    "2008-01-14",
+    "2008-02-11",
+    "2008-03-20",
+    "2008-04-29",
+    "2008-05-05",
+    "2008-05-06",
+    "2008-07-21",
+    "2008-09-15",
+    "2008-09-23",
+    "2008-10-13",
+    "2008-11-03",
+    "2008-11-24",
+    "2008-12-23",
+    "2008-12-31",
+    "2009-01-01",
+    "2009-01-02",
+    "2009-01-12",
+    "2009-02-11",
+    "2009-03-20",
+    "2009-04-29",
+    "2009-05-04",
+    "2009-05-05",
+    "2009-05-06",
+    "2009-07-20",
+    "2009-09-21",
+    "2009-09-22",
+    "2009-09-23",
+    "2009-10-12",
+    "2009-11-03",
+    "2009-11-23",
+    "2009-12-23",
+    "2009-12-31",
+    "2010-01-01",
+    "2010-01-11",
+    "2010-02-11",
+    "2010-03-22",
+    "2010-04-29",
+    "2010-05-03",
+    "2010-05-04",
+    "2010-05-05",
+    "2010-07-19",
+    "2010-09-20",
+    "2010-09-23",
+    "2010-10-11",
+    "2010-11-03",
+    "2010-11-23",
+    "2010-12-23",
+    "2010-12-31",
+    "2011-01-03",
+    "2011-01-10",
+    "2011-02-11",
+    "2011-03-21",
+    "2011-04-29",
+    "2011-05-03",
+    "2011-05-04",
+    "2011-05-05",
+    "2011-07-18",
+    "2011-09-19",
+    "2011-09-23",
+    "2011-10-10",
+    "2011-11-03",
+    "2011-11-23",
+    "2011-12-23",
+    "2012-01-02",
+    "2012-01-03",
+    "2012-01-09",
+    "2012-03-20",
+    "2012-04-30",
+    "2012-05-03",
+    "2012-05-04",
+    "2012-07-16",
+    "2012-09-17",
+    "2012-10-08",
+    "2012-11-23",
+    "2012-12-24",
+    "2012-12-31",
+    "2013-01-01",
+    "2013-01-02",
+    "2013-01-03",
+    "2013-01-14",
+    "2013-02-11",
+    "2013-03-20",
+    "2013-04-29",
+    "2013-05-03",
+    "2013-05-06",
+    "2013-07-15",
+    "2013-09-16",
+    "2013-09-23",
+    "2013-10-14",
+    "2013-11-04",
+    "2013-12-23",
+    "2013-12-31",
+    "2014-01-01",
+    "2014-01-02",
+    "2014-01-03",
+    "2014-01-13",
+    "2014-02-11",
+    "2014-03-21",
+    "2014-04-29",
+    "2014-05-05",
+    "2014-05-06",
+    "2014-07-21",
+    "2014-09-15",
+    "2014-09-23",
+    "2014-10-13",
+    "2014-11-03",
+    "2014-11-24",
+    "2014-12-23",
+    "2014-12-31",
+    "2015-01-01",
+    "2015-01-02",
+    "2015-01-12",
+    "2015-02-11",
+    "2015-04-29",
+    "2015-05-04",
+    "2015-05-05",
+    "2015-05-06",
+    "2015-07-20",
+    "2015-09-21",
+    "2015-09-22",
+    "2015-09-23",
+    "2015-10-12",
+    "2015-11-03",
+    "2015-11-23",
+    "2015-12-23",
+    "2015-12-31",
+    "2016-01-01",
+    "2016-01-11",
+    "2016-02-11",
+    "2016-03-21",
+    "2016-04-29",
+    "2016-05-03",
+    "2016-05-04",
+    "2016-05-05",
+    "2016-07-18",
+    "2016-08-11",
+    "2016-09-19",
+    "2016-09-22",
+    "2016-10-10",
+    "2016-11-03",
+    "2016-11-23",
+    "2016-12-23",
+    "2017-01-02",
+    "2017-01-03",
+    "2017-01-09",
+    "2017-03-20",
+    "2017-05-03",
+    "2017-05-04",
+    "2017-05-05",
+    "2017-07-17",
+    "2017-08-11",
+    "2017-09-18",
+    "2017-10-09",
+    "2017-11-03",
+    "2017-11-23",
+    "2018-01-01",
+    "2018-01-02",
+    "2018-01-03",
+    "2018-01-08",
+    "2018-02-12",
+    "2018-03-21",
+    "2018-04-30",
+    "2018-05-03",
+    "2018-05-04",
+    "2018-07-16",
+    "2018-09-17",
+    "2018-09-24",
+    "2018-10-08",
+    "2018-11-23",
+    "2018-12-24",
+    "2018-12-31",
+    "2019-01-01",
+    "2019-01-02",
+    "2019-01-03",
+    "2019-01-14",
+    "2019-02-11",
+    "2019-03-21",
+    "2019-04-29",
+    "2019-04-30",
+    "2019-05-01",
+    "2019-05-02",
+    "2019-05-03",
+    "2019-05-06",
+    "2019-07-15",
+    "2019-08-12",
+    "2019-09-16",
+    "2019-09-23",
+    "2019-10-14",
+    "2019-10-22",
+    "2019-11-04",
+    "2019-12-31",
+    "2020-01-01",
+    "2020-01-02",
+    "2020-01-03",
+    "2020-01-13",
+    "2020-02-11",
+    "2020-02-24",
+    "2020-03-20",
+    "2020-04-29",
+    "2020-05-04",
+    "2020-05-05",
+    "2020-05-06",
+    "2020-07-23",
+    "2020-07-24",
+    "2020-08-10",
+    "2020-09-21",
+    "2020-09-22",
+    "2020-11-03",
+    "2020-11-23",
+    "2020-12-31",
+    "2021-01-01",
+    "2021-01-11",
+    "2021-02-11",
+    "2021-02-23",
+    "2021-04-29",
+    "2021-05-03",
+    "2021-05-04",
+    "2021-05-05",
+    "2021-07-22",
+    "2021-07-23",
+    "2021-08-09",
+    "2021-09-20",
+    "2021-09-23",
+    "2021-11-03",
+    "2021-11-23",
+    "2021-12-31",
+    "2022-01-03",
+    "2022-01-10",
+    "2022-02-11",
+    "2022-02-23",
+    "2022-03-21",
+    "2022-04-29",
+    "2022-05-03",
+    "2022-05-04",
+    "2022-05-05",
+    "2022-07-18",
+    "2022-08-11",
+    "2022-09-19",
+    "2022-09-23",
+    "2022-10-10",
+    "2022-11-03",
+    "2022-11-23",
+    "2023-01-02",
+    "2023-01-03",
+    "2023-01-09",
+    "2023-02-23",
+    "2023-03-21",
+    "2023-05-03",
+    "2023-05-04",
+    "2023-05-05",
+    "2023-07-17",
+    "2023-08-11",
+    "2023-09-18",
+    "2023-10-09",
+    "2023-11-03",
+    "2023-11-23",
+    "2024-01-01",
+    "2024-01-02",
+    "2024-01-03",
+    "2024-01-08",
+    "2024-02-12",
+    "2024-02-23",
+    "2024-03-20",
+    "2024-04-29",
+    "2024-05-03",
+    "2024-05-06",
+    "2024-07-15",
+    "2024-08-12",
+    "2024-09-16",
+    "2024-09-23",
+    "2024-10-14",
+    "2024-11-04",
+    "2024-12-31",
+    "2025-01-01",
+    "2025-01-02",
+    "2025-01-03",
+    "2025-01-13",
+    "2025-02-11",
+    "2025-02-24",
+    "2025-03-20",
+    "2025-04-29",
+    "2025-05-05",
+    "2025-05-06",
+    "2025-07-21",
+    "2025-08-11",
+    "2025-09-15",
+    "2025-09-23",
+    "2025-10-13",
+    "2025-11-03",
+    "2025-11-24",
+    "2025-12-31",
+    "2026-01-01",
+    "2026-01-02",
+    "2026-01-12",
+    "2026-02-11",
+    "2026-02-23",
+    "2026-03-20",
+    "2026-04-29",
+    "2026-05-04",
+    "2026-05-05",
+    "2026-05-06",
+    "2026-07-20",
+    "2026-08-11",
+    "2026-09-21",
+    "2026-09-22",
+    "2026-09-23",
+    "2026-10-12",
+    "2026-11-03",
+    "2026-11-23",
+    "2026-12-31",
+    "2027-01-01",
+    "2027-01-11",
+    "2027-02-11",
+    "2027-02-23",
+    "2027-03-22",
+    "2027-04-29",
+    "2027-05-03",
+    "2027-05-04",
+    "2027-05-05",
+    "2027-07-19",
+    "2027-08-11",
+    "2027-09-20",
+    "2027-09-23",
+    "2027-10-11",
+    "2027-11-03",
+    "2027-11-23",
+    "2027-12-31",
+    "2028-01-03",
+    "2028-01-10",
+    "2028-02-11",
+    "2028-02-23",
+    "2028-03-20",
+    "2028-05-03",
+    "2028-05-04",
+    "2028-05-05",
+    "2028-07-17",
+    "2028-08-11",
+    "2028-09-18",
+    "2028-09-22",
+    "2028-10-09",
+    "2028-11-03",
+    "2028-11-23",
+    "2029-01-01",
+    "2029-01-02",
+    "2029-01-03",
+    "2029-01-08",
+    "2029-02-12",
+    "2029-02-23",
+    "2029-03-20",
+    "2029-04-30",
+    "2029-05-03",
+    "2029-05-04",
+    "2029-07-16",
+    "2029-09-17",
+    "2029-09-24",
+    "2029-10-08",
+    "2029-11-23",
+    "2029-12-31"
+  ],
+  "early_closes": [
+  ]
+}`
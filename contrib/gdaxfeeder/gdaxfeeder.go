@@ -11,6 +11,7 @@ import (
 	"github.com/alpacahq/marketstore/v4/executor"
 	"github.com/alpacahq/marketstore/v4/planner"
 	"github.com/alpacahq/marketstore/v4/plugins/bgworker"
+	"github.com/alpacahq/marketstore/v4/plugins/version"
 	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/io"
 	"github.com/alpacahq/marketstore/v4/utils/log"
@@ -230,6 +231,9 @@ func (gd *GdaxFetcher) Run() {
 	}
 }
 
+// MarketstoreVersion stamps the plugin with the version it is built against.
+var MarketstoreVersion = version.Version
+
 func main() {
 
 	client := gdax.NewClient("", "", "")
@@ -12,6 +12,7 @@ import (
 	"github.com/alpacahq/marketstore/v4/contrib/iex/api"
 	"github.com/alpacahq/marketstore/v4/executor"
 	"github.com/alpacahq/marketstore/v4/plugins/bgworker"
+	"github.com/alpacahq/marketstore/v4/plugins/version"
 	"github.com/alpacahq/marketstore/v4/utils/io"
 	"github.com/alpacahq/marketstore/v4/utils/log"
 )
@@ -398,6 +399,9 @@ func onceDaily(lastDailyRunDate *int, runHour int, runMinute int) bool {
 	}
 }
 
+// MarketstoreVersion stamps the plugin with the version it is built against.
+var MarketstoreVersion = version.Version
+
 func main() {
 	api.SetToken(os.Getenv("IEXTOKEN"))
 	resp, err := api.GetBars([]string{"AAPL", "AMD", "X", "NVDA", "AMPY", "IBM", "GOOG"}, oneDay, nil, 5)
@@ -19,14 +19,36 @@
 // 	        - 1H
 // 	        - 1D
 //
-// destinations are downsample target time windows.  Optionally, if filter
-// is set to "nasdaq", it filters the scan data by NASDAQ market hours.
+// destinations are downsample target time windows.  Any timeframe such as
+// 7Min, 4H or 1W can be used, as long as it is coarser than the source.
+// Windows that do not divide a day evenly are counted from midnight.
+// Optionally, if filter is set to a market calendar such as "nasdaq", it
+// filters the scan data of the daily and coarser windows by the market hours
+// of the calendar, early closes included, and aligns them to its sessions in
+// its timezone regardless of the system timezone.  "none" (default) aggregates the whole day, e.g. for
+// markets trading around the clock.  week_start sets the day weekly windows begin on
+// (monday by default), and source, if set to the source timeframe such as
+// 1Min, validates the destinations against it on load.
+//
+// If source_type is "trades", the source is a trade bucket such as
+// */1Sec/TRADE, with Price and Size columns, aggregated directly to bars
+// with Volume (and VWAP and the trade count in TickCnt if vwap is true).
+// The destinations may then be as fine as the timeframe of the source, and
+// are written to the attribute_group, OHLCV by default.  attribute_group
+// sets that of the destinations of any source, its own by default.
+//
+// If vwap is true and the source has a Volume column, the volume weighted
+// average price of each bar is written to an extra VWAP column, as well as
+// the sum of the TickCnt column if the source has one.  vwap_zero_volume
+// decides the VWAP of the bars without volume, "nan" (default) or "carry"
+// to carry the previous VWAP forward.
 package aggtrigger
 
 import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -46,15 +68,36 @@ import (
 type AggTriggerConfig struct {
 	Destinations []string `json:"destinations"`
 	Filter       string   `json:"filter"`
+	WeekStart    string   `json:"week_start"`
+	Source       string   `json:"source"`
+	VWAP         bool     `json:"vwap"`
+	ZeroVolume   string   `json:"vwap_zero_volume"`
+	SourceType   string   `json:"source_type"`
+	AttrGroup    string   `json:"attribute_group"`
 }
 
 // OnDiskAggTrigger is the main trigger.
 type OnDiskAggTrigger struct {
 	config       map[string]interface{}
 	destinations timeframes
-	// filter by market hours if this is "nasdaq"
-	filter   string
-	aggCache *sync.Map
+	// filter by the market hours of the calendar, if any
+	filter    calendar.MarketCalendar
+	weekStart time.Weekday
+	options   aggOptions
+	// the source is trades rather than bars
+	trades bool
+	// attribute group of the destinations, that of the source if empty
+	attrGroup string
+	aggCache  *sync.Map
+}
+
+// aggOptions are the optional columns computed on aggregation.
+type aggOptions struct {
+	vwap bool
+	// carry the previous VWAP forward on zero-volume bars instead of NaN
+	vwapCarry bool
+	// loc is the timezone of the windows, the system timezone if nil
+	loc *time.Location
 }
 
 var (
@@ -80,18 +123,65 @@ func NewTrigger(conf map[string]interface{}) (trigger.Trigger, error) {
 
 	log.Info("%d destination(s) configured\n", len(config.Destinations))
 
-	filter := config.Filter
-	if filter != "" && filter != "nasdaq" {
-		log.Error("filter value \"%s\" is not recognized\n", filter)
-		filter = ""
+	var filter calendar.MarketCalendar
+	if config.Filter != "" && !strings.EqualFold(config.Filter, "none") {
+		var err error
+		if filter, err = calendar.Get(config.Filter); err != nil {
+			return nil, fmt.Errorf("invalid filter: %v", err)
+		}
+	}
+
+	weekStart := time.Monday
+	if config.WeekStart != "" {
+		var ok bool
+		if weekStart, ok = weekdays[strings.ToLower(config.WeekStart)]; !ok {
+			return nil, fmt.Errorf("invalid week_start: %s", config.WeekStart)
+		}
+	}
+
+	options := aggOptions{vwap: config.VWAP}
+	switch strings.ToLower(config.ZeroVolume) {
+	case "", "nan":
+	case "carry":
+		options.vwapCarry = true
+	default:
+		return nil, fmt.Errorf("invalid vwap_zero_volume: %s", config.ZeroVolume)
+	}
+
+	var trades bool
+	attrGroup := config.AttrGroup
+	switch strings.ToLower(config.SourceType) {
+	case "", "bars":
+	case "trades":
+		trades = true
+		if attrGroup == "" {
+			attrGroup = "OHLCV"
+		}
+	default:
+		return nil, fmt.Errorf("invalid source_type: %s", config.SourceType)
+	}
+
+	var source *utils.Timeframe
+	if config.Source != "" {
+		if source = utils.TimeframeFromString(config.Source); source == nil {
+			return nil, fmt.Errorf("invalid source: %s", config.Source)
+		}
 	}
 
 	var tfs timeframes
 
 	for _, dest := range config.Destinations {
 		tf := utils.TimeframeFromString(dest)
-		if tf == nil {
-			log.Fatal("invalid destination: %s", dest)
+		if tf == nil || utils.CandleDurationFromString(dest) == nil {
+			return nil, fmt.Errorf("invalid destination: %s", dest)
+		}
+		if source != nil {
+			if tf.Duration < source.Duration || tf.Duration == source.Duration && !trades {
+				return nil, fmt.Errorf("destination %s is not coarser than the source %s", dest, source.String)
+			}
+			if tf.Duration%source.Duration != 0 {
+				return nil, fmt.Errorf("destination %s is not a multiple of the source %s", dest, source.String)
+			}
 		}
 		tfs = append(tfs, *tf)
 	}
@@ -100,9 +190,29 @@ func NewTrigger(conf map[string]interface{}) (trigger.Trigger, error) {
 		config:       conf,
 		destinations: tfs,
 		filter:       filter,
+		weekStart:    weekStart,
+		options:      options,
+		trades:       trades,
+		attrGroup:    attrGroup,
 		aggCache:     &sync.Map{},
 	}, nil
 }
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// window returns the candle window of the timeframe.
+func (s *OnDiskAggTrigger) window(tf string) *utils.CandleDuration {
+	return utils.CandleDurationFromString(tf).SetWeekStart(s.weekStart)
+}
+
 func minInt64(values []int64) int64 {
 	min := values[0]
 	for _, v := range values[1:] {
@@ -125,27 +235,74 @@ func maxInt64(values []int64) int64 {
 
 // Fire implements trigger interface.
 func (s *OnDiskAggTrigger) Fire(keyPath string, records []trigger.Record) {
+	if err := s.FireWithError(keyPath, records); err != nil {
+		log.Error("%v\n", err)
+	}
+}
+
+// FireWithError implements trigger.ErrorTrigger, returning the error of the
+// first window that failed to aggregate, so that the records are fired again.
+func (s *OnDiskAggTrigger) FireWithError(keyPath string, records []trigger.Record) error {
 	elements := strings.Split(keyPath, "/")
 	tf := utils.NewTimeframe(elements[1])
 	fileName := elements[len(elements)-1]
 	year, _ := strconv.Atoi(strings.Replace(fileName, ".bin", "", 1))
 	tbk := io.NewTimeBucketKey(strings.Join(elements[:len(elements)-1], "/"))
 
-	head := io.IndexToTime(
-		records[0].Index(),
-		tf.Duration,
-		int16(year))
+	// query the upper bound since it will contain the most candles
+	window := s.window(s.destinations.UpperBound().String)
+
+	// the records may be in any order, e.g. a day backfilled late, and only
+	// the destination bars of the upper bound windows they fall in are
+	// recomputed, each from the full source of its window
+	for _, span := range windowSpans(records, window, tf.Duration, int16(year)) {
+		if err := s.fireSpan(tbk, tf, int16(year), window, elements, span); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	tail := io.IndexToTime(
-		records[len(records)-1].Index(),
-		tf.Duration,
-		int16(year))
+// recordSpan is the records written in an upper bound window, and the time
+// of the first and last of them.
+type recordSpan struct {
+	records    []trigger.Record
+	head, tail time.Time
+}
 
-	// query the upper bound since it will contain the most candles
-	window := utils.CandleDurationFromString(s.destinations.UpperBound().String)
+// windowSpans groups the records by the window they fall in, in time order.
+func windowSpans(records []trigger.Record, window *utils.CandleDuration,
+	tf time.Duration, year int16) []recordSpan {
+	sorted := make([]trigger.Record, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Index() < sorted[j].Index() })
+
+	var spans []recordSpan
+	for _, record := range sorted {
+		t := io.IndexToTime(record.Index(), tf, year)
+		if n := len(spans); n > 0 && window.IsWithin(t, window.Truncate(spans[n-1].head)) {
+			spans[n-1].records = append(spans[n-1].records, record)
+			spans[n-1].tail = t
+			continue
+		}
+		spans = append(spans, recordSpan{records: []trigger.Record{record}, head: t, tail: t})
+	}
+	return spans
+}
+
+func (s *OnDiskAggTrigger) fireSpan(
+	tbk *io.TimeBucketKey,
+	tf *utils.Timeframe,
+	year int16,
+	window *utils.CandleDuration,
+	elements []string,
+	span recordSpan) error {
+
+	head, tail := span.head, span.tail
 
-	// check if we have a valid cache, if not, re-query
-	if v, ok := s.aggCache.Load(tbk.String()); ok {
+	// check if we have a valid cache, if not, re-query.  The records of
+	// the trades are not cached, as they are variable length
+	if v, ok := s.aggCache.Load(tbk.String()); ok && !s.trades {
 		c := v.(*cachedAgg)
 
 		if !c.Valid(tail, head) {
@@ -157,48 +314,59 @@ func (s *OnDiskAggTrigger) Fire(keyPath string, records []trigger.Record) {
 		cs := trigger.RecordsToColumnSeries(
 			*tbk, c.cs.GetDataShapes(),
 			c.cs.GetCandleAttributes(),
-			tf.Duration, int16(year), records)
+			tf.Duration, year, span.records)
 
-		cs = io.ColumnSeriesUnion(cs, &c.cs)
+		// the records written override the cached ones at the same time
+		cs = io.ColumnSeriesUnion(&c.cs, cs)
 
-		s.write(tbk, cs, tail, head, elements)
-
-		return
+		return s.write(tbk, cs, tail, head, elements)
 	}
 
 Query:
 	csm, err := s.query(tbk, window, head, tail)
-	if err != nil || csm == nil {
-		log.Error("query error for %v (%v)\n", tbk.String(), err)
-		return
+	if err != nil {
+		return fmt.Errorf("query error for %v (%v)", tbk.String(), err)
 	}
 
 	cs := (*csm)[*tbk]
 
-	if cs != nil {
-		s.write(tbk, cs, tail, head, elements)
+	if cs != nil && s.trades {
+		if cs, err = tradeBars(cs); err != nil {
+			return fmt.Errorf("failed to aggregate the trades of %v (%v)", tbk.String(), err)
+		}
 	}
 
-	return
+	if cs != nil {
+		return s.write(tbk, cs, tail, head, elements)
+	}
+	return nil
 }
 
 func (s *OnDiskAggTrigger) write(
 	tbk *io.TimeBucketKey,
 	cs *io.ColumnSeries,
 	tail, head time.Time,
-	elements []string) {
+	elements []string) error {
+
+	tf := utils.NewTimeframe(elements[1])
 
 	for _, dest := range s.destinations {
-		aggTbk := io.NewTimeBucketKeyFromString(elements[0] + "/" + dest.String + "/" + elements[2])
+		if tf != nil && (dest.Duration < tf.Duration || dest.Duration == tf.Duration && !s.trades) {
+			log.Error("destination %s is not coarser than %v, skipping\n", dest.String, tbk.String())
+			continue
+		}
+
+		attrGroup := elements[2]
+		if s.attrGroup != "" {
+			attrGroup = s.attrGroup
+		}
+		aggTbk := io.NewTimeBucketKeyFromString(elements[0] + "/" + dest.String + "/" + attrGroup)
 
 		if err := s.writeAggregates(aggTbk, tbk, *cs, dest, head, tail); err != nil {
-			log.Error(
-				"failed to write %v aggregates (%v)\n",
-				tbk.String(),
-				err)
-			return
+			return fmt.Errorf("failed to write %v aggregates (%v)", tbk.String(), err)
 		}
 	}
+	return nil
 }
 
 type cachedAgg struct {
@@ -206,8 +374,10 @@ type cachedAgg struct {
 	tail, head time.Time
 }
 
+// Valid returns true if the cached source covers the window of the records
+// written from head to tail.
 func (c *cachedAgg) Valid(tail, head time.Time) bool {
-	return tail.Unix() >= c.tail.Unix() && head.Unix() <= c.head.Unix()
+	return head.Unix() >= c.tail.Unix() && tail.Unix() <= c.head.Unix()
 }
 
 func (s *OnDiskAggTrigger) writeAggregates(
@@ -218,9 +388,23 @@ func (s *OnDiskAggTrigger) writeAggregates(
 
 	csm := io.NewColumnSeriesMap()
 
-	window := utils.CandleDurationFromString(dest.String)
+	window := s.window(dest.String)
+
+	// the daily and coarser windows are filtered by the market hours, and
+	// bucketed by the session days in the calendar's timezone, whatever the
+	// system timezone is
+	applyingFilter := s.filter != nil && window.Duration() >= utils.Day
+	options := s.options
+	if applyingFilter {
+		options.loc = s.filter.Tz()
+		head = head.In(options.loc)
+		tail = tail.In(options.loc)
+	}
+
+	// the end is exclusive, so that the last second of the window, where
+	// the trades of the second can be, is aggregated
 	start := window.Truncate(head).Unix()
-	end := window.Ceil(tail).Add(-time.Second).Unix()
+	end := window.Ceil(tail).Unix()
 
 	slc, err := io.SliceColumnSeriesByEpoch(cs, &start, &end)
 	if err != nil {
@@ -231,23 +415,12 @@ func (s *OnDiskAggTrigger) writeAggregates(
 		return nil
 	}
 
-	// decide whether to apply market-hour filter
-	applyingFilter := false
-	if s.filter == "nasdaq" && window.Duration() >= utils.Day {
-		calendarTz := calendar.Nasdaq.Tz()
-		if utils.InstanceConfig.Timezone.String() != calendarTz.String() {
-			log.Warn("misconfiguration... system must be configure in %s\n", calendarTz)
-		} else {
-			applyingFilter = true
-		}
-	}
-
 	// store when writing for upper bound
-	if dest.Duration == s.destinations.UpperBound().Duration {
+	if dest.Duration == s.destinations.UpperBound().Duration && !s.trades {
 		defer func() {
 			t := window.Truncate(tail)
 			tEpoch := t.Unix()
-			h := time.Unix(end, 0)
+			h := time.Unix(end-1, 0)
 
 			cacheSlc, _ := io.SliceColumnSeriesByEpoch(cs, &tEpoch, &end)
 
@@ -261,23 +434,26 @@ func (s *OnDiskAggTrigger) writeAggregates(
 
 	// apply the filter
 	if applyingFilter {
-		tqSlc := slc.ApplyTimeQual(calendar.Nasdaq.EpochIsMarketOpen)
+		tqSlc := slc.ApplyTimeQual(s.filter.EpochIsMarketOpen)
 
 		// normally this will always be true, but when there are random bars
 		// on the weekend, it won't be, so checking to avoid panic
 		if len(tqSlc.GetEpoch()) > 0 {
-			csm.AddColumnSeries(*aggTbk, aggregate(tqSlc, aggTbk))
+			csm.AddColumnSeries(*aggTbk, aggregateWindow(tqSlc, window, options))
 		}
 	} else {
-		csm.AddColumnSeries(*aggTbk, aggregate(&slc, aggTbk))
+		csm.AddColumnSeries(*aggTbk, aggregateWindow(&slc, window, options))
 	}
 
 	return executor.WriteCSM(csm, false)
 }
 
 func aggregate(cs *io.ColumnSeries, tbk *io.TimeBucketKey) *io.ColumnSeries {
-	timeWindow := utils.CandleDurationFromString(tbk.GetItemInCategory("Timeframe"))
+	return aggregateWindow(cs, utils.CandleDurationFromString(tbk.GetItemInCategory("Timeframe")), aggOptions{})
+}
 
+// aggregateWindow downsamples the time ordered cs into timeWindow candles.
+func aggregateWindow(cs *io.ColumnSeries, timeWindow *utils.CandleDuration, options aggOptions) *io.ColumnSeries {
 	params := []accumParam{
 		accumParam{"Open", "first", "Open"},
 		accumParam{"High", "max", "High"},
@@ -287,9 +463,28 @@ func aggregate(cs *io.ColumnSeries, tbk *io.TimeBucketKey) *io.ColumnSeries {
 	if cs.Exists("Volume") {
 		params = append(params, accumParam{"Volume", "sum", "Volume"})
 	}
+	var vwap *vwapAccumulator
+	if options.vwap && cs.Exists("Volume") {
+		vwap = newVWAPAccumulator(cs, options.vwapCarry)
+		if cs.Exists("TickCnt") {
+			params = append(params, accumParam{"TickCnt", "sum", "TickCnt"})
+		}
+	}
 	accumGroup := newAccumGroup(cs, params)
 
 	ts, _ := cs.GetTime()
+	label := func(t time.Time) int64 { return t.Unix() }
+	if options.loc != nil {
+		for i := range ts {
+			ts[i] = ts[i].In(options.loc)
+		}
+		// the windows are labeled by their dates in the system timezone,
+		// which the daily buckets are indexed by
+		label = func(t time.Time) int64 {
+			yy, mm, dd := t.Date()
+			return time.Date(yy, mm, dd, 0, 0, 0, 0, utils.InstanceConfig.Timezone).Unix()
+		}
+	}
 	outEpoch := make([]int64, 0)
 
 	groupKey := timeWindow.Truncate(ts[0])
@@ -299,20 +494,29 @@ func aggregate(cs *io.ColumnSeries, tbk *io.TimeBucketKey) *io.ColumnSeries {
 	for i, t := range ts {
 		if !timeWindow.IsWithin(t, groupKey) {
 			// Emit new row and re-init aggState
-			outEpoch = append(outEpoch, groupKey.Unix())
+			outEpoch = append(outEpoch, label(groupKey))
 			accumGroup.apply(groupStart, i)
+			if vwap != nil {
+				vwap.apply(groupStart, i)
+			}
 			groupKey = timeWindow.Truncate(t)
 			groupStart = i
 		}
 	}
 	// accumulate any remaining values if not yet
-	outEpoch = append(outEpoch, groupKey.Unix())
+	outEpoch = append(outEpoch, label(groupKey))
 	accumGroup.apply(groupStart, len(ts))
+	if vwap != nil {
+		vwap.apply(groupStart, len(ts))
+	}
 
 	// finalize output
 	outCs := io.NewColumnSeries()
 	outCs.AddColumn("Epoch", outEpoch)
 	accumGroup.addColumns(outCs)
+	if vwap != nil {
+		vwap.addColumn(outCs)
+	}
 	return outCs
 }
 
@@ -2,6 +2,8 @@ package aggtrigger
 
 import (
 	"encoding/json"
+	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/alpacahq/marketstore/v4/plugins/trigger"
 
+	"github.com/alpacahq/marketstore/v4/contrib/calendar"
 	"github.com/alpacahq/marketstore/v4/executor"
 	"github.com/alpacahq/marketstore/v4/planner"
 	"github.com/alpacahq/marketstore/v4/utils"
@@ -29,14 +32,22 @@ func getConfig(data string) (ret map[string]interface{}) {
 
 func (t *TestSuite) TestNew(c *C) {
 	var config = getConfig(`{
-        "destinations": ["5Min", "1D"],
-        "filter": "something"
+        "destinations": ["5Min", "1D"]
         }`)
 	var ret, err = NewTrigger(config)
+	c.Assert(err, IsNil)
 	var trig = ret.(*OnDiskAggTrigger)
 	c.Assert(len(trig.destinations), Equals, 2)
-	c.Assert(trig.filter, Equals, "")
-	c.Assert(err, IsNil)
+	c.Assert(trig.filter, IsNil)
+
+	// unknown filter
+	config = getConfig(`{
+        "destinations": ["5Min", "1D"],
+        "filter": "something"
+        }`)
+	ret, err = NewTrigger(config)
+	c.Assert(ret, IsNil)
+	c.Assert(err, ErrorMatches, "invalid filter: unknown market calendar.*")
 
 	// missing destinations
 	config = getConfig(`{}`)
@@ -45,6 +56,182 @@ func (t *TestSuite) TestNew(c *C) {
 	c.Assert(err, NotNil)
 }
 
+func (t *TestSuite) TestNewFilter(c *C) {
+	for filter, expected := range map[string]calendar.MarketCalendar{
+		"none":   nil,
+		"NONE":   nil,
+		"nasdaq": calendar.Nasdaq,
+		"LSE":    calendar.LSE,
+	} {
+		ret, err := NewTrigger(map[string]interface{}{
+			"destinations": []string{"1D"},
+			"filter":       filter,
+		})
+		c.Assert(err, IsNil)
+		c.Assert(ret.(*OnDiskAggTrigger).filter, Equals, expected)
+	}
+}
+
+func (t *TestSuite) TestNewDestinations(c *C) {
+	var config = getConfig(`{
+        "destinations": ["7Min", "30Min", "4H", "1W"],
+        "source": "1Min",
+        "week_start": "Sunday"
+        }`)
+	ret, err := NewTrigger(config)
+	c.Assert(err, IsNil)
+	trig := ret.(*OnDiskAggTrigger)
+	c.Assert(len(trig.destinations), Equals, 4)
+	c.Assert(trig.weekStart, Equals, time.Sunday)
+	c.Assert(trig.destinations.UpperBound().String, Equals, "1W")
+
+	// destination finer than the source
+	_, err = NewTrigger(getConfig(`{"destinations": ["30Sec"], "source": "1Min"}`))
+	c.Assert(err, NotNil)
+
+	// destination equal to the source
+	_, err = NewTrigger(getConfig(`{"destinations": ["1Min"], "source": "1Min"}`))
+	c.Assert(err, NotNil)
+
+	// destination not a multiple of the source
+	_, err = NewTrigger(getConfig(`{"destinations": ["90Sec"], "source": "1Min"}`))
+	c.Assert(err, NotNil)
+
+	// invalid destination, source and week start
+	_, err = NewTrigger(getConfig(`{"destinations": ["0Min"]}`))
+	c.Assert(err, NotNil)
+	_, err = NewTrigger(getConfig(`{"destinations": ["5Min"], "source": "xyz"}`))
+	c.Assert(err, NotNil)
+	_, err = NewTrigger(getConfig(`{"destinations": ["5Min"], "week_start": "someday"}`))
+	c.Assert(err, NotNil)
+}
+
+func (t *TestSuite) TestAggNonDivisor(c *C) {
+	utils.InstanceConfig.Timezone = time.UTC
+
+	// 1Min bars from 09:25 to 09:41 into 7Min bars counted from midnight,
+	// i.e. [09:20, 09:27), [09:27, 09:34), [09:34, 09:41), [09:41, 09:48)
+	var epoch []int64
+	var open, high, low, close []float32
+	for i := 0; i < 17; i++ {
+		epoch = append(epoch, time.Date(2020, 3, 2, 9, 25+i, 0, 0, time.UTC).Unix())
+		open = append(open, float32(i))
+		high = append(high, float32(i)+0.5)
+		low = append(low, float32(i)-0.5)
+		close = append(close, float32(i)+0.1)
+	}
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	cs.AddColumn("Open", open)
+	cs.AddColumn("High", high)
+	cs.AddColumn("Low", low)
+	cs.AddColumn("Close", close)
+
+	outCs := aggregate(cs, io.NewTimeBucketKey("TEST/7Min/OHLC"))
+	c.Assert(outCs.GetEpoch(), DeepEquals, []int64{
+		time.Date(2020, 3, 2, 9, 20, 0, 0, time.UTC).Unix(),
+		time.Date(2020, 3, 2, 9, 27, 0, 0, time.UTC).Unix(),
+		time.Date(2020, 3, 2, 9, 34, 0, 0, time.UTC).Unix(),
+		time.Date(2020, 3, 2, 9, 41, 0, 0, time.UTC).Unix(),
+	})
+	c.Assert(outCs.GetColumn("Open"), DeepEquals, []float32{0, 2, 9, 16})
+	c.Assert(outCs.GetColumn("Close"), DeepEquals, []float32{1.1, 8.1, 15.1, 16.1})
+	c.Assert(outCs.GetColumn("High"), DeepEquals, []float32{1.5, 8.5, 15.5, 16.5})
+	c.Assert(outCs.GetColumn("Low"), DeepEquals, []float32{-0.5, 1.5, 8.5, 15.5})
+}
+
+func (t *TestSuite) TestAggWeekAcrossYears(c *C) {
+	utils.InstanceConfig.Timezone, _ = time.LoadLocation("America/New_York")
+	tz := utils.InstanceConfig.Timezone
+
+	// daily bars from Thu 2020-12-24 to Tue 2021-01-05
+	epoch := []int64{
+		time.Date(2020, 12, 24, 0, 0, 0, 0, tz).Unix(),
+		time.Date(2020, 12, 28, 0, 0, 0, 0, tz).Unix(),
+		time.Date(2020, 12, 31, 0, 0, 0, 0, tz).Unix(),
+		time.Date(2021, 1, 3, 0, 0, 0, 0, tz).Unix(), // Sunday
+		time.Date(2021, 1, 4, 0, 0, 0, 0, tz).Unix(),
+		time.Date(2021, 1, 5, 0, 0, 0, 0, tz).Unix(),
+	}
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	cs.AddColumn("Open", []float32{1, 2, 3, 4, 5, 6})
+	cs.AddColumn("High", []float32{1, 2, 3, 4, 5, 6})
+	cs.AddColumn("Low", []float32{1, 2, 3, 4, 5, 6})
+	cs.AddColumn("Close", []float32{1, 2, 3, 4, 5, 6})
+
+	// Monday based weeks: the Sunday belongs to the week starting 2020-12-28
+	monday, _ := NewTrigger(getConfig(`{"destinations": ["1W"]}`))
+	outCs := aggregateWindow(cs, monday.(*OnDiskAggTrigger).window("1W"), aggOptions{})
+	c.Assert(outCs.GetEpoch(), DeepEquals, []int64{
+		time.Date(2020, 12, 21, 0, 0, 0, 0, tz).Unix(),
+		time.Date(2020, 12, 28, 0, 0, 0, 0, tz).Unix(),
+		time.Date(2021, 1, 4, 0, 0, 0, 0, tz).Unix(),
+	})
+	c.Assert(outCs.GetColumn("Open"), DeepEquals, []float32{1, 2, 5})
+	c.Assert(outCs.GetColumn("Close"), DeepEquals, []float32{1, 4, 6})
+
+	// Sunday based weeks: the Sunday starts a new week
+	sunday, _ := NewTrigger(getConfig(`{"destinations": ["1W"], "week_start": "sunday"}`))
+	outCs = aggregateWindow(cs, sunday.(*OnDiskAggTrigger).window("1W"), aggOptions{})
+	c.Assert(outCs.GetEpoch(), DeepEquals, []int64{
+		time.Date(2020, 12, 20, 0, 0, 0, 0, tz).Unix(),
+		time.Date(2020, 12, 27, 0, 0, 0, 0, tz).Unix(),
+		time.Date(2021, 1, 3, 0, 0, 0, 0, tz).Unix(),
+	})
+	c.Assert(outCs.GetColumn("Open"), DeepEquals, []float32{1, 2, 4})
+	c.Assert(outCs.GetColumn("Close"), DeepEquals, []float32{1, 3, 6})
+}
+
+func (t *TestSuite) TestAggVWAP(c *C) {
+	utils.InstanceConfig.Timezone = time.UTC
+
+	// 1Sec bars made of single trades, and a bar without volume
+	epoch := []int64{
+		time.Date(2020, 3, 2, 9, 30, 0, 0, time.UTC).Unix(),
+		time.Date(2020, 3, 2, 9, 30, 10, 0, time.UTC).Unix(),
+		time.Date(2020, 3, 2, 9, 30, 50, 0, time.UTC).Unix(),
+		time.Date(2020, 3, 2, 9, 31, 5, 0, time.UTC).Unix(),
+		time.Date(2020, 3, 2, 9, 32, 0, 0, time.UTC).Unix(),
+	}
+	price := []float32{100, 101, 102, 103, 104}
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	cs.AddColumn("Open", price)
+	cs.AddColumn("High", price)
+	cs.AddColumn("Low", price)
+	cs.AddColumn("Close", price)
+	cs.AddColumn("Volume", []int32{100, 300, 600, 50, 0})
+	cs.AddColumn("TickCnt", []int32{1, 1, 1, 1, 0})
+
+	trig, err := NewTrigger(getConfig(`{"destinations": ["1Min"], "vwap": true}`))
+	c.Assert(err, IsNil)
+	outCs := aggregateWindow(cs, utils.CandleDurationFromString("1Min"), trig.(*OnDiskAggTrigger).options)
+
+	// (100*100 + 101*300 + 102*600) / 1000 = 101.5
+	vwap := outCs.GetColumn("VWAP").([]float32)
+	c.Assert(len(vwap), Equals, 3)
+	c.Assert(vwap[0], Equals, float32(101.5))
+	c.Assert(vwap[1], Equals, float32(103))
+	c.Assert(math.IsNaN(float64(vwap[2])), Equals, true)
+	c.Assert(outCs.GetColumn("TickCnt"), DeepEquals, []int32{3, 1, 0})
+	c.Assert(outCs.GetColumn("Volume"), DeepEquals, []int32{1000, 50, 0})
+
+	// carry forward the previous VWAP on zero volume
+	trig, err = NewTrigger(getConfig(`{"destinations": ["1Min"], "vwap": true, "vwap_zero_volume": "carry"}`))
+	c.Assert(err, IsNil)
+	outCs = aggregateWindow(cs, utils.CandleDurationFromString("1Min"), trig.(*OnDiskAggTrigger).options)
+	c.Assert(outCs.GetColumn("VWAP"), DeepEquals, []float32{101.5, 103, 103})
+
+	// no VWAP unless enabled
+	outCs = aggregate(cs, io.NewTimeBucketKey("TEST/1Min/OHLCV"))
+	c.Assert(outCs.Exists("VWAP"), Equals, false)
+	c.Assert(outCs.Exists("TickCnt"), Equals, false)
+
+	_, err = NewTrigger(getConfig(`{"destinations": ["1Min"], "vwap": true, "vwap_zero_volume": "zero"}`))
+	c.Assert(err, NotNil)
+}
+
 func (t *TestSuite) TestAgg(c *C) {
 	epoch := []int64{
 		time.Date(2017, 12, 15, 10, 3, 0, 0, time.UTC).Unix(),
@@ -210,3 +397,302 @@ func (t *TestSuite) TestFire(c *C) {
 	t2 := time.Unix(cs1D.GetEpoch()[1], 0).In(utils.InstanceConfig.Timezone)
 	c.Assert(t2.Equal(time.Date(2017, 12, 15, 0, 0, 0, 0, utils.InstanceConfig.Timezone)), Equals, true)
 }
+
+func (t *TestSuite) TestFireSessions(c *C) {
+	// the system timezone differs from the calendar's, and the daily bars
+	// still follow the sessions in New York across the DST end on 2017-11-05
+	// and the early close on 2017-11-24
+	utils.InstanceConfig.Timezone = time.UTC
+	ny, _ := time.LoadLocation("America/New_York")
+
+	rootDir := filepath.Join(c.MkDir(), "mktsdb")
+	os.MkdirAll(rootDir, 0777)
+	executor.NewInstanceSetup(
+		rootDir,
+		true, true, false, false)
+
+	trig, err := NewTrigger(map[string]interface{}{
+		"filter":       "nasdaq",
+		"destinations": []string{"1D"},
+	})
+	c.Assert(err, IsNil)
+
+	epoch := []int64{
+		time.Date(2017, 11, 3, 9, 29, 0, 0, ny).Unix(),
+		time.Date(2017, 11, 3, 9, 30, 0, 0, ny).Unix(),
+		time.Date(2017, 11, 3, 15, 59, 0, 0, ny).Unix(),
+		// after hours, and past midnight in UTC
+		time.Date(2017, 11, 3, 16, 0, 0, 0, ny).Unix(),
+		time.Date(2017, 11, 3, 20, 30, 0, 0, ny).Unix(),
+		time.Date(2017, 11, 6, 9, 30, 0, 0, ny).Unix(),
+		time.Date(2017, 11, 6, 15, 59, 0, 0, ny).Unix(),
+		time.Date(2017, 11, 24, 9, 30, 0, 0, ny).Unix(),
+		time.Date(2017, 11, 24, 12, 59, 0, 0, ny).Unix(),
+		// after the early close
+		time.Date(2017, 11, 24, 13, 0, 0, 0, ny).Unix(),
+		time.Date(2017, 11, 24, 15, 0, 0, 0, ny).Unix(),
+	}
+	open := []float32{9, 1, 2, 9, 9, 3, 4, 5, 6, 9, 9}
+	high := []float32{10, 1, 2, 10, 10, 3, 4, 5, 6, 10, 10}
+	low := []float32{0, 1, 2, 0, 0, 3, 4, 5, 6, 0, 0}
+	close := []float32{9, 1, 2, 9, 9, 3, 4, 5, 6, 9, 9}
+
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	cs.AddColumn("Open", open)
+	cs.AddColumn("High", high)
+	cs.AddColumn("Low", low)
+	cs.AddColumn("Close", close)
+
+	baseTbk := io.NewTimeBucketKey("TEST/1Min/OHLC")
+	aggTbk := io.NewTimeBucketKey("TEST/1D/OHLC")
+	head := time.Unix(epoch[0], 0).In(time.UTC)
+	tail := time.Unix(epoch[len(epoch)-1], 0).In(time.UTC)
+	err = trig.(*OnDiskAggTrigger).writeAggregates(
+		aggTbk, baseTbk, *cs, *utils.TimeframeFromString("1D"), head, tail)
+	c.Assert(err, IsNil)
+
+	q := planner.NewQuery(executor.ThisInstance.CatalogDir)
+	q.AddTargetKey(aggTbk)
+	q.SetRange(planner.MinTime, planner.MaxTime)
+	parsed, err := q.Parse()
+	c.Assert(err, IsNil)
+	scanner, err := executor.NewReader(parsed)
+	c.Assert(err, IsNil)
+	csm, err := scanner.Read()
+	c.Assert(err, IsNil)
+	cs1D := csm[*aggTbk]
+	c.Assert(cs1D, NotNil)
+
+	// labeled by the session dates
+	c.Assert(cs1D.GetEpoch(), DeepEquals, []int64{
+		time.Date(2017, 11, 3, 0, 0, 0, 0, time.UTC).Unix(),
+		time.Date(2017, 11, 6, 0, 0, 0, 0, time.UTC).Unix(),
+		time.Date(2017, 11, 24, 0, 0, 0, 0, time.UTC).Unix(),
+	})
+	c.Assert(cs1D.GetColumn("Open"), DeepEquals, []float32{1, 3, 5})
+	c.Assert(cs1D.GetColumn("High"), DeepEquals, []float32{2, 4, 6})
+	c.Assert(cs1D.GetColumn("Low"), DeepEquals, []float32{1, 3, 5})
+	c.Assert(cs1D.GetColumn("Close"), DeepEquals, []float32{2, 4, 6})
+}
+
+// writeAndFire writes the 1Min bars to TEST/1Min/OHLC, in the order given,
+// and fires the trigger with their records like the executor does.
+func writeAndFire(c *C, trig trigger.Trigger, epoch []int64, open, high, low, close []float32) {
+	trig.Fire(writeBars(c, epoch, open, high, low, close))
+}
+
+// writeBars writes the 1Min bars to TEST/1Min/OHLC, in the order given, and
+// returns the key path and the records the executor fires the triggers with.
+func writeBars(c *C, epoch []int64, open, high, low, close []float32) (string, []trigger.Record) {
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	cs.AddColumn("Open", open)
+	cs.AddColumn("High", high)
+	cs.AddColumn("Low", low)
+	cs.AddColumn("Close", close)
+	tbk := io.NewTimeBucketKey("TEST/1Min/OHLC")
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+
+	rs := cs.ToRowSeries(*tbk, true)
+	rowData := rs.GetData()
+	times, err := rs.GetTime()
+	c.Assert(err, IsNil)
+	rowLen := len(rowData) / len(times)
+	records := make([]trigger.Record, len(times))
+	for i := range times {
+		buf, _ := io.Serialize(nil, io.TimeToIndex(times[i], time.Minute))
+		records[i] = trigger.Record(append(buf, rowData[i*rowLen+8:(i+1)*rowLen]...))
+	}
+	return fmt.Sprintf("TEST/1Min/OHLC/%d.bin", times[0].Year()), records
+}
+
+func readAggregates(c *C, key string) *io.ColumnSeries {
+	tbk := io.NewTimeBucketKey(key)
+	q := planner.NewQuery(executor.ThisInstance.CatalogDir)
+	q.AddTargetKey(tbk)
+	q.SetRange(planner.MinTime, planner.MaxTime)
+	parsed, err := q.Parse()
+	c.Assert(err, IsNil)
+	scanner, err := executor.NewReader(parsed)
+	c.Assert(err, IsNil)
+	csm, err := scanner.Read()
+	c.Assert(err, IsNil)
+	c.Assert(csm[*tbk], NotNil)
+	return csm[*tbk]
+}
+
+func (t *TestSuite) TestFireOutOfOrder(c *C) {
+	utils.InstanceConfig.Timezone, _ = time.LoadLocation("America/New_York")
+	ny := utils.InstanceConfig.Timezone
+
+	rootDir := filepath.Join(c.MkDir(), "mktsdb")
+	os.MkdirAll(rootDir, 0777)
+	executor.NewInstanceSetup(
+		rootDir,
+		true, true, false, false)
+
+	trig, err := NewTrigger(map[string]interface{}{
+		"destinations": []string{"5Min", "1D"},
+	})
+	c.Assert(err, IsNil)
+
+	at := func(day, hour, minute int) int64 {
+		return time.Date(2017, 12, day, hour, minute, 0, 0, ny).Unix()
+	}
+
+	// the later day first
+	writeAndFire(c, trig,
+		[]int64{at(15, 10, 0), at(15, 10, 1), at(15, 10, 5)},
+		[]float32{10, 11, 12}, []float32{10.5, 11.5, 12.5}, []float32{9.5, 10.5, 11.5}, []float32{10, 11, 12})
+
+	// then the earlier one, in reverse order and along with a correction of
+	// a bar of the later day in the cache
+	writeAndFire(c, trig,
+		[]int64{at(15, 10, 1), at(14, 10, 6), at(14, 10, 3), at(14, 10, 2)},
+		[]float32{11, 3, 2, 1}, []float32{20, 3.5, 2.5, 1.5}, []float32{10.5, 2.5, 1.5, 0.5}, []float32{11, 3, 2, 1})
+
+	cs1D := readAggregates(c, "TEST/1D/OHLC")
+	c.Assert(cs1D.GetEpoch(), DeepEquals, []int64{at(14, 0, 0), at(15, 0, 0)})
+	c.Assert(cs1D.GetColumn("Open"), DeepEquals, []float32{1, 10})
+	c.Assert(cs1D.GetColumn("High"), DeepEquals, []float32{3.5, 20})
+	c.Assert(cs1D.GetColumn("Low"), DeepEquals, []float32{0.5, 9.5})
+	c.Assert(cs1D.GetColumn("Close"), DeepEquals, []float32{3, 12})
+
+	cs5 := readAggregates(c, "TEST/5Min/OHLC")
+	c.Assert(cs5.GetEpoch(), DeepEquals, []int64{at(14, 10, 0), at(14, 10, 5), at(15, 10, 0), at(15, 10, 5)})
+	c.Assert(cs5.GetColumn("Open"), DeepEquals, []float32{1, 3, 10, 12})
+	c.Assert(cs5.GetColumn("High"), DeepEquals, []float32{2.5, 3.5, 20, 12.5})
+	c.Assert(cs5.GetColumn("Close"), DeepEquals, []float32{2, 3, 11, 12})
+
+	// and a late bar in the middle of the earlier day
+	writeAndFire(c, trig,
+		[]int64{at(14, 10, 4)},
+		[]float32{5}, []float32{5.5}, []float32{0.1}, []float32{5})
+	cs1D = readAggregates(c, "TEST/1D/OHLC")
+	c.Assert(cs1D.GetColumn("Open"), DeepEquals, []float32{1, 10})
+	c.Assert(cs1D.GetColumn("High"), DeepEquals, []float32{5.5, 20})
+	c.Assert(cs1D.GetColumn("Low"), DeepEquals, []float32{0.1, 9.5})
+	c.Assert(cs1D.GetColumn("Close"), DeepEquals, []float32{3, 12})
+	cs5 = readAggregates(c, "TEST/5Min/OHLC")
+	c.Assert(cs5.GetColumn("Close"), DeepEquals, []float32{5, 3, 11, 12})
+}
+
+func (t *TestSuite) TestFireWithError(c *C) {
+	utils.InstanceConfig.Timezone, _ = time.LoadLocation("America/New_York")
+	ny := utils.InstanceConfig.Timezone
+
+	rootDir := filepath.Join(c.MkDir(), "mktsdb")
+	os.MkdirAll(rootDir, 0777)
+	executor.NewInstanceSetup(
+		rootDir,
+		true, true, false, false)
+
+	ret, err := NewTrigger(map[string]interface{}{
+		"destinations": []string{"5Min"},
+	})
+	c.Assert(err, IsNil)
+	trig := ret.(trigger.ErrorTrigger)
+
+	// the destination has another schema, so the aggregates are not written
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{time.Date(2017, 12, 14, 10, 0, 0, 0, ny).Unix()})
+	cs.AddColumn("Price", []float32{1})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*io.NewTimeBucketKey("TEST/5Min/OHLC"), cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+
+	at := func(minute int) int64 {
+		return time.Date(2017, 12, 14, 10, minute, 0, 0, ny).Unix()
+	}
+	keyPath, records := writeBars(c, []int64{at(0), at(1)},
+		[]float32{1, 2}, []float32{1.5, 2.5}, []float32{0.5, 1.5}, []float32{1, 2})
+	err = trig.FireWithError(keyPath, records)
+	c.Assert(err, ErrorMatches, "failed to write TEST/1Min/OHLC.* aggregates \\(unable to match data columns.*")
+}
+
+func (t *TestSuite) TestFireTrades(c *C) {
+	utils.InstanceConfig.Timezone, _ = time.LoadLocation("America/New_York")
+	ny := utils.InstanceConfig.Timezone
+
+	rootDir := filepath.Join(c.MkDir(), "mktsdb")
+	os.MkdirAll(rootDir, 0777)
+	executor.NewInstanceSetup(
+		rootDir,
+		true, true, false, false)
+
+	_, err := NewTrigger(getConfig(`{"destinations": ["1Min"], "source_type": "quotes"}`))
+	c.Assert(err, ErrorMatches, "invalid source_type: quotes")
+
+	trig, err := NewTrigger(getConfig(`{
+        "destinations": ["1Sec", "1Min", "5Min"],
+        "source": "1Sec",
+        "source_type": "trades",
+        "vwap": true
+        }`))
+	c.Assert(err, IsNil)
+
+	at := func(minute, second, nanos int) time.Time {
+		return time.Date(2017, 12, 14, 10, minute, second, nanos, ny)
+	}
+	// out of order within the minutes, and within a second
+	times := []time.Time{
+		at(0, 30, 0), at(0, 10, 500), at(0, 10, 100), at(0, 59, 0),
+		at(1, 5, 0), at(1, 0, 0),
+		at(6, 0, 0),
+	}
+	price := []float32{10.5, 10.2, 10, 10.1, 11, 11.5, 12}
+	size := []int32{100, 50, 200, 10, 40, 60, 5}
+
+	epoch := make([]int64, len(times))
+	nanos := make([]int32, len(times))
+	for i, t := range times {
+		epoch[i] = t.Unix()
+		nanos[i] = int32(t.Nanosecond())
+	}
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	cs.AddColumn("Price", price)
+	cs.AddColumn("Size", size)
+	cs.AddColumn("Nanoseconds", nanos)
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*io.NewTimeBucketKey("TEST/1Sec/TRADE"), cs)
+	c.Assert(executor.WriteCSM(csm, true), IsNil)
+
+	// only the indexes of the records are read
+	records := make([]trigger.Record, len(times))
+	for i, t := range times {
+		buf, _ := io.Serialize(nil, io.TimeToIndex(t, time.Second))
+		records[i] = trigger.Record(buf)
+	}
+	trig.Fire("TEST/1Sec/TRADE/2017.bin", records)
+
+	// computed by hand from the trades sorted by time
+	cs1 := readAggregates(c, "TEST/1Min/OHLCV")
+	c.Assert(cs1.GetEpoch(), DeepEquals, []int64{at(0, 0, 0).Unix(), at(1, 0, 0).Unix(), at(6, 0, 0).Unix()})
+	c.Assert(cs1.GetColumn("Open"), DeepEquals, []float32{10, 11.5, 12})
+	c.Assert(cs1.GetColumn("High"), DeepEquals, []float32{10.5, 11.5, 12})
+	c.Assert(cs1.GetColumn("Low"), DeepEquals, []float32{10, 11, 12})
+	c.Assert(cs1.GetColumn("Close"), DeepEquals, []float32{10.1, 11, 12})
+	c.Assert(cs1.GetColumn("Volume"), DeepEquals, []int32{360, 100, 5})
+	c.Assert(cs1.GetColumn("TickCnt"), DeepEquals, []int32{4, 2, 1})
+	vwap := cs1.GetColumn("VWAP").([]float32)
+	c.Assert(vwap, HasLen, 3)
+	for i, expected := range []float64{(10*200 + 10.2*50 + 10.5*100 + 10.1*10) / 360, (11.5*60 + 11*40) / 100, 12} {
+		c.Assert(math.Abs(float64(vwap[i])-expected) < 1e-4, Equals, true)
+	}
+
+	cs5 := readAggregates(c, "TEST/5Min/OHLCV")
+	c.Assert(cs5.GetEpoch(), DeepEquals, []int64{at(0, 0, 0).Unix(), at(5, 0, 0).Unix()})
+	c.Assert(cs5.GetColumn("Open"), DeepEquals, []float32{10, 12})
+	c.Assert(cs5.GetColumn("Close"), DeepEquals, []float32{11, 12})
+	c.Assert(cs5.GetColumn("Volume"), DeepEquals, []int32{460, 5})
+
+	// the trades within the same second are aggregated in their order
+	csSec := readAggregates(c, "TEST/1Sec/OHLCV")
+	c.Assert(csSec.Len(), Equals, 6)
+	c.Assert(csSec.GetColumn("Open").([]float32)[0], Equals, float32(10))
+	c.Assert(csSec.GetColumn("Close").([]float32)[0], Equals, float32(10.2))
+}
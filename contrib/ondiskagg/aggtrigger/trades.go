@@ -0,0 +1,54 @@
+package aggtrigger
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// tradeBars returns the trades of cs, with Price and Size columns, as the
+// bars of one trade each sorted by time, so that they are aggregated like
+// the bars: Open, High, Low and Close are the price, Volume is the size and
+// TickCnt is one.
+func tradeBars(cs *io.ColumnSeries) (*io.ColumnSeries, error) {
+	price := cs.GetColumn("Price")
+	switch price.(type) {
+	case []float32, []float64:
+	default:
+		return nil, fmt.Errorf("the trades have no float Price column")
+	}
+	size := cs.GetColumn("Size")
+	if size == nil {
+		return nil, fmt.Errorf("the trades have no Size column")
+	}
+
+	epoch := cs.GetEpoch()
+	nanos, _ := cs.GetColumn("Nanoseconds").([]int32)
+	indexes := make([]int, len(epoch))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := indexes[i], indexes[j]
+		if epoch[a] != epoch[b] || nanos == nil {
+			return epoch[a] < epoch[b]
+		}
+		return nanos[a] < nanos[b]
+	})
+	sorted := cs.SelectRows(indexes)
+
+	tickCnt := make([]int32, len(epoch))
+	for i := range tickCnt {
+		tickCnt[i] = 1
+	}
+
+	bars := io.NewColumnSeries()
+	bars.AddColumn("Epoch", sorted.GetEpoch())
+	for _, name := range []string{"Open", "High", "Low", "Close"} {
+		bars.AddColumn(name, sorted.GetColumn("Price"))
+	}
+	bars.AddColumn("Volume", sorted.GetColumn("Size"))
+	bars.AddColumn("TickCnt", tickCnt)
+	return bars, nil
+}
@@ -0,0 +1,122 @@
+package aggtrigger
+
+import (
+	"math"
+
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// vwapAccumulator computes the volume weighted average price of each
+// output bar.  The price of the input rows is their VWAP column if any,
+// or the typical price (High+Low+Close)/3 otherwise, so that input bars
+// made of a single trade are weighted by the trade price.
+type vwapAccumulator struct {
+	price, volume []float64
+	// carry the previous VWAP forward on zero-volume bars instead of NaN
+	carry   bool
+	float32 bool
+	out     []float64
+}
+
+func newVWAPAccumulator(cs *io.ColumnSeries, carry bool) *vwapAccumulator {
+	volume, ok := toFloat64s(cs.GetColumn("Volume"))
+	if !ok {
+		return nil
+	}
+
+	var price []float64
+	if cs.Exists("VWAP") {
+		if price, ok = toFloat64s(cs.GetColumn("VWAP")); !ok {
+			return nil
+		}
+	} else {
+		high, okH := toFloat64s(cs.GetColumn("High"))
+		low, okL := toFloat64s(cs.GetColumn("Low"))
+		closes, okC := toFloat64s(cs.GetColumn("Close"))
+		if !okH || !okL || !okC {
+			return nil
+		}
+		price = make([]float64, len(closes))
+		for i := range closes {
+			price[i] = (high[i] + low[i] + closes[i]) / 3
+		}
+	}
+
+	_, isFloat32 := cs.GetColumn("Close").([]float32)
+
+	return &vwapAccumulator{
+		price:   price,
+		volume:  volume,
+		carry:   carry,
+		float32: isFloat32,
+	}
+}
+
+func (va *vwapAccumulator) apply(start, end int) {
+	var pv, v float64
+	for i := start; i < end; i++ {
+		pv += va.price[i] * va.volume[i]
+		v += va.volume[i]
+	}
+
+	vwap := math.NaN()
+	switch {
+	case v != 0:
+		vwap = pv / v
+	case va.carry && len(va.out) > 0:
+		vwap = va.out[len(va.out)-1]
+	case va.carry && end > start:
+		// nothing to carry for the first bar, use its last price
+		vwap = va.price[end-1]
+	}
+	va.out = append(va.out, vwap)
+}
+
+func (va *vwapAccumulator) addColumn(cs *io.ColumnSeries) {
+	if !va.float32 {
+		cs.AddColumn("VWAP", va.out)
+		return
+	}
+
+	out := make([]float32, len(va.out))
+	for i, vwap := range va.out {
+		out[i] = float32(vwap)
+	}
+	cs.AddColumn("VWAP", out)
+}
+
+func toFloat64s(col interface{}) ([]float64, bool) {
+	var out []float64
+	switch c := col.(type) {
+	case []float32:
+		out = make([]float64, len(c))
+		for i := range c {
+			out[i] = float64(c[i])
+		}
+	case []float64:
+		out = c
+	case []int32:
+		out = make([]float64, len(c))
+		for i := range c {
+			out[i] = float64(c[i])
+		}
+	case []int64:
+		out = make([]float64, len(c))
+		for i := range c {
+			out[i] = float64(c[i])
+		}
+	case []uint32:
+		out = make([]float64, len(c))
+		for i := range c {
+			out[i] = float64(c[i])
+		}
+	case []uint64:
+		out = make([]float64, len(c))
+		for i := range c {
+			out[i] = float64(c[i])
+		}
+	default:
+		return nil, false
+	}
+	return out, true
+}
@@ -5,6 +5,7 @@ package main
 import (
 	"github.com/alpacahq/marketstore/v4/contrib/ondiskagg/aggtrigger"
 	"github.com/alpacahq/marketstore/v4/plugins/trigger"
+	"github.com/alpacahq/marketstore/v4/plugins/version"
 )
 
 // NewTrigger returns a new on-disk aggregate trigger based on the configuration.
@@ -12,5 +13,8 @@ func NewTrigger(conf map[string]interface{}) (trigger.Trigger, error) {
 	return aggtrigger.NewTrigger(conf)
 }
 
+// MarketstoreVersion stamps the plugin with the version it is built against.
+var MarketstoreVersion = version.Version
+
 func main() {
 }
@@ -19,11 +19,14 @@ import (
 )
 
 const (
-	aggURL     = "%v/v2/aggs/ticker/%v/range/%v/%v/%v/%v"
-	tradesURL  = "%v/v2/ticks/stocks/trades/%v/%v"
-	quotesURL  = "%v/v1/historic/quotes/%v/%v"
-	tickersURL = "%v/v2/reference/tickers"
-	retryCount = 10
+	aggURL       = "%v/v2/aggs/ticker/%v/range/%v/%v/%v/%v"
+	tradesURL    = "%v/v2/ticks/stocks/trades/%v/%v"
+	quotesURL    = "%v/v1/historic/quotes/%v/%v"
+	tickersURL   = "%v/v2/reference/tickers"
+	splitsURL    = "%v/v2/reference/splits/%v"
+	dividendsURL = "%v/v2/reference/dividends/%v"
+	exchangesURL = "%v/v1/meta/exchanges"
+	retryCount   = 10
 )
 
 var (
@@ -124,6 +127,7 @@ func ListTickers() (*ListTickersResponse, error) {
 
 		u.RawQuery = q.Encode()
 
+		limiter.wait()
 		code, body, err := fasthttp.Get(nil, u.String())
 		if err != nil {
 			return nil, err
@@ -159,15 +163,18 @@ func ListTickers() (*ListTickersResponse, error) {
 	return &resp, nil
 }
 
-// GetHistoricAggregates requests polygon's REST API for aggregates
-// for the provided resolution based on the provided parameters.
+// GetHistoricAggregates requests polygon's v2 REST API for aggregates
+// for the provided resolution based on the provided parameters, following
+// the next_url cursor until all the pages are read.  The limit, if any, is
+// the number of base aggregates per page, and adjusted requests them adjusted
+// for splits.
 func GetHistoricAggregates(
 	ticker,
 	timespan string,
 	multiplier int,
 	from, to time.Time,
-	limit *int) (*HistoricAggregates, error) {
-	// FIXME: This function does not handle pagination
+	limit *int,
+	adjusted bool) (*HistoricAggregates, error) {
 
 	u, err := url.Parse(fmt.Sprintf(aggURL, baseURL, ticker, multiplier, timespan, from.Format(completeDate), to.Format(completeDate)))
 	if err != nil {
@@ -176,6 +183,8 @@ func GetHistoricAggregates(
 
 	q := u.Query()
 	q.Set("apiKey", apiKey)
+	q.Set("adjusted", strconv.FormatBool(adjusted))
+	q.Set("sort", "asc")
 
 	if limit != nil {
 		q.Set("limit", strconv.FormatInt(int64(*limit), 10))
@@ -183,18 +192,76 @@ func GetHistoricAggregates(
 
 	u.RawQuery = q.Encode()
 
-	agg := &HistoricAggregates{}
-	err = downloadAndUnmarshal(u.String(), retryCount, agg)
+	var total *HistoricAggregates
+	for u != nil {
+		agg := &HistoricAggregates{}
+		if err = downloadAndUnmarshal(u.String(), retryCount, agg); err != nil {
+			return nil, err
+		}
+
+		if total == nil {
+			total = agg
+		} else {
+			total.Results = append(total.Results, agg.Results...)
+			total.QueryCount += agg.QueryCount
+		}
+
+		if u, err = nextURL(agg.NextURL); err != nil {
+			return nil, err
+		}
+	}
+
+	total.NextURL = ""
+	total.ResultCount = len(total.Results)
+
+	return total, nil
+}
+
+// nextURL returns the URL of the next page given by the next_url cursor of a
+// v2 response, which has to be authenticated again, or nil for the last page.
+func nextURL(next string) (*url.URL, error) {
+	if next == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(next)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("invalid next_url %q: %v", next, err)
+	}
+
+	q := u.Query()
+	if q.Get("apiKey") == "" {
+		q.Set("apiKey", apiKey)
+		u.RawQuery = q.Encode()
 	}
 
-	return agg, nil
+	return u, nil
 }
 
 // GetHistoricTrades requests polygon's REST API for historic trades
 // on the provided date .
 func GetHistoricTrades(symbol, date string, batchSize int) (totalTrades *HistoricTrades, err error) {
+	totalTrades = &HistoricTrades{}
+	err = StreamHistoricTrades(symbol, date, batchSize, func(trades []TradeTick) error {
+		totalTrades.Results = append(totalTrades.Results, trades...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	totalTrades.Ticker = symbol
+	totalTrades.Success = true
+	totalTrades.ResultsCount = len(totalTrades.Results)
+
+	return totalTrades, nil
+}
+
+// StreamHistoricTrades requests polygon's REST API for historic trades
+// on the provided date like GetHistoricTrades, but passes each page of
+// batchSize trades to fn as soon as it is downloaded instead of holding
+// the whole day in memory.  It stops at the first error returned by fn.
+func StreamHistoricTrades(symbol, date string, batchSize int, fn func([]TradeTick) error) (err error) {
 	var (
 		offset = int64(0)
 		u      *url.URL
@@ -204,7 +271,7 @@ func GetHistoricTrades(symbol, date string, batchSize int) (totalTrades *Histori
 	for {
 		u, err = url.Parse(fmt.Sprintf(tradesURL, baseURL, symbol, date))
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		q = u.Query()
@@ -220,13 +287,11 @@ func GetHistoricTrades(symbol, date string, batchSize int) (totalTrades *Histori
 		trades := &HistoricTrades{}
 		err := downloadAndUnmarshal(u.String(), retryCount, trades)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		if totalTrades == nil {
-			totalTrades = trades
-		} else {
-			totalTrades.Results = append(totalTrades.Results, trades.Results...)
+		if err = fn(trades.Results); err != nil {
+			return err
 		}
 
 		if len(trades.Results) == batchSize {
@@ -239,11 +304,7 @@ func GetHistoricTrades(symbol, date string, batchSize int) (totalTrades *Histori
 		}
 	}
 
-	totalTrades.Ticker = symbol
-	totalTrades.Success = true
-	totalTrades.ResultsCount = len(totalTrades.Results)
-
-	return totalTrades, nil
+	return nil
 }
 
 // GetHistoricQuotes requests polygon's REST API for historic quotes
@@ -294,6 +355,46 @@ func GetHistoricQuotes(symbol, date string, batchSize int) (totalQuotes *Histori
 	return totalQuotes, nil
 }
 
+// GetSplits requests polygon's reference REST API for
+// all the historical stock splits of the ticker.
+func GetSplits(ticker string) (*Splits, error) {
+	u, err := url.Parse(fmt.Sprintf(splitsURL, baseURL, ticker))
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Set("apiKey", apiKey)
+	u.RawQuery = q.Encode()
+
+	splits := &Splits{}
+	if err = downloadAndUnmarshal(u.String(), retryCount, splits); err != nil {
+		return nil, err
+	}
+
+	return splits, nil
+}
+
+// GetDividends requests polygon's reference REST API for
+// all the historical cash dividends of the ticker.
+func GetDividends(ticker string) (*Dividends, error) {
+	u, err := url.Parse(fmt.Sprintf(dividendsURL, baseURL, ticker))
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Set("apiKey", apiKey)
+	u.RawQuery = q.Encode()
+
+	dividends := &Dividends{}
+	if err = downloadAndUnmarshal(u.String(), retryCount, dividends); err != nil {
+		return nil, err
+	}
+
+	return dividends, nil
+}
+
 func downloadAndUnmarshal(url string, retryCount int, data interface{}) error {
 	// It is required to retry both the download() and unmarshal() calls
 	// as network errors (e.g. Unexpected EOF) can come also from unmarshal()
@@ -327,6 +428,8 @@ func download(url string, retryCount int) (*http.Response, error) {
 
 	// The returned JSON's size can be greatly reduced by enabling compression
 	req.Header.Add("Accept-Encoding", "gzip")
+	// every attempt counts against the rate limit of the plan
+	limiter.wait()
 	resp, err = client.Do(req)
 	if err != nil {
 		return nil, err
@@ -361,3 +464,23 @@ func unmarshal(resp *http.Response, data interface{}) (err error) {
 
 	return json.Unmarshal(body, data)
 }
+
+// ListExchanges requests polygon's reference REST API for
+// the stock exchanges.
+func ListExchanges() ([]Exchange, error) {
+	u, err := url.Parse(fmt.Sprintf(exchangesURL, baseURL))
+	if err != nil {
+		return nil, err
+	}
+
+	q := u.Query()
+	q.Set("apiKey", apiKey)
+	u.RawQuery = q.Encode()
+
+	var exchanges []Exchange
+	if err = downloadAndUnmarshal(u.String(), retryCount, &exchanges); err != nil {
+		return nil, err
+	}
+
+	return exchanges, nil
+}
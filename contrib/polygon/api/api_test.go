@@ -0,0 +1,131 @@
+package api
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&APITests{})
+
+type APITests struct{}
+
+func (s *APITests) TestGetHistoricAggregatesPagination(c *C) {
+	var requests []*http.Request
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		fixture := "testdata/aggs_v2_page1.json"
+		if r.URL.Query().Get("cursor") != "" {
+			fixture = "testdata/aggs_v2_page2.json"
+		}
+		body, err := ioutil.ReadFile(fixture)
+		c.Assert(err, IsNil)
+		w.Write([]byte(strings.Replace(string(body), "{{BASE_URL}}", srv.URL, 1)))
+	}))
+	defer srv.Close()
+
+	defer SetBaseURL(baseURL)
+	defer SetAPIKey(apiKey)
+	SetBaseURL(srv.URL)
+	SetAPIKey("testkey")
+
+	limit := 2
+	from := time.Date(2020, 12, 10, 0, 0, 0, 0, NY)
+	agg, err := GetHistoricAggregates("AAPL", "minute", 1, from, from, &limit, false)
+	c.Assert(err, IsNil)
+
+	// the first page is requested with the parameters
+	c.Assert(requests, HasLen, 2)
+	c.Assert(requests[0].URL.Path, Equals, "/v2/aggs/ticker/AAPL/range/1/minute/2020-12-10/2020-12-10")
+	c.Assert(requests[0].URL.Query().Get("apiKey"), Equals, "testkey")
+	c.Assert(requests[0].URL.Query().Get("adjusted"), Equals, "false")
+	c.Assert(requests[0].URL.Query().Get("limit"), Equals, "2")
+	// and the next one with the cursor, authenticated again
+	c.Assert(requests[1].URL.Query().Get("cursor"), Equals, "bGltaXQ9MiZzb3J0PWFzYw")
+	c.Assert(requests[1].URL.Query().Get("apiKey"), Equals, "testkey")
+
+	c.Assert(agg.Ticker, Equals, "AAPL")
+	c.Assert(agg.ResultCount, Equals, 3)
+	c.Assert(agg.NextURL, Equals, "")
+	c.Assert(agg.Results, HasLen, 3)
+	for i, epoch := range []int64{1607610600000, 1607610660000, 1607610720000} {
+		c.Assert(agg.Results[i].EpochMilliseconds, Equals, epoch)
+	}
+	c.Assert(agg.Results[0], Equals, AggResult{
+		Volume:            1523487,
+		Open:              122.02,
+		Close:             121.9,
+		High:              122.12,
+		Low:               121.8,
+		EpochMilliseconds: 1607610600000,
+		NumberOfItems:     9174,
+	})
+}
+
+func (s *APITests) TestGetHistoricAggregatesAdjusted(c *C) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(`{"ticker":"AAPL","status":"OK","resultsCount":0}`))
+	}))
+	defer srv.Close()
+
+	defer SetBaseURL(baseURL)
+	SetBaseURL(srv.URL)
+
+	agg, err := GetHistoricAggregates("AAPL", "day", 1, time.Now(), time.Now(), nil, true)
+	c.Assert(err, IsNil)
+	c.Assert(agg.Results, HasLen, 0)
+	c.Assert(strings.Contains(query, "adjusted=true"), Equals, true)
+	c.Assert(strings.Contains(query, "limit="), Equals, false)
+}
+
+func (s *APITests) TestStreamHistoricTrades(c *C) {
+	var timestamps []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timestamps = append(timestamps, r.URL.Query().Get("timestamp"))
+		if r.URL.Query().Get("timestamp") == "" {
+			w.Write([]byte(`{"results":[{"t":1,"p":10,"s":100},{"t":2,"p":11,"s":200}]}`))
+		} else {
+			w.Write([]byte(`{"results":[{"t":3,"p":12,"s":300}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	defer SetBaseURL(baseURL)
+	SetBaseURL(srv.URL)
+
+	// the pages are passed as they are downloaded
+	var pages [][]TradeTick
+	err := StreamHistoricTrades("AAPL", "2020-12-10", 2, func(trades []TradeTick) error {
+		pages = append(pages, trades)
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(timestamps, DeepEquals, []string{"", "2"})
+	c.Assert(pages, HasLen, 2)
+	c.Assert(pages[0], HasLen, 2)
+	c.Assert(pages[1][0].Price, Equals, 12.0)
+
+	// and the download stops at the first error of the callback
+	timestamps = nil
+	err = StreamHistoricTrades("AAPL", "2020-12-10", 2, func(trades []TradeTick) error {
+		return fmt.Errorf("write failed")
+	})
+	c.Assert(err, ErrorMatches, "write failed")
+	c.Assert(timestamps, HasLen, 1)
+
+	trades, err := GetHistoricTrades("AAPL", "2020-12-10", 2)
+	c.Assert(err, IsNil)
+	c.Assert(trades.Ticker, Equals, "AAPL")
+	c.Assert(trades.ResultsCount, Equals, 3)
+}
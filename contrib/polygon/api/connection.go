@@ -11,6 +11,11 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 30 * time.Second
+)
+
 type PolygonWebSocket struct {
 	maxMessageSize int64
 	pingPeriod     time.Duration
@@ -20,6 +25,10 @@ type PolygonWebSocket struct {
 	scope          *SubscriptionScope
 	conn           *websocket.Conn
 	outputChan     chan interface{}
+	// server is the index of the server in use, rotated on failures
+	server int
+	// onReconnect is called once resubscribed after the connection was lost
+	onReconnect func()
 }
 
 func NewPolygonWebSocket(servers, apiKey string, pref Prefix, symbols []string, oChan chan interface{}) *PolygonWebSocket {
@@ -58,15 +67,25 @@ func (p *PolygonWebSocket) pongHandler(s string) (err error) {
 }
 
 func (p *PolygonWebSocket) listen() {
+	subscribed := false
+	backoff := minReconnectBackoff
+	retry := func() {
+		// the next server is tried after a backoff growing up to maxReconnectBackoff
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxReconnectBackoff {
+			backoff = maxReconnectBackoff
+		}
+		p.server = (p.server + 1) % len(p.Servers)
+	}
 restartConnection:
 	// start the upstream websocket connection
 	err := p.connect()
 	if err != nil {
 		log.Warn("error connecting to upstream {%s:%v,%s:%v,%s:%v}",
-			"server", p.Servers[0].String(),
+			"server", p.Servers[p.server].String(),
 			"subscription", p.scope.GetSubScope(),
 			"error", err.Error())
-		time.Sleep(time.Second)
+		retry()
 		goto restartConnection // try again
 	}
 
@@ -74,9 +93,15 @@ restartConnection:
 	p.ping()
 
 	if !p.subscribe() {
-		time.Sleep(time.Second)
+		retry()
 		goto restartConnection // try again
 	}
+	backoff = minReconnectBackoff
+	if subscribed && p.onReconnect != nil {
+		// the messages sent while disconnected are lost
+		p.onReconnect()
+	}
+	subscribed = true
 
 	p.conn.SetReadLimit(p.maxMessageSize)
 	err = p.conn.SetReadDeadline(time.Now().Add(p.pingPeriod))
@@ -146,7 +171,7 @@ func (p *PolygonWebSocket) connect() (err error) {
 	var hresp *http.Response
 	dialer := websocket.DefaultDialer
 	dialer.HandshakeTimeout = 2 * time.Second
-	p.conn, hresp, err = dialer.Dial(p.Servers[0].String(), nil)
+	p.conn, hresp, err = dialer.Dial(p.Servers[p.server].String(), nil)
 	if err != nil {
 		return
 	}
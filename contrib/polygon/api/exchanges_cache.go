@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// exchangesCache is the file caching the exchange list.
+type exchangesCache struct {
+	Fetched   time.Time  `json:"fetched"`
+	Exchanges []Exchange `json:"exchanges"`
+}
+
+// ListExchangesCached returns the exchanges cached in the file like
+// ListTickersCached does the tickers.
+func ListExchangesCached(path string, ttl time.Duration, refresh bool) ([]Exchange, error) {
+	return listExchangesCached(path, ttl, refresh, ListExchanges)
+}
+
+func listExchangesCached(path string, ttl time.Duration, refresh bool,
+	list func() ([]Exchange, error)) ([]Exchange, error) {
+	cached, err := readExchangesCache(path)
+	if err != nil {
+		log.Warn("[polygon] ignoring the exchange cache %v (%v)", path, err)
+	}
+
+	if cached != nil && !refresh && time.Since(cached.Fetched) < ttl {
+		return cached.Exchanges, nil
+	}
+
+	exchanges, err := list()
+	if err != nil {
+		if cached == nil {
+			return nil, err
+		}
+		log.Warn("[polygon] failed to list exchanges, using the exchanges cached at %v (%v)", cached.Fetched, err)
+		return cached.Exchanges, nil
+	}
+
+	if err = writeCacheFile(path, &exchangesCache{Fetched: time.Now(), Exchanges: exchanges}); err != nil {
+		log.Warn("[polygon] failed to cache the exchanges to %v (%v)", path, err)
+	}
+
+	return exchanges, nil
+}
+
+func readExchangesCache(path string) (*exchangesCache, error) {
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	cache := &exchangesCache{}
+	if err = json.Unmarshal(buf, cache); err != nil {
+		return nil, err
+	}
+	if len(cache.Exchanges) == 0 || cache.Fetched.IsZero() {
+		return nil, os.ErrInvalid
+	}
+
+	return cache, nil
+}
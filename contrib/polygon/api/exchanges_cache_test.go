@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *APITests) TestListExchanges(c *C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Assert(r.URL.Path, Equals, "/v1/meta/exchanges")
+		w.Write([]byte(`[{"id":1,"type":"exchange","market":"equities","mic":"XASE","name":"NYSE American (AMEX)","tape":"A"},` +
+			`{"id":12,"type":"exchange","market":"equities","mic":"XNAS","name":"Nasdaq","tape":"T"}]`))
+	}))
+	defer srv.Close()
+
+	defer SetBaseURL(baseURL)
+	SetBaseURL(srv.URL)
+
+	exchanges, err := ListExchanges()
+	c.Assert(err, IsNil)
+	c.Assert(exchanges, HasLen, 2)
+	c.Assert(exchanges[1], Equals, Exchange{
+		ID: 12, Type: "exchange", Market: "equities", MIC: "XNAS", Name: "Nasdaq", Tape: "T",
+	})
+}
+
+func (s *APITests) TestListExchangesCached(c *C) {
+	path := filepath.Join(c.MkDir(), "exchanges.json")
+
+	calls := 0
+	var listErr error
+	list := func() ([]Exchange, error) {
+		calls++
+		if listErr != nil {
+			return nil, listErr
+		}
+		return []Exchange{{ID: 12, Name: "Nasdaq"}}, nil
+	}
+
+	// listed and cached
+	exchanges, err := listExchangesCached(path, time.Hour, false, list)
+	c.Assert(err, IsNil)
+	c.Assert(exchanges, HasLen, 1)
+	c.Assert(calls, Equals, 1)
+
+	// reused
+	exchanges, err = listExchangesCached(path, time.Hour, false, list)
+	c.Assert(err, IsNil)
+	c.Assert(exchanges[0].Name, Equals, "Nasdaq")
+	c.Assert(calls, Equals, 1)
+
+	// expired, but used when listing fails
+	listErr = errors.New("status code 429")
+	exchanges, err = listExchangesCached(path, 0, false, list)
+	c.Assert(err, IsNil)
+	c.Assert(exchanges, HasLen, 1)
+	c.Assert(calls, Equals, 2)
+
+	// failing without a cache
+	_, err = listExchangesCached(filepath.Join(c.MkDir(), "exchanges.json"), time.Hour, false, list)
+	c.Assert(err, ErrorMatches, "status code 429")
+}
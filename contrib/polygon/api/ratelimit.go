@@ -0,0 +1,98 @@
+package api
+
+import (
+	"sync"
+	"time"
+)
+
+// limiter spaces the requests of all the goroutines of the process evenly
+// to stay under the per-minute request budget of the Polygon plan.
+var limiter = &rateLimiter{start: time.Now()}
+
+// rateLimiter hands out the request slots of a budget of requests per
+// minute one interval apart, unlimited if the budget is 0.
+type rateLimiter struct {
+	sync.Mutex
+	budget   int
+	interval time.Duration
+	next     time.Time
+	start    time.Time
+	requests int64
+	waited   time.Duration
+}
+
+// RateLimitStats are the requests made under the rate limit since it was set.
+type RateLimitStats struct {
+	// Budget is the limit of requests per minute, 0 if unlimited
+	Budget   int
+	Requests int64
+	Elapsed  time.Duration
+	// Waited is the total time the requests waited for their slot
+	Waited time.Duration
+}
+
+// RequestsPerMinute returns the average rate of the requests.
+func (s RateLimitStats) RequestsPerMinute() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Requests) / s.Elapsed.Minutes()
+}
+
+// Utilization returns the average rate of the requests as a fraction of the
+// budget, 0 if unlimited.
+func (s RateLimitStats) Utilization() float64 {
+	if s.Budget == 0 {
+		return 0
+	}
+	return s.RequestsPerMinute() / float64(s.Budget)
+}
+
+// SetRateLimit limits the requests of the process to perMinute requests per
+// minute, or removes the limit if 0, and resets the stats.
+func SetRateLimit(perMinute int) {
+	limiter.Lock()
+	defer limiter.Unlock()
+	limiter.budget = perMinute
+	limiter.interval = 0
+	if perMinute > 0 {
+		limiter.interval = time.Minute / time.Duration(perMinute)
+	}
+	limiter.next = time.Time{}
+	limiter.start = time.Now()
+	limiter.requests = 0
+	limiter.waited = 0
+}
+
+// GetRateLimitStats returns the requests made since the rate limit was set.
+func GetRateLimitStats() RateLimitStats {
+	limiter.Lock()
+	defer limiter.Unlock()
+	return RateLimitStats{
+		Budget:   limiter.budget,
+		Requests: limiter.requests,
+		Elapsed:  time.Since(limiter.start),
+		Waited:   limiter.waited,
+	}
+}
+
+// wait blocks until the next request slot, taking it.
+func (l *rateLimiter) wait() {
+	l.Lock()
+	l.requests++
+	if l.interval == 0 {
+		l.Unlock()
+		return
+	}
+	now := time.Now()
+	slot := l.next
+	if slot.Before(now) {
+		slot = now
+	}
+	l.next = slot.Add(l.interval)
+	delay := slot.Sub(now)
+	l.waited += delay
+	l.Unlock()
+
+	time.Sleep(delay)
+}
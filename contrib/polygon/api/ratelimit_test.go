@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *APITests) TestRateLimit(c *C) {
+	var mu sync.Mutex
+	var times []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		w.Write([]byte(`[]`))
+	}))
+	defer srv.Close()
+
+	defer SetBaseURL(baseURL)
+	SetBaseURL(srv.URL)
+	defer SetRateLimit(0)
+	// one request every 10ms
+	SetRateLimit(6000)
+
+	// shared by the goroutines
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 5; j++ {
+				_, err := ListExchanges()
+				c.Check(err, IsNil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	c.Assert(times, HasLen, 20)
+	c.Assert(times[19].Sub(times[0]) >= 180*time.Millisecond, Equals, true)
+
+	stats := GetRateLimitStats()
+	c.Assert(stats.Budget, Equals, 6000)
+	c.Assert(stats.Requests, Equals, int64(20))
+	c.Assert(stats.Waited > 0, Equals, true)
+	c.Assert(stats.Utilization() > 0, Equals, true)
+
+	// unlimited
+	SetRateLimit(0)
+	c.Assert(GetRateLimitStats().Utilization(), Equals, 0.0)
+	for i := 0; i < 10; i++ {
+		_, err := ListExchanges()
+		c.Assert(err, IsNil)
+	}
+	c.Assert(GetRateLimitStats().Waited, Equals, time.Duration(0))
+}
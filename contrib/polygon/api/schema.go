@@ -86,8 +86,10 @@ type HistoricAggregates struct {
 	Status      string      `json:"status"`
 	Adjusted    bool        `json:"adjusted"`
 	QueryCount  int         `json:"queryCount"`
-	ResultCount int         `json:"resultCount"`
+	ResultCount int         `json:"resultsCount"`
 	Results     []AggResult `json:"results"`
+	// NextURL is the cursor to the next page of results, if any
+	NextURL string `json:"next_url,omitempty"`
 }
 
 // AggResult is the structure that defines the actual Aggregate result
@@ -164,3 +166,52 @@ type QuoteTick struct {
 	AskSize     int     `json:"aS"`
 	Condition   int     `json:"c"`
 }
+
+// Splits is the structure that defines stock split
+// data served through polygon's reference REST API.
+type Splits struct {
+	Status  string  `json:"status"`
+	Count   int     `json:"count"`
+	Results []Split `json:"results"`
+}
+
+// Split is a single stock split. A 4-for-1 split has
+// ToFactor 4 and ForFactor 1 (Ratio 0.25).
+type Split struct {
+	Ticker       string  `json:"ticker"`
+	ExDate       string  `json:"exDate"`
+	PaymentDate  string  `json:"paymentDate"`
+	DeclaredDate string  `json:"declaredDate"`
+	Ratio        float64 `json:"ratio"`
+	ToFactor     float64 `json:"tofactor"`
+	ForFactor    float64 `json:"forfactor"`
+}
+
+// Dividends is the structure that defines cash dividend
+// data served through polygon's reference REST API.
+type Dividends struct {
+	Status  string     `json:"status"`
+	Count   int        `json:"count"`
+	Results []Dividend `json:"results"`
+}
+
+// Dividend is a single cash dividend, Amount is per share.
+type Dividend struct {
+	Ticker       string  `json:"ticker"`
+	ExDate       string  `json:"exDate"`
+	PaymentDate  string  `json:"paymentDate"`
+	RecordDate   string  `json:"recordDate"`
+	DeclaredDate string  `json:"declaredDate"`
+	Amount       float64 `json:"amount"`
+}
+
+// Exchange is a stock exchange served through polygon's
+// reference REST API, whose ID is the exchange of the trades.
+type Exchange struct {
+	ID     int    `json:"id"`
+	Type   string `json:"type"`
+	Market string `json:"market"`
+	MIC    string `json:"mic"`
+	Name   string `json:"name"`
+	Tape   string `json:"tape"`
+}
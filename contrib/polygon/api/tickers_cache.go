@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// tickersCache is the file caching the full ticker list, regardless of the
+// symbols selected from it.
+type tickersCache struct {
+	Fetched time.Time            `json:"fetched"`
+	Tickers *ListTickersResponse `json:"tickers"`
+}
+
+// ListTickersCached returns the ticker list cached in the file if it was
+// fetched less than ttl ago, or lists them again and caches them otherwise,
+// or if refresh is set.  A corrupt cache is listed again, and an expired one
+// is used if listing them fails.
+func ListTickersCached(path string, ttl time.Duration, refresh bool) (*ListTickersResponse, error) {
+	return listTickersCached(path, ttl, refresh, ListTickers)
+}
+
+func listTickersCached(path string, ttl time.Duration, refresh bool,
+	list func() (*ListTickersResponse, error)) (*ListTickersResponse, error) {
+	cached, err := readTickersCache(path)
+	if err != nil {
+		log.Warn("[polygon] ignoring the ticker cache %v (%v)", path, err)
+	}
+
+	if cached != nil && !refresh && time.Since(cached.Fetched) < ttl {
+		log.Info("[polygon] using the tickers cached at %v", cached.Fetched)
+		return cached.Tickers, nil
+	}
+
+	resp, err := list()
+	if err != nil {
+		if cached == nil {
+			return nil, err
+		}
+		log.Warn("[polygon] failed to list symbols, using the tickers cached at %v (%v)", cached.Fetched, err)
+		return cached.Tickers, nil
+	}
+
+	if err = writeTickersCache(path, &tickersCache{Fetched: time.Now(), Tickers: resp}); err != nil {
+		log.Warn("[polygon] failed to cache the tickers to %v (%v)", path, err)
+	}
+
+	return resp, nil
+}
+
+func readTickersCache(path string) (*tickersCache, error) {
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	cache := &tickersCache{}
+	if err = json.Unmarshal(buf, cache); err != nil {
+		return nil, err
+	}
+	if cache.Tickers == nil || cache.Fetched.IsZero() {
+		return nil, os.ErrInvalid
+	}
+
+	return cache, nil
+}
+
+// writeTickersCache replaces the file atomically, so that concurrent
+// backfills never read a partial cache.
+func writeTickersCache(path string, cache *tickersCache) error {
+	return writeCacheFile(path, cache)
+}
+
+// writeCacheFile writes the cache as JSON, replacing the file atomically.
+func writeCacheFile(path string, cache interface{}) error {
+	buf, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *APITests) TestListTickersCached(c *C) {
+	path := filepath.Join(c.MkDir(), "tickers.json")
+
+	calls := 0
+	var listErr error
+	list := func() (*ListTickersResponse, error) {
+		calls++
+		if listErr != nil {
+			return nil, listErr
+		}
+		resp := &ListTickersResponse{}
+		err := json.Unmarshal([]byte(`{"tickers":[{"ticker":"AAPL"},{"ticker":"SPY"}]}`), resp)
+		c.Assert(err, IsNil)
+		return resp, nil
+	}
+
+	// listed and cached
+	resp, err := listTickersCached(path, time.Hour, false, list)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Tickers, HasLen, 2)
+	c.Assert(calls, Equals, 1)
+
+	// reused
+	resp, err = listTickersCached(path, time.Hour, false, list)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Tickers, HasLen, 2)
+	c.Assert(resp.Tickers[1].Ticker, Equals, "SPY")
+	c.Assert(calls, Equals, 1)
+
+	// refreshed on demand
+	_, err = listTickersCached(path, time.Hour, true, list)
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 2)
+
+	// expired
+	_, err = listTickersCached(path, 0, false, list)
+	c.Assert(err, IsNil)
+	c.Assert(calls, Equals, 3)
+
+	// expired, but used when listing fails
+	listErr = errors.New("status code 429")
+	resp, err = listTickersCached(path, 0, false, list)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Tickers, HasLen, 2)
+	c.Assert(calls, Equals, 4)
+
+	// corrupt, listed again
+	c.Assert(ioutil.WriteFile(path, []byte(`{"fetched":`), 0644), IsNil)
+	_, err = listTickersCached(path, time.Hour, false, list)
+	c.Assert(err, Equals, listErr)
+	listErr = nil
+	resp, err = listTickersCached(path, time.Hour, false, list)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Tickers, HasLen, 2)
+	c.Assert(calls, Equals, 6)
+}
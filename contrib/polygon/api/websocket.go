@@ -54,6 +54,16 @@ func (s *Subscription) IsActive() bool {
 	defer s.Unlock()
 	return s.pConn.conn != nil
 }
+
+// OnReconnect sets the function called once resubscribed after the connection
+// was lost, e.g. to fill the gap of the messages missed in the meantime.  It
+// must be set before Subscribe.
+func (s *Subscription) OnReconnect(f func()) {
+	s.Lock()
+	defer s.Unlock()
+	s.pConn.onReconnect = f
+}
+
 func (s *Subscription) ResetHandled() {
 	atomic.StoreInt64(&s.handled, 0)
 }
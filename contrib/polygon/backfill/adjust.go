@@ -0,0 +1,199 @@
+package backfill
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/contrib/polygon/api"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// AdjustedAttributeGroup is the attribute group the adjusted
+// bars are written to, next to the raw OHLCV ones.
+const AdjustedAttributeGroup = "OHLCV_ADJ"
+
+// CorporateAction is an event that changes the price (and possibly the volume)
+// of a stock from its ex-date on. Bars before ExDate are multiplied by the
+// factors to be comparable with the bars after it.
+type CorporateAction struct {
+	ExDate       time.Time
+	PriceFactor  float64
+	VolumeFactor float64
+}
+
+// SplitAction returns the adjustment for a toFactor-for-forFactor split,
+// e.g. AAPL's 4:1 split on 2020-08-31 is SplitAction(exDate, 1, 4).
+func SplitAction(exDate time.Time, forFactor, toFactor float64) CorporateAction {
+	return CorporateAction{
+		ExDate:       exDate,
+		PriceFactor:  forFactor / toFactor,
+		VolumeFactor: toFactor / forFactor,
+	}
+}
+
+// DividendAction returns the adjustment for a cash dividend, where prevClose
+// is the close price of the last trading day before the ex-date.
+func DividendAction(exDate time.Time, amount, prevClose float64) CorporateAction {
+	return CorporateAction{
+		ExDate:       exDate,
+		PriceFactor:  1 - amount/prevClose,
+		VolumeFactor: 1,
+	}
+}
+
+// GetCorporateActions fetches the splits and cash dividends of the symbol
+// from polygon and returns them as CorporateActions sorted by ex-date.
+func GetCorporateActions(symbol string) ([]CorporateAction, error) {
+	defer timeAPICall(symbol, time.Now())
+	actions := []CorporateAction{}
+
+	splits, err := api.GetSplits(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, split := range splits.Results {
+		exDate, err := time.ParseInLocation(defaultFormat, split.ExDate, NY)
+		if err != nil {
+			return nil, err
+		}
+		if split.ForFactor <= 0 || split.ToFactor <= 0 {
+			return nil, fmt.Errorf("invalid split factors for %v on %v", symbol, split.ExDate)
+		}
+		actions = append(actions, SplitAction(exDate, split.ForFactor, split.ToFactor))
+	}
+
+	dividends, err := api.GetDividends(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, dividend := range dividends.Results {
+		exDate, err := time.ParseInLocation(defaultFormat, dividend.ExDate, NY)
+		if err != nil {
+			return nil, err
+		}
+
+		// the dividend is relative to the close price of the previous trading day
+		resp, err := api.GetHistoricAggregates(symbol, "day", 1, exDate.AddDate(0, 0, -10), exDate.AddDate(0, 0, -1), nil, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Results) == 0 || resp.Results[len(resp.Results)-1].Close <= 0 {
+			return nil, fmt.Errorf("no close price before the dividend of %v on %v", symbol, dividend.ExDate)
+		}
+
+		prevClose := resp.Results[len(resp.Results)-1].Close
+		actions = append(actions, DividendAction(exDate, dividend.Amount, prevClose))
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].ExDate.Before(actions[j].ExDate)
+	})
+
+	return actions, nil
+}
+
+// AdjustmentFactors returns the cumulative price and volume factors
+// to be applied to the bars at the given epochs.
+func AdjustmentFactors(epochs []int64, actions []CorporateAction) (price, volume []float64) {
+	price = make([]float64, len(epochs))
+	volume = make([]float64, len(epochs))
+
+	for i, epoch := range epochs {
+		price[i], volume[i] = 1, 1
+		for _, action := range actions {
+			if epoch < action.ExDate.Unix() {
+				price[i] *= action.PriceFactor
+				volume[i] *= action.VolumeFactor
+			}
+		}
+	}
+
+	return price, volume
+}
+
+// Adjust returns a copy of the OHLCV column series adjusted for the
+// corporate actions. Columns other than Open, High, Low, Close and
+// Volume are copied as is, so without any action the copy is identical.
+func Adjust(cs *io.ColumnSeries, actions []CorporateAction) (*io.ColumnSeries, error) {
+	price, volume := AdjustmentFactors(cs.GetEpoch(), actions)
+
+	adjusted := io.NewColumnSeries()
+	for _, name := range cs.GetColumnNames() {
+		col := cs.GetColumn(name)
+
+		var err error
+		switch name {
+		case "Open", "High", "Low", "Close":
+			col, err = scale(col, price)
+		case "Volume":
+			col, err = scale(col, volume)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to adjust %v column (%v)", name, err)
+		}
+
+		adjusted.AddColumn(name, col)
+	}
+
+	return adjusted, nil
+}
+
+func scale(col interface{}, factors []float64) (interface{}, error) {
+	switch c := col.(type) {
+	case []float32:
+		out := make([]float32, len(c))
+		for i := range c {
+			out[i] = float32(float64(c[i]) * factors[i])
+		}
+		return out, nil
+	case []float64:
+		out := make([]float64, len(c))
+		for i := range c {
+			out[i] = c[i] * factors[i]
+		}
+		return out, nil
+	case []int32:
+		out := make([]int32, len(c))
+		for i := range c {
+			out[i] = int32(float64(c[i])*factors[i] + 0.5)
+		}
+		return out, nil
+	case []int64:
+		out := make([]int64, len(c))
+		for i := range c {
+			out[i] = int64(float64(c[i])*factors[i] + 0.5)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported column type %T", col)
+	}
+}
+
+// addAdjusted adds the adjusted copy of each OHLCV series in the csm.
+func addAdjusted(csm io.ColumnSeriesMap, actions []CorporateAction) error {
+	adjustedCSM := io.NewColumnSeriesMap()
+
+	for tbk, cs := range csm {
+		if tbk.GetItemInCategory("AttributeGroup") != "OHLCV" {
+			continue
+		}
+
+		adjusted, err := Adjust(cs, actions)
+		if err != nil {
+			return err
+		}
+
+		adjTbk := tbk
+		adjTbk.SetItemInCategory("AttributeGroup", AdjustedAttributeGroup)
+		adjustedCSM.AddColumnSeries(adjTbk, adjusted)
+	}
+
+	for tbk, cs := range adjustedCSM {
+		csm.AddColumnSeries(tbk, cs)
+	}
+
+	return nil
+}
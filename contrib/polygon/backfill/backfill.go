@@ -7,7 +7,6 @@ import (
 	"time"
 
 	"github.com/alpacahq/marketstore/v4/contrib/polygon/api"
-	"github.com/alpacahq/marketstore/v4/executor"
 	"github.com/alpacahq/marketstore/v4/utils/io"
 	"github.com/alpacahq/marketstore/v4/utils/log"
 )
@@ -88,7 +87,67 @@ var (
 	BackfillM *sync.Map
 )
 
-func Bars(symbol string, from, to time.Time) (err error) {
+func Bars(symbol string, from, to time.Time, batchSize int) (err error) {
+	csm, err := bars(symbol, from, to, batchSize)
+	if err != nil || csm == nil {
+		return err
+	}
+
+	return write(csm, false)
+}
+
+// BarsBetween backfills the bars from the from time, up to but excluding the
+// to time, e.g. those missed between the last written bar and the first one
+// streamed, without writing any of them twice.
+func BarsBetween(symbol string, from, to time.Time, batchSize int) error {
+	csm, err := bars(symbol, from, to, batchSize)
+	if err != nil || csm == nil {
+		return err
+	}
+
+	if csm = barsBetween(csm, from, to); csm.IsEmpty() {
+		return nil
+	}
+
+	return write(csm, false)
+}
+
+// barsBetween returns the bars of csm from the from time, up to but excluding
+// the to time.
+func barsBetween(csm io.ColumnSeriesMap, from, to time.Time) io.ColumnSeriesMap {
+	start, end := from.Unix(), to.Unix()
+	out := io.NewColumnSeriesMap()
+	for tbk, cs := range csm {
+		slc := cs.ApplyTimeQual(func(epoch int64) bool {
+			return epoch >= start && epoch < end
+		})
+		if slc.Len() > 0 {
+			out.AddColumnSeries(tbk, slc)
+		}
+	}
+	return out
+}
+
+// AdjustedBars backfills the raw bars like Bars, and also writes a copy
+// of them adjusted for the given corporate actions under the
+// {symbol}/1Min/OHLCV_ADJ key.
+func AdjustedBars(symbol string, from, to time.Time, batchSize int, actions []CorporateAction) error {
+	csm, err := bars(symbol, from, to, batchSize)
+	if err != nil || csm == nil {
+		return err
+	}
+
+	if err = addAdjusted(csm, actions); err != nil {
+		return err
+	}
+
+	return write(csm, false)
+}
+
+// bars requests the 1Min bars unadjusted for splits, so that the raw series on
+// disk does not change when a split happens later, batchSize bars per page if
+// it is positive.
+func bars(symbol string, from, to time.Time, batchSize int) (csm io.ColumnSeriesMap, err error) {
 	if from.IsZero() {
 		from = time.Date(2014, 1, 1, 0, 0, 0, 0, NY)
 	}
@@ -97,9 +156,16 @@ func Bars(symbol string, from, to time.Time) (err error) {
 		to = time.Now()
 	}
 
-	resp, err := api.GetHistoricAggregates(symbol, "minute", 1, from, to, nil)
+	var limit *int
+	if batchSize > 0 {
+		limit = &batchSize
+	}
+
+	start := time.Now()
+	resp, err := api.GetHistoricAggregates(symbol, "minute", 1, from, to, limit, false)
+	timeAPICall(symbol, start)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	if len(resp.Results) == 0 {
@@ -107,7 +173,7 @@ func Bars(symbol string, from, to time.Time) (err error) {
 	}
 
 	tbk := io.NewTimeBucketKeyFromString(symbol + "/1Min/OHLCV")
-	csm := io.NewColumnSeriesMap()
+	csm = io.NewColumnSeriesMap()
 
 	epoch := make([]int64, len(resp.Results))
 	open := make([]float32, len(resp.Results))
@@ -134,7 +200,7 @@ func Bars(symbol string, from, to time.Time) (err error) {
 	cs.AddColumn("Volume", volume)
 	csm.AddColumnSeries(*tbk, cs)
 
-	return executor.WriteCSM(csm, false)
+	return csm, nil
 }
 
 func intInSlice(s int, l []int) bool {
@@ -147,21 +213,46 @@ func intInSlice(s int, l []int) bool {
 }
 
 func BuildBarsFromTrades(symbol string, date time.Time, exchangeIDs []int, batchSize int) error {
-	resp, err := api.GetHistoricTrades(symbol, date.Format(defaultFormat), batchSize)
-	if err != nil {
+	csm, err := streamTradesToBars(symbol, date, exchangeIDs, batchSize)
+	if err != nil || csm == nil {
 		return err
 	}
 
-	csm := tradesToBars(resp.Results, symbol, exchangeIDs)
-	if csm == nil {
-		return nil
+	if err = write(csm, false); err != nil {
+		return err
 	}
 
-	if err = executor.WriteCSM(csm, false); err != nil {
+	return nil
+}
+
+// BuildAdjustedBarsFromTrades works like BuildBarsFromTrades, and also
+// writes a copy of the bars adjusted for the given corporate actions.
+func BuildAdjustedBarsFromTrades(symbol string, date time.Time, exchangeIDs []int, batchSize int, actions []CorporateAction) error {
+	csm, err := streamTradesToBars(symbol, date, exchangeIDs, batchSize)
+	if err != nil || csm == nil {
 		return err
 	}
 
-	return nil
+	if err = addAdjusted(csm, actions); err != nil {
+		return err
+	}
+
+	return write(csm, false)
+}
+
+// streamTradesToBars aggregates the trades of the date to the 1Min bars
+// page by page, so that only one page of the trades is held in memory.
+func streamTradesToBars(symbol string, date time.Time, exchangeIDs []int, batchSize int) (io.ColumnSeriesMap, error) {
+	b := newBarBuilder(symbol, exchangeIDs)
+	err := streamTrades(symbol, date, batchSize, func(ticks []api.TradeTick) error {
+		b.add(ticks)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return b.columnSeriesMap(), nil
 }
 
 func conditionToUpdateInfo(tick api.TradeTick) ConsolidatedUpdateInfo {
@@ -179,52 +270,74 @@ func conditionToUpdateInfo(tick api.TradeTick) ConsolidatedUpdateInfo {
 }
 
 func tradesToBars(ticks []api.TradeTick, symbol string, exchangeIDs []int) io.ColumnSeriesMap {
-	var csm io.ColumnSeriesMap
+	b := newBarBuilder(symbol, exchangeIDs)
+	b.add(ticks)
+	return b.columnSeriesMap()
+}
 
-	if len(ticks) == 0 {
-		return csm
-	}
+// barBuilder aggregates the trades of a day to the 1Min bars, fed in order
+// in any number of calls to add.
+type barBuilder struct {
+	symbol      string
+	exchangeIDs []int
+	ticks       int
+
+	// the bar being aggregated
+	epoch                   int64
+	open, high, low, close_ float32
+	volume, tickCnt         int32
+	lastBucketTimestamp     time.Time
+
+	// the completed bars
+	epochs   []int64
+	opens    []float32
+	highs    []float32
+	lows     []float32
+	closes   []float32
+	volumes  []int32
+	tickCnts []int32
+}
 
-	var epoch int64
-	var open, high, low, close_ float32
-	var volume, tickCnt int32
-
-	epochs := make([]int64, 1440)
-	opens := make([]float32, 1440)
-	highs := make([]float32, 1440)
-	lows := make([]float32, 1440)
-	closes := make([]float32, 1440)
-	volumes := make([]int32, 1440)
-	tickCnts := make([]int32, 1440)
-
-	barIdx := 0
-	lastBucketTimestamp := time.Time{}
-
-	storeAggregates := func() {
-		// Store the minute aggregate
-		epochs[barIdx] = epoch
-		opens[barIdx] = open
-		highs[barIdx] = high
-		lows[barIdx] = low
-		closes[barIdx] = close_
-		volumes[barIdx] = volume
-		tickCnts[barIdx] = tickCnt
-
-		barIdx++
+func newBarBuilder(symbol string, exchangeIDs []int) *barBuilder {
+	return &barBuilder{
+		symbol:      symbol,
+		exchangeIDs: exchangeIDs,
+		epochs:      make([]int64, 0, 1440),
+		opens:       make([]float32, 0, 1440),
+		highs:       make([]float32, 0, 1440),
+		lows:        make([]float32, 0, 1440),
+		closes:      make([]float32, 0, 1440),
+		volumes:     make([]int32, 0, 1440),
+		tickCnts:    make([]int32, 0, 1440),
 	}
+}
+
+// storeAggregates stores the minute aggregate
+func (b *barBuilder) storeAggregates() {
+	b.epochs = append(b.epochs, b.epoch)
+	b.opens = append(b.opens, b.open)
+	b.highs = append(b.highs, b.high)
+	b.lows = append(b.lows, b.low)
+	b.closes = append(b.closes, b.close_)
+	b.volumes = append(b.volumes, b.volume)
+	b.tickCnts = append(b.tickCnts, b.tickCnt)
+}
+
+// FIXME: The daily close bars are not handled correctly:
+// We are aggregating from ticks to minutes then from minutes to daily prices.
+// The current routine correctly aggregates ticks to minutes.
+// The daily close price however should be the tick set with conditions
+// 'Closing Prints' & 'Trade Thru Exempt' (8 & 15), generally sent 2-5 minutes
+// after the official market close time. Given the daily roll-up is using minute data,
+// the close tick will be aggregated  and impossible to extract from the minutely bar.
+// In order to solve this, the daily close price should explicitly be stored and used
+// in the daily roll-up calculation. This would require substantial refactor.
+// The current solution therefore is just a reasonable approximation of the daily close price.
+func (b *barBuilder) add(ticks []api.TradeTick) {
+	b.ticks += len(ticks)
 
-	// FIXME: The daily close bars are not handled correctly:
-	// We are aggregating from ticks to minutes then from minutes to daily prices.
-	// The current routine correctly aggregates ticks to minutes.
-	// The daily close price however should be the tick set with conditions
-	// 'Closing Prints' & 'Trade Thru Exempt' (8 & 15), generally sent 2-5 minutes
-	// after the official market close time. Given the daily roll-up is using minute data,
-	// the close tick will be aggregated  and impossible to extract from the minutely bar.
-	// In order to solve this, the daily close price should explicitly be stored and used
-	// in the daily roll-up calculation. This would require substantial refactor.
-	// The current solution therefore is just a reasonable approximation of the daily close price.
 	for _, tick := range ticks {
-		if !intInSlice(tick.Exchange, exchangeIDs) {
+		if !intInSlice(tick.Exchange, b.exchangeIDs) {
 			continue
 		}
 
@@ -232,27 +345,27 @@ func tradesToBars(ticks []api.TradeTick, symbol string, exchangeIDs []int) io.Co
 		timestamp := time.Unix(0, tick.SipTimestamp)
 		bucketTimestamp := timestamp.Truncate(time.Minute)
 
-		if bucketTimestamp.Before(lastBucketTimestamp) {
-			log.Warn("[polygon] got an out-of-order tick for %v @ %v, skipping", symbol, timestamp)
+		if bucketTimestamp.Before(b.lastBucketTimestamp) {
+			log.Warn("[polygon] got an out-of-order tick for %v @ %v, skipping", b.symbol, timestamp)
 			continue
 		}
 
-		if !lastBucketTimestamp.Equal(bucketTimestamp) {
-			if open != 0 && volume != 0 {
-				storeAggregates()
+		if !b.lastBucketTimestamp.Equal(bucketTimestamp) {
+			if b.open != 0 && b.volume != 0 {
+				b.storeAggregates()
 			}
 
-			lastBucketTimestamp = bucketTimestamp
-			epoch = bucketTimestamp.Unix()
-			open = 0
-			high = 0
-			low = math.MaxFloat32
-			close_ = 0
-			volume = 0
-			tickCnt = 0
+			b.lastBucketTimestamp = bucketTimestamp
+			b.epoch = bucketTimestamp.Unix()
+			b.open = 0
+			b.high = 0
+			b.low = math.MaxFloat32
+			b.close_ = 0
+			b.volume = 0
+			b.tickCnt = 0
 		}
 
-		tickCnt += 1
+		b.tickCnt += 1
 
 		updateInfo := conditionToUpdateInfo(tick)
 
@@ -261,63 +374,77 @@ func tradesToBars(ticks []api.TradeTick, symbol string, exchangeIDs []int) io.Co
 		}
 
 		if updateInfo.UpdateHighLow {
-			if high < price {
-				high = price
+			if b.high < price {
+				b.high = price
 			}
-			if low > price {
-				low = price
+			if b.low > price {
+				b.low = price
 			}
 		}
 
 		if updateInfo.UpdateLast {
-			if open == 0 {
-				open = price
+			if b.open == 0 {
+				b.open = price
 			}
-			close_ = price
+			b.close_ = price
 		}
 
 		if updateInfo.UpdateVolume {
-			volume += int32(tick.Size)
+			b.volume += int32(tick.Size)
 		}
 	}
+}
+
+// columnSeriesMap returns the bars of the trades added, nil if none was.
+func (b *barBuilder) columnSeriesMap() io.ColumnSeriesMap {
+	var csm io.ColumnSeriesMap
+
+	if b.ticks == 0 {
+		return csm
+	}
 
-	if open != 0 && volume != 0 {
-		storeAggregates()
+	if b.open != 0 && b.volume != 0 {
+		b.storeAggregates()
+		// not storing the last bar again on another call
+		b.open = 0
 	}
 
 	cs := io.NewColumnSeries()
-	cs.AddColumn("Epoch", epochs[:barIdx])
-	cs.AddColumn("Open", opens[:barIdx])
-	cs.AddColumn("High", highs[:barIdx])
-	cs.AddColumn("Low", lows[:barIdx])
-	cs.AddColumn("Close", closes[:barIdx])
-	cs.AddColumn("Volume", volumes[:barIdx])
-	cs.AddColumn("TickCnt", tickCnts[:barIdx])
+	cs.AddColumn("Epoch", b.epochs)
+	cs.AddColumn("Open", b.opens)
+	cs.AddColumn("High", b.highs)
+	cs.AddColumn("Low", b.lows)
+	cs.AddColumn("Close", b.closes)
+	cs.AddColumn("Volume", b.volumes)
+	cs.AddColumn("TickCnt", b.tickCnts)
 
 	csm = io.NewColumnSeriesMap()
-	tbk := io.NewTimeBucketKeyFromString(symbol + "/1Min/OHLCV")
+	tbk := io.NewTimeBucketKeyFromString(b.symbol + "/1Min/OHLCV")
 	csm.AddColumnSeries(*tbk, cs)
 
 	return csm
 }
 
-func Trades(symbol string, date time.Time, batchSize int) error {
-	resp, err := api.GetHistoricTrades(symbol, date.Format(defaultFormat), batchSize)
-	if err != nil {
-		return err
-	}
+// Trades backfills the trades of the date before the until time, e.g. the
+// session close, or all of them if it is zero, writing each page of
+// batchSize trades as soon as it is downloaded so that only one is held in
+// memory.
+func Trades(symbol string, date, until time.Time, batchSize int) error {
+	return streamTrades(symbol, date, batchSize, func(ticks []api.TradeTick) error {
+		if ticks = tradesBefore(ticks, until); len(ticks) == 0 {
+			return nil
+		}
 
-	if len(resp.Results) > 0 {
 		csm := io.NewColumnSeriesMap()
 		tbk := io.NewTimeBucketKeyFromString(symbol + "/1Min/TRADE")
 		cs := io.NewColumnSeries()
 
-		epoch := make([]int64, len(resp.Results))
-		nanos := make([]int32, len(resp.Results))
-		price := make([]float32, len(resp.Results))
-		size := make([]int32, len(resp.Results))
+		epoch := make([]int64, len(ticks))
+		nanos := make([]int32, len(ticks))
+		price := make([]float32, len(ticks))
+		size := make([]int32, len(ticks))
 
-		for i, tick := range resp.Results {
+		for i, tick := range ticks {
 			timestamp := time.Unix(0, tick.SipTimestamp)
 			bucketTimestamp := timestamp.Truncate(time.Minute)
 
@@ -333,14 +460,26 @@ func Trades(symbol string, date time.Time, batchSize int) error {
 		cs.AddColumn("Size", size)
 		csm.AddColumnSeries(*tbk, cs)
 
-		if err = executor.WriteCSM(csm, true); err != nil {
-			return err
+		return write(csm, true)
+	})
+}
+
+// tradesBefore returns the trades before until, or all of them if it is zero.
+func tradesBefore(ticks []api.TradeTick, until time.Time) []api.TradeTick {
+	if until.IsZero() {
+		return ticks
+	}
+	before := ticks[:0:0]
+	for _, tick := range ticks {
+		if tick.SipTimestamp < until.UnixNano() {
+			before = append(before, tick)
 		}
 	}
-
-	return nil
+	return before
 }
 
+// Quotes backfills the quotes of the days from the from time, up to but
+// excluding the to time, e.g. the session close.
 func Quotes(symbol string, from, to time.Time, batchSize int) error {
 	// FIXME: This function is broken with the following problems:
 	//  - Callee (backfiller.go) wrongly checks the market day (checks for the day after)
@@ -372,11 +511,15 @@ func Quotes(symbol string, from, to time.Time, batchSize int) error {
 	)
 
 	for {
-		if resp, err = api.GetHistoricQuotes(symbol, from.Format(defaultFormat), batchSize); err != nil {
+		start := time.Now()
+		resp, err = api.GetHistoricQuotes(symbol, from.Format(defaultFormat), batchSize)
+		timeAPICall(symbol, start)
+		if err != nil {
 			return err
 		}
 
-		if len(resp.Ticks) > 0 {
+		if ticks := quotesBefore(resp.Ticks, to); len(ticks) > 0 {
+			resp.Ticks = ticks
 			csm = io.NewColumnSeriesMap()
 			tbk = io.NewTimeBucketKeyFromString(symbol + "/1Min/QUOTE")
 			cs = io.NewColumnSeries()
@@ -407,7 +550,7 @@ func Quotes(symbol string, from, to time.Time, batchSize int) error {
 			cs.AddColumn("AskSize", askSize)
 			csm.AddColumnSeries(*tbk, cs)
 
-			if err = executor.WriteCSM(csm, true); err != nil {
+			if err = write(csm, true); err != nil {
 				return err
 			}
 		}
@@ -421,3 +564,14 @@ func Quotes(symbol string, from, to time.Time, batchSize int) error {
 
 	return nil
 }
+
+// quotesBefore returns the quotes before to.
+func quotesBefore(ticks []api.QuoteTick, to time.Time) []api.QuoteTick {
+	before := ticks[:0:0]
+	for _, tick := range ticks {
+		if time.Unix(0, 1000*1000*tick.Timestamp).Before(to) {
+			before = append(before, tick)
+		}
+	}
+	return before
+}
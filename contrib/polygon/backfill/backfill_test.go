@@ -1,9 +1,11 @@
 package backfill
 
 import (
+	"math"
 	"testing"
 	"time"
 
+	"github.com/alpacahq/marketstore/v4/contrib/calendar"
 	"github.com/alpacahq/marketstore/v4/contrib/polygon/api"
 	"github.com/alpacahq/marketstore/v4/utils/io"
 
@@ -204,3 +206,171 @@ func (s *BackfillTests) TestTicksToBars(c *C) {
 
 	}
 }
+
+func (s *BackfillTests) TestTicksBeforeEarlyClose(c *C) {
+	NY, _ := time.LoadLocation("America/New_York")
+	// the day after Thanksgiving closes at 13:00
+	blackFriday := time.Date(2019, 11, 29, 0, 0, 0, 0, NY)
+	sessionClose := calendar.Nasdaq.MarketClose(blackFriday)
+	times := []time.Time{
+		time.Date(2019, 11, 29, 12, 59, 59, 0, NY),
+		time.Date(2019, 11, 29, 13, 0, 0, 0, NY),
+		time.Date(2019, 11, 29, 15, 30, 0, 0, NY),
+	}
+	var trades []api.TradeTick
+	var quotes []api.QuoteTick
+	for _, t := range times {
+		trades = append(trades, api.TradeTick{SipTimestamp: t.UnixNano()})
+		quotes = append(quotes, api.QuoteTick{Timestamp: t.UnixNano() / int64(time.Millisecond)})
+	}
+
+	// the ticks after the session close are dropped
+	c.Assert(tradesBefore(trades, sessionClose), DeepEquals, trades[:1])
+	c.Assert(quotesBefore(quotes, sessionClose), DeepEquals, quotes[:1])
+
+	// all kept without a close
+	c.Assert(tradesBefore(trades, time.Time{}), HasLen, 3)
+
+	// and the after hours kept up to the end of a normal day
+	day := time.Date(2019, 11, 26, 0, 0, 0, 0, NY)
+	afterHours := time.Date(2019, 11, 26, 17, 30, 0, 0, NY)
+	trades = []api.TradeTick{{SipTimestamp: afterHours.UnixNano()}}
+	quotes = []api.QuoteTick{{Timestamp: afterHours.UnixNano() / int64(time.Millisecond)}}
+	c.Assert(tradesBefore(trades, day.Add(24*time.Hour)), DeepEquals, trades)
+	c.Assert(quotesBefore(quotes, day.Add(24*time.Hour)), DeepEquals, quotes)
+}
+
+func (s *BackfillTests) TestBarBuilderPages(c *C) {
+	NY, _ := time.LoadLocation("America/New_York")
+	var ticks []api.TradeTick
+	for i, price := range []float64{300, 301, 299, 302, 303, 298} {
+		ticks = append(ticks, api.TradeTick{
+			SipTimestamp: time.Date(2020, 1, 21, 9, 30, 20*i, 0, NY).UnixNano(),
+			Price:        price,
+			Size:         100,
+			Exchange:     9,
+		})
+	}
+	key := io.NewTimeBucketKeyFromString("AAPL/1Min/OHLCV")
+	whole := tradesToBars(ticks, "AAPL", []int{9})
+
+	// the bars of the trades added in pages, split within a minute,
+	// are those of the trades added at once
+	b := newBarBuilder("AAPL", []int{9})
+	b.add(ticks[:2])
+	b.add(ticks[2:5])
+	b.add(ticks[5:])
+	paged := b.columnSeriesMap()
+	c.Assert(paged[*key].Len(), Equals, 2)
+	for _, name := range []string{"Epoch", "Open", "High", "Low", "Close", "Volume", "TickCnt"} {
+		c.Assert(paged[*key].GetColumn(name), DeepEquals, whole[*key].GetColumn(name))
+	}
+
+	// and no bars are returned without any trade
+	c.Assert(newBarBuilder("AAPL", []int{9}).columnSeriesMap(), IsNil)
+}
+
+func (s *BackfillTests) TestAdjustSplit(c *C) {
+	NY, _ := time.LoadLocation("America/New_York")
+
+	// Given AAPL bars around its 4:1 split on 2020-08-31
+	actions := []CorporateAction{
+		SplitAction(time.Date(2020, 8, 31, 0, 0, 0, 0, NY), 1, 4),
+	}
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{
+		time.Date(2020, 8, 28, 15, 59, 0, 0, NY).Unix(),
+		time.Date(2020, 8, 31, 9, 30, 0, 0, NY).Unix(),
+	})
+	cs.AddColumn("Open", []float32{498.00, 127.58})
+	cs.AddColumn("High", []float32{500.00, 128.00})
+	cs.AddColumn("Low", []float32{496.00, 127.00})
+	cs.AddColumn("Close", []float32{499.24, 127.50})
+	cs.AddColumn("Volume", []int32{1000, 8000})
+
+	// When we adjust them
+	adjusted, err := Adjust(cs, actions)
+
+	// Then the pre-split prices are divided by 4 and the volume multiplied by 4,
+	// and the post-split bar is left as is
+	c.Assert(err, IsNil)
+	c.Assert(adjusted.GetEpoch(), DeepEquals, cs.GetEpoch())
+	c.Assert(adjusted.GetByName("Open"), DeepEquals, []float32{124.5, 127.58})
+	c.Assert(adjusted.GetByName("High"), DeepEquals, []float32{125, 128})
+	c.Assert(adjusted.GetByName("Low"), DeepEquals, []float32{124, 127})
+	c.Assert(adjusted.GetByName("Close").([]float32)[0], Equals, float32(124.81))
+	c.Assert(adjusted.GetByName("Close").([]float32)[1], Equals, float32(127.50))
+	c.Assert(adjusted.GetByName("Volume"), DeepEquals, []int32{4000, 8000})
+}
+
+func (s *BackfillTests) TestAdjustMultipleActions(c *C) {
+	NY, _ := time.LoadLocation("America/New_York")
+
+	// Given a 5:1 split, a dividend and a 3:1 split
+	actions := []CorporateAction{
+		SplitAction(time.Date(2020, 8, 31, 0, 0, 0, 0, NY), 1, 5),
+		DividendAction(time.Date(2021, 3, 1, 0, 0, 0, 0, NY), 1, 100),
+		SplitAction(time.Date(2022, 8, 25, 0, 0, 0, 0, NY), 1, 3),
+	}
+	epochs := []int64{
+		time.Date(2020, 8, 28, 10, 0, 0, 0, NY).Unix(),
+		time.Date(2021, 1, 4, 10, 0, 0, 0, NY).Unix(),
+		time.Date(2022, 1, 3, 10, 0, 0, 0, NY).Unix(),
+		time.Date(2022, 8, 25, 10, 0, 0, 0, NY).Unix(),
+	}
+
+	// When we compute the adjustment factors
+	price, volume := AdjustmentFactors(epochs, actions)
+
+	// Then each bar is adjusted by all the actions after it
+	expected := []float64{0.99 / 15, 0.99 / 3, 1.0 / 3, 1}
+	for i := range expected {
+		c.Assert(math.Abs(price[i]-expected[i]) < 1e-12, Equals, true)
+	}
+	c.Assert(volume, DeepEquals, []float64{15, 3, 3, 1})
+}
+
+func (s *BackfillTests) TestAdjustWithoutActions(c *C) {
+	// Given a symbol without corporate actions
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{1577979000, 1577979060})
+	cs.AddColumn("Open", []float32{300.35, 300.1})
+	cs.AddColumn("High", []float32{300.6, 300.2})
+	cs.AddColumn("Low", []float32{300.1, 299.95})
+	cs.AddColumn("Close", []float32{300.12, 300.01})
+	cs.AddColumn("Volume", []int32{1234, 567})
+	cs.AddColumn("TickCnt", []int32{12, 5})
+
+	// When we adjust the bars
+	adjusted, err := Adjust(cs, nil)
+
+	// Then the adjusted series is identical to the raw one
+	c.Assert(err, IsNil)
+	c.Assert(adjusted.GetColumnNames(), DeepEquals, cs.GetColumnNames())
+	for _, name := range cs.GetColumnNames() {
+		c.Assert(adjusted.GetByName(name), DeepEquals, cs.GetByName(name))
+	}
+}
+
+func (s *BackfillTests) TestBarsBetween(c *C) {
+	key := io.NewTimeBucketKeyFromString("AAPL/1Min/OHLCV")
+	from := time.Date(2020, 1, 21, 14, 30, 0, 0, time.UTC)
+	epochs := []int64{}
+	for i := -1; i < 4; i++ {
+		epochs = append(epochs, from.Add(time.Duration(i)*time.Minute).Unix())
+	}
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epochs)
+	cs.AddColumn("Close", []float32{1, 2, 3, 4, 5})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*key, cs)
+
+	// the last written bar and the first streamed one are excluded
+	out := barsBetween(csm, from, from.Add(2*time.Minute))
+	c.Assert(out[*key].GetEpoch(), DeepEquals, epochs[1:3])
+	c.Assert(out[*key].GetColumn("Close").([]float32), DeepEquals, []float32{2, 3})
+
+	// no gap
+	out = barsBetween(csm, from, from)
+	c.Assert(out.IsEmpty(), Equals, true)
+}
@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
-	"github.com/gobwas/glob"
+	"io/ioutil"
+	"os"
+	"os/signal"
 	"runtime"
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"code.cloudfoundry.org/bytefmt"
@@ -16,6 +20,7 @@ import (
 	"github.com/alpacahq/marketstore/v4/contrib/polygon/api"
 	"github.com/alpacahq/marketstore/v4/contrib/polygon/backfill"
 	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/plugins"
 	"github.com/alpacahq/marketstore/v4/plugins/trigger"
 	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/log"
@@ -24,11 +29,32 @@ import (
 var (
 	dir, from, to        string
 	bars, quotes, trades bool
+	adjusted             bool
 	symbols              string
+	symbolFile           string
+	symbolMatch          string
+	validateSymbols      bool
 	parallelism          int
 	apiKey               string
 	exchanges            string
 	batchSize            int
+	exchangeCalendar     string
+	calendarFile         string
+	aggregates           string
+	aggFilter            string
+	triggersConfig       string
+	weekStart            string
+	drainTimeout         time.Duration
+	tickersCache         string
+	tickersCacheTTL      time.Duration
+	refreshTickers       bool
+	checkpointPath       string
+	maxMemory            string
+	rateLimit            int
+	slowest              int
+	createRoot           bool
+	server               string
+	serverTimeout        time.Duration
 
 	// NY timezone
 	NY, _  = time.LoadLocation("America/New_York")
@@ -43,18 +69,66 @@ func init() {
 	flag.BoolVar(&bars, "bars", false, "backfill bars")
 	flag.BoolVar(&quotes, "quotes", false, "backfill quotes")
 	flag.BoolVar(&trades, "trades", false, "backfill trades")
+	flag.BoolVar(&adjusted, "adjusted", false,
+		"also write bars adjusted for splits and dividends to {symbol}/1Min/"+backfill.AdjustedAttributeGroup)
 	flag.StringVar(&symbols, "symbols", "*",
-		"glob pattern of symbols to backfill, the default * means backfill all symbols")
+		"pattern of symbols to backfill, the default * means backfill all symbols")
+	flag.StringVar(&symbolMatch, "symbol-match", "glob",
+		"syntax of the symbols pattern, glob or regex, a regex having to match the whole symbol")
+	flag.StringVar(&symbolFile, "symbol-file", "",
+		"file listing the symbols to backfill one per line, with # comments, instead of the symbols pattern")
+	flag.BoolVar(&validateSymbols, "validate-symbols", true,
+		"skip the symbols of the symbol-file not in the ticker list, or backfill them without listing the tickers if false")
 	flag.IntVar(&parallelism, "parallelism", runtime.NumCPU(), "parallelism (default NumCPU)")
-	flag.IntVar(&batchSize, "batchSize", 50000, "batch/pagination size for downloading trades & quotes")
+	flag.IntVar(&batchSize, "batchSize", 50000, "batch/pagination size for downloading bars, trades & quotes")
 	flag.StringVar(&apiKey, "apiKey", "", "polygon API key")
+	flag.StringVar(&exchangeCalendar, "exchange-calendar", "nasdaq",
+		"market calendar deciding the days to backfill ("+strings.Join(calendar.Names(), ", ")+")")
+	flag.StringVar(&aggregates, "aggregates", "5Min,15Min,1H,1D",
+		"comma separated list of timeframes the 1Min bars are aggregated to")
+	flag.StringVar(&weekStart, "week-start", "monday", "the day weekly aggregates begin on")
+	flag.StringVar(&aggFilter, "aggregates-filter", "nasdaq",
+		"market calendar filtering the daily aggregates by its market hours, or none to aggregate the whole day ("+
+			strings.Join(calendar.Names(), ", ")+", none)")
+	flag.StringVar(&triggersConfig, "triggers-config", "",
+		"YAML file (e.g. the mkts.yml of the server) whose triggers block replaces the ondiskagg trigger of the aggregates flags")
+	flag.StringVar(&calendarFile, "calendar-file", "",
+		"YAML or CSV file with holidays and early closes overriding the exchange calendar")
+	flag.DurationVar(&drainTimeout, "drain-timeout", 10*time.Minute,
+		"maximum time to wait for the ondiskagg triggers to complete after backfilling")
+	flag.StringVar(&tickersCache, "tickers-cache", "",
+		"file caching the full ticker list (default {dir}/polygon-tickers.json)")
+	flag.DurationVar(&tickersCacheTTL, "tickers-cache-ttl", 24*time.Hour,
+		"maximum age of the cached ticker and exchange lists, 0 to always list them")
+	flag.BoolVar(&refreshTickers, "refresh-tickers", false, "list the tickers and exchanges again even if they are cached")
+	flag.StringVar(&checkpointPath, "checkpoint", "",
+		"file recording the completed work, skipped when the backfill is run again with the same flags after an interruption")
+	flag.StringVar(&maxMemory, "max-memory", "",
+		"approximate memory limit (e.g. 8G) lowering the parallelism to stay under it, unlimited if empty")
+	flag.IntVar(&rateLimit, "rate-limit", envInt("POLYGON_RATE_LIMIT", 0),
+		"requests per minute shared by all the goroutines, under the limit of the Polygon plan, unlimited if 0 (env POLYGON_RATE_LIMIT)")
+	flag.IntVar(&slowest, "slowest", 10, "number of the slowest symbols to report at the end")
+	flag.BoolVar(&createRoot, "create-root", false, "create the mktsdb directory under dir if it does not exist")
+	flag.StringVar(&server, "server", "",
+		"host:port of the gRPC API of a running server to write through, whose triggers build the aggregates, instead of opening the mktsdb directory under dir")
+	flag.DurationVar(&serverTimeout, "server-timeout", time.Minute, "timeout of a write through the server")
+}
 
-	flag.Parse()
+// envInt returns the integer of the environment variable, or def if unset
+// or invalid.
+func envInt(name string, def int) int {
+	if v, err := strconv.Atoi(os.Getenv(name)); err == nil {
+		return v
+	}
+	return def
 }
 
 func main() {
-	// free memory in the background every 1 minute for long running
-	// backfills with very high parallelism
+	flag.Parse()
+
+	// the memory is bounded by the max-memory budget and by writing the
+	// trades page by page, freeing it in the background every 1 minute is
+	// only a fallback returning it to the OS in long running backfills
 	go func() {
 		for {
 			<-time.After(time.Minute)
@@ -74,6 +148,12 @@ func main() {
 		}
 	}()
 
+	// the pattern is checked before anything is set up
+	var pattern symbolMatcher
+	if symbolFile == "" {
+		pattern = compileSymbolsFlag(symbolMatch, symbols, os.Exit)
+	}
+
 	initWriter()
 
 	if apiKey == "" {
@@ -81,6 +161,27 @@ func main() {
 	}
 
 	api.SetAPIKey(apiKey)
+	if rateLimit < 0 {
+		log.Fatal("[polygon] invalid rate-limit %v", rateLimit)
+	}
+	api.SetRateLimit(rateLimit)
+
+	cal, err := calendar.Get(exchangeCalendar)
+	if err != nil {
+		log.Fatal("[polygon] %v", err)
+	}
+
+	if calendarFile != "" {
+		overrides, err := calendar.LoadOverrides(calendarFile)
+		if err != nil {
+			log.Fatal("[polygon] %v", err)
+		}
+		builtin, ok := cal.(*calendar.Calendar)
+		if !ok {
+			log.Fatal("[polygon] calendar %v does not support overrides", exchangeCalendar)
+		}
+		cal = builtin.WithOverrides(overrides)
+	}
 
 	start, err := time.Parse(format, from)
 	if err != nil {
@@ -92,21 +193,9 @@ func main() {
 		log.Fatal("[polygon] failed to parse to timestamp (%v)", err)
 	}
 
-	var symbolList []string
-	log.Info("[polygon] listing symbols for pattern: %v", symbols)
-	pattern := glob.MustCompile(symbols)
-	resp, err := api.ListTickers()
-	if err != nil {
-		log.Fatal("[polygon] failed to list symbols (%v)", err)
+	if tickersCache == "" {
+		tickersCache = fmt.Sprintf("%v/polygon-tickers.json", dir)
 	}
-	log.Info("[polygon] %v symbols available", len(resp.Tickers))
-	symbolList = make([]string, 1)
-	for _, s := range resp.Tickers {
-		if pattern.Match(s.Ticker) {
-			symbolList = append(symbolList, s.Ticker)
-		}
-	}
-	log.Info("[polygon] selected %v symbols", len(symbolList))
 
 	var exchangeIDs []int
 	if exchanges != "*" {
@@ -119,35 +208,171 @@ func main() {
 			exchangeIDs = append(exchangeIDs, exchangeIDInt)
 		}
 	}
+	if len(exchangeIDs) > 0 {
+		exchangeList, err := api.ListExchangesCached(fmt.Sprintf("%v/polygon-exchanges.json", dir),
+			tickersCacheTTL, refreshTickers)
+		if err != nil {
+			log.Fatal("[polygon] failed to list exchanges (%v)", err)
+		}
+		names, err := exchangeNames(exchangeIDs, exchangeList)
+		if err != nil {
+			log.Fatal("[polygon] %v", err)
+		}
+		log.Info("[polygon] building the bars from the trades of the exchanges %v", strings.Join(names, ", "))
+	}
+
+	var symbolList []string
+	if symbolFile != "" {
+		if symbolList, err = readSymbolFile(symbolFile); err != nil {
+			log.Fatal("[polygon] failed to read the symbol file (%v)", err)
+		}
+		log.Info("[polygon] %v symbols read from %v", len(symbolList), symbolFile)
+		if validateSymbols {
+			resp, err := api.ListTickersCached(tickersCache, tickersCacheTTL, refreshTickers)
+			if err != nil {
+				log.Fatal("[polygon] failed to list symbols (%v)", err)
+			}
+			var unknown []string
+			symbolList, unknown = selectListedSymbols(resp, symbolList)
+			for _, symbol := range unknown {
+				log.Warn("[polygon] skipping the unknown symbol %v", symbol)
+			}
+		}
+	} else {
+		log.Info("[polygon] listing symbols for %v pattern: %v", symbolMatch, symbols)
+		resp, err := api.ListTickersCached(tickersCache, tickersCacheTTL, refreshTickers)
+		if err != nil {
+			log.Fatal("[polygon] failed to list symbols (%v)", err)
+		}
+		log.Info("[polygon] %v symbols available", len(resp.Tickers))
+		symbolList = selectSymbols(resp, pattern)
+	}
+	// sorted so that the runs process the symbols in the same order
+	symbolList = sortSymbols(symbolList)
+	log.Info("[polygon] selected %v symbols", len(symbolList))
+
+	// the backfill work is split into (symbol x market day x data type) units
+	marketDays := int64(0)
+	for d := start; end.After(d); d = d.Add(24 * time.Hour) {
+		if cal.IsMarketDay(d) {
+			marketDays++
+		}
+	}
+	dataTypes := int64(0)
+	for _, enabled := range []bool{bars, quotes, trades} {
+		if enabled {
+			dataTypes++
+		}
+	}
+	prog := newProgress(int64(len(symbolList)) * marketDays * dataTypes)
+	go prog.report(time.Minute)
+
+	ckpt, err := loadCheckpoint(checkpointPath)
+	if err != nil {
+		log.Fatal("[polygon] failed to read the checkpoint %v (%v)", checkpointPath, err)
+	}
+	// resumed reports whether the unit was completed by a previous run
+	resumed := func(unit string) bool {
+		if ckpt.isDone(unit) {
+			prog.resume(1)
+			return true
+		}
+		return false
+	}
+	// finish marks the unit as completed, to be skipped when resumed
+	finish := func(unit string, err error) {
+		if err == nil {
+			ckpt.markDone(unit)
+		}
+		prog.finish(err)
+	}
+
+	// no more work is dispatched once the backfill is interrupted
+	stopping := make(chan struct{})
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go handleInterrupts(signals, func() { close(stopping) }, os.Exit)
+	interrupted := func() bool {
+		select {
+		case <-stopping:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var memoryLimit uint64
+	if maxMemory != "" {
+		if memoryLimit, err = bytefmt.ToBytes(maxMemory); err != nil {
+			log.Fatal("[polygon] invalid max-memory %v (%v)", maxMemory, err)
+		}
+	}
+	budget := newMemoryBudget(memoryLimit)
+	barsMemory := barTaskMemory()
+	if len(exchangeIDs) > 0 {
+		// built from the trades
+		barsMemory = tickTaskMemory(batchSize)
+	}
+	ticksMemory := tickTaskMemory(batchSize)
 
 	sem := make(chan struct{}, parallelism)
+	times := newTaskTimes()
 
 	if bars {
 		log.Info("[polygon] backfilling bars from %v to %v", start, end)
 
 		for _, sym := range symbolList {
+			if interrupted() {
+				break
+			}
+			sym := sym // used by the goroutines
 			s := start
 			e := end
 
 			log.Info("[polygon] backfilling bars for %v", sym)
 
-			for e.After(s) {
-				if calendar.Nasdaq.IsMarketDay(s) {
+			var actions []backfill.CorporateAction
+			if adjusted {
+				if actions, err = backfill.GetCorporateActions(sym); err != nil {
+					log.Warn("[polygon] failed to get corporate actions for %v, skipping (%v)", sym, err)
+					prog.skip(marketDays)
+					continue
+				}
+				log.Info("[polygon] %v corporate actions found for %v", len(actions), sym)
+			}
+
+			for e.After(s) && !interrupted() {
+				if cal.IsMarketDay(s) && !resumed(unitKey("bars", sym, s)) {
 					log.Info("[polygon] backfilling bars for %v on %v", sym, s)
 
 					sem <- struct{}{}
+					budget.acquire(barsMemory)
 					go func(t time.Time) {
 						defer func() { <-sem }()
+						defer budget.release(barsMemory)
+						defer times.start("bars", sym)()
 
+						var err error
 						if len(exchangeIDs) == 0 {
-							if err = backfill.Bars(sym, t, t.Add(24*time.Hour)); err != nil {
+							if adjusted {
+								err = backfill.AdjustedBars(sym, t, t.Add(24*time.Hour), batchSize, actions)
+							} else {
+								err = backfill.Bars(sym, t, t.Add(24*time.Hour), batchSize)
+							}
+							if err != nil {
 								log.Warn("[polygon] failed to backfill bars for %v (%v)", sym, err)
 							}
 						} else {
-							if err = backfill.BuildBarsFromTrades(sym, t, exchangeIDs, batchSize); err != nil {
+							if adjusted {
+								err = backfill.BuildAdjustedBarsFromTrades(sym, t, exchangeIDs, batchSize, actions)
+							} else {
+								err = backfill.BuildBarsFromTrades(sym, t, exchangeIDs, batchSize)
+							}
+							if err != nil {
 								log.Warn("[polygon] failed to backfill bars for %v @ %v (%v)", sym, t, err)
 							}
 						}
+						finish(unitKey("bars", sym, t), err)
 					}(s)
 				}
 				s = s.Add(24 * time.Hour)
@@ -159,22 +384,31 @@ func main() {
 		log.Info("[polygon] backfilling quotes from %v to %v", start, end)
 
 		for _, sym := range symbolList {
+			if interrupted() {
+				break
+			}
+			sym := sym // used by the goroutines
 			s := start
 			e := end
 
 			log.Info("[polygon] backfilling quotes for %v", sym)
 
-			for e.After(s) {
-				if calendar.Nasdaq.IsMarketDay(s) {
+			for e.After(s) && !interrupted() {
+				if cal.IsMarketDay(s) && !resumed(unitKey("quotes", sym, s)) {
 					log.Info("[polygon] backfilling quotes for %v on %v", sym, s)
 
 					sem <- struct{}{}
+					budget.acquire(ticksMemory)
 					go func(t time.Time) {
 						defer func() { <-sem }()
+						defer budget.release(ticksMemory)
+						defer times.start("quotes", sym)()
 
-						if err = backfill.Quotes(sym, t, t.Add(24*time.Hour), batchSize); err != nil {
+						err := backfill.Quotes(sym, t, ticksEnd(cal, t), batchSize)
+						if err != nil {
 							log.Warn("[polygon] failed to backfill quotes for %v (%v)", sym, err)
 						}
+						finish(unitKey("quotes", sym, t), err)
 					}(s)
 				}
 				s = s.Add(24 * time.Hour)
@@ -186,24 +420,33 @@ func main() {
 		log.Info("[polygon] backfilling trades from %v to %v", start, end)
 
 		for _, sym := range symbolList {
+			if interrupted() {
+				break
+			}
+			sym := sym // used by the goroutines
 			s := start
 			e := end
 
 			log.Info("[polygon] backfilling trades for %v", sym)
 
-			for e.After(s) {
+			for e.After(s) && !interrupted() {
 				log.Info("Checking %v", s)
-				if calendar.Nasdaq.IsMarketDay(s) {
+				if cal.IsMarketDay(s) && !resumed(unitKey("trades", sym, s)) {
 					log.Info("[polygon] backfilling trades for %v on %v", sym, s)
 
 					sem <- struct{}{}
+					budget.acquire(ticksMemory)
 					go func(t time.Time) {
 						defer func() { <-sem }()
+						defer budget.release(ticksMemory)
+						defer times.start("trades", sym)()
 
-						if err = backfill.Trades(sym, t, batchSize); err != nil {
+						err := backfill.Trades(sym, t, ticksEnd(cal, t), batchSize)
+						if err != nil {
 							log.Warn("[polygon] failed to backfill trades for %v @ %v (%v)", sym, t, err)
 						}
-					}(e)
+						finish(unitKey("trades", sym, t), err)
+					}(s)
 				}
 				s = s.Add(24 * time.Hour)
 			}
@@ -215,23 +458,105 @@ func main() {
 		sem <- struct{}{}
 	}
 
-	log.Info("[polygon] backfilling complete")
+	prog.close()
+	logRequestStats(api.GetRateLimitStats())
+	times.report(slowest)
+	if interrupted() {
+		log.Info("[polygon] backfilling interrupted with %v units remaining", prog.remaining())
+	} else {
+		log.Info("[polygon] backfilling complete")
+	}
+
+	if server == "" {
+		log.Info("[polygon] waiting for ondiskagg triggers to complete")
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		defer cancel()
+		if err := executor.ThisInstance.DrainTriggers(ctx); err != nil {
+			log.Warn("[polygon] ondiskagg triggers did not complete within %v (%v)", drainTimeout, err)
+		} else {
+			log.Info("[polygon] ondiskagg triggers complete")
+		}
+
+		// the completed units are recorded once their writes are on disk
+		log.Info("[polygon] flushing the WAL")
+		executor.ThisInstance.ShutdownPending = true
+		executor.ThisInstance.WALWg.Wait()
+	}
+	if err := ckpt.save(); err != nil {
+		log.Error("[polygon] failed to write the checkpoint %v (%v)", checkpointPath, err)
+	}
+}
+
+// ticksEnd returns the end of the trades and quotes backfilled on the day
+// of t, the session close on the early-close days, and the end of the day
+// otherwise, keeping the extended hours.
+func ticksEnd(cal calendar.MarketCalendar, t time.Time) time.Time {
+	if cal.IsEarlyClose(t) {
+		return cal.MarketClose(t)
+	}
+	return t.Add(24 * time.Hour)
+}
+
+// logRequestStats logs the rate of the Polygon requests against the budget.
+func logRequestStats(stats api.RateLimitStats) {
+	if stats.Budget == 0 {
+		log.Info("[polygon] made %v requests (%.1f/min) without a rate limit",
+			stats.Requests, stats.RequestsPerMinute())
+		return
+	}
+	log.Info("[polygon] made %v requests (%.1f/min), %.1f%% of the budget of %v/min, waiting %v for the rate limit",
+		stats.Requests, stats.RequestsPerMinute(), 100*stats.Utilization(), stats.Budget,
+		stats.Waited.Round(time.Second))
+}
 
-	log.Info("[polygon] waiting for 10 more seconds for ondiskagg triggers to complete")
-	time.Sleep(10 * time.Second)
+// selectSymbols returns the tickers matching the pattern, sorted and
+// without duplicates.
+func selectSymbols(resp *api.ListTickersResponse, pattern symbolMatcher) []string {
+	symbolList := make([]string, 0)
+	for _, s := range resp.Tickers {
+		if pattern.Match(s.Ticker) {
+			symbolList = append(symbolList, s.Ticker)
+		}
+	}
+	return sortSymbols(symbolList)
 }
 
 func initWriter() {
 	utils.InstanceConfig.Timezone = NY
 	utils.InstanceConfig.WALRotateInterval = 5
 
-	executor.NewInstanceSetup(
-		fmt.Sprintf("%v/mktsdb", dir),
-		true, true, true, true)
+	// the server owns the files and runs its own triggers
+	if server != "" {
+		w, err := dialServer(server, serverTimeout)
+		if err != nil {
+			log.Fatal("[polygon] failed to connect to the server %v (%v)", server, err)
+		}
+		backfill.SetWriter(w.write)
+		log.Info("[polygon] writing through the server %v", server)
+		return
+	}
+
+	rootDir, err := utils.CheckRootDirectory(fmt.Sprintf("%v/mktsdb", dir), createRoot)
+	if err != nil {
+		log.Fatal("[polygon] %v", err)
+	}
+
+	executor.NewInstanceSetup(rootDir, true, true, true, true)
+
+	if triggersConfig != "" {
+		matchers, err := loadTriggers(triggersConfig)
+		if err != nil {
+			log.Fatal("[polygon] backfill failed to initialize the triggers of %v (%v)", triggersConfig, err)
+		}
+		executor.ThisInstance.TriggerMatchers = matchers
+		return
+	}
 
 	config := map[string]interface{}{
-		"filter":       "nasdaq",
-		"destinations": []string{"5Min", "15Min", "1H", "1D"},
+		"filter":       aggFilter,
+		"destinations": strings.Split(aggregates, ","),
+		"source":       "1Min",
+		"week_start":   weekStart,
 	}
 
 	trig, err := aggtrigger.NewTrigger(config)
@@ -243,3 +568,33 @@ func initWriter() {
 		trigger.NewMatcher(trig, "*/1Min/OHLCV"),
 	}
 }
+
+// loadTriggers returns the matchers of the triggers of the triggers block of
+// the YAML file, loading their modules like the server does.
+func loadTriggers(path string) ([]*trigger.TriggerMatcher, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := utils.ParseTriggers(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var matchers []*trigger.TriggerMatcher
+	for _, ts := range settings {
+		loader, err := plugins.NewSymbolLoader(ts.Module)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open plugin %s: %v", ts.Module, err)
+		}
+		trig, err := trigger.Load(loader, ts.Config)
+		if err != nil {
+			return nil, fmt.Errorf("error returned while creating the trigger %s: %v", ts.Module, err)
+		}
+		matcher := trigger.NewMatcher(trig, ts.On)
+		matcher.Name = ts.Module
+		matchers = append(matchers, matcher)
+		log.Info("[polygon] triggering %s on %s", ts.Module, ts.On)
+	}
+	return matchers, nil
+}
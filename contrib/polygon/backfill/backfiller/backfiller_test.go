@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gobwas/glob"
+
+	"github.com/alpacahq/marketstore/v4/contrib/calendar"
+	"github.com/alpacahq/marketstore/v4/contrib/polygon/api"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+var _ = Suite(&BackfillerTests{})
+
+type BackfillerTests struct{}
+
+func (s *BackfillerTests) TestTicksEnd(c *C) {
+	NY, _ := time.LoadLocation("America/New_York")
+
+	// the whole day, after hours included, on a normal day
+	day := time.Date(2019, 11, 26, 0, 0, 0, 0, NY)
+	c.Assert(ticksEnd(calendar.Nasdaq, day), Equals, day.Add(24*time.Hour))
+
+	// and up to the session close on a half day
+	blackFriday := time.Date(2019, 11, 29, 0, 0, 0, 0, NY)
+	c.Assert(ticksEnd(calendar.Nasdaq, blackFriday).Equal(time.Date(2019, 11, 29, 13, 0, 0, 0, NY)), Equals, true)
+}
+
+func (s *BackfillerTests) TestSelectSymbols(c *C) {
+	resp := &api.ListTickersResponse{}
+	err := json.Unmarshal([]byte(`{"tickers":[{"ticker":"AAPL"},{"ticker":"AMZN"},{"ticker":"SPY"}]}`), resp)
+	c.Assert(err, IsNil)
+
+	c.Assert(selectSymbols(resp, glob.MustCompile("A*")), DeepEquals, []string{"AAPL", "AMZN"})
+	c.Assert(selectSymbols(resp, glob.MustCompile("*")), DeepEquals, []string{"AAPL", "AMZN", "SPY"})
+	c.Assert(selectSymbols(resp, glob.MustCompile("QQQ")), DeepEquals, []string{})
+
+	// sorted and deduplicated whatever the order of the tickers
+	err = json.Unmarshal([]byte(`{"tickers":[{"ticker":"SPY"},{"ticker":"AMZN"},{"ticker":"AAPL"},{"ticker":"SPY"}]}`), resp)
+	c.Assert(err, IsNil)
+	c.Assert(selectSymbols(resp, glob.MustCompile("*")), DeepEquals, []string{"AAPL", "AMZN", "SPY"})
+}
+
+func (s *BackfillerTests) TestCompileSymbolPattern(c *C) {
+	resp := &api.ListTickersResponse{}
+	err := json.Unmarshal([]byte(`{"tickers":[{"ticker":"AAPL"},{"ticker":"BRK.A"},{"ticker":"BRK.B"},{"ticker":"SPY"}]}`), resp)
+	c.Assert(err, IsNil)
+
+	pattern, err := compileSymbolPattern("glob", "BRK.*")
+	c.Assert(err, IsNil)
+	c.Assert(selectSymbols(resp, pattern), DeepEquals, []string{"BRK.A", "BRK.B"})
+
+	// a regex matches the whole symbol
+	pattern, err = compileSymbolPattern("regex", `[A-Z]+\.(A|B)`)
+	c.Assert(err, IsNil)
+	c.Assert(selectSymbols(resp, pattern), DeepEquals, []string{"BRK.A", "BRK.B"})
+	pattern, err = compileSymbolPattern("regex", "A|S")
+	c.Assert(err, IsNil)
+	c.Assert(selectSymbols(resp, pattern), DeepEquals, []string{})
+	pattern, err = compileSymbolPattern("regex", "A.*|S.*")
+	c.Assert(err, IsNil)
+	c.Assert(selectSymbols(resp, pattern), DeepEquals, []string{"AAPL", "SPY"})
+
+	_, err = compileSymbolPattern("regex", "BRK(")
+	c.Assert(err, ErrorMatches, ".*missing closing \\): `BRK\\(`")
+	_, err = compileSymbolPattern("glob", "[A-")
+	c.Assert(err, NotNil)
+	_, err = compileSymbolPattern("sql", "%")
+	c.Assert(err, ErrorMatches, `unknown symbol-match "sql", must be glob or regex`)
+}
+
+func (s *BackfillerTests) TestCompileSymbolsFlag(c *C) {
+	var exits []int
+	exit := func(code int) { exits = append(exits, code) }
+
+	c.Assert(compileSymbolsFlag("glob", "A*", exit).Match("AAPL"), Equals, true)
+	c.Assert(exits, HasLen, 0)
+
+	// an invalid pattern exits instead of panicking
+	c.Assert(compileSymbolsFlag("glob", "[A-", exit), IsNil)
+	c.Assert(compileSymbolsFlag("regex", "BRK(", exit), IsNil)
+	c.Assert(exits, DeepEquals, []int{1, 1})
+
+	_, err := compileSymbolPattern("glob", "[A-")
+	c.Assert(invalidPatternMessage("glob", "[A-", err), Matches,
+		`invalid symbols pattern "\[A-" \(.*\), the glob syntax is: \* matches any characters, .*`)
+	_, err = compileSymbolPattern("sql", "%")
+	c.Assert(invalidPatternMessage("sql", "%", err), Equals,
+		`invalid symbols pattern "%" (unknown symbol-match "sql", must be glob or regex)`)
+}
+
+func (s *BackfillerTests) TestSortSymbols(c *C) {
+	c.Assert(sortSymbols([]string{"SPY", "AAPL", "MSFT", "AAPL", "AMZN", "SPY"}), DeepEquals,
+		[]string{"AAPL", "AMZN", "MSFT", "SPY"})
+	c.Assert(sortSymbols(nil), DeepEquals, []string{})
+}
+
+func (s *BackfillerTests) TestReadSymbolFile(c *C) {
+	path := filepath.Join(c.MkDir(), "watchlist.txt")
+	err := ioutil.WriteFile(path, []byte("# watchlist\nAAPL\n\n  AMZN  \nSPY # the index\nAAPL\n\t\n"), 0644)
+	c.Assert(err, IsNil)
+
+	symbols, err := readSymbolFile(path)
+	c.Assert(err, IsNil)
+	c.Assert(symbols, DeepEquals, []string{"AAPL", "AMZN", "SPY"})
+
+	_, err = readSymbolFile(filepath.Join(c.MkDir(), "missing.txt"))
+	c.Assert(err, NotNil)
+}
+
+func (s *BackfillerTests) TestSelectListedSymbols(c *C) {
+	resp := &api.ListTickersResponse{}
+	err := json.Unmarshal([]byte(`{"tickers":[{"ticker":"AAPL"},{"ticker":"AMZN"},{"ticker":"SPY"}]}`), resp)
+	c.Assert(err, IsNil)
+
+	listed, unknown := selectListedSymbols(resp, []string{"SPY", "XXXX", "AAPL"})
+	c.Assert(listed, DeepEquals, []string{"SPY", "AAPL"})
+	c.Assert(unknown, DeepEquals, []string{"XXXX"})
+}
+
+func (s *BackfillerTests) TestLoadTriggers(c *C) {
+	dir := c.MkDir()
+	write := func(config string) string {
+		path := filepath.Join(dir, "mkts.yml")
+		c.Assert(ioutil.WriteFile(path, []byte(config), 0644), IsNil)
+		return path
+	}
+
+	// no triggers
+	matchers, err := loadTriggers(write("root_directory: data\n"))
+	c.Assert(err, IsNil)
+	c.Assert(matchers, HasLen, 0)
+
+	_, err = loadTriggers(write("triggers:\n  - module: ondiskagg.so\n    on: \"*/1Min\"\n"))
+	c.Assert(err, ErrorMatches, `invalid on pattern .*`)
+
+	_, err = loadTriggers(write("triggers:\n  - module: missing.so\n    on: \"*/1Min/OHLCV\"\n"))
+	c.Assert(err, ErrorMatches, `(?s)unable to open plugin missing.so: .*`)
+}
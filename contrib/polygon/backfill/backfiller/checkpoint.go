@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// checkpoint records the (data type x symbol x market day) work units
+// completed by the backfill in a file, so that an interrupted backfill is
+// resumed by running it again with the same flags, skipping them.
+type checkpoint struct {
+	path string
+
+	mu   sync.Mutex
+	done map[string]bool
+}
+
+type checkpointFile struct {
+	Completed []string `json:"completed"`
+}
+
+// loadCheckpoint reads the completed units from the file at the path, of
+// which there are none if it does not exist.  Nothing is recorded if the
+// path is empty.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	c := &checkpoint{path: path, done: map[string]bool{}}
+	if path == "" {
+		return c, nil
+	}
+
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var f checkpointFile
+	if err = json.Unmarshal(buf, &f); err != nil {
+		return nil, err
+	}
+	for _, unit := range f.Completed {
+		c.done[unit] = true
+	}
+	return c, nil
+}
+
+// unitKey returns the key of the work unit backfilling the data type
+// (bars, quotes or trades) of the symbol on the day.
+func unitKey(dataType, symbol string, day time.Time) string {
+	return dataType + "/" + symbol + "/" + day.Format(format)
+}
+
+func (c *checkpoint) isDone(unit string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.done[unit]
+}
+
+func (c *checkpoint) markDone(unit string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.done[unit] = true
+}
+
+// save writes the completed units to the file, replacing it atomically.
+// It is to be called once the writes of the units are flushed to disk.
+func (c *checkpoint) save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	c.mu.Lock()
+	f := checkpointFile{Completed: make([]string, 0, len(c.done))}
+	for unit := range c.done {
+		f.Completed = append(f.Completed, unit)
+	}
+	c.mu.Unlock()
+	sort.Strings(f.Completed)
+
+	buf, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path)
+}
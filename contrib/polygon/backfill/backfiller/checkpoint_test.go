@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+var _ = Suite(&CheckpointTests{})
+
+type CheckpointTests struct{}
+
+func (s *CheckpointTests) TestSaveAndResume(c *C) {
+	path := filepath.Join(c.MkDir(), "checkpoint.json")
+	day := time.Date(2020, 12, 10, 0, 0, 0, 0, NY)
+
+	// nothing is completed before the first run
+	ckpt, err := loadCheckpoint(path)
+	c.Assert(err, IsNil)
+	c.Assert(ckpt.isDone(unitKey("bars", "AAPL", day)), Equals, false)
+
+	ckpt.markDone(unitKey("bars", "AAPL", day))
+	ckpt.markDone(unitKey("trades", "AAPL", day))
+	c.Assert(ckpt.save(), IsNil)
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	c.Assert(err, IsNil)
+	c.Assert(files, HasLen, 1)
+
+	// and the units completed are skipped by the next one
+	ckpt, err = loadCheckpoint(path)
+	c.Assert(err, IsNil)
+	c.Assert(ckpt.isDone("bars/AAPL/2020-12-10"), Equals, true)
+	c.Assert(ckpt.isDone("trades/AAPL/2020-12-10"), Equals, true)
+	c.Assert(ckpt.isDone("quotes/AAPL/2020-12-10"), Equals, false)
+	c.Assert(ckpt.isDone(unitKey("bars", "AAPL", day.AddDate(0, 0, 1))), Equals, false)
+}
+
+func (s *CheckpointTests) TestDisabled(c *C) {
+	ckpt, err := loadCheckpoint("")
+	c.Assert(err, IsNil)
+	ckpt.markDone("bars/AAPL/2020-12-10")
+	c.Assert(ckpt.save(), IsNil)
+}
+
+func (s *CheckpointTests) TestCorrupt(c *C) {
+	path := filepath.Join(c.MkDir(), "checkpoint.json")
+	c.Assert(ioutil.WriteFile(path, []byte("{"), 0644), IsNil)
+	_, err := loadCheckpoint(path)
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alpacahq/marketstore/v4/contrib/polygon/api"
+)
+
+// exchangeNames returns the names of the exchanges of the IDs, e.g. to
+// confirm them in the log, or an error listing the valid IDs if one of
+// them is not an exchange.
+func exchangeNames(ids []int, exchanges []api.Exchange) ([]string, error) {
+	byID := make(map[int]api.Exchange, len(exchanges))
+	for _, e := range exchanges {
+		byID[e.ID] = e
+	}
+
+	names := make([]string, 0, len(ids))
+	var unknown []string
+	for _, id := range ids {
+		e, ok := byID[id]
+		if !ok {
+			unknown = append(unknown, fmt.Sprint(id))
+			continue
+		}
+		names = append(names, fmt.Sprintf("%v (%v)", id, e.Name))
+	}
+	if len(unknown) == 0 {
+		return names, nil
+	}
+
+	valid := make([]string, 0, len(exchanges))
+	sort.Slice(exchanges, func(i, j int) bool { return exchanges[i].ID < exchanges[j].ID })
+	for _, e := range exchanges {
+		valid = append(valid, fmt.Sprintf("%v (%v)", e.ID, e.Name))
+	}
+	return nil, fmt.Errorf("unknown exchange IDs %v, the valid ones are: %v",
+		strings.Join(unknown, ", "), strings.Join(valid, ", "))
+}
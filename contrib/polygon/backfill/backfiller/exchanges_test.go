@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/alpacahq/marketstore/v4/contrib/polygon/api"
+	. "gopkg.in/check.v1"
+)
+
+func (s *BackfillerTests) TestExchangeNames(c *C) {
+	exchanges := []api.Exchange{
+		{ID: 12, Name: "Nasdaq"},
+		{ID: 1, Name: "NYSE American (AMEX)"},
+		{ID: 10, Name: "New York Stock Exchange"},
+	}
+
+	names, err := exchangeNames([]int{10, 12}, exchanges)
+	c.Assert(err, IsNil)
+	c.Assert(names, DeepEquals, []string{"10 (New York Stock Exchange)", "12 (Nasdaq)"})
+
+	// failing with the valid IDs on a typo
+	_, err = exchangeNames([]int{10, 21, 99}, exchanges)
+	c.Assert(err, ErrorMatches, `unknown exchange IDs 21, 99, the valid ones are: `+
+		`1 \(NYSE American \(AMEX\)\), 10 \(New York Stock Exchange\), 12 \(Nasdaq\)`)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+const (
+	// approximate memory of a downloaded trade or quote, decoded and
+	// converted to the columns written
+	tickMemory = 512
+	// approximate memory of a downloaded 1Min bar, of which there are at
+	// most 1440 a day
+	barMemory  = 256
+	barsPerDay = 1440
+)
+
+// tickTaskMemory returns the approximate memory of a task downloading the
+// trades or quotes of a day in pages of batchSize.
+func tickTaskMemory(batchSize int) uint64 {
+	return uint64(batchSize) * tickMemory
+}
+
+// barTaskMemory returns the approximate memory of a task downloading the
+// 1Min bars of a day.
+func barTaskMemory() uint64 {
+	return barsPerDay * barMemory
+}
+
+// memoryBudget bounds the memory of the running backfill tasks, on top of
+// the parallelism.  A task is started only if its approximate memory fits
+// in the budget with those of the running tasks, and the heap in use is
+// below the maximum, so that the concurrency adapts to the tasks that
+// turn out larger than their estimate.  A task is always started if no
+// other is running, so that one larger than the budget runs alone.
+type memoryBudget struct {
+	max  uint64
+	heap func() uint64
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	reserved uint64
+	running  int
+}
+
+// newMemoryBudget returns a budget of max bytes, unlimited if it is zero.
+func newMemoryBudget(max uint64) *memoryBudget {
+	b := &memoryBudget{max: max, heap: heapInUse}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+func heapInUse() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapInuse
+}
+
+// acquire waits until a task of the approximate memory fits in the budget.
+func (b *memoryBudget) acquire(memory uint64) {
+	if b.max == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.running > 0 && (b.reserved+memory > b.max || b.heap() > b.max) {
+		b.cond.Wait()
+	}
+	b.reserved += memory
+	b.running++
+}
+
+// release returns the memory of a finished task acquired with acquire.
+func (b *memoryBudget) release(memory uint64) {
+	if b.max == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	b.reserved -= memory
+	b.running--
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
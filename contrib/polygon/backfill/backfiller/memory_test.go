@@ -0,0 +1,75 @@
+package main
+
+import (
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+var _ = Suite(&MemoryBudgetTests{})
+
+type MemoryBudgetTests struct{}
+
+// acquired reports whether acquire returns shortly.
+func acquired(b *memoryBudget, memory uint64) bool {
+	done := make(chan struct{})
+	go func() {
+		b.acquire(memory)
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(100 * time.Millisecond):
+		return false
+	}
+}
+
+func (s *MemoryBudgetTests) TestReserved(c *C) {
+	b := newMemoryBudget(100)
+	b.heap = func() uint64 { return 0 }
+
+	c.Assert(acquired(b, 60), Equals, true)
+	c.Assert(acquired(b, 40), Equals, true)
+	// waiting until a task is finished to fit in the budget
+	c.Assert(acquired(b, 10), Equals, false)
+	b.release(60)
+	time.Sleep(10 * time.Millisecond)
+	b.mu.Lock()
+	c.Assert(b.reserved, Equals, uint64(50))
+	c.Assert(b.running, Equals, 2)
+	b.mu.Unlock()
+
+	// a task larger than the budget runs alone
+	b.release(40)
+	b.release(10)
+	c.Assert(acquired(b, 1000), Equals, true)
+}
+
+func (s *MemoryBudgetTests) TestHeap(c *C) {
+	heap := uint64(0)
+	b := newMemoryBudget(100)
+	b.heap = func() uint64 { return heap }
+
+	c.Assert(acquired(b, 10), Equals, true)
+	// not starting more tasks while the heap is over the budget
+	b.mu.Lock()
+	heap = 200
+	b.mu.Unlock()
+	c.Assert(acquired(b, 10), Equals, false)
+	b.mu.Lock()
+	heap = 50
+	b.mu.Unlock()
+	b.release(10)
+	time.Sleep(10 * time.Millisecond)
+	b.mu.Lock()
+	c.Assert(b.running, Equals, 1)
+	b.mu.Unlock()
+}
+
+func (s *MemoryBudgetTests) TestUnlimited(c *C) {
+	b := newMemoryBudget(0)
+	for i := 0; i < 10; i++ {
+		c.Assert(acquired(b, 1<<40), Equals, true)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// progress tracks the completed (symbol x day x data type) work units
+// of a backfill across the goroutine pool, and periodically logs
+// a summary with the percentage done, the throughput and an ETA.
+type progress struct {
+	total  int64
+	done   int64
+	failed int64
+	start  time.Time
+	stop   chan struct{}
+	// logf logs the summaries, replaced by the tests
+	logf func(msg string, args ...interface{})
+}
+
+func newProgress(total int64) *progress {
+	return &progress{
+		total: total,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		logf:  log.Info,
+	}
+}
+
+// finish marks a work unit as completed, failed if err is not nil.
+func (p *progress) finish(err error) {
+	if err != nil {
+		atomic.AddInt64(&p.failed, 1)
+	}
+	atomic.AddInt64(&p.done, 1)
+}
+
+// resume removes units completed by a previous run from the total.
+func (p *progress) resume(units int64) {
+	atomic.AddInt64(&p.total, -units)
+}
+
+// remaining returns the number of units not processed yet.
+func (p *progress) remaining() int64 {
+	return atomic.LoadInt64(&p.total) - atomic.LoadInt64(&p.done)
+}
+
+// skip marks units that will never be processed as failed.
+func (p *progress) skip(units int64) {
+	atomic.AddInt64(&p.failed, units)
+	atomic.AddInt64(&p.done, units)
+}
+
+// report logs the progress summary every interval until close is called.
+func (p *progress) report(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.logf("[polygon] progress: %v", p.summary())
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// close stops the periodic reporting and logs the final summary.
+func (p *progress) close() {
+	close(p.stop)
+	p.logf("[polygon] backfilled %v/%v units (%v failed) in %v",
+		atomic.LoadInt64(&p.done),
+		atomic.LoadInt64(&p.total),
+		atomic.LoadInt64(&p.failed),
+		time.Since(p.start).Round(time.Second),
+	)
+}
+
+func (p *progress) summary() string {
+	done := atomic.LoadInt64(&p.done)
+	failed := atomic.LoadInt64(&p.failed)
+	total := atomic.LoadInt64(&p.total)
+	elapsed := time.Since(p.start)
+
+	percent := 100.0
+	if total > 0 {
+		percent = 100 * float64(done) / float64(total)
+	}
+
+	rate := float64(done) / elapsed.Seconds()
+
+	eta := "unknown"
+	if rate > 0 {
+		remaining := time.Duration(float64(total-done)/rate) * time.Second
+		eta = remaining.Round(time.Second).String()
+	}
+
+	return fmt.Sprintf("%v/%v units (%.1f%%), %v failed, %.2f units/sec, ETA %v",
+		done, total, percent, failed, rate, eta)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *BackfillerTests) TestProgress(c *C) {
+	var (
+		mu    sync.Mutex
+		lines []string
+	)
+	logged := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, lines...)
+	}
+
+	// 2 symbols x 3 days x 2 data types, 2 of which were done by a previous run
+	p := newProgress(2 * 3 * 2)
+	p.logf = func(msg string, args ...interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		lines = append(lines, fmt.Sprintf(msg, args...))
+	}
+	p.resume(2)
+	c.Assert(p.remaining(), Equals, int64(10))
+
+	for i := 0; i < 6; i++ {
+		var err error
+		if i%3 == 0 {
+			err = errors.New("no data")
+		}
+		p.finish(err)
+	}
+	// the units of a symbol that failed to list
+	p.skip(2)
+	c.Assert(p.remaining(), Equals, int64(2))
+	c.Assert(p.summary(), Matches, `8/10 units \(80\.0%\), 4 failed, [0-9.]+ units/sec, ETA \S+`)
+
+	go p.report(10 * time.Millisecond)
+	for i := 0; i < 100 && len(logged()) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Assert(logged(), Not(HasLen), 0)
+	c.Assert(logged()[0], Matches, `\[polygon\] progress: 8/10 units \(80\.0%\), 4 failed, .*`)
+
+	p.finish(nil)
+	p.finish(nil)
+	c.Assert(p.remaining(), Equals, int64(0))
+	c.Assert(p.summary(), Matches, `10/10 units \(100\.0%\), 4 failed, [0-9.]+ units/sec, ETA 0s`)
+
+	p.close()
+	// a tick racing with the close may still log a summary after it
+	var final []string
+	for _, line := range logged() {
+		if !strings.HasPrefix(line, "[polygon] progress: ") {
+			final = append(final, line)
+		}
+	}
+	c.Assert(final, DeepEquals, []string{"[polygon] backfilled 10/10 units (4 failed) in 0s"})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/alpacahq/marketstore/v4/frontend"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+const (
+	// remoteRetries is the number of attempts of a write to an unavailable server
+	remoteRetries = 3
+	// maxRemoteMsgSize is the largest write sent, the server default limit
+	maxRemoteMsgSize = 1 << 30
+)
+
+// remoteWriter writes the backfilled records through the gRPC API of a
+// running server, which owns the files and runs its own triggers, so that
+// the server does not have to be stopped for the backfill.
+type remoteWriter struct {
+	client  proto.MarketstoreClient
+	timeout time.Duration
+	// backoff is the wait before the first retry, doubled on each retry
+	backoff time.Duration
+}
+
+// dialServer connects to the gRPC API of the server at addr (host:port).
+func dialServer(addr string, timeout time.Duration) (*remoteWriter, error) {
+	conn, err := grpc.Dial(addr, grpc.WithInsecure(),
+		grpc.WithDefaultCallOptions(grpc.MaxCallSendMsgSize(maxRemoteMsgSize)))
+	if err != nil {
+		return nil, err
+	}
+	return &remoteWriter{client: proto.NewMarketstoreClient(conn), timeout: timeout, backoff: time.Second}, nil
+}
+
+// write writes the buckets of csm in one request, retried if the server
+// is unavailable.  The request may have been written before the error, so
+// it is sent with an idempotency key, for the server not to write the
+// retries of it again, e.g. the trades and quotes appended to their buckets.
+func (w *remoteWriter) write(csm io.ColumnSeriesMap, isVariableLength bool) error {
+	key, err := newIdempotencyKey()
+	if err != nil {
+		return err
+	}
+	req := &proto.MultiWriteRequest{IdempotencyKey: key}
+	for tbk, cs := range csm {
+		nds, err := io.NewNumpyDataset(cs)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %v", tbk.GetItemKey(), err)
+		}
+		nmds, err := io.NewNumpyMultiDataset(nds, tbk)
+		if err != nil {
+			return fmt.Errorf("failed to encode %s: %v", tbk.GetItemKey(), err)
+		}
+		req.Requests = append(req.Requests, &proto.WriteRequest{
+			Data:             frontend.ToProtoNumpyMultiDataSet(nmds),
+			IsVariableLength: isVariableLength,
+		})
+	}
+	if len(req.Requests) == 0 {
+		return nil
+	}
+
+	backoff := w.backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := w.send(req)
+		if err == nil {
+			for _, r := range resp.Responses {
+				if r.Error != "" {
+					return fmt.Errorf("server failed to write: %s", r.Error)
+				}
+			}
+			return nil
+		}
+		if status.Code(err) != codes.Unavailable || attempt == remoteRetries {
+			return err
+		}
+		log.Warn("[polygon] server unavailable, retrying the write in %v (%v)", backoff, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (w *remoteWriter) send(req *proto.MultiWriteRequest) (*proto.MultiServerResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+	return w.client.Write(ctx, req)
+}
+
+// newIdempotencyKey returns a random idempotency key for a write request.
+func newIdempotencyKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate an idempotency key: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
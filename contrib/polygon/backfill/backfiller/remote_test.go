@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/frontend"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+var _ = Suite(&RemoteTests{})
+
+type RemoteTests struct{}
+
+// fakeServer fails the first writes as unavailable, then returns errors.
+type fakeServer struct {
+	proto.MarketstoreClient
+	unavailable int
+	errors      []string
+	requests    []*proto.MultiWriteRequest
+}
+
+func (f *fakeServer) Write(ctx context.Context, req *proto.MultiWriteRequest,
+	opts ...grpc.CallOption) (*proto.MultiServerResponse, error) {
+	f.requests = append(f.requests, req)
+	if f.unavailable > 0 {
+		f.unavailable--
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+	resp := &proto.MultiServerResponse{}
+	for _, e := range f.errors {
+		resp.Responses = append(resp.Responses, &proto.ServerResponse{Error: e})
+	}
+	return resp, nil
+}
+
+func (s *RemoteTests) TestRemoteWrite(c *C) {
+	tbk := io.NewTimeBucketKey("AAPL/1Min/OHLCV")
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{60, 120})
+	cs.AddColumn("Close", []float32{1, 2})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+
+	// the buckets are sent in one request, retried while unavailable
+	server := &fakeServer{unavailable: 1}
+	w := &remoteWriter{client: server, timeout: time.Second}
+	c.Assert(w.write(csm, false), IsNil)
+	c.Assert(server.requests, HasLen, 2)
+	req := server.requests[1]
+	// with the same key, for the server not to write the retry again if
+	// the first attempt was written before failing
+	c.Assert(req.IdempotencyKey, Not(Equals), "")
+	c.Assert(req.IdempotencyKey, Equals, server.requests[0].IdempotencyKey)
+	c.Assert(req.Requests, HasLen, 1)
+	c.Assert(req.Requests[0].IsVariableLength, Equals, false)
+	written, err := frontend.ToNumpyMultiDataSet(req.Requests[0].Data).ToColumnSeriesMap()
+	c.Assert(err, IsNil)
+	c.Assert(written[*tbk].GetEpoch(), DeepEquals, []int64{60, 120})
+	c.Assert(written[*tbk].GetColumn("Close"), DeepEquals, []float32{1, 2})
+
+	// the retries are bounded
+	server = &fakeServer{unavailable: remoteRetries}
+	w.client = server
+	c.Assert(status.Code(w.write(csm, false)), Equals, codes.Unavailable)
+	c.Assert(server.requests, HasLen, remoteRetries)
+	// another key for another write
+	c.Assert(server.requests[0].IdempotencyKey, Not(Equals), req.IdempotencyKey)
+
+	// the errors of the server fail the write, without a retry
+	server = &fakeServer{errors: []string{"disk full"}}
+	w.client = server
+	c.Assert(w.write(csm, false), ErrorMatches, "server failed to write: disk full")
+	c.Assert(server.requests, HasLen, 1)
+}
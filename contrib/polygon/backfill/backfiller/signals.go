@@ -0,0 +1,24 @@
+package main
+
+import (
+	"os"
+
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// handleInterrupts calls stop on the first signal received, so that no
+// more work is dispatched and the backfill winds down, and force-exits the
+// process with exit on another one, e.g. on a hung flush.
+func handleInterrupts(signals <-chan os.Signal, stop func(), exit func(code int)) {
+	stopping := false
+	for s := range signals {
+		if stopping {
+			log.Warn("[polygon] forcing exit due to '%v' while stopping", s)
+			exit(1)
+			continue
+		}
+		stopping = true
+		log.Info("[polygon] stopping the backfill due to '%v' after the work in progress, send it again to force exit", s)
+		stop()
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"syscall"
+
+	. "gopkg.in/check.v1"
+)
+
+var _ = Suite(&SignalsTests{})
+
+type SignalsTests struct{}
+
+func (s *SignalsTests) TestHandleInterrupts(c *C) {
+	signals := make(chan os.Signal, 3)
+	stops := 0
+	var exits []int
+
+	// the first signal stops the backfill, and another forces the exit
+	signals <- syscall.SIGINT
+	signals <- syscall.SIGTERM
+	signals <- syscall.SIGINT
+	close(signals)
+	handleInterrupts(signals, func() { stops++ }, func(code int) { exits = append(exits, code) })
+	c.Assert(stops, Equals, 1)
+	c.Assert(exits, DeepEquals, []int{1, 1})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gobwas/glob"
+
+	"github.com/alpacahq/marketstore/v4/contrib/polygon/api"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// symbolMatcher matches the symbols selected by a pattern.
+type symbolMatcher interface {
+	Match(symbol string) bool
+}
+
+// regexMatcher matches the symbols matched as a whole by a regex.
+type regexMatcher struct {
+	*regexp.Regexp
+}
+
+func (m regexMatcher) Match(symbol string) bool {
+	return m.MatchString(symbol)
+}
+
+// compileSymbolPattern returns the matcher of the pattern in the syntax of
+// mode, glob or regex.  Like a glob, a regex matches the whole symbol.
+func compileSymbolPattern(mode, pattern string) (symbolMatcher, error) {
+	switch mode {
+	case "glob":
+		return glob.Compile(pattern)
+	case "regex":
+		// checked alone for the errors to quote the pattern as given
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+		return regexMatcher{regexp.MustCompile("^(?:" + pattern + ")$")}, nil
+	default:
+		return nil, fmt.Errorf("unknown symbol-match %q, must be glob or regex", mode)
+	}
+}
+
+// symbolSyntax are the hints on the syntax of the patterns by mode.
+var symbolSyntax = map[string]string{
+	"glob": "* matches any characters, ? any one character, [abc] or [a-z] one of the characters, " +
+		"{AB,CD} one of the alternatives, and \\ escapes a special character",
+	"regex": "the Go regular expression syntax (https://golang.org/s/re2syntax), matching the whole symbol",
+}
+
+// compileSymbolsFlag returns the matcher of the symbols pattern, or logs
+// why it is invalid with a hint on its syntax and exits with exit.
+func compileSymbolsFlag(mode, pattern string, exit func(code int)) symbolMatcher {
+	matcher, err := compileSymbolPattern(mode, pattern)
+	if err == nil {
+		return matcher
+	}
+	log.Error("[polygon] %s", invalidPatternMessage(mode, pattern, err))
+	exit(1)
+	return nil
+}
+
+// invalidPatternMessage describes the error of the pattern, with a hint on
+// the syntax of its mode if known.
+func invalidPatternMessage(mode, pattern string, err error) string {
+	msg := fmt.Sprintf("invalid symbols pattern %q (%v)", pattern, err)
+	if syntax, ok := symbolSyntax[mode]; ok {
+		msg += fmt.Sprintf(", the %s syntax is: %s", mode, syntax)
+	}
+	return msg
+}
+
+// readSymbolFile returns the symbols listed one per line in the file, in
+// order and without duplicates.  Blank lines and comments starting with
+// # are ignored.
+func readSymbolFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	symbols := make([]string, 0)
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		symbol := strings.TrimSpace(line)
+		if symbol == "" || seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		symbols = append(symbols, symbol)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return symbols, nil
+}
+
+// selectListedSymbols splits the symbols into those listed in the tickers
+// and the unknown ones, both in order.
+func selectListedSymbols(resp *api.ListTickersResponse, symbols []string) (listed, unknown []string) {
+	tickers := make(map[string]bool, len(resp.Tickers))
+	for _, s := range resp.Tickers {
+		tickers[s.Ticker] = true
+	}
+
+	listed = make([]string, 0, len(symbols))
+	for _, symbol := range symbols {
+		if tickers[symbol] {
+			listed = append(listed, symbol)
+		} else {
+			unknown = append(unknown, symbol)
+		}
+	}
+	return listed, unknown
+}
+
+// sortSymbols returns the symbols sorted alphabetically and without
+// duplicates.
+func sortSymbols(symbols []string) []string {
+	sorted := make([]string, 0, len(symbols))
+	seen := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		if !seen[symbol] {
+			seen[symbol] = true
+			sorted = append(sorted, symbol)
+		}
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/contrib/polygon/backfill"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// taskTimes accumulates the wall-clock time of the backfill tasks run by
+// the goroutine pool, by symbol and by data type, to find the symbols
+// dominating the runtime.
+type taskTimes struct {
+	sync.Mutex
+	bySymbol   map[string]time.Duration
+	byDataType map[string]time.Duration
+}
+
+// symbolTime is the time spent backfilling a symbol.
+type symbolTime struct {
+	symbol  string
+	elapsed time.Duration
+}
+
+func newTaskTimes() *taskTimes {
+	return &taskTimes{
+		bySymbol:   map[string]time.Duration{},
+		byDataType: map[string]time.Duration{},
+	}
+}
+
+// start starts timing a task of the symbol and data type, and returns the
+// function recording its time once it is done.
+func (t *taskTimes) start(dataType, symbol string) func() {
+	start := time.Now()
+	return func() {
+		t.add(dataType, symbol, time.Since(start))
+	}
+}
+
+func (t *taskTimes) add(dataType, symbol string, elapsed time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+	t.bySymbol[symbol] += elapsed
+	t.byDataType[dataType] += elapsed
+}
+
+// slowest returns the n symbols with the longest time, the longest first.
+func (t *taskTimes) slowest(n int) []symbolTime {
+	t.Lock()
+	times := make([]symbolTime, 0, len(t.bySymbol))
+	for symbol, elapsed := range t.bySymbol {
+		times = append(times, symbolTime{symbol: symbol, elapsed: elapsed})
+	}
+	t.Unlock()
+
+	sort.Slice(times, func(i, j int) bool {
+		if times[i].elapsed != times[j].elapsed {
+			return times[i].elapsed > times[j].elapsed
+		}
+		return times[i].symbol < times[j].symbol
+	})
+	if len(times) > n {
+		times = times[:n]
+	}
+	return times
+}
+
+// dataTypes returns the time of each data type.
+func (t *taskTimes) dataTypes() map[string]time.Duration {
+	t.Lock()
+	defer t.Unlock()
+	out := make(map[string]time.Duration, len(t.byDataType))
+	for dataType, elapsed := range t.byDataType {
+		out[dataType] = elapsed
+	}
+	return out
+}
+
+// report logs the time of each data type and of the n slowest symbols with
+// their API call time.
+func (t *taskTimes) report(n int) {
+	byDataType := t.dataTypes()
+	dataTypes := make([]string, 0, len(byDataType))
+	for dataType := range byDataType {
+		dataTypes = append(dataTypes, dataType)
+	}
+	sort.Strings(dataTypes)
+	for _, dataType := range dataTypes {
+		log.Info("[polygon] backfilled %v in %v", dataType, byDataType[dataType].Round(time.Second))
+	}
+	log.Info("[polygon] spent %v in API calls", backfill.ApiCallDuration.Total().Round(time.Second))
+
+	if n <= 0 {
+		return
+	}
+	for i, st := range t.slowest(n) {
+		log.Info("[polygon] slowest symbol #%v: %v in %v (%v in API calls)", i+1, st.symbol,
+			st.elapsed.Round(time.Millisecond), backfill.ApiCallDuration.Symbol(st.symbol).Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+var _ = Suite(&TaskTimesTests{})
+
+type TaskTimesTests struct{}
+
+func (s *TaskTimesTests) TestSlowest(c *C) {
+	t := newTaskTimes()
+
+	// from the goroutine pool
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.add("bars", "AAPL", time.Second)
+			t.add("trades", "AAPL", 2*time.Second)
+			t.add("bars", "MSFT", time.Second)
+			t.add("bars", "TSLA", time.Second)
+		}()
+	}
+	wg.Wait()
+
+	c.Assert(t.slowest(2), DeepEquals, []symbolTime{
+		{symbol: "AAPL", elapsed: 30 * time.Second},
+		// the ties by symbol
+		{symbol: "MSFT", elapsed: 10 * time.Second},
+	})
+	c.Assert(t.slowest(5), HasLen, 3)
+	c.Assert(t.dataTypes(), DeepEquals, map[string]time.Duration{
+		"bars":   30 * time.Second,
+		"trades": 20 * time.Second,
+	})
+
+	done := t.start("quotes", "SPY")
+	time.Sleep(10 * time.Millisecond)
+	done()
+	c.Assert(t.dataTypes()["quotes"] >= 10*time.Millisecond, Equals, true)
+}
@@ -0,0 +1,66 @@
+package backfill
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/contrib/polygon/api"
+)
+
+// ApiCallDuration is the time spent in the Polygon API calls of the
+// backfill, in total and by symbol, accumulated by all the goroutines.
+var ApiCallDuration = NewCallDuration()
+
+// CallDuration accumulates the durations of the calls made concurrently,
+// in total and by symbol.
+type CallDuration struct {
+	sync.Mutex
+	total    time.Duration
+	bySymbol map[string]time.Duration
+}
+
+func NewCallDuration() *CallDuration {
+	return &CallDuration{bySymbol: map[string]time.Duration{}}
+}
+
+// Add adds the duration of a call for the symbol.
+func (d *CallDuration) Add(symbol string, dur time.Duration) {
+	d.Lock()
+	defer d.Unlock()
+	d.total += dur
+	d.bySymbol[symbol] += dur
+}
+
+// Total returns the duration of all the calls.
+func (d *CallDuration) Total() time.Duration {
+	d.Lock()
+	defer d.Unlock()
+	return d.total
+}
+
+// Symbol returns the duration of the calls for the symbol.
+func (d *CallDuration) Symbol(symbol string) time.Duration {
+	d.Lock()
+	defer d.Unlock()
+	return d.bySymbol[symbol]
+}
+
+// timeAPICall adds the time since start to the API call time of the symbol,
+// deferred by the callers of the API.
+func timeAPICall(symbol string, start time.Time) {
+	ApiCallDuration.Add(symbol, time.Since(start))
+}
+
+// streamTrades streams the trades of the date like api.StreamHistoricTrades,
+// timing the API calls but not fn.
+func streamTrades(symbol string, date time.Time, batchSize int, fn func([]api.TradeTick) error) error {
+	start := time.Now()
+	var handling time.Duration
+	err := api.StreamHistoricTrades(symbol, date.Format(defaultFormat), batchSize, func(ticks []api.TradeTick) error {
+		handled := time.Now()
+		defer func() { handling += time.Since(handled) }()
+		return fn(ticks)
+	})
+	ApiCallDuration.Add(symbol, time.Since(start)-handling)
+	return err
+}
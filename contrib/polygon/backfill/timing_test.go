@@ -0,0 +1,34 @@
+package backfill
+
+import (
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+)
+
+func (s *BackfillTests) TestCallDurationConcurrent(c *C) {
+	d := NewCallDuration()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			symbol := "AAPL"
+			if i%2 == 1 {
+				symbol = "MSFT"
+			}
+			for j := 0; j < 1000; j++ {
+				d.Add(symbol, time.Millisecond)
+				d.Total()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	c.Assert(d.Total(), Equals, 16*time.Second)
+	c.Assert(d.Symbol("AAPL"), Equals, 8*time.Second)
+	c.Assert(d.Symbol("MSFT"), Equals, 8*time.Second)
+	c.Assert(d.Symbol("TSLA"), Equals, time.Duration(0))
+}
@@ -0,0 +1,18 @@
+package backfill
+
+import (
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// Writer writes the backfilled records, like executor.WriteCSM.
+type Writer func(csm io.ColumnSeriesMap, isVariableLength bool) error
+
+// write writes to the local instance unless set otherwise.
+var write Writer = executor.WriteCSM
+
+// SetWriter sets the writer of the backfilled records, e.g. to write them
+// through the API of a running server rather than to the local instance.
+func SetWriter(w Writer) {
+	write = w
+}
@@ -23,6 +23,20 @@ const (
 	ConditionOpening         = 19
 )
 
+// symbolFilter matches the symbols written by the handlers, all of them if nil.
+var symbolFilter func(symbol string) bool
+
+// SetSymbolFilter sets the function matching the symbols written by the
+// handlers, e.g. when subscribed to all the symbols to write those matching
+// a glob.
+func SetSymbolFilter(match func(symbol string) bool) {
+	symbolFilter = match
+}
+
+func symbolMatches(symbol string) bool {
+	return symbolFilter == nil || symbolFilter(symbol)
+}
+
 func conditionsPresent(conditions []int) (skip bool) {
 	for _, c := range conditions {
 		switch c {
@@ -51,7 +65,7 @@ func TradeHandler(msg []byte) {
 	writeMap := make(map[io.TimeBucketKey]interface{})
 	for _, rt := range tt {
 		switch {
-		case conditionsPresent(rt.Conditions), rt.Size <= 0, rt.Price <= 0:
+		case !symbolMatches(rt.Symbol), conditionsPresent(rt.Conditions), rt.Size <= 0, rt.Price <= 0:
 			continue
 		}
 		// Polygon time is in milliseconds since the Unix epoch
@@ -88,6 +102,9 @@ func QuoteHandler(msg []byte) {
 	}
 	writeMap := make(map[io.TimeBucketKey]interface{})
 	for _, rq := range qq {
+		if !symbolMatches(rq.Symbol) {
+			continue
+		}
 		timestamp := time.Unix(0, int64(1000*1000*float64(rq.Timestamp)))
 		lagOnReceipt := time.Now().Sub(timestamp).Seconds()
 		q := quote{
@@ -126,6 +143,9 @@ func BarsHandler(msg []byte, addTickCount bool) {
 		return
 	}
 	for _, bar := range am {
+		if !symbolMatches(bar.Symbol) {
+			continue
+		}
 		timestamp := time.Unix(0, int64(1000*1000*float64(bar.EpochMillis)))
 		lagOnReceipt := time.Now().Sub(timestamp).Seconds()
 
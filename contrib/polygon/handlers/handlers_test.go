@@ -72,3 +72,12 @@ func (s *HandlersTestSuite) TestHandlers(c *C) {
 		QuoteHandler(buf)
 	}
 }
+
+func (s *HandlersTestSuite) TestSymbolFilter(c *C) {
+	defer SetSymbolFilter(nil)
+	c.Assert(symbolMatches("AAPL"), Equals, true)
+
+	SetSymbolFilter(func(symbol string) bool { return symbol == "SPY" })
+	c.Assert(symbolMatches("AAPL"), Equals, false)
+	c.Assert(symbolMatches("SPY"), Equals, true)
+}
@@ -23,7 +23,6 @@ type quote struct {
 }
 
 func Write(writeMap map[io.TimeBucketKey]interface{}) {
-	// preallocate the data structures for re-use
 	var (
 		csm   io.ColumnSeriesMap
 		epoch []int64
@@ -55,13 +54,13 @@ func Write(writeMap map[io.TimeBucketKey]interface{}) {
 				csm.AddColumn(tbk, "AskPrice", askPx)
 				csm.AddColumn(tbk, "BidSize", bidSz)
 				csm.AddColumn(tbk, "AskSize", askSz)
-				// trim the slices
-				epoch = epoch[:0]
-				nanos = nanos[:0]
-				bidPx = bidPx[:0]
-				bidSz = bidSz[:0]
-				askPx = bidPx[:0]
-				askSz = askSz[:0]
+				// new slices, as the columns of the csm keep these ones
+				epoch = nil
+				nanos = nil
+				bidPx = nil
+				bidSz = nil
+				askPx = nil
+				askSz = nil
 			}
 		case []*trade:
 			b := bucket.([]*trade)
@@ -77,11 +76,11 @@ func Write(writeMap map[io.TimeBucketKey]interface{}) {
 				csm.AddColumn(tbk, "Price", px)
 				csm.AddColumn(tbk, "Size", sz)
 
-				// trim the slices
-				epoch = epoch[:0]
-				nanos = nanos[:0]
-				px = px[:0]
-				sz = sz[:0]
+				// new slices, as the columns of the csm keep these ones
+				epoch = nil
+				nanos = nil
+				px = nil
+				sz = nil
 			}
 		}
 	}
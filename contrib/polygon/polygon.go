@@ -4,15 +4,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"runtime"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/gobwas/glob"
+
 	"github.com/alpacahq/marketstore/v4/contrib/polygon/api"
 	"github.com/alpacahq/marketstore/v4/contrib/polygon/backfill"
 	"github.com/alpacahq/marketstore/v4/contrib/polygon/handlers"
 	"github.com/alpacahq/marketstore/v4/executor"
 	"github.com/alpacahq/marketstore/v4/planner"
 	"github.com/alpacahq/marketstore/v4/plugins/bgworker"
+	"github.com/alpacahq/marketstore/v4/plugins/version"
 	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/io"
 	"github.com/alpacahq/marketstore/v4/utils/log"
@@ -21,6 +26,8 @@ import (
 type PolygonFetcher struct {
 	config FetcherConfig
 	types  map[string]struct{} // Bars, Quotes, Trades
+	// lastRun is the Unix time in nanoseconds of the last handled message
+	lastRun int64
 }
 
 type FetcherConfig struct {
@@ -35,7 +42,9 @@ type FetcherConfig struct {
 	WSServers string `json:"ws_servers"`
 	// list of data types to subscribe to (one of bars, quotes, trades)
 	DataTypes []string `json:"data_types"`
-	// list of symbols that are important
+	// list of symbols that are important, or glob patterns of them
+	// such as "SP*", which subscribe to all the symbols and write
+	// those matching only
 	Symbols []string `json:"symbols"`
 	// time string when to start first time, in "YYYY-MM-DD HH:MM" format
 	// if it is restarting, the start is the last written data timestamp
@@ -90,6 +99,12 @@ func (pf *PolygonFetcher) Run() {
 		api.SetWSServers(pf.config.WSServers)
 	}
 
+	symbols := pf.config.Symbols
+	if match, ok := symbolGlobs(symbols); ok {
+		symbols = []string{"*"}
+		handlers.SetSymbolFilter(match)
+	}
+
 	for t := range pf.types {
 		var prefix api.Prefix
 		var handler func([]byte)
@@ -104,13 +119,72 @@ func (pf *PolygonFetcher) Run() {
 			prefix = api.Trade
 			handler = handlers.TradeHandler
 		}
-		s := api.NewSubscription(prefix, pf.config.Symbols)
-		s.Subscribe(handler)
+		s := api.NewSubscription(prefix, symbols)
+		if t == "bars" {
+			// the bars missed while disconnected are backfilled from
+			// the first bar streamed for each symbol once reconnected
+			s.OnReconnect(resetBackfill)
+		}
+		s.Subscribe(pf.tracked(handler))
+	}
+
+	if _, ok := pf.types["bars"]; ok {
+		go pf.workBackfillBars()
 	}
 
 	select {}
 }
 
+// LastRun returns the time of the last handled message, for the bgworker
+// to be warned as stalled when the streams stop.
+func (pf *PolygonFetcher) LastRun() time.Time {
+	if nanos := atomic.LoadInt64(&pf.lastRun); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return time.Time{}
+}
+
+func (pf *PolygonFetcher) tracked(handler func([]byte)) func([]byte) {
+	return func(msg []byte) {
+		handler(msg)
+		atomic.StoreInt64(&pf.lastRun, time.Now().UnixNano())
+	}
+}
+
+// symbolGlobs returns the function matching the symbols if any of them is a
+// glob pattern other than "*", which Polygon subscribes to natively.
+func symbolGlobs(symbols []string) (match func(string) bool, ok bool) {
+	var globs []glob.Glob
+	for _, symbol := range symbols {
+		if symbol != "*" && strings.ContainsAny(symbol, "*?[{") {
+			ok = true
+		}
+		g, err := glob.Compile(symbol)
+		if err != nil {
+			log.Error("[polygon] invalid symbol pattern %q (%v)", symbol, err)
+			continue
+		}
+		globs = append(globs, g)
+	}
+	return func(symbol string) bool {
+		for _, g := range globs {
+			if g.Match(symbol) {
+				return true
+			}
+		}
+		return false
+	}, ok
+}
+
+// resetBackfill makes the next streamed bar of every symbol backfill the gap
+// since the last written one.
+func resetBackfill() {
+	backfill.BackfillM.Range(func(key, value interface{}) bool {
+		backfill.BackfillM.Delete(key)
+		return true
+	})
+}
+
 func (pf *PolygonFetcher) workBackfillBars() {
 	ticker := time.NewTicker(30 * time.Second)
 
@@ -124,14 +198,15 @@ func (pf *PolygonFetcher) workBackfillBars() {
 			symbol := key.(string)
 			// make sure epoch value isn't nil (i.e. hasn't
 			// been backfilled already)
-			if value != nil {
+			if epoch, ok := value.(*int64); ok && epoch != nil {
+				wg.Add(1)
+				count++
 				go func() {
-					wg.Add(1)
 					defer wg.Done()
 
 					// backfill the symbol in parallel
-					pf.backfillBars(symbol, time.Unix(*value.(*int64), 0))
-					backfill.BackfillM.Store(key, nil)
+					pf.backfillBars(symbol, time.Unix(*epoch, 0))
+					backfill.BackfillM.Store(symbol, (*int64)(nil))
 				}()
 			}
 
@@ -187,14 +262,15 @@ func (pf *PolygonFetcher) backfillBars(symbol string, end time.Time) {
 			return
 		}
 
-		from = time.Unix(epoch[len(epoch)-1], 0)
+		// from the bar following the last written one
+		from = time.Unix(epoch[len(epoch)-1], 0).Add(time.Minute)
 
 	} else {
 		for _, layout := range []string{
-			"2006-01-02 03:04:05",
-			"2006-01-02T03:04:05",
-			"2006-01-02 03:04",
-			"2006-01-02T03:04",
+			"2006-01-02 15:04:05",
+			"2006-01-02T15:04:05",
+			"2006-01-02 15:04",
+			"2006-01-02T15:04",
 			"2006-01-02",
 		} {
 			from, err = time.Parse(layout, pf.config.QueryStart)
@@ -204,10 +280,14 @@ func (pf *PolygonFetcher) backfillBars(symbol string, end time.Time) {
 		}
 	}
 
-	// request & write the missing bars
-	if err = backfill.Bars(symbol, from, time.Time{}); err != nil {
+	// request & write the missing bars up to the first streamed one, so
+	// that neither the last written bar nor the streamed ones are rewritten
+	if err = backfill.BarsBetween(symbol, from, end, 0); err != nil {
 		log.Error("[polygon] bars backfill failure for key: [%v] (%v)", tbk.String(), err)
 	}
 }
 
+// MarketstoreVersion stamps the plugin with the version it is built against.
+var MarketstoreVersion = version.Version
+
 func main() {}
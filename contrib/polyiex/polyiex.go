@@ -13,6 +13,7 @@ import (
 	"github.com/alpacahq/marketstore/v4/contrib/polyiex/api"
 	"github.com/alpacahq/marketstore/v4/contrib/polyiex/handlers"
 	"github.com/alpacahq/marketstore/v4/plugins/bgworker"
+	"github.com/alpacahq/marketstore/v4/plugins/version"
 	"github.com/alpacahq/marketstore/v4/utils/log"
 )
 
@@ -78,6 +79,9 @@ func configLog() {
 	log.SetLevel(log.DEBUG)
 }
 
+// MarketstoreVersion stamps the plugin with the version it is built against.
+var MarketstoreVersion = version.Version
+
 func main() {
 	configLog()
 
@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/alpacahq/marketstore/v4/contrib/stream/streamtrigger"
 	"github.com/alpacahq/marketstore/v4/plugins/trigger"
+	"github.com/alpacahq/marketstore/v4/plugins/version"
 )
 
 // NewTrigger returns a new on-disk aggregate trigger based on the configuration.
@@ -10,5 +11,8 @@ func NewTrigger(conf map[string]interface{}) (trigger.Trigger, error) {
 	return streamtrigger.NewTrigger(conf)
 }
 
+// MarketstoreVersion stamps the plugin with the version it is built against.
+var MarketstoreVersion = version.Version
+
 func main() {
 }
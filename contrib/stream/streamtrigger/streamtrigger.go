@@ -123,7 +123,9 @@ func (s *StreamTrigger) Fire(keyPath string, records []trigger.Record) {
 
 		// handle the 1D bar case to aggregate based on calendar
 		if tf.Duration >= 24*time.Hour && strings.EqualFold(s.filter, "nasdaq") {
-			deadline = calendar.Nasdaq.MarketClose(end)
+			if mktClose := calendar.Nasdaq.MarketClose(end); !mktClose.IsZero() {
+				deadline = &mktClose
+			}
 		} else {
 			ceiling := timeWindow.Ceil(end)
 			deadline = &ceiling
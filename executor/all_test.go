@@ -2,6 +2,7 @@ package executor_test
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"github.com/alpacahq/marketstore/v4/executor/wal"
 	"io/ioutil"
@@ -10,16 +11,20 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	. "gopkg.in/check.v1"
 
 	. "github.com/alpacahq/marketstore/v4/catalog"
 	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/metrics"
 	. "github.com/alpacahq/marketstore/v4/planner"
 	"github.com/alpacahq/marketstore/v4/utils"
 	. "github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/io/blockfile"
 	. "github.com/alpacahq/marketstore/v4/utils/test"
 )
 
@@ -30,6 +35,7 @@ var (
 	_ = Suite(&TestSuite{nil, "", nil, nil})
 	_ = Suite(&DestructiveWALTests{nil, "", nil, nil})
 	_ = Suite(&DestructiveWALTest2{nil, "", nil, nil})
+	_ = Suite(&DestructiveWALTest3{nil, "", nil, nil})
 )
 
 type TestSuite struct {
@@ -56,6 +62,14 @@ type DestructiveWALTest2 struct {
 	WALFile      *executor.WALFileType
 }
 
+type DestructiveWALTest3 struct {
+	DataDirectory *Directory
+	Rootdir       string
+	// Number of items written in sample data (non-zero index)
+	ItemsWritten map[string]int
+	WALFile      *executor.WALFileType
+}
+
 func (s *TestSuite) SetUpSuite(c *C) {
 	s.Rootdir = c.MkDir()
 	s.ItemsWritten = MakeDummyCurrencyDir(s.Rootdir, true, false)
@@ -184,6 +198,11 @@ func (s *TestSuite) TestWriteVariable(c *C) {
 	*/
 	reader, err := executor.NewReader(parsed)
 	c.Assert(err == nil, Equals, true)
+	// estimated from the records in the index, at least the 2 rows of
+	// Epoch, Bid, Ask and Nanoseconds, not from the size of the file
+	size, err := reader.EstimateSize()
+	c.Assert(err, IsNil)
+	c.Assert(size >= 2*20 && size < 1024, Equals, true, Commentf("estimated %d bytes", size))
 	csm, err := reader.Read()
 	c.Assert(err == nil, Equals, true)
 	c.Assert(len(csm), Equals, 1)
@@ -539,6 +558,165 @@ func (s *TestSuite) TestCrossYear(c *C) {
 	forwardBackwardScan(366, s.DataDirectory, c)
 }
 
+func (s *TestSuite) TestReadPages(c *C) {
+	tbk := NewTimeBucketKey("USDJPY/1H/OHLC")
+	start := time.Date(2001, time.December, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2002, time.January, 31, 0, 0, 0, 0, time.UTC)
+
+	q := NewQuery(s.DataDirectory)
+	q.AddTargetKey(tbk)
+	q.SetRange(start, end)
+	parsed, err := q.Parse()
+	c.Assert(err, IsNil)
+	scanner, err := executor.NewReader(parsed)
+	c.Assert(err, IsNil)
+	csm, err := scanner.Read()
+	c.Assert(err, IsNil)
+	expected := csm[*tbk].GetEpoch()
+	c.Assert(len(expected) > 100, Equals, true)
+
+	var epochs []int64
+	var pages int
+	err = executor.ReadPages(tbk, start, end, 100, func(cs *ColumnSeries) error {
+		c.Assert(cs.Len() <= 100, Equals, true)
+		epochs = append(epochs, cs.GetEpoch()...)
+		pages++
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(epochs, DeepEquals, expected)
+	c.Assert(pages > 1, Equals, true)
+
+	// the range out of the files
+	err = executor.ReadPages(tbk, MinTime, time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC), 100,
+		func(cs *ColumnSeries) error {
+			c.Fatal("unexpected page")
+			return nil
+		})
+	c.Assert(err, IsNil)
+}
+
+func (s *TestSuite) TestScaledColumns(c *C) {
+	for _, test := range []struct {
+		scale    int
+		prices   []float64
+		expected []string
+	}{
+		{1, []float64{1, 2.25, -3.35}, []string{"1.0", "2.3", "-3.4"}},
+		{2, []float64{0.1, 19.99, -0.005}, []string{"0.10", "19.99", "-0.01"}},
+		{8, []float64{0.00000001, 43210.12345678, 0.1 + 0.2}, []string{"0.00000001", "43210.12345678", "0.30000000"}},
+		{18, []float64{1.000000000000000001, 9.2, -0.1}, []string{"1.000000000000000000", "9.200000000000000000", "-0.100000000000000000"}},
+	} {
+		tbk := NewTimeBucketKey(fmt.Sprintf("DECIMAL%d/1D/PRICE", test.scale))
+		tf := utils.TimeframeFromString("1D")
+		dsv := NewDataShapeVector([]string{"Price", "Size"}, []EnumElementType{INT64, FLOAT32})
+		tbinfo := NewTimeBucketInfo(*tf, tbk.GetPathToYearFiles(s.Rootdir), "Test", int16(2019), dsv, FIXED)
+		c.Assert(tbinfo.SetElementScale("Price", test.scale), IsNil)
+		c.Assert(tbinfo.SetElementScale("Size", test.scale), NotNil)
+		c.Assert(executor.ThisInstance.CatalogDir.AddTimeBucket(tbk, tbinfo), IsNil)
+
+		var epoch []int64
+		for i := range test.prices {
+			epoch = append(epoch, time.Date(2019, 1, 2+i, 0, 0, 0, 0, time.UTC).Unix())
+		}
+		cs := NewColumnSeries()
+		cs.AddColumn("Epoch", epoch)
+		cs.AddColumn("Price", test.prices)
+		cs.AddColumn("Size", []float32{1, 2, 3})
+		csm := NewColumnSeriesMap()
+		csm.AddColumnSeries(*tbk, cs)
+		c.Assert(executor.WriteCSM(csm, false), IsNil)
+		executor.ThisInstance.WALFile.RequestFlush()
+
+		// the scale is read from the file header
+		d := NewDirectory(s.Rootdir)
+		tbi, err := d.GetLatestTimeBucketInfoFromKey(tbk)
+		c.Assert(err, IsNil)
+		c.Assert(tbi.GetElementScales(), DeepEquals, []int8{int8(test.scale), 0})
+
+		q := NewQuery(d)
+		q.AddTargetKey(tbk)
+		q.SetRange(MinTime, MaxTime)
+		parsed, err := q.Parse()
+		c.Assert(err, IsNil)
+		reader, err := executor.NewReader(parsed)
+		c.Assert(err, IsNil)
+		readCsm, err := reader.Read()
+		c.Assert(err, IsNil)
+		var prices []string
+		for _, price := range readCsm[*tbk].GetColumn("Price").([]int64) {
+			prices = append(prices, FormatScaled(price, test.scale))
+		}
+		c.Assert(prices, DeepEquals, test.expected, Commentf("scale %d", test.scale))
+	}
+}
+
+func (s *TestSuite) TestRetention(c *C) {
+	tbk := NewTimeBucketKey("RETAIN/1D/OHLC")
+	dsv := NewDataShapeVector([]string{"Close"}, []EnumElementType{FLOAT32})
+	tbinfo := NewTimeBucketInfo(*utils.TimeframeFromString("1D"), tbk.GetPathToYearFiles(s.Rootdir),
+		"Test", 2000, dsv, FIXED)
+	c.Assert(s.DataDirectory.AddTimeBucket(tbk, tbinfo), IsNil)
+	for _, year := range []int16{2001, 2002} {
+		_, err := s.DataDirectory.GetSubDirectoryAndAddFile(tbinfo.Path, year)
+		c.Assert(err, IsNil)
+	}
+	yearFile := func(year string) string { return filepath.Join(tbk.GetPathToYearFiles(s.Rootdir), year+".bin") }
+	now := time.Date(2002, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	// dry run only logs
+	r, err := executor.NewRetention(utils.RetentionSetting{
+		Policies: []*utils.RetentionPolicy{{Symbols: "RETAIN", MaxAge: 24 * time.Hour}},
+		DryRun:   true,
+	})
+	c.Assert(err, IsNil)
+	files, _, err := r.Prune(now)
+	c.Assert(err, IsNil)
+	c.Assert(files, Equals, 0)
+	_, err = s.DataDirectory.PathToTimeBucketInfo(yearFile("2000"))
+	c.Assert(err, IsNil)
+
+	// the years ended before the max age are removed from the catalog, and
+	// archived after the grace period
+	archiveDir := c.MkDir()
+	r, err = executor.NewRetention(utils.RetentionSetting{
+		Policies: []*utils.RetentionPolicy{
+			{Symbols: "RETAIN", Timeframe: "1Min", MaxAge: 24 * time.Hour},
+			{Symbols: "RET*", Timeframe: "1D", MaxAge: 200 * 24 * time.Hour},
+		},
+		ArchiveDirectory: archiveDir,
+	})
+	c.Assert(err, IsNil)
+	files, _, err = r.Prune(now)
+	c.Assert(err, IsNil)
+	c.Assert(files, Equals, 0)
+	_, err = s.DataDirectory.PathToTimeBucketInfo(yearFile("2000"))
+	c.Assert(err, NotNil)
+	_, err = s.DataDirectory.PathToTimeBucketInfo(yearFile("2001"))
+	c.Assert(err, IsNil)
+	_, err = os.Stat(yearFile("2000"))
+	c.Assert(err, IsNil)
+
+	files, bytes, err := r.Prune(now.Add(time.Hour))
+	c.Assert(err, IsNil)
+	c.Assert(files, Equals, 1)
+	c.Assert(bytes > 0, Equals, true)
+	_, err = os.Stat(yearFile("2000"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	_, err = os.Stat(filepath.Join(archiveDir, "RETAIN/1D/OHLC/2000.bin"))
+	c.Assert(err, IsNil)
+
+	// the latest year is kept however old it is
+	files, _, err = r.Prune(now.AddDate(10, 0, 0))
+	c.Assert(err, IsNil)
+	files, _, err = r.Prune(now.AddDate(10, 0, 1))
+	c.Assert(err, IsNil)
+	c.Assert(files, Equals, 1)
+	tbi, err := s.DataDirectory.GetLatestTimeBucketInfoFromKey(tbk)
+	c.Assert(err, IsNil)
+	c.Assert(tbi.Year, Equals, int16(2002))
+}
+
 func (s *TestSuite) TestLastN(c *C) {
 	q := NewQuery(s.DataDirectory)
 	q.AddRestriction("Symbol", "NZDUSD")
@@ -782,6 +960,38 @@ func (s *DestructiveWALTests) TestWALWrite(c *C) {
 
 }
 
+func (s *DestructiveWALTests) TestWALRotate(c *C) {
+	mockInstanceID := time.Now().UTC().UnixNano()
+	wf, err := executor.NewWALFile(s.Rootdir, mockInstanceID)
+	c.Assert(err, IsNil)
+	defer func() {
+		wf.WriteStatus(wal.OPEN, wal.REPLAYED)
+		wf.Delete(mockInstanceID)
+	}()
+
+	tgc := executor.NewTransactionPipe()
+	_, err = addTGData(s.DataDirectory, tgc, 100, false)
+	c.Assert(err, IsNil)
+	c.Assert(wf.FlushToWAL(tgc), IsNil)
+	c.Assert(wf.CreateCheckpoint(), IsNil)
+
+	fi, err := os.Stat(wf.FilePath)
+	c.Assert(err, IsNil)
+	flushed := fi.Size()
+	c.Assert(testutil.ToFloat64(metrics.WALSize), Equals, float64(flushed))
+
+	rotations := testutil.ToFloat64(metrics.WALRotations)
+	wf.Rotate()
+
+	fi, err = os.Stat(wf.FilePath)
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size() < flushed, Equals, true)
+	c.Assert(testutil.ToFloat64(metrics.WALSize), Equals, float64(fi.Size()))
+	c.Assert(testutil.ToFloat64(metrics.WALRotations), Equals, rotations+1)
+	c.Assert(time.Since(time.Unix(int64(testutil.ToFloat64(metrics.WALLastRotation)), 0)) < time.Minute, Equals, true)
+	c.Assert(wf.CanWrite("WALTest", mockInstanceID), Equals, true)
+}
+
 func (s *DestructiveWALTests) TestBrokenWAL(c *C) {
 	var err error
 
@@ -972,6 +1182,176 @@ func (s *DestructiveWALTest2) TestWALReplay(c *C) {
 	c.Assert(WALFile.Delete(WALFile.OwningInstanceID) == nil, Equals, true)
 }
 
+func (s *DestructiveWALTest3) SetUpSuite(c *C) {
+	s.Rootdir = c.MkDir()
+	s.ItemsWritten = MakeDummyCurrencyDir(s.Rootdir, true, false)
+	executor.NewInstanceSetup(s.Rootdir, true, true, false)
+	s.DataDirectory = executor.ThisInstance.CatalogDir
+	s.WALFile = executor.ThisInstance.WALFile
+}
+
+func (s *DestructiveWALTest3) TearDownSuite(c *C) {
+	utils.InstanceConfig.WALReplayWorkers = 0
+	CleanupDummyDataDir(s.Rootdir)
+}
+
+func (s *DestructiveWALTest3) TestParallelWALReplay(c *C) {
+	// Write several TGs, each of them touching all the symbol files and
+	// overwriting the records written by the previous ones
+	var (
+		queryFiles       []string
+		originalContents map[string][]byte
+	)
+	for i, number := range []int{1000, 500, 200} {
+		tgc := executor.NewTransactionPipe()
+		files, err := addTGData(s.DataDirectory, tgc, number, true)
+		c.Assert(err, IsNil)
+		if i == 0 {
+			// the files are unmodified until the first flush
+			queryFiles = files
+			originalContents = createBufferFromFiles(queryFiles, c)
+		}
+		c.Assert(s.WALFile.FlushToWAL(tgc), IsNil)
+	}
+	c.Assert(len(queryFiles) > 1, Equals, true)
+
+	// Save the WALFile contents before checkpoint
+	fstat, _ := s.WALFile.FilePtr.Stat()
+	walContents := make([]byte, fstat.Size())
+	_, err := s.WALFile.FilePtr.ReadAt(walContents, 0)
+	c.Assert(err, IsNil)
+	// Replace PID with a bogus PID
+	for i, val := range [8]byte{1, 1, 1, 1, 1, 1, 1, 1} {
+		walContents[3+i] = val
+	}
+
+	c.Assert(s.WALFile.CreateCheckpoint(), IsNil)
+	writtenContents := createBufferFromFiles(queryFiles, c)
+	c.Assert(compareFileToBuf(originalContents, queryFiles, c), Equals, false)
+
+	replay := func(workers int) map[string][]byte {
+		rewriteFilesFromBuffer(originalContents, c)
+		c.Assert(compareFileToBuf(originalContents, queryFiles, c), Equals, true)
+
+		walFileName := fmt.Sprintf("ReplayWAL%d", workers)
+		err := ioutil.WriteFile(filepath.Join(s.Rootdir, walFileName), walContents, 0600)
+		c.Assert(err, IsNil)
+		WALFile, err := executor.TakeOverWALFile(s.Rootdir, walFileName)
+		c.Assert(err, IsNil)
+
+		utils.InstanceConfig.WALReplayWorkers = workers
+		c.Assert(WALFile.Replay(true), IsNil)
+		c.Assert(WALFile.Delete(WALFile.OwningInstanceID), IsNil)
+		return createBufferFromFiles(queryFiles, c)
+	}
+
+	serialContents := replay(1)
+	parallelContents := replay(4)
+
+	for _, filePath := range queryFiles {
+		c.Assert(bytes.Equal(serialContents[filePath], writtenContents[filePath]), Equals, true)
+		c.Assert(bytes.Equal(parallelContents[filePath], serialContents[filePath]), Equals, true)
+	}
+
+	// the replay at startup of the WAL files left is measured, the WAL file
+	// of the suite retired first so that only the one left is replayed
+	s.WALFile.WriteStatus(wal.OPEN, wal.REPLAYED)
+	c.Assert(s.WALFile.Delete(s.WALFile.OwningInstanceID), IsNil)
+	rewriteFilesFromBuffer(originalContents, c)
+	walFileName := "WALFile.1.walfile"
+	c.Assert(ioutil.WriteFile(filepath.Join(s.Rootdir, walFileName), walContents, 0600), IsNil)
+	_, wf, err := executor.StartupCacheAndWAL(s.Rootdir, time.Now().UTC().UnixNano())
+	c.Assert(err, IsNil)
+	defer func() {
+		wf.WriteStatus(wal.OPEN, wal.REPLAYED)
+		wf.Delete(wf.OwningInstanceID)
+	}()
+	_, err = os.Stat(filepath.Join(s.Rootdir, walFileName))
+	c.Assert(os.IsNotExist(err), Equals, true)
+	c.Assert(compareFileToBuf(writtenContents, queryFiles, c), Equals, true)
+	c.Assert(testutil.ToFloat64(metrics.WALReplayTransactions), Equals, float64(3))
+	c.Assert(testutil.ToFloat64(metrics.WALReplayBytes), Equals, float64(len(walContents)))
+	c.Assert(testutil.ToFloat64(metrics.WALReplayDuration) > 0, Equals, true)
+}
+
+func (s *DestructiveWALTest3) TestCompressedWALReplay(c *C) {
+	defer func() { utils.InstanceConfig.Compression = nil }()
+	utils.InstanceConfig.Compression = map[time.Duration]string{time.Minute: utils.CompressionZstd}
+
+	tbk := NewTimeBucketKey("WALZSTD/1Min/OHLC")
+	start := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	var (
+		times  []time.Time
+		epoch  []int64
+		closes []float32
+	)
+	for i := 0; i < 200; i++ {
+		times = append(times, start.Add(time.Duration(i)*time.Minute))
+		epoch = append(epoch, times[i].Unix())
+		closes = append(closes, float32(i))
+	}
+	cs := NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	cs.AddColumn("Close", closes)
+
+	tf := utils.NewTimeframe("1Min")
+	_, err := executor.EnsureBucket(tbk, NewTimeBucketInfo(*tf, tbk.GetPathToYearFiles(s.Rootdir),
+		"Created By Test", 2019, cs.GetDataShapes(), FIXED))
+	c.Assert(err, IsNil)
+	tbi, err := s.DataDirectory.GetLatestTimeBucketInfoFromKey(tbk)
+	c.Assert(err, IsNil)
+	c.Assert(tbi.GetCodec(), Equals, blockfile.Zstd)
+	original, err := ioutil.ReadFile(tbi.Path)
+	c.Assert(err, IsNil)
+
+	// the records are committed to a WAL file of their own, so that only
+	// they are replayed
+	wf, err := executor.NewWALFile(s.Rootdir, time.Now().UnixNano())
+	c.Assert(err, IsNil)
+	tgc := executor.NewTransactionPipe()
+	w, err := executor.NewWriter(tbi, tgc, s.DataDirectory)
+	c.Assert(err, IsNil)
+	w.WriteRecords(times, cs.ToRowSeries(*tbk, true).GetData(), tbi.GetDataShapesWithEpoch())
+	c.Assert(wf.FlushToWAL(tgc), IsNil)
+	fstat, err := wf.FilePtr.Stat()
+	c.Assert(err, IsNil)
+	walContents := make([]byte, fstat.Size())
+	_, err = wf.FilePtr.ReadAt(walContents, 0)
+	c.Assert(err, IsNil)
+	// Replace PID with a bogus PID
+	for i, val := range [8]byte{1, 1, 1, 1, 1, 1, 1, 1} {
+		walContents[3+i] = val
+	}
+	c.Assert(wf.CreateCheckpoint(), IsNil)
+	wf.WriteStatus(wal.OPEN, wal.REPLAYED)
+	c.Assert(wf.Delete(wf.OwningInstanceID), IsNil)
+
+	// the crash lost the writes to the primary file
+	c.Assert(ioutil.WriteFile(tbi.Path, original, 0600), IsNil)
+	walFileName := "ReplayWALZstd"
+	c.Assert(ioutil.WriteFile(filepath.Join(s.Rootdir, walFileName), walContents, 0600), IsNil)
+	WALFile, err := executor.TakeOverWALFile(s.Rootdir, walFileName)
+	c.Assert(err, IsNil)
+	c.Assert(WALFile.Replay(true), IsNil)
+	c.Assert(WALFile.Delete(WALFile.OwningInstanceID), IsNil)
+
+	q := NewQuery(s.DataDirectory)
+	q.AddTargetKey(tbk)
+	q.SetRange(MinTime, MaxTime)
+	parsed, err := q.Parse()
+	c.Assert(err, IsNil)
+	reader, err := executor.NewReader(parsed)
+	c.Assert(err, IsNil)
+	out, err := reader.Read()
+	c.Assert(err, IsNil)
+	c.Assert(out[*tbk].GetEpoch(), DeepEquals, epoch)
+	c.Assert(out[*tbk].GetColumn("Close"), DeepEquals, closes)
+
+	fc, err := executor.CheckDataFile(s.Rootdir, tbi.Path, false)
+	c.Assert(err, IsNil)
+	c.Assert(fc.Problems, HasLen, 0)
+}
+
 /*
 	===================== Helper Functions =================================
 */
@@ -1201,3 +1581,291 @@ func nearestSecond(seconds int64, nanos int32) int64 {
 	}
 	return seconds
 }
+
+func (s *TestSuite) TestWriteDuplicates(c *C) {
+	defer func() { utils.InstanceConfig.WriteDuplicates = "" }()
+	t0 := time.Date(2016, time.December, 30, 10, 0, 0, 0, time.UTC).Unix()
+
+	write := func(key string, isVariableLength bool, epoch []int64, bid []float32) {
+		cs := NewColumnSeries()
+		cs.AddColumn("Epoch", epoch)
+		cs.AddColumn("Bid", bid)
+		if isVariableLength {
+			cs.AddColumn("Nanoseconds", make([]int32, len(epoch)))
+		}
+		csm := NewColumnSeriesMap()
+		csm.AddColumnSeries(*NewTimeBucketKey(key), cs)
+		c.Assert(executor.WriteCSM(csm, isVariableLength), IsNil)
+		s.WALFile.FlushToWAL(executor.ThisInstance.TXNPipe)
+		s.WALFile.CreateCheckpoint()
+	}
+	read := func(key string) []float32 {
+		q := NewQuery(s.DataDirectory)
+		q.AddTargetKey(NewTimeBucketKey(key))
+		q.SetRange(MinTime, MaxTime)
+		parsed, err := q.Parse()
+		c.Assert(err, IsNil)
+		reader, err := executor.NewReader(parsed)
+		c.Assert(err, IsNil)
+		csm, err := reader.Read()
+		c.Assert(err, IsNil)
+		return csm[*NewTimeBucketKey(key)].GetColumn("Bid").([]float32)
+	}
+
+	for _, test := range []struct {
+		policy   string
+		variable []float32
+		fixed    []float32
+		counted  float64
+	}{
+		{utils.DuplicatesAppend, []float32{1, 3, 2, 4}, []float32{3, 4}, 0},
+		{utils.DuplicatesOverwrite, []float32{3, 4}, []float32{3, 4}, 2},
+		{utils.DuplicatesReject, []float32{1, 2}, []float32{1, 2}, 2},
+	} {
+		utils.InstanceConfig.WriteDuplicates = test.policy
+		before := testutil.ToFloat64(metrics.WriteDuplicates.WithLabelValues(test.policy))
+
+		// the feed replays the same records
+		variable := "DUP" + test.policy + "/1Min/TICK"
+		write(variable, true, []int64{t0, t0 + 1}, []float32{1, 2})
+		write(variable, true, []int64{t0, t0 + 1}, []float32{3, 4})
+		c.Assert(read(variable), DeepEquals, test.variable, Commentf(test.policy))
+		variableCounted := testutil.ToFloat64(metrics.WriteDuplicates.WithLabelValues(test.policy)) - before
+		c.Assert(variableCounted, Equals, test.counted, Commentf(test.policy))
+
+		fixed := "DUP" + test.policy + "/1Min/OHLC"
+		write(fixed, false, []int64{t0, t0 + 60}, []float32{1, 2})
+		write(fixed, false, []int64{t0, t0 + 60}, []float32{3, 4})
+		c.Assert(read(fixed), DeepEquals, test.fixed, Commentf(test.policy))
+		fixedCounted := testutil.ToFloat64(metrics.WriteDuplicates.WithLabelValues(test.policy)) - before - variableCounted
+		c.Assert(fixedCounted, Equals, test.counted, Commentf(test.policy))
+	}
+}
+
+func (s *TestSuite) TestConcurrentWrites(c *C) {
+	const writers, rows = 16, 100
+	t0 := time.Date(2016, time.December, 30, 10, 0, 0, 0, time.UTC).Unix()
+
+	write := func(key string, isVariableLength bool, epoch []int64, bid []float32) {
+		cs := NewColumnSeries()
+		cs.AddColumn("Epoch", epoch)
+		cs.AddColumn("Bid", bid)
+		if isVariableLength {
+			cs.AddColumn("Nanoseconds", make([]int32, len(epoch)))
+		}
+		csm := NewColumnSeriesMap()
+		csm.AddColumnSeries(*NewTimeBucketKey(key), cs)
+		c.Check(executor.WriteCSM(csm, isVariableLength), IsNil)
+	}
+	read := func(key string) *ColumnSeries {
+		q := NewQuery(s.DataDirectory)
+		q.AddTargetKey(NewTimeBucketKey(key))
+		q.SetRange(MinTime, MaxTime)
+		parsed, err := q.Parse()
+		c.Assert(err, IsNil)
+		reader, err := executor.NewReader(parsed)
+		c.Assert(err, IsNil)
+		csm, err := reader.Read()
+		c.Assert(err, IsNil)
+		return csm[*NewTimeBucketKey(key)]
+	}
+	// create the buckets first, so that the writers don't race to create them
+	write("HAMMER/1Min/OHLC", false, []int64{t0}, []float32{-1})
+	write("HAMMER/1Sec/TICK", true, []int64{t0}, []float32{-1})
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			// the same minutes, all of them overwritten by each writer
+			epoch := make([]int64, rows)
+			bid := make([]float32, rows)
+			for i := range epoch {
+				epoch[i] = t0 + int64(i)*60
+				bid[i] = float32(w)
+			}
+			write("HAMMER/1Min/OHLC", false, epoch, bid)
+			// the ticks appended by each writer, in their own seconds
+			for i := range epoch {
+				epoch[i] = t0 + int64(i*writers+w)
+			}
+			write("HAMMER/1Sec/TICK", true, epoch, bid)
+		}(w)
+	}
+	wg.Wait()
+	s.WALFile.FlushToWAL(executor.ThisInstance.TXNPipe)
+	s.WALFile.CreateCheckpoint()
+
+	// the records of the last write, rather than a mix of the writes
+	bids := read("HAMMER/1Min/OHLC").GetColumn("Bid").([]float32)
+	c.Assert(bids, HasLen, rows)
+	for _, bid := range bids {
+		c.Assert(bid, Equals, bids[0])
+	}
+
+	// all the ticks, in order
+	epoch := read("HAMMER/1Sec/TICK").GetEpoch()
+	c.Assert(epoch, HasLen, writers*rows+1)
+	c.Assert(sort.SliceIsSorted(epoch, func(i, j int) bool { return epoch[i] < epoch[j] }), Equals, true)
+}
+
+func (s *TestSuite) TestWriteBatching(c *C) {
+	defer func(window time.Duration, size int) {
+		utils.InstanceConfig.WriteBatchWindow, utils.InstanceConfig.WriteBatchSize = window, size
+	}(utils.InstanceConfig.WriteBatchWindow, utils.InstanceConfig.WriteBatchSize)
+	t0 := time.Date(2016, time.December, 30, 10, 0, 0, 0, time.UTC).Unix()
+
+	write := func(key string, epoch int64) {
+		cs := NewColumnSeries()
+		cs.AddColumn("Epoch", []int64{epoch})
+		cs.AddColumn("Bid", []float32{1})
+		csm := NewColumnSeriesMap()
+		csm.AddColumnSeries(*NewTimeBucketKey(key), cs)
+		c.Check(executor.WriteCSM(csm, false), IsNil)
+	}
+	for _, key := range []string{"BATCH0/1Min/OHLC", "BATCH1/1Min/OHLC", "BATCH2/1Min/OHLC"} {
+		write(key, t0)
+	}
+
+	// a write alone waits for the end of the window
+	utils.InstanceConfig.WriteBatchWindow = 100 * time.Millisecond
+	utils.InstanceConfig.WriteBatchSize = 3
+	start := time.Now()
+	write("BATCH0/1Min/OHLC", t0+60)
+	c.Assert(time.Since(start) >= 100*time.Millisecond, Equals, true)
+
+	// a full batch is flushed without waiting for the end of the window
+	utils.InstanceConfig.WriteBatchWindow = time.Minute
+	start = time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < 3; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			write(fmt.Sprintf("BATCH%d/1Min/OHLC", w), t0+120)
+		}(w)
+	}
+	wg.Wait()
+	c.Assert(time.Since(start) < time.Minute, Equals, true)
+
+	s.WALFile.FlushToWAL(executor.ThisInstance.TXNPipe)
+	s.WALFile.CreateCheckpoint()
+	for w := 0; w < 3; w++ {
+		q := NewQuery(s.DataDirectory)
+		q.AddTargetKey(NewTimeBucketKey(fmt.Sprintf("BATCH%d/1Min/OHLC", w)))
+		q.SetRange(MinTime, MaxTime)
+		parsed, err := q.Parse()
+		c.Assert(err, IsNil)
+		reader, err := executor.NewReader(parsed)
+		c.Assert(err, IsNil)
+		csm, err := reader.Read()
+		c.Assert(err, IsNil)
+		epoch := csm[*NewTimeBucketKey(fmt.Sprintf("BATCH%d/1Min/OHLC", w))].GetEpoch()
+		c.Assert(epoch[len(epoch)-1], Equals, t0+120)
+	}
+}
+
+func (s *TestSuite) TestCompression(c *C) {
+	defer func() { utils.InstanceConfig.Compression = nil }()
+	utils.InstanceConfig.Compression = map[time.Duration]string{time.Minute: utils.CompressionZstd}
+
+	tbk := NewTimeBucketKey("COMPRESSED/1Min/OHLC")
+	start := time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+	var epoch []int64
+	var closes []float32
+	for i := 0; i < 200; i++ {
+		epoch = append(epoch, start.Add(time.Duration(i)*time.Minute).Unix())
+		closes = append(closes, float32(i))
+	}
+	cs := NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	cs.AddColumn("Close", closes)
+	csm := NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+
+	// flushed to the file by the delete
+	deleted, err := executor.ThisInstance.DeleteRange(context.Background(), tbk,
+		start.Add(50*time.Minute), start.Add(99*time.Minute))
+	c.Assert(err, IsNil)
+	c.Assert(deleted, Equals, 50)
+
+	q := NewQuery(s.DataDirectory)
+	q.AddTargetKey(tbk)
+	q.SetRange(MinTime, MaxTime)
+	parsed, err := q.Parse()
+	c.Assert(err, IsNil)
+	reader, err := executor.NewReader(parsed)
+	c.Assert(err, IsNil)
+	out, err := reader.Read()
+	c.Assert(err, IsNil)
+	c.Assert(out[*tbk].GetEpoch(), DeepEquals, append(append([]int64{}, epoch[:50]...), epoch[100:]...))
+	c.Assert(out[*tbk].GetColumn("Close"), DeepEquals, append(append([]float32{}, closes[:50]...), closes[100:]...))
+
+	tbi, err := executor.ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(tbk)
+	c.Assert(err, IsNil)
+	c.Assert(tbi.GetCodec(), Equals, blockfile.Zstd)
+	fi, err := os.Stat(tbi.Path)
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size() < FileSize(time.Minute, 2019, int(tbi.GetRecordLength()))/10, Equals, true)
+
+	fc, err := executor.CheckDataFile(s.Rootdir, tbi.Path, false)
+	c.Assert(err, IsNil)
+	c.Assert(fc.Problems, HasLen, 0)
+}
+
+func (s *TestSuite) TestDeleteRange(c *C) {
+	tbk := NewTimeBucketKey("DELRANGE/1Min/OHLC")
+	start := time.Date(2018, 12, 31, 23, 0, 0, 0, time.UTC)
+	var epoch []int64
+	var closes []float32
+	for i := 0; i < 120; i++ {
+		epoch = append(epoch, start.Add(time.Duration(i)*time.Minute).Unix())
+		closes = append(closes, float32(i))
+	}
+	cs := NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	cs.AddColumn("Close", closes)
+	csm := NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+
+	read := func() []int64 {
+		q := NewQuery(s.DataDirectory)
+		q.AddTargetKey(tbk)
+		q.SetRange(MinTime, MaxTime)
+		parsed, err := q.Parse()
+		c.Assert(err, IsNil)
+		reader, err := executor.NewReader(parsed)
+		c.Assert(err, IsNil)
+		csm, err := reader.Read()
+		c.Assert(err, IsNil)
+		return csm[*tbk].GetEpoch()
+	}
+	ctx := context.Background()
+
+	// a part of a file, written but not yet flushed
+	deleted, err := executor.ThisInstance.DeleteRange(ctx, tbk,
+		start.Add(10*time.Minute), start.Add(19*time.Minute))
+	c.Assert(err, IsNil)
+	c.Assert(deleted, Equals, 10)
+	c.Assert(read(), DeepEquals, append(append([]int64{}, epoch[:10]...), epoch[20:]...))
+
+	// a whole file, from its year before
+	deleted, err = executor.ThisInstance.DeleteRange(ctx, tbk,
+		time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2018, 12, 31, 23, 59, 59, 0, time.UTC))
+	c.Assert(err, IsNil)
+	c.Assert(deleted, Equals, 50)
+	c.Assert(read(), DeepEquals, epoch[60:])
+
+	// out of the files
+	deleted, err = executor.ThisInstance.DeleteRange(ctx, tbk,
+		time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC))
+	c.Assert(err, IsNil)
+	c.Assert(deleted, Equals, 0)
+	c.Assert(read(), DeepEquals, epoch[60:])
+
+	_, err = executor.ThisInstance.DeleteRange(ctx, NewTimeBucketKey("DELRANGE/1Min/NONE"), start, start)
+	c.Assert(err, ErrorMatches, "bucket .* not found")
+}
@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	goio "io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// Backup copies the root directory to outDir while the server is running.
+// The pending writes are flushed and synced first, and then the commits
+// to the data files are frozen until the copy is done, so the backup has all
+// the writes committed up to that point and none after.  The writes made in
+// the meantime are queued and committed once the copy is done.
+//
+// The data files are copied rather than hard-linked, since they are updated
+// in place.  The WAL files are not copied, as all of their transactions are
+// in the data files at that point, so the backup can be used as the root
+// directory of a fresh instance as is.
+//
+// outDir must not exist or be empty, and must be out of the root directory.
+// Once the backup request is queued, it is carried out even if ctx is done.
+func (i *InstanceMetadata) Backup(ctx context.Context, outDir string) error {
+	outDir, err := filepath.Abs(filepath.Clean(outDir))
+	if err != nil {
+		return fmt.Errorf("invalid backup directory %s: %v", outDir, err)
+	}
+	rootDir, err := filepath.Abs(i.RootDir)
+	if err != nil {
+		return err
+	}
+	if outDir == rootDir || strings.HasPrefix(outDir, rootDir+string(filepath.Separator)) {
+		return fmt.Errorf("backup directory %s must be out of the root directory %s", outDir, rootDir)
+	}
+	if entries, err := filepath.Glob(filepath.Join(outDir, "*")); err != nil {
+		return err
+	} else if len(entries) > 0 {
+		return fmt.Errorf("backup directory %s is not empty", outDir)
+	}
+
+	return i.WALFile.requestSync(ctx, func() error {
+		log.Info("backing up %s to %s...", i.RootDir, outDir)
+		if err := copyDir(i.RootDir, outDir); err != nil {
+			return fmt.Errorf("failed to back up %s to %s: %v", i.RootDir, outDir, err)
+		}
+		log.Info("backed up %s to %s", i.RootDir, outDir)
+		return nil
+	})
+}
+
+// copyDir copies the files under src to dst except the WAL files,
+// and syncs them to disk.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm())
+		case filepath.Ext(path) == ".walfile":
+			return nil
+		case !info.Mode().IsRegular():
+			return nil
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = goio.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils"
+)
+
+// writeBatch is the batch of the writes waiting for their flush with the
+// write batching, which coalesces the flushes requested by the writes
+// arriving within the write_batch_window into a single WAL transaction
+// group, flushed at the end of the window from the first write of the
+// batch, or as soon as it has write_batch_size writes.  The writes are
+// queued to the transaction pipe in their order before waiting, so the
+// batching does not reorder them.
+var writeBatch = struct {
+	sync.Mutex
+	waiters []chan struct{}
+	first   time.Time
+	timer   *time.Timer
+}{}
+
+// requestBatchedFlush waits for the flush of the batch of the write, once
+// the window since the first write of the batch is over or once the batch
+// has size writes.
+func (wf *WALFileType) requestBatchedFlush(window time.Duration, size int) {
+	done := make(chan struct{})
+	writeBatch.Lock()
+	writeBatch.waiters = append(writeBatch.waiters, done)
+	if len(writeBatch.waiters) == 1 {
+		writeBatch.first = time.Now()
+	}
+	full := len(writeBatch.waiters) >= size
+	if full {
+		if writeBatch.timer != nil {
+			writeBatch.timer.Stop()
+		}
+	} else if writeBatch.timer == nil {
+		writeBatch.timer = time.AfterFunc(window, wf.flushBatch)
+	}
+	writeBatch.Unlock()
+
+	if full {
+		wf.flushBatch()
+	}
+	<-done
+}
+
+// flushBatch flushes the writes of the batch and releases them.  A timer
+// firing after its batch was flushed early flushes the next batch early,
+// which never delays the writes.
+func (wf *WALFileType) flushBatch() {
+	writeBatch.Lock()
+	waiters, first := writeBatch.waiters, writeBatch.first
+	writeBatch.waiters = nil
+	writeBatch.timer = nil
+	writeBatch.Unlock()
+	if len(waiters) == 0 {
+		return
+	}
+
+	wf.flush()
+	metrics.WriteBatchSize.Observe(float64(len(waiters)))
+	metrics.WriteBatchLatency.Observe(time.Since(first).Seconds())
+	for _, done := range waiters {
+		close(done)
+	}
+}
+
+// batchSize returns the maximum number of writes of a batch.
+func batchSize() int {
+	if utils.InstanceConfig.WriteBatchSize > 0 {
+		return utils.InstanceConfig.WriteBatchSize
+	}
+	return 1000
+}
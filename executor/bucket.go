@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// bucketCreation serializes the creations of the buckets, so that the
+// concurrent creators of a bucket all find the one created first.
+var bucketCreation sync.Mutex
+
+// EnsureBucket creates the bucket of tbk with the schema of tbi if it does
+// not exist, and otherwise checks that its schema is the one of tbi,
+// returning a SchemaMismatchError if not.  It returns true if it created
+// the bucket.
+func EnsureBucket(tbk *io.TimeBucketKey, tbi *io.TimeBucketInfo) (created bool, err error) {
+	if err := checkDeclared(tbk); err != nil {
+		return false, err
+	}
+	bucketCreation.Lock()
+	defer bucketCreation.Unlock()
+	bucket, created, err := addTimeBucket(tbk, tbi)
+	if err != nil {
+		return false, err
+	}
+	// the bucket created may have its declared schema.  It is compared
+	// under the lock, as the header of a bucket found in the catalog is
+	// read on its first use, concurrently with the lookups of the others
+	if want, have := schemaString(tbi), schemaString(bucket); want != have {
+		return created, SchemaMismatchError(fmt.Sprintf("bucket %s has the schema %s, not %s",
+			tbk.GetItemKey(), have, want))
+	}
+	return created, nil
+}
+
+// ensureTimeBucket returns the info of the latest year of the bucket of
+// tbk, which it creates if it does not exist, with its declared schema if
+// any and with tbi otherwise.
+func ensureTimeBucket(tbk *io.TimeBucketKey, tbi *io.TimeBucketInfo) (*io.TimeBucketInfo, bool, error) {
+	if err := checkDeclared(tbk); err != nil {
+		return nil, false, err
+	}
+	bucketCreation.Lock()
+	defer bucketCreation.Unlock()
+	return addTimeBucket(tbk, tbi)
+}
+
+// addTimeBucket is ensureTimeBucket with bucketCreation held.
+func addTimeBucket(tbk *io.TimeBucketKey, tbi *io.TimeBucketInfo) (*io.TimeBucketInfo, bool, error) {
+	cDir := ThisInstance.CatalogDir
+	if existing, err := cDir.GetLatestTimeBucketInfoFromKey(tbk); err == nil {
+		return existing, false, nil
+	}
+	declared, err := declaredBucketInfo(tbk, tbi.Year)
+	if err != nil {
+		return nil, false, err
+	}
+	if declared != nil {
+		tbi = declared
+	}
+	if err := cDir.AddTimeBucket(tbk, tbi); err != nil {
+		return nil, false, err
+	}
+	return tbi, true, nil
+}
+
+// schemaString returns the columns of the bucket, with their scales, and
+// its record type, e.g. Price:INT64(2),Size:INT32 FIXED.
+func schemaString(tbi *io.TimeBucketInfo) string {
+	scales := tbi.GetElementScales()
+	columns := make([]string, 0, len(scales))
+	for i, ds := range tbi.GetDataShapes() {
+		column := ds.String()
+		if scales[i] != 0 {
+			column += fmt.Sprintf("(%d)", scales[i])
+		}
+		columns = append(columns, column)
+	}
+	return strings.Join(columns, ",") + " " + tbi.GetRecordType().String()
+}
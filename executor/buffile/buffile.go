@@ -33,11 +33,17 @@ func New(filePath string) (*BufferedFile, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewFromFile(fp), nil
+}
+
+// NewFromFile returns the BufferedFile of the file fp opened for read and
+// write, which is closed by Close.
+func NewFromFile(fp *os.File) *BufferedFile {
 	blockSize := defaultBlockSize
 	return &BufferedFile{
 		fp:        fp,
 		blockSize: blockSize,
-	}, nil
+	}
 }
 
 func (f *BufferedFile) Close() error {
@@ -45,6 +51,11 @@ func (f *BufferedFile) Close() error {
 	return f.fp.Close()
 }
 
+// Flush writes the buffered data to the file, which is left open.
+func (f *BufferedFile) Flush() error {
+	return f.writeBuffer()
+}
+
 func (f *BufferedFile) readBuffer(offset int64, size int) error {
 	// we always read from block boundary
 	readOffset := offset - offset%int64(f.blockSize)
@@ -108,3 +119,20 @@ func (f *BufferedFile) WriteAt(data []byte, offset int64) (int, error) {
 	n := copy(f.buffer[writePos:], data)
 	return n, nil
 }
+
+// ReadAt reads the data at offset from the beginning of the file, including
+// the data written to the buffer that does not reach to disk yet.
+func (f *BufferedFile) ReadAt(data []byte, offset int64) (int, error) {
+	if err := f.ensureBuffer(data, offset); err != nil {
+		return 0, err
+	}
+	readPos := offset - f.bufferOffset
+	if readPos >= int64(len(f.buffer)) {
+		return 0, io.EOF
+	}
+	n := copy(data, f.buffer[readPos:])
+	if n < len(data) {
+		return n, io.EOF
+	}
+	return n, nil
+}
@@ -1,6 +1,7 @@
 package buffile
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -59,3 +60,34 @@ func (t *TestSuite) TestBufferedFile(c *C) {
 	c.Check(fs.Size(), Equals, int64(1024*1024))
 	fp.Close()
 }
+
+func (t *TestSuite) TestBufferedFileReadAt(c *C) {
+	filePath := filepath.Join(c.MkDir(), "test.bin")
+	fp, err := os.OpenFile(filePath, os.O_CREATE|os.O_RDWR, 0700)
+	c.Assert(err, IsNil)
+	c.Assert(fp.Truncate(1024), IsNil)
+	_, err = fp.WriteAt([]byte{0xbb}, 512)
+	c.Assert(err, IsNil)
+	fp.Close()
+
+	bf, err := New(filePath)
+	c.Assert(err, IsNil)
+	defer bf.Close()
+
+	// the data written to the buffer is read before reaching to disk
+	bf.WriteAt([]byte{0xaa, 0xaa}, 128)
+	data := make([]byte, 3)
+	n, err := bf.ReadAt(data, 127)
+	c.Assert(err, IsNil)
+	c.Assert(n, Equals, 3)
+	c.Assert(data, DeepEquals, []byte{0x00, 0xaa, 0xaa})
+
+	n, err = bf.ReadAt(data[:1], 512)
+	c.Assert(err, IsNil)
+	c.Assert(data[:n], DeepEquals, []byte{0xbb})
+
+	// reading short at the end of file
+	n, err = bf.ReadAt(data, 1023)
+	c.Assert(err, Equals, io.EOF)
+	c.Assert(n, Equals, 1)
+}
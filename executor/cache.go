@@ -18,8 +18,10 @@ const WriteChannelCommandDepth = 1000000
 // and writes it to WAL when flush() is called
 type TransactionPipe struct {
 	tgID         int64                  // Current transaction group ID
+	pendingBytes int64                  // Size of the data in the write channel
 	writeChannel chan *wal.WriteCommand // Channel for write commands
 	flushChannel chan chan struct{}     // Channel for flush request
+	syncChannel  chan *syncRequest      // Channel for sync request
 }
 
 // NewTransactionPipe creates a new transaction pipe that channels all
@@ -29,6 +31,7 @@ func NewTransactionPipe() *TransactionPipe {
 	// Allocate the write channel with enough depth to allow all conceivable writers concurrent access
 	tgc.writeChannel = make(chan *wal.WriteCommand, WriteChannelCommandDepth)
 	tgc.flushChannel = make(chan chan struct{}, WriteChannelCommandDepth)
+	tgc.syncChannel = make(chan *syncRequest, WriteChannelCommandDepth)
 	tgc.newTGID()
 	return tgc
 }
@@ -48,3 +51,14 @@ func (tgc *TransactionPipe) IncrementTGID() int64 {
 func (tgc *TransactionPipe) TGID() int64 {
 	return atomic.LoadInt64(&tgc.tgID)
 }
+
+// write queues the write command to be flushed
+func (tgc *TransactionPipe) write(cc *wal.WriteCommand) {
+	atomic.AddInt64(&tgc.pendingBytes, int64(len(cc.Data)))
+	tgc.writeChannel <- cc
+}
+
+// PendingBytes returns the size of the data written but not flushed yet
+func (tgc *TransactionPipe) PendingBytes() int64 {
+	return atomic.LoadInt64(&tgc.pendingBytes)
+}
@@ -1,23 +1,35 @@
 package executor
 
-import "os"
+import (
+	"os"
 
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// CachedFP keeps the year file last written by the WAL replay open for
+// the following writes to the same file.  The files are opened as data
+// files, so that a compressed file is written at the offsets of its
+// uncompressed data.
 type CachedFP struct {
 	fileName string
-	fp       *os.File
+	fp       io.DataFile
 }
 
 func NewCachedFP() *CachedFP {
 	return new(CachedFP)
 }
 
-func (cfp *CachedFP) GetFP(fileName string) (fp *os.File, err error) {
+// GetFP returns the year file at fileName, closing the one opened before
+// if it is another file.
+func (cfp *CachedFP) GetFP(fileName string) (fp io.DataFile, err error) {
 	if fileName == cfp.fileName {
 		return cfp.fp, nil
-	} else if len(cfp.fileName) != 0 {
-		cfp.fp.Close()
 	}
-	cfp.fp, err = os.OpenFile(fileName, os.O_RDWR, 0700)
+	// a compressed file writes its buffered blocks on close
+	if err = cfp.Close(); err != nil {
+		return nil, err
+	}
+	cfp.fp, err = io.OpenDataFile(fileName, os.O_RDWR)
 	if err != nil {
 		return nil, err
 	}
@@ -26,8 +38,10 @@ func (cfp *CachedFP) GetFP(fileName string) (fp *os.File, err error) {
 }
 
 func (cfp *CachedFP) Close() error {
-	if cfp.fp != nil {
-		return cfp.fp.Close()
+	if cfp.fp == nil {
+		return nil
 	}
-	return nil
+	fp := cfp.fp
+	cfp.fp, cfp.fileName = nil, ""
+	return fp.Close()
 }
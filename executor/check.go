@@ -0,0 +1,280 @@
+package executor
+
+import (
+	"bufio"
+	"fmt"
+	goio "io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/io/blockfile"
+)
+
+// FileCheck is the result of the integrity check of a data file.
+type FileCheck struct {
+	Path string
+	// Key is the {Symbol}/{Timeframe}/{AttributeGroup} of the file
+	Key  string
+	Year int
+	// Problems describes the corruptions found, empty if the file is intact
+	Problems []string
+	// Repaired is true if the corruptions in the records are repaired
+	Repaired bool
+}
+
+// OK returns true if no corruption is found in the file.
+func (fc *FileCheck) OK() bool {
+	return len(fc.Problems) == 0
+}
+
+func (fc *FileCheck) problem(format string, args ...interface{}) {
+	fc.Problems = append(fc.Problems, fmt.Sprintf(format, args...))
+}
+
+// CheckDataDir checks the integrity of all the data files under rootDir.
+// See CheckDataFile for the details.
+func CheckDataDir(rootDir string, repair bool) (checks []*FileCheck, err error) {
+	err = filepath.Walk(rootDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() && filepath.Ext(path) == ".bin" {
+			fc, err := CheckDataFile(rootDir, path, repair)
+			if err != nil {
+				return err
+			}
+			checks = append(checks, fc)
+		}
+		return nil
+	})
+	return checks, err
+}
+
+// CheckDataFile checks the header of the data file, that its size matches
+// the timeframe and the record length, and that each record has the index
+// of its position in the file.  The data of the variable length records
+// must be within the file as well.  The size of a compressed file is not
+// checked, its blocks are instead, and the corrupt ones are recovered from
+// their last intact frame on repair.
+//
+// If repair is true, the records from the first corrupt one onward
+// are cleared, and the file size is restored, so the file has only
+// the records before the corruption.  The header is never repaired,
+// as the layout of the records is unknown without it.
+//
+// The returned error is only for the failure to repair.
+func CheckDataFile(rootDir, filePath string, repair bool) (fc *FileCheck, err error) {
+	fc = &FileCheck{Path: filePath}
+	rel, _ := filepath.Rel(rootDir, filePath)
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) != 4 {
+		fc.problem("unexpected path, not {Symbol}/{Timeframe}/{AttributeGroup}/{Year}.bin")
+		return fc, nil
+	}
+	fc.Key = strings.Join(parts[:3], "/")
+	fc.Year, err = strconv.Atoi(strings.TrimSuffix(parts[3], ".bin"))
+	if err != nil {
+		fc.problem("unexpected file name %s", parts[3])
+		return fc, nil
+	}
+
+	flag := os.O_RDONLY
+	if repair {
+		flag = os.O_RDWR
+	}
+	fp, err := os.OpenFile(filePath, flag, 0600)
+	if err != nil {
+		fc.problem("failed to open: %v", err)
+		return fc, nil
+	}
+	defer fp.Close()
+	fi, err := fp.Stat()
+	if err != nil {
+		fc.problem("failed to stat: %v", err)
+		return fc, nil
+	}
+	size := fi.Size()
+	if size < io.Headersize {
+		fc.problem("truncated header, %d bytes", size)
+		return fc, nil
+	}
+
+	var buffer [io.Headersize]byte
+	if _, err = fp.ReadAt(buffer[:], 0); err != nil {
+		fc.problem("failed to read the header: %v", err)
+		return fc, nil
+	}
+	header := (*io.Header)(unsafe.Pointer(&buffer))
+	if !fc.checkHeader(header, parts[1]) {
+		return fc, nil
+	}
+
+	tf := time.Duration(header.Timeframe)
+	recordLen := header.RecordLength
+	expectedSize := io.FileSize(tf, fc.Year, int(recordLen))
+
+	// the records of a compressed file are read uncompressed from its blocks
+	var data goio.ReaderAt = fp
+	var bf *blockfile.File
+	if header.Codec != int64(blockfile.None) {
+		bf, err = blockfile.New(fp, flag, blockfile.Codec(header.Codec), io.Headersize, expectedSize-io.Headersize)
+		if err != nil {
+			fc.problem("%v", err)
+			return fc, nil
+		}
+		defer bf.Close()
+		corrupt, err := bf.CorruptBlocks()
+		if err != nil {
+			fc.problem("failed to read the blocks: %v", err)
+			return fc, nil
+		}
+		for _, block := range corrupt {
+			fc.problem("block %d has a corrupt frame", block)
+		}
+		data, size = bf, expectedSize
+	} else if size < expectedSize {
+		fc.problem("truncated, %d bytes of %d", size, expectedSize)
+	} else if size > expectedSize && io.EnumRecordType(header.RecordType) == io.FIXED {
+		fc.problem("%d bytes after the last record", size-expectedSize)
+	}
+
+	// scan the records up to the first corrupt one
+	end := expectedSize
+	if size < end {
+		end = size
+	}
+	r := bufio.NewReaderSize(goio.NewSectionReader(data, io.Headersize, end-io.Headersize), 1<<20)
+	record := make([]byte, recordLen)
+	validEnd := int64(io.Headersize) // end of the valid records
+	dataEnd := expectedSize          // end of the variable length data
+	for offset := int64(io.Headersize); offset+recordLen <= end; offset += recordLen {
+		if _, err = goio.ReadFull(r, record); err != nil {
+			fc.problem("failed to read the record at offset %d: %v", offset, err)
+			break
+		}
+		index := io.ToInt64(record)
+		if !fc.checkRecord(offset, index, recordLen) {
+			break
+		}
+		if io.EnumRecordType(header.RecordType) == io.VARIABLE && index != 0 {
+			dataOffset, dataLen := io.ToInt64(record[8:]), io.ToInt64(record[16:])
+			if dataOffset < expectedSize || dataLen < 0 || dataOffset+dataLen > size {
+				fc.problem("record at offset %d has data out of the file, %d bytes at offset %d",
+					offset, dataLen, dataOffset)
+				break
+			}
+			if dataOffset+dataLen > dataEnd {
+				dataEnd = dataOffset + dataLen
+			}
+		}
+		validEnd = offset + recordLen
+	}
+
+	if !repair || fc.OK() {
+		return fc, nil
+	}
+	if bf != nil {
+		err = repairCompressedFile(bf, validEnd, end)
+	} else {
+		err = repairDataFile(fp, validEnd, end, dataEnd)
+	}
+	if err != nil {
+		return fc, fmt.Errorf("failed to repair %s: %v", filePath, err)
+	}
+	fc.Repaired = true
+	return fc, nil
+}
+
+// checkHeader returns true if the header is intact, so that
+// the records can be checked.
+func (fc *FileCheck) checkHeader(header *io.Header, timeframe string) bool {
+	ok := len(fc.Problems)
+	if header.Version != io.FileinfoVersion {
+		fc.problem("header has version %d, expected %d", header.Version, io.FileinfoVersion)
+	}
+	if int(header.Year) != fc.Year {
+		fc.problem("header has year %d", header.Year)
+	}
+	if tf := utils.TimeframeFromString(timeframe); tf == nil || int64(tf.Duration) != header.Timeframe {
+		fc.problem("header has timeframe %v", time.Duration(header.Timeframe))
+	}
+	if header.NElements < 1 || header.NElements > int64(len(header.ElementTypes)) {
+		fc.problem("header has %d elements", header.NElements)
+		return false
+	}
+	fieldLen := 0
+	for i := 0; i < int(header.NElements); i++ {
+		size := io.EnumElementType(header.ElementTypes[i]).Size()
+		if size == 0 {
+			fc.problem("header has invalid type %d for element %d", header.ElementTypes[i], i)
+		}
+		fieldLen += size
+	}
+	switch io.EnumRecordType(header.RecordType) {
+	case io.FIXED:
+		if expected := int64(io.AlignedSize(fieldLen)) + 8; header.RecordLength != expected {
+			fc.problem("header has record length %d, expected %d", header.RecordLength, expected)
+		}
+	case io.VARIABLE:
+		if header.RecordLength != 24 {
+			fc.problem("header has record length %d, expected 24", header.RecordLength)
+		}
+	default:
+		fc.problem("header has invalid record type %d", header.RecordType)
+	}
+	return len(fc.Problems) == ok
+}
+
+// checkRecord returns true if the fixed length record at the offset
+// is either empty or has the index of the offset.
+func (fc *FileCheck) checkRecord(offset, index int64, recordLen int64) bool {
+	if index != 0 && io.IndexToOffset(index, int32(recordLen)) != offset {
+		fc.problem("record at offset %d has index %d out of place", offset, index)
+		return false
+	}
+	return true
+}
+
+// repairDataFile clears the records in [validEnd, end), and restores
+// the file size to dataEnd, where the data of the last valid record ends.
+func repairDataFile(fp *os.File, validEnd, end, dataEnd int64) error {
+	if err := clearRecords(fp, validEnd, end); err != nil {
+		return err
+	}
+	if err := fp.Truncate(dataEnd); err != nil {
+		return err
+	}
+	return fp.Sync()
+}
+
+// repairCompressedFile recovers the corrupt blocks from their last intact
+// frame, and clears the records in [validEnd, end).
+func repairCompressedFile(bf *blockfile.File, validEnd, end int64) error {
+	if err := bf.Repair(); err != nil {
+		return err
+	}
+	if err := clearRecords(bf, validEnd, end); err != nil {
+		return err
+	}
+	return bf.Sync()
+}
+
+func clearRecords(w goio.WriterAt, validEnd, end int64) error {
+	zeroes := make([]byte, 1<<20)
+	for offset := validEnd; offset < end; offset += int64(len(zeroes)) {
+		n := end - offset
+		if n > int64(len(zeroes)) {
+			n = int64(len(zeroes))
+		}
+		if _, err := w.WriteAt(zeroes[:n], offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+type CheckTests struct {
+	rootDir string
+}
+
+var _ = Suite(&CheckTests{})
+
+func (s *CheckTests) SetUpTest(c *C) {
+	s.rootDir = c.MkDir()
+}
+
+// makeDataFile creates a 1H OHLC file of 2020 with the records at the indexes.
+func (s *CheckTests) makeDataFile(c *C, symbol string, indexes ...int64) *io.TimeBucketInfo {
+	dir := filepath.Join(s.rootDir, symbol, "1H", "OHLC")
+	c.Assert(os.MkdirAll(dir, 0700), IsNil)
+	dsv := io.NewDataShapeVector(
+		[]string{"Open", "High", "Low", "Close"},
+		[]io.EnumElementType{io.FLOAT32, io.FLOAT32, io.FLOAT32, io.FLOAT32},
+	)
+	tbi := io.NewTimeBucketInfo(*utils.TimeframeFromString("1H"), dir, "", 2020, dsv, io.FIXED)
+
+	fp, err := os.Create(tbi.Path)
+	c.Assert(err, IsNil)
+	defer fp.Close()
+	c.Assert(io.WriteHeader(fp, tbi), IsNil)
+	c.Assert(fp.Truncate(io.FileSize(tbi.GetTimeframe(), 2020, int(tbi.GetRecordLength()))), IsNil)
+	for _, index := range indexes {
+		s.writeIndex(c, tbi, io.IndexToOffset(index, tbi.GetRecordLength()), index)
+	}
+	return tbi
+}
+
+func (s *CheckTests) writeIndex(c *C, tbi *io.TimeBucketInfo, offset, index int64) {
+	fp, err := os.OpenFile(tbi.Path, os.O_WRONLY, 0600)
+	c.Assert(err, IsNil)
+	defer fp.Close()
+	record := make([]byte, tbi.GetRecordLength())
+	copy(record, io.DataToByteSlice(index))
+	_, err = fp.WriteAt(record, offset)
+	c.Assert(err, IsNil)
+}
+
+func (s *CheckTests) readIndex(c *C, tbi *io.TimeBucketInfo, offset int64) int64 {
+	fp, err := os.Open(tbi.Path)
+	c.Assert(err, IsNil)
+	defer fp.Close()
+	buf := make([]byte, 8)
+	_, err = fp.ReadAt(buf, offset)
+	c.Assert(err, IsNil)
+	return io.ToInt64(buf)
+}
+
+func (s *CheckTests) TestIntact(c *C) {
+	s.makeDataFile(c, "AAPL", 1, 2, 100)
+	s.makeDataFile(c, "TSLA")
+
+	checks, err := CheckDataDir(s.rootDir, false)
+	c.Assert(err, IsNil)
+	c.Assert(checks, HasLen, 2)
+	c.Assert(checks[0].Key, Equals, "AAPL/1H/OHLC")
+	c.Assert(checks[0].Year, Equals, 2020)
+	c.Assert(checks[0].OK(), Equals, true)
+	c.Assert(checks[1].Key, Equals, "TSLA/1H/OHLC")
+	c.Assert(checks[1].OK(), Equals, true)
+}
+
+func (s *CheckTests) TestIndexOutOfPlace(c *C) {
+	tbi := s.makeDataFile(c, "AAPL", 1, 2, 100)
+	rl := tbi.GetRecordLength()
+	s.writeIndex(c, tbi, io.IndexToOffset(50, rl), 51)
+
+	fc, err := CheckDataFile(s.rootDir, tbi.Path, false)
+	c.Assert(err, IsNil)
+	c.Assert(fc.Problems, HasLen, 1)
+	c.Assert(fc.Repaired, Equals, false)
+
+	// the records from the corrupt one onward are cleared
+	fc, err = CheckDataFile(s.rootDir, tbi.Path, true)
+	c.Assert(err, IsNil)
+	c.Assert(fc.Repaired, Equals, true)
+	c.Assert(s.readIndex(c, tbi, io.IndexToOffset(2, rl)), Equals, int64(2))
+	c.Assert(s.readIndex(c, tbi, io.IndexToOffset(50, rl)), Equals, int64(0))
+	c.Assert(s.readIndex(c, tbi, io.IndexToOffset(100, rl)), Equals, int64(0))
+
+	fc, err = CheckDataFile(s.rootDir, tbi.Path, false)
+	c.Assert(err, IsNil)
+	c.Assert(fc.OK(), Equals, true)
+}
+
+func (s *CheckTests) TestTruncated(c *C) {
+	tbi := s.makeDataFile(c, "AAPL", 1, 2, 100)
+	rl := tbi.GetRecordLength()
+	size := io.FileSize(tbi.GetTimeframe(), 2020, int(rl))
+	c.Assert(os.Truncate(tbi.Path, io.IndexToOffset(100, rl)+4), IsNil)
+
+	fc, err := CheckDataFile(s.rootDir, tbi.Path, true)
+	c.Assert(err, IsNil)
+	c.Assert(fc.OK(), Equals, false)
+	c.Assert(fc.Repaired, Equals, true)
+	fi, err := os.Stat(tbi.Path)
+	c.Assert(err, IsNil)
+	c.Assert(fi.Size(), Equals, size)
+	c.Assert(s.readIndex(c, tbi, io.IndexToOffset(2, rl)), Equals, int64(2))
+	c.Assert(s.readIndex(c, tbi, io.IndexToOffset(100, rl)), Equals, int64(0))
+
+	fc, err = CheckDataFile(s.rootDir, tbi.Path, false)
+	c.Assert(err, IsNil)
+	c.Assert(fc.OK(), Equals, true)
+}
+
+func (s *CheckTests) TestCorruptHeader(c *C) {
+	tbi := s.makeDataFile(c, "AAPL", 1)
+	fp, err := os.OpenFile(tbi.Path, os.O_WRONLY, 0600)
+	c.Assert(err, IsNil)
+	_, err = fp.WriteAt(io.DataToByteSlice(int64(99)), 0) // version
+	c.Assert(err, IsNil)
+	fp.Close()
+
+	fc, err := CheckDataFile(s.rootDir, tbi.Path, true)
+	c.Assert(err, IsNil)
+	c.Assert(fc.OK(), Equals, false)
+	c.Assert(fc.Repaired, Equals, false)
+
+	c.Assert(os.Truncate(tbi.Path, 100), IsNil)
+	fc, err = CheckDataFile(s.rootDir, tbi.Path, false)
+	c.Assert(err, IsNil)
+	c.Assert(fc.OK(), Equals, false)
+}
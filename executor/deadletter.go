@@ -0,0 +1,247 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/plugins/trigger"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// DeadLetterFileName is the file under the root directory keeping the trigger
+// invocations that failed, one JSON object per line.
+const DeadLetterFileName = "triggers.deadletter"
+
+// deadLetterQueueDepth is the number of dead letters waiting to be written
+// before the next ones are only logged.
+const deadLetterQueueDepth = 1000
+
+// DeadLetter is a trigger invocation that failed all its attempts, or that was
+// dropped as the trigger was too far behind.
+type DeadLetter struct {
+	ID         int64     `json:"id"`
+	Trigger    string    `json:"trigger"`
+	Key        string    `json:"key"`
+	FirstIndex int64     `json:"first_index"`
+	LastIndex  int64     `json:"last_index"`
+	Time       time.Time `json:"time"`
+	Error      string    `json:"error"`
+	// Records are the written records, to fire the trigger again on
+	Records [][]byte `json:"records,omitempty"`
+}
+
+// DeadLetterQueue persists the dead letters to a file, from its own goroutine
+// so that adding them never blocks the triggers or the writes.
+type DeadLetterQueue struct {
+	path string
+	c    chan *DeadLetter
+	// mu serializes the accesses to the file
+	mu     sync.Mutex
+	lastID int64
+}
+
+// NewDeadLetterQueue returns the DeadLetterQueue persisted to the file.
+func NewDeadLetterQueue(path string) *DeadLetterQueue {
+	q := &DeadLetterQueue{
+		path: path,
+		c:    make(chan *DeadLetter, deadLetterQueueDepth),
+	}
+	go q.run()
+	return q
+}
+
+func newDeadLetter(name, key string, records []trigger.Record, reason string) *DeadLetter {
+	dl := &DeadLetter{
+		Trigger: name,
+		Key:     key,
+		Time:    time.Now().UTC(),
+		Error:   reason,
+		Records: make([][]byte, len(records)),
+	}
+	for i := range records {
+		dl.Records[i] = records[i]
+	}
+	if len(records) > 0 {
+		dl.FirstIndex = records[0].Index()
+		dl.LastIndex = records[len(records)-1].Index()
+	}
+	return dl
+}
+
+// Add queues the dead letter to be persisted.  It is only logged if the queue
+// is nil or full.
+func (q *DeadLetterQueue) Add(dl *DeadLetter) {
+	if q != nil {
+		select {
+		case q.c <- dl:
+			return
+		default:
+		}
+	}
+	log.Error("lost the dead letter of trigger %s on %s indexes %d to %d",
+		dl.Trigger, dl.Key, dl.FirstIndex, dl.LastIndex)
+}
+
+func (q *DeadLetterQueue) run() {
+	for dl := range q.c {
+		if err := q.write(dl); err != nil {
+			log.Error("failed to write the dead letter of trigger %s on %s indexes %d to %d: %v",
+				dl.Trigger, dl.Key, dl.FirstIndex, dl.LastIndex, err)
+		}
+	}
+}
+
+func (q *DeadLetterQueue) write(dl *DeadLetter) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// the ids are unique as long as the clock does not go back
+	dl.ID = time.Now().UnixNano()
+	if dl.ID <= q.lastID {
+		dl.ID = q.lastID + 1
+	}
+	q.lastID = dl.ID
+
+	buf, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	fp, err := os.OpenFile(q.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = fp.Write(append(buf, '\n')); err != nil {
+		fp.Close()
+		return err
+	}
+	if err = fp.Sync(); err != nil {
+		fp.Close()
+		return err
+	}
+	log.Info("dead-lettered trigger %s on %s indexes %d to %d",
+		dl.Trigger, dl.Key, dl.FirstIndex, dl.LastIndex)
+	return fp.Close()
+}
+
+// List returns the persisted dead letters, oldest first.
+func (q *DeadLetterQueue) List() ([]*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.read()
+}
+
+func (q *DeadLetterQueue) read() ([]*DeadLetter, error) {
+	fp, err := os.Open(q.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	var out []*DeadLetter
+	scanner := bufio.NewScanner(fp)
+	scanner.Buffer(nil, 1<<30)
+	for scanner.Scan() {
+		dl := &DeadLetter{}
+		if err := json.Unmarshal(scanner.Bytes(), dl); err != nil {
+			return nil, fmt.Errorf("corrupted dead letter file %s: %v", q.path, err)
+		}
+		out = append(out, dl)
+	}
+	return out, scanner.Err()
+}
+
+// Redrive fires the triggers again on the dead letters of the ids, or on all
+// of them if none is given, and removes the ones that succeed.  It returns the
+// dead letters that are left for the ids.
+func (q *DeadLetterQueue) Redrive(matchers []*trigger.TriggerMatcher, ids ...int64) ([]*DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letters, err := q.read()
+	if err != nil {
+		return nil, err
+	}
+	selected := map[int64]bool{}
+	for _, id := range ids {
+		selected[id] = true
+	}
+	triggers := map[string]trigger.Trigger{}
+	for _, tmatcher := range matchers {
+		triggers[triggerName(tmatcher)] = tmatcher.Trigger
+	}
+
+	var kept, failed []*DeadLetter
+	for _, dl := range letters {
+		if len(ids) > 0 && !selected[dl.ID] {
+			kept = append(kept, dl)
+			continue
+		}
+		trig, ok := triggers[dl.Trigger]
+		if !ok {
+			dl.Error = "trigger is not loaded"
+		} else if err := redrive(trig, dl); err != nil {
+			dl.Error = err.Error()
+		} else {
+			log.Info("redrove trigger %s on %s indexes %d to %d",
+				dl.Trigger, dl.Key, dl.FirstIndex, dl.LastIndex)
+			continue
+		}
+		kept = append(kept, dl)
+		failed = append(failed, dl)
+	}
+	return failed, q.rewrite(kept)
+}
+
+func redrive(trig trigger.Trigger, dl *DeadLetter) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("trigger %s failed again on %s, recovering from %v\n%s", dl.Trigger, dl.Key, r, string(debug.Stack()))
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	records := make([]trigger.Record, len(dl.Records))
+	for i := range dl.Records {
+		records[i] = dl.Records[i]
+	}
+	return trigger.Fire(trig, dl.Key, records)
+}
+
+// rewrite atomically replaces the file with the dead letters.
+func (q *DeadLetterQueue) rewrite(letters []*DeadLetter) error {
+	tmp := q.path + ".tmp"
+	fp, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(fp)
+	for _, dl := range letters {
+		buf, err := json.Marshal(dl)
+		if err != nil {
+			fp.Close()
+			return err
+		}
+		w.Write(append(buf, '\n'))
+	}
+	if err = w.Flush(); err == nil {
+		err = fp.Sync()
+	}
+	if cerr := fp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, q.path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(q.path))
+}
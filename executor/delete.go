@@ -1,11 +1,14 @@
 package executor
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/alpacahq/marketstore/v4/planner"
 	. "github.com/alpacahq/marketstore/v4/utils/io"
@@ -57,60 +60,177 @@ func (de *deleter) Delete() (err error) {
 func (de *deleter) delete(iop *ioplan) (err error) {
 	for _, fp := range iop.FilePlan {
 		filePath := fp.FullPath
-		f, err := os.OpenFile(filePath, os.O_RDWR, 0666)
+		f, err := OpenDataFile(filePath, os.O_RDWR)
 		if err != nil {
 			log.Error("Read: opening %s\n%s", filePath, err)
 			return err
 		}
-		defer f.Close()
-
-		seekerFunc := func(offset int64) error {
-			if _, err = f.Seek(fp.Offset, io.SeekStart); err != nil {
-				log.Error("Read: seeking in %s\n%s", filePath, err)
-				return err
-			}
-			return nil
-		}
-
-		if err := seekerFunc(fp.Offset); err != nil {
-			return err
-		}
 
 		/*
 			Read in the whole target data area to find the non-zero index locations
 			This will preserve the existing holes in the data area at the expense of
-			a potentially large number of file seeks
+			a potentially large number of writes
 		*/
 		bufferSize := int(fp.Length + int64(iop.RecordLen))
 		buffer := make([]byte, bufferSize)
-		n, err := f.Read(buffer)
+		n, err := f.ReadAt(buffer, fp.Offset)
 		if err != nil || n != bufferSize {
+			f.Close()
 			return fmt.Errorf("delete(): Short read %d bytes", n)
 		}
 		numRecs := bufferSize / int(iop.RecordLen)
 		zeroRecord := make([]byte, int(iop.RecordLen))
-		var isContiguous bool
 		for i := 0; i < numRecs; i++ {
 			epochLoc := i * int(iop.RecordLen)
-			index := int64(binary.LittleEndian.Uint64(buffer[epochLoc:]))
-			switch {
-			case index != 0 && !isContiguous:
-				if err := seekerFunc(int64(epochLoc) + fp.Offset); err != nil {
-					return err
-				}
-				isContiguous = true
-				fallthrough
-			case index != 0 && isContiguous:
-				n, err := f.Write(zeroRecord)
-				if err != nil || n != int(iop.RecordLen) {
-					return fmt.Errorf("delete(): Short write %d bytes, error: %s", n, err.Error())
-				}
-			case index == 0:
-				isContiguous = false
+			if binary.LittleEndian.Uint64(buffer[epochLoc:]) == 0 {
+				continue
 			}
+			n, err := f.WriteAt(zeroRecord, fp.Offset+int64(epochLoc))
+			if err != nil || n != int(iop.RecordLen) {
+				f.Close()
+				return fmt.Errorf("delete(): Short write %d bytes, error: %v", n, err)
+			}
+		}
+		// the compressed files are written out on close
+		if err = f.Close(); err != nil {
+			return err
 		}
-		buffer = nil
 	}
 
 	return err
 }
+
+// DeleteRange deletes the records of the bucket from start to end inclusive,
+// returning the number of records deleted.  Like Backup, the pending writes
+// are flushed and synced first, and the commits are frozen while the year
+// files in the range are rewritten, so that neither a write committed later
+// nor a WAL replay brings the records back.  Each year file is rewritten to
+// a temporary file first and renamed over the original, so that a crash
+// leaves either of them as a whole.  The range out of the year files of
+// the bucket deletes nothing.
+//
+// The records of variable length buckets are deleted by their indexes,
+// leaving their data in the file unreferenced.
+func (i *InstanceMetadata) DeleteRange(ctx context.Context, tbk *TimeBucketKey, start, end time.Time) (int, error) {
+	tbi, err := i.CatalogDir.GetLatestTimeBucketInfoFromKey(tbk)
+	if err != nil {
+		return 0, fmt.Errorf("bucket %s not found", tbk.GetItemKey())
+	}
+	if end.Before(start) {
+		return 0, fmt.Errorf("end %v is before start %v", end, start)
+	}
+	subDir, err := i.CatalogDir.GetOwningSubDirectory(filepath.Join(filepath.Dir(tbi.Path), "1970.bin"))
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	err = i.WALFile.requestSync(ctx, func() error {
+		for _, yearFile := range subDir.GetTimeBucketInfoSlice() {
+			n, err := deleteRangeInFile(yearFile, start, end)
+			deleted += n
+			if err != nil {
+				return fmt.Errorf("failed to delete from %s: %v", yearFile.Path, err)
+			}
+		}
+		return nil
+	})
+	// the latest record may be deleted
+	ForgetBucketTail(tbk)
+	return deleted, err
+}
+
+// deleteRangeInFile zeroes the records of the year file from start to end,
+// returning the number of records deleted.
+func deleteRangeInFile(tbi *TimeBucketInfo, start, end time.Time) (int, error) {
+	tf := tbi.GetTimeframe()
+	recordLen := int64(tbi.GetRecordLength())
+	year := int(tbi.Year)
+	fileStart := int64(Headersize)
+	fileEnd := FileSize(tf, year, int(recordLen))
+
+	startOffset, endOffset := fileStart, fileEnd
+	if start.Year() > year || end.Year() < year {
+		return 0, nil
+	}
+	if start.Year() == year {
+		startOffset = TimeToOffset(start, tf, int32(recordLen))
+	}
+	if end.Year() == year {
+		endOffset = TimeToOffset(end, tf, int32(recordLen)) + recordLen
+	}
+	if startOffset < fileStart {
+		startOffset = fileStart
+	}
+	if endOffset > fileEnd {
+		endOffset = fileEnd
+	}
+	if startOffset >= endOffset {
+		return 0, nil
+	}
+
+	f, err := OpenDataFile(tbi.Path, os.O_RDONLY)
+	if err != nil {
+		return 0, err
+	}
+	buffer := make([]byte, endOffset-startOffset)
+	n, err := f.ReadAt(buffer, startOffset)
+	f.Close()
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	buffer = buffer[:n-n%int(recordLen)]
+
+	deleted := 0
+	for pos := 0; pos < len(buffer); pos += int(recordLen) {
+		if binary.LittleEndian.Uint64(buffer[pos:]) == 0 {
+			continue
+		}
+		for j := pos; j < pos+int(recordLen); j++ {
+			buffer[j] = 0
+		}
+		deleted++
+	}
+	if deleted == 0 {
+		return 0, nil
+	}
+
+	info, err := os.Stat(tbi.Path)
+	if err != nil {
+		return 0, err
+	}
+	tmpPath := tbi.Path + ".tmp"
+	os.Remove(tmpPath)
+	if err = copyFile(tbi.Path, tmpPath, info.Mode().Perm()); err != nil {
+		return 0, err
+	}
+	if err = writeAndSync(tmpPath, buffer, startOffset); err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if err = os.Rename(tmpPath, tbi.Path); err != nil {
+		os.Remove(tmpPath)
+		return 0, err
+	}
+	if err = syncDir(filepath.Dir(tbi.Path)); err != nil {
+		return 0, err
+	}
+	log.Info("deleted %d records from %s", deleted, tbi.Path)
+	return deleted, nil
+}
+
+func writeAndSync(filePath string, data []byte, offset int64) error {
+	f, err := OpenDataFile(filePath, os.O_RDWR)
+	if err != nil {
+		return err
+	}
+	if _, err = f.WriteAt(data, offset); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
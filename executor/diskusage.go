@@ -0,0 +1,68 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/catalog"
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// DiskUsage is the size and the number of the year files of a timeframe.
+type DiskUsage struct {
+	Bytes int64
+	Files int
+}
+
+// CollectDiskUsage returns the disk usage of the year files of the catalog
+// by timeframe.  The files are only stat'ed, and those removed since listed
+// are skipped.
+func CollectDiskUsage() map[string]DiskUsage {
+	usage := map[string]DiskUsage{}
+	d := ThisInstance.CatalogDir
+	for _, key := range catalog.ListTimeBucketKeyNames(d) {
+		tbk := io.NewTimeBucketKey(key)
+		timeframe := tbk.GetItemInCategory("Timeframe")
+		subDir, err := d.GetOwningSubDirectory(filepath.Join(tbk.GetPathToYearFiles(d.GetPath()), "1970.bin"))
+		if err != nil {
+			continue
+		}
+		for _, tbi := range subDir.GetTimeBucketInfoSlice() {
+			fi, err := os.Stat(tbi.Path)
+			if err != nil {
+				continue
+			}
+			u := usage[timeframe]
+			u.Bytes += fi.Size()
+			u.Files++
+			usage[timeframe] = u
+		}
+	}
+	return usage
+}
+
+// RunDiskUsage updates the disk usage metrics every interval, forever, so
+// that the scrapes read the last collection rather than walk the files.
+func RunDiskUsage(interval time.Duration) {
+	for {
+		UpdateDiskUsage()
+		time.Sleep(interval)
+	}
+}
+
+// UpdateDiskUsage sets the disk usage metrics to a new collection.
+func UpdateDiskUsage() {
+	usage := CollectDiskUsage()
+	// the timeframes without files any more are removed
+	metrics.DiskBytes.Reset()
+	metrics.DiskFiles.Reset()
+	for timeframe, u := range usage {
+		metrics.DiskBytes.WithLabelValues(timeframe).Set(float64(u.Bytes))
+		metrics.DiskFiles.WithLabelValues(timeframe).Set(float64(u.Files))
+	}
+	metrics.DiskLastCollection.SetToCurrentTime()
+	log.Debug("collected the disk usage of %d timeframes", len(usage))
+}
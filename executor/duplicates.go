@@ -0,0 +1,51 @@
+package executor
+
+import (
+	stdio "io"
+
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// fixedRecordExists returns true if a record is already written at the index
+// of the buffer in a fixed length bucket, whose empty records are all zeros.
+func fixedRecordExists(fp stdio.ReaderAt, offset int64) (bool, error) {
+	buf := make([]byte, 8)
+	if _, err := fp.ReadAt(buf, offset); err != nil && err != stdio.EOF {
+		return false, err
+	}
+	return io.ToInt64(buf) != 0, nil
+}
+
+// dedupVariableRecords removes the records at the same interval ticks as
+// another one from data, sorted by the interval ticks with the existing
+// records first, according to the duplicates policy.  The last record of
+// the same ticks is kept to overwrite, and the first one to reject.
+func dedupVariableRecords(data []byte, varRecLen int, policy string) []byte {
+	if policy != utils.DuplicatesOverwrite && policy != utils.DuplicatesReject {
+		return data
+	}
+	ticks := func(i int) uint32 {
+		return io.ToUInt32(data[(i+1)*varRecLen-4 : (i+1)*varRecLen])
+	}
+
+	numRecords := len(data) / varRecLen
+	out := make([]byte, 0, len(data))
+	removed := 0
+	for i := 0; i < numRecords; i++ {
+		switch {
+		case policy == utils.DuplicatesOverwrite && i+1 < numRecords && ticks(i+1) == ticks(i):
+			removed++
+			continue
+		case policy == utils.DuplicatesReject && i > 0 && ticks(i-1) == ticks(i):
+			removed++
+			continue
+		}
+		out = append(out, data[i*varRecLen:(i+1)*varRecLen]...)
+	}
+	if removed > 0 {
+		metrics.WriteDuplicates.WithLabelValues(policy).Add(float64(removed))
+	}
+	return out
+}
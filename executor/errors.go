@@ -67,3 +67,19 @@ func errReport(base string, msg string) string {
 	log.Error(base, msg)
 	return fmt.Sprintf(base, msg)
 }
+
+// SchemaMismatchError is returned when the schema of an existing bucket
+// is not the one expected.
+type SchemaMismatchError string
+
+func (msg SchemaMismatchError) Error() string {
+	return string(msg)
+}
+
+// UndeclaredSchemaError is returned when a bucket without a declared schema
+// is written with strict_schemas.
+type UndeclaredSchemaError string
+
+func (msg UndeclaredSchemaError) Error() string {
+	return string(msg)
+}
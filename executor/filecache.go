@@ -0,0 +1,158 @@
+package executor
+
+import (
+	"container/list"
+	"os"
+	"sync"
+
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/io/blockfile"
+)
+
+// openFiles are the year files kept open between the reads and the writes,
+// up to max_open_files, so that the files written and read often are not
+// opened each time.  The least recently used are closed past the maximum.
+var openFiles = &fileCache{idle: map[fileKey][]*list.Element{}, lru: list.New()}
+
+type fileKey struct {
+	path string
+	flag int
+}
+
+// openFile is a year file open, used by one reader or writer at a time.
+type openFile struct {
+	key fileKey
+	fp  *os.File
+	// fi is the file opened, to tell if the path was replaced since
+	fi os.FileInfo
+}
+
+type fileCache struct {
+	sync.Mutex
+	// idle are the files not in use by key, the most recently used last
+	idle map[fileKey][]*list.Element
+	// lru are the files not in use, the most recently used first
+	lru *list.List
+	// open is the number of files open, in use or not
+	open int
+}
+
+// acquire returns a file of path opened with flag, reused if one is open
+// and not in use, which is released by release.
+func (c *fileCache) acquire(path string, flag int) (*openFile, error) {
+	key := fileKey{path: path, flag: flag}
+	if f := c.pop(key); f != nil {
+		// the file removed or replaced, e.g. by a delete, is reopened
+		if fi, err := os.Stat(path); err == nil && os.SameFile(fi, f.fi) {
+			return f, nil
+		}
+		c.close(f)
+	}
+
+	fp, err := os.OpenFile(path, flag, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := fp.Stat()
+	if err != nil {
+		fp.Close()
+		return nil, err
+	}
+	c.Lock()
+	c.open++
+	metrics.OpenFiles.Set(float64(c.open))
+	c.Unlock()
+	return &openFile{key: key, fp: fp, fi: fi}, nil
+}
+
+// pop removes the most recently used file of key not in use.
+func (c *fileCache) pop(key fileKey) *openFile {
+	c.Lock()
+	defer c.Unlock()
+	elems := c.idle[key]
+	if len(elems) == 0 {
+		return nil
+	}
+	e := elems[len(elems)-1]
+	c.removeIdle(e)
+	return e.Value.(*openFile)
+}
+
+// release keeps f open for the next use, unless it failed or files are
+// not kept open, and closes the least recently used past the maximum.
+func (c *fileCache) release(f *openFile, failed bool) error {
+	if failed || utils.InstanceConfig.MaxOpenFiles == 0 {
+		return c.close(f)
+	}
+	c.Lock()
+	c.idle[f.key] = append(c.idle[f.key], c.lru.PushFront(f))
+	var evicted []*openFile
+	for c.open-len(evicted) > utils.InstanceConfig.MaxOpenFiles && c.lru.Len() > 0 {
+		e := c.lru.Back()
+		c.removeIdle(e)
+		evicted = append(evicted, e.Value.(*openFile))
+	}
+	c.Unlock()
+
+	for _, f := range evicted {
+		metrics.OpenFileEvictions.Inc()
+		c.close(f)
+	}
+	return nil
+}
+
+// removeIdle removes the element e of a file not in use, with c locked.
+func (c *fileCache) removeIdle(e *list.Element) {
+	c.lru.Remove(e)
+	key := e.Value.(*openFile).key
+	elems := c.idle[key]
+	for i := range elems {
+		if elems[i] == e {
+			elems = append(elems[:i], elems[i+1:]...)
+			break
+		}
+	}
+	if len(elems) == 0 {
+		delete(c.idle, key)
+	} else {
+		c.idle[key] = elems
+	}
+}
+
+func (c *fileCache) close(f *openFile) error {
+	c.forget()
+	return f.fp.Close()
+}
+
+// forget counts out a file closed.
+func (c *fileCache) forget() {
+	c.Lock()
+	c.open--
+	metrics.OpenFiles.Set(float64(c.open))
+	c.Unlock()
+}
+
+// acquireDataFile returns the year file at path opened with flag, from the
+// open files, and the function to release it, with the error of its use if
+// any, closing it if failed.  A compressed file is closed on release, as
+// it buffers the blocks written by itself.
+func acquireDataFile(path string, flag int) (io.DataFile, func(err error) error, error) {
+	f, err := openFiles.acquire(path, flag)
+	if err != nil {
+		return nil, nil, err
+	}
+	df, err := io.NewDataFile(f.fp, flag)
+	if err != nil {
+		openFiles.release(f, true)
+		return nil, nil, err
+	}
+	if cf, ok := df.(*blockfile.File); ok {
+		return cf, func(error) error {
+			openFiles.forget()
+			return cf.Close()
+		}, nil
+	}
+	return f.fp, func(err error) error { return openFiles.release(f, err != nil) }, nil
+}
@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"container/list"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils"
+)
+
+type FileCacheTests struct{}
+
+var _ = Suite(&FileCacheTests{})
+
+func (s *FileCacheTests) TestEvictionAndReopen(c *C) {
+	defer func(max int) { utils.InstanceConfig.MaxOpenFiles = max }(utils.InstanceConfig.MaxOpenFiles)
+	utils.InstanceConfig.MaxOpenFiles = 2
+
+	dir := c.MkDir()
+	paths := make([]string, 3)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, string(rune('a'+i)))
+		c.Assert(ioutil.WriteFile(paths[i], []byte(paths[i]), 0600), IsNil)
+	}
+	cache := &fileCache{idle: map[fileKey][]*list.Element{}, lru: list.New()}
+	read := func(f *openFile) string {
+		buf := make([]byte, 100)
+		n, _ := f.fp.ReadAt(buf, 0)
+		return string(buf[:n])
+	}
+
+	// the files are kept open for reuse, the least recently used closed
+	// past the maximum
+	evictions := testutil.ToFloat64(metrics.OpenFileEvictions)
+	files := map[string]*openFile{}
+	for _, path := range paths {
+		f, err := cache.acquire(path, os.O_RDONLY)
+		c.Assert(err, IsNil)
+		c.Assert(read(f), Equals, path)
+		c.Assert(cache.release(f, false), IsNil)
+		files[path] = f
+	}
+	c.Assert(cache.open, Equals, 2)
+	c.Assert(testutil.ToFloat64(metrics.OpenFileEvictions), Equals, evictions+1)
+
+	f, err := cache.acquire(paths[2], os.O_RDONLY)
+	c.Assert(err, IsNil)
+	c.Assert(f, Equals, files[paths[2]])
+	c.Assert(cache.release(f, false), IsNil)
+
+	// the evicted file is reopened on demand, evicting the next one
+	f, err = cache.acquire(paths[0], os.O_RDONLY)
+	c.Assert(err, IsNil)
+	c.Assert(f, Not(Equals), files[paths[0]])
+	c.Assert(read(f), Equals, paths[0])
+	c.Assert(cache.release(f, false), IsNil)
+	c.Assert(cache.open, Equals, 2)
+	c.Assert(testutil.ToFloat64(metrics.OpenFileEvictions), Equals, evictions+2)
+
+	// the file replaced since is reopened
+	replacement := filepath.Join(dir, "replacement")
+	c.Assert(ioutil.WriteFile(replacement, []byte("replaced"), 0600), IsNil)
+	c.Assert(os.Rename(replacement, paths[2]), IsNil)
+	f, err = cache.acquire(paths[2], os.O_RDONLY)
+	c.Assert(err, IsNil)
+	c.Assert(read(f), Equals, "replaced")
+
+	// the file that failed is closed
+	c.Assert(cache.release(f, true), IsNil)
+	c.Assert(cache.open, Equals, 1)
+	c.Assert(cache.lru.Len(), Equals, 1)
+}
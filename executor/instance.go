@@ -3,6 +3,7 @@ package executor
 import (
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alpacahq/marketstore/v4/catalog"
@@ -13,6 +14,15 @@ import (
 
 var ThisInstance *InstanceMetadata
 
+// setupComplete is set once NewInstanceSetup has returned, which
+// includes the replay of the WAL files left by the previous instances.
+var setupComplete uint32
+
+// SetupComplete returns true once the instance is set up and the WAL replayed.
+func SetupComplete() bool {
+	return atomic.LoadUint32(&setupComplete) == 1
+}
+
 type InstanceMetadata struct {
 	RootDir         string
 	CatalogDir      *catalog.Directory
@@ -22,6 +32,7 @@ type InstanceMetadata struct {
 	ShutdownPending bool
 	WALBypass       bool
 	TriggerMatchers []*trigger.TriggerMatcher
+	DeadLetters     *DeadLetterQueue
 }
 
 func NewInstanceSetup(relRootDir string, options ...bool) {
@@ -56,9 +67,13 @@ func NewInstanceSetup(relRootDir string, options ...bool) {
 	}
 	instanceID := time.Now().UTC().UnixNano()
 	ThisInstance.RootDir = rootDir
+	ThisInstance.DeadLetters = NewDeadLetterQueue(filepath.Join(rootDir, DeadLetterFileName))
 	// Initialize a global catalog
 	if initCatalog {
-		ThisInstance.CatalogDir = catalog.NewDirectory(rootDir)
+		ThisInstance.CatalogDir = catalog.NewDirectory(rootDir, utils.InstanceConfig.StorageTiers...)
+		if err := ThisInstance.CatalogDir.SetSymbolAliases(utils.InstanceConfig.SymbolAliases); err != nil {
+			log.Fatal("Invalid symbol_aliases: %v", err)
+		}
 	}
 	ThisInstance.WALBypass = WALBypass
 	if initWALCache {
@@ -81,4 +96,5 @@ func NewInstanceSetup(relRootDir string, options ...bool) {
 			ThisInstance.WALWg.Add(1)
 		}
 	}
+	atomic.StoreUint32(&setupComplete, 1)
 }
@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/catalog"
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// CollectIngestionLag returns the age at now of the latest record of each
+// symbol, by timeframe and symbol, the oldest over the attribute groups of
+// the symbol.  The empty buckets are skipped.  The latest records are those
+// cached by BucketTail, so only the buckets not yet written or validated
+// since the start are read, by their last record only.
+func CollectIngestionLag(now time.Time) map[string]map[string]time.Duration {
+	lags := map[string]map[string]time.Duration{}
+	for _, key := range catalog.ListTimeBucketKeyNames(ThisInstance.CatalogDir) {
+		tbk := io.NewTimeBucketKey(key)
+		tail, err := BucketTail(tbk)
+		if err != nil {
+			log.Error("failed to read the latest record of %s: %v", key, err)
+			continue
+		}
+		if tail.IsZero() {
+			continue
+		}
+		timeframe, symbol := tbk.GetItemInCategory("Timeframe"), tbk.GetItemInCategory("Symbol")
+		if lags[timeframe] == nil {
+			lags[timeframe] = map[string]time.Duration{}
+		}
+		if lag, ok := lags[timeframe][symbol]; !ok || now.Sub(tail) > lag {
+			lags[timeframe][symbol] = now.Sub(tail)
+		}
+	}
+	return lags
+}
+
+// RunIngestionLag updates the ingestion lag metrics every interval, forever.
+func RunIngestionLag(interval time.Duration) {
+	for {
+		UpdateIngestionLag()
+		time.Sleep(interval)
+	}
+}
+
+// lagLabels are the label values of the ingestion lag metrics set by the
+// last UpdateIngestionLag, the timeframes and the symbols by timeframe.
+var lagLabels struct {
+	sync.Mutex
+	timeframes map[string]bool
+	symbols    map[[2]string]bool
+}
+
+// UpdateIngestionLag sets the ingestion lag metrics to a new collection, by
+// symbol only if the symbol labels are enabled, to keep their cardinality
+// bounded.  The metrics are set in place, rather than reset and set again,
+// so that a scrape in between never misses them.
+func UpdateIngestionLag() {
+	lags := CollectIngestionLag(time.Now())
+	lagLabels.Lock()
+	defer lagLabels.Unlock()
+	timeframes, symbols := map[string]bool{}, map[[2]string]bool{}
+	for timeframe, symbolLags := range lags {
+		oldest, ok := time.Duration(0), false
+		for symbol, lag := range symbolLags {
+			if !ok || lag > oldest {
+				oldest, ok = lag, true
+			}
+			if utils.InstanceConfig.MetricsSymbolLabels {
+				metrics.IngestionSymbolLag.WithLabelValues(symbol, timeframe).Set(lag.Seconds())
+				symbols[[2]string{symbol, timeframe}] = true
+			}
+		}
+		metrics.IngestionLag.WithLabelValues(timeframe).Set(oldest.Seconds())
+		timeframes[timeframe] = true
+	}
+	// the timeframes and symbols without records any more are removed
+	for timeframe := range lagLabels.timeframes {
+		if !timeframes[timeframe] {
+			metrics.IngestionLag.DeleteLabelValues(timeframe)
+		}
+	}
+	for labels := range lagLabels.symbols {
+		if !symbols[labels] {
+			metrics.IngestionSymbolLag.DeleteLabelValues(labels[0], labels[1])
+		}
+	}
+	lagLabels.timeframes, lagLabels.symbols = timeframes, symbols
+	log.Debug("collected the ingestion lag of %d timeframes", len(lags))
+}
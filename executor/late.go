@@ -0,0 +1,100 @@
+package executor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/planner"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// bucketTails caches the time of the latest record of the buckets, in Unix
+// nanoseconds by bucket key, read from the bucket on first use and advanced
+// by the writes.
+var bucketTails = struct {
+	sync.Mutex
+	m map[string]int64
+}{m: map[string]int64{}}
+
+// BucketTail returns the time of the latest record written to the bucket
+// of tbk, or the zero time if it does not exist or is empty.
+func BucketTail(tbk *io.TimeBucketKey) (time.Time, error) {
+	key := tbk.GetItemKey()
+	bucketTails.Lock()
+	tail, ok := bucketTails.m[key]
+	bucketTails.Unlock()
+	if ok {
+		return time.Unix(0, tail), nil
+	}
+
+	if _, err := ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(tbk); err != nil {
+		// not cached, so that it is read once the bucket is created
+		return time.Time{}, nil
+	}
+	t, err := readBucketTail(tbk)
+	if err != nil {
+		return time.Time{}, err
+	}
+	advanceBucketTail(tbk, []time.Time{t}, true)
+	return t, nil
+}
+
+// ForgetBucketTail drops the cached tail of the bucket of tbk, which is read
+// again from the bucket on next use, once records of the bucket are deleted
+// or the bucket itself is.
+func ForgetBucketTail(tbk *io.TimeBucketKey) {
+	bucketTails.Lock()
+	delete(bucketTails.m, tbk.GetItemKey())
+	bucketTails.Unlock()
+}
+
+// readBucketTail reads the time of the last record of the bucket.
+func readBucketTail(tbk *io.TimeBucketKey) (time.Time, error) {
+	q := planner.NewQuery(ThisInstance.CatalogDir)
+	q.AddTargetKey(tbk)
+	q.SetRowLimit(io.LAST, 1)
+	parsed, err := q.Parse()
+	if err != nil {
+		// no year file
+		return time.Time{}, nil
+	}
+	scanner, err := NewReader(parsed)
+	if err != nil {
+		return time.Time{}, err
+	}
+	csm, err := scanner.Read()
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, cs := range csm {
+		epoch := cs.GetEpoch()
+		if len(epoch) == 0 {
+			continue
+		}
+		last := len(epoch) - 1
+		var nanos int64
+		if ns, ok := cs.GetColumn("Nanoseconds").([]int32); ok {
+			nanos = int64(ns[last])
+		}
+		return time.Unix(epoch[last], nanos), nil
+	}
+	return time.Time{}, nil
+}
+
+// advanceBucketTail moves the cached tail of the bucket to the latest of
+// times if it is later, caching it only if load or already cached.
+func advanceBucketTail(tbk *io.TimeBucketKey, times []time.Time, load bool) {
+	key := tbk.GetItemKey()
+	bucketTails.Lock()
+	defer bucketTails.Unlock()
+	tail, ok := bucketTails.m[key]
+	if !ok && !load {
+		return
+	}
+	for _, t := range times {
+		if nanos := t.UnixNano(); !ok || nanos > tail {
+			tail, ok = nanos, true
+		}
+	}
+	bucketTails.m[key] = tail
+}
@@ -0,0 +1,345 @@
+package executor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	goio "io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/io/blockfile"
+)
+
+// Layout is an on-disk layout of a root directory: the
+// {Symbol}/{Timeframe}/{AttributeGroup}/{Year}.bin structure with the
+// category_name files, whose year files have the headers of Version and
+// the fixed length ones the compression of Codec.
+type Layout struct {
+	Name    string
+	Version int64
+	Codec   blockfile.Codec
+}
+
+// Layouts are the layouts known to MigrateDataDir by name, which differ by
+// the compression of the fixed length files.  The variable length files are
+// never compressed.  The v4 layout is also that of the releases before the
+// compression and the scaled integer columns, which were added in the
+// reserved space of the header, e.g. testdata/v4layout.  The layouts of the
+// releases before v4 are not supported.
+var Layouts = map[string]Layout{
+	"v4":        {Name: "v4", Version: io.FileinfoVersion, Codec: blockfile.None},
+	"v4-snappy": {Name: "v4-snappy", Version: io.FileinfoVersion, Codec: blockfile.Snappy},
+	"v4-zstd":   {Name: "v4-zstd", Version: io.FileinfoVersion, Codec: blockfile.Zstd},
+}
+
+// LayoutNames returns the sorted names of the known layouts.
+func LayoutNames() []string {
+	names := make([]string, 0, len(Layouts))
+	for name := range Layouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// the actions of a FileMigration
+const (
+	// MigrateConvert rewrites a year file into the layout migrated to
+	MigrateConvert = "convert"
+	// MigrateCopy copies a file as is
+	MigrateCopy = "copy"
+	// MigrateDone is a file migrated by a previous run
+	MigrateDone = "done"
+)
+
+// migratingSuffix is the suffix of a file being migrated, renamed to its
+// name once written and synced, so that a migration stopped in between
+// resumes from the files not migrated yet.
+const migratingSuffix = ".migrating"
+
+// FileMigration is the migration of a file of the root directory.
+type FileMigration struct {
+	// Path is relative to the root directory
+	Path   string
+	Action string
+	// From and To are the compressions of a converted year file
+	From, To blockfile.Codec
+	// Checksum is the SHA-256 of the records of a year file, and of its
+	// header other than the version and the codec, the same in the source
+	// and the migrated files once verified
+	Checksum []byte
+}
+
+// MigrateDataDir rewrites the root directory srcDir of layout from into dstDir
+// in layout to, and verifies that the year files migrated have the records
+// of the source ones.  The source files are only read.  The year files not
+// in layout from fail the migration before anything is written, as do the
+// WAL files not replayed, whose transactions would be lost.
+//
+// dstDir must be out of srcDir.  The files already in dstDir are those of a
+// previous run stopped before its end, and are verified rather than written
+// again.  With dryRun, the migrations are returned without being carried out.
+func MigrateDataDir(srcDir, dstDir string, from, to Layout, dryRun bool) ([]*FileMigration, error) {
+	srcDir, err := filepath.Abs(filepath.Clean(srcDir))
+	if err != nil {
+		return nil, err
+	}
+	if dstDir, err = filepath.Abs(filepath.Clean(dstDir)); err != nil {
+		return nil, err
+	}
+	if dstDir == srcDir || strings.HasPrefix(dstDir, srcDir+string(filepath.Separator)) {
+		return nil, fmt.Errorf("migration directory %s must be out of %s", dstDir, srcDir)
+	}
+
+	migrations, err := planMigration(srcDir, dstDir, from, to)
+	if err != nil || dryRun {
+		return migrations, err
+	}
+	for _, m := range migrations {
+		if err = m.migrate(srcDir, dstDir); err != nil {
+			return migrations, fmt.Errorf("failed to migrate %s: %v", m.Path, err)
+		}
+	}
+	for _, m := range migrations {
+		if err = m.verify(srcDir, dstDir); err != nil {
+			return migrations, err
+		}
+	}
+	return migrations, nil
+}
+
+// planMigration returns the migrations of the files of srcDir, checking that
+// its year files are in layout from.
+func planMigration(srcDir, dstDir string, from, to Layout) (migrations []*FileMigration, err error) {
+	var wrong []string
+	err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		switch {
+		case !info.Mode().IsRegular():
+			return nil
+		case filepath.Ext(path) == ".walfile":
+			// the WAL files are emptied on a clean shutdown
+			if info.Size() >= 11 {
+				return fmt.Errorf("%s has not been replayed, start and stop the server on %s first", rel, srcDir)
+			}
+			return nil
+		case strings.HasSuffix(path, migratingSuffix):
+			return nil
+		}
+
+		m := &FileMigration{Path: rel, Action: MigrateCopy}
+		if filepath.Ext(path) == ".bin" {
+			header, err := readHeader(path)
+			if err != nil {
+				return err
+			}
+			m.Action, m.From, m.To = MigrateConvert, blockfile.Codec(header.Codec), blockfile.None
+			if header.RecordType == int64(io.FIXED) {
+				m.To = to.Codec
+			}
+			if expected := from.codec(header); header.Version != from.Version || m.From != expected {
+				wrong = append(wrong, fmt.Sprintf("%s (version %d, %s)", rel, header.Version, m.From))
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dstDir, rel)); err == nil {
+			m.Action = MigrateDone
+		}
+		migrations = append(migrations, m)
+		return nil
+	})
+	if err == nil && len(wrong) > 0 {
+		err = fmt.Errorf("the year files are not in the %s layout: %s", from.Name, strings.Join(wrong, ", "))
+	}
+	return migrations, err
+}
+
+// codec returns the compression of a year file of the header in l.
+func (l Layout) codec(header *io.Header) blockfile.Codec {
+	if header.RecordType != int64(io.FIXED) {
+		return blockfile.None
+	}
+	return l.Codec
+}
+
+func readHeader(path string) (*io.Header, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	var buffer [io.Headersize]byte
+	if _, err = fp.ReadAt(buffer[:], 0); err != nil {
+		return nil, fmt.Errorf("failed to read the header of %s: %v", path, err)
+	}
+	return (*io.Header)(unsafe.Pointer(&buffer)), nil
+}
+
+// migrate writes the file of m in dstDir, unless done by a previous run.
+func (m *FileMigration) migrate(srcDir, dstDir string) error {
+	if m.Action == MigrateDone {
+		return nil
+	}
+	src, dst := filepath.Join(srcDir, m.Path), filepath.Join(dstDir, m.Path)
+	info, err := os.Stat(filepath.Dir(src))
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(dst), info.Mode().Perm()); err != nil {
+		return err
+	}
+	// the file left by a previous run is written again
+	tmp := dst + migratingSuffix
+	if err = os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if m.Action == MigrateConvert {
+		err = convertDataFile(src, tmp, m.To)
+	} else {
+		info, err = os.Stat(src)
+		if err == nil {
+			err = copyFile(src, tmp, info.Mode().Perm())
+		}
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+// convertDataFile writes the year file src to dst with the codec.  The
+// variable length files are copied as is, as they are not compressed.
+func convertDataFile(src, dst string, codec blockfile.Codec) error {
+	header, err := readHeader(src)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if header.RecordType != int64(io.FIXED) {
+		return copyFile(src, dst, info.Mode().Perm())
+	}
+
+	in, err := io.OpenDataFile(src, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fp, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_RDWR, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	header.Codec = int64(codec)
+	headerBytes := (*[io.Headersize]byte)(unsafe.Pointer(header))
+	if _, err = fp.Write(headerBytes[:]); err != nil {
+		fp.Close()
+		return err
+	}
+	fileSize := io.FileSize(time.Duration(header.Timeframe), int(header.Year), int(header.RecordLength))
+	if codec != blockfile.None {
+		err = blockfile.Create(fp, io.Headersize, fileSize-io.Headersize)
+	} else {
+		err = fp.Truncate(fileSize)
+	}
+	if err != nil {
+		fp.Close()
+		return err
+	}
+	out, err := io.NewDataFile(fp, os.O_RDWR)
+	if err != nil {
+		fp.Close()
+		return err
+	}
+
+	// the blocks of zeros, without records, are left unwritten
+	buf := make([]byte, blockfile.BlockSize)
+	for off := int64(io.Headersize); off < fileSize; off += int64(len(buf)) {
+		chunk := buf
+		if rest := fileSize - off; rest < int64(len(chunk)) {
+			chunk = chunk[:rest]
+		}
+		if _, err = in.ReadAt(chunk, off); err != nil {
+			out.Close()
+			return err
+		}
+		if bytes.Count(chunk, []byte{0}) == len(chunk) {
+			continue
+		}
+		if _, err = out.WriteAt(chunk, off); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err = out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// verify compares the checksums of the year file of m in srcDir and dstDir.
+func (m *FileMigration) verify(srcDir, dstDir string) error {
+	if filepath.Ext(m.Path) != ".bin" {
+		return nil
+	}
+	src, err := dataChecksum(filepath.Join(srcDir, m.Path))
+	if err != nil {
+		return err
+	}
+	dst, err := dataChecksum(filepath.Join(dstDir, m.Path))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(src, dst) {
+		return fmt.Errorf("%s differs from its source after the migration, remove it and migrate again", m.Path)
+	}
+	m.Checksum = src
+	return nil
+}
+
+// dataChecksum returns the SHA-256 of the header of the year file at path,
+// other than its version and codec, and of its data uncompressed.
+func dataChecksum(path string) ([]byte, error) {
+	header, err := readHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	header.Version, header.Codec = 0, 0
+	hash := sha256.New()
+	hash.Write((*[io.Headersize]byte)(unsafe.Pointer(header))[:])
+
+	df, err := io.OpenDataFile(path, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer df.Close()
+	var size int64
+	if f, ok := df.(*blockfile.File); ok {
+		size = f.Size()
+	} else {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		size = info.Size()
+	}
+	if _, err = goio.Copy(hash, goio.NewSectionReader(df, io.Headersize, size-io.Headersize)); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	return hash.Sum(nil), nil
+}
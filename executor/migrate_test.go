@@ -0,0 +1,202 @@
+package executor
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/io/blockfile"
+)
+
+type MigrateTests struct {
+	srcDir string
+	// files are the contents of the files of srcDir by path
+	files map[string][]byte
+}
+
+var _ = Suite(&MigrateTests{})
+
+// SetUpTest makes a root directory of the uncompressed layout, with 1H OHLC
+// year files of two symbols and their category_name files.
+func (s *MigrateTests) SetUpTest(c *C) {
+	s.srcDir = c.MkDir()
+	c.Assert(ioutil.WriteFile(filepath.Join(s.srcDir, "category_name"), []byte("Symbol"), 0600), IsNil)
+	dsv := io.NewDataShapeVector(
+		[]string{"Open", "High", "Low", "Close"},
+		[]io.EnumElementType{io.FLOAT32, io.FLOAT32, io.FLOAT32, io.FLOAT32},
+	)
+	for _, symbol := range []string{"AAPL", "BBPL"} {
+		dir := filepath.Join(s.srcDir, symbol, "1H", "OHLC")
+		c.Assert(os.MkdirAll(dir, 0700), IsNil)
+		for _, year := range []int16{2019, 2020} {
+			tbi := io.NewTimeBucketInfo(*utils.TimeframeFromString("1H"), dir, "", year, dsv, io.FIXED)
+			fp, err := os.Create(tbi.Path)
+			c.Assert(err, IsNil)
+			c.Assert(io.WriteHeader(fp, tbi), IsNil)
+			c.Assert(fp.Truncate(io.FileSize(tbi.GetTimeframe(), int(year), int(tbi.GetRecordLength()))), IsNil)
+			for index := int64(1); index < 8000; index += 97 {
+				record := make([]byte, tbi.GetRecordLength())
+				copy(record, io.DataToByteSlice(index))
+				_, err = fp.WriteAt(record, io.IndexToOffset(index, tbi.GetRecordLength()))
+				c.Assert(err, IsNil)
+			}
+			c.Assert(fp.Close(), IsNil)
+		}
+	}
+	s.files = s.readFiles(c, s.srcDir)
+}
+
+func (s *MigrateTests) readFiles(c *C, dir string) map[string][]byte {
+	files := map[string][]byte{}
+	c.Assert(filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[rel], err = ioutil.ReadFile(path)
+		return err
+	}), IsNil)
+	return files
+}
+
+func (s *MigrateTests) actions(migrations []*FileMigration) map[string]int {
+	actions := map[string]int{}
+	for _, m := range migrations {
+		actions[m.Action]++
+	}
+	return actions
+}
+
+func (s *MigrateTests) TestDryRun(c *C) {
+	dstDir := filepath.Join(c.MkDir(), "migrated")
+	migrations, err := MigrateDataDir(s.srcDir, dstDir, Layouts["v4"], Layouts["v4-zstd"], true)
+	c.Assert(err, IsNil)
+	c.Assert(s.actions(migrations), DeepEquals, map[string]int{MigrateConvert: 4, MigrateCopy: 1})
+	for _, m := range migrations {
+		if m.Action == MigrateConvert {
+			c.Assert(m.From, Equals, blockfile.None)
+			c.Assert(m.To, Equals, blockfile.Zstd)
+		}
+	}
+	_, err = os.Stat(dstDir)
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
+
+func (s *MigrateTests) TestWrongLayout(c *C) {
+	dstDir := filepath.Join(c.MkDir(), "migrated")
+	_, err := MigrateDataDir(s.srcDir, dstDir, Layouts["v4-zstd"], Layouts["v4"], false)
+	c.Assert(err, ErrorMatches, "the year files are not in the v4-zstd layout: .*")
+	_, err = os.Stat(dstDir)
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	// the migration into the source directory is refused
+	_, err = MigrateDataDir(s.srcDir, filepath.Join(s.srcDir, "migrated"), Layouts["v4"], Layouts["v4-zstd"], false)
+	c.Assert(err, ErrorMatches, "migration directory .* must be out of .*")
+}
+
+func (s *MigrateTests) TestWALNotReplayed(c *C) {
+	walFile := filepath.Join(s.srcDir, "WALFile.1600000000000000000.walfile")
+	c.Assert(ioutil.WriteFile(walFile, make([]byte, 20), 0600), IsNil)
+	_, err := MigrateDataDir(s.srcDir, filepath.Join(c.MkDir(), "migrated"), Layouts["v4"], Layouts["v4-zstd"], false)
+	c.Assert(err, ErrorMatches, "WALFile.* has not been replayed, .*")
+}
+
+func (s *MigrateTests) TestMigrateAndBack(c *C) {
+	dstDir := filepath.Join(c.MkDir(), "migrated")
+	migrations, err := MigrateDataDir(s.srcDir, dstDir, Layouts["v4"], Layouts["v4-zstd"], false)
+	c.Assert(err, IsNil)
+	c.Assert(s.actions(migrations), DeepEquals, map[string]int{MigrateConvert: 4, MigrateCopy: 1})
+	for _, m := range migrations {
+		if m.Action != MigrateConvert {
+			continue
+		}
+		c.Assert(m.Checksum, HasLen, 32)
+		header, err := readHeader(filepath.Join(dstDir, m.Path))
+		c.Assert(err, IsNil)
+		c.Assert(blockfile.Codec(header.Codec), Equals, blockfile.Zstd)
+	}
+	// the source files are left as they were, and the year files compressed
+	c.Assert(s.readFiles(c, s.srcDir), DeepEquals, s.files)
+	migrated := s.readFiles(c, dstDir)
+	c.Assert(migrated, HasLen, len(s.files))
+	for path, data := range s.files {
+		if filepath.Ext(path) == ".bin" {
+			c.Assert(len(migrated[path]) < len(data), Equals, true)
+		} else {
+			c.Assert(migrated[path], DeepEquals, data)
+		}
+	}
+
+	// the migration back restores the year files byte for byte
+	backDir := filepath.Join(c.MkDir(), "back")
+	_, err = MigrateDataDir(dstDir, backDir, Layouts["v4-zstd"], Layouts["v4"], false)
+	c.Assert(err, IsNil)
+	back := s.readFiles(c, backDir)
+	c.Assert(back, HasLen, len(s.files))
+	for path, data := range s.files {
+		c.Assert(bytes.Equal(back[path], data), Equals, true, Commentf("%s", path))
+	}
+}
+
+func (s *MigrateTests) TestResume(c *C) {
+	dstDir := filepath.Join(c.MkDir(), "migrated")
+	_, err := MigrateDataDir(s.srcDir, dstDir, Layouts["v4"], Layouts["v4-snappy"], false)
+	c.Assert(err, IsNil)
+
+	// a run stopped while writing a year file
+	path := filepath.Join("BBPL", "1H", "OHLC", "2020.bin")
+	c.Assert(os.Rename(filepath.Join(dstDir, path), filepath.Join(dstDir, path)+migratingSuffix), IsNil)
+	c.Assert(os.Truncate(filepath.Join(dstDir, path)+migratingSuffix, 100), IsNil)
+
+	migrations, err := MigrateDataDir(s.srcDir, dstDir, Layouts["v4"], Layouts["v4-snappy"], false)
+	c.Assert(err, IsNil)
+	c.Assert(s.actions(migrations), DeepEquals, map[string]int{MigrateConvert: 1, MigrateDone: 4})
+	_, err = os.Stat(filepath.Join(dstDir, path) + migratingSuffix)
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	// a year file damaged since fails the verification
+	c.Assert(os.Truncate(filepath.Join(dstDir, path), 200), IsNil)
+	_, err = MigrateDataDir(s.srcDir, dstDir, Layouts["v4"], Layouts["v4-snappy"], false)
+	c.Assert(err, NotNil)
+}
+
+// TestMigrateFixture migrates the data directory in testdata/v4layout,
+// written by a release before the compression and the scaled integer
+// columns, whose year files are of the v4 layout.
+func (s *MigrateTests) TestMigrateFixture(c *C) {
+	srcDir := filepath.Join("testdata", "v4layout")
+	files := s.readFiles(c, srcDir)
+	dstDir := filepath.Join(c.MkDir(), "migrated")
+	migrations, err := MigrateDataDir(srcDir, dstDir, Layouts["v4"], Layouts["v4-zstd"], false)
+	c.Assert(err, IsNil)
+	c.Assert(s.actions(migrations), DeepEquals, map[string]int{MigrateConvert: 2, MigrateCopy: 5})
+	c.Assert(s.readFiles(c, srcDir), DeepEquals, files)
+
+	// the bars are compressed, the trades copied as is
+	bars := filepath.Join(dstDir, "AAPL", "1D", "OHLCV", "2020.bin")
+	header, err := readHeader(bars)
+	c.Assert(err, IsNil)
+	c.Assert(blockfile.Codec(header.Codec), Equals, blockfile.Zstd)
+	trades := filepath.Join("AAPL", "1D", "TRADE", "2020.bin")
+	c.Assert(s.readFiles(c, dstDir)[trades], DeepEquals, files[trades])
+
+	// with the records written by the release
+	df, err := io.OpenDataFile(bars, os.O_RDONLY)
+	c.Assert(err, IsNil)
+	defer df.Close()
+	record := make([]byte, header.RecordLength)
+	_, err = df.ReadAt(record, io.IndexToOffset(2, int32(header.RecordLength)))
+	c.Assert(err, IsNil)
+	// the second bar written
+	c.Assert(io.ToInt64(record[:8]), Equals, int64(2))
+	c.Assert(io.ToFloat32(record[20:24]), Equals, float32(101))
+	c.Assert(io.ToInt64(record[24:32]), Equals, int64(2000))
+}
@@ -0,0 +1,88 @@
+package executor
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/planner"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// ReadPages reads the records of tbk in [start, end] in time order, and
+// calls fn with each page of them, so that a long range is not read into
+// memory at once.  Each page spans pageSize intervals of the timeframe,
+// which bounds the number of the fixed length records in it.  The empty
+// pages are skipped.
+func ReadPages(tbk *io.TimeBucketKey, start, end time.Time, pageSize int,
+	fn func(cs *io.ColumnSeries) error) error {
+	tf, err := tbk.GetTimeFrame()
+	if err != nil {
+		return err
+	}
+	start, end = clampToYearFiles(tbk, start, end)
+	window := tf.Duration * time.Duration(pageSize)
+
+	for from := start; !from.After(end); from = from.Add(window) {
+		to := from.Add(window - time.Nanosecond)
+		if to.After(end) {
+			to = end
+		}
+		query := planner.NewQuery(ThisInstance.CatalogDir)
+		query.AddTargetKey(tbk)
+		query.SetRange(from, to)
+		pr, err := query.Parse()
+		if err != nil {
+			// no files in the range
+			continue
+		}
+		reader, err := NewReader(pr)
+		if err != nil {
+			return err
+		}
+		csm, err := reader.Read()
+		if err != nil {
+			return err
+		}
+		for _, cs := range csm {
+			if cs.Len() == 0 {
+				continue
+			}
+			if err = fn(cs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// clampToYearFiles narrows [start, end] down to the years of the files of tbk,
+// so that the pages before and after them are not queried one by one.
+func clampToYearFiles(tbk *io.TimeBucketKey, start, end time.Time) (time.Time, time.Time) {
+	var first, last int16
+	// the directory of tbk holds its files in every storage tier
+	subDir, err := ThisInstance.CatalogDir.GetOwningSubDirectory(
+		filepath.Join(tbk.GetPathToYearFiles(ThisInstance.CatalogDir.GetPath()), "1970.bin"))
+	if err == nil {
+		for _, tbi := range subDir.GetTimeBucketInfoSlice() {
+			if first == 0 || tbi.Year < first {
+				first = tbi.Year
+			}
+			if tbi.Year > last {
+				last = tbi.Year
+			}
+		}
+	}
+	if first == 0 {
+		// no files, nothing to read
+		return end.Add(time.Nanosecond), end
+	}
+	loc := utils.InstanceConfig.Timezone
+	if yearStart := time.Date(int(first), time.January, 1, 0, 0, 0, 0, loc); start.Before(yearStart) {
+		start = yearStart
+	}
+	if yearEnd := time.Date(int(last)+1, time.January, 1, 0, 0, 0, 0, loc).Add(-time.Nanosecond); end.After(yearEnd) {
+		end = yearEnd
+	}
+	return start, end
+}
@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RunningQuery is a query being read, listed so that a runaway query can
+// be spotted and canceled.
+type RunningQuery struct {
+	ID      uint64
+	Key     string
+	Start   time.Time
+	End     time.Time
+	Peer    string
+	Started time.Time
+	cancel  context.CancelFunc
+}
+
+// Duration returns the time since the query started.
+func (q *RunningQuery) Duration() time.Duration {
+	return time.Since(q.Started)
+}
+
+// runningQueries are the running queries by ID.  It is a sync.Map as the
+// queries only ever add and remove their own entry, which then do not
+// contend with each other.
+var (
+	runningQueries sync.Map
+	lastQueryID    uint64
+)
+
+// StartQuery registers the query of the bucket key over the range from the
+// peer as running, until the returned function is called once it is done.
+// The query must be read with the returned context, which is canceled by
+// CancelQuery.
+func StartQuery(ctx context.Context, key string, start, end time.Time, peer string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	q := &RunningQuery{
+		ID:      atomic.AddUint64(&lastQueryID, 1),
+		Key:     key,
+		Start:   start,
+		End:     end,
+		Peer:    peer,
+		Started: time.Now(),
+		cancel:  cancel,
+	}
+	runningQueries.Store(q.ID, q)
+	return ctx, func() {
+		runningQueries.Delete(q.ID)
+		cancel()
+	}
+}
+
+// RunningQueries returns the running queries, the longest running first.
+func RunningQueries() []*RunningQuery {
+	var queries []*RunningQuery
+	runningQueries.Range(func(_, value interface{}) bool {
+		queries = append(queries, value.(*RunningQuery))
+		return true
+	})
+	sort.Slice(queries, func(i, j int) bool { return queries[i].ID < queries[j].ID })
+	return queries
+}
+
+// CancelQuery cancels the context of the running query of id, and returns
+// false if there is none, e.g. as it is already done.
+func CancelQuery(id uint64) bool {
+	value, ok := runningQueries.Load(id)
+	if !ok {
+		return false
+	}
+	value.(*RunningQuery).cancel()
+	return true
+}
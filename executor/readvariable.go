@@ -23,10 +23,11 @@ func (r *Reader) readSecondStage(bufMeta []bufferMeta) (rb []byte, err error) {
 		indexBuffer := md.Data
 
 		// Open the file to read the data
-		fp, err := os.OpenFile(file, os.O_RDONLY, 0666)
+		df, release, err := acquireDataFile(file, os.O_RDONLY)
 		if err != nil {
 			return nil, err
 		}
+		fp := df.(*os.File)
 		/*
 			Calculate how much space is needed in the results buffer
 		*/
@@ -60,12 +61,14 @@ func (r *Reader) readSecondStage(bufMeta []bufferMeta) (rb []byte, err error) {
 			buffer := make([]byte, datalen)
 			_, err = fp.ReadAt(buffer, offset)
 			if err != nil {
+				release(err)
 				return nil, err
 			}
 
 			if !utils.InstanceConfig.DisableVariableCompression {
 				buffer, err = snappy.Decode(nil, buffer)
 				if err != nil {
+					release(nil)
 					return nil, err
 				}
 			}
@@ -87,7 +90,7 @@ func (r *Reader) readSecondStage(bufMeta []bufferMeta) (rb []byte, err error) {
 
 		}
 		rb = rb[:rbCursor]
-		fp.Close()
+		release(nil)
 
 		totalBuf = append(totalBuf, rb...)
 	}
@@ -0,0 +1,205 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gobwas/glob"
+
+	"github.com/alpacahq/marketstore/v4/catalog"
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// retentionGracePeriod is how long a pruned year file stays on disk after it
+// is removed from the catalog, for the reads that found it before to finish.
+const retentionGracePeriod = time.Minute
+
+type retentionPolicy struct {
+	symbols   glob.Glob
+	timeframe string
+	maxAge    time.Duration
+}
+
+// Retention prunes the year files whose whole year is older than the max age
+// of the first retention policy matching their bucket.  The latest year file
+// of a bucket is never pruned, so that the bucket and its schema remain.
+type Retention struct {
+	policies   []retentionPolicy
+	dryRun     bool
+	archiveDir string
+	grace      time.Duration
+
+	mu sync.Mutex
+	// pending are the files removed from the catalog by their path
+	pending map[string]prunedFile
+}
+
+type prunedFile struct {
+	// removed is when the file was removed from the catalog
+	removed time.Time
+	// key is the bucket of the file, the path to it under the archive directory
+	key string
+}
+
+// NewRetention returns the Retention of the setting.
+func NewRetention(setting utils.RetentionSetting) (*Retention, error) {
+	r := &Retention{
+		dryRun:     setting.DryRun,
+		archiveDir: setting.ArchiveDirectory,
+		grace:      retentionGracePeriod,
+		pending:    map[string]prunedFile{},
+	}
+	for _, p := range setting.Policies {
+		pattern := p.Symbols
+		if pattern == "" {
+			pattern = "*"
+		}
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid retention symbols %q: %v", p.Symbols, err)
+		}
+		r.policies = append(r.policies, retentionPolicy{symbols: g, timeframe: p.Timeframe, maxAge: p.MaxAge})
+	}
+	return r, nil
+}
+
+// Run prunes the files every interval, forever.
+func (r *Retention) Run(interval time.Duration) {
+	if r.dryRun {
+		log.Info("retention is in dry run, the files past their retention are logged only")
+	}
+	for {
+		if _, _, err := r.Prune(time.Now()); err != nil {
+			log.Error("failed to prune the files past their retention: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Prune removes the files past their retention at now from the catalog, and
+// deletes or archives those removed for longer than the grace period.  It
+// returns the number and size of the files deleted or archived.
+func (r *Retention) Prune(now time.Time) (files int, bytes int64, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for tbi, key := range r.expired(now) {
+		if r.dryRun {
+			log.Info("[dry run] would prune %s past its retention", tbi.Path)
+			continue
+		}
+		if err := ThisInstance.CatalogDir.RemoveYearFile(tbi.Path); err != nil {
+			return files, bytes, err
+		}
+		ForgetBucketTail(io.NewTimeBucketKey(key))
+		notifyPruned(key + "/" + filepath.Base(tbi.Path))
+		log.Info("removed %s past its retention from the catalog", tbi.Path)
+		r.pending[tbi.Path] = prunedFile{removed: now, key: key}
+	}
+
+	for filePath, pruned := range r.pending {
+		if now.Sub(pruned.removed) < r.grace {
+			continue
+		}
+		size, err := r.reclaim(filePath, pruned.key)
+		if err != nil {
+			return files, bytes, err
+		}
+		delete(r.pending, filePath)
+		files++
+		bytes += size
+	}
+	return files, bytes, nil
+}
+
+// expired returns the year files of the catalog past their retention at now,
+// with the keys of their buckets.
+func (r *Retention) expired(now time.Time) map[*io.TimeBucketInfo]string {
+	out := map[*io.TimeBucketInfo]string{}
+	d := ThisInstance.CatalogDir
+	for _, key := range catalog.ListTimeBucketKeyNames(d) {
+		tbk := io.NewTimeBucketKey(key)
+		policy := r.policy(tbk)
+		if policy == nil {
+			continue
+		}
+		subDir, err := d.GetOwningSubDirectory(filepath.Join(tbk.GetPathToYearFiles(d.GetPath()), "1970.bin"))
+		if err != nil {
+			continue
+		}
+		tbis := subDir.GetTimeBucketInfoSlice()
+		sort.Slice(tbis, func(i, j int) bool { return tbis[i].Year < tbis[j].Year })
+		cutoff := now.Add(-policy.maxAge)
+		// the latest year is kept
+		for i := 0; i < len(tbis)-1; i++ {
+			yearEnd := time.Date(int(tbis[i].Year)+1, time.January, 1, 0, 0, 0, 0, utils.InstanceConfig.Timezone)
+			if !yearEnd.After(cutoff) {
+				out[tbis[i]] = key
+			}
+		}
+	}
+	return out
+}
+
+func (r *Retention) policy(tbk *io.TimeBucketKey) *retentionPolicy {
+	symbol := tbk.GetItemInCategory("Symbol")
+	timeframe := tbk.GetItemInCategory("Timeframe")
+	for i, p := range r.policies {
+		if p.symbols.Match(symbol) && (p.timeframe == "" || p.timeframe == timeframe) {
+			return &r.policies[i]
+		}
+	}
+	return nil
+}
+
+// reclaim deletes or archives the file of the bucket key, and syncs its
+// directory so that the removal is durable.
+func (r *Retention) reclaim(filePath, key string) (int64, error) {
+	info, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	action := "delete"
+	if r.archiveDir != "" {
+		action = "archive"
+		target := filepath.Join(r.archiveDir, key, filepath.Base(filePath))
+		if err = os.MkdirAll(filepath.Dir(target), 0770); err != nil {
+			return 0, err
+		}
+		if err = copyFile(filePath, target, info.Mode().Perm()); err != nil {
+			return 0, fmt.Errorf("failed to archive %s: %v", filePath, err)
+		}
+		if err = syncDir(filepath.Dir(target)); err != nil {
+			return 0, err
+		}
+	}
+	if err = os.Remove(filePath); err != nil {
+		return 0, err
+	}
+	if err = syncDir(filepath.Dir(filePath)); err != nil {
+		return 0, err
+	}
+	log.Info("%sd %s past its retention, %d bytes", action, filePath, info.Size())
+	metrics.RetentionReclaimedFiles.WithLabelValues(action).Inc()
+	metrics.RetentionReclaimedBytes.WithLabelValues(action).Add(float64(info.Size()))
+	return info.Size(), nil
+}
+
+func syncDir(dir string) error {
+	fp, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer fp.Close()
+	return fp.Sync()
+}
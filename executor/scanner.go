@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"github.com/alpacahq/marketstore/v4/planner"
 	"github.com/alpacahq/marketstore/v4/utils"
 	. "github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/io/blockfile"
 	"github.com/alpacahq/marketstore/v4/utils/log"
 )
 
@@ -207,7 +209,75 @@ func NewReader(pr *planner.ParseResult) (r *Reader, err error) {
 	return r, nil
 }
 
+// EstimateSize returns the estimated size in bytes of the result of Read,
+// from the query plan and the indexes of the files, without reading their
+// data.  The fixed length records are counted in all the intervals of the
+// range, empty or not, and the variable length ones from the length of
+// their data in the index, so this is rather an upper bound.
+func (r *Reader) EstimateSize() (size int64, err error) {
+	rlMap := r.pr.GetRowLen()
+	for key, iop := range r.IOPMap {
+		var records int64
+		for _, fp := range iop.FilePlan {
+			if iop.RecordType != VARIABLE {
+				records += fp.Length / int64(iop.RecordLen)
+				continue
+			}
+			n, err := variableRecords(fp, iop.VariableRecordLen)
+			if err != nil {
+				return 0, err
+			}
+			records += n
+		}
+		if iop.Limit.Number != math.MaxInt32 && int64(iop.Limit.Number) < records {
+			records = int64(iop.Limit.Number)
+		}
+		size += records * int64(rlMap[key])
+	}
+	return size, nil
+}
+
+// variableRecords returns the number of variable length records in the range
+// of fp, from the length of their data in the index of each interval, the
+// compressed data counted at the ratio readSecondStage assumes.
+func variableRecords(fp *ioFilePlan, varRecLen int) (int64, error) {
+	df, release, err := acquireDataFile(fp.FullPath, os.O_RDONLY)
+	if err != nil {
+		return 0, err
+	}
+	// the index of {epoch, offset, len} of each interval, read in chunks
+	var data int64
+	buffer := make([]byte, 24*4096)
+	end := fp.Offset + fp.Length
+	for offset := fp.Offset; offset < end; offset += int64(len(buffer)) {
+		chunk := buffer
+		if rest := end - offset; rest < int64(len(chunk)) {
+			chunk = chunk[:rest]
+		}
+		if _, err = df.ReadAt(chunk, offset); err != nil {
+			release(err)
+			return 0, err
+		}
+		for i := 0; i+24 <= len(chunk); i += 24 {
+			data += ToInt64(chunk[i+16:])
+		}
+	}
+	if err = release(nil); err != nil {
+		return 0, err
+	}
+	if !utils.InstanceConfig.DisableVariableCompression {
+		data *= 4
+	}
+	return data / int64(varRecLen), nil
+}
+
 func (r *Reader) Read() (csm ColumnSeriesMap, err error) {
+	return r.ReadContext(context.Background())
+}
+
+// ReadContext is Read, which stops with the error of ctx between the files
+// read once ctx is done, e.g. when the query is canceled.
+func (r *Reader) ReadContext(ctx context.Context) (csm ColumnSeriesMap, err error) {
 	// TODO: Need to consider the huge buffer which use loooong time gap to query.
 	// Which probably cause out of memory issue and need new mechanism to handle
 	// those data and not just simply return one ColumnSeriesMap.
@@ -223,7 +293,7 @@ func (r *Reader) Read() (csm ColumnSeriesMap, err error) {
 		cat := catMap[key]
 		rt := rtMap[key]
 		rlen := rlMap[key]
-		buffer, err := r.read(iop)
+		buffer, err := r.read(ctx, iop)
 		if err != nil {
 			return nil, err
 		}
@@ -233,6 +303,9 @@ func (r *Reader) Read() (csm ColumnSeriesMap, err error) {
 		}
 		rs := NewRowSeries(key, buffer, dsMap[key], rlen, cat, rt)
 		key, cs := rs.ToColumnSeries()
+		if cs, err = loadStrings(&key, cs, dsMap[key]); err != nil {
+			return nil, err
+		}
 		csm[key] = cs
 	}
 	return csm, err
@@ -305,7 +378,7 @@ type bufferMeta struct {
 
 // Reads the data from files, removing holes. The resulting buffer will be packed
 // Uses the index that prepends each row to identify filled rows versus holes
-func (r *Reader) read(iop *ioplan) (resultBuffer []byte, err error) {
+func (r *Reader) read(ctx context.Context, iop *ioplan) (resultBuffer []byte, err error) {
 	// Number of bytes to buffer, some multiple of record length
 	// This should be at least bigger than 4096 and be better multiple of 4KB,
 	// which is the common io size on most of the storage/filesystem.
@@ -349,6 +422,9 @@ func (r *Reader) read(iop *ioplan) (resultBuffer []byte, err error) {
 	var finished bool
 	if direction == FIRST {
 		for _, fp := range iop.FilePlan {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			dataLen := len(resultBuffer)
 			resultBuffer, finished, err = ex.readForward(resultBuffer,
 				fp,
@@ -376,6 +452,9 @@ func (r *Reader) read(iop *ioplan) (resultBuffer []byte, err error) {
 		fp := iop.FilePlan
 		var bytesRead int32
 		for i := len(fp) - 1; i >= 0; i-- {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			// Backward scan - we know that we are going to produce a limited result set here
 			resultBuffer, finished, bytesRead, err = ex.readBackward(
 				resultBuffer,
@@ -525,7 +604,7 @@ func (ex *ioExec) readForward(finalBuffer []byte, fp *ioFilePlan, recordLen, byt
 		finalBuffer = make([]byte, 0, len(readBuffer))
 	}
 	// Forward scan
-	f, err := os.OpenFile(filePath, os.O_RDONLY, 0666)
+	f, err := openDataReader(filePath)
 	if err != nil {
 		log.Error("Read: opening %s\n%s", filePath, err)
 		return nil, false, err
@@ -564,7 +643,7 @@ func (ex *ioExec) readBackward(finalBuffer []byte, fp *ioFilePlan,
 		finalBuffer = make([]byte, bytesToRead, bytesToRead)
 	}
 
-	f, err := os.OpenFile(filePath, os.O_RDONLY, 0666)
+	f, err := openDataReader(filePath)
 	if err != nil {
 		log.Error("Read: opening %s\n%s", filePath, err)
 		return nil, false, 0, err
@@ -632,6 +711,37 @@ func (ex *ioExec) readBackward(finalBuffer []byte, fp *ioFilePlan,
 	return finalBuffer, false, bytesRead, nil
 }
 
+type readSeekCloser interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// openDataReader opens the year file for the scans, from the open files,
+// which read its data uncompressed if it is compressed.
+func openDataReader(filePath string) (readSeekCloser, error) {
+	df, release, err := acquireDataFile(filePath, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	closer := closerFunc(func() error { return release(nil) })
+	if f, ok := df.(*blockfile.File); ok {
+		return struct {
+			io.ReadSeeker
+			io.Closer
+		}{io.NewSectionReader(f, 0, f.Size()), closer}, nil
+	}
+	// the scans seek before they read, so the offset left by the previous
+	// use does not matter
+	return struct {
+		io.ReadSeeker
+		io.Closer
+	}{df.(*os.File), closer}, nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
 func seekBackward(f io.Seeker, relative_offset int32, lowerBound int64) (seekAmt int64, curpos int64, err error) {
 	// Find the current file position
 	curpos, err = f.Seek(0, os.SEEK_CUR)
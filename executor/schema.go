@@ -0,0 +1,125 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// declaredSchema is the schema declared in the config for a bucket.
+type declaredSchema struct {
+	dsv        []io.DataShape
+	scales     map[string]int
+	recordType io.EnumRecordType
+}
+
+// declaredSchemas are the declared schemas by the item key of their bucket,
+// and whether the writes to the other buckets are rejected.
+var declaredSchemas = struct {
+	sync.RWMutex
+	m      map[string]*declaredSchema
+	strict bool
+}{}
+
+// DeclareSchemas registers the schemas of the buckets, with which the
+// writer creates them, and creates those that do not exist.  The existing
+// buckets must have the declared schemas.  With strict, the writes to the
+// buckets without a declared schema are rejected.
+func DeclareSchemas(schemas []*utils.BucketSchema, strict bool) error {
+	var errs []string
+	m := map[string]*declaredSchema{}
+	for i, schema := range schemas {
+		dsv, scales, err := io.DataShapesAndScalesFromInputString(schema.Columns)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid columns %q of schema %d: %v", schema.Columns, i, err))
+			continue
+		}
+		declared := &declaredSchema{
+			dsv:        append([]io.DataShape{{Name: "Epoch", Type: io.INT64}}, dsv...),
+			scales:     scales,
+			recordType: io.FIXED,
+		}
+		if schema.VariableLength {
+			declared.recordType = io.VARIABLE
+		}
+		for _, symbol := range schema.Symbols {
+			m[symbol+"/"+schema.Timeframe+"/"+schema.AttributeGroup] = declared
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	declaredSchemas.Lock()
+	declaredSchemas.m = m
+	declaredSchemas.strict = strict
+	declaredSchemas.Unlock()
+
+	var created int
+	for key := range m {
+		tbk := io.NewTimeBucketKey(key)
+		tbi, err := declaredBucketInfo(tbk, int16(time.Now().Year()))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid schema of %s: %v", key, err))
+			continue
+		}
+		ok, err := EnsureBucket(tbk, tbi)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if ok {
+			created++
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	log.Info("declared the schemas of %d buckets, %d of which were created", len(m), created)
+	return nil
+}
+
+// declaredBucketInfo returns the info of the year file of the bucket with
+// its declared schema, or nil if it has none.
+func declaredBucketInfo(tbk *io.TimeBucketKey, year int16) (*io.TimeBucketInfo, error) {
+	declaredSchemas.RLock()
+	declared := declaredSchemas.m[tbk.GetItemKey()]
+	declaredSchemas.RUnlock()
+	if declared == nil {
+		return nil, nil
+	}
+
+	tf, err := tbk.GetTimeFrame()
+	if err != nil {
+		return nil, err
+	}
+	tbi := io.NewTimeBucketInfo(*tf, tbk.GetPathToYearFiles(ThisInstance.CatalogDir.GetPath()),
+		"Declared", year, declared.dsv, declared.recordType)
+	for name, scale := range declared.scales {
+		if err := tbi.SetElementScale(name, scale); err != nil {
+			return nil, err
+		}
+	}
+	return tbi, nil
+}
+
+// checkDeclared returns an error if the schemas are strict and the bucket
+// of tbk has no declared schema.
+func checkDeclared(tbk *io.TimeBucketKey) error {
+	declaredSchemas.RLock()
+	defer declaredSchemas.RUnlock()
+	if !declaredSchemas.strict {
+		return nil
+	}
+	if _, ok := declaredSchemas.m[tbk.GetItemKey()]; !ok {
+		return UndeclaredSchemaError(fmt.Sprintf("bucket %s has no declared schema, which strict_schemas requires",
+			tbk.GetItemKey()))
+	}
+	return nil
+}
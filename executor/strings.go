@@ -0,0 +1,164 @@
+package executor
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+/*
+	The string columns are stored out of the fixed width records, in a side
+	file of the bucket next to its year files, strings.dat, to which the
+	strings written are appended.  Each of them is stored as its length in
+	4 bytes followed by its UTF-8 bytes, and the column of a record holds the
+	offset of the string in the side file plus one, in 8 bytes, so that an
+	empty string, as well as an empty interval, is 0 and not stored.  The
+	side file is shared by the years of the bucket, and is only appended to,
+	so that the records written before still refer to their strings.
+	The strings of the records deleted or pruned are left in the side file,
+	which is only reclaimed when the bucket is destroyed.  Like the year
+	files, the side file is used from the hottest storage tier it is in, and
+	created in the root directory.
+	The buckets without string columns have no side file.
+*/
+
+const stringsFileName = "strings.dat"
+
+// stringsMu serializes the appends to the side files.
+var stringsMu sync.Mutex
+
+// hasStrings returns true if some of the columns are strings.
+func hasStrings(dsv []io.DataShape) bool {
+	for _, ds := range dsv {
+		if ds.Type == io.STRING {
+			return true
+		}
+	}
+	return false
+}
+
+// storeStrings appends the strings of the string columns of cs to the side
+// file of the bucket, which is synced, and returns cs with their references
+// in place of them.
+func storeStrings(tbk *io.TimeBucketKey, cs *io.ColumnSeries, dsv []io.DataShape) (*io.ColumnSeries, error) {
+	if !hasStrings(dsv) {
+		return cs, nil
+	}
+	stringsMu.Lock()
+	defer stringsMu.Unlock()
+
+	path := ThisInstance.CatalogDir.BucketFilePath(tbk, stringsFileName)
+	fp, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	fi, err := fp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	offset := fi.Size()
+
+	var buffer []byte
+	replaced := map[string]interface{}{}
+	for _, ds := range dsv {
+		if ds.Type != io.STRING {
+			continue
+		}
+		col, ok := cs.GetColumn(ds.Name).([]string)
+		if !ok {
+			return nil, fmt.Errorf("column %s must be of strings", ds.Name)
+		}
+		refs := make([]int64, len(col))
+		for i, s := range col {
+			if s == "" {
+				continue
+			}
+			refs[i] = offset + int64(len(buffer)) + 1
+			buffer = append(buffer, make([]byte, 4)...)
+			binary.LittleEndian.PutUint32(buffer[len(buffer)-4:], uint32(len(s)))
+			buffer = append(buffer, s...)
+		}
+		replaced[ds.Name] = refs
+	}
+	if len(buffer) != 0 {
+		if _, err = fp.Write(buffer); err != nil {
+			return nil, err
+		}
+		// synced before the records referring to the strings are in the WAL
+		if err = fp.Sync(); err != nil {
+			return nil, err
+		}
+	}
+	return replaceColumns(cs, replaced), nil
+}
+
+// loadStrings returns cs read from the bucket with the strings of the side
+// file in place of the references of its string columns.
+func loadStrings(tbk *io.TimeBucketKey, cs *io.ColumnSeries, dsv []io.DataShape) (*io.ColumnSeries, error) {
+	if !hasStrings(dsv) {
+		return cs, nil
+	}
+	path := ThisInstance.CatalogDir.BucketFilePath(tbk, stringsFileName)
+	var fp *os.File
+	replaced := map[string]interface{}{}
+	for _, ds := range dsv {
+		if ds.Type != io.STRING {
+			continue
+		}
+		refs, ok := cs.GetColumn(ds.Name).([]int64)
+		if !ok {
+			continue
+		}
+		col := make([]string, len(refs))
+		for i, ref := range refs {
+			if ref == 0 {
+				continue
+			}
+			if fp == nil {
+				var err error
+				if fp, err = os.Open(path); err != nil {
+					return nil, err
+				}
+				defer fp.Close()
+			}
+			s, err := readString(fp, ref-1)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read the string of column %s at %d of %s: %v", ds.Name, ref-1, path, err)
+			}
+			col[i] = s
+		}
+		replaced[ds.Name] = col
+	}
+	return replaceColumns(cs, replaced), nil
+}
+
+// replaceColumns returns a copy of cs with the columns replaced, in the same
+// order.
+func replaceColumns(cs *io.ColumnSeries, replaced map[string]interface{}) *io.ColumnSeries {
+	out := io.NewColumnSeries()
+	for _, name := range cs.GetColumnNames() {
+		col := cs.GetColumn(name)
+		if r, ok := replaced[name]; ok {
+			col = r
+		}
+		out.AddColumn(name, col)
+	}
+	out.SetCandleAttributes(cs.GetCandleAttributes())
+	return out
+}
+
+func readString(fp *os.File, offset int64) (string, error) {
+	var length [4]byte
+	if _, err := fp.ReadAt(length[:], offset); err != nil {
+		return "", err
+	}
+	buffer := make([]byte, binary.LittleEndian.Uint32(length[:]))
+	if _, err := fp.ReadAt(buffer, offset+4); err != nil {
+		return "", err
+	}
+	return string(buffer), nil
+}
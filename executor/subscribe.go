@@ -0,0 +1,157 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alpacahq/marketstore/v4/plugins/trigger"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// WrittenRecords are the records written to a file by a commit, with the
+// key of the file, e.g. AAPL/1Min/OHLCV/2020.bin.
+type WrittenRecords struct {
+	Key     string
+	Records []trigger.Record
+}
+
+// WriteSubscription receives the records written to the files of a bucket
+// as they are committed to the primary files, in the commit order.
+type WriteSubscription struct {
+	prefix string
+	c      chan WrittenRecords
+	// overflowed is set if the subscriber fell too far behind
+	overflowed bool
+}
+
+// subscriptions are the write subscriptions and hooks, notified by
+// dispatchRecords.
+var subscriptions = struct {
+	sync.Mutex
+	m     map[*WriteSubscription]struct{}
+	hooks []func(key string)
+}{m: map[*WriteSubscription]struct{}{}}
+
+// AddWriteHook registers hook to be called with the key of each file
+// written, once its records are committed, or pruned, e.g. to invalidate
+// the data cached from the file.  It is called by the writer and the
+// retention so it must not block.
+func AddWriteHook(hook func(key string)) {
+	subscriptions.Lock()
+	subscriptions.hooks = append(subscriptions.hooks, hook)
+	subscriptions.Unlock()
+}
+
+// SubscribeWrites subscribes to the writes to the bucket of tbk.  Up to
+// depth commits can be pending for the subscriber, past which the
+// subscription is ended, as the writes never wait for the subscribers.
+func SubscribeWrites(tbk *io.TimeBucketKey, depth int) *WriteSubscription {
+	sub := &WriteSubscription{
+		prefix: tbk.GetItemKey() + "/",
+		c:      make(chan WrittenRecords, depth),
+	}
+	subscriptions.Lock()
+	subscriptions.m[sub] = struct{}{}
+	subscriptions.Unlock()
+	return sub
+}
+
+// C returns the channel of the written records, which is closed if the
+// subscription ends because the subscriber fell behind.
+func (sub *WriteSubscription) C() <-chan WrittenRecords {
+	return sub.c
+}
+
+// Overflowed returns true if the subscription ended because the subscriber
+// fell behind.
+func (sub *WriteSubscription) Overflowed() bool {
+	subscriptions.Lock()
+	defer subscriptions.Unlock()
+	return sub.overflowed
+}
+
+// Close ends the subscription.
+func (sub *WriteSubscription) Close() {
+	subscriptions.Lock()
+	delete(subscriptions.m, sub)
+	subscriptions.Unlock()
+}
+
+// notifySubscriptions calls the write hooks and sends the records written
+// to the file of key to its subscribers, ending the subscriptions of those
+// too far behind.
+func notifySubscriptions(key string, records []trigger.Record) {
+	subscriptions.Lock()
+	defer subscriptions.Unlock()
+	for _, hook := range subscriptions.hooks {
+		hook(key)
+	}
+	for sub := range subscriptions.m {
+		if !strings.HasPrefix(key, sub.prefix) {
+			continue
+		}
+		select {
+		case sub.c <- WrittenRecords{Key: key, Records: records}:
+		default:
+			sub.overflowed = true
+			delete(subscriptions.m, sub)
+			close(sub.c)
+		}
+	}
+}
+
+// notifyPruned calls the write hooks with the key of a file removed from
+// the catalog by the retention, whose records are no longer read.
+func notifyPruned(key string) {
+	subscriptions.Lock()
+	defer subscriptions.Unlock()
+	for _, hook := range subscriptions.hooks {
+		hook(key)
+	}
+}
+
+// FlushWrites flushes the pending writes to the primary files, and returns
+// once the subscribers have been sent the records written by them.
+func (i *InstanceMetadata) FlushWrites(ctx context.Context) error {
+	return i.WALFile.flushAndWait(ctx)
+}
+
+// WrittenColumnSeries returns the rows of the records written to the file
+// of key, in the same format as the query results.
+func WrittenColumnSeries(wr WrittenRecords) (*io.TimeBucketKey, *io.ColumnSeries, error) {
+	dir, file := filepath.Split(wr.Key)
+	year, err := strconv.Atoi(strings.TrimSuffix(file, ".bin"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid file key %s", wr.Key)
+	}
+	tbk := io.NewTimeBucketKey(strings.TrimSuffix(dir, "/"))
+	tbi, err := ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(tbk)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tbi.GetRecordType() == io.FIXED {
+		cs := trigger.RecordsToColumnSeries(*tbk, tbi.GetDataShapesWithEpoch(), nil,
+			tbi.GetTimeframe(), int16(year), wr.Records)
+		cs, err = loadStrings(tbk, cs, tbi.GetDataShapesWithEpoch())
+		return tbk, cs, err
+	}
+
+	// the variable records are the rows appended to the interval of the index
+	varRecLen := uint32(tbi.GetVariableRecordLength())
+	var rows []byte
+	for _, record := range wr.Records {
+		payload := record.Payload()
+		intervalStart := io.IndexToTime(record.Index(), tbi.GetTimeframe(), int16(year)).Unix()
+		rows = append(rows, RewriteBuffer(payload, varRecLen, uint32(len(payload))/varRecLen,
+			uint32(tbi.GetIntervals()), uint64(intervalStart))...)
+	}
+	rs := io.NewRowSeries(*tbk, rows, tbi.GetDataShapesWithEpoch(), int(varRecLen)+8, nil, io.VARIABLE)
+	_, cs := rs.ToColumnSeries()
+	cs, err = loadStrings(tbk, cs, tbi.GetDataShapesWithEpoch())
+	return tbk, cs, err
+}
@@ -1,10 +1,14 @@
 package executor
 
 import (
+	"context"
 	"crypto/md5"
 	"fmt"
 	goio "io"
 	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"bytes"
@@ -14,7 +18,9 @@ import (
 
 	"github.com/alpacahq/marketstore/v4/executor/buffile"
 	"github.com/alpacahq/marketstore/v4/executor/wal"
+	"github.com/alpacahq/marketstore/v4/metrics"
 	"github.com/alpacahq/marketstore/v4/plugins/trigger"
+	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/io"
 	"github.com/alpacahq/marketstore/v4/utils/log"
 )
@@ -34,6 +40,9 @@ type WALFileType struct {
 	FilePath          string   // WAL file full path
 	lastCommittedTGID int64    // TGID to be checkpointed
 	FilePtr           *os.File // Active file pointer to FileName
+	lastFlush         int64    // Unix nanoseconds of the last flush to WAL
+	lastCheckpoint    int64    // Unix nanoseconds of the last checkpoint
+	rotation          walRotation
 }
 
 func NewWALFile(rootDir string, owningInstanceID int64) (wf *WALFileType, err error) {
@@ -180,6 +189,7 @@ func (wf *WALFileType) FlushToWAL(tgc *TransactionPipe) (err error) {
 	writeCommands := make([]*wal.WriteCommand, WTCount)
 	for i := 0; i < WTCount; i++ {
 		writeCommands[i] = <-tgc.writeChannel
+		atomic.AddInt64(&tgc.pendingBytes, -int64(len(writeCommands[i].Data)))
 	}
 
 	fileRecordTypes := map[string]io.EnumRecordType{}
@@ -219,26 +229,81 @@ func (wf *WALFileType) FlushToWAL(tgc *TransactionPipe) (err error) {
 		tgc.IncrementTGID()
 
 		wf.FilePtr.Sync() // Flush the OS buffer
+		metrics.WALSize.Set(float64(wf.size()))
+		for keyPath := range fileRecordTypes {
+			wf.rotation.written(keyPath)
+		}
 	}
 
 	/*
 		Write the buffers to primary files (should happen after WAL writes)
 	*/
-	for keyPath, writes := range writesPerFile {
-		recordType := fileRecordTypes[keyPath]
-		varRecLen := varRecLens[keyPath]
-		if err := wf.writePrimary(keyPath, writes, recordType, varRecLen); err != nil {
-			return err
-		}
+	written, err := wf.writePrimaries(writesPerFile, fileRecordTypes, varRecLens)
+	for _, keyPath := range written {
+		writes := writesPerFile[keyPath]
 		for i, buffer := range writes {
 			appendRecord(keyPath, trigger.Record(buffer.IndexAndPayload()))
 			writes[i] = nil // for GC
 		}
 		writesPerFile[keyPath] = nil // for GC
 	}
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt64(&wf.lastFlush, time.Now().UnixNano())
 	return nil
 }
 
+// writePrimaries writes the buffers to the primary files, with up to the
+// wal_commit_workers files written in parallel, and returns the key paths
+// of the files written.  With a single worker, it stops at the first error,
+// and otherwise it writes all the files it can, returning the first error.
+func (wf *WALFileType) writePrimaries(writesPerFile map[string][]wal.OffsetIndexBuffer,
+	fileRecordTypes map[string]io.EnumRecordType, varRecLens map[string]int) (written []string, err error) {
+	workers := commitWorkers()
+	if workers > len(writesPerFile) {
+		workers = len(writesPerFile)
+	}
+	if workers <= 1 {
+		for keyPath, writes := range writesPerFile {
+			if err := wf.writePrimary(keyPath, writes, fileRecordTypes[keyPath], varRecLens[keyPath]); err != nil {
+				return written, err
+			}
+			written = append(written, keyPath)
+		}
+		return written, nil
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errOnce sync.Once
+	)
+	jobs := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for keyPath := range jobs {
+				if e := wf.writePrimary(keyPath, writesPerFile[keyPath],
+					fileRecordTypes[keyPath], varRecLens[keyPath]); e != nil {
+					errOnce.Do(func() { err = e })
+					continue
+				}
+				mu.Lock()
+				written = append(written, keyPath)
+				mu.Unlock()
+			}
+		}()
+	}
+	for keyPath := range writesPerFile {
+		jobs <- keyPath
+	}
+	close(jobs)
+	wg.Wait()
+	return written, err
+}
+
 func serializeTG(tgID int64, commands []*wal.WriteCommand,
 ) (tgSerialized []byte, writesPerFile map[string][]wal.OffsetIndexBuffer) {
 	WTCount := len(commands)
@@ -279,24 +344,39 @@ func serializeTG(tgID int64, commands []*wal.WriteCommand,
 }
 
 func (wf *WALFileType) writePrimary(keyPath string, writes []wal.OffsetIndexBuffer, recordType io.EnumRecordType, varRecLen int) (err error) {
-	type WriteAtCloser interface {
+	type ReadWriterAt interface {
+		goio.ReaderAt
 		goio.WriterAt
-		goio.Closer
 	}
 	const batchThreshold = 100
-	var fp WriteAtCloser
 	fullPath := walKeyToFullPath(wf.RootPath, keyPath)
-	if recordType == io.FIXED && len(writes) >= batchThreshold {
-		fp, err = buffile.New(fullPath)
-	} else {
-		fp, err = os.OpenFile(fullPath, os.O_RDWR, 0700)
-	}
+	// a compressed file buffers the block written by itself
+	df, release, err := acquireDataFile(fullPath, os.O_RDWR)
 	if err != nil {
 		// this is critical, in fact, since tx has been committed
 		log.Error("cannot open file %s for write: %v", fullPath, err)
 		return err
 	}
-	defer fp.Close()
+	var fp ReadWriterAt = df
+	var bf *buffile.BufferedFile
+	if f, ok := df.(*os.File); ok && recordType == io.FIXED && len(writes) >= batchThreshold {
+		bf = buffile.NewFromFile(f)
+		fp = bf
+	}
+	// the buffered writes are written before the file is released, to be
+	// reused by the next writes, or closed if they failed
+	defer func() {
+		if bf != nil {
+			if ferr := bf.Flush(); err == nil && ferr != nil {
+				log.Error("failed to write committed data to %s: %v", fullPath, ferr)
+				err = ferr
+			}
+		}
+		if rerr := release(err); err == nil && rerr != nil {
+			log.Error("failed to write committed data to %s: %v", fullPath, rerr)
+			err = rerr
+		}
+	}()
 
 	for _, buffer := range writes {
 		switch recordType {
@@ -322,6 +402,9 @@ func (wf *WALFileType) writePrimary(keyPath string, writes []wal.OffsetIndexBuff
 // not goroutine-safe with FlushToWAL and caller should make sure
 // it is streamlined.
 func (wf *WALFileType) CreateCheckpoint() error {
+	defer func() {
+		atomic.StoreInt64(&wf.lastCheckpoint, time.Now().UnixNano())
+	}()
 	if wf.lastCommittedTGID == 0 {
 		return nil
 	}
@@ -335,11 +418,49 @@ func (wf *WALFileType) CreateCheckpoint() error {
 		// Sync the filesystem, after this point the filesystem cache data is committed to disk
 		io.Syncfs()
 		wf.WriteTransactionInfo(TGID, CHECKPOINT, COMMITCOMPLETE)
+		metrics.WALSize.Set(float64(wf.size()))
 	}
 	wf.lastCommittedTGID = 0
 	return nil
 }
 
+// Rotate truncates the WAL file, to be called right after a checkpoint
+// when all of its transactions are in the primary files.  The file is
+// truncated in place, so its name is kept.
+func (wf *WALFileType) Rotate() {
+	flushed := wf.size()
+	wf.FilePtr.Truncate(0)
+	wf.WriteStatus(wal.OPEN, wal.NOTREPLAYED)
+	log.Info("rotated WAL file %s, truncated %d bytes flushed since the previous rotation",
+		filepath.Base(wf.FilePath), flushed)
+
+	wf.rotation.rotated()
+	metrics.WALRotations.Inc()
+	metrics.WALRotationBytes.Observe(float64(flushed))
+	metrics.WALLastRotation.SetToCurrentTime()
+	metrics.WALSize.Set(float64(wf.size()))
+}
+
+// rotateIfFull checkpoints and rotates the WAL file before its interval
+// once it reaches the size of wal_rotation of the timeframes written.
+func (wf *WALFileType) rotateIfFull() {
+	maxSize := wf.rotation.maxSize()
+	if maxSize == 0 || wf.size() < maxSize {
+		return
+	}
+	wf.CreateCheckpoint()
+	wf.Rotate()
+}
+
+// size returns the size of the WAL file, or 0 if it cannot be read.
+func (wf *WALFileType) size() int64 {
+	fi, err := wf.FilePtr.Stat()
+	if err != nil {
+		return 0
+	}
+	return fi.Size()
+}
+
 type TGIDlist []int64
 
 func (tgl TGIDlist) Len() int           { return len(tgl) }
@@ -347,6 +468,13 @@ func (tgl TGIDlist) Less(i, j int) bool { return tgl[i] < tgl[j] }
 func (tgl TGIDlist) Swap(i, j int)      { tgl[i], tgl[j] = tgl[j], tgl[i] }
 
 func (wf *WALFileType) Replay(writeData bool) error {
+	_, err := wf.replay(writeData)
+	return err
+}
+
+// replay replays the WAL file like Replay, and returns the number of
+// transaction groups replayed.
+func (wf *WALFileType) replay(writeData bool) (transactions int, err error) {
 	/*
 		Replay this WAL File's unwritten transactions.
 		We will do this in two passes, in the first pass we will collect the Transaction Group IDs that are
@@ -366,7 +494,7 @@ func (wf *WALFileType) Replay(writeData bool) error {
 	if !wf.NeedsReplay() {
 		err := fmt.Errorf("WALFileType.NeedsReplay No Replay Needed")
 		log.Info(err.Error())
-		return err
+		return 0, err
 	}
 
 	// Take control of this file and set the status
@@ -467,6 +595,10 @@ func (wf *WALFileType) Replay(writeData bool) error {
 	}
 	sort.Sort(sortedTGIDs)
 
+	var (
+		tgIDs    []int64
+		tgWTSets [][]wal.WTSet
+	)
 	//for tgid, TG_Serialized := range TGData {
 	for _, tgid := range sortedTGIDs {
 		TG_Serialized := TGData[tgid]
@@ -475,21 +607,31 @@ func (wf *WALFileType) Replay(writeData bool) error {
 			if writeData {
 				tgID, wtSets := parseTGData(TG_Serialized, wf.RootPath)
 				log.Info("Replaying TGID: %d, WTSet count is: %d bytes", tgID, len(wtSets))
-				if err := wf.replayTGData(tgID, wtSets); err != nil {
-					return err
-				}
+				tgIDs = append(tgIDs, tgID)
+				tgWTSets = append(tgWTSets, wtSets)
 			} else {
 				log.Info("Replay for TGID: %d, data length is: %d bytes", tgid, len(TG_Serialized))
 			}
 		}
 	}
+	if workers := replayWorkers(); writeData && workers > 1 {
+		if err := wf.replayParallel(tgIDs, tgWTSets, workers); err != nil {
+			return 0, err
+		}
+	} else if writeData {
+		for i, tgID := range tgIDs {
+			if err := wf.replayTGData(tgID, tgWTSets[i]); err != nil {
+				return i, err
+			}
+		}
+	}
 	log.Info("Replay of WAL file %s finished", wf.FilePath)
 	if writeData {
 		wf.WriteStatus(wal.OPEN, wal.REPLAYED)
 	}
 
 	log.Info("Finished replay of TGData")
-	return nil
+	return len(tgIDs), nil
 }
 func (wf *WALFileType) WriteStatus(FileStatus wal.FileStatusEnum, ReplayState wal.ReplayStateEnum) {
 	wf.FileStatus = FileStatus
@@ -647,29 +789,14 @@ func (wf *WALFileType) replayTGData(tgID int64, wtSets []wal.WTSet) (err error)
 	}
 
 	cfp := NewCachedFP() // Cached open file pointer
-	defer cfp.Close()
-
 	for _, wtSet := range wtSets {
-		fp, err := cfp.GetFP(wtSet.FilePath)
-		if err != nil {
+		if err = replayWTSet(cfp, wtSet); err != nil {
+			cfp.Close()
 			return err
 		}
-		switch io.EnumRecordType(wtSet.RecordType) {
-		case io.FIXED:
-			if err = WriteBufferToFile(fp, wtSet.Buffer); err != nil {
-				return err
-			}
-		case io.VARIABLE:
-			// Find the record length - we need it to use the time column as a sort key later
-			if err = WriteBufferToFileIndirect(fp,
-				wtSet.Buffer,
-				wtSet.VarRecLen,
-			); err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("Error: Record Type is incorrect from WALFile, invalid/outdated WAL file?")
-		}
+	}
+	if err = cfp.Close(); err != nil {
+		return err
 	}
 	wf.lastCommittedTGID = tgID
 	wf.CreateCheckpoint()
@@ -677,6 +804,118 @@ func (wf *WALFileType) replayTGData(tgID int64, wtSets []wal.WTSet) (err error)
 	return nil
 }
 
+// replayParallel writes the TG data to the primary files with the given number
+// of workers.  The writes are grouped by the target file, and each file is written
+// by a single worker in TGID order, so the result is the same as replaying the
+// TGs one by one.  The checkpoint is created once all the writes are done.
+func (wf *WALFileType) replayParallel(tgIDs []int64, tgWTSets [][]wal.WTSet, workers int) error {
+	if len(tgIDs) == 0 {
+		return nil
+	}
+
+	var filePaths []string
+	wtSetsByFile := map[string][]wal.WTSet{}
+	for _, wtSets := range tgWTSets {
+		for _, wtSet := range wtSets {
+			if _, ok := wtSetsByFile[wtSet.FilePath]; !ok {
+				filePaths = append(filePaths, wtSet.FilePath)
+			}
+			wtSetsByFile[wtSet.FilePath] = append(wtSetsByFile[wtSet.FilePath], wtSet)
+		}
+	}
+
+	if workers > len(filePaths) {
+		workers = len(filePaths)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+	log.Info("Replaying %d TGs to %d files with %d workers", len(tgIDs), len(filePaths), workers)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	jobs := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for filePath := range jobs {
+				if err := replayFile(wtSetsByFile[filePath]); err != nil {
+					errOnce.Do(func() { firstErr = err })
+				}
+			}
+		}()
+	}
+	for _, filePath := range filePaths {
+		jobs <- filePath
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	wf.lastCommittedTGID = tgIDs[len(tgIDs)-1]
+	return wf.CreateCheckpoint()
+}
+
+// replayFile writes the WTSets targeting the same file in order.
+func replayFile(wtSets []wal.WTSet) error {
+	cfp := NewCachedFP()
+	for _, wtSet := range wtSets {
+		if err := replayWTSet(cfp, wtSet); err != nil {
+			cfp.Close()
+			return err
+		}
+	}
+	return cfp.Close()
+}
+
+func replayWTSet(cfp *CachedFP, wtSet wal.WTSet) error {
+	fp, err := cfp.GetFP(wtSet.FilePath)
+	if err != nil {
+		return err
+	}
+	switch io.EnumRecordType(wtSet.RecordType) {
+	case io.FIXED:
+		return WriteBufferToFile(fp, wtSet.Buffer)
+	case io.VARIABLE:
+		// the variable length files are not compressed
+		f, ok := fp.(*os.File)
+		if !ok {
+			return fmt.Errorf("variable length records for the compressed file %s", wtSet.FilePath)
+		}
+		// Find the record length - we need it to use the time column as a sort key later
+		return WriteBufferToFileIndirect(f,
+			wtSet.Buffer,
+			wtSet.VarRecLen,
+		)
+	default:
+		return fmt.Errorf("Error: Record Type is incorrect from WALFile, invalid/outdated WAL file?")
+	}
+}
+
+// replayWorkers returns the number of workers replaying the WAL in parallel.
+func replayWorkers() int {
+	if utils.InstanceConfig.WALReplayWorkers > 0 {
+		return utils.InstanceConfig.WALReplayWorkers
+	}
+	return runtime.NumCPU()
+}
+
+// commitWorkers returns the number of workers writing the primary files
+// in parallel on a commit.
+func commitWorkers() int {
+	if utils.InstanceConfig.WALCommitWorkers > 0 {
+		return utils.InstanceConfig.WALCommitWorkers
+	}
+	return 1
+}
+
 func (wf *WALFileType) IsOpen() bool {
 	_, err := wf.FilePtr.Stat()
 	if err != nil {
@@ -760,6 +999,9 @@ func (wf *WALFileType) cleanupOldWALFiles(rootDir string) {
 	}
 	myFileBase := filepath.Base(wf.FilePath)
 	log.Info("My WALFILE: %s", myFileBase)
+	start := time.Now()
+	var replayedFiles, transactions int
+	var replayedBytes int64
 	for _, file := range files {
 		if !file.IsDir() {
 			filename := file.Name()
@@ -776,9 +1018,13 @@ func (wf *WALFileType) cleanupOldWALFiles(rootDir string) {
 						if err != nil {
 							log.Fatal("Opening %s\n%s", filename, err)
 						}
-						if err = w.Replay(true); err != nil {
+						n, err := w.replay(true)
+						if err != nil {
 							log.Fatal("Unable to replay %s\n%s", filename, err)
 						}
+						replayedFiles++
+						transactions += n
+						replayedBytes += fi.Size()
 
 						w.Delete(wf.OwningInstanceID)
 					}
@@ -786,6 +1032,14 @@ func (wf *WALFileType) cleanupOldWALFiles(rootDir string) {
 			}
 		}
 	}
+
+	// set once, before the server starts serving /metrics
+	elapsed := time.Since(start)
+	metrics.WALReplayDuration.Set(elapsed.Seconds())
+	metrics.WALReplayTransactions.Set(float64(transactions))
+	metrics.WALReplayBytes.Set(float64(replayedBytes))
+	log.Info("replayed %d transaction groups of %d WAL files (%d bytes) in %v",
+		transactions, replayedFiles, replayedBytes, elapsed)
 }
 
 func StartupCacheAndWAL(rootDir string, owningInstanceID int64) (tgc *TransactionPipe, wf *WALFileType, err error) {
@@ -808,7 +1062,6 @@ func (wf *WALFileType) SyncWAL(WALRefresh, PrimaryRefresh time.Duration, walRota
 	tickerWAL := time.NewTicker(WALRefresh)
 	tickerPrimary := time.NewTicker(PrimaryRefresh)
 	tickerCheck := time.NewTicker(WALRefresh / 100)
-	primaryFlushCounter := 0
 
 	chanCap := cap(ThisInstance.TXNPipe.writeChannel)
 	for {
@@ -818,26 +1071,30 @@ func (wf *WALFileType) SyncWAL(WALRefresh, PrimaryRefresh time.Duration, walRota
 				if err := wf.FlushToWAL(ThisInstance.TXNPipe); err != nil {
 					log.Fatal(err.Error())
 				}
+				wf.rotateIfFull()
 			case f := <-ThisInstance.TXNPipe.flushChannel:
 				if err := wf.FlushToWAL(ThisInstance.TXNPipe); err != nil {
 					log.Fatal(err.Error())
 				}
 				f <- struct{}{}
+				wf.rotateIfFull()
+			case req := <-ThisInstance.TXNPipe.syncChannel:
+				if err := req.flush(wf); err != nil {
+					log.Fatal(err.Error())
+				}
+				req.done <- req.run()
 			case <-tickerCheck.C:
 				queued := len(ThisInstance.TXNPipe.writeChannel)
 				if float64(queued)/float64(chanCap) >= 0.8 {
 					if err := wf.FlushToWAL(ThisInstance.TXNPipe); err != nil {
 						log.Fatal(err.Error())
 					}
+					wf.rotateIfFull()
 				}
 			case <-tickerPrimary.C:
 				wf.CreateCheckpoint()
-				primaryFlushCounter++
-				if primaryFlushCounter%walRotateInterval == 0 {
-					log.Info("Truncating WAL file...")
-					wf.FilePtr.Truncate(0)
-					wf.WriteStatus(wal.OPEN, wal.NOTREPLAYED)
-					primaryFlushCounter = 0
+				if wf.rotation.checkpoint(walRotateInterval) {
+					wf.Rotate()
 				}
 			}
 		} else {
@@ -857,8 +1114,19 @@ func (wf *WALFileType) SyncWAL(WALRefresh, PrimaryRefresh time.Duration, walRota
 // The function blocks if there are no current queued flushes, and
 // returns if there is already one queued which will handle the data
 // present in the write channel, as it will flush as soon as possible.
+// With the write batching, it waits for the flush of the batch instead.
 func (wf *WALFileType) RequestFlush() {
+	if window := utils.InstanceConfig.WriteBatchWindow; window > 0 {
+		wf.requestBatchedFlush(window, batchSize())
+		return
+	}
+	wf.flush()
+}
+
+func (wf *WALFileType) flush() {
 	if !haveWALWriter {
+		commitMutex.Lock()
+		defer commitMutex.Unlock()
 		wf.FlushToWAL(ThisInstance.TXNPipe)
 		return
 	}
@@ -870,3 +1138,129 @@ func (wf *WALFileType) RequestFlush() {
 	ThisInstance.TXNPipe.flushChannel <- f
 	<-f
 }
+
+// flushAndWait flushes the write channel like RequestFlush, but always waits
+// for its own flush, so that the previously queued flushes are complete and
+// their written records dispatched to the triggers when it returns.
+func (wf *WALFileType) flushAndWait(ctx context.Context) error {
+	if !haveWALWriter {
+		commitMutex.Lock()
+		defer commitMutex.Unlock()
+		return wf.FlushToWAL(ThisInstance.TXNPipe)
+	}
+	// buffered so that the WAL writer never blocks on an abandoned request
+	f := make(chan struct{}, 1)
+	select {
+	case ThisInstance.TXNPipe.flushChannel <- f:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-f:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// commitMutex serializes the commits to the primary files
+// without the WAL writer goroutine, which serializes them otherwise.
+var commitMutex sync.Mutex
+
+// syncRequest is a request to the WAL writer to sync, or only to flush the
+// pending writes with flushOnly, and then to run a function while the
+// commits are frozen.
+type syncRequest struct {
+	then      func() error
+	flushOnly bool
+	done      chan error
+}
+
+// flush commits the pending writes like sync does, without the checkpoint
+// if only a flush is requested.
+func (req *syncRequest) flush(wf *WALFileType) error {
+	if req.flushOnly {
+		return wf.FlushToWAL(ThisInstance.TXNPipe)
+	}
+	return wf.sync()
+}
+
+func (req *syncRequest) run() error {
+	if req.then == nil {
+		return nil
+	}
+	return req.then()
+}
+
+// Sync flushes the pending writes to the WAL and the primary files, and
+// syncs them to disk with a checkpoint, so that all the data written so far
+// is durable when it returns.  It is safe to call concurrently.
+func (wf *WALFileType) Sync(ctx context.Context) error {
+	return wf.requestSync(ctx, nil)
+}
+
+// requestSync syncs like Sync, and then runs then before any other write is
+// committed.  Once the request is queued, it is carried out even if ctx is done.
+func (wf *WALFileType) requestSync(ctx context.Context, then func() error) error {
+	// buffered so that the WAL writer never blocks on an abandoned request
+	return wf.request(ctx, &syncRequest{then: then, done: make(chan error, 1)})
+}
+
+// ReadConsistent flushes the writes accepted so far to the primary files,
+// and then runs read before any other write is committed, so that all the
+// reads of read see the same writes: those accepted before the call, and
+// none accepted after it.  The commits wait for read, which must not write.
+func (i *InstanceMetadata) ReadConsistent(ctx context.Context, read func() error) error {
+	return i.WALFile.request(ctx, &syncRequest{then: read, flushOnly: true, done: make(chan error, 1)})
+}
+
+// request carries out the sync request, and waits for it unless ctx is done.
+func (wf *WALFileType) request(ctx context.Context, req *syncRequest) error {
+	if !haveWALWriter {
+		commitMutex.Lock()
+		defer commitMutex.Unlock()
+		if err := req.flush(wf); err != nil {
+			return err
+		}
+		return req.run()
+	}
+	select {
+	case ThisInstance.TXNPipe.syncChannel <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (wf *WALFileType) sync() error {
+	if err := wf.FlushToWAL(ThisInstance.TXNPipe); err != nil {
+		return err
+	}
+	if ThisInstance.WALBypass {
+		// there is no WAL transaction for the checkpoint to sync
+		io.Syncfs()
+	}
+	return wf.CreateCheckpoint()
+}
+
+// LastFlush returns the time of the last flush to the WAL, or the zero time if none.
+func (wf *WALFileType) LastFlush() time.Time {
+	return unixNanoTime(atomic.LoadInt64(&wf.lastFlush))
+}
+
+// LastCheckpoint returns the time of the last checkpoint, or the zero time if none.
+func (wf *WALFileType) LastCheckpoint() time.Time {
+	return unixNanoTime(atomic.LoadInt64(&wf.lastCheckpoint))
+}
+
+func unixNanoTime(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
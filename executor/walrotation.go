@@ -0,0 +1,86 @@
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils"
+)
+
+// walRotation tells when the WAL file is due for rotation, by the
+// wal_rotation of the timeframes written to it since the previous rotation
+// and wal_rotate_interval for the others.  The WAL file is shared by all the
+// timeframes, so it is rotated at the shortest interval and the smallest
+// size of those written.  Like the other state of the WAL file, it is used
+// by the WAL writer only.
+type walRotation struct {
+	// checkpoints is the number of checkpoints since the previous rotation
+	checkpoints int
+	// timeframes are those written since the previous rotation, with their
+	// rotation overridden
+	timeframes map[time.Duration]utils.WALRotation
+}
+
+// written records the write of the file of the WAL key path
+// {Symbol}/{Timeframe}/{AttributeGroup}/{Year}.bin.
+func (r *walRotation) written(keyPath string) {
+	if len(utils.InstanceConfig.WALRotation) == 0 {
+		return
+	}
+	parts := strings.Split(keyPath, string(filepath.Separator))
+	if len(parts) < 2 {
+		return
+	}
+	tf := utils.TimeframeFromString(parts[1])
+	if tf == nil {
+		return
+	}
+	if _, ok := r.timeframes[tf.Duration]; ok {
+		return
+	}
+	if rotation, ok := utils.InstanceConfig.WALRotation[tf.Duration]; ok {
+		if r.timeframes == nil {
+			r.timeframes = map[time.Duration]utils.WALRotation{}
+		}
+		r.timeframes[tf.Duration] = rotation
+	}
+}
+
+// checkpoint counts a checkpoint, and returns if the WAL file is due for
+// rotation after it, every interval checkpoints of the timeframes written,
+// or of defaultInterval without any overridden.
+func (r *walRotation) checkpoint(defaultInterval int) bool {
+	r.checkpoints++
+	interval := defaultInterval
+	if len(r.timeframes) > 0 {
+		interval = 0
+		for _, rotation := range r.timeframes {
+			i := rotation.Interval
+			if i == 0 {
+				i = defaultInterval
+			}
+			if interval == 0 || i < interval {
+				interval = i
+			}
+		}
+	}
+	return r.checkpoints >= interval
+}
+
+// maxSize returns the size of the WAL file past which it is rotated before
+// its interval, 0 without limit.
+func (r *walRotation) maxSize() (size int64) {
+	for _, rotation := range r.timeframes {
+		if rotation.Size > 0 && (size == 0 || rotation.Size < size) {
+			size = rotation.Size
+		}
+	}
+	return size
+}
+
+// rotated starts over after a rotation.
+func (r *walRotation) rotated() {
+	r.checkpoints = 0
+	r.timeframes = nil
+}
@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/utils"
+)
+
+type WALRotationTests struct{}
+
+var _ = Suite(&WALRotationTests{})
+
+func (s *WALRotationTests) TearDownTest(c *C) {
+	utils.InstanceConfig.WALRotation = nil
+}
+
+// checkpoints returns the number of checkpoints until the rotation.
+func (s *WALRotationTests) checkpoints(r *walRotation, defaultInterval int) int {
+	n := 1
+	for !r.checkpoint(defaultInterval) {
+		n++
+	}
+	r.rotated()
+	return n
+}
+
+func (s *WALRotationTests) TestDefaultInterval(c *C) {
+	r := &walRotation{}
+	r.written(filepath.Join("AAPL", "1Min", "OHLCV", "2020.bin"))
+	c.Assert(s.checkpoints(r, 5), Equals, 5)
+	c.Assert(s.checkpoints(r, 5), Equals, 5)
+	c.Assert(r.maxSize(), Equals, int64(0))
+}
+
+func (s *WALRotationTests) TestTimeframeOverrides(c *C) {
+	utils.InstanceConfig.WALRotation = map[time.Duration]utils.WALRotation{
+		time.Minute: {Interval: 10},
+		time.Second: {Interval: 2, Size: 1 << 20},
+		time.Hour:   {Size: 4 << 20},
+	}
+	minute := filepath.Join("AAPL", "1Min", "OHLCV", "2020.bin")
+	second := filepath.Join("AAPL", "1Sec", "TICK", "2020.bin")
+	hour := filepath.Join("AAPL", "1H", "OHLCV", "2020.bin")
+
+	// without writes, or with those of the timeframes not overridden, the
+	// default interval applies
+	r := &walRotation{}
+	c.Assert(s.checkpoints(r, 5), Equals, 5)
+	r.written(filepath.Join("AAPL", "1D", "OHLCV", "2020.bin"))
+	c.Assert(s.checkpoints(r, 5), Equals, 5)
+
+	r.written(minute)
+	c.Assert(s.checkpoints(r, 5), Equals, 10)
+
+	// the shortest interval and the smallest size of the timeframes written
+	r.written(minute)
+	r.written(second)
+	c.Assert(r.maxSize(), Equals, int64(1<<20))
+	c.Assert(s.checkpoints(r, 5), Equals, 2)
+
+	// an override without interval takes the default one
+	r.written(minute)
+	r.written(hour)
+	c.Assert(r.maxSize(), Equals, int64(4<<20))
+	c.Assert(s.checkpoints(r, 5), Equals, 5)
+	c.Assert(r.maxSize(), Equals, int64(0))
+}
@@ -2,14 +2,18 @@ package executor
 
 import (
 	"fmt"
+	"hash/fnv"
 	stdio "io"
 	"os"
+	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/alpacahq/marketstore/v4/catalog"
 	"github.com/alpacahq/marketstore/v4/executor/wal"
+	"github.com/alpacahq/marketstore/v4/metrics"
 	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/io"
 	. "github.com/alpacahq/marketstore/v4/utils/io"
@@ -43,6 +47,22 @@ func NewWriter(tbi *io.TimeBucketInfo, tgc *TransactionPipe, rootCatDir *catalog
 	}, nil
 }
 
+// bucketLockShards is the number of the locks serializing the writes to the
+// buckets, shared by the buckets hashed to the same one.
+const bucketLockShards = 256
+
+var bucketLocks [bucketLockShards]sync.Mutex
+
+// lockBucket locks the writes to the bucket of the directory, and returns
+// the function unlocking them.
+func lockBucket(dir string) (unlock func()) {
+	h := fnv.New32a()
+	h.Write([]byte(dir))
+	m := &bucketLocks[h.Sum32()%bucketLockShards]
+	m.Lock()
+	return m.Unlock
+}
+
 func (w *Writer) AddNewYearFile(year int16) (err error) {
 	newTbi, err := w.root.GetSubDirectoryAndAddFile(w.tbi.Path, year)
 	if err != nil {
@@ -85,6 +105,9 @@ func (w *Writer) WriteRecords(ts []time.Time, data []byte, ds []DataShape) {
 	if numRows == 0 {
 		return
 	}
+	// the records of a write are queued together, not interleaved with
+	// those of the concurrent writes to the bucket
+	defer lockBucket(filepath.Dir(w.tbi.Path))()
 
 	var (
 		prevIndex int64
@@ -138,7 +161,7 @@ func (w *Writer) WriteRecords(ts []time.Time, data []byte, ds []DataShape) {
 			/*
 				This row is at a new index, output previous output buffer
 			*/
-			w.tgc.writeChannel <- cc
+			w.tgc.write(cc)
 			// Setup next command
 			prevIndex = index
 			outBuf = formatRecord([]byte{}, record, t, index, w.tbi.GetIntervals(), w.tbi.GetRecordType() == VARIABLE)
@@ -155,7 +178,7 @@ func (w *Writer) WriteRecords(ts []time.Time, data []byte, ds []DataShape) {
 	}
 
 	// output to WAL
-	w.tgc.writeChannel <- cc
+	w.tgc.write(cc)
 }
 
 func AppendIntervalTicks(buf []byte, t time.Time, index, intervalsPerDay int64) (outBuf []byte) {
@@ -168,6 +191,20 @@ func AppendIntervalTicks(buf []byte, t time.Time, index, intervalsPerDay int64)
 func WriteBufferToFile(fp stdio.WriterAt, buffer wal.OffsetIndexBuffer) error {
 	offset := buffer.Offset()
 	data := buffer.IndexAndPayload()
+	if policy := utils.InstanceConfig.WriteDuplicates; policy == utils.DuplicatesOverwrite || policy == utils.DuplicatesReject {
+		if r, ok := fp.(stdio.ReaderAt); ok {
+			exists, err := fixedRecordExists(r, offset)
+			if err != nil {
+				return err
+			}
+			if exists {
+				metrics.WriteDuplicates.WithLabelValues(policy).Inc()
+				if policy == utils.DuplicatesReject {
+					return nil
+				}
+			}
+		}
+	}
 	_, err := fp.WriteAt(data, offset)
 	return err
 }
@@ -229,6 +266,8 @@ func WriteBufferToFileIndirect(fp *os.File, buffer wal.OffsetIndexBuffer, varRec
 		Sort the data by the timestamp to maintain on-disk sorted order
 	*/
 	sort.Stable(NewByIntervalTicks(dataToBeWritten, int(dataLen)/varRecLen, varRecLen))
+	dataToBeWritten = dedupVariableRecords(dataToBeWritten, varRecLen, utils.InstanceConfig.WriteDuplicates)
+	dataLen = int64(len(dataToBeWritten))
 
 	/*
 		Write the data at the end of the file
@@ -266,6 +305,16 @@ func WriteBufferToFileIndirect(fp *os.File, buffer wal.OffsetIndexBuffer, varRec
 // not already exist for the given ColumnSeriesMap based on its TimeBucketKey.
 func WriteCSM(csm io.ColumnSeriesMap, isVariableLength bool) (err error) {
 	cDir := ThisInstance.CatalogDir
+	type writtenTimes struct {
+		tbk   io.TimeBucketKey
+		times []time.Time
+	}
+	var written []writtenTimes
+	for tbk := range csm {
+		if err := checkDeclared(&tbk); err != nil {
+			return err
+		}
+	}
 	for tbk, cs := range csm {
 		tf, err := tbk.GetTimeFrame()
 		if err != nil {
@@ -284,8 +333,6 @@ func WriteCSM(csm io.ColumnSeriesMap, isVariableLength bool) (err error) {
 			cs.Remove("Nanoseconds")
 			alignData = false
 		}
-		rs := cs.ToRowSeries(tbk, alignData)
-		rowsdata := rs.GetData()
 
 		tbi, err := cDir.GetLatestTimeBucketInfoFromKey(&tbk)
 		if err != nil {
@@ -315,15 +362,23 @@ func WriteCSM(csm io.ColumnSeriesMap, isVariableLength bool) (err error) {
 				cs.GetDataShapes(), recordType)
 
 			/*
-				Verify there is an available TimeBucket for the destination
+				Verify there is an available TimeBucket for the destination,
+				which may have been created by a concurrent writer
 			*/
-			if err := cDir.AddTimeBucket(&tbk, tbi); err != nil {
+			existing, _, err := ensureTimeBucket(&tbk, tbi)
+			if err != nil {
 				// If File Exists error, ignore it, otherwise return the error
 				if !strings.Contains(err.Error(), "Can not overwrite file") && !strings.Contains(err.Error(), "file exists") {
 					return err
 				}
+			} else {
+				tbi = existing
 			}
 		}
+		// Convert the values of the scaled integer columns
+		if cs, err = io.ScaleColumns(cs, tbi); err != nil {
+			return err
+		}
 		// Check if the previously-written data schema matches the input
 		columnMismatchError := "unable to match data columns (%v) to bucket columns (%v)"
 		dbDSV := tbi.GetDataShapesWithEpoch()
@@ -336,6 +391,14 @@ func WriteCSM(csm io.ColumnSeriesMap, isVariableLength bool) (err error) {
 			return fmt.Errorf(columnMismatchError, csDSV, dbDSV)
 		}
 
+		// the strings are stored out of the records, which refer to them
+		if cs, err = storeStrings(&tbk, cs, dbDSV); err != nil {
+			return err
+		}
+
+		rs := cs.ToRowSeries(tbk, alignData)
+		rowsdata := rs.GetData()
+
 		/*
 			Create a writer for this TimeBucket
 		*/
@@ -345,8 +408,13 @@ func WriteCSM(csm io.ColumnSeriesMap, isVariableLength bool) (err error) {
 		}
 
 		w.WriteRecords(times, rowsdata, dbDSV)
+		written = append(written, writtenTimes{tbk, times})
 	}
 	walfile := ThisInstance.WALFile
 	walfile.RequestFlush()
+	// the buckets not read yet have their tail read from the files
+	for _, w := range written {
+		advanceBucketTail(&w.tbk, w.times, false)
+	}
 	return nil
 }
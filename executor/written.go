@@ -1,23 +1,96 @@
 package executor
 
 import (
+	"context"
+	"fmt"
+	"hash/fnv"
 	"runtime/debug"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/alpacahq/marketstore/v4/utils/log"
-
+	"github.com/alpacahq/marketstore/v4/metrics"
 	"github.com/alpacahq/marketstore/v4/plugins/trigger"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/log"
 )
 
 var (
-	once      sync.Once
-	c         chan writtenRecords
-	done      chan struct{}
-	m         map[string][]trigger.Record
-	triggerWg sync.WaitGroup
+	once sync.Once
+	c    chan writtenRecords
+	done chan struct{}
+	m    map[string][]trigger.Record
+	// triggerJobs tracks the outstanding trigger jobs, from the dispatch
+	// of the written records until the fired triggers return.
+	triggerJobs outstandingJobs
+	// dispatched counts the dispatched written records
+	dispatched uint64
+	// queues are the trigger queues by matcher, one per trigger worker,
+	// only used by run
+	queues = map[*trigger.TriggerMatcher][]*triggerQueue{}
 )
 
+const (
+	// triggerQueueDepth is the number of written records a worker of a
+	// trigger can lag behind before the next ones are dropped for it.
+	triggerQueueDepth = 10000
+	// triggerMaxAttempts is the number of times a trigger is fired on the
+	// written records before they are dead-lettered.
+	triggerMaxAttempts = 3
+)
+
+// triggerRetryBackoff is the wait before the second attempt to fire a trigger,
+// growing linearly with the next attempts, during which the queue fires the
+// next written records.
+var triggerRetryBackoff = 100 * time.Millisecond
+
+// triggerQueue fires a trigger in order on the written records it is sent.
+type triggerQueue struct {
+	name    string
+	trigger trigger.Trigger
+	c       chan writtenRecords
+}
+
+// outstandingJobs counts the trigger jobs not done yet.  Unlike a WaitGroup,
+// it can be waited on while jobs are added, and the wait can be abandoned.
+type outstandingJobs struct {
+	sync.Mutex
+	n int
+	// idle is closed when n drops to zero
+	idle chan struct{}
+}
+
+func (j *outstandingJobs) add(delta int) {
+	j.Lock()
+	defer j.Unlock()
+	if j.n == 0 && delta > 0 {
+		j.idle = make(chan struct{})
+	}
+	j.n += delta
+	switch {
+	case j.n < 0:
+		panic("negative outstanding trigger jobs")
+	case j.n == 0 && delta < 0:
+		close(j.idle)
+	}
+}
+
+func (j *outstandingJobs) done() {
+	j.add(-1)
+}
+
+// wait returns a channel closed once no job is outstanding.
+func (j *outstandingJobs) wait() <-chan struct{} {
+	j.Lock()
+	defer j.Unlock()
+	if j.n == 0 {
+		idle := make(chan struct{})
+		close(idle)
+		return idle
+	}
+	return j.idle
+}
+
 type writtenRecords struct {
 	key     string
 	records []trigger.Record
@@ -43,6 +116,9 @@ func appendRecord(keyPath string, record []byte) {
 // run in a separate goroutine and recovers from panics in the triggers.
 func dispatchRecords() {
 	for key, records := range m {
+		notifySubscriptions(key, records)
+		triggerJobs.add(1)
+		atomic.AddUint64(&dispatched, 1)
 		c <- writtenRecords{key: key, records: records}
 	}
 	m = nil // for GC
@@ -53,27 +129,123 @@ func run() {
 	for wr := range c {
 		for _, tmatcher := range ThisInstance.TriggerMatchers {
 			if tmatcher.Match(wr.key) {
-				triggerWg.Add(1)
-				go fire(tmatcher.Trigger, wr.key, wr.records)
+				queueFor(tmatcher, wr.key).enqueue(wr)
 			}
 		}
+		triggerJobs.done()
 	}
 }
 
-func fire(trig trigger.Trigger, key string, records []trigger.Record) {
+// queueFor returns the queue of the matcher for the file of key, starting
+// the queues of the matcher on first use.  The writes to a file are always
+// fired by the same queue, so that they are fired in order.
+func queueFor(tmatcher *trigger.TriggerMatcher, key string) *triggerQueue {
+	qs, ok := queues[tmatcher]
+	if !ok {
+		qs = make([]*triggerQueue, triggerWorkers())
+		for i := range qs {
+			qs[i] = &triggerQueue{
+				name:    triggerName(tmatcher),
+				trigger: tmatcher.Trigger,
+				c:       make(chan writtenRecords, triggerQueueDepth),
+			}
+			go qs[i].run()
+		}
+		queues[tmatcher] = qs
+	}
+	if len(qs) == 1 {
+		return qs[0]
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return qs[h.Sum32()%uint32(len(qs))]
+}
+
+// triggerWorkers returns the number of workers firing each trigger.
+func triggerWorkers() int {
+	if utils.InstanceConfig.TriggerWorkers > 0 {
+		return utils.InstanceConfig.TriggerWorkers
+	}
+	return 1
+}
+
+// triggerName returns the name of the trigger in the logs and metrics.
+func triggerName(tmatcher *trigger.TriggerMatcher) string {
+	if tmatcher.Name != "" {
+		return tmatcher.Name
+	}
+	return fmt.Sprintf("%T", tmatcher.Trigger)
+}
+
+// enqueue sends the written records to the trigger, or drops them if the
+// trigger is too far behind, so that it never blocks.
+func (q *triggerQueue) enqueue(wr writtenRecords) {
+	triggerJobs.add(1)
+	select {
+	case q.c <- wr:
+		metrics.TriggerQueueDepth.WithLabelValues(q.name).Inc()
+	default:
+		triggerJobs.done()
+		metrics.TriggerDropped.WithLabelValues(q.name).Inc()
+		log.Error("trigger %s is too far behind, dropped the write to %s", q.name, wr.key)
+		ThisInstance.DeadLetters.Add(newDeadLetter(q.name, wr.key, wr.records, "trigger queue is full"))
+	}
+}
+
+func (q *triggerQueue) run() {
+	for wr := range q.c {
+		metrics.TriggerQueueDepth.WithLabelValues(q.name).Dec()
+		q.fire(wr.key, wr.records)
+	}
+}
+
+// fire fires the trigger on the written records, retrying it a few times if
+// it panics or returns an error, and dead-letters them if it keeps failing.
+// The retries are scheduled rather than waited for, so that a failing write
+// does not hold up the next ones of the queue, which may then be fired
+// before it.
+func (q *triggerQueue) fire(key string, records []trigger.Record) {
+	q.attempt(key, records, 1)
+}
+
+func (q *triggerQueue) attempt(key string, records []trigger.Record, attempt int) {
+	err := q.fireOnce(key, records)
+	switch {
+	case err == nil:
+	case attempt < triggerMaxAttempts:
+		time.AfterFunc(time.Duration(attempt)*triggerRetryBackoff, func() {
+			q.attempt(key, records, attempt+1)
+		})
+		// still outstanding until the retry
+		return
+	default:
+		ThisInstance.DeadLetters.Add(newDeadLetter(q.name, key, records, err.Error()))
+	}
+	triggerJobs.done()
+}
+
+func (q *triggerQueue) fireOnce(key string, records []trigger.Record) (err error) {
+	start := time.Now()
 	defer func() {
-		triggerWg.Done()
+		metrics.TriggerFires.WithLabelValues(q.name).Inc()
+		metrics.TriggerDuration.WithLabelValues(q.name).Observe(time.Since(start).Seconds())
 		if r := recover(); r != nil {
-			log.Error("recovering from %v\n%s", r, string(debug.Stack()))
+			metrics.TriggerErrors.WithLabelValues(q.name).Inc()
+			log.Error("trigger %s failed on %s, recovering from %v\n%s", q.name, key, r, string(debug.Stack()))
+			err = fmt.Errorf("%v", r)
 		}
 	}()
-	trig.Fire(key, records)
+	if err = trigger.Fire(q.trigger, key, records); err != nil {
+		metrics.TriggerErrors.WithLabelValues(q.name).Inc()
+		log.Error("trigger %s failed on %s: %v", q.name, key, err)
+	}
+	return err
 }
 
 // FinishAndWait closes the writtenIndexes channel, and waits
 // for the remaining triggers to fire, returning
 func FinishAndWait() {
-	triggerWg.Wait()
+	<-triggerJobs.wait()
 	for {
 		if len(ThisInstance.TXNPipe.writeChannel) == 0 && len(c) == 0 {
 			close(c)
@@ -83,3 +255,27 @@ func FinishAndWait() {
 		time.Sleep(500 * time.Millisecond)
 	}
 }
+
+// DrainTriggers flushes the pending writes and waits until the triggers fired
+// by them, as well as by the writes of the triggers themselves, have returned.
+// It returns the context error if ctx is done before that.
+func (i *InstanceMetadata) DrainTriggers(ctx context.Context) error {
+	for {
+		before := atomic.LoadUint64(&dispatched)
+
+		if err := i.WALFile.flushAndWait(ctx); err != nil {
+			return err
+		}
+
+		select {
+		case <-triggerJobs.wait():
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		// done if nothing was written since the last round
+		if atomic.LoadUint64(&dispatched) == before && len(i.TXNPipe.writeChannel) == 0 {
+			return nil
+		}
+	}
+}
@@ -1,10 +1,18 @@
 package executor
 
 import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	. "gopkg.in/check.v1"
 
 	"github.com/alpacahq/marketstore/v4/executor/wal"
+	"github.com/alpacahq/marketstore/v4/metrics"
 	"github.com/alpacahq/marketstore/v4/plugins/trigger"
+	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/io"
 )
 
@@ -31,6 +39,12 @@ func (s *WrittenIndexesTests) SetUpSuite(c *C) {
 	ThisInstance.TXNPipe = NewTransactionPipe()
 }
 
+// TearDownTest waits for the triggers fired by the test, which read the
+// matchers set by the next one.
+func (s *WrittenIndexesTests) TearDownTest(c *C) {
+	<-triggerJobs.wait()
+}
+
 func (s *WrittenIndexesTests) TearDownSuite(c *C) {
 	ThisInstance.TriggerMatchers = nil
 }
@@ -60,3 +74,247 @@ func (s *WrittenIndexesTests) TestWrittenIndexes(c *C) {
 	dispatchRecords()
 	c.Check(len(t.calledWith), Equals, 0)
 }
+
+type SlowTrigger struct {
+	release chan struct{}
+	fired   chan string
+}
+
+func (t *SlowTrigger) Fire(keyPath string, records []trigger.Record) {
+	<-t.release
+	t.fired <- keyPath
+}
+
+func (s *WrittenIndexesTests) TestDrainTriggers(c *C) {
+	t := &SlowTrigger{release: make(chan struct{}), fired: make(chan string, 1)}
+	s.SetTrigger(t, "AAPL/1Min/OHLCV")
+
+	buffer := io.SwapSliceData([]int64{0, 5}, byte(0)).([]byte)
+	appendRecord("AAPL/1Min/OHLCV/2017.bin", wal.OffsetIndexBuffer(buffer).IndexAndPayload())
+	dispatchRecords()
+
+	// times out while the trigger is still running
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	c.Assert(ThisInstance.DrainTriggers(ctx), Equals, context.DeadlineExceeded)
+
+	// returns only after the trigger is done
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(t.release)
+	}()
+	c.Assert(ThisInstance.DrainTriggers(context.Background()), IsNil)
+	select {
+	case key := <-t.fired:
+		c.Assert(key, Equals, "AAPL/1Min/OHLCV/2017.bin")
+	default:
+		c.Fatal("DrainTriggers returned before the trigger was done")
+	}
+
+	// nothing to drain
+	c.Assert(ThisInstance.DrainTriggers(context.Background()), IsNil)
+}
+
+func (s *WrittenIndexesTests) TestTriggerDeadLetters(c *C) {
+	defer func(backoff time.Duration) { triggerRetryBackoff = backoff }(triggerRetryBackoff)
+	triggerRetryBackoff = time.Millisecond
+	ThisInstance.DeadLetters = NewDeadLetterQueue(filepath.Join(c.MkDir(), DeadLetterFileName))
+	defer func() { ThisInstance.DeadLetters = nil }()
+
+	t := &FakeTrigger{toPanic: true, fireC: make(chan struct{}, 1)}
+	ThisInstance.TriggerMatchers = []*trigger.TriggerMatcher{
+		{Trigger: t, On: "AAPL/1Min/OHLCV", Name: "panicky.so"},
+	}
+
+	buffer := io.SwapSliceData([]int64{0, 5}, byte(0)).([]byte)
+	appendRecord("AAPL/1Min/OHLCV/2017.bin", wal.OffsetIndexBuffer(buffer).IndexAndPayload())
+	dispatchRecords()
+	c.Assert(ThisInstance.DrainTriggers(context.Background()), IsNil)
+
+	// fired and failed on every attempt
+	c.Check(testutil.ToFloat64(metrics.TriggerFires.WithLabelValues("panicky.so")), Equals, float64(triggerMaxAttempts))
+	c.Check(testutil.ToFloat64(metrics.TriggerErrors.WithLabelValues("panicky.so")), Equals, float64(triggerMaxAttempts))
+	c.Check(testutil.ToFloat64(metrics.TriggerQueueDepth.WithLabelValues("panicky.so")), Equals, float64(0))
+
+	// then dead-lettered, asynchronously
+	var letters []*DeadLetter
+	for i := 0; i < 100 && len(letters) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		var err error
+		letters, err = ThisInstance.DeadLetters.List()
+		c.Assert(err, IsNil)
+	}
+	c.Assert(letters, HasLen, 1)
+	c.Check(letters[0].Trigger, Equals, "panicky.so")
+	c.Check(letters[0].Key, Equals, "AAPL/1Min/OHLCV/2017.bin")
+	c.Check(letters[0].FirstIndex, Equals, int64(5))
+	c.Check(letters[0].LastIndex, Equals, int64(5))
+	c.Check(letters[0].Error, Equals, "panic test")
+
+	// fails again
+	failed, err := ThisInstance.DeadLetters.Redrive(ThisInstance.TriggerMatchers)
+	c.Assert(err, IsNil)
+	c.Assert(failed, HasLen, 1)
+
+	// succeeds once the trigger is fixed
+	t.toPanic = false
+	failed, err = ThisInstance.DeadLetters.Redrive(ThisInstance.TriggerMatchers, letters[0].ID)
+	c.Assert(err, IsNil)
+	c.Assert(failed, HasLen, 0)
+	c.Assert(t.calledWith, HasLen, 1)
+	c.Check(t.calledWith[0][0], Equals, "AAPL/1Min/OHLCV/2017.bin")
+	c.Check(t.calledWith[0][1].([]trigger.Record)[0].Index(), Equals, int64(5))
+
+	letters, err = ThisInstance.DeadLetters.List()
+	c.Assert(err, IsNil)
+	c.Assert(letters, HasLen, 0)
+}
+
+type FailingTrigger struct {
+	FakeTrigger
+	failures int
+}
+
+func (t *FailingTrigger) FireWithError(keyPath string, records []trigger.Record) error {
+	if t.failures > 0 {
+		t.failures--
+		return fmt.Errorf("write error")
+	}
+	t.Fire(keyPath, records)
+	return nil
+}
+
+func (s *WrittenIndexesTests) TestTriggerErrors(c *C) {
+	defer func(backoff time.Duration) { triggerRetryBackoff = backoff }(triggerRetryBackoff)
+	triggerRetryBackoff = time.Millisecond
+	ThisInstance.DeadLetters = NewDeadLetterQueue(filepath.Join(c.MkDir(), DeadLetterFileName))
+	defer func() { ThisInstance.DeadLetters = nil }()
+
+	// fired again after an error
+	t := &FailingTrigger{FakeTrigger: FakeTrigger{fireC: make(chan struct{}, 2)}, failures: 1}
+	ThisInstance.TriggerMatchers = []*trigger.TriggerMatcher{
+		{Trigger: t, On: "AAPL/1Min/OHLCV", Name: "failing.so"},
+	}
+	buffer := io.SwapSliceData([]int64{0, 5}, byte(0)).([]byte)
+	appendRecord("AAPL/1Min/OHLCV/2017.bin", wal.OffsetIndexBuffer(buffer).IndexAndPayload())
+	dispatchRecords()
+	c.Assert(ThisInstance.DrainTriggers(context.Background()), IsNil)
+	c.Assert(t.calledWith, HasLen, 1)
+	c.Check(testutil.ToFloat64(metrics.TriggerErrors.WithLabelValues("failing.so")), Equals, float64(1))
+
+	// dead-lettered after the last attempt
+	t.failures = triggerMaxAttempts
+	appendRecord("AAPL/1Min/OHLCV/2017.bin", wal.OffsetIndexBuffer(buffer).IndexAndPayload())
+	dispatchRecords()
+	c.Assert(ThisInstance.DrainTriggers(context.Background()), IsNil)
+	c.Assert(t.calledWith, HasLen, 1)
+
+	var letters []*DeadLetter
+	for i := 0; i < 100 && len(letters) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		var err error
+		letters, err = ThisInstance.DeadLetters.List()
+		c.Assert(err, IsNil)
+	}
+	c.Assert(letters, HasLen, 1)
+	c.Check(letters[0].Trigger, Equals, "failing.so")
+	c.Check(letters[0].Error, Equals, "write error")
+
+	// redriven once the error is gone
+	failed, err := ThisInstance.DeadLetters.Redrive(ThisInstance.TriggerMatchers)
+	c.Assert(err, IsNil)
+	c.Assert(failed, HasLen, 0)
+	c.Assert(t.calledWith, HasLen, 2)
+}
+
+// KeyFailingTrigger fails on the writes to the file of key.
+type KeyFailingTrigger struct {
+	key   string
+	fired chan string
+}
+
+func (t *KeyFailingTrigger) FireWithError(keyPath string, records []trigger.Record) error {
+	if keyPath == t.key {
+		return fmt.Errorf("write error")
+	}
+	t.fired <- keyPath
+	return nil
+}
+
+func (t *KeyFailingTrigger) Fire(keyPath string, records []trigger.Record) {}
+
+func (s *WrittenIndexesTests) TestTriggerRetryNotBlocking(c *C) {
+	defer func(backoff time.Duration) { triggerRetryBackoff = backoff }(triggerRetryBackoff)
+	triggerRetryBackoff = 300 * time.Millisecond
+	ThisInstance.DeadLetters = NewDeadLetterQueue(filepath.Join(c.MkDir(), DeadLetterFileName))
+	defer func() { ThisInstance.DeadLetters = nil }()
+
+	failing := &KeyFailingTrigger{key: "AAPL/1Min/OHLCV/2017.bin", fired: make(chan string, 1)}
+	other := &FakeTrigger{fireC: make(chan struct{}, 2)}
+	ThisInstance.TriggerMatchers = []*trigger.TriggerMatcher{
+		{Trigger: failing, On: "*/1Min/OHLCV", Name: "blocking.so"},
+		{Trigger: other, On: "*/1Min/OHLCV", Name: "other.so"},
+	}
+	buffer := io.SwapSliceData([]int64{0, 5}, byte(0)).([]byte)
+	appendRecord("AAPL/1Min/OHLCV/2017.bin", wal.OffsetIndexBuffer(buffer).IndexAndPayload())
+	dispatchRecords()
+	appendRecord("TSLA/1Min/OHLCV/2017.bin", wal.OffsetIndexBuffer(buffer).IndexAndPayload())
+	dispatchRecords()
+
+	// the next write is fired while the failed one waits for its retry, by
+	// the same trigger and by another one
+	timeout := time.After(150 * time.Millisecond)
+	select {
+	case key := <-failing.fired:
+		c.Check(key, Equals, "TSLA/1Min/OHLCV/2017.bin")
+	case <-timeout:
+		c.Fatal("the retry of a failed write held up the next one")
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-other.fireC:
+		case <-timeout:
+			c.Fatal("another trigger was held up by the failing one")
+		}
+	}
+
+	// and the failed one is dead-lettered after its retries
+	c.Assert(ThisInstance.DrainTriggers(context.Background()), IsNil)
+	var letters []*DeadLetter
+	for i := 0; i < 100 && len(letters) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		var err error
+		letters, err = ThisInstance.DeadLetters.List()
+		c.Assert(err, IsNil)
+	}
+	c.Assert(letters, HasLen, 1)
+	c.Check(letters[0].Key, Equals, "AAPL/1Min/OHLCV/2017.bin")
+}
+
+func (s *WrittenIndexesTests) TestTriggerQueueFull(c *C) {
+	q := &triggerQueue{name: "full", c: make(chan writtenRecords, 1)}
+
+	q.enqueue(writtenRecords{key: "AAPL/1Min/OHLCV/2017.bin"})
+	q.enqueue(writtenRecords{key: "AAPL/1Min/OHLCV/2017.bin"})
+
+	c.Check(len(q.c), Equals, 1)
+	c.Check(testutil.ToFloat64(metrics.TriggerDropped.WithLabelValues("full")), Equals, float64(1))
+	<-q.c
+	triggerJobs.done()
+}
+
+func (s *WrittenIndexesTests) TestTriggerWorkers(c *C) {
+	utils.InstanceConfig.TriggerWorkers = 4
+	defer func() { utils.InstanceConfig.TriggerWorkers = 0 }()
+	tmatcher := trigger.NewMatcher(&FakeTrigger{fireC: make(chan struct{})}, "*/1Min/OHLCV")
+
+	q := queueFor(tmatcher, "AAPL/1Min/OHLCV/2017.bin")
+	c.Check(len(queues[tmatcher]), Equals, 4)
+	// the writes to a file always go to the same worker
+	c.Check(queueFor(tmatcher, "AAPL/1Min/OHLCV/2017.bin"), Equals, q)
+	used := map[*triggerQueue]bool{}
+	for _, symbol := range []string{"AAPL", "TSLA", "MSFT", "AMZN", "GOOG", "NFLX", "NVDA", "INTC"} {
+		used[queueFor(tmatcher, symbol+"/1Min/OHLCV/2017.bin")] = true
+	}
+	c.Check(len(used) > 1, Equals, true)
+}
@@ -0,0 +1,117 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// errNoFiles is returned by the query parse when the bucket has no files.
+const errNoFiles = "No files returned from query parse"
+
+// executeAliasQuery reads the bucket of tbk and the buckets of the same
+// timeframe and attribute group of its old symbols, and stitches them by
+// time.  Where two of them have a record at the same timestamp, only the
+// records of the most recent symbol are returned, so that the canonical
+// bucket of tbk overrides the old ones in the overlap of a ticker change.
+func executeAliasQuery(ctx context.Context, tbk *io.TimeBucketKey, olds []string, start, end time.Time,
+	LimitRecordCount int, LimitFromStart bool, columns []string) (io.ColumnSeriesMap, error) {
+	cd := utils.CandleDurationFromString(tbk.GetItemInCategory("Timeframe"))
+	queryableTimeframe := cd.QueryableTimeframe()
+
+	var stitched *io.ColumnSeries
+	for _, symbol := range append([]string{tbk.GetItemInCategory("Symbol")}, olds...) {
+		key := io.NewTimeBucketKey(tbk.GetItemKey(), tbk.GetCatKey())
+		key.SetItemInCategory("Symbol", symbol)
+		csm, err := executeBucketQuery(ctx, key, start, end, LimitRecordCount, LimitFromStart, columns)
+		if err != nil {
+			if err.Error() == errNoFiles {
+				continue
+			}
+			return nil, err
+		}
+		for _, cs := range csm {
+			if stitched == nil {
+				stitched = cs
+			} else if stitched, err = stitchSeries(cs, stitched); err != nil {
+				return nil, fmt.Errorf("stitching %s with the alias %s: %v", tbk.GetItemKey(), symbol, err)
+			}
+		}
+	}
+	tbk.SetItemInCategory("Timeframe", queryableTimeframe)
+	if stitched == nil {
+		return nil, fmt.Errorf(errNoFiles)
+	}
+
+	if LimitRecordCount != 0 {
+		direction := io.LAST
+		if LimitFromStart {
+			direction = io.FIRST
+		}
+		n := cd.QueryableNrecords(queryableTimeframe, LimitRecordCount)
+		if err := stitched.RestrictLength(n, direction); err != nil {
+			return nil, err
+		}
+	}
+
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, stitched)
+	return csm, nil
+}
+
+// stitchSeries returns the rows of old and recent sorted by time, without
+// those of old at a timestamp of a record of recent.
+func stitchSeries(old, recent *io.ColumnSeries) (*io.ColumnSeries, error) {
+	type timestamp struct {
+		epoch int64
+		nanos int32
+	}
+	timestamps := func(cs *io.ColumnSeries) []timestamp {
+		epoch := cs.GetEpoch()
+		nanos, _ := cs.GetColumn("Nanoseconds").([]int32)
+		out := make([]timestamp, len(epoch))
+		for i := range epoch {
+			out[i].epoch = epoch[i]
+			if nanos != nil {
+				out[i].nanos = nanos[i]
+			}
+		}
+		return out
+	}
+
+	recentTimes := timestamps(recent)
+	overridden := make(map[timestamp]bool, len(recentTimes))
+	for _, t := range recentTimes {
+		overridden[t] = true
+	}
+	var rows []int
+	var times []timestamp
+	for i, t := range timestamps(old) {
+		if !overridden[t] {
+			rows = append(rows, i)
+			times = append(times, t)
+		}
+	}
+
+	all, err := io.ColumnSeriesConcat(old.SelectRows(rows), recent)
+	if err != nil {
+		return nil, err
+	}
+	times = append(times, recentTimes...)
+	indexes := make([]int, len(times))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		ti, tj := times[indexes[i]], times[indexes[j]]
+		if ti.epoch != tj.epoch {
+			return ti.epoch < tj.epoch
+		}
+		return ti.nanos < tj.nanos
+	})
+	return all.SelectRows(indexes), nil
+}
@@ -0,0 +1,71 @@
+package frontend
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// EnsureBucket creates the bucket with the columns of the request if it
+// does not exist, and otherwise fails with FailedPrecondition unless it has
+// the same columns, scales and record type.  The concurrent calls for the
+// same bucket create it once, the others finding it.  A bucket with a
+// declared schema is created with it.
+func (s GRPCService) EnsureBucket(ctx context.Context, req *proto.EnsureBucketRequest) (*proto.EnsureBucketResponse, error) {
+	tbk := io.NewTimeBucketKey(req.Key)
+	if tbk == nil || len(tbk.GetItems()) != 3 {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"key \"%s\" is not in proper format, should be like: TSLA/1Min/OHLCV", req.Key)
+	}
+	tf, err := tbk.GetTimeFrame()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if len(req.DataShapes) == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "no columns for the bucket %s", req.Key)
+	}
+
+	dsv := []io.DataShape{{Name: "Epoch", Type: io.INT64}}
+	for _, shape := range req.DataShapes {
+		elemType, ok := dataTypeMap[shape.Type]
+		if !ok || shape.Type == proto.DataType_UNKNOWN || shape.Type == proto.DataType_NONE {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid type %s of the column %s", shape.Type, shape.Name)
+		}
+		if shape.Name == "" || shape.Name == "Epoch" {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid column name %q", shape.Name)
+		}
+		dsv = append(dsv, io.DataShape{Name: shape.Name, Type: elemType})
+	}
+	recordType := io.FIXED
+	if req.VariableLength {
+		recordType = io.VARIABLE
+	}
+
+	rootDir := executor.ThisInstance.RootDir
+	year := int16(time.Now().Year())
+	tbi := io.NewTimeBucketInfo(*tf, tbk.GetPathToYearFiles(rootDir), "Default", year, dsv, recordType)
+	for _, shape := range req.DataShapes {
+		if shape.Scale == 0 {
+			continue
+		}
+		if err := tbi.SetElementScale(shape.Name, int(shape.Scale)); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+
+	created, err := executor.EnsureBucket(tbk, tbi)
+	if err != nil {
+		switch err.(type) {
+		case executor.SchemaMismatchError, executor.UndeclaredSchemaError:
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "creation of the bucket %s failed: %v", req.Key, err)
+	}
+	return &proto.EnsureBucketResponse{Created: created}, nil
+}
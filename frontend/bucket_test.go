@@ -0,0 +1,209 @@
+package frontend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+func (s *ServerTestSuite) TestEnsureBucket(c *C) {
+	req := &proto.EnsureBucketRequest{
+		Key: "ENSURE/1Min/OHLCV",
+		DataShapes: []*proto.DataShape{
+			{Name: "Price", Type: proto.DataType_INT64, Scale: 2},
+			{Name: "Size", Type: proto.DataType_INT32},
+		},
+	}
+	resp, err := GRPCService{}.EnsureBucket(context.Background(), req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Created, Equals, true)
+
+	// found with the same schema
+	resp, err = GRPCService{}.EnsureBucket(context.Background(), req)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Created, Equals, false)
+
+	// written with the schema
+	tbk := io.NewTimeBucketKey("ENSURE/1Min/OHLCV")
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{time.Date(2021, 1, 4, 9, 30, 0, 0, time.UTC).Unix()})
+	cs.AddColumn("Price", []float64{1.25})
+	cs.AddColumn("Size", []int32{100})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+
+	for _, mismatched := range []*proto.EnsureBucketRequest{
+		// another column type
+		{Key: req.Key, DataShapes: []*proto.DataShape{
+			{Name: "Price", Type: proto.DataType_INT64, Scale: 2},
+			{Name: "Size", Type: proto.DataType_INT64},
+		}},
+		// another scale
+		{Key: req.Key, DataShapes: []*proto.DataShape{
+			{Name: "Price", Type: proto.DataType_INT64, Scale: 4},
+			{Name: "Size", Type: proto.DataType_INT32},
+		}},
+		// a missing column
+		{Key: req.Key, DataShapes: []*proto.DataShape{
+			{Name: "Price", Type: proto.DataType_INT64, Scale: 2},
+		}},
+		// variable length
+		{Key: req.Key, DataShapes: req.DataShapes, VariableLength: true},
+	} {
+		_, err = GRPCService{}.EnsureBucket(context.Background(), mismatched)
+		c.Check(status.Code(err), Equals, codes.FailedPrecondition)
+	}
+
+	for _, invalid := range []*proto.EnsureBucketRequest{
+		{Key: "ENSURE", DataShapes: req.DataShapes},
+		{Key: "ENSURE/1Foo/OHLCV", DataShapes: req.DataShapes},
+		{Key: "ENSURE/1Min/OHLCV"},
+		{Key: "ENSURE/1Min/OHLCV", DataShapes: []*proto.DataShape{{Name: "Price", Type: proto.DataType_UNKNOWN}}},
+		{Key: "ENSURE/1Min/OHLCV", DataShapes: []*proto.DataShape{{Name: "Price", Type: proto.DataType_FLOAT64, Scale: 2}}},
+	} {
+		_, err = GRPCService{}.EnsureBucket(context.Background(), invalid)
+		c.Check(status.Code(err), Equals, codes.InvalidArgument, Commentf("%v", invalid))
+	}
+}
+
+func (s *ServerTestSuite) TestEnsureBucketConcurrent(c *C) {
+	req := &proto.EnsureBucketRequest{
+		Key:        "RACE/1Sec/TICK",
+		DataShapes: []*proto.DataShape{{Name: "Price", Type: proto.DataType_FLOAT64}},
+	}
+
+	const creators = 16
+	var (
+		wg      sync.WaitGroup
+		start   = make(chan struct{})
+		created = make(chan bool, creators)
+		errs    = make(chan error, creators)
+	)
+	for i := 0; i < creators; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			resp, err := GRPCService{}.EnsureBucket(context.Background(), req)
+			if err != nil {
+				errs <- err
+				return
+			}
+			created <- resp.Created
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(created)
+	close(errs)
+
+	for err := range errs {
+		c.Error(err)
+	}
+	var n int
+	for ok := range created {
+		if ok {
+			n++
+		}
+	}
+	c.Assert(n, Equals, 1)
+
+	resp, err := GRPCService{}.DescribeSymbol(context.Background(), &proto.DescribeSymbolRequest{Symbol: "RACE"})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Buckets, HasLen, 1)
+	c.Assert(resp.Buckets[0].DataShapes, DeepEquals, req.DataShapes)
+}
+
+func (s *ServerTestSuite) TestDeclareSchemas(c *C) {
+	defer executor.DeclareSchemas(nil, false)
+	schemas := []*utils.BucketSchema{
+		{
+			Symbols:        []string{"DECL1", "DECL2"},
+			Timeframe:      "1Min",
+			AttributeGroup: "OHLCV",
+			Columns:        "Open,Close/float32:Volume/int64",
+		},
+		{
+			Symbols:        []string{"DECL1"},
+			Timeframe:      "1Sec",
+			AttributeGroup: "TRADE",
+			Columns:        "Price/decimal(2):Size/int32",
+			VariableLength: true,
+		},
+	}
+	c.Assert(executor.DeclareSchemas(schemas, true), IsNil)
+	// declared again with the buckets created
+	c.Assert(executor.DeclareSchemas(schemas, true), IsNil)
+
+	resp, err := GRPCService{}.DescribeSymbol(context.Background(), &proto.DescribeSymbolRequest{Symbol: "DECL1"})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Buckets, HasLen, 2)
+	c.Assert(resp.Buckets[0].Key, Equals, "DECL1/1Min/OHLCV")
+	c.Assert(resp.Buckets[0].DataShapes, DeepEquals, []*proto.DataShape{
+		{Name: "Open", Type: proto.DataType_FLOAT32},
+		{Name: "Close", Type: proto.DataType_FLOAT32},
+		{Name: "Volume", Type: proto.DataType_INT64},
+	})
+	c.Assert(resp.Buckets[1].Key, Equals, "DECL1/1Sec/TRADE")
+	c.Assert(resp.Buckets[1].VariableLength, Equals, true)
+	c.Assert(resp.Buckets[1].DataShapes, DeepEquals, []*proto.DataShape{
+		{Name: "Price", Type: proto.DataType_INT64, Scale: 2},
+		{Name: "Size", Type: proto.DataType_INT32},
+	})
+
+	write := func(key string, columns map[string]interface{}) error {
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", []int64{time.Date(2021, 1, 4, 9, 30, 0, 0, time.UTC).Unix()})
+		for _, name := range []string{"Open", "Close", "Volume"} {
+			if col, ok := columns[name]; ok {
+				cs.AddColumn(name, col)
+			}
+		}
+		csm := io.NewColumnSeriesMap()
+		csm.AddColumnSeries(*io.NewTimeBucketKey(key), cs)
+		return executor.WriteCSM(csm, false)
+	}
+	c.Assert(write("DECL2/1Min/OHLCV", map[string]interface{}{
+		"Open": []float32{1}, "Close": []float32{2}, "Volume": []int64{100},
+	}), IsNil)
+	// the schema drifts
+	c.Assert(write("DECL2/1Min/OHLCV", map[string]interface{}{
+		"Open": []float64{1}, "Close": []float64{2},
+	}), NotNil)
+	// not declared
+	c.Assert(write("UNDECL/1Min/OHLCV", map[string]interface{}{
+		"Open": []float32{1}, "Close": []float32{2}, "Volume": []int64{100},
+	}), ErrorMatches, `bucket UNDECL/1Min/OHLCV has no declared schema, which strict_schemas requires`)
+	_, err = GRPCService{}.EnsureBucket(context.Background(), &proto.EnsureBucketRequest{
+		Key:        "UNDECL/1Min/OHLCV",
+		DataShapes: []*proto.DataShape{{Name: "Close", Type: proto.DataType_FLOAT32}},
+	})
+	c.Assert(status.Code(err), Equals, codes.FailedPrecondition)
+
+	// an existing bucket with another schema
+	err = executor.DeclareSchemas([]*utils.BucketSchema{{
+		Symbols:        []string{"DECL2"},
+		Timeframe:      "1Min",
+		AttributeGroup: "OHLCV",
+		Columns:        "Close/float64",
+	}}, false)
+	c.Assert(err, ErrorMatches, `bucket DECL2/1Min/OHLCV has the schema .*, not Close:FLOAT64 FIXED`)
+
+	err = executor.DeclareSchemas([]*utils.BucketSchema{{
+		Symbols:        []string{"DECL3"},
+		Timeframe:      "1Min",
+		AttributeGroup: "OHLCV",
+		Columns:        "Close",
+	}}, false)
+	c.Assert(err, ErrorMatches, `invalid columns "Close" of schema 0: .*`)
+}
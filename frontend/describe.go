@@ -0,0 +1,109 @@
+package frontend
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/glob"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/alpacahq/marketstore/v4/catalog"
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// symbolMatcher returns the function matching the symbols with the glob
+// pattern, all of them if the pattern is empty.
+func symbolMatcher(pattern string) (func(symbol string) bool, error) {
+	if pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+	g, err := glob.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid symbol glob %q: %v", pattern, err)
+	}
+	return g.Match, nil
+}
+
+// DescribeSymbol returns the buckets of a symbol, with their columns and
+// the time bounds of their data.  The schema is read from the catalog, and
+// the bounds from the first and the last record only.
+func (s GRPCService) DescribeSymbol(ctx context.Context, req *proto.DescribeSymbolRequest) (*proto.DescribeSymbolResponse, error) {
+	if atomic.LoadUint32(&Queryable) == 0 {
+		return nil, queryableError
+	}
+	if req.Symbol == "" || strings.Contains(req.Symbol, "/") {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid symbol %q", req.Symbol)
+	}
+
+	var keys []string
+	for _, key := range catalog.ListTimeBucketKeyNames(executor.ThisInstance.CatalogDir) {
+		if strings.HasPrefix(key, req.Symbol+"/") {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, status.Errorf(codes.NotFound, "symbol %s not found", req.Symbol)
+	}
+	sort.Strings(keys)
+
+	response := proto.DescribeSymbolResponse{}
+	for _, key := range keys {
+		tbk := io.NewTimeBucketKey(key)
+		tbi, err := executor.ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(tbk)
+		if err != nil {
+			// removed since listed
+			continue
+		}
+
+		bucket := &proto.BucketDescription{
+			Key:            key,
+			Timeframe:      tbk.GetItemInCategory("Timeframe"),
+			AttributeGroup: tbk.GetItemInCategory("AttributeGroup"),
+			VariableLength: tbi.GetRecordType() == io.VARIABLE,
+		}
+		types := tbi.GetElementTypes()
+		scales := tbi.GetElementScales()
+		for i, name := range tbi.GetElementNames() {
+			bucket.DataShapes = append(bucket.DataShapes, &proto.DataShape{
+				Name: name, Type: toProtoDataType(types[i]), Scale: int32(scales[i]),
+			})
+		}
+		if bucket.EpochStart, err = boundEpoch(ctx, key, true); err != nil {
+			return nil, err
+		}
+		if bucket.EpochEnd, err = boundEpoch(ctx, key, false); err != nil {
+			return nil, err
+		}
+		response.Buckets = append(response.Buckets, bucket)
+	}
+	return &response, nil
+}
+
+// boundEpoch returns the epoch of the first or the last record of the
+// bucket, or 0 if it has none.
+func boundEpoch(ctx context.Context, key string, first bool) (int64, error) {
+	csm, err := executeQuery(ctx, io.NewTimeBucketKey(key),
+		time.Unix(0, 0), time.Unix(math.MaxInt64, 0),
+		1, first, nil,
+	)
+	if err != nil {
+		if err.Error() == errNoFiles {
+			return 0, nil
+		}
+		return 0, err
+	}
+	for _, cs := range csm {
+		if epoch := cs.GetEpoch(); len(epoch) > 0 {
+			return epoch[0], nil
+		}
+	}
+	return 0, nil
+}
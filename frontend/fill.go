@@ -0,0 +1,160 @@
+package frontend
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/contrib/calendar"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// maxFilledRows bounds the rows of a result with the gaps filled, as a range
+// far wider than the records would fill millions of intervals.
+const maxFilledRows = 5000000
+
+// priceColumns are the columns filled with the previous Close in forward fill.
+var priceColumns = []string{"Open", "High", "Low", "Close"}
+
+// fillOptions are the options of filling the gaps of a query result.
+type fillOptions struct {
+	// forward fills the price columns with the previous Close rather than nulls
+	forward bool
+	// zero fills the float columns with zero rather than NaN
+	zero bool
+	// calendar limits the filled intervals to its market hours, or days
+	// for the daily timeframes, if set
+	calendar calendar.MarketCalendar
+}
+
+// fillInterval returns the function stepping through the intervals of the
+// timeframe, which must be of seconds, minutes, hours or days.
+func fillInterval(cd *utils.CandleDuration) (func(t time.Time) time.Time, error) {
+	switch {
+	case cd == nil:
+		return nil, fmt.Errorf("invalid timeframe to fill")
+	case cd.Duration() < utils.Day:
+		return func(t time.Time) time.Time { return t.Add(cd.Duration()) }, nil
+	case strings.HasSuffix(cd.String, "D"):
+		// the days in the local time, which may not be 24 hours long
+		days := int(cd.Duration() / utils.Day)
+		return func(t time.Time) time.Time { return t.AddDate(0, 0, days) }, nil
+	default:
+		return nil, fmt.Errorf("gaps of %s cannot be filled, only of seconds, minutes, hours or days", cd.String)
+	}
+}
+
+// fillGaps inserts the rows of the intervals of the timeframe missing from the
+// time ordered cs within [start, end], or within the records for an open end.
+// The inserted rows have null values, NaN or zero, or the previous Close in
+// the price columns with forward fill.
+func fillGaps(cs *io.ColumnSeries, cd *utils.CandleDuration, start, end time.Time,
+	openStart, openEnd bool, opts fillOptions) (*io.ColumnSeries, error) {
+	next, err := fillInterval(cd)
+	if err != nil {
+		return nil, err
+	}
+	if cs.Exists("Nanoseconds") {
+		return nil, fmt.Errorf("gaps of variable length records cannot be filled")
+	}
+	epochs := cs.GetEpoch()
+	if len(epochs) == 0 && (openStart || openEnd) {
+		return cs, nil
+	}
+	tz := utils.InstanceConfig.Timezone
+	if openStart {
+		start = time.Unix(epochs[0], 0)
+	}
+	if openEnd {
+		end = time.Unix(epochs[len(epochs)-1], 0)
+	}
+	start, end = start.In(tz), end.In(tz)
+	marketHours := func(t time.Time) bool {
+		switch {
+		case opts.calendar == nil:
+			return true
+		case cd.Duration() < utils.Day:
+			return opts.calendar.IsMarketOpen(t.In(opts.calendar.Tz()))
+		default:
+			return opts.calendar.IsMarketDay(t)
+		}
+	}
+
+	// rows holds the index of each output row in cs, or -1 for a filled row
+	var rows []int
+	var outEpochs []int64
+	t := cd.Truncate(start)
+	if t.Before(start) {
+		t = next(t)
+	}
+	i := 0
+	for ; !t.After(end); t = next(t) {
+		for ; i < len(epochs) && epochs[i] <= t.Unix(); i++ {
+			rows = append(rows, i)
+			outEpochs = append(outEpochs, epochs[i])
+		}
+		if (len(outEpochs) == 0 || outEpochs[len(outEpochs)-1] != t.Unix()) && marketHours(t) {
+			rows = append(rows, -1)
+			outEpochs = append(outEpochs, t.Unix())
+		}
+		if len(rows) > maxFilledRows {
+			return nil, fmt.Errorf("more than %d rows with the gaps filled, narrow the range", maxFilledRows)
+		}
+	}
+	for ; i < len(epochs); i++ {
+		rows = append(rows, i)
+		outEpochs = append(outEpochs, epochs[i])
+	}
+
+	out := io.NewColumnSeries()
+	out.AddColumn("Epoch", outEpochs)
+	for _, name := range cs.GetColumnNames() {
+		if name == "Epoch" {
+			continue
+		}
+		out.AddColumn(name, fillColumn(reflect.ValueOf(cs.GetColumn(name)), rows, opts.zero))
+	}
+	if opts.forward && out.Exists("Close") {
+		fillForward(out, rows)
+	}
+	return out, nil
+}
+
+// fillColumn returns the values at rows, with the nulls for the filled rows.
+func fillColumn(values reflect.Value, rows []int, zero bool) interface{} {
+	out := reflect.MakeSlice(values.Type(), len(rows), len(rows))
+	kind := values.Type().Elem().Kind()
+	for j, i := range rows {
+		switch {
+		case i >= 0:
+			out.Index(j).Set(values.Index(i))
+		case !zero && (kind == reflect.Float32 || kind == reflect.Float64):
+			out.Index(j).SetFloat(math.NaN())
+		}
+	}
+	return out.Interface()
+}
+
+// fillForward sets the price columns of the filled rows to the Close before them.
+func fillForward(cs *io.ColumnSeries, rows []int) {
+	closes := reflect.ValueOf(cs.GetColumn("Close"))
+	var columns []reflect.Value
+	for _, name := range priceColumns {
+		if cs.Exists(name) && reflect.TypeOf(cs.GetColumn(name)) == closes.Type() {
+			columns = append(columns, reflect.ValueOf(cs.GetColumn(name)))
+		}
+	}
+	for j, i := range rows {
+		if i >= 0 || j == 0 {
+			continue
+		}
+		// the previous Close is set already if it is filled as well
+		prev := closes.Index(j - 1).Interface()
+		for _, column := range columns {
+			column.Index(j).Set(reflect.ValueOf(prev))
+		}
+	}
+}
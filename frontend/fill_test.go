@@ -0,0 +1,131 @@
+package frontend
+
+import (
+	"context"
+	"math"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/contrib/calendar"
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+func fillTestSeries(times ...time.Time) *io.ColumnSeries {
+	var epoch []int64
+	var open, close []float32
+	var volume []int64
+	for i, t := range times {
+		epoch = append(epoch, t.Unix())
+		open = append(open, float32(i+1))
+		close = append(close, float32(i+1)+0.5)
+		volume = append(volume, int64(i+1)*100)
+	}
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	cs.AddColumn("Open", open)
+	cs.AddColumn("Close", close)
+	cs.AddColumn("Volume", volume)
+	return cs
+}
+
+func (s *ServerTestSuite) TestFillGaps(c *C) {
+	base := time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC)
+	minute := func(n int) time.Time { return base.Add(time.Duration(n) * time.Minute) }
+	cs := fillTestSeries(minute(1), minute(2), minute(5))
+	cd := utils.CandleDurationFromString("1Min")
+
+	// null fill within the range, which is aligned to the timeframe
+	out, err := fillGaps(cs, cd, base.Add(30*time.Second), minute(6), false, false, fillOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(out.GetEpoch(), DeepEquals, []int64{
+		minute(1).Unix(), minute(2).Unix(), minute(3).Unix(), minute(4).Unix(), minute(5).Unix(), minute(6).Unix(),
+	})
+	opens := out.GetColumn("Open").([]float32)
+	c.Assert(opens[1], Equals, float32(2))
+	c.Assert(math.IsNaN(float64(opens[2])), Equals, true)
+	c.Assert(math.IsNaN(float64(opens[5])), Equals, true)
+	c.Assert(out.GetColumn("Volume"), DeepEquals, []int64{100, 200, 0, 0, 300, 0})
+
+	// zero fill within the records for the open ends
+	out, err = fillGaps(cs, cd, time.Time{}, time.Time{}, true, true, fillOptions{zero: true})
+	c.Assert(err, IsNil)
+	c.Assert(out.GetColumn("Open"), DeepEquals, []float32{1, 2, 0, 0, 3})
+
+	// forward fill from the previous Close, with nulls before the first record
+	out, err = fillGaps(cs, cd, minute(0), minute(6), false, false, fillOptions{forward: true})
+	c.Assert(err, IsNil)
+	opens = out.GetColumn("Open").([]float32)
+	c.Assert(math.IsNaN(float64(opens[0])), Equals, true)
+	c.Assert(opens[1:], DeepEquals, []float32{1, 2, 2.5, 2.5, 3, 3.5})
+	c.Assert(out.GetColumn("Close").([]float32)[1:], DeepEquals, []float32{1.5, 2.5, 2.5, 2.5, 3.5, 3.5})
+	c.Assert(out.GetColumn("Volume"), DeepEquals, []int64{0, 100, 200, 0, 0, 300, 0})
+
+	// weekly gaps are not filled
+	_, err = fillGaps(cs, utils.CandleDurationFromString("1W"), minute(0), minute(6), false, false, fillOptions{})
+	c.Assert(err, NotNil)
+}
+
+func (s *ServerTestSuite) TestFillGapsCalendar(c *C) {
+	ny, _ := time.LoadLocation("America/New_York")
+	// the close of a day and the open of the next one
+	cs := fillTestSeries(
+		time.Date(2020, 1, 2, 15, 57, 0, 0, ny),
+		time.Date(2020, 1, 3, 9, 30, 0, 0, ny),
+		time.Date(2020, 1, 3, 9, 32, 0, 0, ny),
+	)
+	cd := utils.CandleDurationFromString("1Min")
+	out, err := fillGaps(cs, cd, time.Time{}, time.Time{}, true, true, fillOptions{calendar: calendar.Nasdaq})
+	c.Assert(err, IsNil)
+	c.Assert(out.GetEpoch(), DeepEquals, []int64{
+		time.Date(2020, 1, 2, 15, 57, 0, 0, ny).Unix(),
+		time.Date(2020, 1, 2, 15, 58, 0, 0, ny).Unix(),
+		time.Date(2020, 1, 2, 15, 59, 0, 0, ny).Unix(),
+		time.Date(2020, 1, 3, 9, 30, 0, 0, ny).Unix(),
+		time.Date(2020, 1, 3, 9, 31, 0, 0, ny).Unix(),
+		time.Date(2020, 1, 3, 9, 32, 0, 0, ny).Unix(),
+	})
+
+	// the market days for the daily timeframe, skipping the weekend
+	cs = fillTestSeries(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC))
+	out, err = fillGaps(cs, utils.CandleDurationFromString("1D"), time.Time{}, time.Time{}, true, true,
+		fillOptions{calendar: calendar.Nasdaq})
+	c.Assert(err, IsNil)
+	c.Assert(out.Len(), Equals, 4)
+}
+
+func (s *ServerTestSuite) TestQueryFillGaps(c *C) {
+	tbk := io.NewTimeBucketKey("FILL/1Min/OHLCV")
+	base := time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC)
+	cs := fillTestSeries(base, base.Add(time.Minute), base.Add(4*time.Minute))
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+	executor.ThisInstance.WALFile.RequestFlush()
+
+	resp, err := GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{
+			Destination: tbk.GetItemKey(),
+			EpochStart:  base.Unix(),
+			EpochEnd:    base.Add(5 * time.Minute).Unix(),
+			FillGaps:    true,
+			FillForward: true,
+		}},
+	})
+	c.Assert(err, IsNil)
+	nmds := ToNumpyMultiDataSet(resp.Responses[0].Result)
+	for key, start := range nmds.StartIndex {
+		out, err := nmds.ToColumnSeries(start, nmds.Lengths[key])
+		c.Assert(err, IsNil)
+		c.Assert(out.Len(), Equals, 6)
+		c.Assert(out.GetColumn("Close"), DeepEquals, []float32{1.5, 2.5, 2.5, 2.5, 3.5, 3.5})
+	}
+
+	_, err = GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{Destination: tbk.GetItemKey(), FillGaps: true, FillCalendar: "nowhere"}},
+	})
+	c.Assert(err, NotNil)
+}
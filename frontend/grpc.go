@@ -4,16 +4,25 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/alpacahq/marketstore/v4/catalog"
+	"github.com/alpacahq/marketstore/v4/contrib/calendar"
 	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/metrics"
 	"github.com/alpacahq/marketstore/v4/proto"
 	"github.com/alpacahq/marketstore/v4/sqlparser"
 	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/pool"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var dataTypeMap = map[proto.DataType]io.EnumElementType{
@@ -52,6 +61,10 @@ func toProtoDataType(elemType io.EnumElementType) proto.DataType {
 type GRPCService struct{}
 
 func (s GRPCService) Query(ctx context.Context, reqs *proto.MultiQueryRequest) (*proto.MultiQueryResponse, error) {
+	timer := prometheus.NewTimer(metrics.QueryDuration.WithLabelValues("GRPCService.Query"))
+	defer timer.ObserveDuration()
+
+	ctx = withResultBudget(ctx)
 	response := proto.MultiQueryResponse{}
 	response.Version = utils.GitHash
 	response.Timezone = utils.InstanceConfig.Timezone.String()
@@ -79,6 +92,7 @@ func (s GRPCService) Query(ctx context.Context, reqs *proto.MultiQueryRequest) (
 			if err != nil {
 				return nil, err
 			}
+			metrics.QueryRows.WithLabelValues("GRPCService.Query", "", "").Add(float64(cs.Len()))
 			response.Responses = append(response.Responses,
 				&proto.QueryResponse{
 					Result: ToProtoNumpyMultiDataSet(nmds),
@@ -101,11 +115,13 @@ func (s GRPCService) Query(ctx context.Context, reqs *proto.MultiQueryRequest) (
 			RecordFormat := dest.GetItemInCategory("AttributeGroup")
 			Timeframe := dest.GetItemInCategory("Timeframe")
 			Symbols := dest.GetMultiItemInCategory("Symbol")
+			// the symbols of a multi-symbol query are given apart from the destination
+			multiSymbol := len(req.Symbols) != 0 || req.SymbolGlob != ""
 
-			if len(Timeframe) == 0 || len(RecordFormat) == 0 || len(Symbols) == 0 {
+			if len(Timeframe) == 0 || len(RecordFormat) == 0 || (len(Symbols) == 0 && !multiSymbol) {
 				return nil, fmt.Errorf("destinations must have a Symbol, Timeframe and AttributeGroup, have: %s",
 					dest.String())
-			} else if len(Symbols) == 1 && Symbols[0] == "*" {
+			} else if len(Symbols) == 1 && Symbols[0] == "*" && !multiSymbol {
 				// replace the * "symbol" with a list all known actual symbols
 				allSymbols := executor.ThisInstance.CatalogDir.GatherCategoriesAndItems()["Symbol"]
 				symbols := make([]string, 0, len(allSymbols))
@@ -117,91 +133,440 @@ func (s GRPCService) Query(ctx context.Context, reqs *proto.MultiQueryRequest) (
 				dest = io.NewTimeBucketKey(itemKey, req.KeyCategory)
 			}
 
-			epochStart := req.EpochStart
-			epochEnd := req.EpochEnd
-			if req.EpochEnd == 0 {
-				epochEnd = int64(math.MaxInt64)
+			if len(req.Timeframes) != 0 {
+				if multiSymbol {
+					return nil, status.Error(codes.InvalidArgument, "timeframes cannot be used with symbols or symbol_glob")
+				}
+				results, err := s.queryTimeframes(ctx, req, dest)
+				if err != nil {
+					return nil, err
+				}
+				response.Responses = append(response.Responses, &proto.QueryResponse{TimeframeResults: results})
+				continue
 			}
-			limitRecordCount := int(req.LimitRecordCount)
-			limitFromStart := req.LimitFromStart
 
-			columns := make([]string, 0)
-			if req.Columns != nil {
-				columns = req.Columns
+			var csm io.ColumnSeriesMap
+			queryResponse := &proto.QueryResponse{}
+			if multiSymbol {
+				symbols, err := requestSymbols(req.Symbols, req.SymbolGlob)
+				if err != nil {
+					return nil, err
+				}
+				csm, queryResponse.Errors = s.querySymbols(ctx, req, symbols, Timeframe, RecordFormat)
+			} else {
+				var err error
+				csm, err = s.queryDestination(ctx, req, dest, Timeframe)
+				if err != nil {
+					return nil, err
+				}
 			}
+			observeQueryRows("GRPCService.Query", csm)
 
-			start := io.ToSystemTimezone(time.Unix(epochStart, req.EpochStartNanos))
-			end := io.ToSystemTimezone(time.Unix(epochEnd, req.EpochEndNanos))
-			csm, err := executeQuery(
-				dest,
-				start, end,
-				limitRecordCount, limitFromStart,
-				columns,
-			)
+			result, err := queryResult(csm, Timeframe)
 			if err != nil {
 				return nil, err
 			}
+			queryResponse.Result = result
+			response.Responses = append(response.Responses, queryResponse)
 
-			/*
-				Execute function pipeline, if requested
-			*/
-			if len(req.Functions) != 0 {
-				for tbkStr, cs := range csm {
-					csOut, err := runAggFunctions(req.Functions, cs)
-					if err != nil {
-						return nil, err
-					}
-					csm[tbkStr] = csOut
-				}
+		}
+	}
+	return &response, nil
+}
+
+// symbolQueryWorkers is the number of the symbols of a multi-symbol query
+// read concurrently.
+var symbolQueryWorkers = runtime.NumCPU()
+
+// requestSymbols returns the sorted symbols of a multi-symbol query, those
+// listed and those in the catalog matching the glob pattern.
+func requestSymbols(listed []string, pattern string) ([]string, error) {
+	set := map[string]bool{}
+	for _, symbol := range listed {
+		set[symbol] = true
+	}
+	if pattern != "" {
+		match, err := symbolMatcher(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for symbol := range executor.ThisInstance.CatalogDir.GatherCategoriesAndItems()["Symbol"] {
+			if match(symbol) {
+				set[symbol] = true
 			}
+		}
+	}
+	symbols := make([]string, 0, len(set))
+	for symbol := range set {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols, nil
+}
 
-			/*
-				Separate each TimeBucket from the result and compose a NumpyMultiDataset
-			*/
-			var nmds *io.NumpyMultiDataset
-			for tbk, cs := range csm {
-				nds, err := io.NewNumpyDataset(cs)
-				if err != nil {
-					return nil, err
+// querySymbols runs the query of req on each of the symbols separately,
+// returning the results of those succeeded and the errors of the others.
+func (s GRPCService) querySymbols(ctx context.Context, req *proto.QueryRequest, symbols []string,
+	Timeframe, RecordFormat string) (io.ColumnSeriesMap, map[string]string) {
+	csm := io.NewColumnSeriesMap()
+	errs := map[string]string{}
+	var mu sync.Mutex
+
+	p := pool.NewPool(symbolQueryWorkers, func(input interface{}) {
+		symbol := input.(string)
+		dest := io.NewTimeBucketKey(strings.Join([]string{symbol, Timeframe, RecordFormat}, "/"), req.KeyCategory)
+		result, err := s.queryDestination(ctx, req, dest, Timeframe)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			errs[symbol] = status.Convert(err).Message()
+			return
+		}
+		for tbk, cs := range result {
+			csm[tbk] = cs
+		}
+	})
+	c := make(chan interface{})
+	go func() {
+		for _, symbol := range symbols {
+			c <- symbol
+		}
+		close(c)
+	}()
+	p.Work(c)
+	p.Wait()
+	return csm, errs
+}
+
+// queryTimeframes runs the query of req on dest in each of the timeframes of
+// req concurrently, with the commits frozen so that the results reflect the
+// same writes, and returns the results by timeframe, without those of the
+// timeframes that have no files.
+func (s GRPCService) queryTimeframes(ctx context.Context, req *proto.QueryRequest,
+	dest *io.TimeBucketKey) (map[string]*proto.NumpyMultiDataset, error) {
+	timeframes := map[string]bool{}
+	for _, tf := range req.Timeframes {
+		if utils.CandleDurationFromString(tf) == nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid timeframe %q", tf)
+		}
+		timeframes[tf] = true
+	}
+
+	var (
+		mu       sync.Mutex
+		results  = map[string]*proto.NumpyMultiDataset{}
+		firstErr error
+	)
+	err := executor.ThisInstance.ReadConsistent(ctx, func() error {
+		// the commits wait for the reads, which stop once the query is
+		// canceled or one of them fails
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var wg sync.WaitGroup
+		for tf := range timeframes {
+			wg.Add(1)
+			go func(tf string) {
+				defer wg.Done()
+				key := io.NewTimeBucketKey(dest.GetItemKey(), dest.GetCatKey())
+				key.SetItemInCategory("Timeframe", tf)
+				csm, err := s.queryDestination(ctx, req, key, tf)
+				if err != nil && err.Error() == errNoFiles {
+					// the timeframe has no results
+					return
 				}
-				if nmds == nil {
-					nmds, err = io.NewNumpyMultiDataset(nds, tbk)
-					if err != nil {
-						return nil, err
+				var result *proto.NumpyMultiDataset
+				if err == nil {
+					observeQueryRows("GRPCService.Query", csm)
+					result, err = queryResult(csm, tf)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+						cancel()
 					}
-				} else {
-					nmds.Append(cs, tbk)
+					return
 				}
+				if result != nil {
+					results[tf] = result
+				}
+			}(tf)
+		}
+		wg.Wait()
+		return firstErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// checkColumns returns an InvalidArgument error if a column projected is
+// not in the bucket of a symbol of dest.  The buckets not found are not
+// checked, as they are queried to no results.
+func checkColumns(dest *io.TimeBucketKey, Timeframe string, columns []string) error {
+	queryableTimeframe := utils.CandleDurationFromString(Timeframe).QueryableTimeframe()
+	for _, symbol := range dest.GetMultiItemInCategory("Symbol") {
+		key := io.NewTimeBucketKey(dest.GetItemKey(), dest.GetCatKey())
+		key.SetItemInCategory("Symbol", symbol)
+		key.SetItemInCategory("Timeframe", queryableTimeframe)
+		tbi, err := executor.ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(key)
+		if err != nil {
+			continue
+		}
+		names := map[string]bool{"Epoch": true}
+		for _, name := range tbi.GetElementNames() {
+			names[name] = true
+		}
+		if tbi.GetRecordType() == io.VARIABLE {
+			names["Nanoseconds"] = true
+		}
+		for _, name := range columns {
+			if !names[name] {
+				return status.Errorf(codes.InvalidArgument, "column %s is not in %s", name, key.GetItemKey())
 			}
+		}
+	}
+	return nil
+}
 
-			/*
-				Append the NumpyMultiDataset to the MultiResponse
-			*/
+// queryDestination runs the query of req on dest, with the resample, gap
+// filling and functions requested.
+func (s GRPCService) queryDestination(ctx context.Context, req *proto.QueryRequest, dest *io.TimeBucketKey,
+	Timeframe string) (io.ColumnSeriesMap, error) {
+	epochStart := req.EpochStart
+	epochEnd := req.EpochEnd
+	if req.EpochEnd == 0 {
+		epochEnd = int64(math.MaxInt64)
+	}
+	limitRecordCount := int(req.LimitRecordCount)
+	limitFromStart := req.LimitFromStart
 
-			response.Responses = append(response.Responses,
-				&proto.QueryResponse{
-					Result: ToProtoNumpyMultiDataSet(nmds),
-				})
+	columns := make([]string, 0)
+	if req.Columns != nil {
+		if err := checkColumns(dest, Timeframe, req.Columns); err != nil {
+			return nil, err
+		}
+		columns = req.Columns
+	}
 
+	start := io.ToSystemTimezone(time.Unix(epochStart, req.EpochStartNanos))
+	end := io.ToSystemTimezone(time.Unix(epochEnd, req.EpochEndNanos))
+	openStart, openEnd := req.EpochStart == 0, req.EpochEnd == 0
+
+	/*
+		Resolve the range of the last sessions, if requested
+	*/
+	if req.LastSessions != 0 {
+		var err error
+		if start, end, err = lastSessionsRange(req, end); err != nil {
+			return nil, err
 		}
+		openStart, openEnd = false, false
 	}
-	return &response, nil
+
+	/*
+		Widen the range to whole candles for the resample, if requested
+	*/
+	var window *utils.CandleDuration
+	if req.Resample != "" {
+		if limitRecordCount != 0 {
+			return nil, fmt.Errorf("limit_record_count cannot be used with resample")
+		}
+		if err := validateReducers(req.ResampleReducers); err != nil {
+			return nil, err
+		}
+		var err error
+		if window, err = resampleWindow(req.Resample, Timeframe); err != nil {
+			return nil, err
+		}
+		start, end = resampleRange(window, start, end, openStart, openEnd)
+	}
+
+	csm, err := executeQuery(
+		ctx, dest,
+		start, end,
+		limitRecordCount, limitFromStart,
+		columns,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var fill *fillOptions
+	if req.FillGaps {
+		fill = &fillOptions{forward: req.FillForward, zero: req.FillZero}
+		if req.FillCalendar != "" {
+			if fill.calendar, err = calendar.Get(req.FillCalendar); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if window != nil {
+		resampled := io.NewColumnSeriesMap()
+		for tbk, cs := range csm {
+			csOut, err := resample(cs, window, req.ResampleReducers)
+			if err != nil {
+				return nil, err
+			}
+			key := io.NewTimeBucketKey(tbk.GetItemKey(), tbk.GetCatKey())
+			key.SetItemInCategory("Timeframe", window.String)
+			resampled[*key] = csOut
+		}
+		csm = resampled
+	}
+
+	/*
+		Fill the gaps in the timeframe, if requested
+	*/
+	if fill != nil {
+		cd := window
+		if cd == nil {
+			cd = utils.CandleDurationFromString(Timeframe)
+		}
+		for tbk, cs := range csm {
+			csOut, err := fillGaps(cs, cd, start, end, openStart, openEnd, *fill)
+			if err != nil {
+				return nil, err
+			}
+			csm[tbk] = csOut
+		}
+	}
+
+	/*
+		Execute function pipeline, if requested
+	*/
+	if len(req.Functions) != 0 {
+		for tbkStr, cs := range csm {
+			csOut, err := runAggFunctions(req.Functions, cs)
+			if err != nil {
+				return nil, err
+			}
+			csm[tbkStr] = csOut
+		}
+	}
+	return csm, nil
+}
+
+// queryResult separates each TimeBucket from the result and composes
+// a NumpyMultiDataset, nil if the result is empty.
+func queryResult(csm io.ColumnSeriesMap, timeframe string) (*proto.NumpyMultiDataset, error) {
+	var nmds *io.NumpyMultiDataset
+	for tbk, cs := range csm {
+		nds, err := io.NewNumpyDataset(cs)
+		if err != nil {
+			return nil, err
+		}
+		if nmds == nil {
+			nmds, err = io.NewNumpyMultiDataset(nds, tbk)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			nmds.Append(cs, tbk)
+		}
+	}
+	if nmds == nil {
+		return nil, nil
+	}
+
+	result := ToProtoNumpyMultiDataSet(nmds)
+	shapes, err := scaledDataShapes(csm, nmds, timeframe)
+	if err != nil {
+		return nil, err
+	}
+	result.Data.DataShapes = shapes
+	return result, nil
+}
+
+// scaledDataShapes returns the data shapes of the result with the scales of
+// the scaled integer columns of the buckets queried in the timeframe, or nil
+// if none of them is scaled.
+func scaledDataShapes(csm io.ColumnSeriesMap, nmds *io.NumpyMultiDataset, timeframe string) ([]*proto.DataShape, error) {
+	scales := map[string]int8{}
+	var scaled bool
+	for tbk := range csm {
+		// the key is of the resample timeframe after resampling
+		key := io.NewTimeBucketKey(tbk.GetItemKey(), tbk.GetCatKey())
+		key.SetItemInCategory("Timeframe", timeframe)
+		tbi, err := executor.ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(key)
+		if err != nil {
+			continue
+		}
+		for i, name := range tbi.GetElementNames() {
+			scale := tbi.GetElementScales()[i]
+			if prev, ok := scales[name]; ok && prev != scale {
+				return nil, fmt.Errorf("column %s has different scales in the buckets queried", name)
+			}
+			scales[name] = scale
+			scaled = scaled || scale != 0
+		}
+	}
+	if !scaled {
+		return nil, nil
+	}
+	dsv := make([]*proto.DataShape, len(nmds.ColumnNames))
+	for i, name := range nmds.ColumnNames {
+		elemType, _ := io.TypeStrToElemType(nmds.ColumnTypes[i])
+		dsv[i] = &proto.DataShape{Name: name, Type: toProtoDataType(elemType), Scale: int32(scales[name])}
+	}
+	return dsv, nil
 }
 
 func (s GRPCService) Write(ctx context.Context, reqs *proto.MultiWriteRequest) (*proto.MultiServerResponse, error) {
-	response := proto.MultiServerResponse{}
-	for _, req := range reqs.Requests {
+	timer := prometheus.NewTimer(metrics.WriteDuration.WithLabelValues("GRPCService.Write"))
+	defer timer.ObserveDuration()
+
+	// the requests are all validated before any is written, so that an
+	// invalid one rejects the whole batch
+	csms := make([]io.ColumnSeriesMap, len(reqs.Requests))
+	late := make([]error, len(reqs.Requests))
+	for i, req := range reqs.Requests {
 		csm, err := ToNumpyMultiDataSet(req.Data).ToColumnSeriesMap()
+		if err == nil {
+			err = validateWrite(csm, req.IsVariableLength, utils.InstanceConfig.StrictWrites,
+				utils.InstanceConfig.LateDataWindow)
+			if isLateRecords(err) {
+				late[i], err = err, nil
+			}
+		}
 		if err != nil {
-			appendResponse(&response, err)
-			continue
+			return nil, status.Errorf(codes.InvalidArgument, "write request %d: %v", i, err)
 		}
-		if err = executor.WriteCSM(csm, req.IsVariableLength); err != nil {
-			appendResponse(&response, err)
-			continue
+		csms[i] = csm
+	}
+
+	response := proto.MultiServerResponse{}
+	_, err := writeOnce(ctx, "GRPCService.Write", reqs.IdempotencyKey, len(reqs.Requests), func(written []bool) {
+		for i, req := range reqs.Requests {
+			if written[i] {
+				// by an earlier request with the key
+				continue
+			}
+			csm := csms[i]
+			if err := executor.WriteCSM(csm, req.IsVariableLength); err != nil {
+				appendResponse(&response, err)
+				continue
+			}
+			written[i] = true
+			observeWriteBytes("GRPCService.Write", csm)
+			if late[i] != nil {
+				// the records dropped from the written ones
+				appendResponse(&response, late[i])
+			}
+			//TODO: There should be an error response for every server request, need to add the below commented line
+			//appendResponse(err, response)
 		}
-		//TODO: There should be an error response for every server request, need to add the below commented line
-		//appendResponse(err, response)
+	})
+	if err != nil {
+		return nil, status.FromContextError(err).Err()
 	}
 	return &response, nil
 }
@@ -278,15 +643,26 @@ func (s GRPCService) ListSymbols(ctx context.Context, req *proto.ListSymbolsRequ
 		return nil, queryableError
 	}
 
+	match, err := symbolMatcher(req.Pattern)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
 	switch req.Format {
 	case proto.ListSymbolsRequest_SYMBOL:
 		for symbol := range executor.ThisInstance.CatalogDir.GatherCategoriesAndItems()["Symbol"] {
-			response.Results = append(response.Results, symbol)
+			if match(symbol) {
+				response.Results = append(response.Results, symbol)
+			}
 		}
 	case proto.ListSymbolsRequest_TIME_BUCKET_KEY:
 		fallthrough
 	default:
-		response.Results = catalog.ListTimeBucketKeyNames(executor.ThisInstance.CatalogDir)
+		for _, key := range catalog.ListTimeBucketKeyNames(executor.ThisInstance.CatalogDir) {
+			if match(strings.SplitN(key, "/", 2)[0]) {
+				response.Results = append(response.Results, key)
+			}
+		}
 	}
 
 	return &response, nil
@@ -312,6 +688,8 @@ func (s GRPCService) Destroy(ctx context.Context, req *proto.MultiKeyRequest) (*
 		}
 
 		err := executor.ThisInstance.CatalogDir.RemoveTimeBucket(tbk)
+		executor.ForgetBucketTail(tbk)
+		clearQueryCache()
 		if err != nil {
 			err = fmt.Errorf("removal of catalog entry failed: %s", err.Error())
 			appendResponse(&response, err)
@@ -323,8 +701,40 @@ func (s GRPCService) Destroy(ctx context.Context, req *proto.MultiKeyRequest) (*
 	return &response, nil
 }
 
+// Delete deletes the records of each destination in its range, for which
+// it returns the number of records deleted.  The range out of the data of
+// the destination deletes nothing.
+func (s GRPCService) Delete(ctx context.Context, reqs *proto.MultiDeleteRequest) (*proto.MultiDeleteResponse, error) {
+	response := proto.MultiDeleteResponse{}
+	for _, req := range reqs.Requests {
+		tbk := io.NewTimeBucketKey(req.Destination)
+		if tbk == nil {
+			response.Responses = append(response.Responses, &proto.DeleteResponse{
+				Error: fmt.Sprintf("destination \"%s\" is not in proper format, should be like: TSLA/1Min/OHLCV", req.Destination),
+			})
+			continue
+		}
+		deleted, err := executor.ThisInstance.DeleteRange(ctx, tbk,
+			time.Unix(req.EpochStart, 0), time.Unix(req.EpochEnd, 0))
+		invalidateQueryCache(tbk.GetItemKey())
+		resp := &proto.DeleteResponse{Deleted: int64(deleted)}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		response.Responses = append(response.Responses, resp)
+	}
+	return &response, nil
+}
+
+// ServerVersion returns the version info of the build of the server, whose
+// version is the git commit, as before the other fields were added.
 func (s GRPCService) ServerVersion(ctx context.Context, req *proto.ServerVersionRequest) (*proto.ServerVersionResponse, error) {
+	info := utils.GetVersionInfo()
 	return &proto.ServerVersionResponse{
-		Version: utils.GitHash,
+		Version:   info.GitHash,
+		Tag:       info.Version,
+		GitHash:   info.GitHash,
+		BuildTime: info.BuildTime,
+		GoVersion: info.GoVersion,
 	}, nil
 }
@@ -0,0 +1,468 @@
+package frontend
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+func (s *ServerTestSuite) TestQueryMultiSymbol(c *C) {
+	service := GRPCService{}
+	resp, err := service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{
+			Destination:      "*/1Min/OHLC",
+			Symbols:          []string{"USDJPY", "NOSUCHSYMBOL"},
+			SymbolGlob:       "*USD",
+			LimitRecordCount: 10,
+		}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Responses, HasLen, 1)
+
+	// the results by symbol, with the error of the missing one
+	result := resp.Responses[0]
+	var keys []string
+	for key, length := range result.Result.Lengths {
+		keys = append(keys, key)
+		c.Assert(length, Equals, int32(10))
+	}
+	sort.Strings(keys)
+	c.Assert(keys, DeepEquals, []string{
+		"EURUSD/1Min/OHLC:Symbol/Timeframe/AttributeGroup",
+		"NZDUSD/1Min/OHLC:Symbol/Timeframe/AttributeGroup",
+		"USDJPY/1Min/OHLC:Symbol/Timeframe/AttributeGroup",
+	})
+	c.Assert(result.Errors, HasLen, 1)
+	c.Assert(result.Errors["NOSUCHSYMBOL"], Not(Equals), "")
+
+	// all failed
+	resp, err = service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{Destination: "*/1Min/OHLC", Symbols: []string{"NOSUCHSYMBOL"}}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Responses[0].Result, IsNil)
+	c.Assert(resp.Responses[0].Errors, HasLen, 1)
+
+	_, err = service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{Destination: "*/1Min/OHLC", SymbolGlob: "[USD"}},
+	})
+	c.Assert(err, NotNil)
+}
+
+func (s *ServerTestSuite) TestQueryMultiTimeframe(c *C) {
+	service := GRPCService{}
+	day := time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	daily := io.NewColumnSeries()
+	daily.AddColumn("Epoch", []int64{day.Unix(), day.AddDate(0, 0, 1).Unix()})
+	daily.AddColumn("Open", []float32{1, 2})
+	daily.AddColumn("High", []float32{1, 2})
+	daily.AddColumn("Low", []float32{1, 2})
+	daily.AddColumn("Close", []float32{1, 2})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*io.NewTimeBucketKey("USDJPY/1D/OHLC"), daily)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+
+	resp, err := service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{
+			Destination:      "USDJPY/1Min/OHLC",
+			Timeframes:       []string{"1Min", "1D", "1H"},
+			LimitRecordCount: 5,
+		}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Responses, HasLen, 1)
+
+	// the results by timeframe, the 1H bars aggregated from the 1Min ones
+	results := resp.Responses[0].TimeframeResults
+	c.Assert(results, HasLen, 3)
+	c.Assert(resp.Responses[0].Result, IsNil)
+	c.Assert(results["1Min"].Lengths, DeepEquals, map[string]int32{"USDJPY/1Min/OHLC:Symbol/Timeframe/AttributeGroup": 5})
+	c.Assert(results["1H"].Lengths, DeepEquals, map[string]int32{"USDJPY/1H/OHLC:Symbol/Timeframe/AttributeGroup": 5})
+	out, err := ToNumpyMultiDataSet(results["1D"]).ToColumnSeriesMap()
+	c.Assert(err, IsNil)
+	// the latest daily bars are those written
+	cs := out[*io.NewTimeBucketKey("USDJPY/1D/OHLC")]
+	c.Assert(cs.Len(), Equals, 5)
+	c.Assert(cs.GetEpoch()[3:], DeepEquals, []int64{day.Unix(), day.AddDate(0, 0, 1).Unix()})
+	c.Assert(cs.GetColumn("Close").([]float32)[3:], DeepEquals, []float32{1, 2})
+
+	// a timeframe without files has no results
+	resp, err = service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{
+			Destination:      "USDJPY/1Min/OHLC",
+			Timeframes:       []string{"1Min", "5Sec"},
+			LimitRecordCount: 5,
+		}},
+	})
+	c.Assert(err, IsNil)
+	results = resp.Responses[0].TimeframeResults
+	c.Assert(results, HasLen, 1)
+	c.Assert(results["1Min"], NotNil)
+
+	// nothing is read once the query is canceled
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = service.Query(canceled, &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{Destination: "USDJPY/1Min/OHLC", Timeframes: []string{"1Min", "1D"}}},
+	})
+	c.Assert(err, Equals, context.Canceled)
+
+	for _, req := range []*proto.QueryRequest{
+		{Destination: "USDJPY/1Min/OHLC", Timeframes: []string{"1Min", "daily"}},
+		{Destination: "*/1Min/OHLC", Symbols: []string{"USDJPY"}, Timeframes: []string{"1D"}},
+	} {
+		_, err = service.Query(context.Background(), &proto.MultiQueryRequest{Requests: []*proto.QueryRequest{req}})
+		c.Assert(status.Code(err), Equals, codes.InvalidArgument)
+	}
+}
+
+func (s *ServerTestSuite) TestQueryScaledColumns(c *C) {
+	createResp := &MultiServerResponse{}
+	err := (&DataService{}).Create(nil, &MultiCreateRequest{Requests: []CreateRequest{{
+		Key:        "SCALED/1D/PRICE:Symbol/Timeframe/AttributeGroup",
+		DataShapes: "Price/decimal(4):Size/float32",
+		RowType:    "fixed",
+	}}}, createResp)
+	c.Assert(err, IsNil)
+	c.Assert(createResp.Responses[0].Error, Equals, "")
+
+	// the prices are written as floats and read as the scaled integers
+	tbk := io.NewTimeBucketKey("SCALED/1D/PRICE")
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix()})
+	cs.AddColumn("Price", []float64{123.4567})
+	cs.AddColumn("Size", []float32{10})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+	executor.ThisInstance.WALFile.RequestFlush()
+
+	resp, err := GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{Destination: tbk.GetItemKey()}},
+	})
+	c.Assert(err, IsNil)
+	result := resp.Responses[0].Result
+	c.Assert(result.Data.DataShapes, DeepEquals, []*proto.DataShape{
+		{Name: "Epoch", Type: proto.DataType_INT64},
+		{Name: "Price", Type: proto.DataType_INT64, Scale: 4},
+		{Name: "Size", Type: proto.DataType_FLOAT32},
+	})
+	nmds := ToNumpyMultiDataSet(result)
+	for key, start := range nmds.StartIndex {
+		out, err := nmds.ToColumnSeries(start, nmds.Lengths[key])
+		c.Assert(err, IsNil)
+		c.Assert(out.GetColumn("Price"), DeepEquals, []int64{1234567})
+	}
+
+	// the scale only applies to int64 columns
+	err = (&DataService{}).Create(nil, &MultiCreateRequest{Requests: []CreateRequest{{
+		Key:        "SCALED/1D/BAD:Symbol/Timeframe/AttributeGroup",
+		DataShapes: "Price/decimal(19)",
+		RowType:    "fixed",
+	}}}, createResp)
+	c.Assert(err, IsNil)
+	c.Assert(createResp.Responses[1].Error, Not(Equals), "")
+}
+
+func (s *ServerTestSuite) TestDelete(c *C) {
+	tbk := io.NewTimeBucketKey("DELETE/1D/PRICE")
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{
+		time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix(),
+		time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC).Unix(),
+	})
+	cs.AddColumn("Price", []float64{1, 2})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+
+	resp, err := GRPCService{}.Delete(context.Background(), &proto.MultiDeleteRequest{
+		Requests: []*proto.DeleteRequest{
+			{
+				Destination: "DELETE/1D/PRICE",
+				EpochStart:  time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix(),
+				EpochEnd:    time.Date(2020, 1, 2, 23, 59, 59, 0, time.UTC).Unix(),
+			},
+			{Destination: "DELETE/1D/NONE"},
+		},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Responses, HasLen, 2)
+	c.Assert(resp.Responses[0].Error, Equals, "")
+	c.Assert(resp.Responses[0].Deleted, Equals, int64(1))
+	c.Assert(resp.Responses[1].Error, Equals, "bucket DELETE/1D/NONE not found")
+
+	qresp, err := GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{Destination: tbk.GetItemKey()}},
+	})
+	c.Assert(err, IsNil)
+	out, err := ToNumpyMultiDataSet(qresp.Responses[0].Result).ToColumnSeriesMap()
+	c.Assert(err, IsNil)
+	c.Assert(out[*tbk].GetColumn("Price"), DeepEquals, []float64{2})
+}
+
+func (s *ServerTestSuite) TestDescribeSymbol(c *C) {
+	tbk := io.NewTimeBucketKey("DESCRIBE/1D/PRICE")
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{
+		time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix(),
+		time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC).Unix(),
+	})
+	cs.AddColumn("Price", []float64{1, 2})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+
+	list, err := GRPCService{}.ListSymbols(context.Background(), &proto.ListSymbolsRequest{
+		Format:  proto.ListSymbolsRequest_TIME_BUCKET_KEY,
+		Pattern: "DESC*",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(list.Results, DeepEquals, []string{"DESCRIBE/1D/PRICE"})
+
+	_, err = GRPCService{}.ListSymbols(context.Background(), &proto.ListSymbolsRequest{Pattern: "["})
+	c.Assert(status.Code(err), Equals, codes.InvalidArgument)
+
+	resp, err := GRPCService{}.DescribeSymbol(context.Background(), &proto.DescribeSymbolRequest{Symbol: "DESCRIBE"})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Buckets, HasLen, 1)
+	bucket := resp.Buckets[0]
+	c.Assert(bucket.Key, Equals, "DESCRIBE/1D/PRICE")
+	c.Assert(bucket.Timeframe, Equals, "1D")
+	c.Assert(bucket.AttributeGroup, Equals, "PRICE")
+	c.Assert(bucket.VariableLength, Equals, false)
+	c.Assert(bucket.DataShapes, DeepEquals, []*proto.DataShape{{Name: "Price", Type: proto.DataType_FLOAT64}})
+	c.Assert(bucket.EpochStart, Equals, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Unix())
+	c.Assert(bucket.EpochEnd, Equals, time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC).Unix())
+
+	_, err = GRPCService{}.DescribeSymbol(context.Background(), &proto.DescribeSymbolRequest{Symbol: "NONE"})
+	c.Assert(status.Code(err), Equals, codes.NotFound)
+}
+
+func (s *ServerTestSuite) TestQuerySymbolAlias(c *C) {
+	day := func(d int) int64 { return time.Date(2021, 10, d, 0, 0, 0, 0, time.UTC).Unix() }
+	write := func(key string, epoch []int64, price []float64) {
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", epoch)
+		cs.AddColumn("Price", price)
+		csm := io.NewColumnSeriesMap()
+		csm.AddColumnSeries(*io.NewTimeBucketKey(key), cs)
+		c.Assert(executor.WriteCSM(csm, false), IsNil)
+	}
+	write("OLDSYM/1D/PRICE", []int64{day(1), day(4), day(5)}, []float64{1, 4, 5})
+	write("NEWSYM/1D/PRICE", []int64{day(5), day(6)}, []float64{50, 60})
+
+	catalogDir := executor.ThisInstance.CatalogDir
+	c.Assert(catalogDir.SetSymbolAliases(map[string]string{"NEWSYM": "OLDSYM"}), IsNil)
+	defer catalogDir.SetSymbolAliases(nil)
+
+	query := func(req *proto.QueryRequest) *io.ColumnSeries {
+		resp, err := GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+			Requests: []*proto.QueryRequest{req},
+		})
+		c.Assert(err, IsNil)
+		csm, err := ToNumpyMultiDataSet(resp.Responses[0].Result).ToColumnSeriesMap()
+		c.Assert(err, IsNil)
+		cs := csm[*io.NewTimeBucketKey(req.Destination)]
+		c.Assert(cs, NotNil)
+		return cs
+	}
+
+	// the new symbol overrides the old one at the same timestamp
+	cs := query(&proto.QueryRequest{Destination: "NEWSYM/1D/PRICE"})
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{day(1), day(4), day(5), day(6)})
+	c.Assert(cs.GetColumn("Price"), DeepEquals, []float64{1, 4, 50, 60})
+
+	cs = query(&proto.QueryRequest{Destination: "NEWSYM/1D/PRICE", LimitRecordCount: 3})
+	c.Assert(cs.GetColumn("Price"), DeepEquals, []float64{4, 50, 60})
+
+	cs = query(&proto.QueryRequest{Destination: "OLDSYM/1D/PRICE"})
+	c.Assert(cs.GetColumn("Price"), DeepEquals, []float64{1, 4, 5})
+}
+
+func (s *ServerTestSuite) TestQueryColumns(c *C) {
+	service := GRPCService{}
+	resp, err := service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{
+			Destination:      "USDJPY/1Min/OHLC",
+			Columns:          []string{"Close", "Open"},
+			LimitRecordCount: 5,
+		}},
+	})
+	c.Assert(err, IsNil)
+	data := resp.Responses[0].Result.Data
+	c.Assert(data.ColumnNames, DeepEquals, []string{"Epoch", "Close", "Open"})
+	c.Assert(data.ColumnTypes, DeepEquals, []string{"i8", "f4", "f4"})
+
+	// with multiple symbols
+	resp, err = service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{
+			Destination:      "*/1Min/OHLC",
+			Symbols:          []string{"USDJPY", "EURUSD"},
+			Columns:          []string{"High"},
+			LimitRecordCount: 5,
+		}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Responses[0].Errors, HasLen, 0)
+	c.Assert(resp.Responses[0].Result.Data.ColumnNames, DeepEquals, []string{"Epoch", "High"})
+
+	_, err = service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{Destination: "USDJPY/1Min/OHLC", Columns: []string{"Close", "Bid"}}},
+	})
+	c.Assert(status.Code(err), Equals, codes.InvalidArgument)
+	c.Assert(status.Convert(err).Message(), Equals, "column Bid is not in USDJPY/1Min/OHLC")
+}
+
+func (s *ServerTestSuite) TestQueryLatest(c *C) {
+	query := func(dest string) *io.ColumnSeries {
+		resp, err := GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+			Requests: []*proto.QueryRequest{{Destination: dest, LimitRecordCount: 1}},
+		})
+		c.Assert(err, IsNil)
+		csm, err := ToNumpyMultiDataSet(resp.Responses[0].Result).ToColumnSeriesMap()
+		c.Assert(err, IsNil)
+		return csm[*io.NewTimeBucketKey(dest)]
+	}
+
+	cs := query("USDJPY/1Min/OHLC")
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{time.Date(2002, 12, 31, 23, 59, 0, 0, time.UTC).Unix()})
+
+	// the last trade of the ticks
+	tbk := io.NewTimeBucketKey("LATEST/1Sec/TICK")
+	t0 := time.Date(2021, 6, 1, 9, 30, 0, 0, time.UTC).Unix()
+	ticks := io.NewColumnSeries()
+	ticks.AddColumn("Epoch", []int64{t0, t0, t0 + 1, t0 + 1})
+	ticks.AddColumn("Nanoseconds", []int32{100, 200, 300, 400})
+	ticks.AddColumn("Price", []float32{1, 2, 3, 4})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, ticks)
+	c.Assert(executor.WriteCSM(csm, true), IsNil)
+
+	cs = query(tbk.GetItemKey())
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{t0 + 1})
+	c.Assert(cs.GetColumn("Nanoseconds"), DeepEquals, []int32{400})
+	c.Assert(cs.GetColumn("Price"), DeepEquals, []float32{4})
+}
+
+func (s *ServerTestSuite) TestQueryResultSize(c *C) {
+	defer func(max int64) { utils.InstanceConfig.MaxQueryResultBytes = max }(utils.InstanceConfig.MaxQueryResultBytes)
+	utils.InstanceConfig.MaxQueryResultBytes = 1 << 20
+
+	// a year of minutes is estimated over the limit before reading it
+	service := GRPCService{}
+	_, err := service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{Destination: "USDJPY/1Min/OHLC"}},
+	})
+	c.Assert(status.Code(err), Equals, codes.ResourceExhausted)
+	c.Assert(status.Convert(err).Message(), Matches,
+		`the result of USDJPY/1Min/OHLC is estimated at \d+ bytes, over the limit of 1048576 bytes, .*`)
+
+	// but not a limited number of records
+	resp, err := service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{Destination: "USDJPY/1Min/OHLC", LimitRecordCount: 10}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Responses[0].Result.Lengths["USDJPY/1Min/OHLC:Symbol/Timeframe/AttributeGroup"], Equals, int32(10))
+
+	// nor a narrow time range
+	end := time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{
+			Destination: "USDJPY/1Min/OHLC",
+			EpochStart:  end.Add(-time.Hour).Unix(),
+			EpochEnd:    end.Unix(),
+		}},
+	})
+	c.Assert(err, IsNil)
+
+	// the symbols of a request are limited as a whole, each of their 10000
+	// minutes of 24 bytes fitting alone
+	utils.InstanceConfig.MaxQueryResultBytes = 300000
+	resp, err = service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{
+			Destination:      "*/1Min/OHLC",
+			Symbols:          []string{"USDJPY", "EURUSD"},
+			LimitRecordCount: 10000,
+		}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(resp.Responses[0].Result.Lengths, HasLen, 1)
+	c.Assert(resp.Responses[0].Errors, HasLen, 1)
+	for _, msg := range resp.Responses[0].Errors {
+		c.Assert(msg, Matches, `the results of the request are estimated at 480000 bytes with .*, over the limit of 300000 bytes, .*`)
+	}
+}
+
+func (s *ServerTestSuite) TestStringColumns(c *C) {
+	write := func(dest string, cs *io.ColumnSeries, isVariableLength bool) {
+		nds, err := io.NewNumpyDataset(cs)
+		c.Assert(err, IsNil)
+		nmds, err := io.NewNumpyMultiDataset(nds, *io.NewTimeBucketKey(dest))
+		c.Assert(err, IsNil)
+		resp, err := GRPCService{}.Write(context.Background(), &proto.MultiWriteRequest{
+			Requests: []*proto.WriteRequest{{Data: ToProtoNumpyMultiDataSet(nmds), IsVariableLength: isVariableLength}},
+		})
+		c.Assert(err, IsNil)
+		c.Assert(resp.Responses, HasLen, 0)
+	}
+	query := func(dest string) (*io.ColumnSeries, []string) {
+		resp, err := GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+			Requests: []*proto.QueryRequest{{Destination: dest}},
+		})
+		c.Assert(err, IsNil)
+		csm, err := ToNumpyMultiDataSet(resp.Responses[0].Result).ToColumnSeriesMap()
+		c.Assert(err, IsNil)
+		return csm[*io.NewTimeBucketKey(dest)], resp.Responses[0].Result.Data.ColumnTypes
+	}
+	day := func(d int) int64 { return time.Date(2021, 3, d, 0, 0, 0, 0, time.UTC).Unix() }
+
+	// fixed length records
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{day(1), day(2), day(3)})
+	cs.AddColumn("Venue", []string{"XNAS", "", "XNYS"})
+	cs.AddColumn("Close", []float32{1, 2, 3})
+	write("STRINGS/1D/REF", cs, false)
+	// overwritten with another string
+	cs = io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{day(2)})
+	cs.AddColumn("Venue", []string{"ARCX"})
+	cs.AddColumn("Close", []float32{4})
+	write("STRINGS/1D/REF", cs, false)
+
+	out, types := query("STRINGS/1D/REF")
+	c.Assert(out.GetEpoch(), DeepEquals, []int64{day(1), day(2), day(3)})
+	c.Assert(out.GetColumn("Venue"), DeepEquals, []string{"XNAS", "ARCX", "XNYS"})
+	c.Assert(out.GetColumn("Close"), DeepEquals, []float32{1, 4, 3})
+	// as numpy unicode strings of the longest one
+	c.Assert(types[1], Equals, "U4")
+
+	// variable length records
+	ticks := io.NewColumnSeries()
+	ticks.AddColumn("Epoch", []int64{day(1), day(1)})
+	ticks.AddColumn("Nanoseconds", []int32{100, 200})
+	ticks.AddColumn("Tags", []string{"earnings,guidance", "halt"})
+	write("STRINGS/1Sec/NEWS", ticks, true)
+
+	out, _ = query("STRINGS/1Sec/NEWS")
+	c.Assert(out.GetColumn("Nanoseconds"), DeepEquals, []int32{100, 200})
+	c.Assert(out.GetColumn("Tags"), DeepEquals, []string{"earnings,guidance", "halt"})
+
+	// the strings of a bucket are in its side file only
+	dir := filepath.Join(executor.ThisInstance.RootDir, "STRINGS", "1D", "REF")
+	_, err := os.Stat(filepath.Join(dir, "strings.dat"))
+	c.Assert(err, IsNil)
+	_, err = os.Stat(filepath.Join(executor.ThisInstance.RootDir, "USDJPY", "1Min", "OHLC", "strings.dat"))
+	c.Assert(os.IsNotExist(err), Equals, true)
+}
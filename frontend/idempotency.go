@@ -0,0 +1,124 @@
+package frontend
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils"
+)
+
+// writtenKeys are the idempotency keys of the write requests written within
+// the idempotency window, so that a request retried with the same key, e.g.
+// after a timeout, is not written again.
+var writtenKeys = &idempotencyKeys{m: map[string]*keyedWrite{}}
+
+type idempotencyKeys struct {
+	sync.Mutex
+	m map[string]*keyedWrite
+	// expiring are the keys written, in the order they expire
+	expiring []expiringKey
+}
+
+// keyedWrite is the write of a request with an idempotency key, whose done
+// is closed once ended, with written set for each of its sub-requests
+// written so far, by it or by the earlier requests with the key.
+type keyedWrite struct {
+	done    chan struct{}
+	ended   bool
+	written []bool
+}
+
+// ok returns true if the whole request is written.
+func (w *keyedWrite) ok() bool {
+	for _, written := range w.written {
+		if !written {
+			return false
+		}
+	}
+	return true
+}
+
+type expiringKey struct {
+	key     string
+	w       *keyedWrite
+	expires time.Time
+}
+
+// writeOnce calls write with the written flags of the n sub-requests of the
+// request, which write skips if set and sets for those it writes, unless
+// the whole request was written with the same idempotency key within the
+// window, returning true if it is suppressed as such.  A request retried
+// while the first is being written waits for it, and writes the
+// sub-requests the first did not, so that a partially failed request can be
+// retried with its key without writing any sub-request twice.
+// The requests without a key are always written.
+func writeOnce(ctx context.Context, method, key string, n int, write func(written []bool)) (suppressed bool, err error) {
+	if key == "" {
+		write(make([]bool, n))
+		return false, nil
+	}
+	w, err := writtenKeys.begin(ctx, key, n, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if w == nil {
+		metrics.WriteSuppressedBatches.WithLabelValues(method).Inc()
+		return true, nil
+	}
+	defer func() { writtenKeys.end(key, w, time.Now().Add(utils.InstanceConfig.WriteIdempotencyWindow)) }()
+	write(w.written)
+	return false, nil
+}
+
+// begin returns nil if the request of the key was already written, or
+// reserves the key for the request to be written, ended by end, with the
+// sub-requests written by the earlier requests with the key.
+func (k *idempotencyKeys) begin(ctx context.Context, key string, n int, now time.Time) (*keyedWrite, error) {
+	for {
+		k.Lock()
+		k.expire(now)
+		prev, found := k.m[key]
+		if !found || prev.ended && !prev.ok() {
+			w := &keyedWrite{done: make(chan struct{}), written: make([]bool, n)}
+			if found {
+				copy(w.written, prev.written)
+			}
+			k.m[key] = w
+			k.Unlock()
+			return w, nil
+		}
+		k.Unlock()
+
+		select {
+		case <-prev.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if prev.ok() {
+			return nil, nil
+		}
+		// the failed request left the rest to be written by a retry
+	}
+}
+
+// end records the sub-requests of the key written until it expires.
+func (k *idempotencyKeys) end(key string, w *keyedWrite, expires time.Time) {
+	k.Lock()
+	defer k.Unlock()
+	w.ended = true
+	k.expiring = append(k.expiring, expiringKey{key: key, w: w, expires: expires})
+	close(w.done)
+}
+
+// expire forgets the keys expired at now, unless written again since.
+func (k *idempotencyKeys) expire(now time.Time) {
+	i := 0
+	for ; i < len(k.expiring) && !now.Before(k.expiring[i].expires); i++ {
+		if e := k.expiring[i]; k.m[e.key] == e.w {
+			delete(k.m, e.key)
+		}
+	}
+	k.expiring = k.expiring[i:]
+}
@@ -0,0 +1,186 @@
+package frontend
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+func (s *ServerTestSuite) TestWriteIdempotencyKey(c *C) {
+	defer func(window time.Duration) { utils.InstanceConfig.WriteIdempotencyWindow = window }(utils.InstanceConfig.WriteIdempotencyWindow)
+	utils.InstanceConfig.WriteIdempotencyWindow = time.Minute
+
+	tbk := io.NewTimeBucketKey("RETRIED/1Sec/TRADE")
+	t0 := time.Date(2003, 1, 3, 10, 0, 0, 0, time.UTC).Unix()
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{t0, t0 + 1})
+	cs.AddColumn("Price", []float32{1, 2})
+	cs.AddColumn("Nanoseconds", []int32{0, 0})
+	nds, err := io.NewNumpyDataset(cs)
+	c.Assert(err, IsNil)
+	nmds, err := io.NewNumpyMultiDataset(nds, *tbk)
+	c.Assert(err, IsNil)
+	req := &proto.MultiWriteRequest{
+		Requests:       []*proto.WriteRequest{{Data: ToProtoNumpyMultiDataSet(nmds), IsVariableLength: true}},
+		IdempotencyKey: "batch-1",
+	}
+	suppressed := metrics.WriteSuppressedBatches.WithLabelValues("GRPCService.Write")
+	before := testutil.ToFloat64(suppressed)
+
+	// the retried request is not appended again
+	for i := 0; i < 2; i++ {
+		resp, err := GRPCService{}.Write(context.Background(), req)
+		c.Assert(err, IsNil)
+		c.Assert(resp.Responses, HasLen, 0)
+	}
+	c.Assert(testutil.ToFloat64(suppressed)-before, Equals, float64(1))
+	qresp, err := GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{Destination: tbk.String()}},
+	})
+	c.Assert(err, IsNil)
+	csm, err := ToNumpyMultiDataSet(qresp.Responses[0].Result).ToColumnSeriesMap()
+	c.Assert(err, IsNil)
+	c.Assert(csm[*tbk].GetColumn("Price"), DeepEquals, []float32{1, 2})
+}
+
+func (s *ServerTestSuite) TestWriteOnce(c *C) {
+	defer func(window time.Duration) { utils.InstanceConfig.WriteIdempotencyWindow = window }(utils.InstanceConfig.WriteIdempotencyWindow)
+	utils.InstanceConfig.WriteIdempotencyWindow = time.Minute
+
+	ctx := context.Background()
+	writes := 0
+	write := func(ok bool) func([]bool) {
+		return func(written []bool) {
+			writes++
+			written[0] = ok
+		}
+	}
+
+	// a failed request is written again, a written one is not
+	suppressed, err := writeOnce(ctx, "test", "once-1", 1, write(false))
+	c.Assert(err, IsNil)
+	c.Assert(suppressed, Equals, false)
+	suppressed, err = writeOnce(ctx, "test", "once-1", 1, write(true))
+	c.Assert(err, IsNil)
+	c.Assert(suppressed, Equals, false)
+	suppressed, err = writeOnce(ctx, "test", "once-1", 1, write(true))
+	c.Assert(err, IsNil)
+	c.Assert(suppressed, Equals, true)
+	c.Assert(writes, Equals, 2)
+
+	// without a key, always written
+	for i := 0; i < 2; i++ {
+		suppressed, err = writeOnce(ctx, "test", "", 1, write(true))
+		c.Assert(err, IsNil)
+		c.Assert(suppressed, Equals, false)
+	}
+	c.Assert(writes, Equals, 4)
+
+	// the key is forgotten past the window
+	writtenKeys.Lock()
+	writtenKeys.expire(time.Now().Add(utils.InstanceConfig.WriteIdempotencyWindow + time.Second))
+	writtenKeys.Unlock()
+	suppressed, err = writeOnce(ctx, "test", "once-1", 1, write(true))
+	c.Assert(err, IsNil)
+	c.Assert(suppressed, Equals, false)
+	c.Assert(writes, Equals, 5)
+
+	// a retry waits for the request being written
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan bool)
+	go func() {
+		writeOnce(ctx, "test", "once-2", 1, func(written []bool) {
+			close(started)
+			<-release
+			written[0] = true
+		})
+		done <- true
+	}()
+	<-started
+	canceled, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = writeOnce(canceled, "test", "once-2", 1, write(true))
+	c.Assert(err, Equals, context.DeadlineExceeded)
+	retried := make(chan bool)
+	go func() {
+		suppressed, _ := writeOnce(ctx, "test", "once-2", 1, write(true))
+		retried <- suppressed
+	}()
+	close(release)
+	<-done
+	c.Assert(<-retried, Equals, true)
+	c.Assert(writes, Equals, 5)
+}
+
+func (s *ServerTestSuite) TestWriteIdempotencyKeyPartialFailure(c *C) {
+	defer func(window time.Duration) { utils.InstanceConfig.WriteIdempotencyWindow = window }(utils.InstanceConfig.WriteIdempotencyWindow)
+	utils.InstanceConfig.WriteIdempotencyWindow = time.Minute
+
+	t0 := time.Date(2003, 1, 3, 10, 0, 0, 0, time.UTC).Unix()
+	writeRequest := func(key string, cs *io.ColumnSeries, isVariableLength bool) WriteRequest {
+		nds, err := io.NewNumpyDataset(cs)
+		c.Assert(err, IsNil)
+		nmds, err := io.NewNumpyMultiDataset(nds, *io.NewTimeBucketKey(key))
+		c.Assert(err, IsNil)
+		return WriteRequest{Data: nmds, IsVariableLength: isVariableLength}
+	}
+	service := &DataService{}
+	ticks := io.NewColumnSeries()
+	ticks.AddColumn("Epoch", []int64{t0, t0 + 1})
+	ticks.AddColumn("Price", []float32{1, 2})
+	ticks.AddColumn("Nanoseconds", []int32{0, 0})
+	bar := func(price interface{}) *io.ColumnSeries {
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", []int64{t0})
+		cs.AddColumn("Close", price)
+		return cs
+	}
+	var resp MultiServerResponse
+	err := service.Write(nil, &MultiWriteRequest{
+		Requests: []WriteRequest{writeRequest("PARTIAL/1Min/BAR", bar([]float32{1}), false)},
+	}, &resp)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Responses, HasLen, 0)
+
+	// the bar has the wrong type, while the ticks are written
+	err = service.Write(nil, &MultiWriteRequest{
+		Requests: []WriteRequest{
+			writeRequest("PARTIAL/1Sec/TRADE", ticks, true),
+			writeRequest("PARTIAL/1Min/BAR", bar([]float64{2}), false),
+		},
+		IdempotencyKey: "partial-1",
+	}, &resp)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Responses, HasLen, 1)
+
+	// the retry only writes the bar
+	resp = MultiServerResponse{}
+	err = service.Write(nil, &MultiWriteRequest{
+		Requests: []WriteRequest{
+			writeRequest("PARTIAL/1Sec/TRADE", ticks, true),
+			writeRequest("PARTIAL/1Min/BAR", bar([]float32{2}), false),
+		},
+		IdempotencyKey: "partial-1",
+	}, &resp)
+	c.Assert(err, IsNil)
+	c.Assert(resp.Responses, HasLen, 0)
+
+	query := func(key string) *io.ColumnSeries {
+		qresp, err := GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+			Requests: []*proto.QueryRequest{{Destination: key}},
+		})
+		c.Assert(err, IsNil)
+		csm, err := ToNumpyMultiDataSet(qresp.Responses[0].Result).ToColumnSeriesMap()
+		c.Assert(err, IsNil)
+		return csm[*io.NewTimeBucketKey(key)]
+	}
+	c.Assert(query("PARTIAL/1Sec/TRADE").GetColumn("Price"), DeepEquals, []float32{1, 2})
+	c.Assert(query("PARTIAL/1Min/BAR").GetColumn("Close"), DeepEquals, []float32{2})
+}
@@ -0,0 +1,36 @@
+package frontend
+
+import (
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// keyLabels returns the symbol and timeframe labels of the key.  The symbol
+// is left blank unless enabled, to keep the cardinality of the metrics bounded.
+func keyLabels(tbk io.TimeBucketKey) (symbol, timeframe string) {
+	if utils.InstanceConfig.MetricsSymbolLabels {
+		symbol = tbk.GetItemInCategory("Symbol")
+	}
+	return symbol, tbk.GetItemInCategory("Timeframe")
+}
+
+// observeQueryRows counts the rows of the query results.
+func observeQueryRows(method string, csm io.ColumnSeriesMap) {
+	for tbk, cs := range csm {
+		symbol, timeframe := keyLabels(tbk)
+		metrics.QueryRows.WithLabelValues(method, symbol, timeframe).Add(float64(cs.Len()))
+	}
+}
+
+// observeWriteBytes counts the size of the written records.
+func observeWriteBytes(method string, csm io.ColumnSeriesMap) {
+	for tbk, cs := range csm {
+		recordSize := 0
+		for _, ds := range cs.GetDataShapes() {
+			recordSize += ds.Type.Size()
+		}
+		symbol, timeframe := keyLabels(tbk)
+		metrics.WriteBytes.WithLabelValues(method, symbol, timeframe).Add(float64(cs.Len() * recordSize))
+	}
+}
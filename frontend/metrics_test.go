@@ -0,0 +1,126 @@
+package frontend
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "gopkg.in/check.v1"
+)
+
+func (s *ServerTestSuite) TestQueryMetrics(c *C) {
+	service := &DataService{}
+	service.Init()
+
+	rows := metrics.QueryRows.WithLabelValues("DataService.Query", "", "1Min")
+	before := testutil.ToFloat64(rows)
+
+	args := &MultiQueryRequest{
+		Requests: []QueryRequest{
+			(NewQueryRequestBuilder("USDJPY/1Min/OHLC").
+				EpochStart(0).
+				EpochEnd(math.MaxInt32).
+				LimitRecordCount(200).
+				End()),
+		},
+	}
+	var response MultiQueryResponse
+	c.Assert(service.Query(nil, args, &response), IsNil)
+
+	c.Assert(testutil.ToFloat64(rows)-before, Equals, float64(200))
+}
+
+func (s *ServerTestSuite) TestWriteMetrics(c *C) {
+	tbk := io.NewTimeBucketKey("METRICS/1Min/OHLC")
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{0, 60})
+	cs.AddColumn("Open", []float32{1, 2})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+
+	written := metrics.WriteBytes.WithLabelValues("test", "", "1Min")
+	observeWriteBytes("test", csm)
+	c.Assert(testutil.ToFloat64(written), Equals, float64(2*(8+4)))
+
+	// symbols are labeled only if enabled
+	utils.InstanceConfig.MetricsSymbolLabels = true
+	defer func() { utils.InstanceConfig.MetricsSymbolLabels = false }()
+	observeWriteBytes("test", csm)
+	c.Assert(testutil.ToFloat64(metrics.WriteBytes.WithLabelValues("test", "METRICS", "1Min")), Equals, float64(2*(8+4)))
+}
+
+func (s *ServerTestSuite) TestDiskUsageMetrics(c *C) {
+	// the sizes of the year files by timeframe
+	want := map[string]executor.DiskUsage{}
+	err := filepath.Walk(s.Rootdir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".bin" {
+			return err
+		}
+		rel, _ := filepath.Rel(s.Rootdir, path)
+		timeframe := strings.Split(rel, string(filepath.Separator))[1]
+		u := want[timeframe]
+		u.Bytes += info.Size()
+		u.Files++
+		want[timeframe] = u
+		return nil
+	})
+	c.Assert(err, IsNil)
+	c.Assert(want["1Min"].Files > 0, Equals, true)
+
+	c.Assert(executor.CollectDiskUsage(), DeepEquals, want)
+
+	executor.UpdateDiskUsage()
+	for timeframe, u := range want {
+		c.Check(testutil.ToFloat64(metrics.DiskBytes.WithLabelValues(timeframe)), Equals, float64(u.Bytes))
+		c.Check(testutil.ToFloat64(metrics.DiskFiles.WithLabelValues(timeframe)), Equals, float64(u.Files))
+	}
+}
+
+func (s *ServerTestSuite) TestIngestionLagMetrics(c *C) {
+	tbk := io.NewTimeBucketKey("USDJPY/1Min/OHLC")
+	tail, err := executor.BucketTail(tbk)
+	c.Assert(err, IsNil)
+	c.Assert(tail.IsZero(), Equals, false)
+
+	now := tail.Add(time.Hour)
+	lags := executor.CollectIngestionLag(now)
+	c.Assert(lags["1Min"]["USDJPY"], Equals, time.Hour)
+
+	// the oldest of the timeframe, by symbol only if enabled
+	utils.InstanceConfig.MetricsSymbolLabels = true
+	defer func() { utils.InstanceConfig.MetricsSymbolLabels = false }()
+	executor.UpdateIngestionLag()
+	lag := testutil.ToFloat64(metrics.IngestionLag.WithLabelValues("1Min"))
+	symbolLag := testutil.ToFloat64(metrics.IngestionSymbolLag.WithLabelValues("USDJPY", "1Min"))
+	c.Assert(symbolLag >= time.Since(tail).Seconds()-60, Equals, true)
+	c.Assert(lag >= symbolLag, Equals, true)
+
+	// never missing from a scrape while updated
+	updated := make(chan struct{})
+	go func() {
+		defer close(updated)
+		for i := 0; i < 100; i++ {
+			executor.UpdateIngestionLag()
+		}
+	}()
+	for scraping := true; scraping; {
+		select {
+		case <-updated:
+			scraping = false
+		default:
+		}
+		c.Assert(testutil.CollectAndCount(metrics.IngestionSymbolLag) > 0, Equals, true)
+	}
+
+	utils.InstanceConfig.MetricsSymbolLabels = false
+	executor.UpdateIngestionLag()
+	c.Assert(testutil.CollectAndCount(metrics.IngestionSymbolLag), Equals, 0)
+	c.Assert(testutil.CollectAndCount(metrics.IngestionLag) > 0, Equals, true)
+}
@@ -5,6 +5,16 @@ import (
 	"net/http/pprof"
 )
 
+// RegisterPprof registers the net/http/pprof handlers at /debug/pprof/ on mux.
+// They expose the internals of the process, so only register them on demand.
+func RegisterPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
 func Profile(address string) {
 	r := http.NewServeMux()
 
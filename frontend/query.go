@@ -1,6 +1,7 @@
 package frontend
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"net/http"
@@ -10,11 +11,16 @@ import (
 
 	"github.com/alpacahq/marketstore/v4/catalog"
 	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/metrics"
 	"github.com/alpacahq/marketstore/v4/planner"
 	"github.com/alpacahq/marketstore/v4/sqlparser"
 	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/io"
 	"github.com/alpacahq/marketstore/v4/utils/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 // This is the parameter interface for DataService.Query method.
@@ -89,6 +95,10 @@ func (resp *MultiQueryResponse) ToColumnSeriesMap() (*io.ColumnSeriesMap, error)
 }
 
 func (s *DataService) Query(r *http.Request, reqs *MultiQueryRequest, response *MultiQueryResponse) (err error) {
+	timer := prometheus.NewTimer(metrics.QueryDuration.WithLabelValues("DataService.Query"))
+	defer timer.ObserveDuration()
+
+	ctx := withResultBudget(requestContext(r))
 	response.Version = utils.GitHash
 	response.Timezone = utils.InstanceConfig.Timezone.String()
 	for _, req := range reqs.Requests {
@@ -115,6 +125,7 @@ func (s *DataService) Query(r *http.Request, reqs *MultiQueryRequest, response *
 			if err != nil {
 				return err
 			}
+			metrics.QueryRows.WithLabelValues("DataService.Query", "", "").Add(float64(cs.Len()))
 			response.Responses = append(response.Responses,
 				QueryResponse{
 					nmds,
@@ -184,7 +195,7 @@ func (s *DataService) Query(r *http.Request, reqs *MultiQueryRequest, response *
 			start := io.ToSystemTimezone(time.Unix(epochStart, epochStartNanos))
 			end := io.ToSystemTimezone(time.Unix(epochEnd, epochEndNanos))
 			csm, err := executeQuery(
-				dest,
+				ctx, dest,
 				start, end,
 				limitRecordCount, limitFromStart,
 				columns,
@@ -205,6 +216,7 @@ func (s *DataService) Query(r *http.Request, reqs *MultiQueryRequest, response *
 					csm[tbkStr] = csOut
 				}
 			}
+			observeQueryRows("DataService.Query", csm)
 
 			/*
 				Separate each TimeBucket from the result and compose a NumpyMultiDataset
@@ -274,7 +286,43 @@ func (s *DataService) ListSymbols(r *http.Request, req *ListSymbolsRequest, resp
 Utility functions
 */
 
-func executeQuery(tbk *io.TimeBucketKey, start, end time.Time, LimitRecordCount int,
+// peerKey is the context key of the address of the client of a JSON-RPC
+// request, which has no gRPC peer.
+type peerKey struct{}
+
+// requestContext returns the context of the JSON-RPC request r with the
+// address of its client.
+func requestContext(r *http.Request) context.Context {
+	if r == nil {
+		return context.Background()
+	}
+	return context.WithValue(r.Context(), peerKey{}, r.RemoteAddr)
+}
+
+// requestPeer returns the address of the client of the request of ctx, or
+// an empty string if unknown.
+func requestPeer(ctx context.Context) string {
+	if addr, ok := ctx.Value(peerKey{}).(string); ok {
+		return addr
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		return p.Addr.String()
+	}
+	return ""
+}
+
+// executeQuery reads the bucket of tbk, stitched with the buckets of the
+// old symbols of its symbol, if it has any aliases.
+func executeQuery(ctx context.Context, tbk *io.TimeBucketKey, start, end time.Time, LimitRecordCount int,
+	LimitFromStart bool, columns []string) (io.ColumnSeriesMap, error) {
+	olds := executor.ThisInstance.CatalogDir.SymbolAliases(tbk.GetItemInCategory("Symbol"))
+	if len(olds) == 0 {
+		return executeBucketQuery(ctx, tbk, start, end, LimitRecordCount, LimitFromStart, columns)
+	}
+	return executeAliasQuery(ctx, tbk, olds, start, end, LimitRecordCount, LimitFromStart, columns)
+}
+
+func executeBucketQuery(ctx context.Context, tbk *io.TimeBucketKey, start, end time.Time, LimitRecordCount int,
 	LimitFromStart bool, columns []string) (io.ColumnSeriesMap, error) {
 	query := planner.NewQuery(executor.ThisInstance.CatalogDir)
 
@@ -288,6 +336,18 @@ func executeQuery(tbk *io.TimeBucketKey, start, end time.Time, LimitRecordCount
 	tbk.SetItemInCategory("Timeframe", queryableTimeframe)
 	query.AddTargetKey(tbk)
 
+	cache := getQueryCache()
+	var cacheKey queryCacheKey
+	var generation uint64
+	if cache != nil {
+		cacheKey = newQueryCacheKey(tbk, start, end, LimitRecordCount, LimitFromStart, columns)
+		csm, gen, ok := cache.get(cacheKey)
+		if ok {
+			return csm, nil
+		}
+		generation = gen
+	}
+
 	if LimitRecordCount != 0 {
 		direction := io.LAST
 		if LimitFromStart {
@@ -306,7 +366,7 @@ func executeQuery(tbk *io.TimeBucketKey, start, end time.Time, LimitRecordCount
 	parseResult, err := query.Parse()
 	if err != nil {
 		// No results from query
-		if err.Error() == "No files returned from query parse" {
+		if err.Error() == errNoFiles {
 			log.Info("No results returned from query: Target: %v, start, end: %v,%v LimitRecordCount: %v",
 				tbk.String(), start, end, LimitRecordCount)
 		} else {
@@ -319,17 +379,72 @@ func executeQuery(tbk *io.TimeBucketKey, start, end time.Time, LimitRecordCount
 		log.Error("Unable to create scanner: %s\n", err)
 		return nil, err
 	}
-	csm, err := scanner.Read()
-	if err != nil {
+	if err := checkResultSize(ctx, tbk, scanner); err != nil {
+		return nil, err
+	}
+	ctx, done := executor.StartQuery(ctx, tbk.GetItemKey(), start, end, requestPeer(ctx))
+	defer done()
+	csm, err := scanner.ReadContext(ctx)
+	if err == context.Canceled {
+		return nil, status.Errorf(codes.Canceled, "the query of %s was canceled", tbk.GetItemKey())
+	} else if err != nil {
 		log.Error("Error returned from query scanner: %s\n", err)
 		return nil, err
 	}
 
 	csm.FilterColumns(columns)
 
+	if cache != nil {
+		cache.put(cacheKey, generation, csm)
+	}
 	return csm, err
 }
 
+// resultBudgetKey is the context key of the estimated size of the results
+// of the queries of a request so far, which the max_query_result_size limits
+// as a whole, e.g. for the symbols of a multi-symbol query.
+type resultBudgetKey struct{}
+
+// withResultBudget returns ctx with the estimated size of the results of its
+// request, added to by checkResultSize.
+func withResultBudget(ctx context.Context) context.Context {
+	return context.WithValue(ctx, resultBudgetKey{}, new(int64))
+}
+
+// checkResultSize returns a ResourceExhausted error if the estimated size
+// of the result of the scanner, along with the results of the other queries
+// of the request of ctx, is over the max_query_result_size, before the data
+// is read.
+func checkResultSize(ctx context.Context, tbk *io.TimeBucketKey, scanner *executor.Reader) error {
+	max := utils.InstanceConfig.MaxQueryResultBytes
+	if max <= 0 {
+		return nil
+	}
+	size, err := scanner.EstimateSize()
+	if err != nil {
+		return err
+	}
+	if size > max {
+		log.Warn("rejecting the query of %s with an estimated result of %d bytes", tbk.String(), size)
+		return status.Errorf(codes.ResourceExhausted,
+			"the result of %s is estimated at %d bytes, over the limit of %d bytes, "+
+				"add a limit or narrow the time range", tbk.GetItemKey(), size, max)
+	}
+	estimated, ok := ctx.Value(resultBudgetKey{}).(*int64)
+	if !ok {
+		return nil
+	}
+	if total := atomic.AddInt64(estimated, size); total > max {
+		// not read
+		atomic.AddInt64(estimated, -size)
+		log.Warn("rejecting the query of %s with the results of its request estimated at %d bytes", tbk.String(), total)
+		return status.Errorf(codes.ResourceExhausted,
+			"the results of the request are estimated at %d bytes with %s, over the limit of %d bytes, "+
+				"add a limit or narrow the time range", total, tbk.GetItemKey(), max)
+	}
+	return nil
+}
+
 func runAggFunctions(callChain []string, csInput *io.ColumnSeries) (cs *io.ColumnSeries, err error) {
 	cs = nil
 	for _, call := range callChain {
@@ -0,0 +1,206 @@
+package frontend
+
+import (
+	"container/list"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// queryResults is the *queryCache of the bucket queries, nil if disabled.
+var (
+	queryResults     atomic.Value
+	queryResultsHook sync.Once
+)
+
+// InitQueryCache enables the cache of the results of the bucket queries,
+// keeping up to size of them for ttl, or disables it if size is 0.  The
+// results of a bucket are removed from it when a record is written to it,
+// or a year file of it is pruned.
+func InitQueryCache(size int, ttl time.Duration) {
+	var cache *queryCache
+	if size > 0 {
+		cache = newQueryCache(size, ttl)
+	}
+	queryResults.Store(cache)
+	queryResultsHook.Do(func() {
+		executor.AddWriteHook(func(key string) {
+			// the key of the file of the bucket, e.g. AAPL/1Min/OHLCV/2020.bin,
+			// written or pruned
+			invalidateQueryCache(path.Dir(key))
+		})
+	})
+}
+
+func getQueryCache() *queryCache {
+	cache, _ := queryResults.Load().(*queryCache)
+	return cache
+}
+
+// invalidateQueryCache removes the results of the bucket of the item key
+// from the query cache, if enabled.
+func invalidateQueryCache(bucket string) {
+	if cache := getQueryCache(); cache != nil {
+		cache.invalidate(bucket)
+	}
+}
+
+// clearQueryCache removes all the results from the query cache, if enabled,
+// e.g. after removing the buckets under a key.
+func clearQueryCache() {
+	if cache := getQueryCache(); cache != nil {
+		cache.clear()
+	}
+}
+
+// queryCacheKey identifies the query of a bucket.
+type queryCacheKey struct {
+	bucket         string
+	start, end     int64
+	limit          int
+	limitFromStart bool
+	columns        string
+}
+
+func newQueryCacheKey(tbk *io.TimeBucketKey, start, end time.Time, limit int,
+	limitFromStart bool, columns []string) queryCacheKey {
+	return queryCacheKey{
+		bucket:         tbk.GetItemKey(),
+		start:          start.UnixNano(),
+		end:            end.UnixNano(),
+		limit:          limit,
+		limitFromStart: limitFromStart,
+		columns:        strings.Join(columns, ","),
+	}
+}
+
+type cachedQuery struct {
+	key     queryCacheKey
+	csm     io.ColumnSeriesMap
+	expires time.Time
+}
+
+// queryCache is an LRU cache of the results of the bucket queries.
+type queryCache struct {
+	sync.Mutex
+	size int
+	ttl  time.Duration
+	// lru holds the *cachedQuery, the most recently used first
+	lru      *list.List
+	entries  map[queryCacheKey]*list.Element
+	byBucket map[string]map[queryCacheKey]struct{}
+	// generations counts the writes to each bucket and clears counts the
+	// clears of the cache, so that a result read across a write to its
+	// bucket or a clear is not cached
+	generations map[string]uint64
+	clears      uint64
+}
+
+func newQueryCache(size int, ttl time.Duration) *queryCache {
+	return &queryCache{
+		size:        size,
+		ttl:         ttl,
+		lru:         list.New(),
+		entries:     map[queryCacheKey]*list.Element{},
+		byBucket:    map[string]map[queryCacheKey]struct{}{},
+		generations: map[string]uint64{},
+	}
+}
+
+// get returns a copy of the cached result of the query, and otherwise the
+// generation of its bucket to put the result with.
+func (c *queryCache) get(key queryCacheKey) (io.ColumnSeriesMap, uint64, bool) {
+	c.Lock()
+	defer c.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cachedQuery)
+		if time.Now().Before(entry.expires) {
+			c.lru.MoveToFront(elem)
+			metrics.QueryCacheHits.Inc()
+			return copyColumnSeriesMap(entry.csm), 0, true
+		}
+		c.remove(elem)
+	}
+	metrics.QueryCacheMisses.Inc()
+	return nil, c.generation(key.bucket), false
+}
+
+func (c *queryCache) generation(bucket string) uint64 {
+	return c.generations[bucket] + c.clears
+}
+
+// put caches a copy of the result of the query, unless its bucket was
+// written since the generation returned by get.
+func (c *queryCache) put(key queryCacheKey, generation uint64, csm io.ColumnSeriesMap) {
+	c.Lock()
+	defer c.Unlock()
+	if c.generation(key.bucket) != generation {
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&cachedQuery{
+		key:     key,
+		csm:     copyColumnSeriesMap(csm),
+		expires: time.Now().Add(c.ttl),
+	})
+	if c.byBucket[key.bucket] == nil {
+		c.byBucket[key.bucket] = map[queryCacheKey]struct{}{}
+	}
+	c.byBucket[key.bucket][key] = struct{}{}
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// invalidate removes the results of the bucket.
+func (c *queryCache) invalidate(bucket string) {
+	c.Lock()
+	defer c.Unlock()
+	c.generations[bucket]++
+	for key := range c.byBucket[bucket] {
+		c.remove(c.entries[key])
+	}
+}
+
+// clear removes all the results.
+func (c *queryCache) clear() {
+	c.Lock()
+	defer c.Unlock()
+	c.clears++
+	c.lru.Init()
+	c.entries = map[queryCacheKey]*list.Element{}
+	c.byBucket = map[string]map[queryCacheKey]struct{}{}
+}
+
+func (c *queryCache) remove(elem *list.Element) {
+	key := elem.Value.(*cachedQuery).key
+	c.lru.Remove(elem)
+	delete(c.entries, key)
+	delete(c.byBucket[key.bucket], key)
+	if len(c.byBucket[key.bucket]) == 0 {
+		delete(c.byBucket, key.bucket)
+	}
+}
+
+// copyColumnSeriesMap returns a copy of csm sharing the columns, so that
+// adding, removing or replacing the columns of the copy does not change csm.
+func copyColumnSeriesMap(csm io.ColumnSeriesMap) io.ColumnSeriesMap {
+	out := io.NewColumnSeriesMap()
+	for tbk, cs := range csm {
+		csCopy := io.NewColumnSeries()
+		for _, name := range cs.GetColumnNames() {
+			csCopy.AddColumn(name, cs.GetColumn(name))
+		}
+		csCopy.SetCandleAttributes(cs.GetCandleAttributes())
+		out[tbk] = csCopy
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package frontend
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+func (s *ServerTestSuite) TestQueryCache(c *C) {
+	InitQueryCache(10, time.Minute)
+	defer InitQueryCache(0, 0)
+
+	tbk := io.NewTimeBucketKey("CACHE/1Min/OHLC")
+	t0 := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	write := func(t time.Time) {
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", []int64{t.Unix()})
+		cs.AddColumn("Open", []float32{1})
+		cs.AddColumn("High", []float32{2})
+		cs.AddColumn("Low", []float32{0.5})
+		cs.AddColumn("Close", []float32{1.5})
+		csm := io.NewColumnSeriesMap()
+		csm.AddColumnSeries(*tbk, cs)
+		c.Assert(executor.WriteCSM(csm, false), IsNil)
+		c.Assert(executor.ThisInstance.FlushWrites(context.Background()), IsNil)
+	}
+	query := func() []int64 {
+		csm, err := executeQuery(context.Background(), io.NewTimeBucketKey(tbk.String()),
+			time.Unix(0, 0), time.Unix(math.MaxInt32, 0), 0, false, nil)
+		c.Assert(err, IsNil)
+		return csm[*tbk].GetEpoch()
+	}
+	write(t0)
+
+	hits := testutil.ToFloat64(metrics.QueryCacheHits)
+	misses := testutil.ToFloat64(metrics.QueryCacheMisses)
+	c.Assert(query(), DeepEquals, []int64{t0.Unix()})
+	c.Assert(testutil.ToFloat64(metrics.QueryCacheMisses)-misses, Equals, float64(1))
+
+	// the changes to the result do not change the cached one
+	csm, err := executeQuery(context.Background(), io.NewTimeBucketKey(tbk.String()),
+		time.Unix(0, 0), time.Unix(math.MaxInt32, 0), 0, false, nil)
+	c.Assert(err, IsNil)
+	c.Assert(csm[*tbk].Remove("Close"), IsNil)
+	c.Assert(testutil.ToFloat64(metrics.QueryCacheHits)-hits, Equals, float64(1))
+	csm, err = executeQuery(context.Background(), io.NewTimeBucketKey(tbk.String()),
+		time.Unix(0, 0), time.Unix(math.MaxInt32, 0), 0, false, nil)
+	c.Assert(err, IsNil)
+	c.Assert(csm[*tbk].Exists("Close"), Equals, true)
+	c.Assert(testutil.ToFloat64(metrics.QueryCacheHits)-hits, Equals, float64(2))
+
+	// invalidated by a write to the bucket
+	write(t0.Add(time.Minute))
+	c.Assert(query(), DeepEquals, []int64{t0.Unix(), t0.Add(time.Minute).Unix()})
+	c.Assert(testutil.ToFloat64(metrics.QueryCacheMisses)-misses, Equals, float64(2))
+	c.Assert(testutil.ToFloat64(metrics.QueryCacheHits)-hits, Equals, float64(2))
+}
+
+func (s *ServerTestSuite) TestQueryCacheRetention(c *C) {
+	InitQueryCache(10, time.Minute)
+	defer InitQueryCache(0, 0)
+
+	tbk := io.NewTimeBucketKey("PRUNED/1Min/OHLC")
+	t0 := time.Date(2019, 1, 2, 3, 4, 0, 0, time.UTC)
+	t1 := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{t0.Unix(), t1.Unix()})
+	cs.AddColumn("Open", []float32{1, 1})
+	cs.AddColumn("High", []float32{2, 2})
+	cs.AddColumn("Low", []float32{0.5, 0.5})
+	cs.AddColumn("Close", []float32{1.5, 1.5})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+	c.Assert(executor.ThisInstance.FlushWrites(context.Background()), IsNil)
+	query := func() []int64 {
+		csm, err := executeQuery(context.Background(), io.NewTimeBucketKey(tbk.String()),
+			time.Unix(0, 0), time.Unix(math.MaxInt32, 0), 0, false, nil)
+		c.Assert(err, IsNil)
+		return csm[*tbk].GetEpoch()
+	}
+	c.Assert(query(), DeepEquals, []int64{t0.Unix(), t1.Unix()})
+
+	// invalidated by the pruning of a year file of the bucket
+	r, err := executor.NewRetention(utils.RetentionSetting{
+		Policies: []*utils.RetentionPolicy{{Symbols: "PRUNED", MaxAge: 24 * time.Hour}},
+	})
+	c.Assert(err, IsNil)
+	_, _, err = r.Prune(t1.AddDate(1, 0, 0))
+	c.Assert(err, IsNil)
+	c.Assert(query(), DeepEquals, []int64{t1.Unix()})
+}
+
+func (s *ServerTestSuite) TestQueryCacheEviction(c *C) {
+	cache := newQueryCache(2, time.Minute)
+	key := func(bucket string) queryCacheKey { return queryCacheKey{bucket: bucket} }
+	csm := io.NewColumnSeriesMap()
+
+	for _, bucket := range []string{"A/1Min/OHLC", "B/1Min/OHLC", "C/1Min/OHLC"} {
+		_, gen, ok := cache.get(key(bucket))
+		c.Assert(ok, Equals, false)
+		cache.put(key(bucket), gen, csm)
+	}
+	// the least recently used is evicted
+	_, _, ok := cache.get(key("A/1Min/OHLC"))
+	c.Assert(ok, Equals, false)
+	_, _, ok = cache.get(key("B/1Min/OHLC"))
+	c.Assert(ok, Equals, true)
+
+	// not cached if the bucket is written in between
+	_, gen, _ := cache.get(key("A/1Min/OHLC"))
+	cache.invalidate("A/1Min/OHLC")
+	cache.put(key("A/1Min/OHLC"), gen, csm)
+	_, _, ok = cache.get(key("A/1Min/OHLC"))
+	c.Assert(ok, Equals, false)
+
+	// nor after the ttl
+	cache = newQueryCache(2, time.Nanosecond)
+	_, gen, _ = cache.get(key("A/1Min/OHLC"))
+	cache.put(key("A/1Min/OHLC"), gen, csm)
+	time.Sleep(time.Millisecond)
+	_, _, ok = cache.get(key("A/1Min/OHLC"))
+	c.Assert(ok, Equals, false)
+}
@@ -0,0 +1,181 @@
+package frontend
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// queryStreamDepth is the number of commits to the bucket a streaming query
+// can lag behind before it is ended.
+var queryStreamDepth = 1000
+
+// QueryStream sends the result of the query, and then the records written
+// to its bucket in the range of the query, as they are committed, until the
+// call is canceled or its deadline is passed.
+//
+// The writes are subscribed to before the query is read, and the query is
+// read with the commits frozen.  The commits notified by then are in the
+// result, so they are skipped, while those notified after it are all sent,
+// e.g. the updates of the last bar.
+func (s GRPCService) QueryStream(req *proto.QueryRequest, stream proto.Marketstore_QueryStreamServer) error {
+	timer := prometheus.NewTimer(metrics.QueryDuration.WithLabelValues("GRPCService.QueryStream"))
+	defer timer.ObserveDuration()
+
+	dest, err := streamDestination(req)
+	if err != nil {
+		return err
+	}
+	timeframe := dest.GetItemInCategory("Timeframe")
+	ctx := stream.Context()
+
+	sub := executor.SubscribeWrites(dest, queryStreamDepth)
+	defer sub.Close()
+
+	var (
+		csm     io.ColumnSeriesMap
+		handoff int
+	)
+	err = executor.ThisInstance.ReadConsistent(ctx, func() error {
+		var err error
+		csm, err = s.queryDestination(ctx, req, io.NewTimeBucketKey(dest.String()), timeframe)
+		if err != nil && err.Error() != errNoFiles {
+			return err
+		}
+		handoff = len(sub.C())
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	observeQueryRows("GRPCService.QueryStream", csm)
+	if err := sendStreamResult(stream, csm, timeframe); err != nil {
+		return err
+	}
+
+	start, end := streamRange(req)
+	for {
+		select {
+		case wr, ok := <-sub.C():
+			if !ok {
+				return status.Errorf(codes.ResourceExhausted,
+					"the streaming query of %s fell too far behind the writes", dest.String())
+			}
+			if handoff > 0 {
+				// committed before the read
+				handoff--
+				continue
+			}
+			tbk, cs, err := executor.WrittenColumnSeries(wr)
+			if err != nil {
+				return err
+			}
+			cs = rowsInRange(cs, start, end)
+			if cs.Len() == 0 {
+				continue
+			}
+			live := io.ColumnSeriesMap{*tbk: cs}
+			live.FilterColumns(req.Columns)
+			observeQueryRows("GRPCService.QueryStream", live)
+			if err := sendStreamResult(stream, live, timeframe); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// streamDestination returns the bucket of the streaming query, which is
+// of a single symbol and without the options transforming the rows.
+func streamDestination(req *proto.QueryRequest) (*io.TimeBucketKey, error) {
+	switch {
+	case req.IsSqlStatement:
+		return nil, status.Error(codes.InvalidArgument, "SQL statements cannot be streamed")
+	case len(req.Symbols) != 0 || req.SymbolGlob != "":
+		return nil, status.Error(codes.InvalidArgument, "multi-symbol queries cannot be streamed")
+	case req.LimitFromStart:
+		return nil, status.Error(codes.InvalidArgument, "limit_from_start cannot be used with a streaming query")
+	case req.Resample != "" || req.FillGaps || len(req.Functions) != 0:
+		return nil, status.Error(codes.InvalidArgument,
+			"resample, fill_gaps and functions cannot be used with a streaming query")
+	}
+
+	dest := io.NewTimeBucketKey(req.Destination, req.KeyCategory)
+	symbols := dest.GetMultiItemInCategory("Symbol")
+	timeframe := dest.GetItemInCategory("Timeframe")
+	if len(symbols) != 1 || symbols[0] == "*" || timeframe == "" || dest.GetItemInCategory("AttributeGroup") == "" {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"destination must have a single Symbol, a Timeframe and an AttributeGroup, have: %s", dest.String())
+	}
+	if cd := utils.CandleDurationFromString(timeframe); cd == nil || cd.QueryableTimeframe() != timeframe {
+		return nil, status.Errorf(codes.InvalidArgument, "timeframe %s cannot be streamed", timeframe)
+	}
+	if req.Columns != nil {
+		if err := checkColumns(dest, timeframe, req.Columns); err != nil {
+			return nil, err
+		}
+	}
+	return dest, nil
+}
+
+// streamRange returns the range of the query in Unix nanoseconds.
+func streamRange(req *proto.QueryRequest) (start, end int64) {
+	start = req.EpochStart*int64(time.Second) + req.EpochStartNanos
+	end = int64(math.MaxInt64)
+	if req.EpochEnd != 0 {
+		end = req.EpochEnd*int64(time.Second) + req.EpochEndNanos
+	}
+	return start, end
+}
+
+// rowTimes returns the times of the rows in Unix nanoseconds.
+func rowTimes(cs *io.ColumnSeries) []int64 {
+	epochs := cs.GetEpoch()
+	nanos, _ := cs.GetColumn("Nanoseconds").([]int32)
+	times := make([]int64, len(epochs))
+	for i, epoch := range epochs {
+		times[i] = epoch * int64(time.Second)
+		if nanos != nil {
+			times[i] += int64(nanos[i])
+		}
+	}
+	return times
+}
+
+// rowsInRange returns the rows of cs from start to end, inclusive.
+func rowsInRange(cs *io.ColumnSeries, start, end int64) *io.ColumnSeries {
+	var indexes []int
+	for i, t := range rowTimes(cs) {
+		if t >= start && t <= end {
+			indexes = append(indexes, i)
+		}
+	}
+	if len(indexes) == cs.Len() {
+		return cs
+	}
+	return cs.SelectRows(indexes)
+}
+
+func sendStreamResult(stream proto.Marketstore_QueryStreamServer, csm io.ColumnSeriesMap, timeframe string) error {
+	result, err := queryResult(csm, timeframe)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	if err := stream.Send(&proto.QueryResponse{Result: result}); err != nil {
+		return fmt.Errorf("failed to send the streaming query result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package frontend
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+type fakeQueryStream struct {
+	grpc.ServerStream
+	ctx       context.Context
+	responses chan *proto.QueryResponse
+}
+
+func (s *fakeQueryStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeQueryStream) Send(resp *proto.QueryResponse) error {
+	s.responses <- resp
+	return nil
+}
+
+func streamedColumnSeries(c *C, stream *fakeQueryStream) *io.ColumnSeries {
+	select {
+	case resp := <-stream.responses:
+		csm, err := ToNumpyMultiDataSet(resp.Result).ToColumnSeriesMap()
+		c.Assert(err, IsNil)
+		c.Assert(csm, HasLen, 1)
+		for _, cs := range csm {
+			return cs
+		}
+	case <-time.After(10 * time.Second):
+		c.Fatal("timed out waiting for the streaming query")
+	}
+	return nil
+}
+
+func (s *ServerTestSuite) TestQueryStream(c *C) {
+	tbk := io.NewTimeBucketKey("STREAM/1Min/OHLC")
+	t0 := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	write := func(t time.Time, close float32) {
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", []int64{t.Unix()})
+		cs.AddColumn("Open", []float32{1})
+		cs.AddColumn("High", []float32{2})
+		cs.AddColumn("Low", []float32{0.5})
+		cs.AddColumn("Close", []float32{close})
+		csm := io.NewColumnSeriesMap()
+		csm.AddColumnSeries(*tbk, cs)
+		c.Assert(executor.WriteCSM(csm, false), IsNil)
+	}
+	write(t0, 1)
+	write(t0.Add(time.Minute), 2)
+	c.Assert(executor.ThisInstance.FlushWrites(context.Background()), IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &fakeQueryStream{ctx: ctx, responses: make(chan *proto.QueryResponse, 10)}
+	done := make(chan error)
+	go func() {
+		done <- GRPCService{}.QueryStream(&proto.QueryRequest{
+			Destination: tbk.GetItemKey(),
+			Columns:     []string{"Close"},
+		}, stream)
+	}()
+
+	// the history first
+	cs := streamedColumnSeries(c, stream)
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{t0.Unix(), t0.Add(time.Minute).Unix()})
+	c.Assert(cs.GetColumnNames(), DeepEquals, []string{"Epoch", "Close"})
+
+	// then the writes, including the updates of the last bar
+	write(t0.Add(time.Minute), 3)
+	cs = streamedColumnSeries(c, stream)
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{t0.Add(time.Minute).Unix()})
+	c.Assert(cs.GetByName("Close"), DeepEquals, []float32{3})
+
+	write(t0.Add(2*time.Minute), 4)
+	cs = streamedColumnSeries(c, stream)
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{t0.Add(2 * time.Minute).Unix()})
+	c.Assert(cs.GetByName("Close"), DeepEquals, []float32{4})
+
+	cancel()
+	c.Assert(<-done, IsNil)
+	c.Assert(stream.responses, HasLen, 0)
+}
+
+func (s *ServerTestSuite) TestQueryStreamHandoff(c *C) {
+	tbk := io.NewTimeBucketKey("HANDOFF/1Min/OHLC")
+	t0 := time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC)
+	bar := func(t time.Time, close float32) *io.ColumnSeries {
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", []int64{t.Unix()})
+		cs.AddColumn("Close", []float32{close})
+		return cs
+	}
+	write := func(t time.Time, close float32) {
+		csm := io.NewColumnSeriesMap()
+		csm.AddColumnSeries(*tbk, bar(t, close))
+		c.Assert(executor.WriteCSM(csm, false), IsNil)
+	}
+	write(t0, 1)
+	write(t0.Add(time.Minute), 2)
+	c.Assert(executor.ThisInstance.FlushWrites(context.Background()), IsNil)
+
+	// an update of the last bar queued, but not committed yet, when the
+	// stream starts
+	tbi, err := executor.ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(tbk)
+	c.Assert(err, IsNil)
+	w, err := executor.NewWriter(tbi, executor.ThisInstance.TXNPipe, executor.ThisInstance.CatalogDir)
+	c.Assert(err, IsNil)
+	w.WriteRecords([]time.Time{t0.Add(time.Minute)}, bar(t0.Add(time.Minute), 3).ToRowSeries(*tbk, false).GetData(),
+		tbi.GetDataShapesWithEpoch())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &fakeQueryStream{ctx: ctx, responses: make(chan *proto.QueryResponse, 10)}
+	done := make(chan error)
+	go func() {
+		done <- GRPCService{}.QueryStream(&proto.QueryRequest{Destination: tbk.GetItemKey()}, stream)
+	}()
+
+	// is in the history, and not sent again
+	cs := streamedColumnSeries(c, stream)
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{t0.Unix(), t0.Add(time.Minute).Unix()})
+	c.Assert(cs.GetByName("Close"), DeepEquals, []float32{1, 3})
+
+	write(t0.Add(2*time.Minute), 4)
+	cs = streamedColumnSeries(c, stream)
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{t0.Add(2 * time.Minute).Unix()})
+	c.Assert(cs.GetByName("Close"), DeepEquals, []float32{4})
+
+	cancel()
+	c.Assert(<-done, IsNil)
+	c.Assert(stream.responses, HasLen, 0)
+}
+
+func (s *ServerTestSuite) TestQueryStreamVariable(c *C) {
+	tbk := io.NewTimeBucketKey("STREAM/1Sec/TRADE")
+	t0 := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	write := func(nanos []int32, prices []float32) {
+		epochs := make([]int64, len(nanos))
+		for i := range epochs {
+			epochs[i] = t0.Unix()
+		}
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", epochs)
+		cs.AddColumn("Price", prices)
+		cs.AddColumn("Nanoseconds", nanos)
+		csm := io.NewColumnSeriesMap()
+		csm.AddColumnSeries(*tbk, cs)
+		c.Assert(executor.WriteCSM(csm, true), IsNil)
+	}
+	write([]int32{100, 200}, []float32{1, 2})
+	c.Assert(executor.ThisInstance.FlushWrites(context.Background()), IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := &fakeQueryStream{ctx: ctx, responses: make(chan *proto.QueryResponse, 10)}
+	done := make(chan error)
+	go func() {
+		done <- GRPCService{}.QueryStream(&proto.QueryRequest{Destination: tbk.GetItemKey()}, stream)
+	}()
+
+	cs := streamedColumnSeries(c, stream)
+	c.Assert(cs.GetByName("Nanoseconds"), DeepEquals, []int32{100, 200})
+
+	// only the rows appended to the interval are streamed
+	write([]int32{300}, []float32{3})
+	cs = streamedColumnSeries(c, stream)
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{t0.Unix()})
+	c.Assert(cs.GetByName("Price"), DeepEquals, []float32{3})
+	c.Assert(cs.GetByName("Nanoseconds"), DeepEquals, []int32{300})
+
+	cancel()
+	c.Assert(<-done, IsNil)
+}
+
+func (s *ServerTestSuite) TestQueryStreamInvalid(c *C) {
+	stream := &fakeQueryStream{ctx: context.Background()}
+	for _, req := range []*proto.QueryRequest{
+		{Destination: "USDJPY/1Min/OHLC", Resample: "1H"},
+		{Destination: "USDJPY/1Min/OHLC", LimitFromStart: true},
+		{Destination: "*/1Min/OHLC", Symbols: []string{"USDJPY"}},
+		{Destination: "USDJPY,EURUSD/1Min/OHLC"},
+		{Destination: "USDJPY/3Min/OHLC"},
+		{IsSqlStatement: true, SqlStatement: "SELECT * FROM `USDJPY/1Min/OHLC`"},
+	} {
+		err := GRPCService{}.QueryStream(req, stream)
+		c.Assert(status.Code(err), Equals, codes.InvalidArgument, Commentf("%v", req))
+	}
+}
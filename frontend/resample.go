@@ -0,0 +1,213 @@
+package frontend
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+// ohlcvReducers are the reducers of the OHLCV columns in resampling, the same
+// as the on-disk aggregation.
+var ohlcvReducers = map[string]string{
+	"Open":   "first",
+	"High":   "max",
+	"Low":    "min",
+	"Close":  "last",
+	"Volume": "sum",
+}
+
+// resampleReducers are the reducers that may be set for the other columns.
+var resampleReducers = map[string]bool{
+	"last": true,
+	"mean": true,
+	"sum":  true,
+}
+
+// resampleWindow returns the candle duration of the resample timeframe,
+// which must be longer than the timeframe of the bucket.
+func resampleWindow(resample, timeframe string) (*utils.CandleDuration, error) {
+	window := utils.CandleDurationFromString(resample)
+	if window == nil {
+		return nil, fmt.Errorf("invalid resample timeframe %s", resample)
+	}
+	source := utils.CandleDurationFromString(timeframe)
+	if source == nil || source.Duration() >= window.Duration() {
+		return nil, fmt.Errorf("resample timeframe %s must be longer than %s", resample, timeframe)
+	}
+	return window, nil
+}
+
+// resampleRange widens [start, end] to the whole candles of the window, so that
+// the candles at the ends are aggregated from all of their records.  An open
+// end is left as is.
+func resampleRange(window *utils.CandleDuration, start, end time.Time, openStart, openEnd bool) (time.Time, time.Time) {
+	if !openStart {
+		start = window.Truncate(start)
+	}
+	if !openEnd {
+		end = window.Ceil(end).Add(-time.Nanosecond)
+	}
+	return start, end
+}
+
+// validateReducers checks the reducers of the non-OHLCV columns.
+func validateReducers(reducers map[string]string) error {
+	for column, reducer := range reducers {
+		if _, ok := ohlcvReducers[column]; ok {
+			return fmt.Errorf("the reducer of %s cannot be changed", column)
+		}
+		if !resampleReducers[reducer] {
+			return fmt.Errorf("invalid reducer %s for %s, not last, mean or sum", reducer, column)
+		}
+	}
+	return nil
+}
+
+// resample downsamples the time ordered cs into the candles of window, with
+// the OHLCV columns aggregated as the on-disk aggregation does and the others
+// by reducers, "last" by default.  The mean of an integer column is truncated
+// to an integer.  The last candle holds the records up to the end of cs, even
+// if it is incomplete.
+func resample(cs *io.ColumnSeries, window *utils.CandleDuration, reducers map[string]string) (*io.ColumnSeries, error) {
+	ts, err := cs.GetTime()
+	if err != nil {
+		return nil, err
+	}
+
+	// the boundaries of the candles
+	var starts []int
+	var epochs []int64
+	var candle time.Time
+	for i, t := range ts {
+		if i == 0 || !window.IsWithin(t, candle) {
+			candle = window.Truncate(t)
+			starts = append(starts, i)
+			epochs = append(epochs, candle.Unix())
+		}
+	}
+
+	out := io.NewColumnSeries()
+	out.AddColumn("Epoch", epochs)
+	for _, name := range cs.GetColumnNames() {
+		if name == "Epoch" || name == "Nanoseconds" {
+			continue
+		}
+		reducer, ok := ohlcvReducers[name]
+		if !ok {
+			if reducer, ok = reducers[name]; !ok {
+				reducer = "last"
+			}
+		}
+		column, err := reduce(reflect.ValueOf(cs.GetColumn(name)), starts, reducer)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", name, err)
+		}
+		out.AddColumn(name, column)
+	}
+	return out, nil
+}
+
+// reduce applies the reducer to the runs of values beginning at the starts.
+func reduce(values reflect.Value, starts []int, reducer string) (interface{}, error) {
+	out := reflect.MakeSlice(values.Type(), len(starts), len(starts))
+	kind := values.Type().Elem().Kind()
+	for j, start := range starts {
+		end := values.Len()
+		if j+1 < len(starts) {
+			end = starts[j+1]
+		}
+		dst := out.Index(j)
+		switch reducer {
+		case "first":
+			dst.Set(values.Index(start))
+			continue
+		case "last":
+			dst.Set(values.Index(end - 1))
+			continue
+		}
+
+		switch kind {
+		case reflect.Float32, reflect.Float64:
+			acc := values.Index(start).Float()
+			for i := start + 1; i < end; i++ {
+				acc = accumulate(reducer, acc, values.Index(i).Float())
+			}
+			if reducer == "mean" {
+				acc /= float64(end - start)
+			}
+			dst.SetFloat(acc)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			acc := values.Index(start).Int()
+			for i := start + 1; i < end; i++ {
+				acc = accumulateInt(reducer, acc, values.Index(i).Int())
+			}
+			if reducer == "mean" {
+				acc /= int64(end - start)
+			}
+			dst.SetInt(acc)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			acc := values.Index(start).Uint()
+			for i := start + 1; i < end; i++ {
+				acc = accumulateUint(reducer, acc, values.Index(i).Uint())
+			}
+			if reducer == "mean" {
+				acc /= uint64(end - start)
+			}
+			dst.SetUint(acc)
+		default:
+			return nil, fmt.Errorf("cannot %s values of type %s", reducer, kind)
+		}
+	}
+	return out.Interface(), nil
+}
+
+func accumulate(reducer string, acc, value float64) float64 {
+	switch reducer {
+	case "max":
+		if value > acc {
+			return value
+		}
+	case "min":
+		if value < acc {
+			return value
+		}
+	case "sum", "mean":
+		return acc + value
+	}
+	return acc
+}
+
+func accumulateInt(reducer string, acc, value int64) int64 {
+	switch reducer {
+	case "max":
+		if value > acc {
+			return value
+		}
+	case "min":
+		if value < acc {
+			return value
+		}
+	case "sum", "mean":
+		return acc + value
+	}
+	return acc
+}
+
+func accumulateUint(reducer string, acc, value uint64) uint64 {
+	switch reducer {
+	case "max":
+		if value > acc {
+			return value
+		}
+	case "min":
+		if value < acc {
+			return value
+		}
+	case "sum", "mean":
+		return acc + value
+	}
+	return acc
+}
@@ -0,0 +1,134 @@
+package frontend
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/contrib/ondiskagg/aggtrigger"
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/planner"
+	"github.com/alpacahq/marketstore/v4/plugins/trigger"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+func (s *ServerTestSuite) TestResample(c *C) {
+	// 1Min bars from 09:30 to 11:14 with a gap, so the last hour is partial
+	tbk := io.NewTimeBucketKey("RESAMPLE/1Min/OHLCV")
+	var epoch []int64
+	var open, high, low, close []float32
+	var volume []int64
+	for t := time.Date(2020, 1, 2, 9, 30, 0, 0, time.UTC); t.Hour() < 11 || t.Minute() < 15; t = t.Add(time.Minute) {
+		if t.Hour() == 10 && t.Minute() < 10 {
+			continue
+		}
+		v := float32(len(epoch) % 17)
+		epoch = append(epoch, t.Unix())
+		open = append(open, v)
+		high = append(high, v+2)
+		low = append(low, v-2)
+		close = append(close, v+1)
+		volume = append(volume, int64(len(epoch)*100))
+	}
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	cs.AddColumn("Open", open)
+	cs.AddColumn("High", high)
+	cs.AddColumn("Low", low)
+	cs.AddColumn("Close", close)
+	cs.AddColumn("Volume", volume)
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+	executor.ThisInstance.WALFile.RequestFlush()
+
+	// aggregate the same bars on disk
+	trig, err := aggtrigger.NewTrigger(map[string]interface{}{"destinations": []string{"1H"}})
+	c.Assert(err, IsNil)
+	rs := cs.ToRowSeries(*tbk, true)
+	rowData := rs.GetData()
+	times, _ := rs.GetTime()
+	rowLen := len(rowData) / len(times)
+	records := make([]trigger.Record, len(times))
+	for i := range times {
+		buf, _ := io.Serialize(nil, io.TimeToIndex(times[i], time.Minute))
+		records[i] = trigger.Record(append(buf, rowData[i*rowLen+8:(i+1)*rowLen]...))
+	}
+	trig.Fire("RESAMPLE/1Min/OHLCV/2020.bin", records)
+	executor.ThisInstance.WALFile.RequestFlush()
+
+	q := planner.NewQuery(executor.ThisInstance.CatalogDir)
+	tbk1H := io.NewTimeBucketKey("RESAMPLE/1H/OHLCV")
+	q.AddTargetKey(tbk1H)
+	q.SetRange(planner.MinTime, planner.MaxTime)
+	parsed, err := q.Parse()
+	c.Assert(err, IsNil)
+	reader, err := executor.NewReader(parsed)
+	c.Assert(err, IsNil)
+	aggCsm, err := reader.Read()
+	c.Assert(err, IsNil)
+	aggCs := aggCsm[*tbk1H]
+	c.Assert(aggCs.Len(), Equals, 3)
+
+	// the range ends within the first and last hours, which are still whole
+	service := GRPCService{}
+	resp, err := service.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{
+			Destination: tbk.GetItemKey(),
+			EpochStart:  time.Date(2020, 1, 2, 9, 45, 0, 0, time.UTC).Unix(),
+			EpochEnd:    time.Date(2020, 1, 2, 11, 5, 0, 0, time.UTC).Unix(),
+			Resample:    "1H",
+		}},
+	})
+	c.Assert(err, IsNil)
+	nmds := ToNumpyMultiDataSet(resp.Responses[0].Result)
+	c.Assert(nmds.StartIndex, HasLen, 1)
+	for key, start := range nmds.StartIndex {
+		c.Assert(key, Equals, "RESAMPLE/1H/OHLCV:Symbol/Timeframe/AttributeGroup")
+		readCs, err := nmds.ToColumnSeries(start, nmds.Lengths[key])
+		c.Assert(err, IsNil)
+		for _, name := range []string{"Epoch", "Open", "High", "Low", "Close", "Volume"} {
+			c.Check(readCs.GetColumn(name), DeepEquals, aggCs.GetColumn(name), Commentf(name))
+		}
+	}
+
+	// resample must be coarser than the bucket and exclusive of the limit
+	for _, req := range []*proto.QueryRequest{
+		{Destination: tbk.GetItemKey(), Resample: "1Min"},
+		{Destination: tbk.GetItemKey(), Resample: "1H", LimitRecordCount: 10},
+		{Destination: tbk.GetItemKey(), Resample: "1H", ResampleReducers: map[string]string{"Close": "mean"}},
+		{Destination: tbk.GetItemKey(), Resample: "1H", ResampleReducers: map[string]string{"Trades": "max"}},
+	} {
+		_, err = service.Query(context.Background(), &proto.MultiQueryRequest{Requests: []*proto.QueryRequest{req}})
+		c.Check(err, NotNil)
+	}
+}
+
+func (s *ServerTestSuite) TestResampleReducers(c *C) {
+	base := time.Date(2020, 1, 2, 9, 0, 0, 0, time.UTC)
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{
+		base.Unix(), base.Add(5 * time.Minute).Unix(),
+		base.Add(10 * time.Minute).Unix(), base.Add(12 * time.Minute).Unix(), base.Add(14 * time.Minute).Unix(),
+	})
+	cs.AddColumn("Close", []float64{1, 2, 3, 4, 5})
+	cs.AddColumn("Trades", []int32{1, 2, 3, 4, 6})
+	cs.AddColumn("Spread", []float32{1, 2, 3, 4, 5})
+	cs.AddColumn("Flag", []bool{true, false, true, true, false})
+
+	window := utils.CandleDurationFromString("10Min")
+	out, err := resample(cs, window, map[string]string{"Trades": "mean", "Spread": "sum"})
+	c.Assert(err, IsNil)
+	c.Assert(out.GetColumnNames(), DeepEquals, []string{"Epoch", "Close", "Trades", "Spread", "Flag"})
+	c.Assert(out.GetEpoch(), DeepEquals, []int64{base.Unix(), base.Add(10 * time.Minute).Unix()})
+	c.Assert(out.GetColumn("Close"), DeepEquals, []float64{2, 5})
+	c.Assert(out.GetColumn("Trades"), DeepEquals, []int32{1, 4})
+	c.Assert(out.GetColumn("Spread"), DeepEquals, []float32{3, 12})
+	c.Assert(out.GetColumn("Flag"), DeepEquals, []bool{false, false})
+
+	_, err = resample(cs, window, map[string]string{"Flag": "sum"})
+	c.Assert(err, NotNil)
+}
@@ -0,0 +1,67 @@
+package frontend
+
+import (
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/alpacahq/marketstore/v4/contrib/calendar"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils"
+)
+
+const (
+	// defaultSessionCalendar is the calendar of last_sessions if not set
+	defaultSessionCalendar = "nasdaq"
+	// maxSessions bounds last_sessions, about a century of sessions
+	maxSessions = 25000
+)
+
+// lastSessionsRange returns the range of the last sessions of req up to
+// end, now for an open end.
+func lastSessionsRange(req *proto.QueryRequest, end time.Time) (time.Time, time.Time, error) {
+	switch {
+	case req.LastSessions < 0 || req.LastSessions > maxSessions:
+		return time.Time{}, time.Time{}, status.Errorf(codes.InvalidArgument,
+			"invalid last_sessions %d, must be between 1 and %d", req.LastSessions, maxSessions)
+	case req.EpochStart != 0 || req.EpochStartNanos != 0:
+		return time.Time{}, time.Time{}, status.Errorf(codes.InvalidArgument,
+			"epoch_start cannot be used with last_sessions")
+	}
+	name := req.SessionCalendar
+	if name == "" {
+		name = defaultSessionCalendar
+	}
+	cal, err := calendar.Get(name)
+	if err != nil {
+		return time.Time{}, time.Time{}, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if req.EpochEnd == 0 {
+		end = time.Now()
+	}
+	return sessionRange(cal, int(req.LastSessions), end, utils.InstanceConfig.Timezone)
+}
+
+// sessionRange returns the range of the last n sessions of cal up to end:
+// from the start of the day of the earliest to the end of the day of the
+// latest, or to end if before, the days in tz.  The session of the day of
+// end is counted if it has opened at end, even if it is in progress.
+func sessionRange(cal calendar.MarketCalendar, n int, end time.Time, tz *time.Location) (time.Time, time.Time, error) {
+	end = end.In(tz)
+	year, month, day := end.Date()
+	latest := end
+	if open := cal.MarketOpen(end); open.IsZero() || end.Before(open) {
+		// the sessions up to the day before
+		latest = time.Date(year, month, day, 0, 0, 0, 0, tz).Add(-time.Nanosecond)
+	}
+	sessions := calendar.Sessions(cal, latest, n)
+	if len(sessions) < n {
+		return time.Time{}, time.Time{}, status.Errorf(codes.InvalidArgument,
+			"only %d sessions in the calendar before %v", len(sessions), end)
+	}
+	if last := sessions[0].AddDate(0, 0, 1).Add(-time.Nanosecond); last.Before(end) {
+		end = last
+	}
+	return sessions[n-1], end, nil
+}
@@ -0,0 +1,74 @@
+package frontend
+
+import (
+	"context"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/contrib/calendar"
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+func (s *ServerTestSuite) TestSessionRange(c *C) {
+	ny, err := time.LoadLocation("America/New_York")
+	c.Assert(err, IsNil)
+	// around Independence Day 2019, a Thursday
+	at := func(day, hour int) time.Time { return time.Date(2019, 7, day, hour, 0, 0, 0, ny) }
+
+	// the session in progress is counted, up to the end
+	start, end, err := sessionRange(calendar.Nasdaq, 3, at(8, 11), ny)
+	c.Assert(err, IsNil)
+	c.Assert(start, DeepEquals, at(3, 0))
+	c.Assert(end, DeepEquals, at(8, 11))
+
+	// the session not opened yet is not, nor the records before its open
+	for _, t := range []time.Time{at(8, 8), at(7, 12)} {
+		start, end, err = sessionRange(calendar.Nasdaq, 3, t, ny)
+		c.Assert(err, IsNil)
+		c.Assert(start, DeepEquals, at(2, 0))
+		c.Assert(end, DeepEquals, at(6, 0).Add(-time.Nanosecond))
+	}
+
+	// the days are those of the server timezone
+	start, end, err = sessionRange(calendar.Nasdaq, 2, at(8, 11), time.UTC)
+	c.Assert(err, IsNil)
+	c.Assert(start, DeepEquals, time.Date(2019, 7, 5, 0, 0, 0, 0, time.UTC))
+	c.Assert(end, DeepEquals, at(8, 11).In(time.UTC))
+}
+
+func (s *ServerTestSuite) TestQueryLastSessions(c *C) {
+	tbk := io.NewTimeBucketKey("SESSIONS/1D/OHLCV")
+	day := func(d int) time.Time { return time.Date(2019, 7, d, 0, 0, 0, 0, time.UTC) }
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, fillTestSeries(day(1), day(2), day(3), day(5), day(8)))
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+	executor.ThisInstance.WALFile.RequestFlush()
+
+	resp, err := GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{
+			Destination:     tbk.GetItemKey(),
+			EpochEnd:        day(8).Add(20 * time.Hour).Unix(),
+			LastSessions:    3,
+			SessionCalendar: "nyse",
+		}},
+	})
+	c.Assert(err, IsNil)
+	nmds := ToNumpyMultiDataSet(resp.Responses[0].Result)
+	for key, start := range nmds.StartIndex {
+		out, err := nmds.ToColumnSeries(start, nmds.Lengths[key])
+		c.Assert(err, IsNil)
+		c.Assert(out.GetEpoch(), DeepEquals, []int64{day(3).Unix(), day(5).Unix(), day(8).Unix()})
+	}
+
+	for _, req := range []*proto.QueryRequest{
+		{Destination: tbk.GetItemKey(), LastSessions: 3, EpochStart: day(1).Unix()},
+		{Destination: tbk.GetItemKey(), LastSessions: -1},
+		{Destination: tbk.GetItemKey(), LastSessions: 3, SessionCalendar: "nowhere"},
+	} {
+		_, err = GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{Requests: []*proto.QueryRequest{req}})
+		c.Assert(err, NotNil)
+	}
+}
@@ -247,6 +247,27 @@ func Initialize() {
 	go stream()
 }
 
+// Shutdown sends a close frame to all the subscribers, so that the clients
+// know the server is going away.  The connections are closed by the clients
+// in response, or by the process exit.
+func Shutdown() {
+	if catalog == nil {
+		return
+	}
+
+	catalog.RLock()
+	defer catalog.RUnlock()
+
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	for s := range catalog.subs {
+		s.Lock()
+		if err := s.c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait)); err != nil {
+			log.Error("failed to close stream (%v)", err)
+		}
+		s.Unlock()
+	}
+}
+
 // Handler hooks into the HTTP interface and handles the incoming
 // streaming requests, and upgrades the connection
 func Handler(w http.ResponseWriter, r *http.Request) {
@@ -256,6 +277,11 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 		log.Error("failed to upgrade stream socket (%s)", err)
 		return
 	}
+	// streams are long-lived, so they are exempted from
+	// the write timeout of the HTTP server.  The upgrader and
+	// the writes of the websocket clear it too, but the
+	// streams do not rely on that
+	ws.UnderlyingConn().SetWriteDeadline(time.Time{})
 
 	// build the subscriber
 	s := &Subscriber{
@@ -145,3 +145,63 @@ func genColumns() map[string]interface{} {
 		"Epoch":  int64(123456789),
 	}
 }
+
+func (s *StreamTestSuite) TestShutdown(c *C) {
+	srv := httptest.NewServer(http.HandlerFunc(Handler))
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL + "/ws")
+	u.Scheme = "ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	// wait for the subscriber to be registered
+	for i := 0; i < 100; i++ {
+		catalog.RLock()
+		n := len(catalog.subs)
+		catalog.RUnlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	Shutdown()
+
+	_, _, err = conn.ReadMessage()
+	c.Assert(websocket.IsCloseError(err, websocket.CloseGoingAway), Equals, true)
+}
+
+func (s *StreamTestSuite) TestWriteTimeout(c *C) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(Handler))
+	srv.Config.WriteTimeout = 100 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL + "/ws")
+	u.Scheme = "ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	c.Assert(err, IsNil)
+	defer conn.Close()
+
+	buf, err := msgpack.Marshal(SubscribeMessage{Streams: []string{"TSLA/1Min/OHLCV"}})
+	c.Assert(err, IsNil)
+	c.Assert(conn.WriteMessage(websocket.BinaryMessage, buf), IsNil)
+	_, _, err = conn.ReadMessage()
+	c.Assert(err, IsNil)
+
+	// the stream outlives the write timeout of the server
+	time.Sleep(3 * srv.Config.WriteTimeout)
+
+	Push(*io.NewTimeBucketKey("TSLA/1Min/OHLCV"), genColumns())
+
+	c.Assert(conn.SetReadDeadline(time.Now().Add(5*time.Second)), IsNil)
+	_, buf, err = conn.ReadMessage()
+	c.Assert(err, IsNil)
+	var payload Payload
+	c.Assert(msgpack.Unmarshal(buf, &payload), IsNil)
+	c.Assert(payload.Key, Equals, "TSLA/1Min/OHLCV")
+}
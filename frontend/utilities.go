@@ -2,11 +2,17 @@ package frontend
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync/atomic"
 	"time"
 
+	"github.com/alpacahq/marketstore/v4/executor"
 	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/log"
 )
@@ -24,22 +30,297 @@ func init() {
 	Queryable = uint32(0)
 }
 
-func Utilities(url string) {
+// Utilities returns the server for the heartbeat and profiling endpoints
+// on the url.  It has its own mux, so that these endpoints are not exposed
+// on the main listener.
+func Utilities(url string) *http.Server {
+	mux := http.NewServeMux()
+
 	// heartbeat
-	http.HandleFunc("/heartbeat", heartbeat)
+	mux.HandleFunc("/heartbeat", heartbeat)
+	mux.HandleFunc("/version", version)
+
+	// durability
+	mux.HandleFunc("/flush", flush)
+	mux.HandleFunc("/sync-status", syncStatus)
+	mux.HandleFunc("/backup", backup)
+
+	// running queries
+	mux.HandleFunc("/queries", listQueries)
+	mux.HandleFunc("/queries/cancel", cancelQuery)
+
+	// failed trigger invocations
+	mux.HandleFunc("/trigger-deadletters", listDeadLetters)
+	mux.HandleFunc("/trigger-deadletters/redrive", redriveDeadLetters)
 
 	// profiling
-	http.HandleFunc("/pprof/", pprof.Index)
-	http.HandleFunc("/pprof/cmdline", pprof.Cmdline)
-	http.HandleFunc("/pprof/profile", pprof.Profile)
-	http.HandleFunc("/pprof/symbol", pprof.Symbol)
-	http.HandleFunc("/pprof/trace", pprof.Trace)
-	http.Handle("/pprof/heap", pprof.Handler("heap"))
-	http.Handle("/pprof/goroutine", pprof.Handler("goroutine"))
-	http.Handle("/pprof/threadcreate", pprof.Handler("threadcreate"))
-	http.Handle("/pprof/block", pprof.Handler("block"))
+	mux.HandleFunc("/pprof/", pprof.Index)
+	mux.HandleFunc("/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/pprof/trace", pprof.Trace)
+	mux.Handle("/pprof/heap", pprof.Handler("heap"))
+	mux.Handle("/pprof/goroutine", pprof.Handler("goroutine"))
+	mux.Handle("/pprof/threadcreate", pprof.Handler("threadcreate"))
+	mux.Handle("/pprof/block", pprof.Handler("block"))
+
+	return &http.Server{Addr: url, Handler: mux}
+}
+
+// SyncStatusMessage is the durability status of the writes.
+type SyncStatusMessage struct {
+	LastFlush      time.Time `json:"last_flush"`
+	LastCheckpoint time.Time `json:"last_checkpoint"`
+	PendingBytes   int64     `json:"pending_bytes"`
+}
+
+func getSyncStatus() SyncStatusMessage {
+	return SyncStatusMessage{
+		LastFlush:      executor.ThisInstance.WALFile.LastFlush(),
+		LastCheckpoint: executor.ThisInstance.WALFile.LastCheckpoint(),
+		PendingBytes:   executor.ThisInstance.TXNPipe.PendingBytes(),
+	}
+}
+
+// flush makes the data written so far durable on disk, and returns
+// the sync status once done, e.g. before taking a filesystem snapshot.
+func flush(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := executor.ThisInstance.WALFile.Sync(r.Context()); err != nil {
+		log.Error("Failed to flush - Error: %v", err)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(getSyncStatus()); err != nil {
+		log.Error("Failed to write sync status - Error: %v", err)
+	}
+}
+
+// BackupMessage is the result of a backup.
+type BackupMessage struct {
+	Out string `json:"out"`
+}
+
+// backup makes a consistent copy of the root directory to the directory
+// given by the out parameter while the server is running, which must be in
+// the backup_directory of the config, the backups being disabled without it.
+// See executor.InstanceMetadata.Backup for the details.
+func backup(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if utils.InstanceConfig.BackupDirectory == "" {
+		http.Error(rw, "backups are disabled, backup_directory is not set", http.StatusForbidden)
+		return
+	}
+	out := r.URL.Query().Get("out")
+	if out == "" {
+		http.Error(rw, "out parameter is required", http.StatusBadRequest)
+		return
+	}
+	out, err := backupPath(utils.InstanceConfig.BackupDirectory, out)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := executor.ThisInstance.Backup(r.Context(), out); err != nil {
+		log.Error("Failed to back up - Error: %v", err)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(BackupMessage{Out: out}); err != nil {
+		log.Error("Failed to write backup message - Error: %v", err)
+	}
+}
 
-	http.ListenAndServe(url, nil)
+// backupPath returns the path of the backup directory out, relative to dir
+// unless absolute, with the symbolic links resolved, or an error if it is
+// not in dir.
+func backupPath(dir, out string) (string, error) {
+	if !filepath.IsAbs(out) {
+		out = filepath.Join(dir, out)
+	}
+	dir, err := resolvePath(dir)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := resolvePath(out)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(dir, resolved)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("backup directory %s must be in the backup_directory %s", out, dir)
+	}
+	return resolved, nil
+}
+
+// resolvePath returns the absolute path with the symbolic links of its
+// existing part resolved.
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	switch {
+	case err == nil:
+		return resolved, nil
+	case !os.IsNotExist(err) || filepath.Dir(path) == path:
+		return "", err
+	}
+	parent, err := resolvePath(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(parent, filepath.Base(path)), nil
+}
+
+// DeadLettersMessage is the trigger invocations that failed, without the
+// records they were fired on.
+type DeadLettersMessage struct {
+	DeadLetters []*executor.DeadLetter `json:"dead_letters"`
+}
+
+func newDeadLettersMessage(letters []*executor.DeadLetter) DeadLettersMessage {
+	msg := DeadLettersMessage{DeadLetters: []*executor.DeadLetter{}}
+	for _, dl := range letters {
+		summary := *dl
+		summary.Records = nil
+		msg.DeadLetters = append(msg.DeadLetters, &summary)
+	}
+	return msg
+}
+
+func listDeadLetters(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	letters, err := executor.ThisInstance.DeadLetters.List()
+	if err != nil {
+		log.Error("Failed to list the dead letters - Error: %v", err)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(newDeadLettersMessage(letters)); err != nil {
+		log.Error("Failed to write dead letters - Error: %v", err)
+	}
+}
+
+// redriveDeadLetters fires the triggers again on the dead letters given by the
+// id parameters, or on all of them if none is given, and returns the ones that
+// failed again.
+func redriveDeadLetters(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var ids []int64
+	for _, param := range r.URL.Query()["id"] {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			http.Error(rw, fmt.Sprintf("invalid id %q", param), http.StatusBadRequest)
+			return
+		}
+		ids = append(ids, id)
+	}
+	failed, err := executor.ThisInstance.DeadLetters.Redrive(executor.ThisInstance.TriggerMatchers, ids...)
+	if err != nil {
+		log.Error("Failed to redrive the dead letters - Error: %v", err)
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(newDeadLettersMessage(failed)); err != nil {
+		log.Error("Failed to write dead letters - Error: %v", err)
+	}
+}
+
+// RunningQueryMessage is a running query, of the bucket key over the range
+// from start to end, requested by the client at peer.
+type RunningQueryMessage struct {
+	ID       uint64    `json:"id"`
+	Key      string    `json:"key"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Peer     string    `json:"peer"`
+	Started  time.Time `json:"started"`
+	Duration string    `json:"duration"`
+}
+
+// RunningQueriesMessage is the running queries, the longest running first.
+type RunningQueriesMessage struct {
+	Queries []RunningQueryMessage `json:"queries"`
+}
+
+func listQueries(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	msg := RunningQueriesMessage{Queries: []RunningQueryMessage{}}
+	for _, q := range executor.RunningQueries() {
+		msg.Queries = append(msg.Queries, RunningQueryMessage{
+			ID:       q.ID,
+			Key:      q.Key,
+			Start:    q.Start,
+			End:      q.End,
+			Peer:     q.Peer,
+			Started:  q.Started,
+			Duration: q.Duration().String(),
+		})
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(msg); err != nil {
+		log.Error("Failed to write running queries - Error: %v", err)
+	}
+}
+
+// cancelQuery cancels the running query given by the id parameter, which
+// then fails with a Canceled error.
+func cancelQuery(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		rw.Header().Set("Allow", http.MethodPost)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	param := r.URL.Query().Get("id")
+	id, err := strconv.ParseUint(param, 10, 64)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("invalid id %q", param), http.StatusBadRequest)
+		return
+	}
+	if !executor.CancelQuery(id) {
+		http.Error(rw, fmt.Sprintf("no running query %d", id), http.StatusNotFound)
+		return
+	}
+	log.Info("canceled the query %d", id)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func syncStatus(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		http.Error(rw, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(getSyncStatus()); err != nil {
+		log.Error("Failed to write sync status - Error: %v", err)
+	}
 }
 
 func heartbeat(rw http.ResponseWriter, r *http.Request) {
@@ -71,3 +352,34 @@ func heartbeat(rw http.ResponseWriter, r *http.Request) {
 		}
 	}
 }
+
+// version returns the version info of the build of the server.
+func version(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(utils.GetVersionInfo()); err != nil {
+		log.Error("Failed to write version info - Error: %v", err)
+	}
+}
+
+// Healthz is the liveness probe, which succeeds as long as the process
+// is able to serve HTTP.
+func Healthz(rw http.ResponseWriter, r *http.Request) {
+	rw.WriteHeader(http.StatusOK)
+	rw.Write([]byte("ok\n"))
+}
+
+// Readyz is the readiness probe, which succeeds only once the WAL is replayed
+// and the instance is queryable.  It fails again during the graceful shutdown.
+func Readyz(rw http.ResponseWriter, r *http.Request) {
+	switch {
+	case !executor.SetupComplete():
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		rw.Write([]byte("initializing\n"))
+	case atomic.LoadUint32(&Queryable) == 0:
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		rw.Write([]byte("not queryable\n"))
+	default:
+		rw.WriteHeader(http.StatusOK)
+		rw.Write([]byte("ok\n"))
+	}
+}
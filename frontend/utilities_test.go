@@ -1,12 +1,25 @@
 package frontend
 
 import (
+	"context"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"sync/atomic"
+	"time"
 
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/proto"
 	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	. "gopkg.in/check.v1"
 )
 
@@ -65,3 +78,235 @@ func (s *HeartbeatTestSuite) TestHandler(c *C) {
 		}
 	}
 }
+
+func (s *HeartbeatTestSuite) TestProbes(c *C) {
+	executor.NewInstanceSetup(c.MkDir(), true, true, false, false)
+	defer atomic.StoreUint32(&Queryable, uint32(0))
+
+	rec := httptest.NewRecorder()
+	Healthz(rec, nil)
+	c.Assert(rec.Code, Equals, http.StatusOK)
+
+	// not ready until queryable
+	atomic.StoreUint32(&Queryable, uint32(0))
+	rec = httptest.NewRecorder()
+	Readyz(rec, nil)
+	c.Assert(rec.Code, Equals, http.StatusServiceUnavailable)
+	c.Assert(rec.Body.String(), Equals, "not queryable\n")
+
+	atomic.StoreUint32(&Queryable, uint32(1))
+	rec = httptest.NewRecorder()
+	Readyz(rec, nil)
+	c.Assert(rec.Code, Equals, http.StatusOK)
+
+	// the liveness does not depend on the readiness
+	atomic.StoreUint32(&Queryable, uint32(0))
+	rec = httptest.NewRecorder()
+	Healthz(rec, nil)
+	c.Assert(rec.Code, Equals, http.StatusOK)
+}
+
+func (s *HeartbeatTestSuite) TestRegisterPprof(c *C) {
+	mux := http.NewServeMux()
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	c.Assert(rec.Code, Equals, http.StatusNotFound)
+
+	RegisterPprof(mux)
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/heap", nil))
+	c.Assert(rec.Code, Equals, http.StatusOK)
+
+	// not exposed by the utilities server on the default mux
+	rec = httptest.NewRecorder()
+	Utilities("localhost:0").Handler.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	c.Assert(rec.Code, Equals, http.StatusNotFound)
+}
+
+func (s *ServerTestSuite) TestFlush(c *C) {
+	tbk := io.NewTimeBucketKey("FLUSH/1Min/OHLC")
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC).Unix()})
+	cs.AddColumn("Open", []float32{1})
+	cs.AddColumn("High", []float32{2})
+	cs.AddColumn("Low", []float32{0.5})
+	cs.AddColumn("Close", []float32{1.5})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+
+	handler := Utilities("localhost:0").Handler
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/flush", nil))
+	c.Assert(rec.Code, Equals, http.StatusMethodNotAllowed)
+
+	before := time.Now()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/flush", nil))
+	c.Assert(rec.Code, Equals, http.StatusOK)
+	status := SyncStatusMessage{}
+	c.Assert(json.NewDecoder(rec.Body).Decode(&status), IsNil)
+	c.Assert(status.PendingBytes, Equals, int64(0))
+	c.Assert(status.LastFlush.IsZero(), Equals, false)
+	c.Assert(status.LastCheckpoint.Before(before), Equals, false)
+
+	// idempotent
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/flush", nil))
+	c.Assert(rec.Code, Equals, http.StatusOK)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/sync-status", nil))
+	c.Assert(rec.Code, Equals, http.StatusOK)
+	c.Assert(json.NewDecoder(rec.Body).Decode(&status), IsNil)
+	c.Assert(status.PendingBytes, Equals, int64(0))
+}
+
+func (s *ServerTestSuite) TestBackup(c *C) {
+	tbk := io.NewTimeBucketKey("BACKUP/1Min/OHLC")
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{time.Date(2020, 1, 2, 3, 4, 0, 0, time.UTC).Unix()})
+	cs.AddColumn("Open", []float32{1})
+	cs.AddColumn("High", []float32{2})
+	cs.AddColumn("Low", []float32{0.5})
+	cs.AddColumn("Close", []float32{1.5})
+	csm := io.NewColumnSeriesMap()
+	csm.AddColumnSeries(*tbk, cs)
+	c.Assert(executor.WriteCSM(csm, false), IsNil)
+
+	handler := Utilities("localhost:0").Handler
+	backupDir := c.MkDir()
+	out := filepath.Join(backupDir, "backup")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/backup?out="+out, nil))
+	c.Assert(rec.Code, Equals, http.StatusMethodNotAllowed)
+
+	// disabled without a backup directory
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/backup?out="+out, nil))
+	c.Assert(rec.Code, Equals, http.StatusForbidden)
+
+	utils.InstanceConfig.BackupDirectory = backupDir
+	defer func() { utils.InstanceConfig.BackupDirectory = "" }()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/backup", nil))
+	c.Assert(rec.Code, Equals, http.StatusBadRequest)
+
+	// out of the backup directory
+	outside := filepath.Join(c.MkDir(), "backup")
+	c.Assert(os.Symlink(filepath.Dir(outside), filepath.Join(backupDir, "link")), IsNil)
+	for _, out := range []string{outside, "../backup", backupDir, filepath.Join(backupDir, "link", "backup")} {
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/backup?out="+url.QueryEscape(out), nil))
+		c.Check(rec.Code, Equals, http.StatusBadRequest, Commentf("%s", out))
+		c.Check(rec.Body.String(), Matches, "backup directory .* must be in the backup_directory .*\n")
+	}
+	_, err := os.Stat(outside)
+	c.Assert(os.IsNotExist(err), Equals, true)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/backup?out="+out, nil))
+	c.Assert(rec.Code, Equals, http.StatusOK)
+
+	// the pending write is in the backup
+	orig, err := ioutil.ReadFile(filepath.Join(s.Rootdir, "BACKUP", "1Min", "OHLC", "2020.bin"))
+	c.Assert(err, IsNil)
+	copied, err := ioutil.ReadFile(filepath.Join(out, "BACKUP", "1Min", "OHLC", "2020.bin"))
+	c.Assert(err, IsNil)
+	c.Assert(copied, DeepEquals, orig)
+	walFiles, err := filepath.Glob(filepath.Join(out, "*.walfile"))
+	c.Assert(err, IsNil)
+	c.Assert(walFiles, HasLen, 0)
+
+	// not empty
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/backup?out="+out, nil))
+	c.Assert(rec.Code, Equals, http.StatusInternalServerError)
+
+	// relative to the backup directory
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/backup?out=daily", nil))
+	c.Assert(rec.Code, Equals, http.StatusOK)
+	_, err = os.Stat(filepath.Join(backupDir, "daily", "BACKUP", "1Min", "OHLC", "2020.bin"))
+	c.Assert(err, IsNil)
+
+	// in the root directory
+	utils.InstanceConfig.BackupDirectory = s.Rootdir
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/backup?out="+filepath.Join(s.Rootdir, "backup"), nil))
+	c.Assert(rec.Code, Equals, http.StatusInternalServerError)
+}
+
+func (s *ServerTestSuite) TestRunningQueries(c *C) {
+	handler := Utilities("localhost:0").Handler
+	list := func() RunningQueriesMessage {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/queries", nil))
+		c.Assert(rec.Code, Equals, http.StatusOK)
+		var msg RunningQueriesMessage
+		c.Assert(json.NewDecoder(rec.Body).Decode(&msg), IsNil)
+		return msg
+	}
+	cancel := func(id string) int {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/queries/cancel?id="+id, nil))
+		return rec.Code
+	}
+
+	start := time.Date(2002, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2003, 1, 1, 0, 0, 0, 0, time.UTC)
+	ctx, done := executor.StartQuery(context.Background(), "USDJPY/1Min/OHLC", start, end, "10.0.0.1:1234")
+	msg := list()
+	c.Assert(msg.Queries, HasLen, 1)
+	q := msg.Queries[0]
+	c.Assert(q.Key, Equals, "USDJPY/1Min/OHLC")
+	c.Assert(q.Start.Equal(start), Equals, true)
+	c.Assert(q.End.Equal(end), Equals, true)
+	c.Assert(q.Peer, Equals, "10.0.0.1:1234")
+	c.Assert(q.Duration, Not(Equals), "")
+
+	c.Assert(cancel("x"), Equals, http.StatusBadRequest)
+	c.Assert(cancel(strconv.FormatUint(q.ID, 10)), Equals, http.StatusNoContent)
+	c.Assert(ctx.Err(), Equals, context.Canceled)
+	done()
+	c.Assert(list().Queries, HasLen, 0)
+	c.Assert(cancel(strconv.FormatUint(q.ID, 10)), Equals, http.StatusNotFound)
+
+	// a canceled query fails rather than returning partial results
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	cancelCtx()
+	_, err := executeQuery(ctx, io.NewTimeBucketKey("USDJPY/1Min/OHLC"), start, end, 0, false, nil)
+	c.Assert(status.Code(err), Equals, codes.Canceled)
+	c.Assert(list().Queries, HasLen, 0)
+}
+
+func (s *HeartbeatTestSuite) TestVersion(c *C) {
+	defer func(tag, hash, stamp string) {
+		utils.Tag, utils.GitHash, utils.BuildStamp = tag, hash, stamp
+	}(utils.Tag, utils.GitHash, utils.BuildStamp)
+	utils.Tag, utils.GitHash, utils.BuildStamp = "v4.1.0", "0123abcd", "2021-01-02-15-04-05"
+
+	rec := httptest.NewRecorder()
+	version(rec, nil)
+	c.Assert(rec.Code, Equals, http.StatusOK)
+	var info utils.VersionInfo
+	c.Assert(json.NewDecoder(rec.Body).Decode(&info), IsNil)
+	c.Assert(info, Equals, utils.VersionInfo{
+		Version:   "v4.1.0",
+		GitHash:   "0123abcd",
+		BuildTime: "2021-01-02-15-04-05",
+		GoVersion: runtime.Version(),
+	})
+
+	resp, err := GRPCService{}.ServerVersion(context.Background(), &proto.ServerVersionRequest{})
+	c.Assert(err, IsNil)
+	// the commit, for the clients of the version before the other fields
+	c.Assert(resp.Version, Equals, "0123abcd")
+	c.Assert(resp.Tag, Equals, "v4.1.0")
+	c.Assert(resp.GitHash, Equals, "0123abcd")
+	c.Assert(resp.BuildTime, Equals, "2021-01-02-15-04-05")
+	c.Assert(resp.GoVersion, Equals, runtime.Version())
+}
@@ -0,0 +1,211 @@
+package frontend
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+// validateWrite checks the column series of a write request before they are
+// written, so that bad data is rejected with a clear error rather than
+// corrupting the buckets and their aggregates.  The timeframe of each key
+// must be well-formed, and the columns must match the schema of the bucket
+// if it exists.  The timestamps must be in order, and unique unless the
+// records are of variable length.  If strict, the ones that are not are
+// rejected, otherwise they are sorted and deduplicated in place of the
+// column series, keeping the last record of a duplicate timestamp.  If
+// lateWindow is not zero, the records more than lateWindow before the latest
+// one of the bucket are rejected if strict, and dropped otherwise, returning
+// a *lateRecordsError of them along with the rest of the column series.
+func validateWrite(csm io.ColumnSeriesMap, isVariableLength, strict bool, lateWindow time.Duration) error {
+	late := &lateRecordsError{}
+	for tbk, cs := range csm {
+		key := tbk
+		if _, err := key.GetTimeFrame(); err != nil {
+			return fmt.Errorf("%s: invalid timeframe %q", key.GetItemKey(), key.GetItemInCategory("Timeframe"))
+		}
+		if err := validateColumns(&key, cs, isVariableLength); err != nil {
+			return fmt.Errorf("%s: %v", key.GetItemKey(), err)
+		}
+		ordered, err := orderRows(cs, isVariableLength, strict)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key.GetItemKey(), err)
+		}
+		if lateWindow > 0 {
+			if ordered, err = dropLateRows(&key, ordered, strict, lateWindow, late); err != nil {
+				return fmt.Errorf("%s: %v", key.GetItemKey(), err)
+			}
+		}
+		csm[key] = ordered
+	}
+	if len(late.buckets) > 0 {
+		return late
+	}
+	return nil
+}
+
+// lateRecordsError reports the records dropped from a write as late, by
+// bucket, which the rest of the write is not rejected for.
+type lateRecordsError struct {
+	buckets []string
+}
+
+func (e *lateRecordsError) Error() string {
+	return strings.Join(e.buckets, "; ")
+}
+
+// maxLateTimestamps is the number of timestamps of the dropped records
+// listed in a lateRecordsError, by bucket.
+const maxLateTimestamps = 10
+
+// isLateRecords returns true if err only reports the late records dropped
+// from a write.
+func isLateRecords(err error) bool {
+	_, ok := err.(*lateRecordsError)
+	return ok
+}
+
+// dropLateRows returns the rows of the time ordered cs that are at most
+// window before the latest record of the bucket, adding the other ones to
+// late, or an error if strict and some are not.
+func dropLateRows(tbk *io.TimeBucketKey, cs *io.ColumnSeries, strict bool, window time.Duration,
+	late *lateRecordsError) (*io.ColumnSeries, error) {
+	tail, err := executor.BucketTail(tbk)
+	if err != nil || tail.IsZero() {
+		return cs, err
+	}
+	cutoff := tail.Add(-window)
+	times, err := cs.GetTime()
+	if err != nil {
+		return nil, err
+	}
+	// the late rows are the first ones
+	n := sort.Search(len(times), func(i int) bool { return !times[i].Before(cutoff) })
+	if n == 0 {
+		return cs, nil
+	}
+	if strict {
+		return nil, fmt.Errorf("timestamp %v at row 0 is more than %v before the latest one %v",
+			times[0].UTC(), window, tail.UTC())
+	}
+	log.Warn("%s: dropping %d record(s) more than %v before the latest one %v",
+		tbk.GetItemKey(), n, window, tail.UTC())
+	metrics.WriteLateRecords.Add(float64(n))
+	dropped := make([]string, 0, maxLateTimestamps+1)
+	for i := 0; i < n && i < maxLateTimestamps; i++ {
+		dropped = append(dropped, times[i].UTC().Format(time.RFC3339Nano))
+	}
+	if n > maxLateTimestamps {
+		dropped = append(dropped, fmt.Sprintf("and %d more", n-maxLateTimestamps))
+	}
+	late.buckets = append(late.buckets, fmt.Sprintf("%s: dropped %d record(s) more than %v before the latest one %v: %s",
+		tbk.GetItemKey(), n, window, tail.UTC(), strings.Join(dropped, ", ")))
+	indexes := make([]int, 0, len(times)-n)
+	for i := n; i < len(times); i++ {
+		indexes = append(indexes, i)
+	}
+	return cs.SelectRows(indexes), nil
+}
+
+// validateColumns checks the columns of cs against the schema of the
+// bucket, if it exists.  Float columns are accepted for the scaled
+// integer ones, which they are converted to on write.
+func validateColumns(tbk *io.TimeBucketKey, cs *io.ColumnSeries, isVariableLength bool) error {
+	if epoch, ok := cs.GetColumn("Epoch").([]int64); !ok || epoch == nil {
+		return fmt.Errorf("missing Epoch column of type INT64")
+	}
+
+	tbi, err := executor.ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(tbk)
+	if err != nil {
+		// a new bucket is created with the columns written
+		return nil
+	}
+
+	expected := map[string]bool{"Epoch": true}
+	names := tbi.GetElementNames()
+	types := tbi.GetElementTypes()
+	scales := tbi.GetElementScales()
+	for i, name := range names {
+		expected[name] = true
+		column := cs.GetColumn(name)
+		if column == nil {
+			return fmt.Errorf("missing column %s of type %v", name, types[i])
+		}
+		typ := io.GetElementType(column)
+		if scales[i] != 0 && (typ == io.FLOAT32 || typ == io.FLOAT64) {
+			continue
+		}
+		if typ != types[i] {
+			return fmt.Errorf("column %s is of type %v, but %v in the bucket", name, typ, types[i])
+		}
+	}
+	for _, name := range cs.GetColumnNames() {
+		if isVariableLength && name == "Nanoseconds" {
+			continue
+		}
+		if !expected[name] {
+			return fmt.Errorf("column %s is not in the bucket", name)
+		}
+	}
+	return nil
+}
+
+// orderRows returns cs if its timestamps are in order and unique, or else
+// an error if strict, or the column series sorted and deduplicated if not.
+func orderRows(cs *io.ColumnSeries, isVariableLength, strict bool) (*io.ColumnSeries, error) {
+	epoch := cs.GetEpoch()
+	var nanos []int32
+	if isVariableLength {
+		nanos, _ = cs.GetColumn("Nanoseconds").([]int32)
+	}
+	less := func(i, j int) bool {
+		if epoch[i] != epoch[j] || nanos == nil {
+			return epoch[i] < epoch[j]
+		}
+		return nanos[i] < nanos[j]
+	}
+
+	ordered := true
+	for i := 1; i < len(epoch); i++ {
+		switch {
+		case less(i, i-1):
+			if strict {
+				return nil, fmt.Errorf("timestamp %v at row %d is before the previous one",
+					time.Unix(epoch[i], 0).UTC(), i)
+			}
+			ordered = false
+		case !isVariableLength && epoch[i] == epoch[i-1]:
+			if strict {
+				return nil, fmt.Errorf("duplicate timestamp %v at row %d",
+					time.Unix(epoch[i], 0).UTC(), i)
+			}
+			ordered = false
+		}
+	}
+	if ordered {
+		return cs, nil
+	}
+
+	indexes := make([]int, len(epoch))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool { return less(indexes[i], indexes[j]) })
+	if !isVariableLength {
+		unique := indexes[:0]
+		for i, index := range indexes {
+			if i+1 < len(indexes) && epoch[indexes[i+1]] == epoch[index] {
+				continue
+			}
+			unique = append(unique, index)
+		}
+		indexes = unique
+	}
+	return cs.SelectRows(indexes), nil
+}
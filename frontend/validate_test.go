@@ -0,0 +1,189 @@
+package frontend
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/proto"
+	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io"
+)
+
+func ohlcWriteRequest(c *C, key string, epoch []int64, columns map[string]interface{}) *proto.WriteRequest {
+	cs := io.NewColumnSeries()
+	cs.AddColumn("Epoch", epoch)
+	for _, name := range []string{"Open", "High", "Low", "Close", "Volume"} {
+		if column, ok := columns[name]; ok {
+			cs.AddColumn(name, column)
+		}
+	}
+	nds, err := io.NewNumpyDataset(cs)
+	c.Assert(err, IsNil)
+	nmds, err := io.NewNumpyMultiDataset(nds, *io.NewTimeBucketKey(key))
+	c.Assert(err, IsNil)
+	return &proto.WriteRequest{Data: ToProtoNumpyMultiDataSet(nmds)}
+}
+
+func float32s(values ...float32) map[string]interface{} {
+	return map[string]interface{}{"Open": values, "High": values, "Low": values, "Close": values}
+}
+
+func (s *ServerTestSuite) TestWriteValidation(c *C) {
+	defer func() { utils.InstanceConfig.StrictWrites = false }()
+
+	t0 := time.Date(2003, 1, 2, 0, 0, 0, 0, time.UTC).Unix()
+	write := func(reqs ...*proto.WriteRequest) error {
+		_, err := GRPCService{}.Write(context.Background(), &proto.MultiWriteRequest{Requests: reqs})
+		return err
+	}
+	assertInvalid := func(err error, message string) {
+		c.Assert(status.Code(err), Equals, codes.InvalidArgument)
+		c.Assert(status.Convert(err).Message(), Matches, message)
+	}
+
+	// the timeframe is malformed
+	err := write(ohlcWriteRequest(c, "VALID/1Foo/OHLC", []int64{t0}, float32s(1)))
+	assertInvalid(err, `write request 0: VALID/1Foo/OHLC: invalid timeframe "1Foo"`)
+
+	// the columns do not match the schema of USDJPY/1Min/OHLC
+	columns := float32s(1)
+	delete(columns, "Close")
+	err = write(ohlcWriteRequest(c, "USDJPY/1Min/OHLC", []int64{t0}, columns))
+	assertInvalid(err, `.*USDJPY/1Min/OHLC: missing column Close of type FLOAT32`)
+
+	columns = float32s(1)
+	columns["Close"] = []float64{1}
+	err = write(ohlcWriteRequest(c, "USDJPY/1Min/OHLC", []int64{t0}, columns))
+	assertInvalid(err, `.*column Close is of type FLOAT64, but FLOAT32 in the bucket`)
+
+	columns = float32s(1)
+	columns["Volume"] = []int32{1}
+	err = write(ohlcWriteRequest(c, "USDJPY/1Min/OHLC", []int64{t0}, columns))
+	assertInvalid(err, `.*column Volume is not in the bucket`)
+
+	// out-of-order and duplicate timestamps are sorted and deduplicated
+	err = write(ohlcWriteRequest(c, "LENIENT/1Min/OHLC", []int64{t0 + 120, t0, t0 + 60, t0}, float32s(1, 2, 3, 4)))
+	c.Assert(err, IsNil)
+	resp, err := GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+		Requests: []*proto.QueryRequest{{Destination: "LENIENT/1Min/OHLC"}},
+	})
+	c.Assert(err, IsNil)
+	csm, err := ToNumpyMultiDataSet(resp.Responses[0].Result).ToColumnSeriesMap()
+	c.Assert(err, IsNil)
+	cs := csm[*io.NewTimeBucketKey("LENIENT/1Min/OHLC")]
+	c.Assert(cs, NotNil)
+	c.Assert(cs.GetEpoch(), DeepEquals, []int64{t0, t0 + 60, t0 + 120})
+	c.Assert(cs.GetColumn("Close"), DeepEquals, []float32{4, 3, 1})
+
+	// or rejected if strict, along with the valid requests of the batch
+	utils.InstanceConfig.StrictWrites = true
+	err = write(
+		ohlcWriteRequest(c, "STRICT/1Min/OHLC", []int64{t0}, float32s(1)),
+		ohlcWriteRequest(c, "STRICT/1Min/OHLC", []int64{t0 + 120, t0 + 60}, float32s(2, 3)),
+	)
+	assertInvalid(err, `write request 1: STRICT/1Min/OHLC: timestamp 2003-01-02 00:01:00 \+0000 UTC at row 1 is before the previous one`)
+	err = write(ohlcWriteRequest(c, "STRICT/1Min/OHLC", []int64{t0, t0}, float32s(1, 2)))
+	assertInvalid(err, `.*duplicate timestamp 2003-01-02 00:00:00 \+0000 UTC at row 1`)
+	_, err = executor.ThisInstance.CatalogDir.GetLatestTimeBucketInfoFromKey(io.NewTimeBucketKey("STRICT/1Min/OHLC"))
+	c.Assert(err, NotNil)
+}
+
+func (s *ServerTestSuite) TestWriteLateData(c *C) {
+	utils.InstanceConfig.LateDataWindow = 5 * time.Second
+	defer func() {
+		utils.InstanceConfig.StrictWrites = false
+		utils.InstanceConfig.LateDataWindow = 0
+	}()
+
+	t0 := time.Date(2003, 1, 3, 10, 0, 0, 0, time.UTC)
+	write := func(times []time.Time, price ...float32) error {
+		epoch := make([]int64, len(times))
+		nanos := make([]int32, len(times))
+		for i, t := range times {
+			epoch[i] = t.Unix()
+			nanos[i] = int32(t.Nanosecond())
+		}
+		cs := io.NewColumnSeries()
+		cs.AddColumn("Epoch", epoch)
+		cs.AddColumn("Price", price)
+		cs.AddColumn("Nanoseconds", nanos)
+		nds, err := io.NewNumpyDataset(cs)
+		c.Assert(err, IsNil)
+		nmds, err := io.NewNumpyMultiDataset(nds, *io.NewTimeBucketKey("LATE/1Sec/TRADE"))
+		c.Assert(err, IsNil)
+		resp, err := GRPCService{}.Write(context.Background(), &proto.MultiWriteRequest{
+			Requests: []*proto.WriteRequest{{Data: ToProtoNumpyMultiDataSet(nmds), IsVariableLength: true}},
+		})
+		if err != nil {
+			return err
+		}
+		if len(resp.Responses) > 0 {
+			return errors.New(resp.Responses[0].Error)
+		}
+		return nil
+	}
+	read := func() *io.ColumnSeries {
+		resp, err := GRPCService{}.Query(context.Background(), &proto.MultiQueryRequest{
+			Requests: []*proto.QueryRequest{{Destination: "LATE/1Sec/TRADE"}},
+		})
+		c.Assert(err, IsNil)
+		csm, err := ToNumpyMultiDataSet(resp.Responses[0].Result).ToColumnSeriesMap()
+		c.Assert(err, IsNil)
+		return csm[*io.NewTimeBucketKey("LATE/1Sec/TRADE")]
+	}
+
+	c.Assert(write([]time.Time{t0.Add(10 * time.Second), t0.Add(10*time.Second + 500*time.Millisecond)}, 1, 2), IsNil)
+
+	// the records within the window are stored in order, within the same
+	// second too
+	c.Assert(write([]time.Time{t0.Add(10*time.Second + 200*time.Millisecond), t0.Add(6 * time.Second)}, 3, 4), IsNil)
+	cs := read()
+	c.Assert(cs.GetColumn("Price"), DeepEquals, []float32{4, 1, 3, 2})
+
+	// and the ones behind it are dropped, reported to the caller
+	dropped := testutil.ToFloat64(metrics.WriteLateRecords)
+	c.Assert(write([]time.Time{t0.Add(4 * time.Second), t0.Add(11 * time.Second)}, 5, 6), ErrorMatches,
+		`LATE/1Sec/TRADE: dropped 1 record\(s\) more than 5s before the latest one 2003-01-03 10:00:10.5 \+0000 UTC: `+
+			`2003-01-03T10:00:04Z`)
+	c.Assert(write([]time.Time{t0}, 7), ErrorMatches, `LATE/1Sec/TRADE: dropped 1 record\(s\) .*: 2003-01-03T10:00:00Z`)
+	cs = read()
+	c.Assert(cs.GetColumn("Price"), DeepEquals, []float32{4, 1, 3, 2, 6})
+	c.Assert(testutil.ToFloat64(metrics.WriteLateRecords)-dropped, Equals, float64(2))
+
+	// or rejected if strict
+	utils.InstanceConfig.StrictWrites = true
+	err := write([]time.Time{t0.Add(5 * time.Second), t0.Add(12 * time.Second)}, 8, 9)
+	c.Assert(err, ErrorMatches, `.*write request 0: LATE/1Sec/TRADE: timestamp 2003-01-03 10:00:05 \+0000 UTC at row 0 is more than 5s `+
+		`before the latest one 2003-01-03 10:00:11 \+0000 UTC`)
+	c.Assert(read().Len(), Equals, 5)
+
+	// the latest records deleted, the ones behind them are not late any more
+	deleted, err := GRPCService{}.Delete(context.Background(), &proto.MultiDeleteRequest{
+		Requests: []*proto.DeleteRequest{{
+			Destination: "LATE/1Sec/TRADE",
+			EpochStart:  t0.Add(10 * time.Second).Unix(),
+			EpochEnd:    t0.Add(11 * time.Second).Unix(),
+		}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(deleted.Responses[0].Error, Equals, "")
+	c.Assert(write([]time.Time{t0.Add(5 * time.Second)}, 10), IsNil)
+	c.Assert(read().GetColumn("Price"), DeepEquals, []float32{10, 4})
+
+	// nor once the bucket is destroyed
+	destroyed, err := GRPCService{}.Destroy(context.Background(), &proto.MultiKeyRequest{
+		Requests: []*proto.KeyRequest{{Key: "LATE/1Sec/TRADE"}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(destroyed.Responses[0].Error, Equals, "")
+	c.Assert(write([]time.Time{t0}, 11), IsNil)
+	c.Assert(read().GetColumn("Price"), DeepEquals, []float32{11})
+}
@@ -8,8 +8,10 @@ import (
 	"time"
 
 	"github.com/alpacahq/marketstore/v4/executor"
+	"github.com/alpacahq/marketstore/v4/metrics"
 	"github.com/alpacahq/marketstore/v4/utils"
 	"github.com/alpacahq/marketstore/v4/utils/io"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type WriteRequest struct {
@@ -22,6 +24,10 @@ type MultiWriteRequest struct {
 		A multi-request allows for different Timeframes and record formats for each request
 	*/
 	Requests []WriteRequest `msgpack:"requests"`
+	// IdempotencyKey is the key of the request, unique to the client, so
+	// that the request retried with the same key within the idempotency
+	// window is not written again
+	IdempotencyKey string `msgpack:"idempotency_key"`
 }
 
 type ServerResponse struct {
@@ -34,20 +40,41 @@ type MultiServerResponse struct {
 }
 
 func (s *DataService) Write(r *http.Request, reqs *MultiWriteRequest, response *MultiServerResponse) (err error) {
-	for _, req := range reqs.Requests {
-		csm, err := req.Data.ToColumnSeriesMap()
-		if err != nil {
-			response.appendResponse(err)
-			continue
-		}
-		if err = executor.WriteCSM(csm, req.IsVariableLength); err != nil {
-			response.appendResponse(err)
-			continue
+	timer := prometheus.NewTimer(metrics.WriteDuration.WithLabelValues("DataService.Write"))
+	defer timer.ObserveDuration()
+
+	_, err = writeOnce(requestContext(r), "DataService.Write", reqs.IdempotencyKey, len(reqs.Requests), func(written []bool) {
+		for i, req := range reqs.Requests {
+			if written[i] {
+				// by an earlier request with the key
+				continue
+			}
+			csm, err := req.Data.ToColumnSeriesMap()
+			if err != nil {
+				response.appendResponse(err)
+				continue
+			}
+			late := validateWrite(csm, req.IsVariableLength, utils.InstanceConfig.StrictWrites,
+				utils.InstanceConfig.LateDataWindow)
+			if late != nil && !isLateRecords(late) {
+				response.appendResponse(late)
+				continue
+			}
+			if err = executor.WriteCSM(csm, req.IsVariableLength); err != nil {
+				response.appendResponse(err)
+				continue
+			}
+			written[i] = true
+			observeWriteBytes("DataService.Write", csm)
+			if late != nil {
+				// the records dropped from the written ones
+				response.appendResponse(late)
+			}
+			//TODO: There should be an error response for every server request, need to add the below commented line
+			//appendResponse(err, response)
 		}
-		//TODO: There should be an error response for every server request, need to add the below commented line
-		//appendResponse(err, response)
-	}
-	return nil
+	})
+	return err
 }
 
 /*
@@ -78,7 +105,7 @@ func (s *DataService) Create(r *http.Request, reqs *MultiCreateRequest, response
 			continue
 		}
 
-		dsv, err := io.DataShapesFromInputString(req.DataShapes)
+		dsv, scales, err := io.DataShapesAndScalesFromInputString(req.DataShapes)
 		if err != nil {
 			response.appendResponse(err)
 			continue
@@ -102,6 +129,15 @@ func (s *DataService) Create(r *http.Request, reqs *MultiCreateRequest, response
 		}
 		rt := io.EnumRecordTypeByName(rowType)
 		tbinfo := io.NewTimeBucketInfo(*tf, tbk.GetPathToYearFiles(rootDir), "Default", year, dsv, rt)
+		for name, scale := range scales {
+			if err = tbinfo.SetElementScale(name, scale); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			response.appendResponse(err)
+			continue
+		}
 
 		err = executor.ThisInstance.CatalogDir.AddTimeBucket(tbk, tbinfo)
 		if err != nil {
@@ -182,6 +218,8 @@ func (s *DataService) Destroy(r *http.Request, reqs *MultiKeyRequest, response *
 		}
 
 		err = executor.ThisInstance.CatalogDir.RemoveTimeBucket(tbk)
+		executor.ForgetBucketTail(tbk)
+		clearQueryCache()
 		if err != nil {
 			err = fmt.Errorf("removal of catalog entry failed: %s", err.Error())
 			response.appendResponse(err)
@@ -0,0 +1,338 @@
+// Package metrics sets up the Prometheus registry serving the metrics of
+// the server and its plugins at /metrics.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// QueryDuration is the latency of the query requests, partitioned by RPC method
+	QueryDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "query_duration_seconds",
+			Help:    "Latency of the query requests, partitioned by RPC method",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 16),
+		},
+		[]string{"method"},
+	)
+	// QueryRows is the number of rows returned to the queries
+	QueryRows = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "query_rows_total",
+			Help: "Number of rows returned to the queries, partitioned by RPC method, symbol and timeframe",
+		},
+		[]string{"method", "symbol", "timeframe"},
+	)
+	// QueryCacheHits is the number of bucket queries served from the query cache
+	QueryCacheHits = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "query_cache_hits_total",
+			Help: "Number of bucket queries served from the query cache",
+		},
+	)
+	// QueryCacheMisses is the number of bucket queries read from the files with the query cache enabled
+	QueryCacheMisses = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "query_cache_misses_total",
+			Help: "Number of bucket queries read from the files with the query cache enabled",
+		},
+	)
+	// WriteDuration is the latency of the write requests, partitioned by RPC method
+	WriteDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "write_duration_seconds",
+			Help:    "Latency of the write requests, partitioned by RPC method",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 16),
+		},
+		[]string{"method"},
+	)
+	// WriteBatchSize is the number of the writes coalesced into a flush by the write batching
+	WriteBatchSize = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "write_batch_size",
+			Help:    "Number of the writes coalesced into a WAL flush by the write batching",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+		},
+	)
+	// WriteBatchLatency is the time from the first write of a batch to its flush
+	WriteBatchLatency = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "write_batch_flush_latency_seconds",
+			Help:    "Time from the first write of a batch to the end of its WAL flush",
+			Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+		},
+	)
+	// WriteBytes is the size of the written records
+	WriteBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "write_bytes_total",
+			Help: "Size of the written records, partitioned by RPC method, symbol and timeframe",
+		},
+		[]string{"method", "symbol", "timeframe"},
+	)
+	// WriteDuplicates is the number of records written at the timestamp of an existing one
+	WriteDuplicates = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "write_duplicate_records_total",
+			Help: "Number of records written at the timestamp of an existing one, partitioned by action (overwrite or reject)",
+		},
+		[]string{"action"},
+	)
+	// WriteLateRecords is the number of records dropped as older than the late data window
+	WriteLateRecords = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "write_late_records_total",
+			Help: "Number of records dropped from the writes as older than the late data window",
+		},
+	)
+	// WriteSuppressedBatches is the number of write requests not written again
+	WriteSuppressedBatches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "write_suppressed_batches_total",
+			Help: "Number of write requests retried with the idempotency key of a request already written, and not written again, partitioned by RPC method",
+		},
+		[]string{"method"},
+	)
+	// RetentionReclaimedFiles is the number of year files pruned past their retention
+	RetentionReclaimedFiles = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "retention_reclaimed_files_total",
+			Help: "Number of year files pruned past their retention, partitioned by action (delete or archive)",
+		},
+		[]string{"action"},
+	)
+	// RetentionReclaimedBytes is the size of the year files pruned past their retention
+	RetentionReclaimedBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "retention_reclaimed_bytes_total",
+			Help: "Size of the year files pruned past their retention, partitioned by action (delete or archive)",
+		},
+		[]string{"action"},
+	)
+	// BgWorkerLastRun is the time of the last successful run of the bgworkers
+	BgWorkerLastRun = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bgworker_last_run_timestamp_seconds",
+			Help: "Unix time of the last successful run of the bgworkers reporting their progress, partitioned by worker name",
+		},
+		[]string{"name"},
+	)
+	// BgWorkerRestarts is the number of restarts of the bgworkers after a panic
+	BgWorkerRestarts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bgworker_restarts_total",
+			Help: "Number of restarts of the bgworkers after a panic, partitioned by worker name",
+		},
+		[]string{"name"},
+	)
+	// TriggerFires is the number of times the triggers were fired
+	TriggerFires = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "trigger_fires_total",
+			Help: "Number of times the triggers were fired, partitioned by trigger name",
+		},
+		[]string{"trigger"},
+	)
+	// TriggerErrors is the number of panics recovered from the triggers
+	TriggerErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "trigger_errors_total",
+			Help: "Number of panics recovered from the triggers, partitioned by trigger name",
+		},
+		[]string{"trigger"},
+	)
+	// TriggerDropped is the number of writes not fired as the trigger queue was full
+	TriggerDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "trigger_dropped_total",
+			Help: "Number of writes the triggers were not fired on as their queue was full, partitioned by trigger name",
+		},
+		[]string{"trigger"},
+	)
+	// TriggerDuration is the latency of the triggers
+	TriggerDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "trigger_duration_seconds",
+			Help:    "Latency of the triggers, partitioned by trigger name",
+			Buckets: prometheus.ExponentialBuckets(0.001, 2, 16),
+		},
+		[]string{"trigger"},
+	)
+	// TriggerQueueDepth is the number of writes waiting for the triggers
+	TriggerQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "trigger_queue_depth",
+			Help: "Number of writes waiting for the triggers to be fired, partitioned by trigger name",
+		},
+		[]string{"trigger"},
+	)
+	// DiskBytes is the size of the year files by timeframe
+	DiskBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "disk_bytes",
+			Help: "Size of the year files of the buckets, partitioned by timeframe",
+		},
+		[]string{"timeframe"},
+	)
+	// DiskFiles is the number of year files by timeframe
+	DiskFiles = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "disk_files",
+			Help: "Number of year files of the buckets, partitioned by timeframe",
+		},
+		[]string{"timeframe"},
+	)
+	// DiskLastCollection is the time the disk usage was last collected
+	DiskLastCollection = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "disk_last_collection_timestamp_seconds",
+			Help: "Unix time the disk usage metrics were last collected",
+		},
+	)
+	// IngestionLag is the age of the latest record of the buckets by timeframe
+	IngestionLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ingestion_lag_seconds",
+			Help: "Age of the latest record of the buckets, the oldest over the symbols, partitioned by timeframe",
+		},
+		[]string{"timeframe"},
+	)
+	// IngestionSymbolLag is the age of the latest record of the buckets by symbol
+	IngestionSymbolLag = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "ingestion_symbol_lag_seconds",
+			Help: "Age of the latest record of the buckets, partitioned by symbol and timeframe",
+		},
+		[]string{"symbol", "timeframe"},
+	)
+	// OpenFiles is the number of year files open
+	OpenFiles = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "open_files",
+			Help: "Number of year files open for the reads and the writes, in use or kept open for reuse",
+		},
+	)
+	// OpenFileEvictions is the number of year files closed past the maximum
+	OpenFileEvictions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "open_file_evictions_total",
+			Help: "Number of year files kept open for reuse closed as the least recently used past max_open_files",
+		},
+	)
+	// WALSize is the size of the WAL file
+	WALSize = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "wal_size_bytes",
+			Help: "Size of the WAL file",
+		},
+	)
+	// WALRotations is the number of times the WAL file was rotated
+	WALRotations = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "wal_rotations_total",
+			Help: "Number of times the WAL file was rotated",
+		},
+	)
+	// WALLastRotation is the time of the last rotation of the WAL file
+	WALLastRotation = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "wal_last_rotation_timestamp_seconds",
+			Help: "Unix time of the last rotation of the WAL file",
+		},
+	)
+	// WALRotationBytes is the size of the WAL file at its rotations
+	WALRotationBytes = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "wal_rotation_bytes",
+			Help:    "Size of the transactions flushed to the WAL file between two rotations",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 12),
+		},
+	)
+	// WALReplayDuration is the time taken by the replay of the WAL files at startup
+	WALReplayDuration = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "wal_replay_duration_seconds",
+			Help: "Time taken at startup by the replay of the WAL files left by the previous instances",
+		},
+	)
+	// WALReplayTransactions is the number of transactions replayed at startup
+	WALReplayTransactions = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "wal_replay_transactions",
+			Help: "Number of transaction groups replayed at startup from the WAL files left by the previous instances",
+		},
+	)
+	// WALReplayBytes is the size of the WAL files replayed at startup
+	WALReplayBytes = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "wal_replay_bytes",
+			Help: "Size of the WAL files left by the previous instances read by the replay at startup",
+		},
+	)
+)
+
+// Setup replaces the default Prometheus registerer and gatherer with a new
+// registry, and returns the handler serving it.  The names of the collectors
+// registered from then on, including the ones of the plugins using promauto,
+// are prefixed with the namespace if any, and the collectors are labeled with
+// the static labels, so that several instances can be scraped together.
+// With neither of them, the metric names stay the same as the default registry.
+func Setup(namespace string, labels map[string]string) http.Handler {
+	reg := prometheus.NewRegistry()
+
+	var registerer prometheus.Registerer = reg
+	if namespace != "" {
+		registerer = prometheus.WrapRegistererWithPrefix(namespace+"_", registerer)
+	}
+	if len(labels) > 0 {
+		registerer = prometheus.WrapRegistererWith(prometheus.Labels(labels), registerer)
+	}
+
+	registerer.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		QueryDuration,
+		QueryRows,
+		QueryCacheHits,
+		QueryCacheMisses,
+		WriteDuration,
+		WriteBytes,
+		WriteBatchSize,
+		WriteBatchLatency,
+		WriteDuplicates,
+		WriteLateRecords,
+		WriteSuppressedBatches,
+		RetentionReclaimedFiles,
+		RetentionReclaimedBytes,
+		BgWorkerLastRun,
+		BgWorkerRestarts,
+		TriggerFires,
+		TriggerErrors,
+		TriggerDropped,
+		TriggerDuration,
+		TriggerQueueDepth,
+		DiskBytes,
+		DiskFiles,
+		DiskLastCollection,
+		IngestionLag,
+		IngestionSymbolLag,
+		OpenFiles,
+		OpenFileEvictions,
+		WALSize,
+		WALRotations,
+		WALLastRotation,
+		WALRotationBytes,
+		WALReplayDuration,
+		WALReplayTransactions,
+		WALReplayBytes,
+	)
+
+	prometheus.DefaultRegisterer = registerer
+	prometheus.DefaultGatherer = reg
+
+	return promhttp.InstrumentMetricHandler(registerer, promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type MetricsTestSuite struct{}
+
+var _ = Suite(&MetricsTestSuite{})
+
+func (s *MetricsTestSuite) TestSetup(c *C) {
+	defaultRegisterer, defaultGatherer := prometheus.DefaultRegisterer, prometheus.DefaultGatherer
+	defer func() {
+		prometheus.DefaultRegisterer, prometheus.DefaultGatherer = defaultRegisterer, defaultGatherer
+	}()
+
+	handler := Setup("mkts", map[string]string{"instance": "a", "region": "us"})
+
+	// registered like the plugins do
+	counter := promauto.NewCounter(prometheus.CounterOpts{
+		Name: "test_total",
+		Help: "test counter",
+	})
+	counter.Inc()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	c.Assert(strings.Contains(body, `mkts_test_total{instance="a",region="us"} 1`), Equals, true)
+	c.Assert(strings.Contains(body, `mkts_go_goroutines{instance="a",region="us"}`), Equals, true)
+}
+
+func (s *MetricsTestSuite) TestSetupWithoutNamespace(c *C) {
+	defaultRegisterer, defaultGatherer := prometheus.DefaultRegisterer, prometheus.DefaultGatherer
+	defer func() {
+		prometheus.DefaultRegisterer, prometheus.DefaultGatherer = defaultRegisterer, defaultGatherer
+	}()
+
+	handler := Setup("", nil)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	c.Assert(strings.Contains(rec.Body.String(), "\ngo_goroutines "), Equals, true)
+}
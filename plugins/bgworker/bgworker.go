@@ -2,17 +2,22 @@
 // has to implement the following function.
 // NewBgWorker(config map[string]interface{}) (BgWorker, error)
 //
+// It also has to be stamped with the marketstore version it is built against,
+// see the version package.
+//
 // Background workers run under the marketstore server by implementing the
 // interface, started at the very beginning of the server lifecycle before the
 // query interface is started, but internal state shuold be fledged. The server
-// does not handle panics that happen within the plugin.  A plugin can recover
-// from panics, but be careful not to screw the server state if touching
-// internal API.  It is often better to just let it go.
+// recovers the panics that happen within the plugin, and restarts it with a
+// backoff, so be careful not to screw the server state if touching internal
+// API.  A plugin implementing Progresser reports its last successful run, and
+// is warned as stalled when it makes no progress for the stall timeout.
 //
 // Configuration is as follows.
 //  bgworkers:
 //    - module: xxxWorker.so
 //      name: datafeed
+//      stall_timeout: 600 # in seconds, optional
 //      config: <according to the plulgin>
 package bgworker
 
@@ -0,0 +1,132 @@
+package bgworker
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/metrics"
+	"github.com/alpacahq/marketstore/v4/utils/log"
+)
+
+const (
+	minRestartBackoff = time.Second
+	maxRestartBackoff = time.Minute
+)
+
+// Progresser is optionally implemented by a BgWorker to report the time of its
+// last successful run, e.g. the last time it wrote the data it fetched.  It is
+// exported as a metric, and used to tell the worker has stalled.
+type Progresser interface {
+	LastRun() time.Time
+}
+
+// Supervisor runs a BgWorker, recovering it from panics and restarting it with
+// an exponential backoff, and warns when it makes no progress for longer than
+// the stall timeout.
+type Supervisor struct {
+	name         string
+	worker       BgWorker
+	stallTimeout time.Duration
+
+	minBackoff, maxBackoff time.Duration
+	checkInterval          time.Duration
+}
+
+// NewSupervisor returns the Supervisor of the worker of the name.  A zero
+// stall timeout disables the stall warnings.
+func NewSupervisor(name string, worker BgWorker, stallTimeout time.Duration) *Supervisor {
+	checkInterval := time.Minute
+	if stallTimeout > 0 && stallTimeout/4 < checkInterval {
+		checkInterval = stallTimeout / 4
+	}
+	return &Supervisor{
+		name:          name,
+		worker:        worker,
+		stallTimeout:  stallTimeout,
+		minBackoff:    minRestartBackoff,
+		maxBackoff:    maxRestartBackoff,
+		checkInterval: checkInterval,
+	}
+}
+
+// Run runs the worker until it returns, restarting it whenever it panics.
+func (s *Supervisor) Run() {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	if p, ok := s.worker.(Progresser); ok {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.watch(p, done)
+		}()
+	} else if s.stallTimeout > 0 {
+		log.Warn("bgworker %s does not report its progress, its stall timeout is ignored", s.name)
+	}
+	defer wg.Wait()
+	defer close(done)
+
+	backoff := s.minBackoff
+	for {
+		started := time.Now()
+		if !s.runOnce() {
+			log.Info("bgworker %s has returned", s.name)
+			return
+		}
+		metrics.BgWorkerRestarts.WithLabelValues(s.name).Inc()
+		// a worker that ran for a while before panicking is restarted promptly
+		if time.Since(started) > s.maxBackoff {
+			backoff = s.minBackoff
+		}
+		log.Warn("restarting bgworker %s in %v", s.name, backoff)
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// runOnce runs the worker, and returns whether it panicked.
+func (s *Supervisor) runOnce() (panicked bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error("bgworker %s panicked: %v\n%s", s.name, r, debug.Stack())
+			panicked = true
+		}
+	}()
+	s.worker.Run()
+	return false
+}
+
+// watch exports the last run of the worker, and warns once per stall when it
+// is older than the stall timeout, until done is closed.
+func (s *Supervisor) watch(p Progresser, done <-chan struct{}) {
+	ticker := time.NewTicker(s.checkInterval)
+	defer ticker.Stop()
+
+	started := time.Now()
+	stalled := false
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			last := p.LastRun()
+			if !last.IsZero() {
+				metrics.BgWorkerLastRun.WithLabelValues(s.name).Set(float64(last.Unix()))
+			}
+			if s.stallTimeout <= 0 {
+				continue
+			}
+			if last.Before(started) {
+				last = started
+			}
+			if now.Sub(last) <= s.stallTimeout {
+				stalled = false
+			} else if !stalled {
+				stalled = true
+				log.Warn("bgworker %s has made no progress since %v", s.name, last)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package bgworker
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/alpacahq/marketstore/v4/metrics"
+	. "gopkg.in/check.v1"
+)
+
+// Hook up gocheck into the "go test" runner.
+func Test(t *testing.T) { TestingT(t) }
+
+type TestSuite struct{}
+
+var _ = Suite(&TestSuite{})
+
+type panickyWorker struct {
+	panics int32
+	runs   int32
+}
+
+func (w *panickyWorker) Run() {
+	if atomic.AddInt32(&w.runs, 1) <= w.panics {
+		panic("boom")
+	}
+}
+
+type progressWorker struct {
+	last time.Time
+	stop chan struct{}
+}
+
+func (w *progressWorker) Run() { <-w.stop }
+
+func (w *progressWorker) LastRun() time.Time { return w.last }
+
+func (s *TestSuite) TestSupervisorRestartsPanics(c *C) {
+	w := &panickyWorker{panics: 3}
+	sup := NewSupervisor("panicky", w, 0)
+	sup.minBackoff, sup.maxBackoff = time.Millisecond, 4*time.Millisecond
+
+	sup.Run()
+
+	c.Assert(atomic.LoadInt32(&w.runs), Equals, int32(4))
+	c.Assert(testutil.ToFloat64(metrics.BgWorkerRestarts.WithLabelValues("panicky")), Equals, float64(3))
+}
+
+func (s *TestSuite) TestSupervisorLastRun(c *C) {
+	last := time.Unix(1600000000, 0)
+	w := &progressWorker{last: last, stop: make(chan struct{})}
+	sup := NewSupervisor("progress", w, time.Hour)
+	sup.checkInterval = time.Millisecond
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(w.stop)
+	}()
+	sup.Run()
+
+	c.Assert(testutil.ToFloat64(metrics.BgWorkerLastRun.WithLabelValues("progress")), Equals, float64(last.Unix()))
+}
@@ -8,6 +8,7 @@ import (
 	"plugin"
 	"strings"
 
+	"github.com/alpacahq/marketstore/v4/plugins/version"
 	"github.com/alpacahq/marketstore/v4/utils/log"
 )
 
@@ -17,17 +18,42 @@ type SymbolLoader struct {
 
 // NewSymbolLoader creates a SymbolLoader that loads symbol from a particular module.
 // moduleName can be a file name under one of $GOPATH directories or current working
-// directory, or an absolute path to the file.
+// directory, or an absolute path to the file.  The module must be stamped with the
+// version of the marketstore packages of the server, see the version package.
 func NewSymbolLoader(moduleName string) (*SymbolLoader, error) {
 	pi, err := Load(moduleName)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkVersion(moduleName, pi); err != nil {
+		return nil, err
+	}
 	return &SymbolLoader{
 		module: pi,
 	}, nil
 }
 
+// checkVersion returns an error if the module is not built against the
+// version of the marketstore packages of the server.
+func checkVersion(moduleName string, pi *plugin.Plugin) error {
+	sym, err := pi.Lookup(version.SymbolName)
+	if err != nil {
+		return fmt.Errorf("module %s is not stamped with the marketstore version, expected %s, "+
+			"rebuild it with `var %s = version.Version` in its main package",
+			moduleName, version.Version, version.SymbolName)
+	}
+	found, ok := sym.(*string)
+	if !ok {
+		return fmt.Errorf("module %s exports %s as %T rather than a string",
+			moduleName, version.SymbolName, sym)
+	}
+	if *found != version.Version {
+		return fmt.Errorf("module %s is built against marketstore %s, expected %s, rebuild it against this version",
+			moduleName, *found, version.Version)
+	}
+	return nil
+}
+
 // LoadSymbol looks up a symbol from the module.  Plugin packages can accept this
 // by defining an interface type without importing this package.  It is important
 // to note that each plugin package cannot import this plugins package since
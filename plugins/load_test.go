@@ -2,6 +2,7 @@ package plugins
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -10,6 +11,8 @@ import (
 	"testing"
 
 	. "gopkg.in/check.v1"
+
+	"github.com/alpacahq/marketstore/v4/plugins/version"
 )
 
 // Hook up gocheck into the "go test" runner.
@@ -19,6 +22,9 @@ type TestSuite struct {
 	TestPluginLib    string
 	AbsTestPluginLib string
 	OldGoPath        string
+	// paths of the plugins stamped with the version, another version and none
+	StampedPluginLib    string
+	MismatchedPluginLib string
 }
 
 var _ = Suite(&TestSuite{})
@@ -56,6 +62,11 @@ func main() {}
 		c.Skip("Unable to build test plugin ** is go version > 1.9 in your path?")
 	}
 
+	s.StampedPluginLib = buildPlugin(c, dirName, "stamped",
+		fmt.Sprintf("var %s = %q", version.SymbolName, version.Version))
+	s.MismatchedPluginLib = buildPlugin(c, dirName, "mismatched",
+		fmt.Sprintf("var %s = %q", version.SymbolName, "0.0"))
+
 	goPath := os.Getenv("GOPATH")
 	newGoPath := dirName + ":" + goPath
 	s.OldGoPath = goPath
@@ -63,6 +74,18 @@ func main() {}
 	os.Setenv("GOPATH", newGoPath)
 }
 
+// buildPlugin builds a plugin with the declarations in dir, returning its path.
+func buildPlugin(c *C, dir, name, declarations string) string {
+	srcPath := filepath.Join(dir, name+".go")
+	soPath := filepath.Join(dir, name+".so")
+	code := "package main\n" + declarations + "\nfunc main() {}\n"
+	c.Assert(ioutil.WriteFile(srcPath, []byte(code), 0644), IsNil)
+	if out, err := exec.Command("go", "build", "-buildmode=plugin", "-o", soPath, srcPath).CombinedOutput(); err != nil {
+		c.Skip(fmt.Sprintf("Unable to build test plugin %s: %s", name, out))
+	}
+	return soPath
+}
+
 func (s *TestSuite) TearDownSuite(c *C) {
 	if s.OldGoPath != "" {
 		os.Setenv("GOPATH", s.OldGoPath)
@@ -85,3 +108,14 @@ func (s *TestSuite) TestLoadFromGOPATH(c *C) {
 	c.Check(pi, NotNil)
 	c.Check(err, IsNil)
 }
+
+func (s *TestSuite) TestNewSymbolLoaderVersion(c *C) {
+	_, err := NewSymbolLoader(s.StampedPluginLib)
+	c.Assert(err, IsNil)
+
+	_, err = NewSymbolLoader(s.MismatchedPluginLib)
+	c.Assert(err, ErrorMatches, `module .*mismatched.so is built against marketstore 0.0, expected `+version.Version+`, .*`)
+
+	_, err = NewSymbolLoader(s.AbsTestPluginLib)
+	c.Assert(err, ErrorMatches, `module .*plugin.so is not stamped with the marketstore version, expected `+version.Version+`, .*`)
+}
@@ -2,6 +2,9 @@
 // A trigger plugin has to implement the following function.
 // - NewTrigger(config map[string]interface{}) (Trigger, error)
 //
+// It also has to be stamped with the marketstore version it is built against,
+// see the version package.
+//
 // The trigger instance returned by this function will be called on Fire()
 // with the filePath (relative to root directory) and indexes that have been written
 // (appended or updated).  It is guaranteed that the new content has been written
@@ -19,6 +22,19 @@
 // is fired or not.  It can contain wildcard character "*".
 // As of now, trigger fires only on the running state.  Trigger on WAL replay
 // may be added later.
+//
+// Triggers are fired asynchronously.  Processes that need the triggers to be
+// complete before exiting, such as the backfillers, can wait for them with
+// executor.ThisInstance.DrainTriggers(ctx).
+//
+// Each trigger is fired in order by its own goroutine from a bounded queue, so
+// that a slow trigger delays neither the writes nor the other triggers.  The
+// writes are dropped for a trigger whose queue is full, and logged with the
+// trigger name like the panics of the trigger.  A trigger that panics, or that
+// returns an error as an ErrorTrigger, is fired again a few times, then the
+// written records are dead-lettered to a file under the root directory, like
+// the dropped ones, to be fired again later through the
+// /trigger-deadletters/redrive utilities endpoint.
 package trigger
 
 import (
@@ -39,6 +55,25 @@ type Trigger interface {
 	Fire(keyPath string, records []Record)
 }
 
+// ErrorTrigger is a Trigger that reports its failures.  It is fired with
+// FireWithError instead of Fire.
+type ErrorTrigger interface {
+	Trigger
+	// FireWithError is Fire, returning an error if the trigger failed
+	// on the records, so that they are fired again.
+	FireWithError(keyPath string, records []Record) error
+}
+
+// Fire fires the trigger on the records, returning its error if it is an
+// ErrorTrigger.
+func Fire(t Trigger, keyPath string, records []Record) error {
+	if et, ok := t.(ErrorTrigger); ok {
+		return et.FireWithError(keyPath, records)
+	}
+	t.Fire(keyPath, records)
+	return nil
+}
+
 // TriggerMatcher checks if the trigger should be fired or not.
 type TriggerMatcher struct {
 	Trigger Trigger
@@ -46,6 +81,9 @@ type TriggerMatcher struct {
 	// fire event.  It is the prefix of file path such as
 	// ""*/1Min/OHLC"
 	On string
+	// Name identifies the trigger in the logs and metrics, such as
+	// its module.  The type of the trigger is used if empty.
+	Name string
 }
 
 // SymbolLoader is an interface to retrieve symbol object from plugin
@@ -0,0 +1,15 @@
+// Package version stamps the plugins with the version of the marketstore
+// packages they are built against, which the server checks when it loads
+// them, since a plugin built against other packages can crash it.
+//
+// A plugin stamps itself by exporting the version from its main package:
+//
+//	var MarketstoreVersion = version.Version
+package version
+
+// Version is the version of the marketstore packages the plugins are built
+// against, bumped on an incompatible change to the packages they use.
+const Version = "4.1"
+
+// SymbolName is the name of the variable a plugin exports with the version.
+const SymbolName = "MarketstoreVersion"
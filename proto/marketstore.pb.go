@@ -118,12 +118,14 @@ func (x ListSymbolsRequest_Format) String() string {
 }
 
 func (ListSymbolsRequest_Format) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_a89eb64cdc1fc4a5, []int{13, 0}
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{17, 0}
 }
 
 type DataShape struct {
-	Name                 string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Type                 DataType `protobuf:"varint,2,opt,name=type,proto3,enum=proto.DataType" json:"type,omitempty"`
+	Name string   `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Type DataType `protobuf:"varint,2,opt,name=type,proto3,enum=proto.DataType" json:"type,omitempty"`
+	// Scale of a scaled integer (decimal) column of type INT64, whose values are value * 10^scale, 0 otherwise
+	Scale                int32    `protobuf:"varint,3,opt,name=scale,proto3" json:"scale,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -168,6 +170,13 @@ func (m *DataShape) GetType() DataType {
 	return DataType_UNKNOWN
 }
 
+func (m *DataShape) GetScale() int32 {
+	if m != nil {
+		return m.Scale
+	}
+	return 0
+}
+
 type NumpyMultiDataset struct {
 	Data                 *NumpyDataset    `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
 	StartIndex           map[string]int32 `protobuf:"bytes,2,rep,name=start_index,json=startIndex,proto3" json:"start_index,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"varint,2,opt,name=value,proto3"`
@@ -231,7 +240,7 @@ type NumpyDataset struct {
 	// two dimentional byte arrays holding the column data
 	ColumnData [][]byte `protobuf:"bytes,3,rep,name=column_data,json=columnData,proto3" json:"column_data,omitempty"`
 	Length     int32    `protobuf:"varint,4,opt,name=length,proto3" json:"length,omitempty"`
-	// hidden
+	// hidden, except the scales of the scaled integer columns in a query response
 	DataShapes           []*DataShape `protobuf:"bytes,5,rep,name=data_shapes,json=dataShapes,proto3" json:"data_shapes,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
 	XXX_unrecognized     []byte       `json:"-"`
@@ -355,14 +364,46 @@ type QueryRequest struct {
 	EpochEnd int64 `protobuf:"varint,7,opt,name=epoch_end,json=epochEnd,proto3" json:"epoch_end,omitempty"`
 	// fractional part (nano second) of epoch_end
 	EpochEndNanos int64 `protobuf:"varint,8,opt,name=epoch_end_nanos,json=epochEndNanos,proto3" json:"epoch_end_nanos,omitempty"`
-	// Number of max returned rows from lower/upper bound
+	// Number of max returned rows from lower/upper bound. From the upper bound, the latest rows are read
+	// backward from the end of the files, stopping at this number, e.g. 1 for the last bar or trade
 	LimitRecordCount int32 `protobuf:"varint,9,opt,name=limit_record_count,json=limitRecordCount,proto3" json:"limit_record_count,omitempty"`
 	// Set to true if LimitRecordCount should be from the lower
 	LimitFromStart bool `protobuf:"varint,10,opt,name=limit_from_start,json=limitFromStart,proto3" json:"limit_from_start,omitempty"`
-	// Array of column names to be returned
+	// Array of column names to be returned, in this order after the Epoch (and before the Nanoseconds of
+	// variable length records), all of them if empty. A column not in the bucket is an InvalidArgument error
 	Columns []string `protobuf:"bytes,11,rep,name=columns,proto3" json:"columns,omitempty"`
 	// Support for functions is experimental and subject to change
-	Functions            []string `protobuf:"bytes,12,rep,name=functions,proto3" json:"functions,omitempty"`
+	Functions []string `protobuf:"bytes,12,rep,name=functions,proto3" json:"functions,omitempty"`
+	// Timeframe to resample the records to on read (e.g. "1H"), longer than the timeframe of the destination.
+	// Open, High, Low, Close and Volume are aggregated as first, max, min, last and sum
+	Resample string `protobuf:"bytes,13,opt,name=resample,proto3" json:"resample,omitempty"`
+	// Reducer (last, mean or sum) of the other columns in resampling by column name, last by default
+	ResampleReducers map[string]string `protobuf:"bytes,14,rep,name=resample_reducers,json=resampleReducers,proto3" json:"resample_reducers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Set to true to insert the rows of the intervals of the timeframe missing in the range.
+	// The inserted rows have NaN in the float columns and zero in the others
+	FillGaps bool `protobuf:"varint,15,opt,name=fill_gaps,json=fillGaps,proto3" json:"fill_gaps,omitempty"`
+	// Set to true to fill Open, High, Low and Close with the previous Close rather than NaN
+	FillForward bool `protobuf:"varint,16,opt,name=fill_forward,json=fillForward,proto3" json:"fill_forward,omitempty"`
+	// Set to true to fill the float columns with zero rather than NaN
+	FillZero bool `protobuf:"varint,17,opt,name=fill_zero,json=fillZero,proto3" json:"fill_zero,omitempty"`
+	// Name of the market calendar (e.g. "nasdaq") to fill only the market hours, or days for the daily timeframes
+	FillCalendar string `protobuf:"bytes,18,opt,name=fill_calendar,json=fillCalendar,proto3" json:"fill_calendar,omitempty"`
+	// Symbols to query each in the Timeframe and AttributeGroup of destination, whose Symbol is ignored.
+	// The errors of the symbols are returned by symbol rather than failing the request
+	Symbols []string `protobuf:"bytes,19,rep,name=symbols,proto3" json:"symbols,omitempty"`
+	// Glob pattern (e.g. "A*") of the symbols to query as symbols, in addition to them
+	SymbolGlob string `protobuf:"bytes,20,opt,name=symbol_glob,json=symbolGlob,proto3" json:"symbol_glob,omitempty"`
+	// Timeframes (e.g. "1Min", "1D") to query each with the Symbol and AttributeGroup of destination, whose
+	// Timeframe is ignored, returned in timeframe_results. They are read from the same writes: all those
+	// accepted before the request, and none after it
+	Timeframes []string `protobuf:"bytes,21,rep,name=timeframes,proto3" json:"timeframes,omitempty"`
+	// Number of the last trading sessions of session_calendar to query, in place of epoch_start, up to
+	// epoch_end or now. The range spans the whole days of the sessions in the server timezone, so that the
+	// daily bars and the extended hours are included. The session of the day of the end is counted once it
+	// has opened, even if in progress, with the records up to the end
+	LastSessions int32 `protobuf:"varint,22,opt,name=last_sessions,json=lastSessions,proto3" json:"last_sessions,omitempty"`
+	// Name of the market calendar (e.g. "nasdaq") of last_sessions, "nasdaq" by default
+	SessionCalendar      string   `protobuf:"bytes,23,opt,name=session_calendar,json=sessionCalendar,proto3" json:"session_calendar,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -477,6 +518,83 @@ func (m *QueryRequest) GetFunctions() []string {
 	return nil
 }
 
+func (m *QueryRequest) GetResample() string {
+	if m != nil {
+		return m.Resample
+	}
+	return ""
+}
+
+func (m *QueryRequest) GetResampleReducers() map[string]string {
+	if m != nil {
+		return m.ResampleReducers
+	}
+	return nil
+}
+
+func (m *QueryRequest) GetFillGaps() bool {
+	if m != nil {
+		return m.FillGaps
+	}
+	return false
+}
+
+func (m *QueryRequest) GetFillForward() bool {
+	if m != nil {
+		return m.FillForward
+	}
+	return false
+}
+
+func (m *QueryRequest) GetFillZero() bool {
+	if m != nil {
+		return m.FillZero
+	}
+	return false
+}
+
+func (m *QueryRequest) GetFillCalendar() string {
+	if m != nil {
+		return m.FillCalendar
+	}
+	return ""
+}
+
+func (m *QueryRequest) GetSymbols() []string {
+	if m != nil {
+		return m.Symbols
+	}
+	return nil
+}
+
+func (m *QueryRequest) GetSymbolGlob() string {
+	if m != nil {
+		return m.SymbolGlob
+	}
+	return ""
+}
+
+func (m *QueryRequest) GetTimeframes() []string {
+	if m != nil {
+		return m.Timeframes
+	}
+	return nil
+}
+
+func (m *QueryRequest) GetLastSessions() int32 {
+	if m != nil {
+		return m.LastSessions
+	}
+	return 0
+}
+
+func (m *QueryRequest) GetSessionCalendar() string {
+	if m != nil {
+		return m.SessionCalendar
+	}
+	return ""
+}
+
 type MultiQueryResponse struct {
 	Responses            []*QueryResponse `protobuf:"bytes,1,rep,name=responses,proto3" json:"responses,omitempty"`
 	Version              string           `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
@@ -533,10 +651,14 @@ func (m *MultiQueryResponse) GetTimezone() string {
 }
 
 type QueryResponse struct {
-	Result               *NumpyMultiDataset `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
-	XXX_unrecognized     []byte             `json:"-"`
-	XXX_sizecache        int32              `json:"-"`
+	Result *NumpyMultiDataset `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
+	// Errors of the symbols of a multi-symbol query by symbol
+	Errors map[string]string `protobuf:"bytes,2,rep,name=errors,proto3" json:"errors,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	// Results of a multi-timeframe query by timeframe, without those of no records
+	TimeframeResults     map[string]*NumpyMultiDataset `protobuf:"bytes,3,rep,name=timeframe_results,json=timeframeResults,proto3" json:"timeframe_results,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	XXX_NoUnkeyedLiteral struct{}                      `json:"-"`
+	XXX_unrecognized     []byte                        `json:"-"`
+	XXX_sizecache        int32                         `json:"-"`
 }
 
 func (m *QueryResponse) Reset()         { *m = QueryResponse{} }
@@ -571,13 +693,30 @@ func (m *QueryResponse) GetResult() *NumpyMultiDataset {
 	return nil
 }
 
+func (m *QueryResponse) GetErrors() map[string]string {
+	if m != nil {
+		return m.Errors
+	}
+	return nil
+}
+
+func (m *QueryResponse) GetTimeframeResults() map[string]*NumpyMultiDataset {
+	if m != nil {
+		return m.TimeframeResults
+	}
+	return nil
+}
+
 type MultiWriteRequest struct {
 	//
 	//A multi-request allows for different Timeframes and record formats for each request
-	Requests             []*WriteRequest `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
-	XXX_unrecognized     []byte          `json:"-"`
-	XXX_sizecache        int32           `json:"-"`
+	Requests []*WriteRequest `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	// Key of the request, unique to the client, so that the request retried with the same key within the
+	// idempotency window of the server is not written again
+	IdempotencyKey       string   `protobuf:"bytes,2,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *MultiWriteRequest) Reset()         { *m = MultiWriteRequest{} }
@@ -612,6 +751,13 @@ func (m *MultiWriteRequest) GetRequests() []*WriteRequest {
 	return nil
 }
 
+func (m *MultiWriteRequest) GetIdempotencyKey() string {
+	if m != nil {
+		return m.IdempotencyKey
+	}
+	return ""
+}
+
 type WriteRequest struct {
 	Data                 *NumpyMultiDataset `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
 	IsVariableLength     bool               `protobuf:"varint,2,opt,name=is_variable_length,json=isVariableLength,proto3" json:"is_variable_length,omitempty"`
@@ -823,18 +969,204 @@ func (m *KeyRequest) GetKey() string {
 	return ""
 }
 
+type MultiDeleteRequest struct {
+	Requests             []*DeleteRequest `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *MultiDeleteRequest) Reset()         { *m = MultiDeleteRequest{} }
+func (m *MultiDeleteRequest) String() string { return proto.CompactTextString(m) }
+func (*MultiDeleteRequest) ProtoMessage()    {}
+func (*MultiDeleteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{13}
+}
+
+func (m *MultiDeleteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MultiDeleteRequest.Unmarshal(m, b)
+}
+func (m *MultiDeleteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MultiDeleteRequest.Marshal(b, m, deterministic)
+}
+func (m *MultiDeleteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MultiDeleteRequest.Merge(m, src)
+}
+func (m *MultiDeleteRequest) XXX_Size() int {
+	return xxx_messageInfo_MultiDeleteRequest.Size(m)
+}
+func (m *MultiDeleteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_MultiDeleteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MultiDeleteRequest proto.InternalMessageInfo
+
+func (m *MultiDeleteRequest) GetRequests() []*DeleteRequest {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+type DeleteRequest struct {
+	// Destination is <symbol>/<timeframe>/<attributegroup>
+	Destination string `protobuf:"bytes,1,opt,name=destination,proto3" json:"destination,omitempty"`
+	// Lower time predicate (i.e. index >= start) in unix epoch second
+	EpochStart int64 `protobuf:"varint,2,opt,name=epoch_start,json=epochStart,proto3" json:"epoch_start,omitempty"`
+	// Upper time predicate (i.e. index <= end) in unix epoch second
+	EpochEnd             int64    `protobuf:"varint,3,opt,name=epoch_end,json=epochEnd,proto3" json:"epoch_end,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteRequest) Reset()         { *m = DeleteRequest{} }
+func (m *DeleteRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteRequest) ProtoMessage()    {}
+func (*DeleteRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{14}
+}
+
+func (m *DeleteRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteRequest.Unmarshal(m, b)
+}
+func (m *DeleteRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteRequest.Marshal(b, m, deterministic)
+}
+func (m *DeleteRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteRequest.Merge(m, src)
+}
+func (m *DeleteRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteRequest.Size(m)
+}
+func (m *DeleteRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteRequest proto.InternalMessageInfo
+
+func (m *DeleteRequest) GetDestination() string {
+	if m != nil {
+		return m.Destination
+	}
+	return ""
+}
+
+func (m *DeleteRequest) GetEpochStart() int64 {
+	if m != nil {
+		return m.EpochStart
+	}
+	return 0
+}
+
+func (m *DeleteRequest) GetEpochEnd() int64 {
+	if m != nil {
+		return m.EpochEnd
+	}
+	return 0
+}
+
+type MultiDeleteResponse struct {
+	Responses            []*DeleteResponse `protobuf:"bytes,1,rep,name=responses,proto3" json:"responses,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *MultiDeleteResponse) Reset()         { *m = MultiDeleteResponse{} }
+func (m *MultiDeleteResponse) String() string { return proto.CompactTextString(m) }
+func (*MultiDeleteResponse) ProtoMessage()    {}
+func (*MultiDeleteResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{15}
+}
+
+func (m *MultiDeleteResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_MultiDeleteResponse.Unmarshal(m, b)
+}
+func (m *MultiDeleteResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_MultiDeleteResponse.Marshal(b, m, deterministic)
+}
+func (m *MultiDeleteResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_MultiDeleteResponse.Merge(m, src)
+}
+func (m *MultiDeleteResponse) XXX_Size() int {
+	return xxx_messageInfo_MultiDeleteResponse.Size(m)
+}
+func (m *MultiDeleteResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_MultiDeleteResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_MultiDeleteResponse proto.InternalMessageInfo
+
+func (m *MultiDeleteResponse) GetResponses() []*DeleteResponse {
+	if m != nil {
+		return m.Responses
+	}
+	return nil
+}
+
+type DeleteResponse struct {
+	Error string `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+	// Number of records deleted
+	Deleted              int64    `protobuf:"varint,2,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DeleteResponse) Reset()         { *m = DeleteResponse{} }
+func (m *DeleteResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteResponse) ProtoMessage()    {}
+func (*DeleteResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{16}
+}
+
+func (m *DeleteResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteResponse.Unmarshal(m, b)
+}
+func (m *DeleteResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteResponse.Marshal(b, m, deterministic)
+}
+func (m *DeleteResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteResponse.Merge(m, src)
+}
+func (m *DeleteResponse) XXX_Size() int {
+	return xxx_messageInfo_DeleteResponse.Size(m)
+}
+func (m *DeleteResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteResponse proto.InternalMessageInfo
+
+func (m *DeleteResponse) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *DeleteResponse) GetDeleted() int64 {
+	if m != nil {
+		return m.Deleted
+	}
+	return 0
+}
+
 type ListSymbolsRequest struct {
-	Format               ListSymbolsRequest_Format `protobuf:"varint,1,opt,name=format,proto3,enum=proto.ListSymbolsRequest_Format" json:"format,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}                  `json:"-"`
-	XXX_unrecognized     []byte                    `json:"-"`
-	XXX_sizecache        int32                     `json:"-"`
+	Format ListSymbolsRequest_Format `protobuf:"varint,1,opt,name=format,proto3,enum=proto.ListSymbolsRequest_Format" json:"format,omitempty"`
+	// Glob pattern of the symbols listed (e.g. "AA*"), all of them if empty
+	Pattern              string   `protobuf:"bytes,2,opt,name=pattern,proto3" json:"pattern,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
 }
 
 func (m *ListSymbolsRequest) Reset()         { *m = ListSymbolsRequest{} }
 func (m *ListSymbolsRequest) String() string { return proto.CompactTextString(m) }
 func (*ListSymbolsRequest) ProtoMessage()    {}
 func (*ListSymbolsRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a89eb64cdc1fc4a5, []int{13}
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{17}
 }
 
 func (m *ListSymbolsRequest) XXX_Unmarshal(b []byte) error {
@@ -862,6 +1194,13 @@ func (m *ListSymbolsRequest) GetFormat() ListSymbolsRequest_Format {
 	return ListSymbolsRequest_SYMBOL
 }
 
+func (m *ListSymbolsRequest) GetPattern() string {
+	if m != nil {
+		return m.Pattern
+	}
+	return ""
+}
+
 type ListSymbolsResponse struct {
 	Results              []string `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -873,7 +1212,7 @@ func (m *ListSymbolsResponse) Reset()         { *m = ListSymbolsResponse{} }
 func (m *ListSymbolsResponse) String() string { return proto.CompactTextString(m) }
 func (*ListSymbolsResponse) ProtoMessage()    {}
 func (*ListSymbolsResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a89eb64cdc1fc4a5, []int{14}
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{18}
 }
 
 func (m *ListSymbolsResponse) XXX_Unmarshal(b []byte) error {
@@ -901,6 +1240,272 @@ func (m *ListSymbolsResponse) GetResults() []string {
 	return nil
 }
 
+type DescribeSymbolRequest struct {
+	Symbol               string   `protobuf:"bytes,1,opt,name=symbol,proto3" json:"symbol,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *DescribeSymbolRequest) Reset()         { *m = DescribeSymbolRequest{} }
+func (m *DescribeSymbolRequest) String() string { return proto.CompactTextString(m) }
+func (*DescribeSymbolRequest) ProtoMessage()    {}
+func (*DescribeSymbolRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{19}
+}
+
+func (m *DescribeSymbolRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DescribeSymbolRequest.Unmarshal(m, b)
+}
+func (m *DescribeSymbolRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DescribeSymbolRequest.Marshal(b, m, deterministic)
+}
+func (m *DescribeSymbolRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DescribeSymbolRequest.Merge(m, src)
+}
+func (m *DescribeSymbolRequest) XXX_Size() int {
+	return xxx_messageInfo_DescribeSymbolRequest.Size(m)
+}
+func (m *DescribeSymbolRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DescribeSymbolRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DescribeSymbolRequest proto.InternalMessageInfo
+
+func (m *DescribeSymbolRequest) GetSymbol() string {
+	if m != nil {
+		return m.Symbol
+	}
+	return ""
+}
+
+type DescribeSymbolResponse struct {
+	// Buckets of the symbol, sorted by the timeframe and attribute group
+	Buckets              []*BucketDescription `protobuf:"bytes,1,rep,name=buckets,proto3" json:"buckets,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *DescribeSymbolResponse) Reset()         { *m = DescribeSymbolResponse{} }
+func (m *DescribeSymbolResponse) String() string { return proto.CompactTextString(m) }
+func (*DescribeSymbolResponse) ProtoMessage()    {}
+func (*DescribeSymbolResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{20}
+}
+
+func (m *DescribeSymbolResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DescribeSymbolResponse.Unmarshal(m, b)
+}
+func (m *DescribeSymbolResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DescribeSymbolResponse.Marshal(b, m, deterministic)
+}
+func (m *DescribeSymbolResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DescribeSymbolResponse.Merge(m, src)
+}
+func (m *DescribeSymbolResponse) XXX_Size() int {
+	return xxx_messageInfo_DescribeSymbolResponse.Size(m)
+}
+func (m *DescribeSymbolResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DescribeSymbolResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DescribeSymbolResponse proto.InternalMessageInfo
+
+func (m *DescribeSymbolResponse) GetBuckets() []*BucketDescription {
+	if m != nil {
+		return m.Buckets
+	}
+	return nil
+}
+
+type BucketDescription struct {
+	// {symbol/timeframe/attributeGroup} name of the bucket
+	Key            string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Timeframe      string `protobuf:"bytes,2,opt,name=timeframe,proto3" json:"timeframe,omitempty"`
+	AttributeGroup string `protobuf:"bytes,3,opt,name=attribute_group,json=attributeGroup,proto3" json:"attribute_group,omitempty"`
+	// Columns of the bucket after the Epoch
+	DataShapes     []*DataShape `protobuf:"bytes,4,rep,name=data_shapes,json=dataShapes,proto3" json:"data_shapes,omitempty"`
+	VariableLength bool         `protobuf:"varint,5,opt,name=variable_length,json=variableLength,proto3" json:"variable_length,omitempty"`
+	// Time bounds of the data of the bucket, 0 if it has none
+	EpochStart           int64    `protobuf:"varint,6,opt,name=epoch_start,json=epochStart,proto3" json:"epoch_start,omitempty"`
+	EpochEnd             int64    `protobuf:"varint,7,opt,name=epoch_end,json=epochEnd,proto3" json:"epoch_end,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *BucketDescription) Reset()         { *m = BucketDescription{} }
+func (m *BucketDescription) String() string { return proto.CompactTextString(m) }
+func (*BucketDescription) ProtoMessage()    {}
+func (*BucketDescription) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{21}
+}
+
+func (m *BucketDescription) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_BucketDescription.Unmarshal(m, b)
+}
+func (m *BucketDescription) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_BucketDescription.Marshal(b, m, deterministic)
+}
+func (m *BucketDescription) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_BucketDescription.Merge(m, src)
+}
+func (m *BucketDescription) XXX_Size() int {
+	return xxx_messageInfo_BucketDescription.Size(m)
+}
+func (m *BucketDescription) XXX_DiscardUnknown() {
+	xxx_messageInfo_BucketDescription.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_BucketDescription proto.InternalMessageInfo
+
+func (m *BucketDescription) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *BucketDescription) GetTimeframe() string {
+	if m != nil {
+		return m.Timeframe
+	}
+	return ""
+}
+
+func (m *BucketDescription) GetAttributeGroup() string {
+	if m != nil {
+		return m.AttributeGroup
+	}
+	return ""
+}
+
+func (m *BucketDescription) GetDataShapes() []*DataShape {
+	if m != nil {
+		return m.DataShapes
+	}
+	return nil
+}
+
+func (m *BucketDescription) GetVariableLength() bool {
+	if m != nil {
+		return m.VariableLength
+	}
+	return false
+}
+
+func (m *BucketDescription) GetEpochStart() int64 {
+	if m != nil {
+		return m.EpochStart
+	}
+	return 0
+}
+
+func (m *BucketDescription) GetEpochEnd() int64 {
+	if m != nil {
+		return m.EpochEnd
+	}
+	return 0
+}
+
+type EnsureBucketRequest struct {
+	// {symbol/timeframe/attributeGroup} name of the bucket
+	Key string `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	// Columns of the bucket after the Epoch
+	DataShapes           []*DataShape `protobuf:"bytes,2,rep,name=data_shapes,json=dataShapes,proto3" json:"data_shapes,omitempty"`
+	VariableLength       bool         `protobuf:"varint,3,opt,name=variable_length,json=variableLength,proto3" json:"variable_length,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}     `json:"-"`
+	XXX_unrecognized     []byte       `json:"-"`
+	XXX_sizecache        int32        `json:"-"`
+}
+
+func (m *EnsureBucketRequest) Reset()         { *m = EnsureBucketRequest{} }
+func (m *EnsureBucketRequest) String() string { return proto.CompactTextString(m) }
+func (*EnsureBucketRequest) ProtoMessage()    {}
+func (*EnsureBucketRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{22}
+}
+
+func (m *EnsureBucketRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EnsureBucketRequest.Unmarshal(m, b)
+}
+func (m *EnsureBucketRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EnsureBucketRequest.Marshal(b, m, deterministic)
+}
+func (m *EnsureBucketRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EnsureBucketRequest.Merge(m, src)
+}
+func (m *EnsureBucketRequest) XXX_Size() int {
+	return xxx_messageInfo_EnsureBucketRequest.Size(m)
+}
+func (m *EnsureBucketRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_EnsureBucketRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EnsureBucketRequest proto.InternalMessageInfo
+
+func (m *EnsureBucketRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *EnsureBucketRequest) GetDataShapes() []*DataShape {
+	if m != nil {
+		return m.DataShapes
+	}
+	return nil
+}
+
+func (m *EnsureBucketRequest) GetVariableLength() bool {
+	if m != nil {
+		return m.VariableLength
+	}
+	return false
+}
+
+type EnsureBucketResponse struct {
+	// True if the bucket was created, false if it already existed
+	Created              bool     `protobuf:"varint,1,opt,name=created,proto3" json:"created,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *EnsureBucketResponse) Reset()         { *m = EnsureBucketResponse{} }
+func (m *EnsureBucketResponse) String() string { return proto.CompactTextString(m) }
+func (*EnsureBucketResponse) ProtoMessage()    {}
+func (*EnsureBucketResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{23}
+}
+
+func (m *EnsureBucketResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_EnsureBucketResponse.Unmarshal(m, b)
+}
+func (m *EnsureBucketResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_EnsureBucketResponse.Marshal(b, m, deterministic)
+}
+func (m *EnsureBucketResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_EnsureBucketResponse.Merge(m, src)
+}
+func (m *EnsureBucketResponse) XXX_Size() int {
+	return xxx_messageInfo_EnsureBucketResponse.Size(m)
+}
+func (m *EnsureBucketResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_EnsureBucketResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_EnsureBucketResponse proto.InternalMessageInfo
+
+func (m *EnsureBucketResponse) GetCreated() bool {
+	if m != nil {
+		return m.Created
+	}
+	return false
+}
+
 type ServerVersionRequest struct {
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
@@ -911,7 +1516,7 @@ func (m *ServerVersionRequest) Reset()         { *m = ServerVersionRequest{} }
 func (m *ServerVersionRequest) String() string { return proto.CompactTextString(m) }
 func (*ServerVersionRequest) ProtoMessage()    {}
 func (*ServerVersionRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a89eb64cdc1fc4a5, []int{15}
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{24}
 }
 
 func (m *ServerVersionRequest) XXX_Unmarshal(b []byte) error {
@@ -933,7 +1538,15 @@ func (m *ServerVersionRequest) XXX_DiscardUnknown() {
 var xxx_messageInfo_ServerVersionRequest proto.InternalMessageInfo
 
 type ServerVersionResponse struct {
-	Version              string   `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	// The git commit of the build, kept for the existing clients
+	Version string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	// The version tag of the build
+	Tag     string `protobuf:"bytes,2,opt,name=tag,proto3" json:"tag,omitempty"`
+	GitHash string `protobuf:"bytes,3,opt,name=git_hash,json=gitHash,proto3" json:"git_hash,omitempty"`
+	// The UTC build time
+	BuildTime string `protobuf:"bytes,4,opt,name=build_time,json=buildTime,proto3" json:"build_time,omitempty"`
+	// The version of Go the server was built with
+	GoVersion            string   `protobuf:"bytes,5,opt,name=go_version,json=goVersion,proto3" json:"go_version,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -943,7 +1556,7 @@ func (m *ServerVersionResponse) Reset()         { *m = ServerVersionResponse{} }
 func (m *ServerVersionResponse) String() string { return proto.CompactTextString(m) }
 func (*ServerVersionResponse) ProtoMessage()    {}
 func (*ServerVersionResponse) Descriptor() ([]byte, []int) {
-	return fileDescriptor_a89eb64cdc1fc4a5, []int{16}
+	return fileDescriptor_a89eb64cdc1fc4a5, []int{25}
 }
 
 func (m *ServerVersionResponse) XXX_Unmarshal(b []byte) error {
@@ -971,6 +1584,34 @@ func (m *ServerVersionResponse) GetVersion() string {
 	return ""
 }
 
+func (m *ServerVersionResponse) GetTag() string {
+	if m != nil {
+		return m.Tag
+	}
+	return ""
+}
+
+func (m *ServerVersionResponse) GetGitHash() string {
+	if m != nil {
+		return m.GitHash
+	}
+	return ""
+}
+
+func (m *ServerVersionResponse) GetBuildTime() string {
+	if m != nil {
+		return m.BuildTime
+	}
+	return ""
+}
+
+func (m *ServerVersionResponse) GetGoVersion() string {
+	if m != nil {
+		return m.GoVersion
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterEnum("proto.DataType", DataType_name, DataType_value)
 	proto.RegisterEnum("proto.ListSymbolsRequest_Format", ListSymbolsRequest_Format_name, ListSymbolsRequest_Format_value)
@@ -981,16 +1622,28 @@ func init() {
 	proto.RegisterType((*NumpyDataset)(nil), "proto.NumpyDataset")
 	proto.RegisterType((*MultiQueryRequest)(nil), "proto.MultiQueryRequest")
 	proto.RegisterType((*QueryRequest)(nil), "proto.QueryRequest")
+	proto.RegisterMapType((map[string]string)(nil), "proto.QueryRequest.ResampleReducersEntry")
 	proto.RegisterType((*MultiQueryResponse)(nil), "proto.MultiQueryResponse")
 	proto.RegisterType((*QueryResponse)(nil), "proto.QueryResponse")
+	proto.RegisterMapType((map[string]string)(nil), "proto.QueryResponse.ErrorsEntry")
+	proto.RegisterMapType((map[string]*NumpyMultiDataset)(nil), "proto.QueryResponse.TimeframeResultsEntry")
 	proto.RegisterType((*MultiWriteRequest)(nil), "proto.MultiWriteRequest")
 	proto.RegisterType((*WriteRequest)(nil), "proto.WriteRequest")
 	proto.RegisterType((*MultiServerResponse)(nil), "proto.MultiServerResponse")
 	proto.RegisterType((*ServerResponse)(nil), "proto.ServerResponse")
 	proto.RegisterType((*MultiKeyRequest)(nil), "proto.MultiKeyRequest")
 	proto.RegisterType((*KeyRequest)(nil), "proto.KeyRequest")
+	proto.RegisterType((*MultiDeleteRequest)(nil), "proto.MultiDeleteRequest")
+	proto.RegisterType((*DeleteRequest)(nil), "proto.DeleteRequest")
+	proto.RegisterType((*MultiDeleteResponse)(nil), "proto.MultiDeleteResponse")
+	proto.RegisterType((*DeleteResponse)(nil), "proto.DeleteResponse")
 	proto.RegisterType((*ListSymbolsRequest)(nil), "proto.ListSymbolsRequest")
 	proto.RegisterType((*ListSymbolsResponse)(nil), "proto.ListSymbolsResponse")
+	proto.RegisterType((*DescribeSymbolRequest)(nil), "proto.DescribeSymbolRequest")
+	proto.RegisterType((*DescribeSymbolResponse)(nil), "proto.DescribeSymbolResponse")
+	proto.RegisterType((*BucketDescription)(nil), "proto.BucketDescription")
+	proto.RegisterType((*EnsureBucketRequest)(nil), "proto.EnsureBucketRequest")
+	proto.RegisterType((*EnsureBucketResponse)(nil), "proto.EnsureBucketResponse")
 	proto.RegisterType((*ServerVersionRequest)(nil), "proto.ServerVersionRequest")
 	proto.RegisterType((*ServerVersionResponse)(nil), "proto.ServerVersionResponse")
 }
@@ -1000,76 +1653,118 @@ func init() {
 }
 
 var fileDescriptor_a89eb64cdc1fc4a5 = []byte{
-	// 1099 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x56, 0x5d, 0x53, 0xdb, 0x46,
-	0x14, 0x8d, 0xfc, 0xed, 0x2b, 0x83, 0xc5, 0x42, 0x32, 0x8a, 0x93, 0x69, 0x5d, 0x65, 0xda, 0xba,
-	0x19, 0x4a, 0x8a, 0x61, 0x18, 0x26, 0x53, 0xa6, 0x09, 0x60, 0x5a, 0x07, 0x90, 0x5b, 0xd9, 0x24,
-	0xc3, 0x93, 0x46, 0xd8, 0x4b, 0x50, 0xb1, 0x24, 0xb3, 0xbb, 0x66, 0xaa, 0x3e, 0xf4, 0x67, 0xf5,
-	0xb9, 0x33, 0xed, 0xbf, 0xe8, 0x9f, 0xe9, 0xec, 0x87, 0x6c, 0x09, 0x70, 0x3b, 0x7d, 0xe2, 0xee,
-	0xb9, 0xe7, 0x5e, 0xed, 0x9e, 0x7b, 0x76, 0x0d, 0xac, 0x04, 0x1e, 0xb9, 0xc6, 0x8c, 0xb2, 0x88,
-	0xe0, 0x8d, 0x09, 0x89, 0x58, 0x84, 0x8a, 0xe2, 0x8f, 0x75, 0x08, 0xd5, 0x43, 0x8f, 0x79, 0xfd,
-	0x2b, 0x6f, 0x82, 0x11, 0x82, 0x42, 0xe8, 0x05, 0xd8, 0xd4, 0x9a, 0x5a, 0xab, 0xea, 0x88, 0x18,
-	0xbd, 0x80, 0x02, 0x8b, 0x27, 0xd8, 0xcc, 0x35, 0xb5, 0xd6, 0x72, 0xbb, 0x2e, 0xab, 0x37, 0x78,
-	0xcd, 0x20, 0x9e, 0x60, 0x47, 0x24, 0xad, 0x3f, 0x73, 0xb0, 0x62, 0x4f, 0x83, 0x49, 0x7c, 0x3a,
-	0x1d, 0x33, 0x9f, 0x27, 0x29, 0x66, 0xe8, 0x4b, 0x28, 0x8c, 0x3c, 0xe6, 0x89, 0x76, 0x7a, 0x7b,
-	0x55, 0x95, 0x0a, 0x9e, 0xa2, 0x38, 0x82, 0x80, 0xba, 0xa0, 0x53, 0xe6, 0x11, 0xe6, 0xfa, 0xe1,
-	0x08, 0xff, 0x62, 0xe6, 0x9a, 0xf9, 0x96, 0xde, 0x6e, 0xa5, 0xf9, 0xe9, 0xbe, 0x1b, 0x7d, 0xce,
-	0xed, 0x72, 0x6a, 0x27, 0x64, 0x24, 0x76, 0x80, 0xce, 0x00, 0xf4, 0x1d, 0x94, 0xc7, 0x38, 0xfc,
-	0xc8, 0xae, 0xa8, 0x99, 0x17, 0x6d, 0x3e, 0x5f, 0xd8, 0xe6, 0x44, 0xf2, 0x64, 0x8f, 0xa4, 0xaa,
-	0xb1, 0x07, 0xf5, 0x3b, 0xfd, 0x91, 0x01, 0xf9, 0x6b, 0x1c, 0x2b, 0x55, 0x78, 0x88, 0xd6, 0xa0,
-	0x78, 0xeb, 0x8d, 0xa7, 0x52, 0x95, 0xa2, 0x23, 0x17, 0xaf, 0x73, 0xbb, 0x5a, 0xe3, 0x35, 0xd4,
-	0xd2, 0x7d, 0xff, 0x4f, 0xad, 0xf5, 0x87, 0x06, 0xb5, 0xb4, 0x3a, 0xe8, 0x33, 0xa8, 0x0d, 0xa3,
-	0xf1, 0x34, 0x08, 0x5d, 0xae, 0x32, 0x35, 0xb5, 0x66, 0xbe, 0x55, 0x75, 0x74, 0x89, 0x71, 0xf9,
-	0x69, 0x8a, 0xc2, 0xa7, 0x45, 0x85, 0x76, 0x33, 0x8a, 0xcd, 0x21, 0xf4, 0x29, 0xa8, 0xa5, 0x2b,
-	0xa6, 0xc1, 0x65, 0xa9, 0x39, 0x20, 0x21, 0xfe, 0x25, 0xf4, 0x04, 0x4a, 0xf2, 0xf4, 0x66, 0x41,
-	0x6c, 0x49, 0xad, 0xd0, 0x26, 0xe8, 0xbc, 0xc2, 0xa5, 0xdc, 0x1c, 0xd4, 0x2c, 0x0a, 0x3d, 0x8d,
-	0x94, 0x03, 0x84, 0x6b, 0x1c, 0x18, 0x25, 0x21, 0xb5, 0x0e, 0x61, 0x45, 0x68, 0xfc, 0xd3, 0x14,
-	0x93, 0xd8, 0xc1, 0x37, 0x53, 0x4c, 0x19, 0x7a, 0x05, 0x15, 0x22, 0x43, 0x79, 0x84, 0xb9, 0x17,
-	0xd2, 0x34, 0x67, 0x46, 0xb2, 0xfe, 0xca, 0x43, 0x2d, 0xd3, 0xa1, 0x05, 0x86, 0x4f, 0x5d, 0x7a,
-	0x33, 0x76, 0x29, 0xf3, 0x18, 0x0e, 0x70, 0xc8, 0x84, 0xa4, 0x15, 0x67, 0xd9, 0xa7, 0xfd, 0x9b,
-	0x71, 0x3f, 0x41, 0xd1, 0x0b, 0x58, 0xca, 0xd2, 0x72, 0x42, 0xf9, 0x1a, 0x4d, 0x93, 0x9a, 0xa0,
-	0x8f, 0x30, 0x65, 0x7e, 0xe8, 0x31, 0x3f, 0x0a, 0xcd, 0xbc, 0xa0, 0xa4, 0x21, 0x2e, 0xeb, 0x35,
-	0x8e, 0xdd, 0xa1, 0xc7, 0xf0, 0xc7, 0x88, 0xc4, 0x42, 0x98, 0xaa, 0xa3, 0x5f, 0xe3, 0xf8, 0x40,
-	0x41, 0x5c, 0x56, 0x3c, 0x89, 0x86, 0x57, 0xae, 0x70, 0x9f, 0x59, 0x6c, 0x6a, 0xad, 0xbc, 0x03,
-	0x02, 0x12, 0x06, 0x42, 0x2f, 0x61, 0x25, 0x45, 0x70, 0x43, 0x2f, 0x8c, 0xa8, 0x59, 0x12, 0xb4,
-	0xfa, 0x9c, 0x66, 0x73, 0x18, 0x3d, 0x83, 0xaa, 0xe4, 0xe2, 0x70, 0x64, 0x96, 0x05, 0xa7, 0x22,
-	0x80, 0x4e, 0x38, 0x42, 0x5f, 0x40, 0x7d, 0x96, 0x54, 0x6d, 0x2a, 0x82, 0xb2, 0x94, 0x50, 0x64,
-	0x93, 0x75, 0x40, 0x63, 0x3f, 0xf0, 0x99, 0x4b, 0xf0, 0x30, 0x22, 0x23, 0x77, 0x18, 0x4d, 0x43,
-	0x66, 0x56, 0xc5, 0x4c, 0x0d, 0x91, 0x71, 0x44, 0xe2, 0x80, 0xe3, 0x5c, 0x53, 0xc9, 0xbe, 0x24,
-	0x51, 0xa0, 0x0e, 0x01, 0x52, 0x53, 0x81, 0x1f, 0x91, 0x28, 0x90, 0x07, 0x31, 0xa1, 0x2c, 0xdd,
-	0x42, 0x4d, 0x5d, 0xd8, 0x2b, 0x59, 0xa2, 0xe7, 0x50, 0xbd, 0x9c, 0x86, 0x43, 0x2e, 0x19, 0x35,
-	0x6b, 0x22, 0x37, 0x07, 0xac, 0xdf, 0x00, 0xa5, 0xcd, 0x40, 0x27, 0x51, 0x48, 0x31, 0x6a, 0x43,
-	0x95, 0xa8, 0x38, 0xb1, 0xc3, 0x5a, 0xd6, 0x0e, 0x32, 0xe9, 0xcc, 0x69, 0x7c, 0x07, 0xb7, 0x98,
-	0x50, 0x3e, 0x2c, 0x39, 0xcf, 0x64, 0x89, 0x1a, 0x50, 0x61, 0x7e, 0x80, 0x7f, 0x8d, 0x42, 0xac,
-	0xe6, 0x38, 0x5b, 0x5b, 0x6f, 0x61, 0x29, 0xfb, 0xe9, 0x6f, 0xa0, 0x44, 0x30, 0x9d, 0x8e, 0x99,
-	0x7a, 0x92, 0xcc, 0x45, 0x6f, 0x83, 0xa3, 0x78, 0x33, 0x3f, 0x7f, 0x20, 0x3e, 0xc3, 0xff, 0xed,
-	0xe7, 0x34, 0x2d, 0xe5, 0xe7, 0x9f, 0xa1, 0x96, 0x69, 0xb0, 0x9e, 0x79, 0x18, 0x17, 0xef, 0x42,
-	0xbe, 0x8e, 0xeb, 0x80, 0x7c, 0xea, 0xde, 0x7a, 0xc4, 0xf7, 0x2e, 0xc6, 0xd8, 0x55, 0x57, 0x35,
-	0x27, 0x46, 0x65, 0xf8, 0xf4, 0xbd, 0x4a, 0xc8, 0x67, 0xc7, 0x7a, 0x07, 0xab, 0xa2, 0x47, 0x1f,
-	0x93, 0x5b, 0x4c, 0x66, 0x47, 0xdf, 0xba, 0xaf, 0xfa, 0x63, 0xf5, 0xdd, 0x2c, 0x33, 0x25, 0xbb,
-	0xf5, 0x06, 0x96, 0xef, 0xb4, 0x59, 0x83, 0x22, 0x26, 0x24, 0x22, 0xea, 0x41, 0x93, 0x8b, 0xc5,
-	0xe3, 0xb1, 0xde, 0x40, 0x5d, 0xec, 0xe6, 0x18, 0xcf, 0xee, 0xf2, 0xd7, 0xf7, 0xd4, 0x5b, 0x51,
-	0x1b, 0x99, 0x93, 0x52, 0xda, 0x7d, 0x02, 0x90, 0x2a, 0xbe, 0xf7, 0x9c, 0x5a, 0x31, 0xa0, 0x13,
-	0x9f, 0xb2, 0x7e, 0x1c, 0x5c, 0x44, 0x63, 0x9a, 0xf0, 0x76, 0xa1, 0x74, 0x19, 0x91, 0xc0, 0x93,
-	0x93, 0x5e, 0x6e, 0x37, 0xd5, 0x27, 0xee, 0x53, 0x37, 0x8e, 0x04, 0xcf, 0x51, 0x7c, 0xeb, 0x2b,
-	0x28, 0x49, 0x04, 0x01, 0x94, 0xfa, 0xe7, 0xa7, 0xfb, 0xbd, 0x13, 0xe3, 0x11, 0x5a, 0x85, 0xfa,
-	0xa0, 0x7b, 0xda, 0x71, 0xf7, 0xcf, 0x0e, 0x8e, 0x3b, 0x03, 0xf7, 0xb8, 0x73, 0x6e, 0x68, 0xd6,
-	0x2b, 0x58, 0xcd, 0xf4, 0x53, 0x1a, 0x99, 0x50, 0x96, 0xee, 0x49, 0x1e, 0xec, 0x64, 0x69, 0x3d,
-	0x81, 0x35, 0xa9, 0xe7, 0x7b, 0x29, 0x8f, 0xda, 0x82, 0xb5, 0x09, 0x8f, 0xef, 0xe0, 0xf3, 0x56,
-	0x89, 0xb0, 0x5a, 0x46, 0xd8, 0x97, 0xbf, 0x6b, 0x50, 0x49, 0x7e, 0x84, 0x91, 0x0e, 0xe5, 0x33,
-	0xfb, 0xd8, 0xee, 0x7d, 0xb0, 0x8d, 0x47, 0x7c, 0x71, 0x74, 0xd2, 0x7b, 0x3b, 0xd8, 0x6a, 0x1b,
-	0x1a, 0xaa, 0x42, 0xb1, 0x6b, 0xf3, 0x30, 0x37, 0xc3, 0x77, 0xb6, 0x8d, 0xbc, 0xc2, 0x77, 0xb6,
-	0x8d, 0x02, 0x0f, 0x3b, 0x3f, 0xf6, 0x0e, 0x7e, 0x30, 0x8a, 0xa8, 0x02, 0x85, 0xfd, 0xf3, 0x41,
-	0xc7, 0x28, 0x89, 0xa8, 0xd7, 0x3b, 0x31, 0xca, 0x3c, 0xb2, 0x7b, 0x76, 0xc7, 0xa8, 0x08, 0x3d,
-	0x06, 0x4e, 0xd7, 0xfe, 0xde, 0xa8, 0xaa, 0xfa, 0xcd, 0x1d, 0x03, 0x78, 0x78, 0xd6, 0xb5, 0x07,
-	0xbb, 0x86, 0xce, 0x19, 0x67, 0x12, 0xae, 0x25, 0xf1, 0x56, 0xdb, 0x58, 0x4a, 0xe2, 0x9d, 0x6d,
-	0x63, 0xb9, 0xfd, 0x77, 0x0e, 0xf4, 0xd3, 0xf9, 0x7f, 0x23, 0xe8, 0x5b, 0x28, 0x8a, 0x4b, 0x8a,
-	0x92, 0x6b, 0x70, 0xef, 0xf7, 0xa3, 0xf1, 0xf4, 0x81, 0x8c, 0x12, 0x68, 0x0f, 0x8a, 0xe2, 0x66,
-	0x65, 0xab, 0xd3, 0x97, 0xad, 0xd1, 0x48, 0x67, 0xee, 0xd8, 0x79, 0x0f, 0xca, 0x87, 0x98, 0x32,
-	0x12, 0xc5, 0xe8, 0x49, 0x9a, 0x36, 0x77, 0xdc, 0xbf, 0x96, 0x1f, 0x82, 0x9e, 0x32, 0x00, 0x7a,
-	0xba, 0xd0, 0x64, 0xb3, 0x2e, 0x0f, 0xf9, 0xe5, 0x1d, 0x2c, 0x65, 0xa6, 0x8f, 0x9e, 0x65, 0x2e,
-	0x66, 0xd6, 0x2b, 0x8d, 0xe7, 0x0f, 0x27, 0x65, 0xaf, 0x8b, 0x92, 0x48, 0x6e, 0xfd, 0x13, 0x00,
-	0x00, 0xff, 0xff, 0xfe, 0xbc, 0x2b, 0xf5, 0xf1, 0x09, 0x00, 0x00,
+	// 1768 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x57, 0xdd, 0x6e, 0xdb, 0xc8,
+	0x15, 0x5e, 0x4a, 0xd6, 0xdf, 0x91, 0x2c, 0x51, 0xe3, 0x9f, 0x72, 0x99, 0xec, 0x56, 0xe5, 0xa2,
+	0x5d, 0x25, 0xd8, 0x3a, 0x59, 0x27, 0x08, 0xd2, 0xa0, 0xc1, 0x6e, 0x63, 0xcb, 0x5e, 0xaf, 0x6d,
+	0xb9, 0xa5, 0x94, 0x04, 0x59, 0xa0, 0x20, 0x28, 0x69, 0x2c, 0xb3, 0xa6, 0x48, 0x65, 0x66, 0xe4,
+	0x56, 0x7b, 0xd1, 0x9b, 0x02, 0x7d, 0x81, 0xde, 0xf6, 0x09, 0xfa, 0x00, 0xbd, 0x2e, 0xd0, 0xf7,
+	0x29, 0xfa, 0x08, 0xc5, 0xfc, 0x90, 0x22, 0x29, 0x3a, 0x46, 0x7a, 0xa5, 0x99, 0xef, 0x7c, 0x73,
+	0xce, 0xcc, 0xf9, 0xa5, 0xa0, 0x3d, 0x73, 0xc9, 0x35, 0x66, 0x94, 0x85, 0x04, 0xef, 0xcd, 0x49,
+	0xc8, 0x42, 0x54, 0x12, 0x3f, 0xd6, 0x0f, 0x50, 0x3b, 0x74, 0x99, 0x3b, 0xb8, 0x72, 0xe7, 0x18,
+	0x21, 0xd8, 0x08, 0xdc, 0x19, 0x36, 0xb4, 0x8e, 0xd6, 0xad, 0xd9, 0x62, 0x8d, 0xbe, 0x80, 0x0d,
+	0xb6, 0x9c, 0x63, 0xa3, 0xd0, 0xd1, 0xba, 0xcd, 0xfd, 0x96, 0x3c, 0xbd, 0xc7, 0xcf, 0x0c, 0x97,
+	0x73, 0x6c, 0x0b, 0x21, 0xda, 0x86, 0x12, 0x1d, 0xbb, 0x3e, 0x36, 0x8a, 0x1d, 0xad, 0x5b, 0xb2,
+	0xe5, 0xc6, 0xfa, 0x77, 0x01, 0xda, 0xfd, 0xc5, 0x6c, 0xbe, 0x3c, 0x5f, 0xf8, 0xcc, 0xe3, 0x47,
+	0x28, 0x66, 0xe8, 0x4b, 0xd8, 0x98, 0xb8, 0xcc, 0x15, 0x46, 0xea, 0xfb, 0x5b, 0x4a, 0xa1, 0xe0,
+	0x29, 0x8a, 0x2d, 0x08, 0xe8, 0x04, 0xea, 0x94, 0xb9, 0x84, 0x39, 0x5e, 0x30, 0xc1, 0x7f, 0x32,
+	0x0a, 0x9d, 0x62, 0xb7, 0xbe, 0xdf, 0x4d, 0xf2, 0x93, 0x7a, 0xf7, 0x06, 0x9c, 0x7b, 0xc2, 0xa9,
+	0xbd, 0x80, 0x91, 0xa5, 0x0d, 0x34, 0x06, 0xd0, 0x37, 0x50, 0xf1, 0x71, 0x30, 0x65, 0x57, 0xd4,
+	0x28, 0x0a, 0x35, 0x3f, 0xbf, 0x55, 0xcd, 0x99, 0xe4, 0x49, 0x1d, 0xd1, 0x29, 0xf3, 0x25, 0xb4,
+	0x32, 0xfa, 0x91, 0x0e, 0xc5, 0x6b, 0xbc, 0x54, 0xbe, 0xe2, 0x4b, 0xee, 0x85, 0x1b, 0xd7, 0x5f,
+	0x48, 0x5f, 0x95, 0x6c, 0xb9, 0x79, 0x51, 0x78, 0xae, 0x99, 0x2f, 0xa0, 0x91, 0xd4, 0xfb, 0x31,
+	0x67, 0xad, 0x7f, 0x69, 0xd0, 0x48, 0x7a, 0x07, 0xfd, 0x0c, 0x1a, 0xe3, 0xd0, 0x5f, 0xcc, 0x02,
+	0x87, 0xfb, 0x9e, 0x1a, 0x5a, 0xa7, 0xd8, 0xad, 0xd9, 0x75, 0x89, 0xf1, 0xa0, 0xd0, 0x04, 0x85,
+	0xc7, 0x90, 0x1a, 0x85, 0x24, 0xa5, 0xcf, 0x21, 0xf4, 0x53, 0x50, 0x5b, 0x47, 0x44, 0x83, 0xbb,
+	0xa5, 0x61, 0x83, 0x84, 0xb8, 0x25, 0xb4, 0x0b, 0x65, 0xf9, 0x7a, 0x63, 0x43, 0x5c, 0x49, 0xed,
+	0xd0, 0xd7, 0x50, 0xe7, 0x27, 0x1c, 0xca, 0x53, 0x86, 0x1a, 0x25, 0xe1, 0x4f, 0x3d, 0x91, 0x17,
+	0x22, 0x97, 0x6c, 0x98, 0x44, 0x4b, 0x6a, 0x1d, 0x42, 0x5b, 0xf8, 0xf8, 0x77, 0x0b, 0x4c, 0x96,
+	0x36, 0x7e, 0xbf, 0xc0, 0x94, 0xa1, 0x47, 0x50, 0x25, 0x72, 0x29, 0x9f, 0xb0, 0xca, 0x85, 0x24,
+	0xcd, 0x8e, 0x49, 0xd6, 0x3f, 0x2a, 0xd0, 0x48, 0x69, 0xe8, 0x82, 0xee, 0x51, 0x87, 0xbe, 0xf7,
+	0x1d, 0xca, 0x5c, 0x86, 0x67, 0x38, 0x60, 0xc2, 0xa5, 0x55, 0xbb, 0xe9, 0xd1, 0xc1, 0x7b, 0x7f,
+	0x10, 0xa1, 0xe8, 0x0b, 0xd8, 0x4c, 0xd3, 0x0a, 0xc2, 0xf3, 0x0d, 0x9a, 0x24, 0x75, 0xa0, 0x3e,
+	0xc1, 0x94, 0x79, 0x81, 0xcb, 0xbc, 0x30, 0x10, 0xa9, 0x5c, 0xb3, 0x93, 0x10, 0x77, 0xeb, 0x35,
+	0x5e, 0x3a, 0x63, 0x97, 0xe1, 0x69, 0x48, 0x96, 0xc2, 0x31, 0x35, 0xbb, 0x7e, 0x8d, 0x97, 0x07,
+	0x0a, 0xe2, 0x6e, 0xc5, 0xf3, 0x70, 0x7c, 0xe5, 0x88, 0xec, 0x33, 0x4a, 0x1d, 0xad, 0x5b, 0xb4,
+	0x41, 0x40, 0x22, 0x81, 0xd0, 0x43, 0x68, 0x27, 0x08, 0x4e, 0xe0, 0x06, 0x21, 0x35, 0xca, 0x82,
+	0xd6, 0x5a, 0xd1, 0xfa, 0x1c, 0x46, 0xf7, 0xa0, 0x26, 0xb9, 0x38, 0x98, 0x18, 0x15, 0xc1, 0xa9,
+	0x0a, 0xa0, 0x17, 0x4c, 0xd0, 0x2f, 0xa0, 0x15, 0x0b, 0x95, 0x9a, 0xaa, 0xa0, 0x6c, 0x46, 0x14,
+	0xa9, 0xe4, 0x2b, 0x40, 0xbe, 0x37, 0xf3, 0x98, 0x43, 0xf0, 0x38, 0x24, 0x13, 0x67, 0x1c, 0x2e,
+	0x02, 0x66, 0xd4, 0x44, 0x4c, 0x75, 0x21, 0xb1, 0x85, 0xe0, 0x80, 0xe3, 0xdc, 0xa7, 0x92, 0x7d,
+	0x49, 0xc2, 0x99, 0x7a, 0x04, 0x48, 0x9f, 0x0a, 0xfc, 0x88, 0x84, 0x33, 0xf9, 0x10, 0x03, 0x2a,
+	0x32, 0x5b, 0xa8, 0x51, 0x17, 0xe9, 0x15, 0x6d, 0xd1, 0x7d, 0xa8, 0x5d, 0x2e, 0x82, 0x31, 0x77,
+	0x19, 0x35, 0x1a, 0x42, 0xb6, 0x02, 0x90, 0xc9, 0xe3, 0x4e, 0xdd, 0xd9, 0xdc, 0xc7, 0xc6, 0xa6,
+	0x70, 0x60, 0xbc, 0x47, 0x6f, 0xa0, 0x1d, 0xad, 0x1d, 0x82, 0x27, 0x8b, 0x31, 0x26, 0xd4, 0x68,
+	0x8a, 0xe4, 0x78, 0x90, 0x93, 0x1c, 0x7b, 0xb6, 0x22, 0xdb, 0x8a, 0x2b, 0xab, 0x56, 0x27, 0x19,
+	0x98, 0x3b, 0xf2, 0xd2, 0xf3, 0x7d, 0x67, 0xea, 0xce, 0xa9, 0xd1, 0x12, 0xcf, 0xa9, 0x72, 0xe0,
+	0xd8, 0x9d, 0x8b, 0x62, 0x11, 0xc2, 0xcb, 0x90, 0xfc, 0xd1, 0x25, 0x13, 0x43, 0x17, 0xf2, 0x3a,
+	0xc7, 0x8e, 0x24, 0x14, 0x9f, 0xff, 0x11, 0x93, 0xd0, 0x68, 0xaf, 0xce, 0xff, 0x80, 0x49, 0xc8,
+	0x93, 0x4b, 0x08, 0x79, 0xcf, 0x0b, 0x26, 0x2e, 0x31, 0x90, 0x4c, 0x2e, 0x0e, 0x1e, 0x28, 0x8c,
+	0x7b, 0x8b, 0x2e, 0x67, 0xa3, 0xd0, 0xa7, 0xc6, 0x96, 0xf4, 0x96, 0xda, 0xf2, 0x8c, 0x91, 0x4b,
+	0x67, 0xea, 0x87, 0x23, 0x63, 0x5b, 0x1c, 0x06, 0x09, 0x1d, 0xfb, 0xe1, 0x08, 0x7d, 0x0e, 0xc0,
+	0xbc, 0x19, 0xbe, 0x24, 0xa2, 0x94, 0x77, 0xc4, 0xe9, 0x04, 0xc2, 0xed, 0xfb, 0x2e, 0x65, 0x0e,
+	0xc5, 0x94, 0x0a, 0x97, 0xef, 0x8a, 0xd8, 0x36, 0x38, 0x38, 0x50, 0x18, 0x7a, 0x00, 0xba, 0x92,
+	0xaf, 0xee, 0xf9, 0x13, 0x61, 0xaa, 0xa5, 0xf0, 0xe8, 0xaa, 0xe6, 0x01, 0xec, 0xe4, 0xfa, 0xf5,
+	0xae, 0xae, 0x55, 0x4b, 0x76, 0xad, 0x3f, 0x03, 0x4a, 0x96, 0x3c, 0x9d, 0x87, 0x01, 0xc5, 0x68,
+	0x1f, 0x6a, 0x44, 0xad, 0xa3, 0xa2, 0xdf, 0x4e, 0xc7, 0x55, 0x0a, 0xed, 0x15, 0x8d, 0x7b, 0xee,
+	0x06, 0x13, 0x7e, 0x43, 0x65, 0x25, 0xda, 0xf2, 0x4c, 0xe2, 0x6e, 0xf8, 0x31, 0x0c, 0xb0, 0xaa,
+	0xd6, 0x78, 0x6f, 0xfd, 0xa7, 0x00, 0x9b, 0x69, 0xdb, 0x8f, 0xa1, 0x4c, 0x30, 0x5d, 0xf8, 0x4c,
+	0x4d, 0x1e, 0xe3, 0xb6, 0x11, 0x60, 0x2b, 0x1e, 0x7a, 0x0e, 0x65, 0x4c, 0x48, 0x48, 0xa8, 0x9a,
+	0x3d, 0x9d, 0xbc, 0xab, 0xee, 0xf5, 0x04, 0x45, 0x66, 0x9e, 0xe2, 0xa3, 0xb7, 0xd0, 0x8e, 0x03,
+	0xe4, 0x48, 0x6d, 0xd1, 0xe4, 0x79, 0x98, 0xab, 0x64, 0x18, 0xb1, 0x6d, 0x49, 0x56, 0x89, 0xcc,
+	0x32, 0xb0, 0xf9, 0x2b, 0xa8, 0x27, 0xec, 0x7d, 0x4c, 0x44, 0xcc, 0xdf, 0xc3, 0x4e, 0xae, 0x95,
+	0x1c, 0x25, 0x7b, 0x49, 0x25, 0x1f, 0xf2, 0x54, 0x22, 0xe0, 0x33, 0xd5, 0xe3, 0xdf, 0x12, 0x8f,
+	0xe1, 0xbb, 0x7b, 0x7c, 0x92, 0xb6, 0xea, 0xf1, 0xe8, 0x4b, 0x68, 0x79, 0x13, 0x3c, 0x9b, 0x87,
+	0x0c, 0x07, 0xe3, 0xa5, 0xc3, 0xef, 0x25, 0x1f, 0xd2, 0x4c, 0xc0, 0xa7, 0x78, 0x69, 0xfd, 0x01,
+	0x1a, 0x29, 0x4b, 0x5f, 0xa5, 0xbe, 0x2a, 0x6e, 0xbf, 0xb1, 0x60, 0xf1, 0x9e, 0xe8, 0x51, 0xe7,
+	0xc6, 0x25, 0x9e, 0x3b, 0xf2, 0xb1, 0xa3, 0xe6, 0x5c, 0x41, 0x14, 0xb6, 0xee, 0xd1, 0x37, 0x4a,
+	0x20, 0x67, 0xb6, 0xf5, 0x3d, 0x6c, 0x09, 0x1d, 0x03, 0x4c, 0x6e, 0x30, 0x89, 0x13, 0xea, 0xc9,
+	0x7a, 0x32, 0xef, 0x28, 0xbb, 0x69, 0x66, 0x22, 0x9b, 0xad, 0x6f, 0xa1, 0x99, 0x51, 0xb3, 0x0d,
+	0x25, 0x91, 0x35, 0x2a, 0x00, 0x72, 0x73, 0x7b, 0xd6, 0x5b, 0xdf, 0x42, 0x4b, 0xdc, 0xe6, 0x14,
+	0xc7, 0x83, 0xf0, 0x97, 0x6b, 0x6e, 0x6e, 0xab, 0x8b, 0xac, 0x48, 0x89, 0x41, 0xfa, 0x39, 0x40,
+	0xe2, 0xf0, 0x5a, 0xf8, 0xad, 0x23, 0x55, 0xbb, 0x87, 0xd8, 0xc7, 0x2b, 0x0f, 0x3f, 0x5e, 0x33,
+	0x12, 0x95, 0x6e, 0x8a, 0x97, 0xb0, 0x13, 0xc2, 0x66, 0x5a, 0x45, 0x66, 0xc2, 0x6a, 0xeb, 0x13,
+	0x36, 0x33, 0x3e, 0x0b, 0x6b, 0xe3, 0x33, 0x35, 0x12, 0x8b, 0xe9, 0x91, 0x18, 0x07, 0x2a, 0xb2,
+	0x7a, 0x77, 0xa0, 0xd2, 0xcc, 0x4c, 0xa0, 0x32, 0x6a, 0x6e, 0x0d, 0xd4, 0x44, 0xf0, 0x26, 0xea,
+	0xb6, 0xd1, 0xd6, 0xfa, 0x9b, 0x06, 0xe8, 0xcc, 0xa3, 0x6c, 0x20, 0x1b, 0x7d, 0xe4, 0x84, 0xe7,
+	0x50, 0xbe, 0x0c, 0xc9, 0xcc, 0x95, 0x7d, 0xa8, 0x19, 0x77, 0x95, 0x75, 0xea, 0xde, 0x91, 0xe0,
+	0xd9, 0x8a, 0xcf, 0x4d, 0xcd, 0x5d, 0xc6, 0x30, 0x89, 0x73, 0x42, 0x6d, 0xad, 0x07, 0x50, 0x96,
+	0x5c, 0x04, 0x50, 0x1e, 0xbc, 0x3b, 0x7f, 0x75, 0x71, 0xa6, 0x7f, 0x82, 0xb6, 0xa0, 0x35, 0x3c,
+	0x39, 0xef, 0x39, 0xaf, 0x5e, 0x1f, 0x9c, 0xf6, 0x86, 0xce, 0x69, 0xef, 0x9d, 0xae, 0x59, 0x8f,
+	0x60, 0x2b, 0x65, 0x49, 0x3d, 0xce, 0x80, 0x4a, 0xd4, 0xa7, 0xe4, 0xf7, 0x64, 0xb4, 0xb5, 0x1e,
+	0xc1, 0xce, 0x21, 0xa6, 0x63, 0xe2, 0x8d, 0xb0, 0x3c, 0x14, 0x3d, 0x64, 0x17, 0xca, 0x72, 0x4a,
+	0x29, 0x87, 0xa8, 0x9d, 0x75, 0x06, 0xbb, 0xd9, 0x03, 0x71, 0xfb, 0xaf, 0x8c, 0x16, 0xe3, 0x6b,
+	0xac, 0x8c, 0xac, 0xea, 0xf4, 0x95, 0x40, 0xe5, 0xa9, 0x39, 0x4f, 0x04, 0x3b, 0x22, 0x5a, 0x7f,
+	0x2d, 0x40, 0x7b, 0x4d, 0x9c, 0xd3, 0xb3, 0xee, 0x43, 0x2d, 0xee, 0x96, 0xca, 0x3d, 0x2b, 0x80,
+	0xf7, 0x15, 0x97, 0x31, 0xe2, 0x8d, 0x16, 0x0c, 0x3b, 0x53, 0x12, 0x2e, 0xe6, 0x6a, 0x62, 0x34,
+	0x63, 0xf8, 0x98, 0xa3, 0xd9, 0xaf, 0xdb, 0x8d, 0xbb, 0xbf, 0x6e, 0xb9, 0xee, 0x6c, 0x27, 0x29,
+	0xc9, 0x2f, 0xa6, 0x9b, 0x54, 0x1f, 0xc9, 0x26, 0x77, 0xf9, 0xc3, 0xc9, 0x9d, 0xf9, 0xde, 0xb3,
+	0xfe, 0xa2, 0xc1, 0x56, 0x2f, 0xa0, 0x0b, 0x82, 0xa5, 0x3b, 0x6e, 0xad, 0xdf, 0xec, 0x1b, 0x0a,
+	0xff, 0xdf, 0x1b, 0x8a, 0x79, 0x6f, 0xb0, 0x1e, 0xc3, 0x76, 0xfa, 0x12, 0xab, 0xfc, 0x19, 0x13,
+	0xec, 0xf2, 0x32, 0x90, 0x9f, 0xe0, 0xd1, 0xd6, 0xda, 0x85, 0x6d, 0xd9, 0xf1, 0xde, 0xc8, 0x06,
+	0xa6, 0xee, 0x6d, 0xfd, 0x5d, 0x83, 0x9d, 0x8c, 0x60, 0xa5, 0x2b, 0xea, 0x7d, 0x5a, 0x7a, 0xe2,
+	0xeb, 0x50, 0x64, 0xee, 0x54, 0x85, 0x97, 0x2f, 0xd1, 0xa7, 0x50, 0x9d, 0x7a, 0xcc, 0xb9, 0x72,
+	0xe9, 0x95, 0x8a, 0x68, 0x65, 0xea, 0xb1, 0xef, 0x5c, 0x7a, 0x85, 0x3e, 0x03, 0x18, 0x2d, 0x3c,
+	0x7f, 0xe2, 0xf0, 0x34, 0x50, 0xdf, 0xea, 0x35, 0x81, 0xf0, 0x39, 0xc8, 0xc5, 0xd3, 0xd0, 0x89,
+	0x0c, 0x95, 0xa4, 0x78, 0x1a, 0xaa, 0xcb, 0x3c, 0xfc, 0xa7, 0x06, 0xd5, 0xe8, 0x5f, 0x2e, 0xaa,
+	0x43, 0xe5, 0x75, 0xff, 0xb4, 0x7f, 0xf1, 0xb6, 0xaf, 0x7f, 0xc2, 0x37, 0x47, 0x67, 0x17, 0xbf,
+	0x19, 0x3e, 0xd9, 0xd7, 0x35, 0x54, 0x83, 0xd2, 0x49, 0x9f, 0x2f, 0x0b, 0x31, 0xfe, 0xec, 0xa9,
+	0x5e, 0x54, 0xf8, 0xb3, 0xa7, 0xfa, 0x06, 0x5f, 0xf6, 0x7e, 0x7b, 0x71, 0xf0, 0x9d, 0x5e, 0x42,
+	0x55, 0xd8, 0x78, 0xf5, 0x6e, 0xd8, 0xd3, 0xcb, 0x62, 0x75, 0x71, 0x71, 0xa6, 0x57, 0xf8, 0xaa,
+	0x7f, 0xd1, 0xef, 0xe9, 0x55, 0x51, 0xbb, 0x43, 0xfb, 0xa4, 0x7f, 0xac, 0xd7, 0xd4, 0xf9, 0xaf,
+	0x9f, 0xe9, 0xc0, 0x97, 0xaf, 0x4f, 0xfa, 0xc3, 0xe7, 0x7a, 0x9d, 0x33, 0x5e, 0x4b, 0xb8, 0x11,
+	0xad, 0x9f, 0xec, 0xeb, 0x9b, 0xd1, 0xfa, 0xd9, 0x53, 0xbd, 0xb9, 0xff, 0xdf, 0x0d, 0xa8, 0x9f,
+	0xaf, 0xfe, 0xee, 0xa3, 0x5f, 0x43, 0x49, 0x7c, 0x6b, 0xa0, 0xa8, 0xd8, 0xd6, 0xfe, 0x8a, 0x99,
+	0x9f, 0xe6, 0x48, 0x54, 0x2c, 0x5e, 0x40, 0x5d, 0x00, 0x03, 0x46, 0xb0, 0x3b, 0x43, 0x79, 0x7f,
+	0xd1, 0xcc, 0xdc, 0x4f, 0xb8, 0xc7, 0x1a, 0x7a, 0x09, 0x25, 0x31, 0xa3, 0xd3, 0x96, 0x93, 0x63,
+	0xdb, 0x34, 0x93, 0x92, 0xcc, 0x60, 0x7c, 0x09, 0x95, 0x43, 0x4c, 0x19, 0x09, 0x97, 0x68, 0x37,
+	0x49, 0x5b, 0xcd, 0xae, 0x0f, 0x1e, 0xff, 0x06, 0xca, 0xb2, 0x81, 0xa3, 0xd4, 0xf3, 0x52, 0x13,
+	0xc9, 0x34, 0xf3, 0x44, 0x4a, 0xc1, 0x21, 0xd4, 0x13, 0x9d, 0x32, 0xd6, 0xb2, 0xde, 0xa7, 0x4d,
+	0x33, 0x4f, 0xa4, 0xb4, 0x9c, 0x43, 0x53, 0x36, 0xae, 0xa8, 0x1b, 0xa2, 0xfb, 0xf1, 0xec, 0xc9,
+	0xe9, 0xaa, 0xe6, 0x67, 0xb7, 0x48, 0x95, 0xba, 0x63, 0x68, 0x24, 0xeb, 0x0f, 0x45, 0xa6, 0x73,
+	0x3a, 0x83, 0x79, 0x2f, 0x57, 0xa6, 0x14, 0x7d, 0x0f, 0x9b, 0xa9, 0xea, 0x43, 0xf7, 0x52, 0xdf,
+	0x2e, 0xe9, 0x62, 0x35, 0xef, 0xe7, 0x0b, 0xa5, 0xae, 0x51, 0x59, 0x08, 0x9f, 0xfc, 0x6f, 0x00,
+	0x71, 0x3e, 0x10, 0xdc, 0x67, 0x12, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -1085,9 +1780,17 @@ const _ = grpc.SupportPackageIsVersion6
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
 type MarketstoreClient interface {
 	Query(ctx context.Context, in *MultiQueryRequest, opts ...grpc.CallOption) (*MultiQueryResponse, error)
+	// QueryStream returns the result of the query, and then the records
+	// written to the bucket after it, until the call is canceled
+	QueryStream(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (Marketstore_QueryStreamClient, error)
 	Write(ctx context.Context, in *MultiWriteRequest, opts ...grpc.CallOption) (*MultiServerResponse, error)
 	Destroy(ctx context.Context, in *MultiKeyRequest, opts ...grpc.CallOption) (*MultiServerResponse, error)
+	Delete(ctx context.Context, in *MultiDeleteRequest, opts ...grpc.CallOption) (*MultiDeleteResponse, error)
 	ListSymbols(ctx context.Context, in *ListSymbolsRequest, opts ...grpc.CallOption) (*ListSymbolsResponse, error)
+	DescribeSymbol(ctx context.Context, in *DescribeSymbolRequest, opts ...grpc.CallOption) (*DescribeSymbolResponse, error)
+	// EnsureBucket creates the bucket if it does not exist, and otherwise
+	// checks that it has the same schema
+	EnsureBucket(ctx context.Context, in *EnsureBucketRequest, opts ...grpc.CallOption) (*EnsureBucketResponse, error)
 	ServerVersion(ctx context.Context, in *ServerVersionRequest, opts ...grpc.CallOption) (*ServerVersionResponse, error)
 }
 
@@ -1108,6 +1811,38 @@ func (c *marketstoreClient) Query(ctx context.Context, in *MultiQueryRequest, op
 	return out, nil
 }
 
+func (c *marketstoreClient) QueryStream(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (Marketstore_QueryStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Marketstore_serviceDesc.Streams[0], "/proto.Marketstore/QueryStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &marketstoreQueryStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Marketstore_QueryStreamClient interface {
+	Recv() (*QueryResponse, error)
+	grpc.ClientStream
+}
+
+type marketstoreQueryStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *marketstoreQueryStreamClient) Recv() (*QueryResponse, error) {
+	m := new(QueryResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *marketstoreClient) Write(ctx context.Context, in *MultiWriteRequest, opts ...grpc.CallOption) (*MultiServerResponse, error) {
 	out := new(MultiServerResponse)
 	err := c.cc.Invoke(ctx, "/proto.Marketstore/Write", in, out, opts...)
@@ -1126,6 +1861,15 @@ func (c *marketstoreClient) Destroy(ctx context.Context, in *MultiKeyRequest, op
 	return out, nil
 }
 
+func (c *marketstoreClient) Delete(ctx context.Context, in *MultiDeleteRequest, opts ...grpc.CallOption) (*MultiDeleteResponse, error) {
+	out := new(MultiDeleteResponse)
+	err := c.cc.Invoke(ctx, "/proto.Marketstore/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *marketstoreClient) ListSymbols(ctx context.Context, in *ListSymbolsRequest, opts ...grpc.CallOption) (*ListSymbolsResponse, error) {
 	out := new(ListSymbolsResponse)
 	err := c.cc.Invoke(ctx, "/proto.Marketstore/ListSymbols", in, out, opts...)
@@ -1135,6 +1879,24 @@ func (c *marketstoreClient) ListSymbols(ctx context.Context, in *ListSymbolsRequ
 	return out, nil
 }
 
+func (c *marketstoreClient) DescribeSymbol(ctx context.Context, in *DescribeSymbolRequest, opts ...grpc.CallOption) (*DescribeSymbolResponse, error) {
+	out := new(DescribeSymbolResponse)
+	err := c.cc.Invoke(ctx, "/proto.Marketstore/DescribeSymbol", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *marketstoreClient) EnsureBucket(ctx context.Context, in *EnsureBucketRequest, opts ...grpc.CallOption) (*EnsureBucketResponse, error) {
+	out := new(EnsureBucketResponse)
+	err := c.cc.Invoke(ctx, "/proto.Marketstore/EnsureBucket", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *marketstoreClient) ServerVersion(ctx context.Context, in *ServerVersionRequest, opts ...grpc.CallOption) (*ServerVersionResponse, error) {
 	out := new(ServerVersionResponse)
 	err := c.cc.Invoke(ctx, "/proto.Marketstore/ServerVersion", in, out, opts...)
@@ -1147,9 +1909,17 @@ func (c *marketstoreClient) ServerVersion(ctx context.Context, in *ServerVersion
 // MarketstoreServer is the server API for Marketstore service.
 type MarketstoreServer interface {
 	Query(context.Context, *MultiQueryRequest) (*MultiQueryResponse, error)
+	// QueryStream returns the result of the query, and then the records
+	// written to the bucket after it, until the call is canceled
+	QueryStream(*QueryRequest, Marketstore_QueryStreamServer) error
 	Write(context.Context, *MultiWriteRequest) (*MultiServerResponse, error)
 	Destroy(context.Context, *MultiKeyRequest) (*MultiServerResponse, error)
+	Delete(context.Context, *MultiDeleteRequest) (*MultiDeleteResponse, error)
 	ListSymbols(context.Context, *ListSymbolsRequest) (*ListSymbolsResponse, error)
+	DescribeSymbol(context.Context, *DescribeSymbolRequest) (*DescribeSymbolResponse, error)
+	// EnsureBucket creates the bucket if it does not exist, and otherwise
+	// checks that it has the same schema
+	EnsureBucket(context.Context, *EnsureBucketRequest) (*EnsureBucketResponse, error)
 	ServerVersion(context.Context, *ServerVersionRequest) (*ServerVersionResponse, error)
 }
 
@@ -1160,15 +1930,27 @@ type UnimplementedMarketstoreServer struct {
 func (*UnimplementedMarketstoreServer) Query(ctx context.Context, req *MultiQueryRequest) (*MultiQueryResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
 }
+func (*UnimplementedMarketstoreServer) QueryStream(req *QueryRequest, srv Marketstore_QueryStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method QueryStream not implemented")
+}
 func (*UnimplementedMarketstoreServer) Write(ctx context.Context, req *MultiWriteRequest) (*MultiServerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Write not implemented")
 }
 func (*UnimplementedMarketstoreServer) Destroy(ctx context.Context, req *MultiKeyRequest) (*MultiServerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Destroy not implemented")
 }
+func (*UnimplementedMarketstoreServer) Delete(ctx context.Context, req *MultiDeleteRequest) (*MultiDeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
 func (*UnimplementedMarketstoreServer) ListSymbols(ctx context.Context, req *ListSymbolsRequest) (*ListSymbolsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ListSymbols not implemented")
 }
+func (*UnimplementedMarketstoreServer) DescribeSymbol(ctx context.Context, req *DescribeSymbolRequest) (*DescribeSymbolResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DescribeSymbol not implemented")
+}
+func (*UnimplementedMarketstoreServer) EnsureBucket(ctx context.Context, req *EnsureBucketRequest) (*EnsureBucketResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnsureBucket not implemented")
+}
 func (*UnimplementedMarketstoreServer) ServerVersion(ctx context.Context, req *ServerVersionRequest) (*ServerVersionResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method ServerVersion not implemented")
 }
@@ -1195,6 +1977,27 @@ func _Marketstore_Query_Handler(srv interface{}, ctx context.Context, dec func(i
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Marketstore_QueryStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(QueryRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MarketstoreServer).QueryStream(m, &marketstoreQueryStreamServer{stream})
+}
+
+type Marketstore_QueryStreamServer interface {
+	Send(*QueryResponse) error
+	grpc.ServerStream
+}
+
+type marketstoreQueryStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *marketstoreQueryStreamServer) Send(m *QueryResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 func _Marketstore_Write_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(MultiWriteRequest)
 	if err := dec(in); err != nil {
@@ -1231,6 +2034,24 @@ func _Marketstore_Destroy_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Marketstore_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MultiDeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MarketstoreServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Marketstore/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MarketstoreServer).Delete(ctx, req.(*MultiDeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Marketstore_ListSymbols_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ListSymbolsRequest)
 	if err := dec(in); err != nil {
@@ -1249,6 +2070,42 @@ func _Marketstore_ListSymbols_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Marketstore_DescribeSymbol_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DescribeSymbolRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MarketstoreServer).DescribeSymbol(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Marketstore/DescribeSymbol",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MarketstoreServer).DescribeSymbol(ctx, req.(*DescribeSymbolRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Marketstore_EnsureBucket_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EnsureBucketRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MarketstoreServer).EnsureBucket(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.Marketstore/EnsureBucket",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MarketstoreServer).EnsureBucket(ctx, req.(*EnsureBucketRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Marketstore_ServerVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(ServerVersionRequest)
 	if err := dec(in); err != nil {
@@ -1283,15 +2140,33 @@ var _Marketstore_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Destroy",
 			Handler:    _Marketstore_Destroy_Handler,
 		},
+		{
+			MethodName: "Delete",
+			Handler:    _Marketstore_Delete_Handler,
+		},
 		{
 			MethodName: "ListSymbols",
 			Handler:    _Marketstore_ListSymbols_Handler,
 		},
+		{
+			MethodName: "DescribeSymbol",
+			Handler:    _Marketstore_DescribeSymbol_Handler,
+		},
+		{
+			MethodName: "EnsureBucket",
+			Handler:    _Marketstore_EnsureBucket_Handler,
+		},
 		{
 			MethodName: "ServerVersion",
 			Handler:    _Marketstore_ServerVersion_Handler,
 		},
 	},
-	Streams:  []grpc.StreamDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "QueryStream",
+			Handler:       _Marketstore_QueryStream_Handler,
+			ServerStreams: true,
+		},
+	},
 	Metadata: "marketstore.proto",
 }
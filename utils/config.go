@@ -3,6 +3,13 @@ package utils
 import (
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/pprof"
 	"strconv"
 	"strings"
 	"time"
@@ -27,23 +34,124 @@ type BgWorkerSetting struct {
 	Module string
 	Name   string
 	Config map[string]interface{}
+	// StallTimeout is how long the worker may make no progress before it is
+	// warned as stalled, with zero for never
+	StallTimeout time.Duration
 }
 
+// RetentionPolicy is the max age of the year files of the buckets of the
+// symbols matching a glob and of a timeframe.
+type RetentionPolicy struct {
+	// Symbols is the glob pattern of the symbols, all of them if empty
+	Symbols string
+	// Timeframe is the timeframe of the buckets, all of them if empty
+	Timeframe string
+	MaxAge    time.Duration
+}
+
+// RetentionSetting is the setting of pruning the year files past their
+// retention.
+type RetentionSetting struct {
+	Policies []*RetentionPolicy
+	Interval time.Duration
+	// DryRun only logs the files that would be pruned
+	DryRun bool
+	// ArchiveDirectory is where the pruned files are moved to rather than
+	// deleted, if set
+	ArchiveDirectory string
+}
+
+// WALRotation is the rotation of the WAL file written to the buckets of a
+// timeframe, overriding wal_rotate_interval.
+type WALRotation struct {
+	// Interval is the number of checkpoints between the rotations,
+	// wal_rotate_interval if 0
+	Interval int
+	// Size is the size in bytes past which the WAL file is checkpointed
+	// and rotated early, without limit if 0
+	Size int64
+}
+
+// BucketSchema is the schema declared for the buckets of some symbols with
+// a timeframe and an attribute group.
+type BucketSchema struct {
+	Symbols        []string
+	Timeframe      string
+	AttributeGroup string
+	// Columns are the columns after the Epoch, in the data shapes format
+	// of the Create API, e.g. Open,High,Low,Close/float32:Volume/int64
+	Columns        string
+	VariableLength bool
+}
+
+// The policies for writing a record at the timestamp of an existing one.
+const (
+	// DuplicatesAppend stores both records in variable length buckets, and
+	// overwrites the existing one in fixed length buckets
+	DuplicatesAppend = "append"
+	// DuplicatesOverwrite overwrites the existing record
+	DuplicatesOverwrite = "overwrite"
+	// DuplicatesReject keeps the existing record and discards the new one
+	DuplicatesReject = "reject"
+)
+
+// The codecs of the compressed year files.
+const (
+	CompressionNone   = "none"
+	CompressionSnappy = "snappy"
+	CompressionZstd   = "zstd"
+)
+
 type MktsConfig struct {
 	RootDirectory              string
+	CreateRootDirectory        bool
+	StorageTiers               []string
 	ListenURL                  string
 	GRPCListenURL              string
 	GRPCMaxSendMsgSize         int // in bytes
 	GRPCMaxRecvMsgSize         int // in bytes
+	GRPCReflection             bool
+	MaxQueryResultBytes        int64
+	QueryCacheSize             int
+	QueryCacheTTL              time.Duration
 	UtilitiesURL               string
+	AdminListenURL             string
+	MetricsNamespace           string
+	MetricsLabels              map[string]string
+	MetricsSymbolLabels        bool
+	DiskUsageInterval          time.Duration
+	IngestionLagInterval       time.Duration
+	MaxOpenFiles               int
+	EnablePprof                bool
+	BackupDirectory            string
+	DumpDirectory              string
+	DumpProfiles               []string
+	DumpCPUDuration            time.Duration
 	Timezone                   *time.Location
+	LogLevel                   string
+	LogFormat                  string
 	Queryable                  bool
 	StopGracePeriod            time.Duration
+	HTTPReadTimeout            time.Duration
+	HTTPWriteTimeout           time.Duration
+	HTTPIdleTimeout            time.Duration
 	WALRotateInterval          int
+	WALRotation                map[time.Duration]WALRotation
+	WALReplayWorkers           int
+	WALCommitWorkers           int
+	TriggerWorkers             int
+	WriteBatchWindow           time.Duration
+	WriteBatchSize             int
 	EnableAdd                  bool
 	EnableRemove               bool
 	EnableLastKnown            bool
 	DisableVariableCompression bool
+	Compression                map[time.Duration]string
+	StrictWrites               bool
+	WriteDuplicates            string
+	LateDataWindow             time.Duration
+	WriteIdempotencyWindow     time.Duration
+	SymbolAliases              map[string]string
 	InitCatalog                bool
 	InitWALCache               bool
 	BackgroundSync             bool
@@ -52,43 +160,97 @@ type MktsConfig struct {
 	StartTime                  time.Time
 	Triggers                   []*TriggerSetting
 	BgWorkers                  []*BgWorkerSetting
+	Retention                  RetentionSetting
+	Schemas                    []*BucketSchema
+	StrictSchemas              bool
 }
 
 func (m *MktsConfig) Parse(data []byte) error {
 	var (
-		err error
 		aux struct {
-			RootDirectory              string `yaml:"root_directory"`
-			ListenHost                 string `yaml:"listen_host"`
-			ListenPort                 string `yaml:"listen_port"`
-			GRPCListenPort             string `yaml:"grpc_listen_port"`
-			GRPCMaxSendMsgSize         int    `yaml:"grpc_max_send_msg_size"` // in MB
-			GRPCMaxRecvMsgSize         int    `yaml:"grpc_max_recv_msg_size"` // in MB
-			UtilitiesURL               string `yaml:"utilities_url"`
-			Timezone                   string `yaml:"timezone"`
-			LogLevel                   string `yaml:"log_level"`
-			Queryable                  string `yaml:"queryable"`
-			StopGracePeriod            int    `yaml:"stop_grace_period"`
-			WALRotateInterval          int    `yaml:"wal_rotate_interval"`
-			EnableAdd                  string `yaml:"enable_add"`
-			EnableRemove               string `yaml:"enable_remove"`
-			EnableLastKnown            string `yaml:"enable_last_known"`
-			DisableVariableCompression string `yaml:"disable_variable_compression"`
-			InitCatalog                string `yaml:"init_catalog"`
-			InitWALCache               string `yaml:"init_wal_cache"`
-			BackgroundSync             string `yaml:"background_sync"`
-			WALBypass                  string `yaml:"wal_bypass"`
-			ClusterMode                string `yaml:"cluster_mode"`
-			Triggers                   []struct {
-				Module string                 `yaml:"module"`
-				On     string                 `yaml:"on"`
-				Config map[string]interface{} `yaml:"config"`
-			} `yaml:"triggers"`
-			BgWorkers []struct {
-				Module string                 `yaml:"module"`
-				Name   string                 `yaml:"name"`
-				Config map[string]interface{} `yaml:"config"`
+			RootDirectory              string            `yaml:"root_directory"`
+			CreateRootDirectory        bool              `yaml:"create_root_directory"`
+			StorageTiers               []string          `yaml:"storage_tiers"`
+			ListenHost                 string            `yaml:"listen_host"`
+			ListenPort                 string            `yaml:"listen_port"`
+			GRPCListenPort             string            `yaml:"grpc_listen_port"`
+			GRPCMaxSendMsgSize         int               `yaml:"grpc_max_send_msg_size"` // in MB
+			GRPCMaxRecvMsgSize         int               `yaml:"grpc_max_recv_msg_size"` // in MB
+			MaxQueryResultSize         int               `yaml:"max_query_result_size"`  // in MB
+			QueryCacheSize             int               `yaml:"query_cache_size"`
+			QueryCacheTTL              int               `yaml:"query_cache_ttl"` // in seconds
+			GRPCReflection             string            `yaml:"grpc_reflection"`
+			UtilitiesURL               string            `yaml:"utilities_url"`
+			AdminListenURL             string            `yaml:"admin_listen_url"`
+			MetricsNamespace           string            `yaml:"metrics_namespace"`
+			MetricsLabels              map[string]string `yaml:"metrics_labels"`
+			MetricsSymbolLabels        bool              `yaml:"metrics_symbol_labels"`
+			DiskUsageInterval          int               `yaml:"disk_usage_interval"`    // in seconds
+			IngestionLagInterval       int               `yaml:"ingestion_lag_interval"` // in seconds
+			MaxOpenFiles               int               `yaml:"max_open_files"`
+			EnablePprof                bool              `yaml:"enable_pprof"`
+			BackupDirectory            string            `yaml:"backup_directory"`
+			DumpDirectory              string            `yaml:"dump_directory"`
+			DumpProfiles               string            `yaml:"dump_profiles"`
+			DumpCPUDuration            int               `yaml:"dump_cpu_duration"` // in seconds
+			Timezone                   string            `yaml:"timezone"`
+			LogLevel                   string            `yaml:"log_level"`
+			LogFormat                  string            `yaml:"log_format"`
+			Queryable                  string            `yaml:"queryable"`
+			StopGracePeriod            int               `yaml:"stop_grace_period"`
+			HTTPReadTimeout            int               `yaml:"http_read_timeout"`  // in seconds
+			HTTPWriteTimeout           int               `yaml:"http_write_timeout"` // in seconds
+			HTTPIdleTimeout            int               `yaml:"http_idle_timeout"`  // in seconds
+			WALRotateInterval          int               `yaml:"wal_rotate_interval"`
+			WALReplayWorkers           int               `yaml:"wal_replay_workers"`
+			WALCommitWorkers           int               `yaml:"wal_commit_workers"`
+			TriggerWorkers             int               `yaml:"trigger_workers"`
+			WriteBatchWindow           int               `yaml:"write_batch_window"` // in milliseconds
+			WriteBatchSize             int               `yaml:"write_batch_size"`
+			EnableAdd                  string            `yaml:"enable_add"`
+			EnableRemove               string            `yaml:"enable_remove"`
+			EnableLastKnown            string            `yaml:"enable_last_known"`
+			DisableVariableCompression string            `yaml:"disable_variable_compression"`
+			Compression                map[string]string `yaml:"compression"` // by timeframe
+			StrictWrites               string            `yaml:"strict_writes"`
+			WriteDuplicates            string            `yaml:"write_duplicates"`
+			LateDataWindow             int               `yaml:"late_data_window"`         // in seconds
+			WriteIdempotencyWindow     int               `yaml:"write_idempotency_window"` // in seconds
+			SymbolAliases              map[string]string `yaml:"symbol_aliases"`
+			InitCatalog                string            `yaml:"init_catalog"`
+			InitWALCache               string            `yaml:"init_wal_cache"`
+			BackgroundSync             string            `yaml:"background_sync"`
+			WALBypass                  string            `yaml:"wal_bypass"`
+			ClusterMode                string            `yaml:"cluster_mode"`
+			Triggers                   []triggerConfig   `yaml:"triggers"`
+			BgWorkers                  []struct {
+				Module       string                 `yaml:"module"`
+				Name         string                 `yaml:"name"`
+				Config       map[string]interface{} `yaml:"config"`
+				StallTimeout int                    `yaml:"stall_timeout"` // in seconds
 			} `yaml:"bgworkers"`
+			Retention struct {
+				Interval         int    `yaml:"interval"` // in minutes
+				DryRun           bool   `yaml:"dry_run"`
+				ArchiveDirectory string `yaml:"archive_directory"`
+				Policies         []struct {
+					Symbols   string `yaml:"symbols"`
+					Timeframe string `yaml:"timeframe"`
+					MaxAge    string `yaml:"max_age"`
+				} `yaml:"policies"`
+			} `yaml:"retention"`
+			Schemas []struct {
+				Symbols        []string `yaml:"symbols"`
+				Timeframe      string   `yaml:"timeframe"`
+				AttributeGroup string   `yaml:"attribute_group"`
+				Columns        string   `yaml:"columns"`
+				VariableLength bool     `yaml:"variable_length"`
+			} `yaml:"schemas"`
+			StrictSchemas string `yaml:"strict_schemas"`
+			WALRotation   map[string]struct {
+				Interval int `yaml:"interval"`
+				Size     int `yaml:"size"` // in MB
+			} `yaml:"wal_rotation"` // by timeframe
 		}
 	)
 
@@ -96,105 +258,287 @@ func (m *MktsConfig) Parse(data []byte) error {
 		return err
 	}
 
+	// all the invalid values are listed at once
+	var errs configErrors
+	parseBool := func(name, value string, out *bool) {
+		if value == "" {
+			return
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			errs.add("invalid %s %q, must be true or false", name, value)
+			return
+		}
+		*out = b
+	}
+	nonNegative := func(name string, value int) {
+		if value < 0 {
+			errs.add("invalid %s %d, must not be negative", name, value)
+		}
+	}
+
+	// the environment variables override the file, e.g.
+	// MARKETSTORE_LISTEN_PORT for listen_port
+	applyEnvOverrides(&errs, map[string]interface{}{
+		"root_directory":         &aux.RootDirectory,
+		"create_root_directory":  &aux.CreateRootDirectory,
+		"listen_host":            &aux.ListenHost,
+		"listen_port":            &aux.ListenPort,
+		"grpc_listen_port":       &aux.GRPCListenPort,
+		"grpc_max_send_msg_size": &aux.GRPCMaxSendMsgSize,
+		"grpc_max_recv_msg_size": &aux.GRPCMaxRecvMsgSize,
+		"max_query_result_size":  &aux.MaxQueryResultSize,
+		"utilities_url":          &aux.UtilitiesURL,
+		"admin_listen_url":       &aux.AdminListenURL,
+		"metrics_namespace":      &aux.MetricsNamespace,
+		"enable_pprof":           &aux.EnablePprof,
+		"dump_directory":         &aux.DumpDirectory,
+		"dump_profiles":          &aux.DumpProfiles,
+		"timezone":               &aux.Timezone,
+		"log_level":              &aux.LogLevel,
+		"log_format":             &aux.LogFormat,
+		"queryable":              &aux.Queryable,
+		"stop_grace_period":      &aux.StopGracePeriod,
+		"wal_rotate_interval":    &aux.WALRotateInterval,
+		"wal_replay_workers":     &aux.WALReplayWorkers,
+		"wal_commit_workers":     &aux.WALCommitWorkers,
+		"trigger_workers":        &aux.TriggerWorkers,
+	})
+
 	if aux.RootDirectory == "" {
-		log.Fatal("Invalid root directory.")
-		return errors.New("Invalid root directory.")
+		errs.add("missing root_directory")
+	} else if err := checkWritableDir(aux.RootDirectory); err != nil {
+		errs.add("invalid root_directory %q: %v", aux.RootDirectory, err)
 	}
 
 	if aux.ListenPort == "" {
-		log.Fatal("Invalid listen port.")
-		return errors.New("Invalid listen port.")
+		errs.add("missing listen_port")
+	} else if err := checkPort(aux.ListenPort); err != nil {
+		errs.add("invalid listen_port %q: %v", aux.ListenPort, err)
 	}
 
 	// GRPC is optional for now
-	// if aux.GRPCListenPort == "" {
-	// 	log.Fatal("Invalid GRPC listen port.")
-	// 	return errors.New("Invalid GRPC listen port.")
-	// }
-	if aux.GRPCMaxSendMsgSize == 0 {
-		aux.GRPCMaxSendMsgSize = 1024
-	} else if aux.GRPCMaxSendMsgSize < 64 {
-		log.Warn("WARNING: Low grpc_max_send_msg_size: %dMB (recommend at least 64MB)", aux.GRPCMaxSendMsgSize)
+	if aux.GRPCListenPort != "" {
+		if err := checkPort(aux.GRPCListenPort); err != nil {
+			errs.add("invalid grpc_listen_port %q: %v", aux.GRPCListenPort, err)
+		}
+	}
+	if aux.UtilitiesURL != "" {
+		if _, port, err := net.SplitHostPort(aux.UtilitiesURL); err != nil {
+			errs.add("invalid utilities_url %q, must be host:port: %v", aux.UtilitiesURL, err)
+		} else if err := checkPort(port); err != nil {
+			errs.add("invalid utilities_url %q: %v", aux.UtilitiesURL, err)
+		}
+	}
+	if aux.AdminListenURL != "" {
+		if _, port, err := net.SplitHostPort(aux.AdminListenURL); err != nil {
+			errs.add("invalid admin_listen_url %q, must be host:port: %v", aux.AdminListenURL, err)
+		} else if err := checkPort(port); err != nil {
+			errs.add("invalid admin_listen_url %q: %v", aux.AdminListenURL, err)
+		}
 	}
-	m.GRPCMaxSendMsgSize = aux.GRPCMaxSendMsgSize * (1 << 20)
 
-	if aux.GRPCMaxRecvMsgSize == 0 {
-		aux.GRPCMaxRecvMsgSize = 1024
-	} else if aux.GRPCMaxRecvMsgSize < 64 {
-		log.Warn("WARNING: Low grpc_max_recv_msg_size: %dMB (recommend at least 64MB)", aux.GRPCMaxRecvMsgSize)
+	for _, size := range []struct {
+		name  string
+		value *int
+	}{
+		{"grpc_max_send_msg_size", &aux.GRPCMaxSendMsgSize},
+		{"grpc_max_recv_msg_size", &aux.GRPCMaxRecvMsgSize},
+	} {
+		switch {
+		case *size.value == 0:
+			*size.value = 1024
+		case *size.value < 0 || *size.value > maxGRPCMsgSize:
+			errs.add("invalid %s %dMB, must be between 1 and %d", size.name, *size.value, maxGRPCMsgSize)
+		case *size.value < 64:
+			log.Warn("WARNING: Low %s: %dMB (recommend at least 64MB)", size.name, *size.value)
+		}
 	}
+	m.GRPCMaxSendMsgSize = aux.GRPCMaxSendMsgSize * (1 << 20)
 	m.GRPCMaxRecvMsgSize = aux.GRPCMaxRecvMsgSize * (1 << 20)
 
+	nonNegative("max_query_result_size", aux.MaxQueryResultSize)
+	if aux.MaxQueryResultSize == 0 {
+		aux.MaxQueryResultSize = 4096
+	}
+	m.MaxQueryResultBytes = int64(aux.MaxQueryResultSize) * (1 << 20)
+	nonNegative("query_cache_size", aux.QueryCacheSize)
+	m.QueryCacheSize = aux.QueryCacheSize
+	nonNegative("query_cache_ttl", aux.QueryCacheTTL)
+	if aux.QueryCacheTTL == 0 {
+		aux.QueryCacheTTL = 60
+	}
+	m.QueryCacheTTL = time.Duration(aux.QueryCacheTTL) * time.Second
+
+	nonNegative("disk_usage_interval", aux.DiskUsageInterval)
+	if aux.DiskUsageInterval == 0 {
+		aux.DiskUsageInterval = 300
+	}
+	m.DiskUsageInterval = time.Duration(aux.DiskUsageInterval) * time.Second
+	nonNegative("ingestion_lag_interval", aux.IngestionLagInterval)
+	if aux.IngestionLagInterval == 0 {
+		aux.IngestionLagInterval = 15
+	}
+	m.IngestionLagInterval = time.Duration(aux.IngestionLagInterval) * time.Second
+	nonNegative("max_open_files", aux.MaxOpenFiles)
+	m.MaxOpenFiles = aux.MaxOpenFiles
+
 	// Giving "" to LoadLocation will be UTC anyway, which is our default too.
-	m.Timezone, err = time.LoadLocation(aux.Timezone)
-	if err != nil {
-		log.Fatal("Invalid timezone.")
-		return errors.New("Invalid timezone")
+	if tz, err := time.LoadLocation(aux.Timezone); err != nil {
+		errs.add("invalid timezone %q, must be an IANA time zone name such as America/New_York: %v",
+			aux.Timezone, err)
+	} else {
+		m.Timezone = tz
 	}
 
+	nonNegative("wal_rotate_interval", aux.WALRotateInterval)
 	if aux.WALRotateInterval == 0 {
 		m.WALRotateInterval = 5 // Default of rotate interval of five periods
 	} else {
 		m.WALRotateInterval = aux.WALRotateInterval
 	}
 
-	if aux.Queryable != "" {
-		queryable, err := strconv.ParseBool(aux.Queryable)
-		if err != nil {
-			log.Error("Invalid value: %v for Queryable. Running as queryable...", aux.Queryable)
-		} else {
-			m.Queryable = queryable
+	m.WALRotation = map[time.Duration]WALRotation{}
+	for timeframe, rotation := range aux.WALRotation {
+		tf := TimeframeFromString(timeframe)
+		if tf == nil {
+			errs.add("invalid timeframe %q of wal_rotation", timeframe)
+			continue
+		}
+		if rotation.Interval < 0 || rotation.Size < 0 {
+			errs.add("invalid wal_rotation of %s, interval %d and size %d must not be negative",
+				timeframe, rotation.Interval, rotation.Size)
+			continue
+		}
+		m.WALRotation[tf.Duration] = WALRotation{
+			Interval: rotation.Interval,
+			Size:     int64(rotation.Size) * (1 << 20),
 		}
 	}
 
+	nonNegative("wal_replay_workers", aux.WALReplayWorkers)
+	if aux.WALReplayWorkers <= 0 {
+		m.WALReplayWorkers = runtime.NumCPU() // Default of one replay worker per CPU
+	} else {
+		m.WALReplayWorkers = aux.WALReplayWorkers
+	}
+
+	// the commits and the triggers are run by a single worker by default
+	nonNegative("wal_commit_workers", aux.WALCommitWorkers)
+	m.WALCommitWorkers = 1
+	if aux.WALCommitWorkers > 0 {
+		m.WALCommitWorkers = aux.WALCommitWorkers
+	}
+	nonNegative("trigger_workers", aux.TriggerWorkers)
+	m.TriggerWorkers = 1
+	if aux.TriggerWorkers > 0 {
+		m.TriggerWorkers = aux.TriggerWorkers
+	}
+
+	nonNegative("write_batch_window", aux.WriteBatchWindow)
+	m.WriteBatchWindow = time.Duration(aux.WriteBatchWindow) * time.Millisecond
+	nonNegative("write_batch_size", aux.WriteBatchSize)
+	m.WriteBatchSize = 1000
+	if aux.WriteBatchSize > 0 {
+		m.WriteBatchSize = aux.WriteBatchSize
+	}
+
+	parseBool("queryable", aux.Queryable, &m.Queryable)
+	parseBool("grpc_reflection", aux.GRPCReflection, &m.GRPCReflection)
+
+	m.LogFormat = string(log.JSON)
+	if aux.LogFormat != "" {
+		switch strings.ToLower(aux.LogFormat) {
+		case string(log.JSON), string(log.Text):
+			m.LogFormat = strings.ToLower(aux.LogFormat)
+		default:
+			errs.add("invalid log_format %q, must be %s or %s", aux.LogFormat, log.JSON, log.Text)
+		}
+		log.SetFormat(log.Format(m.LogFormat))
+	}
+
 	if aux.LogLevel != "" {
-		switch strings.ToLower(aux.LogLevel) {
+		m.LogLevel = strings.ToLower(aux.LogLevel)
+		switch m.LogLevel {
 		case "fatal":
 			log.SetLevel(log.FATAL)
 		case "error":
 			log.SetLevel(log.ERROR)
-		case "warning":
+		case "warning", "warn":
 			log.SetLevel(log.WARNING)
 		case "debug":
 			log.SetLevel(log.DEBUG)
 		case "info":
-			fallthrough
-		default:
 			log.SetLevel(log.INFO)
+		default:
+			errs.add("invalid log_level %q, must be fatal, error, warning, info or debug", aux.LogLevel)
 		}
 	}
 
+	nonNegative("stop_grace_period", aux.StopGracePeriod)
+	m.StopGracePeriod = 30 * time.Second
 	if aux.StopGracePeriod > 0 {
 		m.StopGracePeriod = time.Duration(aux.StopGracePeriod) * time.Second
 	}
 
-	if aux.EnableAdd != "" {
-		enableAdd, err := strconv.ParseBool(aux.EnableAdd)
-		if err != nil {
-			log.Error("Invalid value: %v for enable_add. Disabling add...", aux.EnableAdd)
-		} else {
-			m.EnableAdd = enableAdd
-		}
+	nonNegative("http_read_timeout", aux.HTTPReadTimeout)
+	nonNegative("http_write_timeout", aux.HTTPWriteTimeout)
+	nonNegative("http_idle_timeout", aux.HTTPIdleTimeout)
+	m.HTTPReadTimeout = 30 * time.Second
+	if aux.HTTPReadTimeout > 0 {
+		m.HTTPReadTimeout = time.Duration(aux.HTTPReadTimeout) * time.Second
 	}
-
-	if aux.EnableRemove != "" {
-		enableRemove, err := strconv.ParseBool(aux.EnableRemove)
-		if err != nil {
-			log.Error("Invalid value: %v for enable_add. Disabling remove...", aux.EnableRemove)
-		} else {
-			m.EnableRemove = enableRemove
-		}
+	// long enough to write large query results
+	m.HTTPWriteTimeout = 5 * time.Minute
+	if aux.HTTPWriteTimeout > 0 {
+		m.HTTPWriteTimeout = time.Duration(aux.HTTPWriteTimeout) * time.Second
+	}
+	m.HTTPIdleTimeout = 2 * time.Minute
+	if aux.HTTPIdleTimeout > 0 {
+		m.HTTPIdleTimeout = time.Duration(aux.HTTPIdleTimeout) * time.Second
 	}
 
+	parseBool("enable_add", aux.EnableAdd, &m.EnableAdd)
+	parseBool("enable_remove", aux.EnableRemove, &m.EnableRemove)
+
 	m.EnableLastKnown = false
 	log.Info("Disabling \"enable_last_known\" feature until it is fixed...")
 
-	if aux.DisableVariableCompression != "" {
-		m.DisableVariableCompression, err = strconv.ParseBool(aux.DisableVariableCompression)
-		if err != nil {
-			log.Error("Invalid value for DisableVariableCompression")
+	parseBool("disable_variable_compression", aux.DisableVariableCompression, &m.DisableVariableCompression)
+	parseBool("strict_writes", aux.StrictWrites, &m.StrictWrites)
+
+	m.Compression = map[time.Duration]string{}
+	for timeframe, codec := range aux.Compression {
+		tf := TimeframeFromString(timeframe)
+		if tf == nil {
+			errs.add("invalid timeframe %q of compression", timeframe)
+			continue
+		}
+		switch codec {
+		case CompressionNone, CompressionSnappy, CompressionZstd:
+			m.Compression[tf.Duration] = codec
+		default:
+			errs.add("invalid compression %q of %s, must be none, snappy or zstd", codec, timeframe)
 		}
 	}
+
+	switch aux.WriteDuplicates {
+	case "", DuplicatesAppend:
+		m.WriteDuplicates = DuplicatesAppend
+	case DuplicatesOverwrite, DuplicatesReject:
+		m.WriteDuplicates = aux.WriteDuplicates
+	default:
+		errs.add("invalid write_duplicates %q, must be append, overwrite or reject", aux.WriteDuplicates)
+	}
+	nonNegative("late_data_window", aux.LateDataWindow)
+	m.LateDataWindow = time.Duration(aux.LateDataWindow) * time.Second
+	nonNegative("write_idempotency_window", aux.WriteIdempotencyWindow)
+	if aux.WriteIdempotencyWindow == 0 {
+		aux.WriteIdempotencyWindow = 600
+	}
+	m.WriteIdempotencyWindow = time.Duration(aux.WriteIdempotencyWindow) * time.Second
+	m.SymbolAliases = aux.SymbolAliases
+
 	/*
 		// Broken - disable for now
 		if aux.EnableLastKnown != "" {
@@ -207,69 +551,318 @@ func (m *MktsConfig) Parse(data []byte) error {
 		}
 	*/
 	m.InitCatalog = true
-	if aux.InitCatalog != "" {
-		m.InitCatalog, err = strconv.ParseBool(aux.InitCatalog)
-		if err != nil {
-			log.Error("Invalid value for InitCatalog")
+	parseBool("init_catalog", aux.InitCatalog, &m.InitCatalog)
+
+	m.InitWALCache = true
+	parseBool("init_wal_cache", aux.InitWALCache, &m.InitWALCache)
+
+	m.BackgroundSync = true
+	parseBool("background_sync", aux.BackgroundSync, &m.BackgroundSync)
+
+	m.WALBypass = false
+	parseBool("wal_bypass", aux.WALBypass, &m.WALBypass)
+
+	m.ClusterMode = true
+	parseBool("cluster_mode", aux.ClusterMode, &m.ClusterMode)
+
+	m.RootDirectory = aux.RootDirectory
+	m.CreateRootDirectory = aux.CreateRootDirectory
+	m.StorageTiers = aux.StorageTiers
+	m.ListenURL = fmt.Sprintf("%v:%v", aux.ListenHost, aux.ListenPort)
+	if aux.GRPCListenPort != "" {
+		m.GRPCListenURL = fmt.Sprintf("%v:%v", aux.ListenHost, aux.GRPCListenPort)
+	}
+	m.UtilitiesURL = fmt.Sprintf("%v", aux.UtilitiesURL)
+	m.AdminListenURL = aux.AdminListenURL
+	m.MetricsNamespace = aux.MetricsNamespace
+	m.MetricsLabels = aux.MetricsLabels
+	m.MetricsSymbolLabels = aux.MetricsSymbolLabels
+	m.EnablePprof = aux.EnablePprof
+	if aux.BackupDirectory != "" {
+		if err := checkWritableDir(aux.BackupDirectory); err != nil {
+			errs.add("invalid backup_directory %q: %v", aux.BackupDirectory, err)
 		}
 	}
+	m.BackupDirectory = aux.BackupDirectory
 
-	m.InitWALCache = true
-	if aux.InitWALCache != "" {
-		m.InitWALCache, err = strconv.ParseBool(aux.InitWALCache)
-		if err != nil {
-			log.Error("Invalid value for InitWALCache")
+	if aux.DumpDirectory != "" {
+		if err := checkWritableDir(aux.DumpDirectory); err != nil {
+			errs.add("invalid dump_directory %q: %v", aux.DumpDirectory, err)
 		}
 	}
+	m.DumpDirectory = aux.DumpDirectory
+	if aux.DumpProfiles == "" {
+		aux.DumpProfiles = "goroutine,heap"
+	}
+	m.DumpProfiles = nil
+	for _, profile := range strings.Split(aux.DumpProfiles, ",") {
+		profile = strings.TrimSpace(profile)
+		if profile != "cpu" && pprof.Lookup(profile) == nil {
+			errs.add("invalid profile %q of dump_profiles, must be cpu or a runtime profile like goroutine or heap", profile)
+			continue
+		}
+		m.DumpProfiles = append(m.DumpProfiles, profile)
+	}
+	nonNegative("dump_cpu_duration", aux.DumpCPUDuration)
+	if aux.DumpCPUDuration == 0 {
+		aux.DumpCPUDuration = 5
+	}
+	m.DumpCPUDuration = time.Duration(aux.DumpCPUDuration) * time.Second
 
-	m.BackgroundSync = true
-	if aux.BackgroundSync != "" {
-		m.BackgroundSync, err = strconv.ParseBool(aux.BackgroundSync)
-		if err != nil {
-			log.Error("Invalid value for BackgroundSync")
+	m.Triggers = parseTriggers(&errs, aux.Triggers)
+
+	for i, bg := range aux.BgWorkers {
+		if bg.Module == "" {
+			errs.add("bgworker %d must have a module", i)
+		}
+		nonNegative(fmt.Sprintf("stall_timeout of bgworker %d", i), bg.StallTimeout)
+		bgWorkerSetting := &BgWorkerSetting{
+			Module:       bg.Module,
+			Name:         bg.Name,
+			Config:       bg.Config,
+			StallTimeout: time.Duration(bg.StallTimeout) * time.Second,
 		}
+		m.BgWorkers = append(m.BgWorkers, bgWorkerSetting)
 	}
 
-	m.WALBypass = false
-	if aux.WALBypass != "" {
-		m.WALBypass, err = strconv.ParseBool(aux.WALBypass)
-		if err != nil {
-			log.Error("Invalid value for WALBypass")
+	m.Retention = RetentionSetting{
+		Interval:         time.Hour,
+		DryRun:           aux.Retention.DryRun,
+		ArchiveDirectory: aux.Retention.ArchiveDirectory,
+	}
+	nonNegative("retention interval", aux.Retention.Interval)
+	if aux.Retention.Interval > 0 {
+		m.Retention.Interval = time.Duration(aux.Retention.Interval) * time.Minute
+	}
+	for _, policy := range aux.Retention.Policies {
+		maxAge, err := parseAge(policy.MaxAge)
+		if err != nil || maxAge <= 0 {
+			errs.add("invalid retention max_age %q, must be a positive duration such as 90d or 72h", policy.MaxAge)
+			continue
 		}
+		m.Retention.Policies = append(m.Retention.Policies, &RetentionPolicy{
+			Symbols:   policy.Symbols,
+			Timeframe: policy.Timeframe,
+			MaxAge:    maxAge,
+		})
 	}
 
-	m.ClusterMode = true
-	if aux.ClusterMode != "" {
-		m.ClusterMode, err = strconv.ParseBool(aux.ClusterMode)
-		if err != nil {
-			log.Error("Invalid value for ClusterMode")
+	// the columns are parsed with the data shapes, when the buckets of the
+	// schemas are created
+	declared := map[string]int{}
+	for i, schema := range aux.Schemas {
+		if len(schema.Symbols) == 0 || schema.Timeframe == "" || schema.AttributeGroup == "" || schema.Columns == "" {
+			errs.add("schema %d must have symbols, a timeframe, an attribute_group and columns", i)
+			continue
 		}
+		if TimeframeFromString(schema.Timeframe) == nil {
+			errs.add("invalid timeframe %q of schema %d", schema.Timeframe, i)
+			continue
+		}
+		if strings.ContainsAny(schema.AttributeGroup, "/*") {
+			errs.add("invalid attribute_group %q of schema %d", schema.AttributeGroup, i)
+			continue
+		}
+		for _, symbol := range schema.Symbols {
+			key := symbol + "/" + schema.Timeframe + "/" + schema.AttributeGroup
+			if symbol == "" || strings.ContainsAny(symbol, "/*") {
+				errs.add("invalid symbol %q of schema %d", symbol, i)
+			} else if j, ok := declared[key]; ok {
+				errs.add("%s is declared by both schema %d and schema %d", key, j, i)
+			} else {
+				declared[key] = i
+			}
+		}
+		m.Schemas = append(m.Schemas, &BucketSchema{
+			Symbols:        schema.Symbols,
+			Timeframe:      schema.Timeframe,
+			AttributeGroup: schema.AttributeGroup,
+			Columns:        schema.Columns,
+			VariableLength: schema.VariableLength,
+		})
 	}
+	parseBool("strict_schemas", aux.StrictSchemas, &m.StrictSchemas)
 
-	m.RootDirectory = aux.RootDirectory
-	m.ListenURL = fmt.Sprintf("%v:%v", aux.ListenHost, aux.ListenPort)
-	if aux.GRPCListenPort != "" {
-		m.GRPCListenURL = fmt.Sprintf("%v:%v", aux.ListenHost, aux.GRPCListenPort)
+	return errs.err()
+}
+
+type triggerConfig struct {
+	Module string                 `yaml:"module"`
+	On     string                 `yaml:"on"`
+	Config map[string]interface{} `yaml:"config"`
+}
+
+// ParseTriggers returns the triggers of the triggers block of a config,
+// e.g. to set up the same triggers as the server in a tool writing to its
+// root directory, validated like Parse does.
+func ParseTriggers(data []byte) ([]*TriggerSetting, error) {
+	var aux struct {
+		Triggers []triggerConfig `yaml:"triggers"`
+	}
+	if err := yaml.Unmarshal(data, &aux); err != nil {
+		return nil, err
 	}
-	m.UtilitiesURL = fmt.Sprintf("%v", aux.UtilitiesURL)
 
-	for _, trig := range aux.Triggers {
-		triggerSetting := &TriggerSetting{
+	var errs configErrors
+	triggers := parseTriggers(&errs, aux.Triggers)
+	return triggers, errs.err()
+}
+
+func parseTriggers(errs *configErrors, triggers []triggerConfig) []*TriggerSetting {
+	var settings []*TriggerSetting
+	for i, trig := range triggers {
+		if trig.Module == "" || trig.On == "" {
+			errs.add("trigger %d must have a module and an on pattern", i)
+		} else if err := checkTriggerPattern(trig.On); err != nil {
+			errs.add("invalid on pattern %q of trigger %d, %v", trig.On, i, err)
+		}
+		settings = append(settings, &TriggerSetting{
 			Module: trig.Module,
 			On:     trig.On,
 			Config: trig.Config,
+		})
+	}
+	return settings
+}
+
+// checkTriggerPattern returns an error if the pattern is not a
+// {symbol}/{timeframe}/{attribute group} key whose items may be *, such as
+// */1Min/OHLCV.
+func checkTriggerPattern(on string) error {
+	items := strings.Split(on, "/")
+	if len(items) != 3 {
+		return fmt.Errorf("must be {symbol}/{timeframe}/{attribute group}")
+	}
+	for _, item := range items {
+		if item == "" {
+			return fmt.Errorf("must be {symbol}/{timeframe}/{attribute group}")
 		}
-		m.Triggers = append(m.Triggers, triggerSetting)
 	}
+	if items[1] != "*" && TimeframeFromString(items[1]) == nil {
+		return fmt.Errorf("unknown timeframe %s", items[1])
+	}
+	// matched as a regular expression of the items, * matching any one
+	if _, err := regexp.Compile(strings.Replace(on, "*", "[^/]+", -1)); err != nil {
+		return err
+	}
+	return nil
+}
 
-	for _, bg := range aux.BgWorkers {
-		bgWorkerSetting := &BgWorkerSetting{
-			Module: bg.Module,
-			Name:   bg.Name,
-			Config: bg.Config,
+// EnvPrefix is the prefix of the environment variables overriding the
+// config values, followed by their names in upper case.
+const EnvPrefix = "MARKETSTORE_"
+
+// applyEnvOverrides sets the values of the config names to those of their
+// environment variables, if set, parsed to the type of the value.
+func applyEnvOverrides(errs *configErrors, values map[string]interface{}) {
+	for name, value := range values {
+		env := EnvPrefix + strings.ToUpper(name)
+		s, ok := os.LookupEnv(env)
+		if !ok {
+			continue
 		}
-		m.BgWorkers = append(m.BgWorkers, bgWorkerSetting)
+		switch v := value.(type) {
+		case *string:
+			*v = s
+		case *int:
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				errs.add("invalid %s %q, must be an integer", env, s)
+				continue
+			}
+			*v = n
+		case *bool:
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				errs.add("invalid %s %q, must be true or false", env, s)
+				continue
+			}
+			*v = b
+		}
+		log.Info("%s overrides %s of the config", env, name)
 	}
+}
+
+// maxGRPCMsgSize is the max size of a gRPC message in MB, which is limited
+// to 2GB by protobuf.
+const maxGRPCMsgSize = 2047
 
-	return err
+// configErrors are the problems found in a config.
+type configErrors []string
+
+func (e *configErrors) add(format string, args ...interface{}) {
+	*e = append(*e, fmt.Sprintf(format, args...))
+}
+
+// err returns the problem, or all of them in one error, or nil if none.
+func (e configErrors) err() error {
+	switch len(e) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(e[0])
+	}
+	return fmt.Errorf("%d config errors: %s", len(e), strings.Join(e, "; "))
+}
+
+// checkPort returns an error if port is not a TCP port number.
+func checkPort(port string) error {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return errors.New("must be a port number between 0 and 65535")
+	}
+	return nil
+}
+
+// checkWritableDir returns an error if dir exists but is not a writable
+// directory.  A missing one is not checked here.
+func checkWritableDir(dir string) error {
+	fi, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !fi.IsDir() {
+		return errors.New("not a directory")
+	}
+	f, err := ioutil.TempFile(dir, ".write-check")
+	if err != nil {
+		return fmt.Errorf("not writable: %v", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// CheckRootDirectory returns the absolute path of the root directory, after
+// checking that it is a writable directory, which is created if missing
+// with create, and otherwise an error saying how to fix it.
+func CheckRootDirectory(dir string, create bool) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("invalid root directory %q: %v", dir, err)
+	}
+	if _, err := os.Stat(absDir); os.IsNotExist(err) {
+		if !create {
+			return "", fmt.Errorf("root directory %s does not exist, create it or start with --create-root or create_root_directory: true", absDir)
+		}
+		if err := os.MkdirAll(absDir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create the root directory %s: %v", absDir, err)
+		}
+		log.Info("created the root directory %s", absDir)
+	}
+	if err := checkWritableDir(absDir); err != nil {
+		return "", fmt.Errorf("root directory %s is %v, it must be a directory writable by the user running marketstore",
+			absDir, err)
+	}
+	return absDir, nil
+}
+
+// parseAge parses a duration, which may also be in days with the d suffix.
+func parseAge(age string) (time.Duration, error) {
+	if strings.HasSuffix(age, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(age, "d"))
+		return time.Duration(days) * 24 * time.Hour, err
+	}
+	return time.ParseDuration(age)
 }
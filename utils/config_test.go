@@ -0,0 +1,271 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils/log"
+	. "gopkg.in/check.v1"
+)
+
+func (s *UtilsTestSuite) TestParseTimezone(c *C) {
+	config := "root_directory: data\nlisten_port: 5993\ntimezone: %s\n"
+
+	m := &MktsConfig{}
+	c.Assert(m.Parse([]byte(fmt.Sprintf(config, "America/New_York"))), IsNil)
+	c.Assert(m.Timezone.String(), Equals, "America/New_York")
+
+	m = &MktsConfig{}
+	c.Assert(m.Parse([]byte("root_directory: data\nlisten_port: 5993\n")), IsNil)
+	c.Assert(m.Timezone, Equals, time.UTC)
+
+	m = &MktsConfig{}
+	err := m.Parse([]byte(fmt.Sprintf(config, "America/New_Yrok")))
+	c.Assert(err, ErrorMatches, `invalid timezone "America/New_Yrok", must be an IANA time zone name such as America/New_York: .*`)
+}
+
+func (s *UtilsTestSuite) TestParseInvalid(c *C) {
+	file, err := ioutil.TempFile(c.MkDir(), "file")
+	c.Assert(err, IsNil)
+	file.Close()
+
+	const valid = "root_directory: data\nlisten_port: 5993\n"
+	for _, t := range []struct {
+		config string
+		err    string
+	}{
+		{"listen_port: 5993\n", `missing root_directory`},
+		{"root_directory: " + file.Name() + "\nlisten_port: 5993\n", `invalid root_directory ".*": not a directory`},
+		{"root_directory: data\n", `missing listen_port`},
+		{"root_directory: data\nlisten_port: 99999\n", `invalid listen_port "99999": must be a port number between 0 and 65535`},
+		{valid + "grpc_listen_port: grpc\n", `invalid grpc_listen_port "grpc": .*`},
+		{valid + "utilities_url: localhost\n", `invalid utilities_url "localhost", must be host:port: .*`},
+		{valid + "admin_listen_url: 9090\n", `invalid admin_listen_url "9090", must be host:port: .*`},
+		{valid + "grpc_max_send_msg_size: -1\n", `invalid grpc_max_send_msg_size -1MB, must be between 1 and 2047`},
+		{valid + "grpc_max_recv_msg_size: 4096\n", `invalid grpc_max_recv_msg_size 4096MB, must be between 1 and 2047`},
+		{valid + "max_query_result_size: -1\n", `invalid max_query_result_size -1, must not be negative`},
+		{valid + "query_cache_size: -1\n", `invalid query_cache_size -1, must not be negative`},
+		{valid + "disk_usage_interval: -60\n", `invalid disk_usage_interval -60, must not be negative`},
+		{valid + "ingestion_lag_interval: -1\n", `invalid ingestion_lag_interval -1, must not be negative`},
+		{valid + "write_idempotency_window: -1\n", `invalid write_idempotency_window -1, must not be negative`},
+		{valid + "max_open_files: -1\n", `invalid max_open_files -1, must not be negative`},
+		{valid + "stop_grace_period: -5\n", `invalid stop_grace_period -5, must not be negative`},
+		{valid + "dump_directory: " + file.Name() + "\n", `invalid dump_directory ".*": not a directory`},
+		{valid + "backup_directory: " + file.Name() + "\n", `invalid backup_directory ".*": not a directory`},
+		{valid + "dump_profiles: goroutine,flame\n", `invalid profile "flame" of dump_profiles, must be cpu or a runtime profile like goroutine or heap`},
+		{valid + "dump_cpu_duration: -1\n", `invalid dump_cpu_duration -1, must not be negative`},
+		{valid + "http_write_timeout: -1\n", `invalid http_write_timeout -1, must not be negative`},
+		{valid + "wal_rotate_interval: -1\n", `invalid wal_rotate_interval -1, must not be negative`},
+		{valid + "wal_rotation:\n  1Foo:\n    interval: 2\n", `invalid timeframe "1Foo" of wal_rotation`},
+		{valid + "wal_rotation:\n  1Min:\n    interval: -2\n", `invalid wal_rotation of 1Min, interval -2 and size 0 must not be negative`},
+		{valid + "wal_rotation:\n  1Sec:\n    size: -1\n", `invalid wal_rotation of 1Sec, interval 0 and size -1 must not be negative`},
+		{valid + "wal_commit_workers: -2\n", `invalid wal_commit_workers -2, must not be negative`},
+		{valid + "trigger_workers: -1\n", `invalid trigger_workers -1, must not be negative`},
+		{valid + "write_batch_window: -1\n", `invalid write_batch_window -1, must not be negative`},
+		{valid + "write_batch_size: -1\n", `invalid write_batch_size -1, must not be negative`},
+		{valid + "compression:\n  1Foo: zstd\n", `invalid timeframe "1Foo" of compression`},
+		{valid + "compression:\n  1Min: lz4\n", `invalid compression "lz4" of 1Min, must be none, snappy or zstd`},
+		{valid + "log_level: verbose\n", `invalid log_level "verbose", must be fatal, error, warning, info or debug`},
+		{valid + "log_format: xml\n", `invalid log_format "xml", must be json or text`},
+		{valid + "strict_writes: maybe\n", `invalid strict_writes "maybe", must be true or false`},
+		{valid + "late_data_window: -3\n", `invalid late_data_window -3, must not be negative`},
+		{valid + "write_duplicates: ignore\n", `invalid write_duplicates "ignore", must be append, overwrite or reject`},
+		{valid + "triggers:\n  - module: agg.so\n", `trigger 0 must have a module and an on pattern`},
+		{valid + "triggers:\n  - module: agg.so\n    on: \"*/1Min\"\n", `invalid on pattern "\*/1Min" of trigger 0, .*`},
+		{valid + "triggers:\n  - module: agg.so\n    on: \"*/5Q/OHLCV\"\n", `.* unknown timeframe 5Q`},
+		{valid + "triggers:\n  - module: agg.so\n    on: \"(*/1Min/OHLCV\"\n", `invalid on pattern .* of trigger 0, error parsing regexp: .*`},
+		{valid + "retention:\n  policies:\n    - max_age: forever\n", `invalid retention max_age "forever", .*`},
+		{valid + "schemas:\n  - symbols: [AAPL]\n    timeframe: 1Min\n", `schema 0 must have symbols, a timeframe, an attribute_group and columns`},
+		{valid + "schemas:\n  - symbols: [AAPL]\n    timeframe: 5Q\n    attribute_group: OHLCV\n    columns: Close/float32\n",
+			`invalid timeframe "5Q" of schema 0`},
+		{valid + "schemas:\n  - symbols: [AAPL, \"*\"]\n    timeframe: 1Min\n    attribute_group: OHLCV\n    columns: Close/float32\n",
+			`invalid symbol "\*" of schema 0`},
+		{valid + "schemas:\n  - symbols: [AAPL]\n    timeframe: 1Min\n    attribute_group: OHLCV\n    columns: Close/float32\n" +
+			"  - symbols: [TSLA, AAPL]\n    timeframe: 1Min\n    attribute_group: OHLCV\n    columns: Close/float64\n",
+			`AAPL/1Min/OHLCV is declared by both schema 0 and schema 1`},
+		{valid + "strict_schemas: always\n", `invalid strict_schemas "always", must be true or false`},
+		// all the problems at once
+		{"listen_port: -1\nenable_add: yes!\ntimezone: Mars/Olympus\n", `4 config errors: missing root_directory; ` +
+			`invalid listen_port "-1": .*; invalid timezone "Mars/Olympus", .*; invalid enable_add "yes!", must be true or false`},
+	} {
+		m := &MktsConfig{}
+		c.Check(m.Parse([]byte(t.config)), ErrorMatches, t.err, Commentf("%s", t.config))
+	}
+}
+
+func (s *UtilsTestSuite) TestParseWALRotation(c *C) {
+	m := &MktsConfig{}
+	c.Assert(m.Parse([]byte("root_directory: data\nlisten_port: 5993\nwal_rotate_interval: 3\nwal_rotation:\n"+
+		"  1Min:\n    interval: 10\n  1Sec:\n    interval: 1\n    size: 64\n")), IsNil)
+	c.Assert(m.WALRotateInterval, Equals, 3)
+	c.Assert(m.WALRotation, DeepEquals, map[time.Duration]WALRotation{
+		time.Minute: {Interval: 10},
+		time.Second: {Interval: 1, Size: 64 << 20},
+	})
+}
+
+func (s *UtilsTestSuite) TestParseStopGracePeriod(c *C) {
+	m := &MktsConfig{}
+	c.Assert(m.Parse([]byte("root_directory: data\nlisten_port: 5993\n")), IsNil)
+	c.Assert(m.StopGracePeriod, Equals, 30*time.Second)
+
+	m = &MktsConfig{}
+	c.Assert(m.Parse([]byte("root_directory: data\nlisten_port: 5993\nstop_grace_period: 5\n")), IsNil)
+	c.Assert(m.StopGracePeriod, Equals, 5*time.Second)
+}
+
+func (s *UtilsTestSuite) TestParseLogging(c *C) {
+	// back to the defaults of the log package for the other tests
+	defer log.SetFormat(log.JSON)
+	defer log.SetLevel(log.DEBUG)
+
+	m := &MktsConfig{}
+	c.Assert(m.Parse([]byte("root_directory: data\nlisten_port: 5993\n")), IsNil)
+	c.Assert(m.LogFormat, Equals, "json")
+	c.Assert(m.LogLevel, Equals, "")
+
+	for _, t := range []struct {
+		format, level string
+	}{
+		{"json", "debug"},
+		{"text", "info"},
+		{"Text", "warning"},
+		{"JSON", "Warn"},
+		{"text", "error"},
+		{"json", "FATAL"},
+	} {
+		m = &MktsConfig{}
+		config := fmt.Sprintf("root_directory: data\nlisten_port: 5993\nlog_format: %s\nlog_level: %s\n", t.format, t.level)
+		c.Assert(m.Parse([]byte(config)), IsNil, Commentf("%s", config))
+		c.Check(m.LogFormat, Equals, strings.ToLower(t.format))
+		c.Check(m.LogLevel, Equals, strings.ToLower(t.level))
+	}
+
+	m = &MktsConfig{}
+	err := m.Parse([]byte("root_directory: data\nlisten_port: 5993\nlog_format: yaml\nlog_level: trace\n"))
+	c.Assert(err, ErrorMatches, `2 config errors: invalid log_format "yaml", must be json or text; `+
+		`invalid log_level "trace", must be fatal, error, warning, info or debug`)
+}
+
+func (s *UtilsTestSuite) TestParseSchemas(c *C) {
+	m := &MktsConfig{}
+	c.Assert(m.Parse([]byte("root_directory: data\nlisten_port: 5993\nstrict_schemas: true\nschemas:\n"+
+		"  - symbols: [AAPL, TSLA]\n    timeframe: 1Min\n    attribute_group: OHLCV\n"+
+		"    columns: Open,High,Low,Close/float32:Volume/int64\n"+
+		"  - symbols: [AAPL]\n    timeframe: 1Sec\n    attribute_group: TRADE\n"+
+		"    columns: Price/decimal(4):Size/int32:Exchange/byte\n    variable_length: true\n")), IsNil)
+	c.Assert(m.StrictSchemas, Equals, true)
+	c.Assert(m.Schemas, DeepEquals, []*BucketSchema{
+		{
+			Symbols:        []string{"AAPL", "TSLA"},
+			Timeframe:      "1Min",
+			AttributeGroup: "OHLCV",
+			Columns:        "Open,High,Low,Close/float32:Volume/int64",
+		},
+		{
+			Symbols:        []string{"AAPL"},
+			Timeframe:      "1Sec",
+			AttributeGroup: "TRADE",
+			Columns:        "Price/decimal(4):Size/int32:Exchange/byte",
+			VariableLength: true,
+		},
+	})
+}
+
+func (s *UtilsTestSuite) TestParseTriggers(c *C) {
+	triggers, err := ParseTriggers([]byte("root_directory: data\ntriggers:\n" +
+		"  - module: ondiskagg.so\n    on: \"*/1Min/OHLCV\"\n    config:\n      destinations: [5Min, 1D]\n" +
+		"  - module: stream.so\n    on: \"AAPL/*/*\"\n"))
+	c.Assert(err, IsNil)
+	c.Assert(triggers, HasLen, 2)
+	c.Assert(triggers[0].Module, Equals, "ondiskagg.so")
+	c.Assert(triggers[0].On, Equals, "*/1Min/OHLCV")
+	c.Assert(triggers[0].Config["destinations"], DeepEquals, []interface{}{"5Min", "1D"})
+	c.Assert(triggers[1].On, Equals, "AAPL/*/*")
+
+	_, err = ParseTriggers([]byte("triggers:\n  - module: ondiskagg.so\n    on: \"*/1Min\"\n  - on: \"*/1Min/OHLCV\"\n"))
+	c.Assert(err, ErrorMatches, `2 config errors: invalid on pattern .*; trigger 1 must have a module and an on pattern`)
+}
+
+func (s *UtilsTestSuite) TestParseEnvOverrides(c *C) {
+	setenv := func(name, value string) {
+		c.Assert(os.Setenv(name, value), IsNil)
+	}
+	defer func() {
+		for _, name := range []string{"MARKETSTORE_ROOT_DIRECTORY", "MARKETSTORE_LISTEN_PORT",
+			"MARKETSTORE_GRPC_MAX_SEND_MSG_SIZE", "MARKETSTORE_ENABLE_PPROF", "MARKETSTORE_STRICT_WRITES"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	// the environment takes precedence over the file
+	setenv("MARKETSTORE_LISTEN_PORT", "6000")
+	setenv("MARKETSTORE_GRPC_MAX_SEND_MSG_SIZE", "128")
+	setenv("MARKETSTORE_ENABLE_PPROF", "true")
+	m := &MktsConfig{}
+	c.Assert(m.Parse([]byte("root_directory: data\nlisten_port: 5993\n")), IsNil)
+	c.Assert(m.ListenURL, Equals, ":6000")
+	c.Assert(m.GRPCMaxSendMsgSize, Equals, 128<<20)
+	c.Assert(m.MaxQueryResultBytes, Equals, int64(4096<<20))
+	c.Assert(m.EnablePprof, Equals, true)
+	c.Assert(m.RootDirectory, Equals, "data")
+
+	// and fills in the values missing from the file
+	setenv("MARKETSTORE_ROOT_DIRECTORY", "/tmp/mkts-env")
+	m = &MktsConfig{}
+	c.Assert(m.Parse([]byte("")), IsNil)
+	c.Assert(m.RootDirectory, Equals, "/tmp/mkts-env")
+
+	// only the values listed are overridden
+	setenv("MARKETSTORE_STRICT_WRITES", "true")
+	m = &MktsConfig{}
+	c.Assert(m.Parse([]byte("")), IsNil)
+	c.Assert(m.StrictWrites, Equals, false)
+
+	setenv("MARKETSTORE_GRPC_MAX_SEND_MSG_SIZE", "lots")
+	setenv("MARKETSTORE_ENABLE_PPROF", "on")
+	m = &MktsConfig{}
+	err := m.Parse([]byte(""))
+	c.Assert(err, ErrorMatches, `2 config errors: .*`)
+	c.Assert(err, ErrorMatches, `.*invalid MARKETSTORE_GRPC_MAX_SEND_MSG_SIZE "lots", must be an integer.*`)
+	c.Assert(err, ErrorMatches, `.*invalid MARKETSTORE_ENABLE_PPROF "on", must be true or false.*`)
+}
+
+func (s *UtilsTestSuite) TestCheckRootDirectory(c *C) {
+	dir := c.MkDir()
+
+	// an existing directory
+	absDir, err := CheckRootDirectory(dir, false)
+	c.Assert(err, IsNil)
+	c.Assert(absDir, Equals, dir)
+
+	// a missing one, only created if asked to
+	missing := filepath.Join(dir, "missing", "data")
+	_, err = CheckRootDirectory(missing, false)
+	c.Assert(err, ErrorMatches, `root directory `+missing+` does not exist, create it or .*`)
+	absDir, err = CheckRootDirectory(missing, true)
+	c.Assert(err, IsNil)
+	c.Assert(absDir, Equals, missing)
+	fi, err := os.Stat(missing)
+	c.Assert(err, IsNil)
+	c.Assert(fi.IsDir(), Equals, true)
+
+	// the relative paths are resolved
+	wd, err := os.Getwd()
+	c.Assert(err, IsNil)
+	c.Assert(os.Chdir(dir), IsNil)
+	defer os.Chdir(wd)
+	absDir, err = CheckRootDirectory("missing/data", false)
+	c.Assert(err, IsNil)
+	c.Assert(absDir, Equals, missing)
+
+	// a file
+	file := filepath.Join(dir, "file")
+	c.Assert(ioutil.WriteFile(file, nil, 0644), IsNil)
+	_, err = CheckRootDirectory(file, true)
+	c.Assert(err, ErrorMatches, `root directory `+file+` is not a directory, it must be a directory writable by .*`)
+}
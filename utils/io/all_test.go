@@ -561,3 +561,62 @@ func (s *TestSuite) TestApplyTimeQual(c *C) {
 
 	c.Assert(cs.ApplyTimeQual(tq).Len(), Equals, 0)
 }
+
+func (s *TestSuite) TestScaledValues(c *C) {
+	for _, test := range []struct {
+		value    float64
+		scale    int
+		expected int64
+	}{
+		{0.1, 1, 1},
+		{0.15, 1, 2},
+		{-0.15, 1, -2},
+		{19.99, 2, 1999},
+		{0.1 + 0.2, 8, 30000000},
+		{123456.00000001, 8, 12345600000001},
+		{9.2233720368547, 18, 9223372036854700000},
+	} {
+		scaled, err := ScaleFloat(test.value, 64, test.scale)
+		c.Assert(err, IsNil)
+		c.Check(scaled, Equals, test.expected, Commentf("%v at scale %d", test.value, test.scale))
+	}
+	_, err := ScaleFloat(10, 64, 18)
+	c.Assert(err, NotNil)
+	_, err = ScaleFloat(math.NaN(), 64, 2)
+	c.Assert(err, NotNil)
+
+	// float32 values are scaled from their own shortest representation
+	scaled, err := ScaleColumn([]float32{0.1, 1.7}, 10)
+	c.Assert(err, IsNil)
+	c.Assert(scaled, DeepEquals, []int64{1000000000, 17000000000})
+
+	c.Assert(FormatScaled(1999, 2), Equals, "19.99")
+	c.Assert(FormatScaled(-5, 3), Equals, "-0.005")
+	c.Assert(FormatScaled(42, 0), Equals, "42")
+	c.Assert(UnscaleColumn([]int64{1999, -5}, 2), DeepEquals, []float64{19.99, -0.05})
+
+	dsv, scales, err := DataShapesAndScalesFromInputString("Bid,Ask/decimal(8):Size/float32")
+	c.Assert(err, IsNil)
+	c.Assert(dsv, DeepEquals, []DataShape{{"Bid", INT64}, {"Ask", INT64}, {"Size", FLOAT32}})
+	c.Assert(scales, DeepEquals, map[string]int{"Bid": 8, "Ask": 8})
+	_, _, err = DataShapesAndScalesFromInputString("Bid/decimal(0)")
+	c.Assert(err, NotNil)
+}
+
+func (s *TestSuite) TestTimeBucketInfoScales(c *C) {
+	filePath := filepath.Join(c.MkDir(), "2018.bin")
+	dsv := NewDataShapeVector([]string{"Price", "Size"}, []EnumElementType{INT64, FLOAT32})
+	tbi := NewTimeBucketInfo(*utils.NewTimeframe("1Min"), filePath, "testing", 2018, dsv, FIXED)
+	c.Assert(tbi.SetElementScale("Price", 6), IsNil)
+	c.Assert(tbi.SetElementScale("Size", 6), NotNil)
+	c.Assert(tbi.SetElementScale("Missing", 6), NotNil)
+
+	fp, err := os.Create(filePath)
+	c.Assert(err, IsNil)
+	c.Assert(WriteHeader(fp, tbi), IsNil)
+	fp.Close()
+
+	tbi2 := TimeBucketInfo{Year: 2018, Path: filePath}
+	c.Assert(tbi2.GetElementScales(), DeepEquals, []int8{6, 0})
+	c.Assert(tbi2.GetDeepCopy().GetElementScales(), DeepEquals, []int8{6, 0})
+}
@@ -0,0 +1,503 @@
+// Package blockfile stores the data of a file compressed in fixed size
+// blocks, read and written at the offsets of the uncompressed data, so that
+// a compressed data file is used in place of an uncompressed one.
+//
+// After the header, which is stored as is, the file has a table of the
+// blocks, followed by the frames of the compressed blocks, appended as the
+// blocks are written:
+//
+//	[0, headerSize)                  the header
+//	[headerSize, headerSize+table)   an entry of 16 bytes per block
+//	[headerSize+table, ...)          the frames
+//
+// The entry of a block has the offset and the length of its frame, or is
+// zero if the block was never written, which then reads as zeros like the
+// holes of an uncompressed file.  A frame is the block number, the length
+// and the CRC of its payload, followed by the payload.
+//
+// A block is rewritten by appending its new frame, then pointing its entry
+// to it, so the frames written before are never overwritten.  If a crash
+// leaves the entry pointing to a torn frame, the frame fails its CRC and
+// the block is recovered from the last intact frame of the block in the
+// file, which has at least the data synced before, as for the uncompressed
+// files the WAL replay then writes the records of the block again.
+// The entries are aligned, so an entry is never torn.
+//
+// The frames replaced are compacted away by rewriting the file once they
+// take more space than the current ones.
+package blockfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// BlockSize is the size of the uncompressed data of a block.
+	BlockSize = 64 * 1024
+
+	entrySize       = 16
+	frameHeaderSize = 12
+	// minCompaction is the size of the replaced frames below which the
+	// file is not compacted
+	minCompaction = 1 << 20
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var errCorrupt = errors.New("corrupt frame")
+
+// entry is the location of the frame of a block.
+type entry struct {
+	offset int64
+	length int64
+}
+
+// File is a compressed data file.  It buffers the block last read or
+// written, so like an *os.File it is not safe for concurrent use, and it
+// must be closed, or synced, for its writes to be written to the file.
+type File struct {
+	fp         *os.File
+	codec      Codec
+	headerSize int64
+	dataSize   int64
+	table      []entry
+	end        int64
+	writable   bool
+
+	// the block buffered, dirty if written since it was read
+	cached int64
+	block  []byte
+	dirty  bool
+	// recovered are the frames of the blocks whose entry points to a
+	// corrupt frame, once the file is scanned for them
+	recovered map[int64]entry
+}
+
+func numBlocks(dataSize int64) int64 {
+	return (dataSize + BlockSize - 1) / BlockSize
+}
+
+// Create lays out an empty compressed file of dataSize bytes of data in fp,
+// after the header of headerSize bytes written to it.
+func Create(fp *os.File, headerSize, dataSize int64) error {
+	return fp.Truncate(headerSize + numBlocks(dataSize)*entrySize)
+}
+
+// New returns the compressed file of dataSize bytes of data after the
+// header of headerSize bytes, compressed by codec, in fp opened with flag.
+// The file takes over fp, closed by Close.
+func New(fp *os.File, flag int, codec Codec, headerSize, dataSize int64) (*File, error) {
+	if codec == None {
+		return nil, fmt.Errorf("%s is not compressed", fp.Name())
+	}
+	f := &File{
+		fp:         fp,
+		codec:      codec,
+		headerSize: headerSize,
+		dataSize:   dataSize,
+		writable:   flag&(os.O_WRONLY|os.O_RDWR) != 0,
+		cached:     -1,
+	}
+	if err := f.load(); err != nil {
+		return nil, fmt.Errorf("invalid compressed file %s: %v", fp.Name(), err)
+	}
+	return f, nil
+}
+
+func (f *File) load() error {
+	fi, err := f.fp.Stat()
+	if err != nil {
+		return err
+	}
+	f.end = fi.Size()
+	tableEnd := f.headerSize + numBlocks(f.dataSize)*entrySize
+	if f.end < tableEnd {
+		return fmt.Errorf("truncated block table, %d bytes of %d", f.end, tableEnd)
+	}
+	buf := make([]byte, tableEnd-f.headerSize)
+	if _, err = f.fp.ReadAt(buf, f.headerSize); err != nil {
+		return err
+	}
+	f.table = make([]entry, numBlocks(f.dataSize))
+	for i := range f.table {
+		f.table[i] = entry{
+			offset: int64(binary.LittleEndian.Uint64(buf[i*entrySize:])),
+			length: int64(binary.LittleEndian.Uint32(buf[i*entrySize+8:])),
+		}
+	}
+	return nil
+}
+
+// Size returns the size of the file uncompressed.
+func (f *File) Size() int64 {
+	return f.headerSize + f.dataSize
+}
+
+// Name returns the name of the file.
+func (f *File) Name() string {
+	return f.fp.Name()
+}
+
+func (f *File) blockLen(block int64) int64 {
+	if rest := f.dataSize - block*BlockSize; rest < BlockSize {
+		return rest
+	}
+	return BlockSize
+}
+
+// ReadAt reads the data of the file uncompressed at off.
+func (f *File) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= f.Size() {
+			return n, io.EOF
+		}
+		if pos < f.headerSize {
+			end := len(p)
+			if rest := f.headerSize - pos; int64(end-n) > rest {
+				end = n + int(rest)
+			}
+			m, err := f.fp.ReadAt(p[n:end], pos)
+			n += m
+			if err != nil {
+				return n, err
+			}
+			continue
+		}
+		block, start := (pos-f.headerSize)/BlockSize, (pos-f.headerSize)%BlockSize
+		if err = f.buffer(block); err != nil {
+			return n, err
+		}
+		n += copy(p[n:], f.block[start:])
+	}
+	return n, nil
+}
+
+// WriteAt writes the data of the file uncompressed at off, which is written
+// to the file compressed once the block written is no longer buffered.
+func (f *File) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off+int64(len(p)) > f.Size() {
+		return 0, fmt.Errorf("write of %d bytes at %d past the end of %s", len(p), off, f.Name())
+	}
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos < f.headerSize {
+			end := len(p)
+			if rest := f.headerSize - pos; int64(end-n) > rest {
+				end = n + int(rest)
+			}
+			m, err := f.fp.WriteAt(p[n:end], pos)
+			n += m
+			if err != nil {
+				return n, err
+			}
+			continue
+		}
+		block, start := (pos-f.headerSize)/BlockSize, (pos-f.headerSize)%BlockSize
+		if err = f.buffer(block); err != nil {
+			return n, err
+		}
+		n += copy(f.block[start:], p[n:])
+		f.dirty = true
+	}
+	return n, nil
+}
+
+// buffer reads the block into the buffer, writing the dirty one first.
+func (f *File) buffer(block int64) error {
+	if block == f.cached {
+		return nil
+	}
+	if err := f.flush(); err != nil {
+		return err
+	}
+	data, err := f.readBlock(block)
+	if err != nil {
+		return err
+	}
+	f.cached, f.block = block, data
+	return nil
+}
+
+// readBlock returns the data of the block, recovered from its last intact
+// frame if its entry points to a corrupt one.
+func (f *File) readBlock(block int64) ([]byte, error) {
+	data, err := f.readFrame(block, f.table[block])
+	if err != errCorrupt {
+		return data, err
+	}
+	if f.recovered == nil {
+		if f.recovered, err = f.scan(); err != nil {
+			return nil, err
+		}
+	}
+	data, err = f.readFrame(block, f.recovered[block])
+	if err == errCorrupt {
+		return nil, fmt.Errorf("block %d of %s has no intact frame", block, f.Name())
+	}
+	return data, err
+}
+
+// readFrame returns the data of the block in the frame of e, or errCorrupt
+// if the frame is not intact.
+func (f *File) readFrame(block int64, e entry) ([]byte, error) {
+	size := f.blockLen(block)
+	if e.offset == 0 {
+		return make([]byte, size), nil
+	}
+	if e.length < frameHeaderSize || e.offset+e.length > f.end {
+		return nil, errCorrupt
+	}
+	frame := make([]byte, e.length)
+	if _, err := f.fp.ReadAt(frame, e.offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if int64(binary.LittleEndian.Uint32(frame)) != block {
+		return nil, errCorrupt
+	}
+	payload, ok := framePayload(frame)
+	if !ok {
+		return nil, errCorrupt
+	}
+	data, err := f.codec.decode(payload, int(size))
+	if err != nil || int64(len(data)) != size {
+		return nil, errCorrupt
+	}
+	return data, nil
+}
+
+// framePayload returns the payload of the frame, and false if it fails
+// its length or its CRC.  The payload is never empty, so that the zeros
+// of a torn frame are not mistaken for a frame.
+func framePayload(frame []byte) ([]byte, bool) {
+	length := int(binary.LittleEndian.Uint32(frame[4:]))
+	if length == 0 || length != len(frame)-frameHeaderSize {
+		return nil, false
+	}
+	payload := frame[frameHeaderSize:]
+	return payload, crc32.Checksum(payload, castagnoli) == binary.LittleEndian.Uint32(frame[8:])
+}
+
+// scan returns the last intact frame of each block in the file, read up
+// to the first frame that is not intact, after which the frames are torn.
+func (f *File) scan() (map[int64]entry, error) {
+	frames := map[int64]entry{}
+	header := make([]byte, frameHeaderSize)
+	for offset := f.headerSize + int64(len(f.table))*entrySize; offset+frameHeaderSize <= f.end; {
+		if _, err := f.fp.ReadAt(header, offset); err != nil {
+			return nil, err
+		}
+		block := int64(binary.LittleEndian.Uint32(header))
+		length := frameHeaderSize + int64(binary.LittleEndian.Uint32(header[4:]))
+		if block >= int64(len(f.table)) || offset+length > f.end {
+			break
+		}
+		frame := make([]byte, length)
+		if _, err := f.fp.ReadAt(frame, offset); err != nil {
+			return nil, err
+		}
+		if _, ok := framePayload(frame); !ok {
+			break
+		}
+		frames[block] = entry{offset: offset, length: length}
+		offset += length
+	}
+	return frames, nil
+}
+
+// flush appends the frame of the dirty block and points its entry to it.
+// A block of zeros has no frame.
+func (f *File) flush() error {
+	if !f.dirty {
+		return nil
+	}
+	e := entry{}
+	if !allZeros(f.block) {
+		payload := f.codec.encode(f.block)
+		frame := make([]byte, frameHeaderSize, frameHeaderSize+len(payload))
+		binary.LittleEndian.PutUint32(frame, uint32(f.cached))
+		binary.LittleEndian.PutUint32(frame[4:], uint32(len(payload)))
+		binary.LittleEndian.PutUint32(frame[8:], crc32.Checksum(payload, castagnoli))
+		frame = append(frame, payload...)
+		if _, err := f.fp.WriteAt(frame, f.end); err != nil {
+			return err
+		}
+		e = entry{offset: f.end, length: int64(len(frame))}
+		f.end += e.length
+	}
+	if err := f.writeEntry(f.cached, e); err != nil {
+		return err
+	}
+	f.dirty = false
+	return nil
+}
+
+func (f *File) writeEntry(block int64, e entry) error {
+	var buf [entrySize]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(e.offset))
+	binary.LittleEndian.PutUint32(buf[8:], uint32(e.length))
+	if _, err := f.fp.WriteAt(buf[:], f.headerSize+block*entrySize); err != nil {
+		return err
+	}
+	f.table[block] = e
+	delete(f.recovered, block)
+	return nil
+}
+
+func allZeros(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Sync writes the dirty block and syncs the file.
+func (f *File) Sync() error {
+	if err := f.flush(); err != nil {
+		return err
+	}
+	return f.fp.Sync()
+}
+
+// Close writes the dirty block, compacts the file if the frames replaced
+// take more space than the current ones, and closes it.
+func (f *File) Close() error {
+	err := f.flush()
+	if err == nil && f.writable {
+		live, replaced := f.Usage()
+		if replaced > live && replaced >= minCompaction {
+			err = f.compact()
+		}
+	}
+	if cerr := f.fp.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Usage returns the bytes of the current frames of the blocks, and of the
+// frames replaced by them.
+func (f *File) Usage() (live, replaced int64) {
+	for _, e := range f.table {
+		live += e.length
+	}
+	replaced = f.end - f.headerSize - int64(len(f.table))*entrySize - live
+	return live, replaced
+}
+
+// compact rewrites the file with only the current frames of the blocks.
+// The file is replaced by a synced copy, so that the readers of the file
+// still read the file they opened.
+func (f *File) compact() error {
+	path := f.fp.Name()
+	tmpPath := path + ".tmp"
+	os.Remove(tmpPath)
+	fi, err := f.fp.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_RDWR, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	err = f.copyTo(out)
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to compact %s: %v", path, err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func (f *File) copyTo(out *os.File) error {
+	header := make([]byte, f.headerSize)
+	if _, err := f.fp.ReadAt(header, 0); err != nil {
+		return err
+	}
+	if _, err := out.WriteAt(header, 0); err != nil {
+		return err
+	}
+	if err := Create(out, f.headerSize, f.dataSize); err != nil {
+		return err
+	}
+	c := &File{fp: out, codec: f.codec, headerSize: f.headerSize, dataSize: f.dataSize, cached: -1}
+	if err := c.load(); err != nil {
+		return err
+	}
+	for block := range f.table {
+		data, err := f.readBlock(int64(block))
+		if err != nil {
+			return err
+		}
+		c.cached, c.block, c.dirty = int64(block), data, true
+		if err = c.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// CorruptBlocks returns the blocks whose entry points to a frame that is
+// not intact, which are read from their last intact frame.
+func (f *File) CorruptBlocks() ([]int64, error) {
+	var corrupt []int64
+	for block, e := range f.table {
+		if _, err := f.readFrame(int64(block), e); err == errCorrupt {
+			corrupt = append(corrupt, int64(block))
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return corrupt, nil
+}
+
+// Repair points the entries of the corrupt blocks to their last intact
+// frame, or clears them if they have none, and syncs the file.
+func (f *File) Repair() error {
+	corrupt, err := f.CorruptBlocks()
+	if err != nil || len(corrupt) == 0 {
+		return err
+	}
+	if f.recovered == nil {
+		if f.recovered, err = f.scan(); err != nil {
+			return err
+		}
+	}
+	for _, block := range corrupt {
+		if err = f.writeEntry(block, f.recovered[block]); err != nil {
+			return err
+		}
+	}
+	return f.fp.Sync()
+}
@@ -0,0 +1,240 @@
+package blockfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	testHeaderSize = 312
+	testDataSize   = 5*BlockSize + 1000
+)
+
+func create(t testing.TB, codec Codec) string {
+	path := filepath.Join(t.TempDir(), "2020.bin")
+	fp, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := bytes.Repeat([]byte{0xAB}, testHeaderSize)
+	if _, err = fp.Write(header); err != nil {
+		t.Fatal(err)
+	}
+	if err = Create(fp, testHeaderSize, testDataSize); err != nil {
+		t.Fatal(err)
+	}
+	if err = fp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func open(t testing.TB, path string, flag int, codec Codec) *File {
+	fp, err := os.OpenFile(path, flag, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := New(fp, flag, codec, testHeaderSize, testDataSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// records returns n records of 8 bytes index and 32 bytes of prices
+func records(n int, seed int64) []byte {
+	r := rand.New(rand.NewSource(seed))
+	buf := make([]byte, n*40)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint64(buf[i*40:], uint64(i+1))
+		for j := 8; j < 40; j += 8 {
+			binary.LittleEndian.PutUint64(buf[i*40+j:], uint64(100000+r.Intn(100)))
+		}
+	}
+	return buf
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, codec := range []Codec{Snappy, Zstd} {
+		t.Run(codec.String(), func(t *testing.T) {
+			path := create(t, codec)
+			data := records(3000, 1)
+			offset := int64(testHeaderSize + BlockSize - 100)
+
+			f := open(t, path, os.O_RDWR, codec)
+			if _, err := f.WriteAt(data, offset); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			f = open(t, path, os.O_RDONLY, codec)
+			defer f.Close()
+			got := make([]byte, len(data))
+			if _, err := f.ReadAt(got, offset); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatal("read data differs from written")
+			}
+			// never written data reads as zeros
+			zeros := make([]byte, 1000)
+			if _, err := f.ReadAt(zeros, testHeaderSize+testDataSize-1000); err != nil {
+				t.Fatal(err)
+			}
+			if !allZeros(zeros) {
+				t.Fatal("never written data is not zeros")
+			}
+			// the header is stored as is
+			header := make([]byte, testHeaderSize)
+			if _, err := f.ReadAt(header, 0); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(header, bytes.Repeat([]byte{0xAB}, testHeaderSize)) {
+				t.Fatal("header differs from written")
+			}
+			fi, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fi.Size() >= int64(testHeaderSize+len(data)) {
+				t.Fatalf("file of %d bytes is not compressed", fi.Size())
+			}
+		})
+	}
+}
+
+func TestTornFrame(t *testing.T) {
+	path := create(t, Snappy)
+	first, second := records(100, 1), records(100, 2)
+	offset := int64(testHeaderSize)
+
+	f := open(t, path, os.O_RDWR, Snappy)
+	if _, err := f.WriteAt(first, offset); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt(second, offset); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// tear the last frame, as if the crash was before all of it was written
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Truncate(path, fi.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+
+	f = open(t, path, os.O_RDWR, Snappy)
+	corrupt, err := f.CorruptBlocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrupt) != 1 || corrupt[0] != 0 {
+		t.Fatalf("corrupt blocks %v, expected [0]", corrupt)
+	}
+	got := make([]byte, len(first))
+	if _, err = f.ReadAt(got, offset); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, first) {
+		t.Fatal("block is not recovered from its previous frame")
+	}
+	if err = f.Repair(); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f = open(t, path, os.O_RDONLY, Snappy)
+	defer f.Close()
+	if corrupt, err = f.CorruptBlocks(); err != nil || len(corrupt) != 0 {
+		t.Fatalf("corrupt blocks %v after the repair, error: %v", corrupt, err)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	path := create(t, Snappy)
+	r := rand.New(rand.NewSource(1))
+	block := make([]byte, BlockSize)
+
+	// the incompressible blocks rewritten take more than minCompaction
+	for i := 0; i < 3*minCompaction/BlockSize; i++ {
+		f := open(t, path, os.O_RDWR, Snappy)
+		r.Read(block)
+		if _, err := f.WriteAt(block, testHeaderSize); err != nil {
+			t.Fatal(err)
+		}
+		if err := f.Sync(); err != nil {
+			t.Fatal(err)
+		}
+		f.fp.Close()
+	}
+	f := open(t, path, os.O_RDWR, Snappy)
+	if _, replaced := f.Usage(); replaced < minCompaction {
+		t.Fatalf("%d bytes replaced, expected at least %d", replaced, minCompaction)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f = open(t, path, os.O_RDONLY, Snappy)
+	defer f.Close()
+	if live, replaced := f.Usage(); replaced != 0 || live < BlockSize {
+		t.Fatalf("%d bytes live and %d replaced after the compaction", live, replaced)
+	}
+	got := make([]byte, BlockSize)
+	if _, err := f.ReadAt(got, testHeaderSize); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, block) {
+		t.Fatal("compaction lost the last write")
+	}
+}
+
+func TestParseCodec(t *testing.T) {
+	for name, expected := range map[string]Codec{"": None, "none": None, "snappy": Snappy, "zstd": Zstd} {
+		if codec, err := ParseCodec(name); err != nil || codec != expected {
+			t.Errorf("ParseCodec(%q) = %v, %v", name, codec, err)
+		}
+	}
+	if _, err := ParseCodec("lz4"); err == nil {
+		t.Error("ParseCodec(\"lz4\") succeeded")
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	data := records(testDataSize/40, 1)
+	for _, codec := range []Codec{Snappy, Zstd} {
+		b.Run(codec.String(), func(b *testing.B) {
+			path := create(b, codec)
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				f := open(b, path, os.O_RDWR, codec)
+				if _, err := f.WriteAt(data, testHeaderSize); err != nil {
+					b.Fatal(err)
+				}
+				if err := f.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			f := open(b, path, os.O_RDONLY, codec)
+			live, _ := f.Usage()
+			f.Close()
+			b.ReportMetric(float64(live)/float64(len(data)), "ratio")
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package blockfile
+
+import (
+	"fmt"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec is the compression of the blocks of a file, stored in its header.
+type Codec int64
+
+const (
+	// None is an uncompressed file, whose data is stored as is.
+	None Codec = iota
+	Snappy
+	Zstd
+)
+
+var codecNames = map[Codec]string{
+	None:   "none",
+	Snappy: "snappy",
+	Zstd:   "zstd",
+}
+
+// ParseCodec returns the codec of the name, None if empty.
+func ParseCodec(name string) (Codec, error) {
+	if name == "" {
+		return None, nil
+	}
+	for codec, codecName := range codecNames {
+		if name == codecName {
+			return codec, nil
+		}
+	}
+	return None, fmt.Errorf("unknown codec %q, must be none, snappy or zstd", name)
+}
+
+func (c Codec) String() string {
+	if name, ok := codecNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("codec(%d)", int64(c))
+}
+
+// the zstd encoder and decoder are stateless for EncodeAll and DecodeAll,
+// which are safe for concurrent use
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+)
+
+func (c Codec) encode(data []byte) []byte {
+	switch c {
+	case Snappy:
+		return snappy.Encode(nil, data)
+	case Zstd:
+		return zstdEncoder.EncodeAll(data, nil)
+	}
+	return data
+}
+
+func (c Codec) decode(payload []byte, size int) ([]byte, error) {
+	switch c {
+	case Snappy:
+		return snappy.Decode(make([]byte, size), payload)
+	case Zstd:
+		return zstdDecoder.DecodeAll(payload, make([]byte, 0, size))
+	}
+	return nil, fmt.Errorf("unknown codec %d", int64(c))
+}
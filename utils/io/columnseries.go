@@ -254,6 +254,18 @@ func (cs *ColumnSeries) AddNullColumn(ds DataShape) {
 func (cs *ColumnSeries) ApplyTimeQual(tq func(epoch int64) bool) *ColumnSeries {
 	indexes := []int{}
 
+	for i, epoch := range cs.GetEpoch() {
+		if tq(epoch) {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return cs.SelectRows(indexes)
+}
+
+// SelectRows returns a new ColumnSeries with the rows at the
+// given indexes, in the order of the indexes.
+func (cs *ColumnSeries) SelectRows(indexes []int) *ColumnSeries {
 	out := &ColumnSeries{
 		orderedNames:     cs.orderedNames,
 		candleAttributes: cs.candleAttributes,
@@ -261,12 +273,6 @@ func (cs *ColumnSeries) ApplyTimeQual(tq func(epoch int64) bool) *ColumnSeries {
 		columns:          map[string]interface{}{},
 	}
 
-	for i, epoch := range cs.GetEpoch() {
-		if tq(epoch) {
-			indexes = append(indexes, i)
-		}
-	}
-
 	for name, col := range cs.columns {
 		iv := reflect.ValueOf(col)
 		slc := reflect.MakeSlice(reflect.TypeOf(col), 0, 0)
@@ -281,6 +287,38 @@ func (cs *ColumnSeries) ApplyTimeQual(tq func(epoch int64) bool) *ColumnSeries {
 	return out
 }
 
+// ColumnSeriesConcat returns a new ColumnSeries with the rows of
+// first followed by those of second, which must have the same columns
+// of the same types.
+func ColumnSeriesConcat(first, second *ColumnSeries) (*ColumnSeries, error) {
+	out := &ColumnSeries{
+		orderedNames:     first.orderedNames,
+		candleAttributes: first.candleAttributes,
+		nameIncrement:    first.nameIncrement,
+		columns:          map[string]interface{}{},
+	}
+
+	if len(first.columns) != len(second.columns) {
+		return nil, fmt.Errorf("cannot concatenate %d columns with %d columns",
+			len(first.columns), len(second.columns))
+	}
+	for name, col := range first.columns {
+		other, ok := second.columns[name]
+		if !ok {
+			return nil, fmt.Errorf("column %s is missing", name)
+		}
+		if reflect.TypeOf(col) != reflect.TypeOf(other) {
+			return nil, fmt.Errorf("column %s is of type %T and %T", name, col, other)
+		}
+		iv, ov := reflect.ValueOf(col), reflect.ValueOf(other)
+		slc := reflect.MakeSlice(iv.Type(), 0, iv.Len()+ov.Len())
+		slc = reflect.AppendSlice(slc, iv)
+		out.columns[name] = reflect.AppendSlice(slc, ov).Interface()
+	}
+
+	return out, nil
+}
+
 // SliceColumnSeriesByEpoch slices the column series by the provided epochs,
 // returning a new column series with only records occurring
 // between the two provided epoch times. If only one is provided,
@@ -438,12 +476,18 @@ func (csm *ColumnSeriesMap) FilterColumns(columns []string) {
 		return
 	}
 
-	// index columns (=Epoch and Nanoseconds) are always necessary and Epoch should be the first column
-	keepColumns := []string{"Epoch"}
-	keepColumns = append(keepColumns, columns...)
-	keepColumns = append(keepColumns, "Nanoseconds")
-
 	for _, cs := range *csm {
+		// index columns (=Epoch and Nanoseconds) are always necessary and Epoch should be the first column
+		keepColumns := []string{"Epoch"}
+		for _, name := range columns {
+			if name != "Epoch" && name != "Nanoseconds" {
+				keepColumns = append(keepColumns, name)
+			}
+		}
+		if cs.Exists("Nanoseconds") {
+			keepColumns = append(keepColumns, "Nanoseconds")
+		}
+
 		// filter out unnecessary columns
 		err := cs.Project(keepColumns)
 		if err != nil {
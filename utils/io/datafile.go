@@ -0,0 +1,52 @@
+package io
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+	"time"
+
+	"github.com/alpacahq/marketstore/v4/utils/io/blockfile"
+)
+
+// DataFile is an open year file, read and written at the offsets of its
+// uncompressed data whether it is compressed or not.
+type DataFile interface {
+	goio.ReaderAt
+	goio.WriterAt
+	goio.Closer
+	Sync() error
+}
+
+// OpenDataFile opens the year file at path with flag, as an *os.File if it
+// is not compressed, or as a *blockfile.File with its codec otherwise.
+func OpenDataFile(path string, flag int) (DataFile, error) {
+	fp, err := os.OpenFile(path, flag, 0600)
+	if err != nil {
+		return nil, err
+	}
+	df, err := NewDataFile(fp, flag)
+	if err != nil {
+		fp.Close()
+		return nil, err
+	}
+	return df, nil
+}
+
+// NewDataFile returns the year file fp opened with flag, fp itself if it is
+// not compressed, or the *blockfile.File of fp, taking it over, otherwise.
+func NewDataFile(fp *os.File, flag int) (DataFile, error) {
+	// the fixed part of the header, up to the element names
+	var buffer [312]byte
+	if _, err := fp.ReadAt(buffer[:], 0); err != nil {
+		return nil, fmt.Errorf("failed to read the header of %s: %v", fp.Name(), err)
+	}
+	// the offsets of the fields in Header
+	year, timeframe := ToInt64(buffer[264:]), ToInt64(buffer[272:])
+	recordLength, codec := ToInt64(buffer[296:]), blockfile.Codec(ToInt64(buffer[304:]))
+	if codec == blockfile.None {
+		return fp, nil
+	}
+	dataSize := FileSize(time.Duration(timeframe), int(year), int(recordLength)) - Headersize
+	return blockfile.New(fp, flag, codec, Headersize, dataSize)
+}
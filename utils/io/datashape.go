@@ -38,28 +38,47 @@ func (ds *DataShape) Equal(shape DataShape) bool {
 }
 
 func DataShapesFromInputString(inputStr string) (dsa []DataShape, err error) {
+	dsa, _, err = DataShapesAndScalesFromInputString(inputStr)
+	return dsa, err
+}
+
+// DataShapesAndScalesFromInputString parses the data shape string as
+// DataShapesFromInputString, where the type may also be decimal(<scale>) for
+// scaled integer columns, which are int64 with the scale returned by name.
+func DataShapesAndScalesFromInputString(inputStr string) (dsa []DataShape, scales map[string]int, err error) {
 	splitString := strings.Split(inputStr, ":")
 	dsa = make([]DataShape, 0)
+	scales = map[string]int{}
 	for _, group := range splitString {
 		twoParts := strings.Split(group, "/")
 		if len(twoParts) != 2 {
 			err = fmt.Errorf("error: %s: Data shape is not described by a list of column names followed by type.", group)
 			fmt.Println(err.Error())
-			return nil, err
+			return nil, nil, err
 		}
 		elementNames := strings.Split(twoParts[0], ",")
 		elementType := twoParts[1]
+		scale, isDecimal, err := parseDecimalType(elementType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error: %s: %v", group, err)
+		}
 		eType := EnumElementTypeFromName(elementType)
+		if isDecimal {
+			eType = INT64
+		}
 		if eType == NONE {
 			err = fmt.Errorf("error: %s: Data type is not a supported type", group)
 			fmt.Println(err.Error())
-			return nil, err
+			return nil, nil, err
 		}
 		for _, name := range elementNames {
 			dsa = append(dsa, DataShape{Name: name, Type: eType})
+			if isDecimal {
+				scales[name] = scale
+			}
 		}
 	}
-	return dsa, nil
+	return dsa, scales, nil
 }
 
 func (ds *DataShape) toBytes() ([]byte, error) {
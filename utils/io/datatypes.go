@@ -46,6 +46,8 @@ const (
 	BYTE
 	BOOL
 	NONE
+	// STRING is stored in the records as an 8 byte reference to the string
+	// in a side file of the bucket
 	STRING
 	INT16
 	UINT8
@@ -69,7 +71,7 @@ var (
 		BYTE:    {reflect.Int8, "byte", 1, reflect.TypeOf(byte(0))},
 		BOOL:    {reflect.Bool, "bool", 1, reflect.TypeOf(bool(false))},
 		NONE:    {reflect.Invalid, "none", 0, reflect.TypeOf(byte(0))},
-		STRING:  {reflect.String, "string", 0, reflect.TypeOf("")},
+		STRING:  {reflect.String, "string", 8, reflect.TypeOf("")},
 		INT16:   {reflect.Int16, "int16", 2, reflect.TypeOf(int16(0))},
 		UINT8:   {reflect.Uint8, "uint8", 1, reflect.TypeOf(uint8(0))},
 		UINT16:  {reflect.Uint16, "uint16", 2, reflect.TypeOf(uint16(0))},
@@ -136,7 +138,8 @@ func (e EnumElementType) ConvertByteSliceInto(data []byte) interface{} {
 	case INT16:
 		return SwapSliceByte(data, int16(0)).([]int16)
 	case STRING:
-		return SwapSliceByte(data, string(0)).([]string)
+		// the references to the strings stored out of the records
+		return SwapSliceByte(data, int64(0)).([]int64)
 	case UINT8:
 		return SwapSliceByte(data, uint8(0)).([]uint8)
 	case UINT16:
@@ -0,0 +1,176 @@
+package io
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxScale is the largest scale of a scaled integer column, as 10^18 is the
+// largest power of ten an int64 holds.
+const MaxScale = 18
+
+// decimalTypeName is the type of a scaled integer column in a data shape
+// string, followed by the scale in parentheses, e.g. "decimal(8)".
+const decimalTypeName = "decimal"
+
+/*
+A scaled integer (decimal) column stores value × 10^scale as an INT64, so that
+prices are kept exactly rather than rounded to the nearest float.  The scale of
+each column is recorded in the file header, with zero for a plain INT64 column,
+which is the case for every file written before scaled columns existed.
+*/
+
+// ValidateScale checks the scale of a scaled integer column, which is at least
+// 1 as zero is of a plain INT64 column.
+func ValidateScale(scale int) error {
+	if scale < 1 || scale > MaxScale {
+		return fmt.Errorf("scale %d is out of the range 1 to %d", scale, MaxScale)
+	}
+	return nil
+}
+
+// ScaleColumn returns the values of a column scaled to integers of the scale.
+// Floats are converted from their shortest decimal representation, rounding
+// half away from zero the digits beyond the scale, and an []int64 is taken as
+// already scaled.
+func ScaleColumn(values interface{}, scale int) ([]int64, error) {
+	switch col := values.(type) {
+	case []int64:
+		return col, nil
+	case []float64:
+		out := make([]int64, len(col))
+		for i, v := range col {
+			scaled, err := ScaleFloat(v, 64, scale)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = scaled
+		}
+		return out, nil
+	case []float32:
+		out := make([]int64, len(col))
+		for i, v := range col {
+			scaled, err := ScaleFloat(float64(v), 32, scale)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = scaled
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("cannot scale values of type %T", values)
+	}
+}
+
+// ScaleFloat returns v × 10^scale as an integer, from the shortest decimal
+// representation of v as a float of bitSize bits.
+func ScaleFloat(v float64, bitSize, scale int) (int64, error) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("cannot scale %v", v)
+	}
+	return parseScaled(strconv.FormatFloat(v, 'f', -1, bitSize), scale)
+}
+
+// parseScaled parses a decimal string without an exponent into an integer of
+// the scale.
+func parseScaled(s string, scale int) (int64, error) {
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, fraction := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	roundUp := false
+	if len(fraction) > scale {
+		roundUp = fraction[scale] >= '5'
+		fraction = fraction[:scale]
+	}
+	digits := whole + fraction + strings.Repeat("0", scale-len(fraction))
+	scaled, err := strconv.ParseInt(digits, 10, 64)
+	if err == nil && roundUp {
+		if scaled == math.MaxInt64 {
+			err = strconv.ErrRange
+		}
+		scaled++
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s cannot be scaled to an int64 at scale %d", s, scale)
+	}
+	if negative {
+		scaled = -scaled
+	}
+	return scaled, nil
+}
+
+// UnscaleColumn returns the nearest floats of the scaled integers, for
+// rendering or computation where the exact value is not needed.
+func UnscaleColumn(scaled []int64, scale int) []float64 {
+	out := make([]float64, len(scaled))
+	divisor := math.Pow10(scale)
+	for i, v := range scaled {
+		out[i] = float64(v) / divisor
+	}
+	return out
+}
+
+// FormatScaled returns the exact decimal representation of a scaled integer.
+func FormatScaled(scaled int64, scale int) string {
+	if scale == 0 {
+		return strconv.FormatInt(scaled, 10)
+	}
+	sign := ""
+	digits := strconv.FormatInt(scaled, 10)
+	if scaled < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// ScaleColumns returns cs with the columns that are scaled in tbi converted
+// to scaled integers, or cs itself if there is nothing to convert.
+func ScaleColumns(cs *ColumnSeries, tbi *TimeBucketInfo) (*ColumnSeries, error) {
+	scales := map[string]int{}
+	for i, name := range tbi.GetElementNames() {
+		if scale := tbi.GetElementScales()[i]; scale != 0 && cs.Exists(name) {
+			if _, ok := cs.GetColumn(name).([]int64); !ok {
+				scales[name] = int(scale)
+			}
+		}
+	}
+	if len(scales) == 0 {
+		return cs, nil
+	}
+	// the columns are kept in order, as the records are serialized in it
+	out := NewColumnSeries()
+	for _, name := range cs.GetColumnNames() {
+		column := cs.GetColumn(name)
+		if scale, ok := scales[name]; ok {
+			scaled, err := ScaleColumn(column, scale)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %v", name, err)
+			}
+			column = scaled
+		}
+		out.AddColumn(name, column)
+	}
+	return out, nil
+}
+
+// parseDecimalType parses the type of a scaled integer column in a data
+// shape string, returning ok=false if it is not one.
+func parseDecimalType(typeName string) (scale int, ok bool, err error) {
+	lower := strings.ToLower(typeName)
+	if !strings.HasPrefix(lower, decimalTypeName+"(") || !strings.HasSuffix(lower, ")") {
+		return 0, false, nil
+	}
+	scale, err = strconv.Atoi(lower[len(decimalTypeName)+1 : len(lower)-1])
+	if err != nil {
+		return 0, true, fmt.Errorf("invalid scale in %s", typeName)
+	}
+	return scale, true, ValidateScale(scale)
+}
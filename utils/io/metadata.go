@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/alpacahq/marketstore/v4/utils"
+	"github.com/alpacahq/marketstore/v4/utils/io/blockfile"
 	"github.com/alpacahq/marketstore/v4/utils/log"
 )
 
@@ -54,6 +55,12 @@ type TimeBucketInfo struct {
 	variableRecordLength int32 // In case of variable recordType, the sum of field lengths in elementTypes
 	elementNames         []string
 	elementTypes         []EnumElementType
+	// elementScales are the scales of the scaled integer (decimal) columns,
+	// zero for the others
+	elementScales []int8
+	// codec is the compression of the blocks of the file, None if the
+	// file is not compressed
+	codec blockfile.Codec
 
 	once sync.Once
 }
@@ -79,6 +86,7 @@ func NewTimeBucketInfo(tf utils.Timeframe, path, description string, year int16,
 	f.nElements = int32(len(elementTypes))
 	f.elementTypes = elementTypes
 	f.elementNames = elementNames
+	f.elementScales = make([]int8, len(elementTypes))
 	f.recordType = recordType
 	if f.recordType == FIXED {
 		f.recordLength = int32(AlignedSize(f.getFieldRecordLength())) + 8 // add an 8-byte epoch field
@@ -141,11 +149,14 @@ func (f *TimeBucketInfo) GetDeepCopy() *TimeBucketInfo {
 		recordType:           f.recordType,
 		recordLength:         f.recordLength,
 		variableRecordLength: f.variableRecordLength,
+		codec:                f.codec,
 	}
 	fcopy.elementNames = make([]string, len(f.elementNames))
 	fcopy.elementTypes = make([]EnumElementType, len(f.elementTypes))
+	fcopy.elementScales = make([]int8, len(f.elementScales))
 	copy(fcopy.elementNames, f.elementNames)
 	copy(fcopy.elementTypes, f.elementTypes)
+	copy(fcopy.elementScales, f.elementScales)
 	return &fcopy
 }
 
@@ -245,6 +256,48 @@ func (f *TimeBucketInfo) SetElementTypes(newTypes []EnumElementType) error {
 	return nil
 }
 
+// GetElementScales returns the scales of the fields contained by the file
+// described by the given TimeBucketInfo, zero for those not scaled integers.
+// The scales are sized with the fields, when the TimeBucketInfo is created
+// or its header read, so that they are never reallocated on read.
+func (f *TimeBucketInfo) GetElementScales() []int8 {
+	f.once.Do(f.initFromFile)
+	return f.elementScales
+}
+
+// GetCodec returns the compression of the blocks of the file described by
+// the given TimeBucketInfo, blockfile.None if the file is not compressed
+func (f *TimeBucketInfo) GetCodec() blockfile.Codec {
+	f.once.Do(f.initFromFile)
+	return f.codec
+}
+
+// SetCodec sets the compression of the blocks of the file described by the
+// given TimeBucketInfo, before the file is created
+func (f *TimeBucketInfo) SetCodec(codec blockfile.Codec) {
+	f.once.Do(f.initFromFile)
+	f.codec = codec
+}
+
+// SetElementScale makes the INT64 field named name a scaled integer
+// (decimal) field of the scale, before the file is created
+func (f *TimeBucketInfo) SetElementScale(name string, scale int) error {
+	if err := ValidateScale(scale); err != nil {
+		return err
+	}
+	for i, elName := range f.GetElementNames() {
+		if elName != name {
+			continue
+		}
+		if f.elementTypes[i] != INT64 {
+			return fmt.Errorf("scaled column %s must be int64, not %s", name, f.elementTypes[i])
+		}
+		f.GetElementScales()[i] = int8(scale)
+		return nil
+	}
+	return fmt.Errorf("no column named %s", name)
+}
+
 func (f *TimeBucketInfo) readHeader(path string) (err error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -278,14 +331,12 @@ func (f *TimeBucketInfo) readHeader(path string) (err error) {
 		log.Error("Failed to read header part3 from file: %v - Error: %v", path, err)
 		return err
 	}
-	if EnumRecordType(header.RecordType) == VARIABLE {
-		// Read to end of header
-		start += int(header.NElements)
-		n, err = file.Read(buffer[start:Headersize])
-		if err != nil || n != (Headersize-start) {
-			log.Error("Failed to read header part4 from file: %v - Error: %v", path, err)
-			return err
-		}
+	// Read to end of header, which holds the element scales
+	start += int(header.NElements)
+	n, err = file.Read(buffer[start:Headersize])
+	if err != nil || n != (Headersize-start) {
+		log.Error("Failed to read header part4 from file: %v - Error: %v", path, err)
+		return err
 	}
 	f.load(header, path)
 	return nil
@@ -301,12 +352,14 @@ func (f *TimeBucketInfo) load(hp *Header, path string) {
 	f.nElements = int32(hp.NElements)
 	f.recordLength = int32(hp.RecordLength)
 	f.recordType = EnumRecordType(hp.RecordType)
-	f.elementNames = nil
-	f.elementTypes = nil
+	f.codec = blockfile.Codec(hp.Codec)
+	f.elementNames = make([]string, f.nElements)
+	f.elementTypes = make([]EnumElementType, f.nElements)
+	f.elementScales = make([]int8, f.nElements)
 	for i := 0; i < int(f.nElements); i++ {
-		baseName := string(bytes.Trim(hp.ElementNames[i][:], "\x00"))
-		f.elementNames = append(f.elementNames, baseName)
-		f.elementTypes = append(f.elementTypes, EnumElementType(hp.ElementTypes[i]))
+		f.elementNames[i] = string(bytes.Trim(hp.ElementNames[i][:], "\x00"))
+		f.elementTypes[i] = EnumElementType(hp.ElementTypes[i])
+		f.elementScales[i] = hp.ElementScales[i]
 	}
 }
 
@@ -326,11 +379,16 @@ type Header struct {
 	RecordType   int64
 	NElements    int64
 	RecordLength int64
-	reserved1    int64
+	// Codec is the compression of the blocks of the file, zero in the
+	// files not compressed, which were the reserved space
+	Codec int64
 	// Above is the fixed header portion - size is 312 Bytes = (7*8 + 256)
 	ElementNames [1024][32]byte
 	ElementTypes [1024]byte
-	reserved2    [365]int64
+	// ElementScales are zero in the files written before the scaled integer
+	// columns, which were the reserved space
+	ElementScales [1024]int8
+	reserved2     [237]int64
 }
 
 // WriteHeader writes the header described by a given TimeBucketInfo to the
@@ -360,6 +418,8 @@ func (hp *Header) Load(f *TimeBucketInfo) {
 	for i := 0; i < int(hp.NElements); i++ {
 		copy(hp.ElementNames[i][:], f.GetElementNames()[i])
 		hp.ElementTypes[i] = byte(f.GetElementTypes()[i])
+		hp.ElementScales[i] = f.GetElementScales()[i]
 	}
 	hp.RecordType = int64(f.GetRecordType())
+	hp.Codec = int64(f.GetCodec())
 }
@@ -2,8 +2,12 @@ package io
 
 // TODO: this is no longer numpy.  rename later.
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/alpacahq/marketstore/v4/utils/log"
 )
@@ -34,10 +38,73 @@ var typeStrMap = func() map[string]EnumElementType {
 // TypeStrToElemType converts a numpy type string (e.g. "i8", "f4") to an element type
 // ok=false is returned when unknown string is specified
 func TypeStrToElemType(typeStr string) (elemType EnumElementType, ok bool) {
+	if _, ok := stringWidth(typeStr); ok {
+		return STRING, true
+	}
 	elemType, ok = typeStrMap[typeStr]
 	return elemType, ok
 }
 
+/*
+	The string columns are sent as numpy fixed width unicode strings as long as
+	the longest one, e.g. U8 for up to 8 characters, each of them in 4 bytes
+	(UTF-32) and padded with zeros.
+*/
+
+// stringWidth returns the number of characters of a numpy unicode type string.
+func stringWidth(typeStr string) (int, bool) {
+	if !strings.HasPrefix(typeStr, "U") {
+		return 0, false
+	}
+	width, err := strconv.Atoi(typeStr[1:])
+	return width, err == nil && width > 0
+}
+
+func stringTypeStr(width int) string {
+	return "U" + strconv.Itoa(width)
+}
+
+// maxStringWidth returns the number of characters of the longest string,
+// at least 1.
+func maxStringWidth(col []string) int {
+	width := 1
+	for _, s := range col {
+		if n := utf8.RuneCountInString(s); n > width {
+			width = n
+		}
+	}
+	return width
+}
+
+func encodeStrings(col []string, width int) []byte {
+	data := make([]byte, len(col)*width*4)
+	for i, s := range col {
+		offset := i * width * 4
+		for _, r := range s {
+			binary.LittleEndian.PutUint32(data[offset:], uint32(r))
+			offset += 4
+		}
+	}
+	return data
+}
+
+func decodeStrings(data []byte, width int) []string {
+	col := make([]string, len(data)/(width*4))
+	runes := make([]rune, 0, width)
+	for i := range col {
+		runes = runes[:0]
+		for j := 0; j < width; j++ {
+			r := rune(binary.LittleEndian.Uint32(data[(i*width+j)*4:]))
+			if r == 0 {
+				break
+			}
+			runes = append(runes, r)
+		}
+		col[i] = string(runes)
+	}
+	return col
+}
+
 func ToTypeStr(elemType EnumElementType) (typeStr string, ok bool) {
 	typeStr, ok = typeMap[elemType]
 	return typeStr, ok
@@ -64,6 +131,12 @@ func NewNumpyDataset(cs *ColumnSeries) (nds *NumpyDataset, err error) {
 	nds.dataShapes = cs.GetDataShapes()
 	for i, name := range cs.GetColumnNames() {
 		nds.ColumnNames = append(nds.ColumnNames, name)
+		if col, ok := cs.GetColumn(name).([]string); ok {
+			width := maxStringWidth(col)
+			nds.ColumnData = append(nds.ColumnData, encodeStrings(col, width))
+			nds.ColumnTypes = append(nds.ColumnTypes, stringTypeStr(width))
+			continue
+		}
 		colBytes := CastToByteSlice(cs.GetColumn(name))
 		nds.ColumnData = append(nds.ColumnData, colBytes)
 		if typeStr, ok := typeMap[nds.dataShapes[i].Type]; !ok {
@@ -83,7 +156,7 @@ func (nds *NumpyDataset) Len() int {
 func (nds *NumpyDataset) buildDataShapes() ([]DataShape, error) {
 	etypes := []EnumElementType{}
 	for _, typeStr := range nds.ColumnTypes {
-		if typ, ok := typeStrMap[typeStr]; !ok {
+		if typ, ok := TypeStrToElemType(typeStr); !ok {
 			return nil, fmt.Errorf("unsupported type string %s", typeStr)
 		} else {
 			etypes = append(etypes, typ)
@@ -117,6 +190,12 @@ func (nds *NumpyDataset) ToColumnSeries(options ...int) (cs *ColumnSeries, err e
 		}
 	}
 	for i, shape := range nds.dataShapes {
+		if width, ok := stringWidth(nds.ColumnTypes[i]); ok {
+			start := startIndex * width * 4
+			end := start + length*width*4
+			cs.AddColumn(shape.Name, decodeStrings(nds.ColumnData[i][start:end], width))
+			continue
+		}
 		size := shape.Type.Size()
 		start := startIndex * size
 		end := start + length*size
@@ -184,8 +263,24 @@ func (nmds *NumpyMultiDataset) Append(cs *ColumnSeries, tbk TimeBucketKey) (err
 	nmds.Lengths[tbk.String()] = cs.Len()
 	nmds.Length += cs.Len()
 	for idx, col := range colSeriesNames {
+		if strs, ok := cs.GetColumn(col).([]string); ok {
+			nmds.appendStrings(idx, strs)
+			continue
+		}
 		newBuffer := CastToByteSlice(cs.GetColumn(col))
 		nmds.ColumnData[idx] = append(nmds.ColumnData[idx], newBuffer...)
 	}
 	return nil
 }
+
+// appendStrings appends the strings to the string column idx, widening the
+// column if they are longer than its width.
+func (nmds *NumpyMultiDataset) appendStrings(idx int, col []string) {
+	width, _ := stringWidth(nmds.ColumnTypes[idx])
+	if newWidth := maxStringWidth(col); newWidth > width {
+		nmds.ColumnData[idx] = encodeStrings(decodeStrings(nmds.ColumnData[idx], width), newWidth)
+		nmds.ColumnTypes[idx] = stringTypeStr(newWidth)
+		width = newWidth
+	}
+	nmds.ColumnData[idx] = append(nmds.ColumnData[idx], encodeStrings(col, width)...)
+}
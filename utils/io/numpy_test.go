@@ -99,3 +99,36 @@ func (s *TestSuite3) TestToColumnSeries(c *C) {
 	c.Check(err, Equals, nil)
 	c.Check(reflect.DeepEqual(csReturned, cs), Equals, true)
 }
+
+func (s *TestSuite3) TestStringColumns(c *C) {
+	cs := NewColumnSeries()
+	cs.AddColumn("Epoch", []int64{10, 11, 12})
+	cs.AddColumn("Tag", []string{"earnings", "", "fusão"})
+	nds, err := NewNumpyDataset(cs)
+	c.Assert(err, IsNil)
+	c.Check(nds.ColumnTypes, DeepEquals, []string{"i8", "U8"})
+	c.Check(len(nds.ColumnData[1]), Equals, 3*8*4)
+
+	tbk := NewTimeBucketKey("AAPL/1D/NEWS")
+	nmds, err := NewNumpyMultiDataset(nds, *tbk)
+	c.Assert(err, IsNil)
+
+	// widened for the longer strings of the next symbol
+	cs2 := NewColumnSeries()
+	cs2.AddColumn("Epoch", []int64{13})
+	cs2.AddColumn("Tag", []string{"stock split announced"})
+	tbk2 := NewTimeBucketKey("TSLA/1D/NEWS")
+	c.Assert(nmds.Append(cs2, *tbk2), IsNil)
+	c.Check(nmds.ColumnTypes[1], Equals, "U21")
+
+	csm, err := nmds.ToColumnSeriesMap()
+	c.Assert(err, IsNil)
+	c.Check(csm[*tbk].GetColumn("Tag"), DeepEquals, []string{"earnings", "", "fusão"})
+	c.Check(csm[*tbk2].GetColumn("Tag"), DeepEquals, []string{"stock split announced"})
+
+	elemType, ok := TypeStrToElemType("U21")
+	c.Check(elemType, Equals, STRING)
+	c.Check(ok, Equals, true)
+	_, ok = TypeStrToElemType("U0")
+	c.Check(ok, Equals, false)
+}
@@ -45,7 +45,8 @@ func (rows *Rows) GetColumn(colname string) (col interface{}) {
 				return getInt16Column(offset, int(rows.GetRowLen()), rows.GetNumRows(), rows.GetData())
 			case INT32:
 				return getInt32Column(offset, int(rows.GetRowLen()), rows.GetNumRows(), rows.GetData())
-			case EPOCH, INT64:
+			case EPOCH, INT64, STRING:
+				// the strings are returned as their references
 				return getInt64Column(offset, int(rows.GetRowLen()), rows.GetNumRows(), rows.GetData())
 			case UINT8:
 				return getUInt8Column(offset, int(rows.GetRowLen()), rows.GetNumRows(), rows.GetData())
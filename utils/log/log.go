@@ -8,15 +8,42 @@ import (
 )
 
 func init() {
+	SetFormat(JSON)
+}
+
+// Format is the output format of the logs.
+type Format string
+
+const (
+	// JSON writes a JSON object per line with the timestamp, level, caller
+	// and the rendered message in the "msg" field.  This is the default.
+	JSON Format = "json"
+	// Text writes the same fields as tab separated human readable lines.
+	Text Format = "text"
+)
+
+// output is where the logs are written, replaced by the tests.
+var output zapcore.WriteSyncer = zapcore.Lock(os.Stdout)
+
+// SetFormat replaces the global logger with the one writing the given format.
+func SetFormat(format Format) {
 	atom := zap.NewAtomicLevelAt(zapcore.DebugLevel)
 
 	encoderCfg := zap.NewProductionEncoderConfig()
 	encoderCfg.TimeKey = "timestamp"
 	encoderCfg.EncodeTime = zapcore.ISO8601TimeEncoder
 
+	var encoder zapcore.Encoder
+	if format == Text {
+		encoderCfg.EncodeLevel = zapcore.CapitalLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderCfg)
+	} else {
+		encoder = zapcore.NewJSONEncoder(encoderCfg)
+	}
+
 	logger := zap.New(zapcore.NewCore(
-		zapcore.NewJSONEncoder(encoderCfg),
-		zapcore.Lock(os.Stdout),
+		encoder,
+		output,
 		atom,
 	))
 
@@ -24,43 +51,19 @@ func init() {
 }
 
 func Debug(msg string, args ...interface{}) {
-	if logLevel <= DEBUG {
-		if len(args) > 0 {
-			zap.S().Debugf(msg, args...)
-		} else {
-			zap.S().Debug(msg)
-		}
-	}
+	logf(DEBUG, nil, msg, args)
 }
 
 func Info(msg string, args ...interface{}) {
-	if logLevel <= INFO {
-		if len(args) > 0 {
-			zap.S().Infof(msg, args...)
-		} else {
-			zap.S().Info(msg)
-		}
-	}
+	logf(INFO, nil, msg, args)
 }
 
 func Warn(msg string, args ...interface{}) {
-	if logLevel <= WARNING {
-		if len(args) > 0 {
-			zap.S().Warnf(msg, args...)
-		} else {
-			zap.S().Warn(msg)
-		}
-	}
+	logf(WARNING, nil, msg, args)
 }
 
 func Error(msg string, args ...interface{}) {
-	if logLevel <= ERROR {
-		if len(args) > 0 {
-			zap.S().Errorf(msg, args...)
-		} else {
-			zap.S().Error(msg)
-		}
-	}
+	logf(ERROR, nil, msg, args)
 }
 
 func Fatal(msg string, args ...interface{}) {
@@ -71,6 +74,65 @@ func Fatal(msg string, args ...interface{}) {
 	}
 }
 
+// Logger logs with a key/value context, which the JSON format writes as
+// fields next to "msg" and the text format appends to the line as a JSON
+// object.
+type Logger struct {
+	fields []interface{}
+}
+
+// With returns a Logger adding the alternating keys and values to the
+// messages it logs, e.g.
+//
+//	log.With("key", tbk.String(), "rows", n).Info("wrote the %s records", kind)
+func With(keysAndValues ...interface{}) *Logger {
+	return &Logger{fields: keysAndValues}
+}
+
+// With returns a Logger adding the keys and values to the context of l.
+func (l *Logger) With(keysAndValues ...interface{}) *Logger {
+	fields := make([]interface{}, 0, len(l.fields)+len(keysAndValues))
+	fields = append(fields, l.fields...)
+	return &Logger{fields: append(fields, keysAndValues...)}
+}
+
+func (l *Logger) Debug(msg string, args ...interface{}) {
+	logf(DEBUG, l.fields, msg, args)
+}
+
+func (l *Logger) Info(msg string, args ...interface{}) {
+	logf(INFO, l.fields, msg, args)
+}
+
+func (l *Logger) Warn(msg string, args ...interface{}) {
+	logf(WARNING, l.fields, msg, args)
+}
+
+func (l *Logger) Error(msg string, args ...interface{}) {
+	logf(ERROR, l.fields, msg, args)
+}
+
+func logf(level Level, fields []interface{}, msg string, args []interface{}) {
+	if logLevel > level {
+		return
+	}
+	logger := zap.S()
+	if len(fields) > 0 {
+		logger = logger.With(fields...)
+	}
+	// the message is logged as is without args, even with a literal %
+	switch level {
+	case DEBUG:
+		logger.Debugf(msg, args...)
+	case INFO:
+		logger.Infof(msg, args...)
+	case WARNING:
+		logger.Warnf(msg, args...)
+	default:
+		logger.Errorf(msg, args...)
+	}
+}
+
 func SetLevel(level Level) {
 	logLevel = level
 }
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap/zapcore"
+	. "gopkg.in/check.v1"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type LogTestSuite struct {
+	buf    *bytes.Buffer
+	stdout zapcore.WriteSyncer
+}
+
+var _ = Suite(&LogTestSuite{})
+
+func (s *LogTestSuite) SetUpTest(c *C) {
+	s.buf = &bytes.Buffer{}
+	s.stdout = output
+	output = zapcore.AddSync(s.buf)
+	SetLevel(DEBUG)
+}
+
+func (s *LogTestSuite) TearDownTest(c *C) {
+	output = s.stdout
+	SetFormat(JSON)
+	SetLevel(DEBUG)
+}
+
+// lines returns the lines logged since the start of the test.
+func (s *LogTestSuite) lines() []string {
+	return strings.Split(strings.TrimSuffix(s.buf.String(), "\n"), "\n")
+}
+
+func (s *LogTestSuite) TestJSON(c *C) {
+	SetFormat(JSON)
+	Info("wrote %d rows to %s", 3, "AAPL/1Min/OHLCV")
+	With("key", "AAPL/1Min/OHLCV", "rows", 3).Warn("late records, 100% dropped")
+
+	lines := s.lines()
+	c.Assert(lines, HasLen, 2)
+
+	var entry map[string]interface{}
+	c.Assert(json.Unmarshal([]byte(lines[0]), &entry), IsNil)
+	c.Assert(entry["level"], Equals, "info")
+	c.Assert(entry["msg"], Equals, "wrote 3 rows to AAPL/1Min/OHLCV")
+	c.Assert(entry["timestamp"], Matches, `\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d.*`)
+
+	entry = nil
+	c.Assert(json.Unmarshal([]byte(lines[1]), &entry), IsNil)
+	c.Assert(entry["level"], Equals, "warn")
+	c.Assert(entry["msg"], Equals, "late records, 100% dropped")
+	c.Assert(entry["key"], Equals, "AAPL/1Min/OHLCV")
+	c.Assert(entry["rows"], Equals, float64(3))
+}
+
+func (s *LogTestSuite) TestText(c *C) {
+	SetFormat(Text)
+	Error("failed to write %s: %v", "AAPL/1Min/OHLCV", "disk full")
+	With("key", "AAPL/1Min/OHLCV").With("rows", 3).Debug("flushed")
+
+	lines := s.lines()
+	c.Assert(lines, HasLen, 2)
+	c.Assert(lines[0], Matches, `\d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\S*\tERROR\tfailed to write AAPL/1Min/OHLCV: disk full`)
+	c.Assert(lines[1], Matches, `\S+\tDEBUG\tflushed\t\{"key": "AAPL/1Min/OHLCV", "rows": 3\}`)
+}
+
+func (s *LogTestSuite) TestLevel(c *C) {
+	SetFormat(Text)
+	SetLevel(WARNING)
+	Debug("debug")
+	Info("info")
+	With("key", "AAPL/1Min/OHLCV").Info("info with context")
+	Warn("warning")
+	With("key", "AAPL/1Min/OHLCV").Error("error with context")
+
+	lines := s.lines()
+	c.Assert(lines, HasLen, 2)
+	c.Assert(lines[0], Matches, `\S+\tWARN\twarning`)
+	c.Assert(lines[1], Matches, `\S+\tERROR\terror with context\t.*`)
+}
@@ -106,6 +106,48 @@ type CandleDuration struct {
 	duration   time.Duration
 	suffix     string
 	multiplier int
+	weekStart  time.Weekday
+}
+
+// weekEpoch is the reference day weekly candles are counted from.
+// It is a Monday, so that single weeks match the ISO weeks by default.
+var weekEpoch = time.Date(1970, 1, 5, 0, 0, 0, 0, time.UTC)
+
+// SetWeekStart sets the day weekly candles begin on (Monday by default).
+func (cd *CandleDuration) SetWeekStart(day time.Weekday) *CandleDuration {
+	cd.weekStart = day
+	return cd
+}
+
+// WeekStart returns the day weekly candles begin on.
+func (cd *CandleDuration) WeekStart() time.Weekday {
+	return cd.weekStart
+}
+
+// alignsToDay is true for the intraday windows that do not divide a day evenly,
+// such as 7Min, whose candles are counted from the beginning of each day.
+func (cd *CandleDuration) alignsToDay() bool {
+	return cd.duration > 0 && cd.duration < Day && Day%cd.duration != 0
+}
+
+func startOfDay(ts time.Time) time.Time {
+	yy, mm, dd := ts.Date()
+	return time.Date(yy, mm, dd, 0, 0, 0, 0, ts.Location())
+}
+
+// truncateWeek returns the beginning of the (multi-)week window of ts.
+func (cd *CandleDuration) truncateWeek(ts time.Time) time.Time {
+	day := startOfDay(ts)
+	yy, mm, dd := day.Date()
+	// count the days in UTC to be free from DST changes
+	days := int(time.Date(yy, mm, dd, 0, 0, 0, 0, time.UTC).Sub(weekEpoch) / Day)
+	days -= (int(cd.weekStart) - int(time.Monday) + 7) % 7
+	period := 7 * cd.multiplier
+	offset := days % period
+	if offset < 0 {
+		offset += period
+	}
+	return day.AddDate(0, 0, -offset)
 }
 
 func (cd *CandleDuration) IsWithin(ts, start time.Time) bool {
@@ -115,11 +157,7 @@ func (cd *CandleDuration) IsWithin(ts, start time.Time) bool {
 		yy1, mm1, dd1 := start.In(ts.Location()).Date()
 		return yy0 == yy1 && mm0 == mm1 && dd0 == dd1
 	case "W":
-		tsY, tsW := ts.ISOWeek()
-		sY, sW := start.ISOWeek()
-		if tsY == sY && tsW == sW {
-			return true
-		}
+		return cd.truncateWeek(ts).Equal(cd.truncateWeek(start.In(ts.Location())))
 	case "M":
 		if ts.Year() == start.Year() {
 			if ts.Month() == start.Month() {
@@ -145,6 +183,9 @@ func (cd *CandleDuration) IsWithin(ts, start time.Time) bool {
 			return true
 		}
 	default:
+		if cd.alignsToDay() {
+			return cd.Truncate(ts).Equal(start)
+		}
 		if ts.Truncate(cd.duration) == start {
 			return true
 		}
@@ -159,9 +200,15 @@ func (cd *CandleDuration) Truncate(ts time.Time) time.Time {
 	case "D":
 		yy, mm, dd := ts.Date()
 		return time.Date(yy, mm, dd, 0, 0, 0, 0, ts.Location())
+	case "W":
+		return cd.truncateWeek(ts)
 	case "M":
 		return time.Date(ts.Year(), ts.Month(), 1, 0, 0, 0, 0, ts.Location())
 	default:
+		if cd.alignsToDay() {
+			day := startOfDay(ts)
+			return day.Add(ts.Sub(day) / cd.duration * cd.duration)
+		}
 		return ts.Truncate(cd.duration)
 	}
 }
@@ -184,6 +231,17 @@ func (cd *CandleDuration) Ceil(ts time.Time) time.Time {
 		}
 		return time.Date(year, month, 1, 0, 0, 0, 0, ts.Location())
 	}
+	if cd.suffix == "W" {
+		return cd.truncateWeek(ts).AddDate(0, 0, 7*cd.multiplier)
+	}
+	if cd.alignsToDay() {
+		// the last candle of the day is cut at midnight
+		ceil := cd.Truncate(ts).Add(cd.duration)
+		if nextDay := startOfDay(ts).AddDate(0, 0, 1); ceil.After(nextDay) {
+			return nextDay
+		}
+		return ceil
+	}
 
 	return (ts.Add(cd.duration)).Truncate(cd.duration)
 }
@@ -227,6 +285,7 @@ func CandleDurationFromString(tf string) (cd *CandleDuration) {
 		multiplier: mult,
 		suffix:     suffix,
 		duration:   time.Duration(mult) * suffixDefs[suffix],
+		weekStart:  time.Monday,
 	}
 }
 
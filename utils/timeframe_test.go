@@ -105,3 +105,45 @@ func (s *UtilsTestSuite) TestCandleDuration(c *C) {
 	cd = CandleDurationFromString("abc")
 	c.Assert(cd, IsNil)
 }
+
+func (s *UtilsTestSuite) TestCandleDurationWeekStart(c *C) {
+	// Monday-based weeks across the year boundary
+	cd := CandleDurationFromString("1W")
+	c.Assert(cd.WeekStart(), Equals, time.Monday)
+	val := time.Date(2020, 1, 2, 13, 0, 0, 0, time.UTC)
+	c.Assert(cd.Truncate(val), Equals, time.Date(2019, 12, 30, 0, 0, 0, 0, time.UTC))
+	c.Assert(cd.Ceil(val), Equals, time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC))
+	c.Assert(cd.IsWithin(time.Date(2019, 12, 31, 10, 0, 0, 0, time.UTC), cd.Truncate(val)), Equals, true)
+	c.Assert(cd.IsWithin(time.Date(2019, 12, 29, 10, 0, 0, 0, time.UTC), cd.Truncate(val)), Equals, false)
+
+	// Sunday-based weeks in New York
+	loc, _ := time.LoadLocation("America/New_York")
+	cd = CandleDurationFromString("1W").SetWeekStart(time.Sunday)
+	val = time.Date(2021, 1, 1, 10, 0, 0, 0, loc)
+	c.Assert(cd.Truncate(val), Equals, time.Date(2020, 12, 27, 0, 0, 0, 0, loc))
+	c.Assert(cd.Ceil(val), Equals, time.Date(2021, 1, 3, 0, 0, 0, 0, loc))
+	c.Assert(cd.IsWithin(time.Date(2020, 12, 27, 0, 0, 0, 0, loc), cd.Truncate(val)), Equals, true)
+	c.Assert(cd.IsWithin(time.Date(2021, 1, 3, 0, 0, 0, 0, loc), cd.Truncate(val)), Equals, false)
+	c.Assert(cd.Truncate(time.Date(2020, 12, 27, 0, 0, 0, 0, loc)), Equals, time.Date(2020, 12, 27, 0, 0, 0, 0, loc))
+
+	// two-week windows are contiguous
+	cd = CandleDurationFromString("2W")
+	start := cd.Truncate(time.Date(2019, 12, 25, 0, 0, 0, 0, time.UTC))
+	c.Assert(cd.Ceil(start).Sub(start), Equals, 2*Week)
+	c.Assert(cd.Truncate(cd.Ceil(start).Add(-time.Second)), Equals, start)
+}
+
+func (s *UtilsTestSuite) TestCandleDurationNonDivisor(c *C) {
+	// 7Min does not divide a day, so the candles are counted from midnight
+	cd := CandleDurationFromString("7Min")
+	val := time.Date(2020, 3, 2, 9, 33, 0, 0, time.UTC)
+	c.Assert(cd.Truncate(val), Equals, time.Date(2020, 3, 2, 9, 27, 0, 0, time.UTC))
+	c.Assert(cd.Ceil(val), Equals, time.Date(2020, 3, 2, 9, 34, 0, 0, time.UTC))
+	c.Assert(cd.IsWithin(time.Date(2020, 3, 2, 9, 30, 0, 0, time.UTC), cd.Truncate(val)), Equals, true)
+	c.Assert(cd.IsWithin(time.Date(2020, 3, 2, 9, 34, 0, 0, time.UTC), cd.Truncate(val)), Equals, false)
+
+	// the first candle of the day starts at midnight and the last one is cut there
+	c.Assert(cd.Truncate(time.Date(2020, 3, 3, 0, 3, 0, 0, time.UTC)), Equals, time.Date(2020, 3, 3, 0, 0, 0, 0, time.UTC))
+	c.Assert(cd.Truncate(time.Date(2020, 3, 2, 23, 59, 0, 0, time.UTC)), Equals, time.Date(2020, 3, 2, 23, 55, 0, 0, time.UTC))
+	c.Assert(cd.Ceil(time.Date(2020, 3, 2, 23, 59, 0, 0, time.UTC)), Equals, time.Date(2020, 3, 3, 0, 0, 0, 0, time.UTC))
+}
@@ -2,8 +2,29 @@
 
 package utils
 
+import "runtime"
+
+// The version of the build, injected with -ldflags "-X ..." by the Makefile.
 var (
 	Tag        string
 	GitHash    string
 	BuildStamp string
 )
+
+// VersionInfo identifies the build of the running binary.
+type VersionInfo struct {
+	Version   string `json:"version"`
+	GitHash   string `json:"git_hash"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// GetVersionInfo returns the version info of the running binary.
+func GetVersionInfo() VersionInfo {
+	return VersionInfo{
+		Version:   Tag,
+		GitHash:   GitHash,
+		BuildTime: BuildStamp,
+		GoVersion: runtime.Version(),
+	}
+}